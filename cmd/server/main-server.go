@@ -16,6 +16,7 @@ import (
 	"github.com/commandlinedev/starterm/pkg/authkey"
 	"github.com/commandlinedev/starterm/pkg/blockcontroller"
 	"github.com/commandlinedev/starterm/pkg/blocklogger"
+	"github.com/commandlinedev/starterm/pkg/cmdhistory"
 	"github.com/commandlinedev/starterm/pkg/filestore"
 	"github.com/commandlinedev/starterm/pkg/panichandler"
 	"github.com/commandlinedev/starterm/pkg/remote/conncontroller"
@@ -32,6 +33,7 @@ import (
 	"github.com/commandlinedev/starterm/pkg/util/utilfn"
 	"github.com/commandlinedev/starterm/pkg/wcloud"
 	"github.com/commandlinedev/starterm/pkg/web"
+	"github.com/commandlinedev/starterm/pkg/web/webapi"
 	"github.com/commandlinedev/starterm/pkg/wps"
 	"github.com/commandlinedev/starterm/pkg/wshrpc"
 	"github.com/commandlinedev/starterm/pkg/wshrpc/wshremote"
@@ -325,6 +327,11 @@ func main() {
 	log.Printf("star version: %s (%s)\n", StarVersion, BuildTime)
 	log.Printf("star data dir: %s\n", starbase.GetStarDataDir())
 	log.Printf("star config dir: %s\n", starbase.GetStarConfigDir())
+	err = wshutil.InitJwtKeys()
+	if err != nil {
+		log.Printf("error initializing jwt keys: %v\n", err)
+		return
+	}
 	err = filestore.InitFilestore()
 	if err != nil {
 		log.Printf("error initializing filestore: %v\n", err)
@@ -336,6 +343,7 @@ func main() {
 		return
 	}
 	panichandler.PanicTelemetryHandler = panicTelemetryHandler
+	conncontroller.ReconnectedHandler = blockcontroller.RestartDroppedConnControllers
 	go func() {
 		defer func() {
 			panichandler.PanicHandler("InitCustomShellStartupFiles", recover())
@@ -363,6 +371,8 @@ func main() {
 	go stdinReadWatch()
 	go telemetryLoop()
 	go updateTelemetryCountsLoop()
+	go cmdhistory.RunCleanLoop()
+	go wshserver.RunConnServerRekeyLoop()
 	startupActivityUpdate() // must be after startConfigWatcher()
 	blocklogger.InitBlockLogger()
 
@@ -390,6 +400,13 @@ func main() {
 		fmt.Fprintf(os.Stderr, "STARSRV-ESTART ws:%s web:%s version:%s buildtime:%s\n", wsListener.Addr(), webListener.Addr(), StarVersion, BuildTime)
 	}()
 	go wshutil.RunWshRpcOverListener(unixListener)
+	apiListener, err := webapi.MakeApiListener()
+	if err != nil {
+		// the api is optional, keep running without it
+		log.Printf("error creating api listener: %v\n", err)
+	} else if apiListener != nil {
+		go webapi.RunApiServer(apiListener)
+	}
 	web.RunWebServer(webListener) // blocking
 	runtime.KeepAlive(starLock)
 }
@@ -0,0 +1,76 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
+	"github.com/spf13/cobra"
+)
+
+var aiModelsCmd = &cobra.Command{
+	Use:   "aimodels",
+	Short: "list the models installed in a local ollama server",
+	Long: `List the models installed in a local ollama server.
+With --presets an AI preset is written for every model (to presets/ollama.json, which is replaced each time).`,
+	Example: "  wsh aimodels\n  wsh aimodels --presets\n  wsh aimodels --baseurl http://gpubox:11434 --presets",
+	Args:    cobra.NoArgs,
+	RunE:    activityWrap("aimodels", aiModelsRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var (
+	aiModelsBaseURL string
+	aiModelsPresets bool
+	aiModelsJson    bool
+)
+
+func init() {
+	rootCmd.AddCommand(aiModelsCmd)
+	aiModelsCmd.Flags().StringVar(&aiModelsBaseURL, "baseurl", "", "ollama base url (default http://localhost:11434)")
+	aiModelsCmd.Flags().BoolVar(&aiModelsPresets, "presets", false, "write an AI preset for every model")
+	aiModelsCmd.Flags().BoolVar(&aiModelsJson, "json", false, "output as json")
+}
+
+func formatModelSize(size int64) string {
+	if size >= 1e9 {
+		return fmt.Sprintf("%.1f GB", float64(size)/1e9)
+	}
+	return fmt.Sprintf("%d MB", size/1e6)
+}
+
+func aiModelsRun(cmd *cobra.Command, args []string) error {
+	data := wshrpc.CommandStarAiListModelsData{
+		APIType:      "ollama",
+		BaseURL:      aiModelsBaseURL,
+		WritePresets: aiModelsPresets,
+	}
+	rtn, err := wshclient.StarAiListModelsCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: 10000})
+	if err != nil {
+		return fmt.Errorf("listing models: %w", err)
+	}
+	if aiModelsJson {
+		barr, err := json.MarshalIndent(rtn.Models, "", "  ")
+		if err != nil {
+			return err
+		}
+		WriteStdout("%s\n", string(barr))
+	} else {
+		for _, model := range rtn.Models {
+			modTime := time.UnixMilli(model.ModifiedTs).Format("2006-01-02")
+			WriteStdout("%-32s %-8s %-8s %10s  %s\n", model.Name, model.ParameterSize, model.Quantization, formatModelSize(model.Size), modTime)
+		}
+		if len(rtn.Models) == 0 {
+			WriteStderr("no models installed (use 'ollama pull <model>')\n")
+		}
+	}
+	if rtn.PresetsFile != "" {
+		WriteStderr("wrote %d presets to %s\n", len(rtn.Models), rtn.PresetsFile)
+	}
+	return nil
+}
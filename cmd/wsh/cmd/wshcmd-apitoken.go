@@ -0,0 +1,111 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
+	"github.com/spf13/cobra"
+)
+
+var apiTokenCmd = &cobra.Command{
+	Use:   "apitoken",
+	Short: "manage tokens for the local automation api",
+	Long:  "Manage tokens for the local automation api (enable the api with the api:enabled setting, it starts with the next restart).",
+}
+
+var apiTokenCreateCmd = &cobra.Command{
+	Use:     "create [name]",
+	Short:   "create an api token (replaces an existing token with the same name)",
+	Long:    "Create an api token with the given scopes (" + strings.Join(wshrpc.AllApiScopes, ", ") + "). The token is only shown once, only its hash is stored.",
+	Args:    cobra.ExactArgs(1),
+	RunE:    apiTokenCreateRun,
+	PreRunE: preRunSetupRpcClient,
+}
+
+var apiTokenListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "list api tokens",
+	Args:    cobra.NoArgs,
+	RunE:    apiTokenListRun,
+	PreRunE: preRunSetupRpcClient,
+}
+
+var apiTokenRmCmd = &cobra.Command{
+	Use:     "rm [name]",
+	Short:   "delete an api token",
+	Args:    cobra.ExactArgs(1),
+	RunE:    apiTokenRmRun,
+	PreRunE: preRunSetupRpcClient,
+}
+
+var apiTokenScopes []string
+
+func init() {
+	rootCmd.AddCommand(apiTokenCmd)
+	apiTokenCmd.AddCommand(apiTokenCreateCmd)
+	apiTokenCmd.AddCommand(apiTokenListCmd)
+	apiTokenCmd.AddCommand(apiTokenRmCmd)
+	apiTokenCreateCmd.Flags().StringSliceVarP(&apiTokenScopes, "scope", "s", []string{wshrpc.ApiScope_Read}, "token scopes ("+strings.Join(wshrpc.AllApiScopes, ", ")+")")
+}
+
+func apiTokenCreateRun(cmd *cobra.Command, args []string) error {
+	data := wshrpc.CommandApiTokenCreateData{Name: args[0], Scopes: apiTokenScopes}
+	rtn, err := wshclient.ApiTokenCreateCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: 5000})
+	if err != nil {
+		return fmt.Errorf("creating api token: %w", err)
+	}
+	WriteStdout("%s\n", rtn.Token)
+	if rtn.Url != "" {
+		WriteStderr("api running at %s\n", rtn.Url)
+	} else {
+		WriteStderr("the api is not running (set api:enabled and restart)\n")
+	}
+	return nil
+}
+
+func apiTokenListRun(cmd *cobra.Command, args []string) error {
+	fullConfig, err := wshclient.GetFullConfigCommand(RpcClient, &wshrpc.RpcOpts{Timeout: 2000})
+	if err != nil {
+		return fmt.Errorf("getting config: %w", err)
+	}
+	if len(fullConfig.ApiTokens) == 0 {
+		WriteStdout("no api tokens\n")
+		return nil
+	}
+	var names []string
+	for name := range fullConfig.ApiTokens {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "NAME\tSCOPES\tCREATED\n")
+	for _, name := range names {
+		apiToken := fullConfig.ApiTokens[name]
+		created := "-"
+		if apiToken.CreatedTs > 0 {
+			created = time.UnixMilli(apiToken.CreatedTs).Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, strings.Join(apiToken.Scopes, ","), created)
+	}
+	tw.Flush()
+	WriteStdout("%s", sb.String())
+	return nil
+}
+
+func apiTokenRmRun(cmd *cobra.Command, args []string) error {
+	err := wshclient.ApiTokenDeleteCommand(RpcClient, args[0], &wshrpc.RpcOpts{Timeout: 5000})
+	if err != nil {
+		return fmt.Errorf("deleting api token: %w", err)
+	}
+	WriteStdout("api token %q deleted\n", args[0])
+	return nil
+}
@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/commandlinedev/starterm/pkg/remote"
@@ -66,6 +67,41 @@ var connEnsureCmd = &cobra.Command{
 	PreRunE: preRunSetupRpcClient,
 }
 
+var connForwardCmd = &cobra.Command{
+	Use:   "forward",
+	Short: "manage port forwards on an ssh connection",
+	Long:  "Commands to manage ad-hoc port forwards (tunnels) on a live SSH connection. Forwards from ssh:localforward, ssh:remoteforward and ssh:dynamicforward (or LocalForward, RemoteForward and DynamicForward in ~/.ssh/config) are started automatically.",
+}
+
+var connForwardAddCmd = &cobra.Command{
+	Use:     "add [CONNECTION] (-L spec | -R spec | -D spec)",
+	Short:   "add a port forward to a connection",
+	Long:    "add a port forward to a connection.  -L [bind_address:]port:host:hostport forwards a local port to host:hostport (from the remote), -R [bind_address:]port:host:hostport forwards a remote port to host:hostport (from this machine), -D [bind_address:]port starts a local SOCKS proxy",
+	Args:    cobra.MaximumNArgs(1),
+	RunE:    connForwardAddRun,
+	PreRunE: preRunSetupRpcClient,
+}
+
+var connForwardListCmd = &cobra.Command{
+	Use:     "list [CONNECTION]",
+	Short:   "list the port forwards of a connection (or all connections)",
+	Args:    cobra.MaximumNArgs(1),
+	RunE:    connForwardListRun,
+	PreRunE: preRunSetupRpcClient,
+}
+
+var connForwardRmCmd = &cobra.Command{
+	Use:     "rm CONNECTION FORWARDID",
+	Short:   "remove a port forward from a connection",
+	Args:    cobra.ExactArgs(2),
+	RunE:    connForwardRmRun,
+	PreRunE: preRunSetupRpcClient,
+}
+
+var connForwardLocal string
+var connForwardRemote string
+var connForwardDynamic string
+
 func init() {
 	rootCmd.AddCommand(connCmd)
 	connCmd.AddCommand(connStatusCmd)
@@ -74,6 +110,13 @@ func init() {
 	connCmd.AddCommand(connDisconnectAllCmd)
 	connCmd.AddCommand(connConnectCmd)
 	connCmd.AddCommand(connEnsureCmd)
+	connCmd.AddCommand(connForwardCmd)
+	connForwardCmd.AddCommand(connForwardAddCmd)
+	connForwardCmd.AddCommand(connForwardListCmd)
+	connForwardCmd.AddCommand(connForwardRmCmd)
+	connForwardAddCmd.Flags().StringVarP(&connForwardLocal, "local", "L", "", "local forward [bind_address:]port:host:hostport")
+	connForwardAddCmd.Flags().StringVarP(&connForwardRemote, "remote", "R", "", "remote forward [bind_address:]port:host:hostport")
+	connForwardAddCmd.Flags().StringVarP(&connForwardDynamic, "dynamic", "D", "", "dynamic (socks) forward [bind_address:]port")
 }
 
 func validateConnectionName(name string) error {
@@ -210,3 +253,113 @@ func connEnsureRun(cmd *cobra.Command, args []string) error {
 	WriteStdout("wsh ensured on connection %q\n", connName)
 	return nil
 }
+
+func getForwardConnName(args []string) (string, error) {
+	if len(args) == 0 {
+		if RpcContext.Conn == "" {
+			return "", fmt.Errorf("no connection specified")
+		}
+		return RpcContext.Conn, nil
+	}
+	connName := args[0]
+	if err := validateConnectionName(connName); err != nil {
+		return "", err
+	}
+	return connName, nil
+}
+
+func connForwardAddRun(cmd *cobra.Command, args []string) error {
+	connName, err := getForwardConnName(args)
+	if err != nil {
+		return err
+	}
+	var data wshrpc.CommandConnForwardAddData
+	numSpecs := 0
+	if connForwardLocal != "" {
+		data = wshrpc.CommandConnForwardAddData{Type: "local", Spec: connForwardLocal}
+		numSpecs++
+	}
+	if connForwardRemote != "" {
+		data = wshrpc.CommandConnForwardAddData{Type: "remote", Spec: connForwardRemote}
+		numSpecs++
+	}
+	if connForwardDynamic != "" {
+		data = wshrpc.CommandConnForwardAddData{Type: "dynamic", Spec: connForwardDynamic}
+		numSpecs++
+	}
+	if numSpecs != 1 {
+		OutputHelpMessage(cmd)
+		return fmt.Errorf("exactly one of -L, -R, or -D must be specified")
+	}
+	data.ConnName = connName
+	fwd, err := wshclient.ConnForwardAddCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: 10000})
+	if err != nil {
+		return fmt.Errorf("adding forward: %w", err)
+	}
+	WriteStdout("added %s forward %d on %q (%s)\n", fwd.Type, fwd.ForwardId, connName, formatForwardAddrs(fwd))
+	return nil
+}
+
+func formatForwardAddrs(fwd wshrpc.ConnForwardStatus) string {
+	if fwd.TargetAddr == "" {
+		return fwd.BindAddr
+	}
+	return fwd.BindAddr + " -> " + fwd.TargetAddr
+}
+
+func connForwardListRun(cmd *cobra.Command, args []string) error {
+	var connName string
+	if len(args) > 0 {
+		connName = args[0]
+		if err := validateConnectionName(connName); err != nil {
+			return err
+		}
+	}
+	allResp, err := wshclient.ConnStatusCommand(RpcClient, nil)
+	if err != nil {
+		return fmt.Errorf("getting connection status: %w", err)
+	}
+	var numForwards int
+	for _, conn := range allResp {
+		if connName != "" && conn.Connection != connName {
+			continue
+		}
+		for _, fwd := range conn.Forwards {
+			if numForwards == 0 {
+				WriteStdout("%-30s %-4s %-8s %-7s %-7s %s\n", "connection", "id", "type", "source", "status", "forward")
+				WriteStdout("------------------------------------------------------------------------------\n")
+			}
+			numForwards++
+			str := fmt.Sprintf("%-30s %-4d %-8s %-7s %-7s %s", conn.Connection, fwd.ForwardId, fwd.Type, fwd.Source, fwd.Status, formatForwardAddrs(fwd))
+			if fwd.BindAddr == "" {
+				str += fmt.Sprintf("%q", fwd.Spec)
+			}
+			if fwd.Error != "" {
+				str += fmt.Sprintf(" (%s)", fwd.Error)
+			}
+			WriteStdout("%s\n", str)
+		}
+	}
+	if numForwards == 0 {
+		WriteStdout("no forwards\n")
+	}
+	return nil
+}
+
+func connForwardRmRun(cmd *cobra.Command, args []string) error {
+	connName := args[0]
+	if err := validateConnectionName(connName); err != nil {
+		return err
+	}
+	forwardId, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid forward id %q", args[1])
+	}
+	data := wshrpc.CommandConnForwardRmData{ConnName: connName, ForwardId: forwardId}
+	err = wshclient.ConnForwardRmCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: 10000})
+	if err != nil {
+		return fmt.Errorf("removing forward: %w", err)
+	}
+	WriteStdout("removed forward %d from %q\n", forwardId, connName)
+	return nil
+}
@@ -65,11 +65,12 @@ func MakeRemoteUnixListener() (net.Listener, error) {
 func handleNewListenerConn(conn net.Conn, router *wshutil.WshRouter) {
 	var routeIdContainer atomic.Pointer[string]
 	proxy := wshutil.MakeRpcProxy()
+	framing := packetparser.MakeFraming(conn, false, false)
 	go func() {
 		defer func() {
 			panichandler.PanicHandler("handleNewListenerConn:AdaptOutputChToStream", recover())
 		}()
-		writeErr := wshutil.AdaptOutputChToStream(proxy.ToRemoteCh, conn)
+		writeErr := wshutil.AdaptOutputChToStream(proxy.ToRemoteCh, conn, framing)
 		if writeErr != nil {
 			log.Printf("error writing to domain socket: %v\n", writeErr)
 		}
@@ -96,7 +97,7 @@ func handleNewListenerConn(conn net.Conn, router *wshutil.WshRouter) {
 				router.InjectMessage(disposeBytes, *routeIdPtr)
 			}
 		}()
-		wshutil.AdaptStreamToMsgCh(conn, proxy.FromRemoteCh)
+		wshutil.AdaptStreamToMsgCh(conn, proxy.FromRemoteCh, framing)
 	}()
 	routeId, err := proxy.HandleClientProxyAuth(router)
 	if err != nil {
@@ -149,13 +150,15 @@ func serverRunRouter(jwtToken string) error {
 	router := wshutil.NewWshRouter()
 	termProxy := wshutil.MakeRpcProxy()
 	rawCh := make(chan []byte, wshutil.DefaultOutputChSize)
-	go packetparser.Parse(os.Stdin, termProxy.FromRemoteCh, rawCh)
+	// the main server offers binary framing, we answer it
+	framing := packetparser.MakeFraming(os.Stdout, true, false)
+	go packetparser.Parse(os.Stdin, termProxy.FromRemoteCh, rawCh, framing)
 	go func() {
 		defer func() {
 			panichandler.PanicHandler("serverRunRouter:WritePackets", recover())
 		}()
 		for msg := range termProxy.ToRemoteCh {
-			packetparser.WritePacket(os.Stdout, msg)
+			framing.WriteMsg(msg)
 		}
 	}()
 	go func() {
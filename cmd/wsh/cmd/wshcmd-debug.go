@@ -5,9 +5,15 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
 	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
+	"github.com/commandlinedev/starterm/pkg/wshutil"
 )
 
 var debugCmd = &cobra.Command{
@@ -38,7 +44,30 @@ var debugGetTabCmd = &cobra.Command{
 	Hidden: true,
 }
 
+var debugRpcStatsCmd = &cobra.Command{
+	Use:   "rpcstats",
+	Short: "show rpc metrics (requests, errors, in-flight requests and latencies by command and route)",
+	Long: `show rpc metrics (requests, errors, in-flight requests and latencies by command and route).
+stats are kept per process: the local server by default, or the connserver of a connection with -c.
+"client" rows are requests the process sent, "server" rows requests it handled, and "router" rows requests it passed on.
+with tracing on, requests get a trace id that is kept across processes, so the spans of one request can be matched.`,
+	RunE:   debugRpcStatsRun,
+	Hidden: true,
+}
+
+var debugRpcStatsJson bool
+var debugRpcStatsPrometheus bool
+var debugRpcStatsReset bool
+var debugRpcStatsTrace string
+var debugRpcStatsConn string
+
 func init() {
+	debugRpcStatsCmd.Flags().BoolVar(&debugRpcStatsJson, "json", false, "output as json")
+	debugRpcStatsCmd.Flags().BoolVar(&debugRpcStatsPrometheus, "prometheus", false, "output in the prometheus text format")
+	debugRpcStatsCmd.Flags().BoolVar(&debugRpcStatsReset, "reset", false, "reset the metrics (after showing them)")
+	debugRpcStatsCmd.Flags().StringVar(&debugRpcStatsTrace, "trace", "", "turn request tracing on or off")
+	debugRpcStatsCmd.Flags().StringVarP(&debugRpcStatsConn, "connection", "c", "", "show the stats of this connection's connserver")
+	debugCmd.AddCommand(debugRpcStatsCmd)
 	debugCmd.AddCommand(debugBlockIdsCmd)
 	debugCmd.AddCommand(debugSendTelemetryCmd)
 	debugCmd.AddCommand(debugGetTabCmd)
@@ -79,3 +108,97 @@ func debugBlockIdsRun(cmd *cobra.Command, args []string) error {
 	WriteStdout("%s\n", string(barr))
 	return nil
 }
+
+// returns the upper bound of the bucket that holds the q quantile (or the max for the overflow bucket)
+func rpcLatencyQuantile(stats wshrpc.RpcCommandStats, bucketsMs []float64, q float64) float64 {
+	var total int64
+	for _, count := range stats.LatencyCounts {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+	target := int64(q * float64(total))
+	var cumulative int64
+	for idx, count := range stats.LatencyCounts {
+		cumulative += count
+		if cumulative > target {
+			if idx < len(bucketsMs) {
+				return min(bucketsMs[idx], stats.LatencyMaxMs)
+			}
+			break
+		}
+	}
+	return stats.LatencyMaxMs
+}
+
+func formatRpcMs(ms float64) string {
+	return time.Duration(ms * float64(time.Millisecond)).Round(100 * time.Microsecond).String()
+}
+
+func debugRpcStatsRun(cmd *cobra.Command, args []string) error {
+	var data wshrpc.CommandRpcStatsData
+	data.Reset = debugRpcStatsReset
+	switch debugRpcStatsTrace {
+	case "":
+	case "on":
+		data.Trace = new(bool)
+		*data.Trace = true
+	case "off":
+		data.Trace = new(bool)
+	default:
+		return fmt.Errorf("invalid --trace value %q (expected on or off)", debugRpcStatsTrace)
+	}
+	opts := &wshrpc.RpcOpts{Timeout: 5000}
+	if debugRpcStatsConn != "" {
+		opts.Route = wshutil.MakeConnectionRouteId(debugRpcStatsConn)
+	}
+	stats, err := wshclient.RpcStatsCommand(RpcClient, data, opts)
+	if err != nil {
+		return fmt.Errorf("getting rpc stats: %w", err)
+	}
+	if debugRpcStatsJson {
+		barr, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return err
+		}
+		WriteStdout("%s\n", string(barr))
+		return nil
+	}
+	if debugRpcStatsPrometheus {
+		return wshutil.WriteRpcStatsPrometheus(os.Stdout, stats)
+	}
+	tracing := "off"
+	if stats.Tracing {
+		tracing = "on"
+	}
+	WriteStdout("rpc stats for %s since %s (tracing %s)\n\n", stats.Name, time.UnixMilli(stats.SinceTs).Format(time.DateTime), tracing)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(writer, "SIDE\tCOMMAND\tROUTE\tCOUNT\tERRORS\tINFLIGHT\tAVG\tP50\tP99\tMAX\n")
+	for _, row := range stats.Stats {
+		avg := "-"
+		var timed int64
+		for _, count := range row.LatencyCounts {
+			timed += count
+		}
+		p50, p99, maxMs := "-", "-", "-"
+		if timed > 0 {
+			avg = formatRpcMs(row.LatencySumMs / float64(timed))
+			p50 = formatRpcMs(rpcLatencyQuantile(row, stats.LatencyBucketsMs, 0.5))
+			p99 = formatRpcMs(rpcLatencyQuantile(row, stats.LatencyBucketsMs, 0.99))
+			maxMs = formatRpcMs(row.LatencyMaxMs)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\n", row.Side, row.Command, row.Route, row.Count, row.Errors, row.InFlight, avg, p50, p99, maxMs)
+	}
+	writer.Flush()
+	if len(stats.Traces) > 0 {
+		WriteStdout("\nrecent traced requests:\n")
+		writer = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(writer, "TRACEID\tSTART\tSIDE\tCOMMAND\tROUTE\tDURATION\tERROR\n")
+		for _, span := range stats.Traces {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", span.TraceId, time.UnixMilli(span.StartTs).Format("15:04:05.000"), span.Side, span.Command, span.Route, formatRpcMs(span.DurationMs), span.Error)
+		}
+		writer.Flush()
+	}
+	return nil
+}
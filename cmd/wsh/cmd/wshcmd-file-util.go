@@ -6,6 +6,7 @@ package cmd
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -15,6 +16,7 @@ import (
 	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fsutil"
 	"github.com/commandlinedev/starterm/pkg/util/fileutil"
 	"github.com/commandlinedev/starterm/pkg/util/starfileutil"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
 	"github.com/commandlinedev/starterm/pkg/wshrpc"
 	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
 )
@@ -91,6 +93,43 @@ func streamWriteToFile(fileData wshrpc.FileData, reader io.Reader) error {
 	return nil
 }
 
+// writes reader in chunks to a stage file next to the file, which replaces the file once all of it was written.
+// readers never see a partly written file, and there is no size limit. with ifMatch set the file is only replaced if it still has that etag.
+func stagedWriteToFile(fileData wshrpc.FileData, reader io.Reader, ifMatch string) error {
+	stageId, err := utilfn.RandomHexString(12)
+	if err != nil {
+		return fmt.Errorf("creating stage id: %w", err)
+	}
+	buf := make([]byte, wshrpc.FileChunkSize)
+	for first := true; ; first = false {
+		n, err := io.ReadFull(reader, buf)
+		done := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !done {
+			return fmt.Errorf("reading input: %w", err)
+		}
+		opts := &wshrpc.FileOpts{StageId: stageId, Commit: done}
+		if done {
+			opts.IfMatch = ifMatch
+		}
+		chunkData := wshrpc.FileData{
+			Info:   &wshrpc.FileInfo{Path: fileData.Info.Path, Opts: opts},
+			Data64: base64.StdEncoding.EncodeToString(buf[:n]),
+		}
+		if first {
+			// the first chunk starts the stage
+			err = wshclient.FileWriteCommand(RpcClient, chunkData, &wshrpc.RpcOpts{Timeout: fileTimeout})
+		} else {
+			err = wshclient.FileAppendCommand(RpcClient, chunkData, &wshrpc.RpcOpts{Timeout: fileTimeout})
+		}
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
 func streamReadFromFile(ctx context.Context, fileData wshrpc.FileData, writer io.Writer) error {
 	ch := wshclient.FileReadStreamCommand(RpcClient, fileData, &wshrpc.RpcOpts{Timeout: fileTimeout})
 	return fsutil.ReadFileStreamToWriter(ctx, ch, writer)
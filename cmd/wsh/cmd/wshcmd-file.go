@@ -10,10 +10,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"text/tabwriter"
 	"time"
 
@@ -94,15 +98,44 @@ func init() {
 	fileListCmd.Flags().BoolP("files", "f", false, "list files only")
 
 	fileCmd.AddCommand(fileListCmd)
+	fileCatCmd.Flags().Int64("offset", 0, "start reading at this byte offset")
+	fileCatCmd.Flags().Int64("length", 0, "read at most this many bytes (default to the end of the file)")
 	fileCmd.AddCommand(fileCatCmd)
+	fileWriteCmd.Flags().String("if-match", "", "only replace the file if it still has this etag (see \"wsh file info\"), fails with a conflict otherwise")
 	fileCmd.AddCommand(fileWriteCmd)
 	fileRmCmd.Flags().BoolP("recursive", "r", false, "remove directories recursively")
+	fileRmCmd.Flags().Bool("permanent", false, "delete permanently, even when the trash is enabled (file:trash)")
 	fileCmd.AddCommand(fileRmCmd)
 	fileCmd.AddCommand(fileInfoCmd)
 	fileCmd.AddCommand(fileAppendCmd)
 	fileCpCmd.Flags().BoolP("merge", "m", false, "merge directories")
 	fileCpCmd.Flags().BoolP("force", "f", false, "force overwrite of existing files")
+	fileCpCmd.Flags().Bool("resume", false, "skip files that were already copied and continue partially copied files")
+	fileCpCmd.Flags().String("compression", "none", "compression for copies between connections (gzip or none)")
+	fileCpCmd.Flags().Bool("no-checksum", false, "do not verify copies between connections with a sha256 of every file (which reads each source file twice)")
+	fileCpCmd.Flags().BoolP("quiet", "q", false, "do not show progress")
 	fileCmd.AddCommand(fileCpCmd)
+	fileSyncCmd.Flags().Bool("delete", false, "delete destination files that are not in the source")
+	fileSyncCmd.Flags().BoolP("checksum", "c", false, "compare file contents instead of sizes and modification times")
+	fileSyncCmd.Flags().StringArray("include", nil, "only sync files matching this pattern (.gitignore syntax, can be repeated)")
+	fileSyncCmd.Flags().StringArray("exclude", nil, "skip files matching this pattern (.gitignore syntax, can be repeated)")
+	fileSyncCmd.Flags().Bool("gitignore", false, "skip files ignored by .gitignore files in the source (and .git directories)")
+	fileSyncCmd.Flags().BoolP("dry-run", "n", false, "show what would be done without changing anything")
+	fileCmd.AddCommand(fileSyncCmd)
+	fileWatchCmd.Flags().BoolP("recursive", "r", false, "watch subdirectories recursively")
+	fileWatchCmd.Flags().Int64("debounce", 0, "wait until nothing changed for this many milliseconds before reporting changes (default 100)")
+	fileWatchCmd.Flags().StringArray("include", nil, "only report files matching this pattern (.gitignore syntax, can be repeated)")
+	fileWatchCmd.Flags().StringArray("exclude", nil, "ignore files matching this pattern (.gitignore syntax, can be repeated)")
+	fileCmd.AddCommand(fileWatchCmd)
+	fileGrepCmd.Flags().BoolP("fixed-strings", "F", false, "match the pattern as a literal string instead of a regular expression")
+	fileGrepCmd.Flags().BoolP("ignore-case", "i", false, "match case-insensitively")
+	fileGrepCmd.Flags().StringArray("include", nil, "only search files matching this pattern (.gitignore syntax, can be repeated)")
+	fileGrepCmd.Flags().StringArray("exclude", nil, "skip files matching this pattern (.gitignore syntax, can be repeated)")
+	fileGrepCmd.Flags().Bool("no-ignore", false, "also search files ignored by .gitignore files (and .git directories)")
+	fileGrepCmd.Flags().Int64("max-filesize", 0, "skip files larger than this many bytes (default 10MB)")
+	fileGrepCmd.Flags().IntP("max-count", "m", 0, "stop after this many matches (default 10000)")
+	fileGrepCmd.Flags().BoolP("files-with-matches", "l", false, "only print the paths of the files with matches")
+	fileCmd.AddCommand(fileGrepCmd)
 	fileMvCmd.Flags().BoolP("recursive", "r", false, "move directories recursively")
 	fileMvCmd.Flags().BoolP("force", "f", false, "force overwrite of existing files")
 	fileCmd.AddCommand(fileMvCmd)
@@ -121,8 +154,8 @@ var fileListCmd = &cobra.Command{
 var fileCatCmd = &cobra.Command{
 	Use:     "cat [uri]",
 	Short:   "display contents of a file",
-	Long:    "Display the contents of a file." + UriHelpText,
-	Example: "  wsh file cat wsh://user@ec2/home/user/config.txt\n  wsh file cat starfile://client/settings.json",
+	Long:    "Display the contents of a file. Use --offset and --length to read part of a (large) file." + UriHelpText,
+	Example: "  wsh file cat wsh://user@ec2/home/user/config.txt\n  wsh file cat starfile://client/settings.json\n  wsh file cat --offset 1048576 --length 4096 wsh://user@ec2/var/log/big.log",
 	Args:    cobra.ExactArgs(1),
 	RunE:    activityWrap("file", fileCatRun),
 	PreRunE: preRunSetupRpcClient,
@@ -141,7 +174,7 @@ var fileInfoCmd = &cobra.Command{
 var fileRmCmd = &cobra.Command{
 	Use:     "rm [uri]",
 	Short:   "remove a file",
-	Long:    "Remove a file." + UriHelpText,
+	Long:    "Remove a file. With the trash enabled (file:trash), the file is moved to the trash of its connection, see \"wsh file trash\"." + UriHelpText,
 	Example: "  wsh file rm wsh://user@ec2/home/user/config.txt\n  wsh file rm starfile://client/settings.json",
 	Args:    cobra.ExactArgs(1),
 	RunE:    activityWrap("file", fileRmRun),
@@ -149,10 +182,14 @@ var fileRmCmd = &cobra.Command{
 }
 
 var fileWriteCmd = &cobra.Command{
-	Use:     "write [uri]",
-	Short:   "write stdin into a file (up to 10MB)",
-	Long:    "Write stdin into a file, buffering input (10MB total file size limit)." + UriHelpText,
-	Example: "  echo 'hello' | wsh file write starfile://block/greeting.txt",
+	Use:   "write [uri]",
+	Short: "write stdin into a file",
+	Long: `Write stdin into a file, replacing its content.
+On local and wsh connections the input is written to a stage file next to the file, which replaces the file once the
+input is complete. The file is never partly written and there is no size limit. With --if-match the file is only
+replaced if it still has the given etag (see "wsh file info"), otherwise the write fails with a conflict and the file
+is left alone. Other storage systems buffer the input (10MB limit) and do not support --if-match.` + UriHelpText,
+	Example: "  echo 'hello' | wsh file write starfile://block/greeting.txt\n  wsh file cat config.txt | sed s/a/b/ | wsh file write --if-match 1860f1a2b3c4d5e6-1a-2f0c1d config.txt",
 	Args:    cobra.ExactArgs(1),
 	RunE:    activityWrap("file", fileWriteRun),
 	PreRunE: preRunSetupRpcClient,
@@ -172,13 +209,45 @@ var fileCpCmd = &cobra.Command{
 	Use:     "cp [source-uri] [destination-uri]" + UriHelpText,
 	Aliases: []string{"copy"},
 	Short:   "copy files between storage systems, recursively if needed",
-	Long:    "Copy files between different storage systems." + UriHelpText,
-	Example: "  wsh file cp starfile://block/config.txt ./local-config.txt\n  wsh file cp ./local-config.txt starfile://block/config.txt\n  wsh file cp wsh://user@ec2/home/user/config.txt starfile://client/config.txt",
+	Long:    "Copy files between different storage systems. Copies between connections verify every file with a checksum (unless --no-checksum is set) and can be compressed (--compression gzip). An interrupted copy can be continued with --resume." + UriHelpText,
+	Example: "  wsh file cp starfile://block/config.txt ./local-config.txt\n  wsh file cp ./local-config.txt starfile://block/config.txt\n  wsh file cp wsh://user@ec2/home/user/config.txt starfile://client/config.txt\n  wsh file cp --resume ./dataset/ wsh://user@ec2/home/user/dataset/",
 	Args:    cobra.ExactArgs(2),
 	RunE:    activityWrap("file", fileCpRun),
 	PreRunE: preRunSetupRpcClient,
 }
 
+var fileSyncCmd = &cobra.Command{
+	Use:     "sync [source-uri] [destination-uri]" + UriHelpText,
+	Short:   "sync a directory to another directory, copying only what changed",
+	Long:    "Make the destination directory match the source directory. Files that are missing at the destination, differ in size, or are newer in the source are copied. Works between any two storage systems." + UriHelpText,
+	Example: "  wsh file sync ./config/ wsh://user@ec2/home/user/config/\n  wsh file sync --delete --gitignore ./site/ s3://bucket/site/\n  wsh file sync -n --exclude '*.log' wsh://user@ec2/var/app/ ./app-backup/",
+	Args:    cobra.ExactArgs(2),
+	RunE:    activityWrap("file", fileSyncRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var fileWatchCmd = &cobra.Command{
+	Use:   "watch [uri] [-- command [args...]]",
+	Short: "watch a file or directory for changes",
+	Long: "Watch a file or directory for changes. Without a command, each change is printed as \"op path\" (op is create, write, remove or rename, paths are relative to the watched directory). " +
+		"With a command, the command is run once the watch is set up and again after every batch of changes. Only connections running wsh can be watched." + UriHelpText,
+	Example: "  wsh file watch -r ./src\n  wsh file watch -r --include '*.go' . -- go test ./...\n  wsh file watch wsh://user@ec2/var/log/app.log",
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    activityWrap("file", fileWatchRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var fileGrepCmd = &cobra.Command{
+	Use:   "grep [pattern] [uri]",
+	Short: "search file contents",
+	Long: "Search the file, or the files below the directory (default is the current directory), for lines matching a regular expression. Matches are printed as \"path:line:column:text\", paths are relative to the searched directory. " +
+		"Files ignored by .gitignore files, binary files and files over 10MB are skipped. Connections running wsh are searched on the connection, other storage systems are searched by reading every file." + UriHelpText,
+	Example: "  wsh file grep TODO\n  wsh file grep -i --include '*.go' 'func \\w+Command' wsh://user@ec2/home/user/src\n  wsh file grep -F -l 'ERROR' s3://bucket/logs/",
+	Args:    cobra.RangeArgs(1, 2),
+	RunE:    activityWrap("file", fileGrepRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
 var fileMvCmd = &cobra.Command{
 	Use:     "mv [source-uri] [destination-uri]" + UriHelpText,
 	Aliases: []string{"move"},
@@ -198,6 +267,17 @@ func fileCatRun(cmd *cobra.Command, args []string) error {
 	fileData := wshrpc.FileData{
 		Info: &wshrpc.FileInfo{
 			Path: path}}
+	offset, _ := cmd.Flags().GetInt64("offset")
+	length, _ := cmd.Flags().GetInt64("length")
+	if offset < 0 || length < 0 {
+		return fmt.Errorf("offset and length cannot be negative")
+	}
+	if offset > 0 || length > 0 {
+		if length == 0 {
+			length = math.MaxInt32
+		}
+		fileData.At = &wshrpc.FileDataAt{Offset: offset, Size: int(min(length, math.MaxInt32))}
+	}
 
 	err = streamReadFromFile(cmd.Context(), fileData, os.Stdout)
 	if err != nil {
@@ -214,7 +294,8 @@ func fileInfoRun(cmd *cobra.Command, args []string) error {
 	}
 	fileData := wshrpc.FileData{
 		Info: &wshrpc.FileInfo{
-			Path: path}}
+			Path: path,
+			Opts: &wshrpc.FileOpts{ObjectTags: true}}}
 
 	info, err := wshclient.FileInfoCommand(RpcClient, fileData, &wshrpc.RpcOpts{Timeout: fileTimeout})
 	err = convertNotFoundErr(err)
@@ -234,6 +315,9 @@ func fileInfoRun(cmd *cobra.Command, args []string) error {
 	if !info.IsDir {
 		WriteStdout("size:\t%d\n", info.Size)
 	}
+	if info.ETag != "" {
+		WriteStdout("etag:\t%s\n", info.ETag)
+	}
 	if info.Meta != nil && len(*info.Meta) > 0 {
 		WriteStdout("metadata:\n")
 		for k, v := range *info.Meta {
@@ -252,8 +336,12 @@ func fileRmRun(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	permanent, err := cmd.Flags().GetBool("permanent")
+	if err != nil {
+		return err
+	}
 
-	err = wshclient.FileDeleteCommand(RpcClient, wshrpc.CommandDeleteFileData{Path: path, Recursive: recursive}, &wshrpc.RpcOpts{Timeout: fileTimeout})
+	err = wshclient.FileDeleteCommand(RpcClient, wshrpc.CommandDeleteFileData{Path: path, Recursive: recursive, Permanent: permanent}, &wshrpc.RpcOpts{Timeout: fileTimeout})
 	if err != nil {
 		return fmt.Errorf("removing file: %w", err)
 	}
@@ -274,7 +362,15 @@ func fileWriteRun(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("getting fileshare capability: %w", err)
 	}
-	if capability.CanAppend {
+	ifMatch, _ := cmd.Flags().GetString("if-match")
+	if capability.CanStage {
+		err = stagedWriteToFile(fileData, WrappedStdin, ifMatch)
+		if err != nil {
+			return fmt.Errorf("writing file: %w", err)
+		}
+	} else if ifMatch != "" {
+		return fmt.Errorf("--if-match is not supported for %q", path)
+	} else if capability.CanAppend {
 		err = streamWriteToFile(fileData, WrappedStdin)
 		if err != nil {
 			return fmt.Errorf("writing file: %w", err)
@@ -388,6 +484,38 @@ func getTargetPath(src, dst string) (string, error) {
 	return dst, nil
 }
 
+func formatCopyBytes(size int64) string {
+	if size >= 1<<30 {
+		return fmt.Sprintf("%.1f GB", float64(size)/(1<<30))
+	}
+	if size >= 1<<20 {
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	}
+	return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+}
+
+func formatCopyProgress(progress wshrpc.FileCopyProgress) string {
+	var rate string
+	if progress.ElapsedMs > 0 {
+		rate = fmt.Sprintf(", %s/s", formatCopyBytes(progress.TotalBytes*1000/progress.ElapsedMs))
+	}
+	summary := fmt.Sprintf("%d files, %s%s", progress.NumFiles, formatCopyBytes(progress.TotalBytes), rate)
+	if progress.NumSkipped > 0 {
+		summary += fmt.Sprintf(", %d skipped", progress.NumSkipped)
+	}
+	if progress.NumResumed > 0 {
+		summary += fmt.Sprintf(", %d resumed", progress.NumResumed)
+	}
+	if progress.Done || progress.Path == "" {
+		return summary
+	}
+	pct := 100
+	if progress.FileSize > 0 {
+		pct = int(progress.FileBytes * 100 / progress.FileSize)
+	}
+	return fmt.Sprintf("%s %d%% (%s)", progress.Path, pct, summary)
+}
+
 func fileCpRun(cmd *cobra.Command, args []string) error {
 	src, dst := args[0], args[1]
 	merge, err := cmd.Flags().GetBool("merge")
@@ -398,6 +526,26 @@ func fileCpRun(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	resume, err := cmd.Flags().GetBool("resume")
+	if err != nil {
+		return err
+	}
+	compression, err := cmd.Flags().GetString("compression")
+	if err != nil {
+		return err
+	}
+	if compression != "gzip" && compression != "none" {
+		return fmt.Errorf("invalid compression %q (must be gzip or none)", compression)
+	}
+	noChecksum, err := cmd.Flags().GetBool("no-checksum")
+	if err != nil {
+		return err
+	}
+	quiet, err := cmd.Flags().GetBool("quiet")
+	if err != nil {
+		return err
+	}
+	showProgress := !quiet && getIsTty()
 
 	srcPath, err := fixRelativePaths(src)
 	if err != nil {
@@ -407,15 +555,263 @@ func fileCpRun(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("unable to parse dest path: %w", err)
 	}
-	log.Printf("Copying %s to %s; merge: %v, force: %v", srcPath, destPath, merge, force)
+	log.Printf("Copying %s to %s; merge: %v, force: %v, resume: %v", srcPath, destPath, merge, force, resume)
 	rpcOpts := &wshrpc.RpcOpts{Timeout: TimeoutYear}
-	err = wshclient.FileCopyCommand(RpcClient, wshrpc.CommandFileCopyData{SrcUri: srcPath, DestUri: destPath, Opts: &wshrpc.FileCopyOpts{Merge: merge, Overwrite: force, Timeout: TimeoutYear}}, rpcOpts)
+	opts := &wshrpc.FileCopyOpts{Merge: merge, Overwrite: force, Resume: resume, Compression: compression, Checksum: !noChecksum, Timeout: TimeoutYear}
+	progressCh := wshclient.FileCopyStreamCommand(RpcClient, wshrpc.CommandFileCopyData{SrcUri: srcPath, DestUri: destPath, Opts: opts}, rpcOpts)
+	var lastLineLen int
+	for respUnion := range progressCh {
+		if respUnion.Error != nil {
+			if lastLineLen > 0 {
+				WriteStderr("\n")
+			}
+			return fmt.Errorf("copying file: %w", respUnion.Error)
+		}
+		if !showProgress {
+			continue
+		}
+		line := formatCopyProgress(respUnion.Response)
+		padding := ""
+		if len(line) < lastLineLen {
+			padding = strings.Repeat(" ", lastLineLen-len(line))
+		}
+		WriteStderr("\r%s%s", line, padding)
+		lastLineLen = len(line)
+		if respUnion.Response.Done {
+			WriteStderr("\n")
+			lastLineLen = 0
+		}
+	}
+	return nil
+}
+
+func fileSyncRun(cmd *cobra.Command, args []string) error {
+	opts := &wshrpc.FileSyncOpts{Timeout: fileTimeout}
+	var err error
+	if opts.Delete, err = cmd.Flags().GetBool("delete"); err != nil {
+		return err
+	}
+	if opts.Checksum, err = cmd.Flags().GetBool("checksum"); err != nil {
+		return err
+	}
+	if opts.Include, err = cmd.Flags().GetStringArray("include"); err != nil {
+		return err
+	}
+	if opts.Exclude, err = cmd.Flags().GetStringArray("exclude"); err != nil {
+		return err
+	}
+	if opts.GitIgnore, err = cmd.Flags().GetBool("gitignore"); err != nil {
+		return err
+	}
+	if opts.DryRun, err = cmd.Flags().GetBool("dry-run"); err != nil {
+		return err
+	}
+	srcPath, err := fixRelativePaths(args[0])
 	if err != nil {
-		return fmt.Errorf("copying file: %w", err)
+		return fmt.Errorf("unable to parse src path: %w", err)
+	}
+	destPath, err := fixRelativePaths(args[1])
+	if err != nil {
+		return fmt.Errorf("unable to parse dest path: %w", err)
+	}
+	syncCh := wshclient.FileSyncCommand(RpcClient, wshrpc.CommandFileSyncData{SrcUri: srcPath, DestUri: destPath, Opts: opts}, &wshrpc.RpcOpts{Timeout: TimeoutYear})
+	counts := make(map[string]int)
+	for respUnion := range syncCh {
+		if respUnion.Error != nil {
+			return fmt.Errorf("syncing files: %w", respUnion.Error)
+		}
+		progress := respUnion.Response
+		if progress.Action != nil {
+			counts[progress.Action.Op]++
+			WriteStdout("%-6s %s\n", progress.Action.Op, progress.Action.Path)
+		}
+		if progress.Done {
+			dryRun := ""
+			if opts.DryRun {
+				dryRun = " (dry run)"
+			}
+			WriteStderr("%d copied, %d updated, %d deleted, %d unchanged%s\n", counts["copy"], counts["update"], counts["delete"], progress.Unchanged, dryRun)
+		}
 	}
 	return nil
 }
 
+// runs the watch command, its exit status is reported but does not stop the watch
+func runWatchCommand(cmdArgs []string) {
+	execCmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+	execCmd.Stdin = os.Stdin
+	execCmd.Stdout = os.Stdout
+	execCmd.Stderr = os.Stderr
+	if err := execCmd.Run(); err != nil {
+		WriteStderr("[watch] %s: %v\n", strings.Join(cmdArgs, " "), err)
+	}
+}
+
+func fileWatchRun(cmd *cobra.Command, args []string) error {
+	opts := &wshrpc.FileWatchOpts{}
+	var err error
+	if opts.Recursive, err = cmd.Flags().GetBool("recursive"); err != nil {
+		return err
+	}
+	if opts.DebounceMs, err = cmd.Flags().GetInt64("debounce"); err != nil {
+		return err
+	}
+	if opts.Include, err = cmd.Flags().GetStringArray("include"); err != nil {
+		return err
+	}
+	if opts.Exclude, err = cmd.Flags().GetStringArray("exclude"); err != nil {
+		return err
+	}
+	var cmdArgs []string
+	if dashIdx := cmd.ArgsLenAtDash(); dashIdx >= 0 {
+		cmdArgs = args[dashIdx:]
+		args = args[:dashIdx]
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one uri to watch")
+	}
+	if cmd.ArgsLenAtDash() >= 0 && len(cmdArgs) == 0 {
+		return fmt.Errorf("no command given after --")
+	}
+	path, err := fixRelativePaths(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to parse path: %w", err)
+	}
+	rpcOpts := &wshrpc.RpcOpts{Timeout: TimeoutYear}
+	watchCh := wshclient.FileWatchCommand(RpcClient, wshrpc.CommandFileWatchData{Uri: path, Opts: opts}, rpcOpts)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	for {
+		var respUnion wshrpc.RespOrErrorUnion[wshrpc.FileWatchEvent]
+		var ok bool
+		select {
+		case <-sigCh:
+			// stop the watch on the connection, it would otherwise run until the timeout
+			if rpcOpts.StreamCancelFn != nil {
+				rpcOpts.StreamCancelFn()
+			}
+			return nil
+		case respUnion, ok = <-watchCh:
+		}
+		if !ok {
+			return nil
+		}
+		if respUnion.Error != nil {
+			return fmt.Errorf("watching files: %w", respUnion.Error)
+		}
+		event := respUnion.Response
+		if event.Ready {
+			WriteStderr("watching %s\n", event.Uri)
+		}
+		if len(cmdArgs) == 0 {
+			for _, change := range event.Changes {
+				WriteStdout("%-6s %s\n", change.Op, change.Path)
+			}
+			continue
+		}
+		runWatchCommand(cmdArgs)
+		// drop the changes reported while the command ran, a burst of changes should not queue up several runs
+		for drained := false; !drained; {
+			select {
+			case respUnion, ok = <-watchCh:
+				if !ok {
+					return nil
+				}
+				if respUnion.Error != nil {
+					return fmt.Errorf("watching files: %w", respUnion.Error)
+				}
+			default:
+				drained = true
+			}
+		}
+	}
+}
+
+func fileGrepRun(cmd *cobra.Command, args []string) error {
+	opts := &wshrpc.FileSearchOpts{Pattern: args[0]}
+	var err error
+	if opts.Literal, err = cmd.Flags().GetBool("fixed-strings"); err != nil {
+		return err
+	}
+	if opts.IgnoreCase, err = cmd.Flags().GetBool("ignore-case"); err != nil {
+		return err
+	}
+	if opts.Include, err = cmd.Flags().GetStringArray("include"); err != nil {
+		return err
+	}
+	if opts.Exclude, err = cmd.Flags().GetStringArray("exclude"); err != nil {
+		return err
+	}
+	if opts.NoGitIgnore, err = cmd.Flags().GetBool("no-ignore"); err != nil {
+		return err
+	}
+	if opts.MaxFileSize, err = cmd.Flags().GetInt64("max-filesize"); err != nil {
+		return err
+	}
+	if opts.MaxResults, err = cmd.Flags().GetInt("max-count"); err != nil {
+		return err
+	}
+	filesOnly, err := cmd.Flags().GetBool("files-with-matches")
+	if err != nil {
+		return err
+	}
+	uri := "."
+	if len(args) > 1 {
+		uri = args[1]
+	}
+	path, err := fixRelativePaths(uri)
+	if err != nil {
+		return fmt.Errorf("unable to parse path: %w", err)
+	}
+	rpcOpts := &wshrpc.RpcOpts{Timeout: TimeoutYear}
+	searchCh := wshclient.FileSearchCommand(RpcClient, wshrpc.CommandFileSearchData{Uri: path, Opts: opts}, rpcOpts)
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	numMatches := 0
+	numFiles := 0
+	for {
+		var respUnion wshrpc.RespOrErrorUnion[wshrpc.FileSearchResult]
+		var ok bool
+		select {
+		case <-sigCh:
+			// stop the search on the connection
+			if rpcOpts.StreamCancelFn != nil {
+				rpcOpts.StreamCancelFn()
+			}
+			return nil
+		case respUnion, ok = <-searchCh:
+		}
+		if !ok {
+			return nil
+		}
+		if respUnion.Error != nil {
+			return fmt.Errorf("searching files: %w", respUnion.Error)
+		}
+		result := respUnion.Response
+		if len(result.Matches) > 0 {
+			numMatches += len(result.Matches)
+			numFiles++
+			if filesOnly {
+				WriteStdout("%s\n", result.Matches[0].Path)
+			}
+		}
+		if !filesOnly {
+			for _, match := range result.Matches {
+				WriteStdout("%s:%d:%d:%s\n", match.Path, match.Line, match.Column, match.Text)
+			}
+		}
+		if result.Done {
+			truncated := ""
+			if result.Truncated {
+				truncated = " (stopped at the max count)"
+			}
+			WriteStderr("%d matches in %d files, %d files searched, %d skipped%s\n", numMatches, numFiles, result.FilesSearched, result.FilesSkipped, truncated)
+		}
+	}
+}
+
 func fileMvRun(cmd *cobra.Command, args []string) error {
 	src, dst := args[0], args[1]
 	recursive, err := cmd.Flags().GetBool("recursive")
@@ -0,0 +1,122 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
+	"github.com/spf13/cobra"
+)
+
+var fileTrashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "manage the trash of deleted and overwritten files",
+	Long: `With the trash enabled (set file:trash to true), deleted and overwritten files are moved to a trash instead of being removed.
+Every connection has its own trash in ~/.starterm/trash, s3 buckets and starfile zones have it under the starterm-trash/ prefix.
+Entries older than file:trashretentiondays (default 30) are deleted. The trash commands use the trash of the connection
+(or bucket) of --uri, which defaults to the current directory.`,
+}
+
+var fileTrashListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "list the entries in the trash, oldest first",
+	Example: "  wsh file trash list\n  wsh file trash list --uri wsh://user@ec2/\n  wsh file trash list --uri s3://bucket/",
+	Args:    cobra.NoArgs,
+	RunE:    activityWrap("file", fileTrashListRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var fileTrashRestoreCmd = &cobra.Command{
+	Use:     "restore [id...]",
+	Short:   "move entries back from the trash to where they were",
+	Example: "  wsh file trash restore 20250102-150405-1a2b3c4d\n  wsh file trash restore --to ./recovered.txt 20250102-150405-1a2b3c4d",
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    activityWrap("file", fileTrashRestoreRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var fileTrashEmptyCmd = &cobra.Command{
+	Use:     "empty [id...]",
+	Short:   "permanently delete the given entries, or all entries",
+	Example: "  wsh file trash empty\n  wsh file trash empty --uri wsh://user@ec2/ 20250102-150405-1a2b3c4d",
+	Args:    cobra.ArbitraryArgs,
+	RunE:    activityWrap("file", fileTrashEmptyRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var (
+	fileTrashUri       string
+	fileTrashRestoreTo string
+)
+
+func init() {
+	fileCmd.AddCommand(fileTrashCmd)
+	fileTrashCmd.PersistentFlags().StringVar(&fileTrashUri, "uri", ".", "use the trash of the connection (or bucket) of this uri")
+	fileTrashCmd.AddCommand(fileTrashListCmd)
+	fileTrashCmd.AddCommand(fileTrashRestoreCmd)
+	fileTrashRestoreCmd.Flags().StringVar(&fileTrashRestoreTo, "to", "", "restore a single entry to this path instead of where it was")
+	fileTrashCmd.AddCommand(fileTrashEmptyCmd)
+}
+
+func makeFileTrashData(ids []string) (wshrpc.CommandFileTrashData, error) {
+	uri, err := fixRelativePaths(fileTrashUri)
+	if err != nil {
+		return wshrpc.CommandFileTrashData{}, fmt.Errorf("unable to parse uri: %w", err)
+	}
+	return wshrpc.CommandFileTrashData{Uri: uri, Ids: ids}, nil
+}
+
+func fileTrashListRun(cmd *cobra.Command, args []string) error {
+	data, err := makeFileTrashData(nil)
+	if err != nil {
+		return err
+	}
+	entries, err := wshclient.FileTrashListCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: fileTimeout})
+	if err != nil {
+		return fmt.Errorf("listing trash: %w", err)
+	}
+	for _, entry := range entries {
+		tsStr := time.UnixMilli(entry.TrashedTs).Format("2006-01-02 15:04:05")
+		kind := "file"
+		if entry.IsDir {
+			kind = "dir"
+		}
+		WriteStdout("%s  %s  %-9s %-4s %s\n", entry.Id, tsStr, entry.Reason, kind, entry.Uri)
+	}
+	return nil
+}
+
+func fileTrashRestoreRun(cmd *cobra.Command, args []string) error {
+	data, err := makeFileTrashData(args)
+	if err != nil {
+		return err
+	}
+	if fileTrashRestoreTo != "" {
+		if data.DestUri, err = fixRelativePaths(fileTrashRestoreTo); err != nil {
+			return fmt.Errorf("unable to parse destination: %w", err)
+		}
+	}
+	err = wshclient.FileTrashRestoreCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: fileTimeout})
+	if err != nil {
+		return fmt.Errorf("restoring from trash: %w", err)
+	}
+	return nil
+}
+
+func fileTrashEmptyRun(cmd *cobra.Command, args []string) error {
+	data, err := makeFileTrashData(args)
+	if err != nil {
+		return err
+	}
+	numDeleted, err := wshclient.FileTrashEmptyCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: fileTimeout})
+	if err != nil {
+		return fmt.Errorf("emptying trash: %w", err)
+	}
+	WriteStdout("deleted %d trash entries\n", numDeleted)
+	return nil
+}
@@ -0,0 +1,254 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
+	"github.com/spf13/cobra"
+)
+
+// how many (unique) commands are loaded into the interactive picker
+const HistoryPickerLimit = 10000
+
+// fzf exit codes for "no match" and a cancelled picker (ctrl-c, esc)
+const fzfExitNoMatch = 1
+const fzfExitInterrupted = 130
+
+var historyCmd = &cobra.Command{
+	Use:   "history [flags] [query]",
+	Short: "search command history",
+	Long: `Search the command history of all terminal blocks (newest commands are printed last).
+Commands are recorded when shell integration is active in the block.
+The query is a case-insensitive substring match.
+
+With --interactive, the matching commands are shown in an fzf picker (the query is used as the initial
+fzf query) and the selected command is inserted into the block's command line.  The picker runs the fzf
+installed on the host, it is not built into wsh.`,
+	Example: "  wsh history\n  wsh history -n 200 git\n  wsh history --conn user@host --failed --since 2d\n  wsh history --cwd . -i",
+	Args:    cobra.MaximumNArgs(1),
+	RunE:    activityWrap("history", historyRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var historyPurgeCmd = &cobra.Command{
+	Use:     "purge [--conn CONNECTION | --all]",
+	Short:   "delete command history",
+	Example: "  wsh history purge --conn user@host\n  wsh history purge --conn local\n  wsh history purge --all",
+	Args:    cobra.NoArgs,
+	RunE:    activityWrap("history", historyPurgeRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var (
+	historyLimit       int
+	historyConn        string
+	historyCwd         string
+	historyFailed      bool
+	historySince       string
+	historyInteractive bool
+	historyPrint       bool
+	historyPurgeConn   string
+	historyPurgeAll    bool
+)
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 50, "maximum number of commands to show")
+	historyCmd.Flags().StringVar(&historyConn, "conn", "", "only show commands run on this connection (\"local\" for the local machine)")
+	historyCmd.Flags().StringVar(&historyCwd, "cwd", "", "only show commands run in this directory (\".\" for the current directory)")
+	historyCmd.Flags().BoolVar(&historyFailed, "failed", false, "only show commands that exited with a non-zero exit code")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "only show commands run since a duration ago (e.g. 30m, 12h, 7d) or a date (2006-01-02)")
+	historyCmd.Flags().BoolVarP(&historyInteractive, "interactive", "i", false, "pick a command with fzf (must be installed) and insert it into the block")
+	historyCmd.Flags().BoolVar(&historyPrint, "print", false, "with --interactive, print the picked command instead of inserting it")
+	historyCmd.AddCommand(historyPurgeCmd)
+	historyPurgeCmd.Flags().StringVar(&historyPurgeConn, "conn", "", "delete the history for this connection (\"local\" for the local machine)")
+	historyPurgeCmd.Flags().BoolVar(&historyPurgeAll, "all", false, "delete all history")
+}
+
+func formatHistoryExitCode(item wshrpc.HistoryItem) string {
+	if item.ExitCode == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d", *item.ExitCode)
+}
+
+func historyConnArg(connName string) string {
+	if connName == "local" {
+		return ""
+	}
+	return connName
+}
+
+// accepts a duration (with "d" and "w" for days and weeks) or a date, returns unix ms
+func parseHistorySince(sinceStr string) (int64, error) {
+	if len(sinceStr) > 1 && (strings.HasSuffix(sinceStr, "d") || strings.HasSuffix(sinceStr, "w")) {
+		num, err := strconv.Atoi(sinceStr[:len(sinceStr)-1])
+		if err == nil {
+			days := num
+			if strings.HasSuffix(sinceStr, "w") {
+				days = num * 7
+			}
+			return time.Now().AddDate(0, 0, -days).UnixMilli(), nil
+		}
+	}
+	if dur, err := time.ParseDuration(sinceStr); err == nil {
+		return time.Now().Add(-dur).UnixMilli(), nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", time.RFC3339} {
+		if ts, err := time.ParseInLocation(layout, sinceStr, time.Local); err == nil {
+			return ts.UnixMilli(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid --since value %q (use a duration like 12h or 7d, or a date like 2006-01-02)", sinceStr)
+}
+
+func makeHistorySearchData(cmd *cobra.Command) (wshrpc.CommandHistorySearchData, error) {
+	data := wshrpc.CommandHistorySearchData{Limit: historyLimit, Failed: historyFailed}
+	if cmd.Flags().Changed("conn") {
+		connName := historyConnArg(historyConn)
+		data.Conn = &connName
+	}
+	if historyCwd != "" {
+		absCwd, err := filepath.Abs(historyCwd)
+		if err != nil {
+			return data, fmt.Errorf("resolving --cwd: %w", err)
+		}
+		data.Cwd = absCwd
+	}
+	if historySince != "" {
+		sinceTs, err := parseHistorySince(historySince)
+		if err != nil {
+			return data, err
+		}
+		data.Since = sinceTs
+	}
+	return data, nil
+}
+
+func historyRun(cmd *cobra.Command, args []string) error {
+	data, err := makeHistorySearchData(cmd)
+	if err != nil {
+		return err
+	}
+	if historyInteractive {
+		return historyPickRun(cmd, data, args)
+	}
+	if len(args) > 0 {
+		data.Query = args[0]
+	}
+	items, err := wshclient.HistorySearchCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: 5000})
+	if err != nil {
+		return fmt.Errorf("searching history: %w", err)
+	}
+	// results come back newest first, print them like shell history (oldest first)
+	for idx := len(items) - 1; idx >= 0; idx-- {
+		item := items[idx]
+		tsStr := time.UnixMilli(item.Ts).Format("2006-01-02 15:04:05")
+		cmdStr := strings.ReplaceAll(item.Cmd, "\n", "\\n")
+		WriteStdout("%s  %4s  %s\n", tsStr, formatHistoryExitCode(item), cmdStr)
+	}
+	return nil
+}
+
+// runs the fzf installed on the host over the commands (newest first), returns "" if nothing was picked
+func pickHistoryItem(items []wshrpc.HistoryItem, query string) (string, error) {
+	fzfPath, err := exec.LookPath("fzf")
+	if err != nil {
+		return "", fmt.Errorf("wsh history --interactive needs fzf installed on this host (https://github.com/junegunn/fzf)")
+	}
+	var input bytes.Buffer
+	for _, item := range items {
+		input.WriteString(item.Cmd)
+		input.WriteByte(0)
+	}
+	var output bytes.Buffer
+	// fzf draws its ui on the tty, only the picked command goes to stdout
+	fzfCmd := exec.Command(fzfPath, "--read0", "--height=40%", "--reverse", "--tiebreak=index", "--prompt=history> ", "--query="+query)
+	fzfCmd.Stdin = &input
+	fzfCmd.Stdout = &output
+	fzfCmd.Stderr = os.Stderr
+	err = fzfCmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && (exitErr.ExitCode() == fzfExitNoMatch || exitErr.ExitCode() == fzfExitInterrupted) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("running fzf: %w", err)
+	}
+	return strings.TrimSuffix(output.String(), "\n"), nil
+}
+
+func historyPickRun(cmd *cobra.Command, data wshrpc.CommandHistorySearchData, args []string) error {
+	if !cmd.Flags().Changed("limit") {
+		data.Limit = HistoryPickerLimit
+	}
+	data.Unique = true
+	items, err := wshclient.HistorySearchCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: 5000})
+	if err != nil {
+		return fmt.Errorf("searching history: %w", err)
+	}
+	var query string
+	if len(args) > 0 {
+		query = args[0]
+	}
+	picked, err := pickHistoryItem(items, query)
+	if err != nil {
+		return err
+	}
+	if picked == "" {
+		// cancelled, lets the keybindings know to leave the command line alone
+		WshExitCode = 1
+		return nil
+	}
+	// a newline would run the command instead of just inserting it
+	picked = strings.NewReplacer("\r", " ", "\n", " ").Replace(picked)
+	if historyPrint || (blockArg == "" && RpcContext.BlockId == "") {
+		WriteStdout("%s\n", picked)
+		return nil
+	}
+	fullORef, err := resolveBlockArg()
+	if err != nil {
+		return err
+	}
+	inputData := wshrpc.CommandBlockInputData{
+		BlockId:     fullORef.OID,
+		InputData64: base64.StdEncoding.EncodeToString([]byte(picked)),
+	}
+	err = wshclient.ControllerInputCommand(RpcClient, inputData, &wshrpc.RpcOpts{Timeout: 2000})
+	if err != nil {
+		return fmt.Errorf("inserting command: %w", err)
+	}
+	return nil
+}
+
+func historyPurgeRun(cmd *cobra.Command, args []string) error {
+	connFlagSet := cmd.Flags().Changed("conn")
+	if historyPurgeAll == connFlagSet {
+		OutputHelpMessage(cmd)
+		return fmt.Errorf("specify exactly one of --conn or --all")
+	}
+	data := wshrpc.CommandHistoryPurgeData{All: historyPurgeAll}
+	if connFlagSet {
+		connName := historyConnArg(historyPurgeConn)
+		data.Conn = &connName
+	}
+	numPurged, err := wshclient.HistoryPurgeCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: 10000})
+	if err != nil {
+		return fmt.Errorf("purging history: %w", err)
+	}
+	WriteStdout("deleted %d history items\n", numPurged)
+	return nil
+}
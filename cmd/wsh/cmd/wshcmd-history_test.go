@@ -0,0 +1,111 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+	"github.com/spf13/pflag"
+)
+
+func TestParseHistorySince(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{input: "30m", want: now.Add(-30 * time.Minute)},
+		{input: "12h", want: now.Add(-12 * time.Hour)},
+		{input: "2d", want: now.AddDate(0, 0, -2)},
+		{input: "1w", want: now.AddDate(0, 0, -7)},
+		{input: "2024-03-01", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{input: "2024-03-01 10:30", want: time.Date(2024, 3, 1, 10, 30, 0, 0, time.Local)},
+		{input: "2024-03-01T10:30:00Z", want: time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)},
+		{input: "d", wantErr: true},
+		{input: "xd", wantErr: true},
+		{input: "yesterday", wantErr: true},
+		{input: "2024-13-01", wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := parseHistorySince(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseHistorySince(%q) = %d, want an error", tc.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseHistorySince(%q) failed: %v", tc.input, err)
+			continue
+		}
+		// durations are relative to the time of the call
+		if diff := got - tc.want.UnixMilli(); diff < -1000 || diff > 1000 {
+			t.Errorf("parseHistorySince(%q) = %v, want %v", tc.input, time.UnixMilli(got), tc.want)
+		}
+	}
+}
+
+func resetHistoryFlags(t *testing.T) {
+	historyCmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if err := flag.Value.Set(flag.DefValue); err != nil {
+			t.Fatalf("error resetting --%s: %v", flag.Name, err)
+		}
+		flag.Changed = false
+	})
+}
+
+func TestMakeHistorySearchData(t *testing.T) {
+	defer resetHistoryFlags(t)
+	curDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting cwd: %v", err)
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		conn    *string
+		cwd     string
+		failed  bool
+		limit   int
+		since   bool
+		wantErr bool
+	}{
+		{name: "defaults", limit: 50},
+		{name: "local conn", args: []string{"--conn", "local"}, conn: utilfn.Ptr(""), limit: 50},
+		{name: "remote conn", args: []string{"--conn", "user@host"}, conn: utilfn.Ptr("user@host"), limit: 50},
+		{name: "empty conn is local", args: []string{"--conn="}, conn: utilfn.Ptr(""), limit: 50},
+		{name: "cwd", args: []string{"--cwd", "."}, cwd: curDir, limit: 50},
+		{name: "absolute cwd", args: []string{"--cwd", "/tmp"}, cwd: "/tmp", limit: 50},
+		{name: "failed and limit", args: []string{"--failed", "-n", "10"}, failed: true, limit: 10},
+		{name: "since", args: []string{"--since", "7d"}, since: true, limit: 50},
+		{name: "bad since", args: []string{"--since", "last week"}, wantErr: true},
+	}
+	for _, tc := range tests {
+		resetHistoryFlags(t)
+		if err := historyCmd.ParseFlags(tc.args); err != nil {
+			t.Fatalf("%s: error parsing flags: %v", tc.name, err)
+		}
+		data, err := makeHistorySearchData(historyCmd)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if (data.Conn == nil) != (tc.conn == nil) || (data.Conn != nil && *data.Conn != *tc.conn) {
+			t.Errorf("%s: got conn %v, want %v", tc.name, data.Conn, tc.conn)
+		}
+		if data.Cwd != tc.cwd || data.Failed != tc.failed || data.Limit != tc.limit || (data.Since > 0) != tc.since {
+			t.Errorf("%s: unexpected search data %+v", tc.name, data)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/commandlinedev/starterm/pkg/asciicast"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
+	"github.com/spf13/cobra"
+)
+
+var recordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "record terminal sessions",
+	Long: `Record the output of a terminal block (sets term:record on the block).
+Recordings are stored in asciicast v2 format and can be exported or replayed in a new block.
+Use -b to target a block other than the current one.`,
+}
+
+var recordStartCmd = &cobra.Command{
+	Use:     "start",
+	Short:   "start recording the block",
+	Args:    cobra.NoArgs,
+	RunE:    activityWrap("record", recordStartRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var recordStopCmd = &cobra.Command{
+	Use:     "stop",
+	Short:   "stop recording the block (the recording is kept)",
+	Args:    cobra.NoArgs,
+	RunE:    activityWrap("record", recordStopRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var recordExportCmd = &cobra.Command{
+	Use:     "export",
+	Short:   "export the recording of the block",
+	Long:    "Export the recording of the block. asciicast output can be played with asciinema, raw output is the terminal output without timing.",
+	Example: "  wsh record export -o session.cast\n  wsh record export --idle-limit 2 -o session.cast\n  wsh record export --format raw | less -R",
+	Args:    cobra.NoArgs,
+	RunE:    activityWrap("record", recordExportRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var recordReplayCmd = &cobra.Command{
+	Use:     "replay",
+	Short:   "replay the recording of the block in a new block",
+	Example: "  wsh record replay\n  wsh record replay --speed 2 --max-idle 1",
+	Args:    cobra.NoArgs,
+	RunE:    activityWrap("record", recordReplayRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var (
+	recordExportFormat    string
+	recordExportOutput    string
+	recordExportIdleLimit float64
+	recordReplaySpeed     float64
+	recordReplayMaxIdle   float64
+)
+
+func init() {
+	rootCmd.AddCommand(recordCmd)
+	recordCmd.AddCommand(recordStartCmd)
+	recordCmd.AddCommand(recordStopCmd)
+	recordCmd.AddCommand(recordExportCmd)
+	recordCmd.AddCommand(recordReplayCmd)
+	recordExportCmd.Flags().StringVar(&recordExportFormat, "format", "asciicast", "export format (asciicast or raw)")
+	recordExportCmd.Flags().StringVarP(&recordExportOutput, "output", "o", "", "output file (defaults to stdout)")
+	recordExportCmd.Flags().Float64Var(&recordExportIdleLimit, "idle-limit", 0, "cap pauses between output to this many seconds (asciicast only)")
+	recordReplayCmd.Flags().Float64Var(&recordReplaySpeed, "speed", 1, "playback speed")
+	recordReplayCmd.Flags().Float64Var(&recordReplayMaxIdle, "max-idle", 0, "cap pauses between output to this many seconds")
+}
+
+func setRecordMeta(value any) (*starobj.ORef, error) {
+	fullORef, err := resolveBlockArg()
+	if err != nil {
+		return nil, err
+	}
+	data := wshrpc.CommandSetMetaData{
+		ORef: *fullORef,
+		Meta: map[string]any{
+			starobj.MetaKey_TermRecord: value,
+		},
+	}
+	err = wshclient.SetMetaCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: 2000})
+	if err != nil {
+		return nil, fmt.Errorf("setting term:record: %w", err)
+	}
+	return fullORef, nil
+}
+
+func recordStartRun(cmd *cobra.Command, args []string) error {
+	fullORef, err := setRecordMeta(true)
+	if err != nil {
+		return err
+	}
+	WriteStderr("recording block %s\n", fullORef.OID)
+	return nil
+}
+
+func recordStopRun(cmd *cobra.Command, args []string) error {
+	fullORef, err := setRecordMeta(nil)
+	if err != nil {
+		return err
+	}
+	WriteStderr("stopped recording block %s\n", fullORef.OID)
+	return nil
+}
+
+func exportRecording(reader *asciicast.Reader, header *asciicast.Header, writer io.Writer) error {
+	if recordExportFormat == "asciicast" {
+		headerBytes, err := asciicast.EncodeHeader(*header)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(headerBytes); err != nil {
+			return err
+		}
+	}
+	var lastTime, shift float64
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if recordExportFormat == "raw" {
+			if event.Type != asciicast.EventType_Output {
+				continue
+			}
+			if _, err := io.WriteString(writer, event.Data); err != nil {
+				return err
+			}
+			continue
+		}
+		if recordExportIdleLimit > 0 && event.Time-lastTime > recordExportIdleLimit {
+			shift += event.Time - lastTime - recordExportIdleLimit
+		}
+		lastTime = event.Time
+		event.Time -= shift
+		eventBytes, err := asciicast.EncodeEvent(*event)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(eventBytes); err != nil {
+			return err
+		}
+	}
+}
+
+func recordExportRun(cmd *cobra.Command, args []string) error {
+	if recordExportFormat != "asciicast" && recordExportFormat != "raw" {
+		return fmt.Errorf("invalid format %q (must be asciicast or raw)", recordExportFormat)
+	}
+	fullORef, err := resolveBlockArg()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	fileData := wshrpc.FileData{
+		Info: &wshrpc.FileInfo{
+			Path: fmt.Sprintf("starfile://%s/%s", fullORef.OID, starbase.BlockFile_Record)}}
+	err = streamReadFromFile(cmd.Context(), fileData, &buf)
+	if err != nil {
+		return fmt.Errorf("reading recording (is term:record set on the block?): %w", err)
+	}
+	reader, header, err := asciicast.MakeReader(&buf)
+	if err != nil {
+		return err
+	}
+	if recordExportIdleLimit > 0 {
+		header.IdleTimeLimit = recordExportIdleLimit
+	}
+	var writer io.Writer = os.Stdout
+	if recordExportOutput != "" {
+		outFile, err := os.Create(recordExportOutput)
+		if err != nil {
+			return fmt.Errorf("creating output file: %w", err)
+		}
+		defer outFile.Close()
+		writer = outFile
+	}
+	err = exportRecording(reader, header, writer)
+	if err != nil {
+		return fmt.Errorf("exporting recording: %w", err)
+	}
+	return nil
+}
+
+func recordReplayRun(cmd *cobra.Command, args []string) error {
+	if recordReplaySpeed <= 0 {
+		return fmt.Errorf("--speed must be greater than 0")
+	}
+	fullORef, err := resolveBlockArg()
+	if err != nil {
+		return err
+	}
+	data := wshrpc.CommandRecordingReplayData{
+		BlockId:   fullORef.OID,
+		Speed:     recordReplaySpeed,
+		MaxIdleMs: int64(recordReplayMaxIdle * 1000),
+	}
+	replayORef, err := wshclient.RecordingReplayCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: 5000})
+	if err != nil {
+		return fmt.Errorf("starting replay: %w", err)
+	}
+	WriteStderr("replaying in block %s\n", replayORef.OID)
+	return nil
+}
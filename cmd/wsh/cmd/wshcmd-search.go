@@ -0,0 +1,90 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
+	"github.com/spf13/cobra"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search [flags] pattern",
+	Short: "search terminal output",
+	Long: `Search the output of the terminal blocks in the current tab (ANSI escape sequences are stripped).
+The archived output of blocks with term:archive set is searched as well.
+Use -b to search a single block, or --workspace to search every block in the current workspace.`,
+	Example: "  wsh search error\n  wsh search -i -r 'fail(ed|ure)'\n  wsh search --workspace TODO\n  wsh search -b 2 panic",
+	Args:    cobra.ExactArgs(1),
+	RunE:    activityWrap("search", searchRun),
+	PreRunE: preRunSetupRpcClient,
+}
+
+var (
+	searchRegex      bool
+	searchIgnoreCase bool
+	searchWorkspace  bool
+	searchMax        int
+)
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().BoolVarP(&searchRegex, "regex", "r", false, "treat the pattern as a regular expression")
+	searchCmd.Flags().BoolVarP(&searchIgnoreCase, "ignore-case", "i", false, "case-insensitive search")
+	searchCmd.Flags().BoolVarP(&searchWorkspace, "workspace", "w", false, "search all blocks in the current workspace")
+	searchCmd.Flags().IntVarP(&searchMax, "max", "m", 200, "maximum number of matches")
+}
+
+func resolveSearchScope() (*starobj.ORef, error) {
+	if blockArg != "" {
+		return resolveBlockArg()
+	}
+	if searchWorkspace {
+		return resolveSimpleId("workspace")
+	}
+	return resolveSimpleId("tab")
+}
+
+func formatSearchLine(match wshrpc.TermSearchMatch, color bool) string {
+	if !color || match.MatchStart < 0 || match.MatchEnd > len(match.Line) || match.MatchStart > match.MatchEnd {
+		return match.Line
+	}
+	return match.Line[:match.MatchStart] + "\x1b[1;31m" + match.Line[match.MatchStart:match.MatchEnd] + "\x1b[0m" + match.Line[match.MatchEnd:]
+}
+
+func searchRun(cmd *cobra.Command, args []string) error {
+	scopeORef, err := resolveSearchScope()
+	if err != nil {
+		return err
+	}
+	data := wshrpc.CommandTermSearchData{
+		ORef:       *scopeORef,
+		Query:      args[0],
+		Regex:      searchRegex,
+		IgnoreCase: searchIgnoreCase,
+		MaxMatches: searchMax,
+	}
+	rtn, err := wshclient.TermSearchCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: 30000})
+	if err != nil {
+		return fmt.Errorf("searching terminal output: %w", err)
+	}
+	color := getIsTty()
+	for _, match := range rtn.Matches {
+		archivedStr := ""
+		if match.Archived {
+			archivedStr = " (archived)"
+		}
+		WriteStdout("%s:%d%s: %s\n", match.BlockId[:min(8, len(match.BlockId))], match.Offset, archivedStr, formatSearchLine(match, color))
+	}
+	if len(rtn.Matches) == 0 {
+		WshExitCode = 1
+	}
+	if rtn.Truncated {
+		WriteStderr("[search] stopped after %d matches (use --max to see more)\n", len(rtn.Matches))
+	}
+	return nil
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/commandlinedev/starterm/pkg/util/shellutil"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
 )
 
 var tokenCmd = &cobra.Command{
@@ -17,8 +19,21 @@ var tokenCmd = &cobra.Command{
 	Hidden: true,
 }
 
+var tokenRotateCmd = &cobra.Command{
+	Use:     "rotate",
+	Short:   "rotate the signing key for wsh tokens",
+	Long:    "Generates a new signing key for wsh tokens and re-keys connected connservers. Tokens signed with the previous key keep working until they expire, unless --revoke is given.",
+	Args:    cobra.NoArgs,
+	RunE:    tokenRotateRun,
+	PreRunE: preRunSetupRpcClient,
+}
+
+var tokenRotateRevoke bool
+
 func init() {
 	rootCmd.AddCommand(tokenCmd)
+	tokenCmd.AddCommand(tokenRotateCmd)
+	tokenRotateCmd.Flags().BoolVar(&tokenRotateRevoke, "revoke", false, "immediately invalidate all tokens signed with previous keys")
 }
 
 func tokenCmdRun(cmd *cobra.Command, args []string) (rtnErr error) {
@@ -43,3 +58,22 @@ func tokenCmdRun(cmd *cobra.Command, args []string) (rtnErr error) {
 	WriteStdout("%s\n", rtnData.InitScriptText)
 	return nil
 }
+
+func tokenRotateRun(cmd *cobra.Command, args []string) error {
+	data := wshrpc.CommandRotateJwtKeyData{RevokeOld: tokenRotateRevoke}
+	rtn, err := wshclient.RotateJwtKeyCommand(RpcClient, data, &wshrpc.RpcOpts{Timeout: 30000})
+	if err != nil {
+		return fmt.Errorf("rotating jwt key: %w", err)
+	}
+	WriteStdout("new signing key %s\n", rtn.KeyId)
+	for _, connName := range rtn.RekeyedConns {
+		WriteStdout("re-keyed connection %q\n", connName)
+	}
+	for _, errStr := range rtn.RekeyErrors {
+		WriteStderr("error re-keying %s\n", errStr)
+	}
+	if tokenRotateRevoke {
+		WriteStdout("previous keys revoked, existing shells must be restarted\n")
+	}
+	return nil
+}
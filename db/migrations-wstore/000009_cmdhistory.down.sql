@@ -0,0 +1,15 @@
+CREATE TABLE history_migrated (
+	historyid varchar(36) PRIMARY KEY,
+    ts bigint NOT NULL,
+	remotename varchar(200) NOT NULL,
+	haderror boolean NOT NULL,
+    cmdstr text NOT NULL,
+	exitcode int NULL DEFAULT NULL, 
+	durationms int NULL DEFAULT NULL
+);
+
+INSERT INTO history_migrated (historyid, ts, remotename, haderror, cmdstr, exitcode, durationms)
+SELECT historyid, ts, conn, COALESCE(exitcode, 0) <> 0, cmd, exitcode, durationms
+FROM db_cmdhistory;
+
+DROP TABLE db_cmdhistory;
@@ -0,0 +1,21 @@
+CREATE TABLE db_cmdhistory (
+    historyid varchar(36) PRIMARY KEY,
+    ts bigint NOT NULL,
+    cmd text NOT NULL,
+    cwd text NOT NULL DEFAULT '',
+    conn varchar(200) NOT NULL DEFAULT '',
+    blockid varchar(36) NOT NULL DEFAULT '',
+    tabid varchar(36) NOT NULL DEFAULT '',
+    workspaceid varchar(36) NOT NULL DEFAULT '',
+    exitcode int NULL DEFAULT NULL,
+    durationms bigint NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_cmdhistory_ts ON db_cmdhistory (ts);
+CREATE INDEX idx_cmdhistory_conn ON db_cmdhistory (conn, ts);
+
+INSERT INTO db_cmdhistory (historyid, ts, cmd, conn, exitcode, durationms)
+SELECT historyid, ts, cmdstr, remotename, exitcode, COALESCE(durationms, 0)
+FROM history_migrated;
+
+DROP TABLE history_migrated;
@@ -391,6 +391,10 @@ const ConnStatusOverlay = React.memo(
             statusText = `Connecting to "${connName}"...`;
             showReconnect = false;
         }
+        if (connStatus.status == "reconnecting") {
+            statusText = `Connection lost, reconnecting to "${connName}"...`;
+            showReconnect = false;
+        }
         if (connStatus.status == "connected") {
             showReconnect = false;
         }
@@ -403,7 +407,7 @@ const ConnStatusOverlay = React.memo(
             reconDisplay = "Reconnect";
             reconClassName = clsx(reconClassName, "font-size-11 vertical-padding-3 horizontal-padding-7");
         }
-        const showIcon = connStatus.status != "connecting";
+        const showIcon = connStatus.status != "connecting" && connStatus.status != "reconnecting";
 
         const wshConfigEnabled = fullConfig?.connections?.[connName]?.["conn:wshenabled"] ?? true;
         React.useEffect(() => {
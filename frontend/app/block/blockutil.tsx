@@ -185,9 +185,10 @@ export const ConnectionButton = React.memo(
                 titleText = "Connected to " + connection;
                 let iconName = "arrow-right-arrow-left";
                 let iconSvg = null;
-                if (connStatus?.status == "connecting") {
+                if (connStatus?.status == "connecting" || connStatus?.status == "reconnecting") {
                     color = "var(--warning-color)";
-                    titleText = "Connecting to " + connection;
+                    titleText =
+                        (connStatus?.status == "reconnecting" ? "Reconnecting to " : "Connecting to ") + connection;
                     shouldSpin = false;
                     iconSvg = (
                         <div className="connecting-svg">
@@ -1,7 +1,7 @@
 // Copyright 2025, Command Line Inc.
 // SPDX-License-Identifier: Apache-2.0
 
-import { sendRpcCommand, sendRpcResponse } from "@/app/store/wshrpcutil";
+import { DefaultStreamWindow, sendRpcCommand, sendRpcResponse } from "@/app/store/wshrpcutil";
 import * as util from "@/util/util";
 
 const notFoundLogMap = new Map<string, boolean>();
@@ -81,6 +81,7 @@ class WshClient {
             data: data,
             reqid: crypto.randomUUID(),
             source: this.routeId,
+            window: DefaultStreamWindow,
         };
         if (opts?.timeout) {
             msg.timeout = opts.timeout;
@@ -17,6 +17,16 @@ class RpcApiType {
         return client.wshRpcCall("aisendmessage", data, opts);
     }
 
+    // command "apitokencreate" [call]
+    ApiTokenCreateCommand(client: WshClient, data: CommandApiTokenCreateData, opts?: RpcOpts): Promise<CommandApiTokenCreateRtnData> {
+        return client.wshRpcCall("apitokencreate", data, opts);
+    }
+
+    // command "apitokendelete" [call]
+    ApiTokenDeleteCommand(client: WshClient, data: string, opts?: RpcOpts): Promise<void> {
+        return client.wshRpcCall("apitokendelete", data, opts);
+    }
+
     // command "authenticate" [call]
     AuthenticateCommand(client: WshClient, data: string, opts?: RpcOpts): Promise<CommandAuthenticateRtnData> {
         return client.wshRpcCall("authenticate", data, opts);
@@ -27,6 +37,11 @@ class RpcApiType {
         return client.wshRpcCall("authenticatetoken", data, opts);
     }
 
+    // command "blockcommands" [call]
+    BlockCommandsCommand(client: WshClient, data: CommandBlockCommandsData, opts?: RpcOpts): Promise<BlockCommandRecord[]> {
+        return client.wshRpcCall("blockcommands", data, opts);
+    }
+
     // command "blockinfo" [call]
     BlockInfoCommand(client: WshClient, data: string, opts?: RpcOpts): Promise<BlockInfoData> {
         return client.wshRpcCall("blockinfo", data, opts);
@@ -47,6 +62,16 @@ class RpcApiType {
         return client.wshRpcCall("connensure", data, opts);
     }
 
+    // command "connforwardadd" [call]
+    ConnForwardAddCommand(client: WshClient, data: CommandConnForwardAddData, opts?: RpcOpts): Promise<ConnForwardStatus> {
+        return client.wshRpcCall("connforwardadd", data, opts);
+    }
+
+    // command "connforwardrm" [call]
+    ConnForwardRmCommand(client: WshClient, data: CommandConnForwardRmData, opts?: RpcOpts): Promise<void> {
+        return client.wshRpcCall("connforwardrm", data, opts);
+    }
+
     // command "connlist" [call]
     ConnListCommand(client: WshClient, opts?: RpcOpts): Promise<string[]> {
         return client.wshRpcCall("connlist", null, opts);
@@ -177,6 +202,11 @@ class RpcApiType {
         return client.wshRpcCall("filecopy", data, opts);
     }
 
+    // command "filecopystream" [responsestream]
+	FileCopyStreamCommand(client: WshClient, data: CommandFileCopyData, opts?: RpcOpts): AsyncGenerator<FileCopyProgress, void, boolean> {
+        return client.wshRpcStream("filecopystream", data, opts);
+    }
+
     // command "filecreate" [call]
     FileCreateCommand(client: WshClient, data: FileData, opts?: RpcOpts): Promise<void> {
         return client.wshRpcCall("filecreate", data, opts);
@@ -227,6 +257,11 @@ class RpcApiType {
         return client.wshRpcStream("filereadstream", data, opts);
     }
 
+    // command "filesearch" [responsestream]
+	FileSearchCommand(client: WshClient, data: CommandFileSearchData, opts?: RpcOpts): AsyncGenerator<FileSearchResult, void, boolean> {
+        return client.wshRpcStream("filesearch", data, opts);
+    }
+
     // command "filesharecapability" [call]
     FileShareCapabilityCommand(client: WshClient, data: string, opts?: RpcOpts): Promise<FileShareCapability> {
         return client.wshRpcCall("filesharecapability", data, opts);
@@ -237,6 +272,31 @@ class RpcApiType {
         return client.wshRpcStream("filestreamtar", data, opts);
     }
 
+    // command "filesync" [responsestream]
+	FileSyncCommand(client: WshClient, data: CommandFileSyncData, opts?: RpcOpts): AsyncGenerator<FileSyncProgress, void, boolean> {
+        return client.wshRpcStream("filesync", data, opts);
+    }
+
+    // command "filetrashempty" [call]
+    FileTrashEmptyCommand(client: WshClient, data: CommandFileTrashData, opts?: RpcOpts): Promise<number> {
+        return client.wshRpcCall("filetrashempty", data, opts);
+    }
+
+    // command "filetrashlist" [call]
+    FileTrashListCommand(client: WshClient, data: CommandFileTrashData, opts?: RpcOpts): Promise<TrashEntry[]> {
+        return client.wshRpcCall("filetrashlist", data, opts);
+    }
+
+    // command "filetrashrestore" [call]
+    FileTrashRestoreCommand(client: WshClient, data: CommandFileTrashData, opts?: RpcOpts): Promise<void> {
+        return client.wshRpcCall("filetrashrestore", data, opts);
+    }
+
+    // command "filewatch" [responsestream]
+	FileWatchCommand(client: WshClient, data: CommandFileWatchData, opts?: RpcOpts): AsyncGenerator<FileWatchEvent, void, boolean> {
+        return client.wshRpcStream("filewatch", data, opts);
+    }
+
     // command "filewrite" [call]
     FileWriteCommand(client: WshClient, data: FileData, opts?: RpcOpts): Promise<void> {
         return client.wshRpcCall("filewrite", data, opts);
@@ -272,6 +332,16 @@ class RpcApiType {
         return client.wshRpcCall("getvar", data, opts);
     }
 
+    // command "historypurge" [call]
+    HistoryPurgeCommand(client: WshClient, data: CommandHistoryPurgeData, opts?: RpcOpts): Promise<number> {
+        return client.wshRpcCall("historypurge", data, opts);
+    }
+
+    // command "historysearch" [call]
+    HistorySearchCommand(client: WshClient, data: CommandHistorySearchData, opts?: RpcOpts): Promise<HistoryItem[]> {
+        return client.wshRpcCall("historysearch", data, opts);
+    }
+
     // command "message" [call]
     MessageCommand(client: WshClient, data: CommandMessageData, opts?: RpcOpts): Promise<void> {
         return client.wshRpcCall("message", data, opts);
@@ -287,6 +357,11 @@ class RpcApiType {
         return client.wshRpcCall("path", data, opts);
     }
 
+    // command "recordingreplay" [call]
+    RecordingReplayCommand(client: WshClient, data: CommandRecordingReplayData, opts?: RpcOpts): Promise<ORef> {
+        return client.wshRpcCall("recordingreplay", data, opts);
+    }
+
     // command "recordtevent" [call]
     RecordTEventCommand(client: WshClient, data: TEvent, opts?: RpcOpts): Promise<void> {
         return client.wshRpcCall("recordtevent", data, opts);
@@ -297,6 +372,11 @@ class RpcApiType {
         return client.wshRpcCall("remotefilecopy", data, opts);
     }
 
+    // command "remotefilecopystream" [responsestream]
+	RemoteFileCopyStreamCommand(client: WshClient, data: CommandFileCopyData, opts?: RpcOpts): AsyncGenerator<FileCopyProgress, void, boolean> {
+        return client.wshRpcStream("remotefilecopystream", data, opts);
+    }
+
     // command "remotefiledelete" [call]
     RemoteFileDeleteCommand(client: WshClient, data: CommandDeleteFileData, opts?: RpcOpts): Promise<void> {
         return client.wshRpcCall("remotefiledelete", data, opts);
@@ -317,11 +397,21 @@ class RpcApiType {
         return client.wshRpcCall("remotefilemove", data, opts);
     }
 
+    // command "remotefilesearch" [responsestream]
+	RemoteFileSearchCommand(client: WshClient, data: CommandFileSearchData, opts?: RpcOpts): AsyncGenerator<FileSearchResult, void, boolean> {
+        return client.wshRpcStream("remotefilesearch", data, opts);
+    }
+
     // command "remotefiletouch" [call]
     RemoteFileTouchCommand(client: WshClient, data: string, opts?: RpcOpts): Promise<void> {
         return client.wshRpcCall("remotefiletouch", data, opts);
     }
 
+    // command "remotefilewatch" [responsestream]
+	RemoteFileWatchCommand(client: WshClient, data: CommandFileWatchData, opts?: RpcOpts): AsyncGenerator<FileWatchEvent, void, boolean> {
+        return client.wshRpcStream("remotefilewatch", data, opts);
+    }
+
     // command "remotegetinfo" [call]
     RemoteGetInfoCommand(client: WshClient, opts?: RpcOpts): Promise<RemoteInfo> {
         return client.wshRpcCall("remotegetinfo", null, opts);
@@ -357,6 +447,11 @@ class RpcApiType {
         return client.wshRpcStream("remotetarstream", data, opts);
     }
 
+    // command "remoteupdatejwt" [call]
+    RemoteUpdateJwtCommand(client: WshClient, data: string, opts?: RpcOpts): Promise<void> {
+        return client.wshRpcCall("remoteupdatejwt", data, opts);
+    }
+
     // command "remotewritefile" [call]
     RemoteWriteFileCommand(client: WshClient, data: FileData, opts?: RpcOpts): Promise<void> {
         return client.wshRpcCall("remotewritefile", data, opts);
@@ -367,6 +462,11 @@ class RpcApiType {
         return client.wshRpcCall("resolveids", data, opts);
     }
 
+    // command "rotatejwtkey" [call]
+    RotateJwtKeyCommand(client: WshClient, data: CommandRotateJwtKeyData, opts?: RpcOpts): Promise<CommandRotateJwtKeyRtnData> {
+        return client.wshRpcCall("rotatejwtkey", data, opts);
+    }
+
     // command "routeannounce" [call]
     RouteAnnounceCommand(client: WshClient, opts?: RpcOpts): Promise<void> {
         return client.wshRpcCall("routeannounce", null, opts);
@@ -377,6 +477,11 @@ class RpcApiType {
         return client.wshRpcCall("routeunannounce", null, opts);
     }
 
+    // command "rpcstats" [call]
+    RpcStatsCommand(client: WshClient, data: CommandRpcStatsData, opts?: RpcOpts): Promise<RpcStatsData> {
+        return client.wshRpcCall("rpcstats", data, opts);
+    }
+
     // command "sendtelemetry" [call]
     SendTelemetryCommand(client: WshClient, opts?: RpcOpts): Promise<void> {
         return client.wshRpcCall("sendtelemetry", null, opts);
@@ -407,6 +512,11 @@ class RpcApiType {
         return client.wshRpcCall("setview", data, opts);
     }
 
+    // command "starailistmodels" [call]
+    StarAiListModelsCommand(client: WshClient, data: CommandStarAiListModelsData, opts?: RpcOpts): Promise<CommandStarAiListModelsRtnData> {
+        return client.wshRpcCall("starailistmodels", data, opts);
+    }
+
     // command "starinfo" [call]
     StarInfoCommand(client: WshClient, opts?: RpcOpts): Promise<StarInfoData> {
         return client.wshRpcCall("starinfo", null, opts);
@@ -427,6 +537,11 @@ class RpcApiType {
         return client.wshRpcStream("streamtest", null, opts);
     }
 
+    // command "termsearch" [call]
+    TermSearchCommand(client: WshClient, data: CommandTermSearchData, opts?: RpcOpts): Promise<CommandTermSearchRtnData> {
+        return client.wshRpcCall("termsearch", data, opts);
+    }
+
     // command "test" [call]
     TestCommand(client: WshClient, data: string, opts?: RpcOpts): Promise<void> {
         return client.wshRpcCall("test", data, opts);
@@ -10,13 +10,20 @@ import { addWSReconnectHandler, ElectronOverrideOpts, globalWS, initGlobalWS, WS
 let DefaultRouter: WshRouter;
 let TabRpcClient: WshClient;
 
+// flow control window for streaming responses (matches wshutil.DefaultStreamWindow)
+const DefaultStreamWindow = 32;
+
 async function* rpcResponseGenerator(
     openRpcs: Map<string, ClientRpcEntry>,
     command: string,
     reqid: string,
-    timeout: number
+    timeout: number,
+    window: number
 ): AsyncGenerator<any, void, boolean> {
     const msgQueue: RpcMessage[] = [];
+    // credits are only granted once the responder echoed the window (older responders do not support flow control)
+    let flowAcked = false;
+    let consumed = 0;
     let signalFn: () => void;
     let signalPromise = new Promise<void>((resolve) => (signalFn = resolve));
     let timeoutId: NodeJS.Timeout = null;
@@ -57,6 +64,13 @@ async function* rpcResponseGenerator(
                 if (!msg.cont) {
                     return;
                 }
+                if (window > 0) {
+                    flowAcked ||= msg.window > 0;
+                    if (flowAcked && ++consumed >= Math.ceil(window / 2)) {
+                        sendRpcCredits(reqid, consumed);
+                        consumed = 0;
+                    }
+                }
             }
             await signalPromise;
         }
@@ -73,6 +87,11 @@ function sendRpcCancel(reqid: string) {
     DefaultRouter.recvRpcMessage(rpcMsg);
 }
 
+function sendRpcCredits(reqid: string, credits: number) {
+    const rpcMsg: RpcMessage = { reqid: reqid, credits: credits };
+    DefaultRouter.recvRpcMessage(rpcMsg);
+}
+
 function sendRpcResponse(msg: RpcMessage) {
     DefaultRouter.recvRpcMessage(msg);
 }
@@ -85,7 +104,7 @@ function sendRpcCommand(
     if (msg.reqid == null) {
         return null;
     }
-    const rtnGen = rpcResponseGenerator(openRpcs, msg.command, msg.reqid, msg.timeout);
+    const rtnGen = rpcResponseGenerator(openRpcs, msg.command, msg.reqid, msg.timeout, msg.window ?? 0);
     rtnGen.next(); // start the generator (run the initialization/registration logic, throw away the result)
     return rtnGen;
 }
@@ -149,4 +168,13 @@ class UpstreamWshRpcProxy implements AbstractWshClient {
     }
 }
 
-export { DefaultRouter, initElectronWshrpc, initWshrpc, sendRpcCommand, sendRpcResponse, shutdownWshrpc, TabRpcClient };
+export {
+    DefaultRouter,
+    DefaultStreamWindow,
+    initElectronWshrpc,
+    initWshrpc,
+    sendRpcCommand,
+    sendRpcResponse,
+    shutdownWshrpc,
+    TabRpcClient,
+};
@@ -10,7 +10,7 @@ import { TabRpcClient } from "@/app/store/wshrpcutil";
 import { type PreviewModel } from "@/app/view/preview/preview";
 import { checkKeyPressed, isCharacterKeyEvent } from "@/util/keyutil";
 import { PLATFORM, PlatformMacOS } from "@/util/platformutil";
-import { addOpenMenuItems } from "@/util/previewutil";
+import { addOpenMenuItems, isArchiveFile } from "@/util/previewutil";
 import { fireAndForget, isBlank } from "@/util/util";
 import { formatRemoteUri } from "@/util/starutil";
 import { offset, useDismiss, useFloating, useInteractions } from "@floating-ui/react";
@@ -792,7 +792,10 @@ function DirectoryPreview({ model }: DirectoryPreviewProps) {
                         TabRpcClient,
                         {
                             info: {
-                                path: await model.formatRemoteUri(dirPath, globalStore.get),
+                                path: await model.formatRemoteUri(
+                                    isArchiveFile(conn, finfo) ? dirPath + "//" : dirPath,
+                                    globalStore.get
+                                ),
                             },
                         },
                         null
@@ -18,10 +18,19 @@ import * as WOS from "@/store/wos";
 import { getWebServerEndpoint } from "@/util/endpoints";
 import { goHistory, goHistoryBack, goHistoryForward } from "@/util/historyutil";
 import { adaptFromReactOrNativeKeyEvent, checkKeyPressed } from "@/util/keyutil";
-import { addOpenMenuItems } from "@/util/previewutil";
-import { base64ToString, fireAndForget, isBlank, jotaiLoadableValue, makeConnRoute, stringToBase64 } from "@/util/util";
+import { addOpenMenuItems, isArchiveFile } from "@/util/previewutil";
+import {
+    base64ToArray,
+    base64ToString,
+    fireAndForget,
+    isBlank,
+    jotaiLoadableValue,
+    makeConnRoute,
+    stringToBase64,
+} from "@/util/util";
 import { formatRemoteUri } from "@/util/starutil";
 import { Monaco } from "@monaco-editor/react";
+import base64 from "base64-js";
 import clsx from "clsx";
 import { Atom, atom, Getter, PrimitiveAtom, useAtom, useAtomValue, useSetAtom, WritableAtom } from "jotai";
 import { loadable } from "jotai/utils";
@@ -33,7 +42,7 @@ import { CSVView } from "./csvview";
 import { DirectoryPreview } from "./directorypreview";
 import "./preview.scss";
 
-const MaxFileSize = 1024 * 1024 * 10; // 10MB
+const ReadChunkSize = 1024 * 1024 * 8; // 8MB, larger files are read in ranges
 const MaxCSVSize = 1024 * 1024 * 1; // 1MB
 
 // TODO drive this using config
@@ -111,6 +120,25 @@ function isStreamingType(mimeType: string): boolean {
         mimeType.startsWith("image/")
     );
 }
+// reads the file in ReadChunkSize ranges, a single read is capped on the server
+async function readFileInRanges(path: string, fileInfo: FileInfo): Promise<FileData> {
+    const data = new Uint8Array(fileInfo.size);
+    let offset = 0;
+    while (offset < fileInfo.size) {
+        const chunk = await RpcApi.FileReadCommand(TabRpcClient, {
+            info: { path },
+            at: { offset, size: Math.min(ReadChunkSize, fileInfo.size - offset) },
+        });
+        const chunkData = base64ToArray(chunk?.data64 ?? "");
+        if (chunkData.length == 0) {
+            break;
+        }
+        data.set(chunkData.subarray(0, fileInfo.size - offset), offset);
+        offset += chunkData.length;
+    }
+    return { info: fileInfo, data64: base64.fromByteArray(data.subarray(0, Math.min(offset, fileInfo.size))) };
+}
+
 export class PreviewModel implements ViewModel {
     viewType: string;
     blockId: string;
@@ -133,6 +161,7 @@ export class PreviewModel implements ViewModel {
 
     metaFilePath: Atom<string>;
     statFilePath: Atom<Promise<string>>;
+    savedETag: { path: string; etag: string } = null;
     loadableFileInfo: Atom<Loadable<FileInfo>>;
     connection: Atom<Promise<string>>;
     connectionImmediate: Atom<string>;
@@ -417,6 +446,10 @@ export class PreviewModel implements ViewModel {
                 return null;
             }
             try {
+                const fileInfo = await get(this.statFile);
+                if (fileInfo != null && !fileInfo.isdir && fileInfo.size > ReadChunkSize) {
+                    return await readFileInRanges(path, fileInfo);
+                }
                 const file = await RpcApi.FileReadCommand(TabRpcClient, {
                     info: {
                         path,
@@ -505,8 +538,8 @@ export class PreviewModel implements ViewModel {
             const fileNameStr = fileName ? " " + JSON.stringify(fileName) : "";
             return { errorStr: "File Not Found" + fileNameStr };
         }
-        if (fileInfo.size > MaxFileSize) {
-            return { errorStr: "File Too Large to Preiview (10 MB Max)" };
+        if (isArchiveFile(getFn(this.connectionImmediate), fileInfo)) {
+            return { specializedView: "directory" };
         }
         if (mimeType == "text/csv" && fileInfo.size > MaxCSVSize) {
             return { errorStr: "CSV File Too Large to Preiview (1 MB Max)" };
@@ -624,7 +657,8 @@ export class PreviewModel implements ViewModel {
         await services.ObjectService.UpdateObjectMeta(blockOref, { ...blockMeta, edit });
     }
 
-    async handleFileSave() {
+    // saves only if the file was not changed since it was read (or last saved), unless force is set
+    async handleFileSave(force: boolean = false) {
         const filePath = await globalStore.get(this.statFilePath);
         if (filePath == null) {
             return;
@@ -634,21 +668,46 @@ export class PreviewModel implements ViewModel {
             console.log("not saving file, newFileContent is null");
             return;
         }
+        const fileInfo = await globalStore.get(this.statFile);
+        const etag = this.savedETag?.path == filePath ? this.savedETag.etag : fileInfo?.etag;
         try {
+            const path = await this.formatRemoteUri(filePath, globalStore.get);
             await RpcApi.FileWriteCommand(TabRpcClient, {
                 info: {
-                    path: await this.formatRemoteUri(filePath, globalStore.get),
+                    path,
+                    opts: force || isBlank(etag) ? null : { ifmatch: etag },
                 },
                 data64: stringToBase64(newFileContent),
             });
             globalStore.set(this.fileContent, newFileContent);
             globalStore.set(this.newFileContent, null);
             console.log("saved file", filePath);
+            if (!isBlank(etag) || fileInfo?.notfound) {
+                const savedInfo = await RpcApi.FileInfoCommand(TabRpcClient, { info: { path } });
+                this.savedETag = { path: filePath, etag: savedInfo?.etag };
+            }
         } catch (e) {
-            const errorStatus: ErrorMsg = {
+            const errorText = `${e}`;
+            let errorStatus: ErrorMsg = {
                 status: "Save Failed",
-                text: `${e}`,
+                text: errorText,
             };
+            if (errorText.includes("CONFLICT:")) {
+                errorStatus = {
+                    status: "Save Conflict",
+                    text: "The file was changed since it was opened. Saving will replace those changes.",
+                    level: "warning",
+                    buttons: [
+                        {
+                            text: "Save Anyway",
+                            onClick: () => {
+                                globalStore.set(this.errorMsgAtom, null);
+                                fireAndForget(() => this.handleFileSave(true));
+                            },
+                        },
+                    ],
+                };
+            }
             globalStore.set(this.errorMsgAtom, errorStatus);
         }
     }
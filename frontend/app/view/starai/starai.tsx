@@ -25,6 +25,7 @@ interface ChatMessageType {
     user: string;
     text: string;
     isUpdating?: boolean;
+    status?: string;
 }
 
 const outline = "2px solid var(--accent-color)";
@@ -79,6 +80,7 @@ export class StarAiModel implements ViewModel {
     latestMessageAtom: Atom<ChatMessageType>;
     addMessageAtom: WritableAtom<unknown, [message: ChatMessageType], void>;
     updateLastMessageAtom: WritableAtom<unknown, [text: string, isUpdating: boolean], void>;
+    updateLastMessageStatusAtom: WritableAtom<unknown, [status: string], void>;
     removeLastMessageAtom: WritableAtom<unknown, [], void>;
     simulateAssistantResponseAtom: WritableAtom<unknown, [userMessage: ChatMessageType], Promise<void>>;
     textAreaRef: React.RefObject<HTMLTextAreaElement>;
@@ -136,6 +138,13 @@ export class StarAiModel implements ViewModel {
                 set(this.messagesAtom, [...messages.slice(0, -1), updatedMessage]);
             }
         });
+        this.updateLastMessageStatusAtom = atom(null, (get, set, status: string) => {
+            const messages = get(this.messagesAtom);
+            const lastMessage = messages[messages.length - 1];
+            if (lastMessage.user == "assistant") {
+                set(this.messagesAtom, [...messages.slice(0, -1), { ...lastMessage, status }]);
+            }
+        });
         this.removeLastMessageAtom = atom(null, (get, set) => {
             const messages = get(this.messagesAtom);
             messages.pop();
@@ -187,6 +196,7 @@ export class StarAiModel implements ViewModel {
                 maxtokens: mergedPresets["ai:maxtokens"] ?? null,
                 timeoutms: mergedPresets["ai:timeoutms"] ?? 60000,
                 baseurl: mergedPresets["ai:baseurl"] ?? null,
+                tools: mergedPresets["ai:tools"] ?? false,
             };
             return opts;
         });
@@ -209,6 +219,14 @@ export class StarAiModel implements ViewModel {
                         noAction: true,
                     });
                     break;
+                case "ollama":
+                    viewTextChildren.push({
+                        elemtype: "iconbutton",
+                        icon: "location-dot",
+                        title: `Using Local Ollama @ ${aiOpts.baseurl ?? "http://localhost:11434"} (${aiOpts.model})`,
+                        noAction: true,
+                    });
+                    break;
                 case "perplexity":
                     viewTextChildren.push({
                         elemtype: "iconbutton",
@@ -363,6 +381,7 @@ export class StarAiModel implements ViewModel {
             const history = await this.fetchAiData();
             const beMsg: StarAIStreamRequest = {
                 clientid: clientId,
+                blockid: this.blockId,
                 opts: opts,
                 prompt: [...history, newPrompt],
             };
@@ -370,6 +389,9 @@ export class StarAiModel implements ViewModel {
             try {
                 const aiGen = RpcApi.StreamStarAiCommand(TabRpcClient, beMsg, { timeout: opts.timeoutms });
                 for await (const msg of aiGen) {
+                    if (msg.status) {
+                        globalStore.set(this.updateLastMessageStatusAtom, msg.status);
+                    }
                     fullMsg += msg.text ?? "";
                     globalStore.set(this.updateLastMessageAtom, msg.text ?? "", true);
                     if (this.cancel) {
@@ -446,7 +468,7 @@ export class StarAiModel implements ViewModel {
 
 const ChatItem = ({ chatItemAtom, model }: ChatItemProps) => {
     const chatItem = useAtomValue(chatItemAtom);
-    const { user, text } = chatItem;
+    const { user, text, status } = chatItem;
     const fontSize = useAtomValue(model.mergedPresets)?.["ai:fontsize"];
     const fixedFontSize = useAtomValue(model.mergedPresets)?.["ai:fixedfontsize"];
     const renderContent = useMemo(() => {
@@ -492,6 +514,7 @@ const ChatItem = ({ chatItemAtom, model }: ChatItemProps) => {
                         <i className="fa-sharp fa-solid fa-sparkles"></i>
                     </div>
                     <TypingIndicator className="chat-msg typing-indicator" />
+                    {status && <div className="chat-msg chat-msg-status">{status}</div>}
                 </>
             );
         }
@@ -508,7 +531,7 @@ const ChatItem = ({ chatItemAtom, model }: ChatItemProps) => {
                 </div>
             </>
         );
-    }, [text, user, fontSize, fixedFontSize]);
+    }, [text, user, status, fontSize, fixedFontSize]);
 
     return <div className={"chat-msg-container"}>{renderContent}</div>;
 };
@@ -344,7 +344,7 @@ declare global {
         viewModel: ViewModel;
     };
 
-    type ConnStatusType = "connected" | "connecting" | "disconnected" | "error" | "init";
+    type ConnStatusType = "connected" | "connecting" | "reconnecting" | "disconnected" | "error" | "init";
 
     interface SuggestionBaseItem {
         label: string;
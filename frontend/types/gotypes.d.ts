@@ -45,6 +45,13 @@ declare global {
         message?: string;
     };
 
+    // sconfig.ApiTokenType
+    type ApiTokenType = {
+        tokenhash: string;
+        scopes: string[];
+        createdts?: number;
+    };
+
     // starobj.Block
     type Block = StarObj & {
         parentoref?: string;
@@ -53,6 +60,22 @@ declare global {
         subblockids?: string[];
     };
 
+    // wshrpc.BlockCommandRecord
+    type BlockCommandRecord = {
+        cmdid: number;
+        blockid: string;
+        cmdline?: string;
+        cwd?: string;
+        promptoffset: number;
+        startoffset: number;
+        endoffset: number;
+        startts: number;
+        endts?: number;
+        duration?: number;
+        exitcode?: number;
+        running?: boolean;
+    };
+
     // blockcontroller.BlockControllerRuntimeStatus
     type BlockControllerRuntimeStatus = {
         blockid: string;
@@ -98,6 +121,19 @@ declare global {
         newactivetabid?: string;
     };
 
+    // wshrpc.CommandApiTokenCreateData
+    type CommandApiTokenCreateData = {
+        name: string;
+        scopes: string[];
+    };
+
+    // wshrpc.CommandApiTokenCreateRtnData
+    type CommandApiTokenCreateRtnData = {
+        name: string;
+        token: string;
+        url?: string;
+    };
+
     // wshrpc.CommandAppendIJsonData
     type CommandAppendIJsonData = {
         zoneid: string;
@@ -109,6 +145,7 @@ declare global {
     type CommandAuthenticateRtnData = {
         routeid: string;
         authtoken?: string;
+        rpccontext?: RpcContext;
         env?: {[key: string]: string};
         initscripttext?: string;
     };
@@ -118,6 +155,12 @@ declare global {
         token: string;
     };
 
+    // wshrpc.CommandBlockCommandsData
+    type CommandBlockCommandsData = {
+        blockid: string;
+        limit?: number;
+    };
+
     // wshrpc.CommandBlockInputData
     type CommandBlockInputData = {
         blockid: string;
@@ -132,6 +175,19 @@ declare global {
         view: string;
     };
 
+    // wshrpc.CommandConnForwardAddData
+    type CommandConnForwardAddData = {
+        connname: string;
+        type: string;
+        spec: string;
+    };
+
+    // wshrpc.CommandConnForwardRmData
+    type CommandConnForwardRmData = {
+        connname: string;
+        forwardid: number;
+    };
+
     // wshrpc.CommandControllerAppendOutputData
     type CommandControllerAppendOutputData = {
         blockid: string;
@@ -172,6 +228,7 @@ declare global {
     type CommandDeleteFileData = {
         path: string;
         recursive: boolean;
+        permanent?: boolean;
     };
 
     // wshrpc.CommandDisposeData
@@ -193,17 +250,68 @@ declare global {
         opts?: FileCopyOpts;
     };
 
+    // wshrpc.CommandFileSearchData
+    type CommandFileSearchData = {
+        uri: string;
+        opts: FileSearchOpts;
+    };
+
+    // wshrpc.CommandFileSyncData
+    type CommandFileSyncData = {
+        srcuri: string;
+        desturi: string;
+        opts?: FileSyncOpts;
+    };
+
+    // wshrpc.CommandFileTrashData
+    type CommandFileTrashData = {
+        uri: string;
+        ids?: string[];
+        desturi?: string;
+    };
+
+    // wshrpc.CommandFileWatchData
+    type CommandFileWatchData = {
+        uri: string;
+        opts?: FileWatchOpts;
+    };
+
     // wshrpc.CommandGetMetaData
     type CommandGetMetaData = {
         oref: ORef;
     };
 
+    // wshrpc.CommandHistoryPurgeData
+    type CommandHistoryPurgeData = {
+        conn?: string;
+        all?: boolean;
+    };
+
+    // wshrpc.CommandHistorySearchData
+    type CommandHistorySearchData = {
+        query?: string;
+        limit?: number;
+        conn?: string;
+        cwd?: string;
+        failed?: boolean;
+        since?: number;
+        unique?: boolean;
+    };
+
     // wshrpc.CommandMessageData
     type CommandMessageData = {
         oref: ORef;
         message: string;
     };
 
+    // wshrpc.CommandRecordingReplayData
+    type CommandRecordingReplayData = {
+        blockid: string;
+        tabid: string;
+        speed?: number;
+        maxidlems?: number;
+    };
+
     // wshrpc.CommandRemoteListEntriesData
     type CommandRemoteListEntriesData = {
         path: string;
@@ -225,6 +333,7 @@ declare global {
     type CommandRemoteStreamTarData = {
         path: string;
         opts?: FileCopyOpts;
+        resume?: {[key: string]: FileResumeInfo};
     };
 
     // wshrpc.CommandResolveIdsData
@@ -238,12 +347,58 @@ declare global {
         resolvedids: {[key: string]: ORef};
     };
 
+    // wshrpc.CommandRotateJwtKeyData
+    type CommandRotateJwtKeyData = {
+        revokeold?: boolean;
+    };
+
+    // wshrpc.CommandRotateJwtKeyRtnData
+    type CommandRotateJwtKeyRtnData = {
+        keyid: string;
+        rekeyedconns?: string[];
+        rekeyerrors?: string[];
+    };
+
+    // wshrpc.CommandRpcStatsData
+    type CommandRpcStatsData = {
+        reset?: boolean;
+        trace?: boolean;
+    };
+
     // wshrpc.CommandSetMetaData
     type CommandSetMetaData = {
         oref: ORef;
         meta: MetaType;
     };
 
+    // wshrpc.CommandStarAiListModelsData
+    type CommandStarAiListModelsData = {
+        apitype: string;
+        baseurl?: string;
+        writepresets?: boolean;
+    };
+
+    // wshrpc.CommandStarAiListModelsRtnData
+    type CommandStarAiListModelsRtnData = {
+        models: StarAIModelInfo[];
+        presetsfile?: string;
+    };
+
+    // wshrpc.CommandTermSearchData
+    type CommandTermSearchData = {
+        oref: ORef;
+        query: string;
+        regex?: boolean;
+        ignorecase?: boolean;
+        maxmatches?: number;
+    };
+
+    // wshrpc.CommandTermSearchRtnData
+    type CommandTermSearchRtnData = {
+        matches: TermSearchMatch[];
+        truncated?: boolean;
+    };
+
     // wshrpc.CommandVarData
     type CommandVarData = {
         key: string;
@@ -293,6 +448,18 @@ declare global {
         logblockid?: string;
     };
 
+    // wshrpc.ConnForwardStatus
+    type ConnForwardStatus = {
+        forwardid: number;
+        type: string;
+        source: string;
+        spec: string;
+        bindaddr?: string;
+        targetaddr?: string;
+        status: string;
+        error?: string;
+    };
+
     // sconfig.ConnKeywords
     type ConnKeywords = {
         "conn:wshenabled"?: boolean;
@@ -328,6 +495,17 @@ declare global {
         "ssh:proxyjump"?: string[];
         "ssh:userknownhostsfile"?: string[];
         "ssh:globalknownhostsfile"?: string[];
+        "ssh:localforward"?: string[];
+        "ssh:remoteforward"?: string[];
+        "ssh:dynamicforward"?: string[];
+        "ssh:serveraliveinterval"?: number;
+        "ssh:serveralivecountmax"?: number;
+        "s3:endpointurl"?: string;
+        "s3:pathstyle"?: boolean;
+        "s3:region"?: string;
+        "s3:profile"?: string;
+        "s3:insecureskipverify"?: boolean;
+        "s3:cafile"?: string;
     };
 
     // wshrpc.ConnRequest
@@ -349,6 +527,7 @@ declare global {
         wsherror?: string;
         nowshreason?: string;
         wshversion?: string;
+        forwards?: ConnForwardStatus[];
     };
 
     // wshrpc.CpuDataRequest
@@ -376,6 +555,8 @@ declare global {
         "file:cwd"?: string;
         "file:dironly"?: boolean;
         "file:connection"?: string;
+        "history:cwd"?: string;
+        "history:connection"?: string;
     };
 
     // wshrpc.FetchSuggestionsResponse
@@ -390,6 +571,23 @@ declare global {
         recursive?: boolean;
         merge?: boolean;
         timeout?: number;
+        compression?: string;
+        checksum?: boolean;
+        resume?: boolean;
+    };
+
+    // wshrpc.FileCopyProgress
+    type FileCopyProgress = {
+        path?: string;
+        filesize?: number;
+        filebytes?: number;
+        numfiles: number;
+        numskipped?: number;
+        numresumed?: number;
+        totalbytes: number;
+        elapsedms: number;
+        isdir?: boolean;
+        done?: boolean;
     };
 
     // wshrpc.FileData
@@ -428,6 +626,7 @@ declare global {
         supportsmkdir?: boolean;
         mimetype?: string;
         readonly?: boolean;
+        etag?: string;
     };
 
     // wshrpc.FileListData
@@ -451,12 +650,103 @@ declare global {
         ijsonbudget?: number;
         truncate?: boolean;
         append?: boolean;
+        ifmatch?: string;
+        stageid?: string;
+        commit?: boolean;
+        objecttags?: boolean;
+    };
+
+    // wshrpc.FileResumeInfo
+    type FileResumeInfo = {
+        size?: number;
+        modts?: number;
+        partialsize?: number;
+        checksum?: string;
+    };
+
+    // wshrpc.FileSearchMatch
+    type FileSearchMatch = {
+        path: string;
+        line: number;
+        column: number;
+        text: string;
+    };
+
+    // wshrpc.FileSearchOpts
+    type FileSearchOpts = {
+        pattern: string;
+        literal?: boolean;
+        ignorecase?: boolean;
+        include?: string[];
+        exclude?: string[];
+        nogitignore?: boolean;
+        maxfilesize?: number;
+        maxresults?: number;
+    };
+
+    // wshrpc.FileSearchResult
+    type FileSearchResult = {
+        matches?: FileSearchMatch[];
+        filessearched?: number;
+        filesskipped?: number;
+        truncated?: boolean;
+        done?: boolean;
     };
 
     // wshrpc.FileShareCapability
     type FileShareCapability = {
         canappend: boolean;
         canmkdir: boolean;
+        maxfilesize?: number;
+        canmultipart?: boolean;
+        canstage?: boolean;
+    };
+
+    // wshrpc.FileSyncAction
+    type FileSyncAction = {
+        op: string;
+        path: string;
+        size?: number;
+    };
+
+    // wshrpc.FileSyncOpts
+    type FileSyncOpts = {
+        checksum?: boolean;
+        delete?: boolean;
+        include?: string[];
+        exclude?: string[];
+        gitignore?: boolean;
+        dryrun?: boolean;
+        timeout?: number;
+    };
+
+    // wshrpc.FileSyncProgress
+    type FileSyncProgress = {
+        action?: FileSyncAction;
+        unchanged?: number;
+        done?: boolean;
+    };
+
+    // wshrpc.FileWatchChange
+    type FileWatchChange = {
+        path: string;
+        op: string;
+        isdir?: boolean;
+    };
+
+    // wshrpc.FileWatchEvent
+    type FileWatchEvent = {
+        uri: string;
+        changes?: FileWatchChange[];
+        ready?: boolean;
+    };
+
+    // wshrpc.FileWatchOpts
+    type FileWatchOpts = {
+        recursive?: boolean;
+        debouncems?: number;
+        include?: string[];
+        exclude?: string[];
     };
 
     // sconfig.FullConfigType
@@ -469,9 +759,24 @@ declare global {
         termthemes: {[key: string]: TermThemeType};
         connections: {[key: string]: ConnKeywords};
         bookmarks: {[key: string]: WebBookmark};
+        apitokens: {[key: string]: ApiTokenType};
         configerrors: ConfigError[];
     };
 
+    // wshrpc.HistoryItem
+    type HistoryItem = {
+        historyid: string;
+        ts: number;
+        cmd: string;
+        cwd?: string;
+        conn?: string;
+        blockid?: string;
+        tabid?: string;
+        workspaceid?: string;
+        exitcode?: number;
+        durationms?: number;
+    };
+
     // starobj.LayoutActionData
     type LayoutActionData = {
         actiontype: string;
@@ -533,6 +838,8 @@ declare global {
         "cmd:closeonexitforce"?: boolean;
         "cmd:closeonexitdelay"?: number;
         "cmd:nowsh"?: boolean;
+        "cmd:nohistory"?: boolean;
+        "cmd:wshcaps"?: string[];
         "cmd:args"?: string[];
         "cmd:shell"?: boolean;
         "cmd:allowconnchange"?: boolean;
@@ -555,6 +862,7 @@ declare global {
         "ai:apiversion"?: string;
         "ai:maxtokens"?: number;
         "ai:timeoutms"?: number;
+        "ai:tools"?: boolean;
         "editor:*"?: boolean;
         "editor:minimapenabled"?: boolean;
         "editor:stickyscrollenabled"?: boolean;
@@ -582,6 +890,8 @@ declare global {
         "term:transparency"?: number;
         "term:allowbracketedpaste"?: boolean;
         "term:conndebug"?: string;
+        "term:archive"?: boolean;
+        "term:record"?: boolean;
         "web:zoom"?: number;
         "web:hidenav"?: boolean;
         "web:partition"?: string;
@@ -639,6 +949,19 @@ declare global {
         shell: string;
     };
 
+    // wshrpc.RpcCommandStats
+    type RpcCommandStats = {
+        side: string;
+        command: string;
+        route: string;
+        count: number;
+        errors: number;
+        inflight: number;
+        latencysumms: number;
+        latencymaxms: number;
+        latencycounts: number[];
+    };
+
     // wshutil.RpcMessage
     type RpcMessage = {
         command?: string;
@@ -650,6 +973,9 @@ declare global {
         source?: string;
         cont?: boolean;
         cancel?: boolean;
+        window?: number;
+        credits?: number;
+        traceid?: string;
         error?: string;
         datatype?: string;
         data?: any;
@@ -662,6 +988,27 @@ declare global {
         route?: string;
     };
 
+    // wshrpc.RpcStatsData
+    type RpcStatsData = {
+        name: string;
+        sincets: number;
+        tracing: boolean;
+        latencybucketsms: number[];
+        stats: RpcCommandStats[];
+        traces?: RpcTraceSpan[];
+    };
+
+    // wshrpc.RpcTraceSpan
+    type RpcTraceSpan = {
+        traceid: string;
+        side: string;
+        command: string;
+        route: string;
+        startts: number;
+        durationms: number;
+        error?: string;
+    };
+
     // starobj.RuntimeOpts
     type RuntimeOpts = {
         termsize?: TermSize;
@@ -692,6 +1039,7 @@ declare global {
         "ai:apiversion"?: string;
         "ai:maxtokens"?: number;
         "ai:timeoutms"?: number;
+        "ai:tools"?: boolean;
         "ai:fontsize"?: number;
         "ai:fixedfontsize"?: number;
         "term:*"?: boolean;
@@ -750,6 +1098,29 @@ declare global {
         "conn:*"?: boolean;
         "conn:askbeforewshinstall"?: boolean;
         "conn:wshenabled"?: boolean;
+        "history:*"?: boolean;
+        "history:disabled"?: boolean;
+        "history:ignore"?: string[];
+        "history:maxagedays"?: number;
+        "history:maxitems"?: number;
+        "file:*"?: boolean;
+        "file:trash"?: boolean;
+        "file:trashretentiondays"?: number;
+        "debug:*"?: boolean;
+        "debug:rpcmetrics"?: boolean;
+        "api:*"?: boolean;
+        "api:enabled"?: boolean;
+        "api:port"?: number;
+    };
+
+    // wshrpc.StarAIModelInfo
+    type StarAIModelInfo = {
+        name: string;
+        size?: number;
+        modifiedts?: number;
+        family?: string;
+        parametersize?: string;
+        quantization?: string;
     };
 
     // wshrpc.StarAIOptsType
@@ -763,6 +1134,7 @@ declare global {
         maxtokens?: number;
         maxchoices?: number;
         timeoutms?: number;
+        tools?: boolean;
     };
 
     // wshrpc.StarAIPacketType
@@ -774,6 +1146,8 @@ declare global {
         usage?: StarAIUsageType;
         index?: number;
         text?: string;
+        toolcalls?: StarAIToolCall[];
+        status?: string;
         error?: string;
     };
 
@@ -782,13 +1156,32 @@ declare global {
         role: string;
         content: string;
         name?: string;
+        toolcalls?: StarAIToolCall[];
+        toolcallid?: string;
+        iserror?: boolean;
     };
 
     // wshrpc.StarAIStreamRequest
     type StarAIStreamRequest = {
         clientid?: string;
+        blockid?: string;
         opts: StarAIOptsType;
         prompt: StarAIPromptMessageType[];
+        tools?: StarAIToolDef[];
+    };
+
+    // wshrpc.StarAIToolCall
+    type StarAIToolCall = {
+        id: string;
+        name: string;
+        input: string;
+    };
+
+    // wshrpc.StarAIToolDef
+    type StarAIToolDef = {
+        name: string;
+        description: string;
+        inputschema: {[key: string]: any};
     };
 
     // wshrpc.StarAIUsageType
@@ -1008,6 +1401,17 @@ declare global {
         blockids: string[];
     };
 
+    // wshrpc.TermSearchMatch
+    type TermSearchMatch = {
+        blockid: string;
+        offset: number;
+        endoffset: number;
+        line: string;
+        matchstart: number;
+        matchend: number;
+        archived?: boolean;
+    };
+
     // starobj.TermSize
     type TermSize = {
         rows: number;
@@ -1048,6 +1452,16 @@ declare global {
         values: {[key: string]: number};
     };
 
+    // wshrpc.TrashEntry
+    type TrashEntry = {
+        id: string;
+        uri: string;
+        isdir?: boolean;
+        size?: number;
+        reason: string;
+        trashedts: number;
+    };
+
     // starobj.UIContext
     type UIContext = {
         windowid: string;
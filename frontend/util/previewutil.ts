@@ -75,3 +75,14 @@ export function addOpenMenuItems(menu: ContextMenuItem[], conn: string, finfo: F
     }
     return menu;
 }
+
+const ArchiveSuffixes = [".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tbz", ".zip"];
+
+// tar and zip archives on wsh connections are browsed as directories, their root is at "<archive>//"
+export function isArchiveFile(conn: string, finfo: FileInfo): boolean {
+    if (finfo == null || finfo.isdir || finfo.notfound || conn?.startsWith("aws:")) {
+        return false;
+    }
+    const name = finfo.name?.toLowerCase() ?? "";
+    return ArchiveSuffixes.some((suffix) => name.length > suffix.length && name.endsWith(suffix));
+}
@@ -0,0 +1,154 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// reads and writes asciicast v2 files (https://docs.asciinema.org/manual/asciicast/v2/).
+// a header line (json object) followed by one event per line: [time, type, data]
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+const Version = 2
+
+const MaxLineSize = 16 * 1024 * 1024
+
+const (
+	EventType_Output = "o"
+	EventType_Input  = "i"
+	EventType_Resize = "r"
+	EventType_Marker = "m"
+)
+
+type Header struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp,omitempty"` // unix seconds
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+}
+
+type Event struct {
+	Time float64 // seconds since the start of the recording
+	Type string
+	Data string
+}
+
+func EncodeHeader(header Header) ([]byte, error) {
+	barr, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	return append(barr, '\n'), nil
+}
+
+func EncodeEvent(event Event) ([]byte, error) {
+	// microsecond precision is plenty (and keeps the files smaller)
+	eventTime := math.Round(event.Time*1e6) / 1e6
+	barr, err := json.Marshal([]any{eventTime, event.Type, event.Data})
+	if err != nil {
+		return nil, err
+	}
+	return append(barr, '\n'), nil
+}
+
+func FormatResize(cols int, rows int) string {
+	return fmt.Sprintf("%dx%d", cols, rows)
+}
+
+func ParseResize(data string) (cols int, rows int, err error) {
+	_, err = fmt.Sscanf(data, "%dx%d", &cols, &rows)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid resize event %q", data)
+	}
+	return cols, rows, nil
+}
+
+// splits off an incomplete utf-8 sequence at the end of data (so it can be prepended to the next chunk).
+// event data must be a string, a rune split across two pty reads would otherwise get mangled.
+func SplitIncompleteRune(data []byte) (complete []byte, partial []byte) {
+	// a rune is at most 4 bytes, so only the last 3 bytes can start an incomplete one
+	for back := 1; back <= 3 && back <= len(data); back++ {
+		idx := len(data) - back
+		if !utf8.RuneStart(data[idx]) {
+			continue
+		}
+		if utf8.FullRune(data[idx:]) {
+			return data, nil
+		}
+		return data[:idx], data[idx:]
+	}
+	return data, nil
+}
+
+type Reader struct {
+	scanner *bufio.Scanner
+	lineNum int
+}
+
+// reads the header, events are read with Next
+func MakeReader(r io.Reader) (*Reader, *Header, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MaxLineSize)
+	rtn := &Reader{scanner: scanner}
+	line, err := rtn.nextLine()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("empty asciicast file")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var header Header
+	err = json.Unmarshal(line, &header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid asciicast header: %w", err)
+	}
+	if header.Version != Version {
+		return nil, nil, fmt.Errorf("unsupported asciicast version %d", header.Version)
+	}
+	return rtn, &header, nil
+}
+
+func (r *Reader) nextLine() ([]byte, error) {
+	for r.scanner.Scan() {
+		r.lineNum++
+		line := r.scanner.Bytes()
+		if strings.TrimSpace(string(line)) == "" {
+			continue
+		}
+		return line, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading asciicast file: %w", err)
+	}
+	return nil, io.EOF
+}
+
+// returns io.EOF at the end of the file
+func (r *Reader) Next() (*Event, error) {
+	line, err := r.nextLine()
+	if err != nil {
+		return nil, err
+	}
+	var parts []json.RawMessage
+	err = json.Unmarshal(line, &parts)
+	if err != nil || len(parts) != 3 {
+		return nil, fmt.Errorf("invalid asciicast event on line %d", r.lineNum)
+	}
+	var event Event
+	err1 := json.Unmarshal(parts[0], &event.Time)
+	err2 := json.Unmarshal(parts[1], &event.Type)
+	err3 := json.Unmarshal(parts[2], &event.Data)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, fmt.Errorf("invalid asciicast event on line %d", r.lineNum)
+	}
+	return &event, nil
+}
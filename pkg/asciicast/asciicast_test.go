@@ -0,0 +1,98 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package asciicast
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	headerBytes, err := EncodeHeader(Header{Version: Version, Width: 80, Height: 24, Timestamp: 1700000000})
+	if err != nil {
+		t.Fatalf("error encoding header: %v", err)
+	}
+	buf.Write(headerBytes)
+	events := []Event{
+		{Time: 0.1234567, Type: EventType_Output, Data: "\x1b[1mhello\x1b[0m\r\n"},
+		{Time: 1.5, Type: EventType_Resize, Data: FormatResize(120, 40)},
+		{Time: 2, Type: EventType_Output, Data: "héllo \"quoted\"\n"},
+	}
+	for _, event := range events {
+		eventBytes, err := EncodeEvent(event)
+		if err != nil {
+			t.Fatalf("error encoding event: %v", err)
+		}
+		buf.Write(eventBytes)
+	}
+	reader, header, err := MakeReader(&buf)
+	if err != nil {
+		t.Fatalf("error reading header: %v", err)
+	}
+	if header.Width != 80 || header.Height != 24 || header.Timestamp != 1700000000 {
+		t.Errorf("bad header: %+v", header)
+	}
+	for idx, expected := range events {
+		event, err := reader.Next()
+		if err != nil {
+			t.Fatalf("error reading event %d: %v", idx, err)
+		}
+		if event.Type != expected.Type || event.Data != expected.Data {
+			t.Errorf("event %d mismatch: got %+v, expected %+v", idx, event, expected)
+		}
+	}
+	if event, _ := reader.Next(); event != nil {
+		t.Errorf("expected no more events, got %+v", event)
+	}
+	if _, err = reader.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+	// time is rounded to microseconds
+	buf.Reset()
+	eventBytes, _ := EncodeEvent(events[0])
+	if !bytes.HasPrefix(eventBytes, []byte("[0.123457,")) {
+		t.Errorf("bad event time encoding: %s", eventBytes)
+	}
+}
+
+func TestParseResize(t *testing.T) {
+	cols, rows, err := ParseResize("132x43")
+	if err != nil || cols != 132 || rows != 43 {
+		t.Errorf("bad resize parse: %d %d %v", cols, rows, err)
+	}
+	_, _, err = ParseResize("wide")
+	if err == nil {
+		t.Errorf("expected error for invalid resize")
+	}
+}
+
+func TestSplitIncompleteRune(t *testing.T) {
+	euro := []byte("€") // 3 bytes
+	data := append([]byte("abc"), euro[:2]...)
+	complete, partial := SplitIncompleteRune(data)
+	if string(complete) != "abc" || !bytes.Equal(partial, euro[:2]) {
+		t.Errorf("bad split: %q %q", complete, partial)
+	}
+	complete, partial = SplitIncompleteRune(append([]byte("abc"), euro...))
+	if string(complete) != "abc€" || len(partial) != 0 {
+		t.Errorf("complete rune should not be split: %q %q", complete, partial)
+	}
+	complete, partial = SplitIncompleteRune([]byte("plain"))
+	if string(complete) != "plain" || len(partial) != 0 {
+		t.Errorf("ascii should not be split: %q %q", complete, partial)
+	}
+}
+
+func TestBadHeader(t *testing.T) {
+	_, _, err := MakeReader(bytes.NewReader([]byte(`{"version": 1, "width": 80, "height": 24}` + "\n")))
+	if err == nil {
+		t.Errorf("expected error for version 1 file")
+	}
+	_, _, err = MakeReader(bytes.NewReader(nil))
+	if err == nil {
+		t.Errorf("expected error for empty file")
+	}
+}
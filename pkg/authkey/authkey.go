@@ -4,6 +4,9 @@
 package authkey
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"os"
@@ -37,3 +40,22 @@ func SetAuthKeyFromEnv() error {
 func GetAuthKey() string {
 	return authkey
 }
+
+// automation api tokens (see web/webapi), only their hashes are kept in the config
+const ApiTokenPrefix = "starapi_"
+
+// returns a new random api token and its hash
+func MakeApiToken() (string, string, error) {
+	tokenBytes := make([]byte, 32)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		return "", "", fmt.Errorf("error generating api token: %w", err)
+	}
+	token := ApiTokenPrefix + hex.EncodeToString(tokenBytes)
+	return token, HashApiToken(token), nil
+}
+
+func HashApiToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
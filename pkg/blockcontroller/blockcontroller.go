@@ -26,6 +26,7 @@ import (
 	"github.com/commandlinedev/starterm/pkg/shellexec"
 	"github.com/commandlinedev/starterm/pkg/starbase"
 	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/termarchive"
 	"github.com/commandlinedev/starterm/pkg/util/envutil"
 	"github.com/commandlinedev/starterm/pkg/util/fileutil"
 	"github.com/commandlinedev/starterm/pkg/util/shellutil"
@@ -84,8 +85,12 @@ type BlockController struct {
 	ShellInputCh      chan *BlockInputUnion
 	ShellProcStatus   string
 	ShellProcExitCode int
+	ShellProcExitTs   int64
 	RunLock           *atomic.Bool
+	ArchiveMode       *atomic.Bool // term:archive, read when the shell starts (saves a stat of the term file on every append)
 	StatusVersion     int
+	CmdTracker        *CmdTracker
+	Recorder          *TermRecorder
 }
 
 type BlockControllerRuntimeStatus struct {
@@ -156,6 +161,13 @@ func HandleTruncateBlockFile(blockId string) error {
 	if err != nil {
 		return fmt.Errorf("error truncating blockfile: %w", err)
 	}
+	if bc := GetBlockController(blockId); bc != nil {
+		bc.resetCmdTracker()
+	}
+	err = termarchive.ResetArchive(ctx, blockId, starbase.BlockFile_Term)
+	if err != nil {
+		log.Printf("error resetting term archive (continuing): %v\n", err)
+	}
 	err = filestore.WFS.DeleteFile(ctx, blockId, starbase.BlockFile_Cache)
 	if err == fs.ErrNotExist {
 		err = nil
@@ -179,6 +191,12 @@ func HandleTruncateBlockFile(blockId string) error {
 func HandleAppendBlockFile(blockId string, blockFile string, data []byte) error {
 	ctx, cancelFn := context.WithTimeout(context.Background(), DefaultTimeout)
 	defer cancelFn()
+	if bc := GetBlockController(blockId); blockFile == starbase.BlockFile_Term && bc != nil && bc.ArchiveMode.Load() {
+		archiveErr := termarchive.ArchiveBeforeAppend(ctx, blockId, blockFile, data)
+		if archiveErr != nil {
+			log.Printf("error archiving term output for block %s (continuing): %v\n", blockId, archiveErr)
+		}
+	}
 	err := filestore.WFS.AppendData(ctx, blockId, blockFile, data)
 	if err != nil {
 		return fmt.Errorf("error appending to blockfile: %w", err)
@@ -533,6 +551,12 @@ func (bc *BlockController) setupAndStartShellProcess(logCtx context.Context, rc
 		// reset the terminal state
 		bc.resetTerminalState(logCtx)
 	}
+	archiveMode := blockMeta.GetBool(starobj.MetaKey_TermArchive, false)
+	fsErr = termarchive.SetArchiveMode(ctx, bc.BlockId, starbase.BlockFile_Term, archiveMode)
+	if fsErr != nil {
+		log.Printf("error setting archive mode for block %s: %v\n", bc.BlockId, fsErr)
+	}
+	bc.ArchiveMode.Store(archiveMode && fsErr == nil)
 	bcInitStatus := bc.GetRuntimeStatus()
 	if bcInitStatus.ShellProcStatus == Status_Running {
 		return nil, nil
@@ -580,7 +604,7 @@ func (bc *BlockController) setupAndStartShellProcess(logCtx context.Context, rc
 			}
 		} else {
 			sockName := wslConn.GetDomainSocketName()
-			rpcContext := wshrpc.RpcContext{TabId: bc.TabId, BlockId: bc.BlockId, Conn: wslConn.GetName()}
+			rpcContext := wshrpc.RpcContext{TabId: bc.TabId, BlockId: bc.BlockId, Conn: wslConn.GetName(), Caps: blockMeta.GetStringList(starobj.MetaKey_CmdWshCaps)}
 			jwtStr, err := wshutil.MakeClientJWTToken(rpcContext, sockName)
 			if err != nil {
 				return nil, fmt.Errorf("error making jwt token: %w", err)
@@ -609,7 +633,7 @@ func (bc *BlockController) setupAndStartShellProcess(logCtx context.Context, rc
 			}
 		} else {
 			sockName := conn.GetDomainSocketName()
-			rpcContext := wshrpc.RpcContext{TabId: bc.TabId, BlockId: bc.BlockId, Conn: conn.Opts.String()}
+			rpcContext := wshrpc.RpcContext{TabId: bc.TabId, BlockId: bc.BlockId, Conn: conn.Opts.String(), Caps: blockMeta.GetStringList(starobj.MetaKey_CmdWshCaps)}
 			jwtStr, err := wshutil.MakeClientJWTToken(rpcContext, sockName)
 			if err != nil {
 				return nil, fmt.Errorf("error making jwt token: %w", err)
@@ -632,7 +656,7 @@ func (bc *BlockController) setupAndStartShellProcess(logCtx context.Context, rc
 	} else if connUnion.ConnType == ConnType_Local {
 		if connUnion.WshEnabled {
 			sockName := starbase.GetDomainSocketName()
-			rpcContext := wshrpc.RpcContext{TabId: bc.TabId, BlockId: bc.BlockId}
+			rpcContext := wshrpc.RpcContext{TabId: bc.TabId, BlockId: bc.BlockId, Caps: blockMeta.GetStringList(starobj.MetaKey_CmdWshCaps)}
 			jwtStr, err := wshutil.MakeClientJWTToken(rpcContext, sockName)
 			if err != nil {
 				return nil, fmt.Errorf("error making jwt token: %w", err)
@@ -676,9 +700,12 @@ func (bc *BlockController) manageRunningShellProcess(shellProc *shellexec.ShellP
 	// make esc sequence wshclient wshProxy
 	// we don't need to authenticate this wshProxy since it is coming direct
 	wshProxy := wshutil.MakeRpcProxy()
-	wshProxy.SetRpcContext(&wshrpc.RpcContext{TabId: bc.TabId, BlockId: bc.BlockId})
+	wshProxy.SetRpcContext(&wshrpc.RpcContext{TabId: bc.TabId, BlockId: bc.BlockId, Caps: blockMeta.GetStringList(starobj.MetaKey_CmdWshCaps)})
 	wshutil.DefaultRouter.RegisterRoute(wshutil.MakeControllerRouteId(bc.BlockId), wshProxy, true)
 	ptyBuffer := wshutil.MakePtyBuffer(wshutil.StarOSCPrefix, shellProc.Cmd, wshProxy.FromRemoteCh)
+	if blockMeta.GetBool(starobj.MetaKey_TermRecord, false) {
+		bc.startRecording(rc.TermSize)
+	}
 	go func() {
 		// handles regular output from the pty (goes to the blockfile and xterm)
 		defer func() {
@@ -706,6 +733,8 @@ func (bc *BlockController) manageRunningShellProcess(shellProc *shellexec.ShellP
 		for {
 			nr, err := ptyBuffer.Read(buf)
 			if nr > 0 {
+				bc.trackShellIntegration(buf[:nr])
+				bc.recordOutput(buf[:nr])
 				err := HandleAppendBlockFile(bc.BlockId, starbase.BlockFile_Term, buf[:nr])
 				if err != nil {
 					log.Printf("error appending to blockfile: %v\n", err)
@@ -732,6 +761,7 @@ func (bc *BlockController) manageRunningShellProcess(shellProc *shellexec.ShellP
 			}
 			if ic.TermSize != nil {
 				updateTermSize(shellProc, bc.BlockId, *ic.TermSize)
+				bc.recordResize(*ic.TermSize)
 			}
 		}
 	}()
@@ -756,11 +786,14 @@ func (bc *BlockController) manageRunningShellProcess(shellProc *shellexec.ShellP
 		var exitCode int
 		defer func() {
 			wshutil.DefaultRouter.UnregisterRoute(wshutil.MakeControllerRouteId(bc.BlockId))
+			bc.finishRunningCmd()
+			bc.stopRecording()
 			bc.UpdateControllerAndSendUpdate(func() bool {
 				if bc.ShellProcStatus == Status_Running {
 					bc.ShellProcStatus = Status_Done
 				}
 				bc.ShellProcExitCode = exitCode
+				bc.ShellProcExitTs = time.Now().UnixMilli()
 				return true
 			})
 			log.Printf("[shellproc] shell process wait loop done\n")
@@ -1003,6 +1036,7 @@ func getOrCreateBlockController(tabId string, blockId string, controllerName str
 			BlockId:         blockId,
 			ShellProcStatus: Status_Init,
 			RunLock:         &atomic.Bool{},
+			ArchiveMode:     &atomic.Bool{},
 		}
 		blockControllerMap[blockId] = bc
 		createdController = true
@@ -1137,6 +1171,31 @@ func StopAllBlockControllers() {
 	}
 }
 
+// restarts the shells that went down with a dropped connection (called once the connection is re-established).
+// a shell counts as dropped if it exited after the connection was lost (with some slack for the ssh channel closing first)
+func RestartDroppedConnControllers(connName string, droppedTs int64) {
+	const dropSlackMs = 2000
+	for _, bc := range getControllerList() {
+		var shouldRestart bool
+		bc.WithLock(func() {
+			shouldRestart = bc.ControllerType == BlockController_Shell &&
+				bc.ShellProc != nil && bc.ShellProc.ConnName == connName &&
+				bc.ShellProcStatus == Status_Done && bc.ShellProcExitTs >= droppedTs-dropSlackMs
+		})
+		if !shouldRestart {
+			continue
+		}
+		// not a timeout ctx, the shell is started in the background with it
+		ctx := blocklogger.ContextWithLogBlockId(context.Background(), bc.BlockId, false)
+		log.Printf("restarting blockcontroller %s after reconnect to %q\n", bc.BlockId, connName)
+		// force is required, run() only auto-starts shells from the init status
+		err := ResyncController(ctx, bc.TabId, bc.BlockId, nil, true)
+		if err != nil {
+			log.Printf("error restarting blockcontroller %s after reconnect: %v\n", bc.BlockId, err)
+		}
+	}
+}
+
 func GetBlockController(blockId string) *BlockController {
 	globalLock.Lock()
 	defer globalLock.Unlock()
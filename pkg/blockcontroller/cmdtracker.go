@@ -0,0 +1,259 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package blockcontroller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/cmdhistory"
+	"github.com/commandlinedev/starterm/pkg/filestore"
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/util/shellutil"
+	"github.com/commandlinedev/starterm/pkg/wps"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wstore"
+)
+
+const MaxCmdRecords = 500
+
+// builds per-command records out of the shell integration markers (OSC 133 A/B/C/D + OSC 7) in the pty output.
+// offsets are logical offsets into the term blockfile (they keep growing when the circular file wraps).
+// protected by the BlockController lock
+type CmdTracker struct {
+	scanner      *shellutil.SIScanner
+	ptyOffset    int64 // pty bytes scanned, the scanner works in these offsets
+	cwd          string
+	promptOffset int64
+	curCmd       *wshrpc.BlockCommandRecord
+	records      []*wshrpc.BlockCommandRecord
+	nextCmdId    int
+}
+
+func makeCmdTracker() *CmdTracker {
+	return &CmdTracker{scanner: shellutil.MakeSIScanner(), promptOffset: -1}
+}
+
+// returns the records that changed (copies, safe to publish)
+func (t *CmdTracker) processMarks(blockId string, marks []shellutil.SIMark) []wshrpc.BlockCommandRecord {
+	var changed []wshrpc.BlockCommandRecord
+	for _, mark := range marks {
+		switch mark.Mark {
+		case shellutil.SIMark_Cwd:
+			t.cwd = mark.Cwd
+		case shellutil.SIMark_PromptStart:
+			t.promptOffset = mark.Offset
+		case shellutil.SIMark_OutputStart:
+			if t.curCmd != nil {
+				// never got a D for the last command (e.g. the shell integration was interrupted)
+				changed = append(changed, t.finishCmd(mark.Offset, nil))
+			}
+			t.nextCmdId++
+			t.curCmd = &wshrpc.BlockCommandRecord{
+				CmdId:        t.nextCmdId,
+				BlockId:      blockId,
+				CmdLine:      mark.CmdLine,
+				Cwd:          t.cwd,
+				PromptOffset: t.promptOffset,
+				StartOffset:  mark.EndOffset,
+				StartTs:      time.Now().UnixMilli(),
+				Running:      true,
+			}
+			t.promptOffset = -1
+			t.records = append(t.records, t.curCmd)
+			if len(t.records) > MaxCmdRecords {
+				t.records = t.records[len(t.records)-MaxCmdRecords:]
+			}
+			changed = append(changed, *t.curCmd)
+		case shellutil.SIMark_CommandDone:
+			// shells send D before every prompt, it only counts if a command is running
+			if t.curCmd != nil {
+				changed = append(changed, t.finishCmd(mark.Offset, mark.ExitCode))
+			}
+		}
+	}
+	return changed
+}
+
+func (t *CmdTracker) finishCmd(endOffset int64, exitCode *int) wshrpc.BlockCommandRecord {
+	cmd := t.curCmd
+	t.curCmd = nil
+	cmd.EndOffset = endOffset
+	cmd.EndTs = time.Now().UnixMilli()
+	cmd.Duration = cmd.EndTs - cmd.StartTs
+	cmd.ExitCode = exitCode
+	cmd.Running = false
+	return *cmd
+}
+
+func (t *CmdTracker) getRecords(limit int) []wshrpc.BlockCommandRecord {
+	records := t.records
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	rtn := make([]wshrpc.BlockCommandRecord, 0, len(records))
+	for _, rec := range records {
+		rtn = append(rtn, *rec)
+	}
+	return rtn
+}
+
+func getTermFileSize(blockId string) int64 {
+	ctx, cancelFn := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancelFn()
+	wfile, err := filestore.WFS.Stat(ctx, blockId, starbase.BlockFile_Term)
+	if err != nil {
+		return 0
+	}
+	return wfile.Size
+}
+
+func publishBlockCommandEvent(tabId string, rec wshrpc.BlockCommandRecord) {
+	wps.Broker.Publish(wps.StarEvent{
+		Event: wps.Event_BlockCommand,
+		Scopes: []string{
+			starobj.MakeORef(starobj.OType_Tab, tabId).String(),
+			starobj.MakeORef(starobj.OType_Block, rec.BlockId).String(),
+		},
+		Data: rec,
+	})
+}
+
+// stores a finished command in the persistent history (unless the block opted out with cmd:nohistory)
+func recordCmdHistory(tabId string, connName string, rec wshrpc.BlockCommandRecord) {
+	if rec.Running || rec.CmdLine == "" {
+		return
+	}
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("blockcontroller:recordCmdHistory", recover())
+		}()
+		ctx, cancelFn := context.WithTimeout(context.Background(), DefaultTimeout)
+		defer cancelFn()
+		block, err := wstore.DBMustGet[*starobj.Block](ctx, rec.BlockId)
+		if err != nil {
+			log.Printf("error getting block %s for history: %v\n", rec.BlockId, err)
+			return
+		}
+		if block.Meta.GetBool(starobj.MetaKey_CmdNoHistory, false) {
+			return
+		}
+		workspaceId, _ := wstore.DBFindWorkspaceForTabId(ctx, tabId)
+		err = cmdhistory.RecordCommand(ctx, wshrpc.HistoryItem{
+			Ts:          rec.StartTs,
+			Cmd:         rec.CmdLine,
+			Cwd:         rec.Cwd,
+			Conn:        connName,
+			BlockId:     rec.BlockId,
+			TabId:       tabId,
+			WorkspaceId: workspaceId,
+			ExitCode:    rec.ExitCode,
+			DurationMs:  rec.Duration,
+		})
+		if err != nil {
+			log.Printf("error recording command history for block %s: %v\n", rec.BlockId, err)
+		}
+	}()
+}
+
+func (bc *BlockController) getShellProcConnName_nolock() string {
+	if bc.ShellProc == nil {
+		return ""
+	}
+	return bc.ShellProc.ConnName
+}
+
+// must be called with pty output *before* it is appended to the term blockfile
+func (bc *BlockController) trackShellIntegration(data []byte) {
+	var tracker *CmdTracker
+	var marks []shellutil.SIMark
+	var chunkOffset int64
+	bc.WithLock(func() {
+		if bc.CmdTracker == nil {
+			bc.CmdTracker = makeCmdTracker()
+		}
+		tracker = bc.CmdTracker
+		chunkOffset = tracker.ptyOffset
+		marks = tracker.scanner.Scan(chunkOffset, data)
+		tracker.ptyOffset += int64(len(data))
+	})
+	if len(marks) == 0 {
+		return
+	}
+	// the term file also gets output that is not from the pty, so the marks are moved to file offsets
+	// (only stat the file when there are marks, this runs for every chunk of output)
+	shift := getTermFileSize(bc.BlockId) - chunkOffset
+	for idx := range marks {
+		marks[idx].Offset += shift
+		marks[idx].EndOffset += shift
+	}
+	var changed []wshrpc.BlockCommandRecord
+	var connName string
+	bc.WithLock(func() {
+		if bc.CmdTracker != tracker {
+			// reset while we were looking at the file
+			return
+		}
+		changed = tracker.processMarks(bc.BlockId, marks)
+		connName = bc.getShellProcConnName_nolock()
+	})
+	for _, rec := range changed {
+		publishBlockCommandEvent(bc.TabId, rec)
+		recordCmdHistory(bc.TabId, connName, rec)
+	}
+}
+
+// the shell exited, so a running command is not coming back
+func (bc *BlockController) finishRunningCmd() {
+	endOffset := getTermFileSize(bc.BlockId)
+	var rec *wshrpc.BlockCommandRecord
+	var connName string
+	bc.WithLock(func() {
+		if bc.CmdTracker == nil {
+			return
+		}
+		connName = bc.getShellProcConnName_nolock()
+		// a partial escape sequence from the old shell should not swallow output from the next one
+		bc.CmdTracker.scanner = shellutil.MakeSIScanner()
+		bc.CmdTracker.promptOffset = -1
+		if bc.CmdTracker.curCmd != nil {
+			finished := bc.CmdTracker.finishCmd(endOffset, nil)
+			rec = &finished
+		}
+	})
+	if rec != nil {
+		publishBlockCommandEvent(bc.TabId, *rec)
+		recordCmdHistory(bc.TabId, connName, *rec)
+	}
+}
+
+// the offsets are meaningless once the term file is truncated
+func (bc *BlockController) resetCmdTracker() {
+	bc.WithLock(func() {
+		if bc.CmdTracker == nil {
+			return
+		}
+		nextCmdId := bc.CmdTracker.nextCmdId
+		bc.CmdTracker = makeCmdTracker()
+		bc.CmdTracker.nextCmdId = nextCmdId
+	})
+}
+
+func GetBlockCommands(blockId string, limit int) ([]wshrpc.BlockCommandRecord, error) {
+	bc := GetBlockController(blockId)
+	if bc == nil {
+		return nil, fmt.Errorf("no controller found for block %q", blockId)
+	}
+	var rtn []wshrpc.BlockCommandRecord
+	bc.WithLock(func() {
+		if bc.CmdTracker != nil {
+			rtn = bc.CmdTracker.getRecords(limit)
+		}
+	})
+	return rtn, nil
+}
@@ -0,0 +1,261 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package blockcontroller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/asciicast"
+	"github.com/commandlinedev/starterm/pkg/filestore"
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wstore"
+)
+
+const MaxRecordingSize = 64 * 1024 * 1024
+
+const RecordMetaKey_StartTs = "startts" // file meta (ms), event times are relative to this
+
+const (
+	DefaultReplaySpeed   = 1.0
+	ReplayTermMaxSize    = 2 * 1024 * 1024
+	replayStartDelay     = 500 * time.Millisecond // give the new block a chance to load before output starts
+	replayFinishedMarker = "\r\n\x1b[2m[replay finished]\x1b[0m\r\n"
+)
+
+// records the pty output (and resizes) of a block into the BlockFile_Record blockfile (asciicast v2).
+// protected by the BlockController lock
+type TermRecorder struct {
+	startTs int64
+	size    int64
+	pending []byte // incomplete utf-8 sequence at the end of the last read
+	stopped bool   // hit MaxRecordingSize
+}
+
+// creates the recording (with the asciicast header) if it does not exist, otherwise the recording is continued
+func openRecording(blockId string, termSize starobj.TermSize) (*TermRecorder, bool, error) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancelFn()
+	wfile, err := filestore.WFS.Stat(ctx, blockId, starbase.BlockFile_Record)
+	if err == nil {
+		startTs, ok := utilfn.ToInt64(wfile.Meta[RecordMetaKey_StartTs])
+		if !ok {
+			startTs = wfile.CreatedTs
+		}
+		return &TermRecorder{startTs: startTs, size: wfile.Size}, true, nil
+	}
+	if err != fs.ErrNotExist {
+		return nil, false, err
+	}
+	startTs := time.Now().UnixMilli()
+	header := asciicast.Header{
+		Version:   asciicast.Version,
+		Width:     termSize.Cols,
+		Height:    termSize.Rows,
+		Timestamp: startTs / 1000,
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	}
+	headerBytes, err := asciicast.EncodeHeader(header)
+	if err != nil {
+		return nil, false, err
+	}
+	err = filestore.WFS.MakeFile(ctx, blockId, starbase.BlockFile_Record, wshrpc.FileMeta{RecordMetaKey_StartTs: startTs}, wshrpc.FileOpts{})
+	if err != nil {
+		return nil, false, fmt.Errorf("error creating recording: %w", err)
+	}
+	err = filestore.WFS.AppendData(ctx, blockId, starbase.BlockFile_Record, headerBytes)
+	if err != nil {
+		return nil, false, fmt.Errorf("error writing recording header: %w", err)
+	}
+	return &TermRecorder{startTs: startTs, size: int64(len(headerBytes))}, false, nil
+}
+
+func (r *TermRecorder) makeEvent(blockId string, eventType string, data string) []byte {
+	if r.stopped {
+		return nil
+	}
+	elapsed := float64(time.Now().UnixMicro()-r.startTs*1000) / 1e6
+	eventBytes, err := asciicast.EncodeEvent(asciicast.Event{Time: elapsed, Type: eventType, Data: data})
+	if err != nil {
+		log.Printf("error encoding recording event for block %s: %v\n", blockId, err)
+		return nil
+	}
+	if r.size+int64(len(eventBytes)) > MaxRecordingSize {
+		log.Printf("recording for block %s hit the max size (%d bytes), stopping\n", blockId, MaxRecordingSize)
+		r.stopped = true
+		return nil
+	}
+	r.size += int64(len(eventBytes))
+	return eventBytes
+}
+
+func (bc *BlockController) appendRecording(eventBytes []byte) {
+	if len(eventBytes) == 0 {
+		return
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancelFn()
+	err := filestore.WFS.AppendData(ctx, bc.BlockId, starbase.BlockFile_Record, eventBytes)
+	if err != nil {
+		// most likely the recording was deleted, it gets recreated the next time recording starts
+		log.Printf("error appending to recording for block %s (stopping): %v\n", bc.BlockId, err)
+		bc.WithLock(func() {
+			bc.Recorder = nil
+		})
+	}
+}
+
+func (bc *BlockController) startRecording(termSize starobj.TermSize) {
+	var isRecording bool
+	bc.WithLock(func() {
+		isRecording = bc.Recorder != nil
+	})
+	if isRecording {
+		return
+	}
+	rec, continued, err := openRecording(bc.BlockId, termSize)
+	if err != nil {
+		log.Printf("error starting recording for block %s: %v\n", bc.BlockId, err)
+		return
+	}
+	bc.WithLock(func() {
+		if bc.Recorder == nil {
+			bc.Recorder = rec
+		}
+	})
+	if continued {
+		// the size might have changed since the recording was paused
+		bc.recordResize(termSize)
+	}
+}
+
+func (bc *BlockController) stopRecording() {
+	var eventBytes []byte
+	bc.WithLock(func() {
+		rec := bc.Recorder
+		if rec == nil {
+			return
+		}
+		if len(rec.pending) > 0 {
+			eventBytes = rec.makeEvent(bc.BlockId, asciicast.EventType_Output, strings.ToValidUTF8(string(rec.pending), "\uFFFD"))
+		}
+		bc.Recorder = nil
+	})
+	bc.appendRecording(eventBytes)
+}
+
+func (bc *BlockController) recordOutput(data []byte) {
+	var eventBytes []byte
+	bc.WithLock(func() {
+		rec := bc.Recorder
+		if rec == nil {
+			return
+		}
+		buf := make([]byte, 0, len(rec.pending)+len(data))
+		buf = append(buf, rec.pending...)
+		buf = append(buf, data...)
+		complete, partial := asciicast.SplitIncompleteRune(buf)
+		rec.pending = partial
+		if len(complete) == 0 {
+			return
+		}
+		eventBytes = rec.makeEvent(bc.BlockId, asciicast.EventType_Output, strings.ToValidUTF8(string(complete), "\uFFFD"))
+	})
+	bc.appendRecording(eventBytes)
+}
+
+func (bc *BlockController) recordResize(termSize starobj.TermSize) {
+	var eventBytes []byte
+	bc.WithLock(func() {
+		if bc.Recorder == nil {
+			return
+		}
+		eventBytes = bc.Recorder.makeEvent(bc.BlockId, asciicast.EventType_Resize, asciicast.FormatResize(termSize.Cols, termSize.Rows))
+	})
+	bc.appendRecording(eventBytes)
+}
+
+// called when term:record changes.  (if the shell is not running, recording starts with the shell)
+func SetRecording(ctx context.Context, blockId string, enabled bool) error {
+	bc := GetBlockController(blockId)
+	if bc == nil {
+		return nil
+	}
+	if !enabled {
+		bc.stopRecording()
+		return nil
+	}
+	if bc.GetRuntimeStatus().ShellProcStatus != Status_Running {
+		return nil
+	}
+	bdata, err := wstore.DBMustGet[*starobj.Block](ctx, blockId)
+	if err != nil {
+		return fmt.Errorf("error getting block: %w", err)
+	}
+	bc.startRecording(getTermSize(bdata))
+	return nil
+}
+
+type ReplayOpts struct {
+	Speed   float64
+	MaxIdle time.Duration // 0 for no limit
+}
+
+// plays the recording into the term blockfile of destBlockId (a block without a controller, so it is read-only).
+// resize events are skipped, the replay block is sized by the layout.  stops early if the block is deleted.
+func StartReplay(destBlockId string, reader *asciicast.Reader, opts ReplayOpts) error {
+	if opts.Speed <= 0 {
+		opts.Speed = DefaultReplaySpeed
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancelFn()
+	err := filestore.WFS.MakeFile(ctx, destBlockId, starbase.BlockFile_Term, nil, wshrpc.FileOpts{MaxSize: ReplayTermMaxSize, Circular: true})
+	if err != nil && err != fs.ErrExist {
+		return fmt.Errorf("error creating blockfile: %w", err)
+	}
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("blockcontroller:StartReplay", recover())
+		}()
+		time.Sleep(replayStartDelay)
+		var lastTime float64
+		for {
+			event, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Printf("error reading recording for replay in block %s: %v\n", destBlockId, err)
+				break
+			}
+			if event.Type != asciicast.EventType_Output {
+				continue
+			}
+			delay := time.Duration((event.Time - lastTime) / opts.Speed * float64(time.Second))
+			lastTime = event.Time
+			if opts.MaxIdle > 0 && delay > opts.MaxIdle {
+				delay = opts.MaxIdle
+			}
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+			err = HandleAppendBlockFile(destBlockId, starbase.BlockFile_Term, []byte(event.Data))
+			if err != nil {
+				// the replay block was closed
+				return
+			}
+		}
+		HandleAppendBlockFile(destBlockId, starbase.BlockFile_Term, []byte(replayFinishedMarker))
+	}()
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmdhistory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/sconfig"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wstore"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultMaxAgeDays  = 365
+	DefaultMaxItems    = 100000
+	DefaultSearchLimit = 100
+	MaxCmdLen          = 16 * 1024
+	CleanInterval      = 6 * time.Hour
+	InitialCleanWait   = 1 * time.Minute
+)
+
+const historyCols = `historyid, ts, cmd, cwd, conn, blockid, tabid, workspaceid, exitcode, durationms`
+
+func getSettings() sconfig.SettingsType {
+	watcher := sconfig.GetWatcher()
+	if watcher == nil {
+		return sconfig.SettingsType{}
+	}
+	return watcher.GetFullConfig().Settings
+}
+
+// returns the reason the command should not be stored ("" if it should be)
+func checkIgnore(settings sconfig.SettingsType, cmd string) string {
+	if strings.TrimSpace(cmd) == "" {
+		return "empty command"
+	}
+	// same convention as HISTCONTROL=ignorespace
+	if strings.HasPrefix(cmd, " ") {
+		return "command starts with a space"
+	}
+	for _, pattern := range settings.HistoryIgnore {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("invalid history:ignore pattern %q: %v\n", pattern, err)
+			continue
+		}
+		if re.MatchString(cmd) {
+			return fmt.Sprintf("matches history:ignore pattern %q", pattern)
+		}
+	}
+	return ""
+}
+
+// checks the global settings and the ignore patterns, the caller is responsible for the block opt-out (cmd:nohistory)
+func RecordCommand(ctx context.Context, item wshrpc.HistoryItem) error {
+	settings := getSettings()
+	if settings.HistoryDisabled {
+		return nil
+	}
+	if reason := checkIgnore(settings, item.Cmd); reason != "" {
+		return nil
+	}
+	if len(item.Cmd) > MaxCmdLen {
+		return fmt.Errorf("command too long to store in history (%d bytes)", len(item.Cmd))
+	}
+	if item.HistoryId == "" {
+		item.HistoryId = uuid.New().String()
+	}
+	if item.Ts == 0 {
+		item.Ts = time.Now().UnixMilli()
+	}
+	return wstore.WithTx(ctx, func(tx *wstore.TxWrap) error {
+		query := `INSERT INTO db_cmdhistory (` + historyCols + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		tx.Exec(query, item.HistoryId, item.Ts, item.Cmd, item.Cwd, item.Conn, item.BlockId, item.TabId, item.WorkspaceId, item.ExitCode, item.DurationMs)
+		return nil
+	})
+}
+
+func escapeLike(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `%`, `\%`)
+	return strings.ReplaceAll(s, `_`, `\_`)
+}
+
+// most recent first.  query is a case-insensitive substring match on the command.
+// with unique set, only the most recent run of each command is returned
+func SearchHistory(ctx context.Context, data wshrpc.CommandHistorySearchData) ([]wshrpc.HistoryItem, error) {
+	limit := data.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	return wstore.WithTxRtn(ctx, func(tx *wstore.TxWrap) ([]wshrpc.HistoryItem, error) {
+		var whereClauses []string
+		var args []any
+		if data.Query != "" {
+			whereClauses = append(whereClauses, `cmd LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+escapeLike(data.Query)+"%")
+		}
+		if data.Conn != nil {
+			whereClauses = append(whereClauses, `conn = ?`)
+			args = append(args, *data.Conn)
+		}
+		if data.Cwd != "" {
+			whereClauses = append(whereClauses, `cwd = ?`)
+			args = append(args, data.Cwd)
+		}
+		if data.Failed {
+			whereClauses = append(whereClauses, `exitcode IS NOT NULL AND exitcode <> 0`)
+		}
+		if data.Since > 0 {
+			whereClauses = append(whereClauses, `ts >= ?`)
+			args = append(args, data.Since)
+		}
+		var whereStr string
+		if len(whereClauses) > 0 {
+			whereStr = ` WHERE ` + strings.Join(whereClauses, " AND ")
+		}
+		var query string
+		if data.Unique {
+			// sqlite returns the bare columns from the row that has the max(ts)
+			query = `SELECT historyid, max(ts) AS ts, cmd, cwd, conn, blockid, tabid, workspaceid, exitcode, durationms
+			         FROM db_cmdhistory` + whereStr + ` GROUP BY cmd ORDER BY ts DESC LIMIT ?`
+		} else {
+			query = `SELECT ` + historyCols + ` FROM db_cmdhistory` + whereStr + ` ORDER BY ts DESC LIMIT ?`
+		}
+		args = append(args, limit)
+		var rtn []wshrpc.HistoryItem
+		tx.Select(&rtn, query, args...)
+		return rtn, nil
+	})
+}
+
+// used for suggestions, conn "" means all connections
+func GetRecentHistory(ctx context.Context, conn string, limit int) ([]wshrpc.HistoryItem, error) {
+	return wstore.WithTxRtn(ctx, func(tx *wstore.TxWrap) ([]wshrpc.HistoryItem, error) {
+		var rtn []wshrpc.HistoryItem
+		if conn == "" {
+			tx.Select(&rtn, `SELECT `+historyCols+` FROM db_cmdhistory ORDER BY ts DESC LIMIT ?`, limit)
+		} else {
+			tx.Select(&rtn, `SELECT `+historyCols+` FROM db_cmdhistory WHERE conn = ? ORDER BY ts DESC LIMIT ?`, conn, limit)
+		}
+		return rtn, nil
+	})
+}
+
+// returns the number of items removed
+func PurgeHistory(ctx context.Context, data wshrpc.CommandHistoryPurgeData) (int, error) {
+	if !data.All && data.Conn == nil {
+		return 0, fmt.Errorf("must specify a connection or all")
+	}
+	return wstore.WithTxRtn(ctx, func(tx *wstore.TxWrap) (int, error) {
+		var result sql.Result
+		if data.All {
+			result = tx.Exec(`DELETE FROM db_cmdhistory`)
+		} else {
+			result = tx.Exec(`DELETE FROM db_cmdhistory WHERE conn = ?`, *data.Conn)
+		}
+		if result == nil {
+			return 0, nil
+		}
+		numRows, _ := result.RowsAffected()
+		return int(numRows), nil
+	})
+}
+
+// enforces history:maxagedays and history:maxitems (0 disables either limit)
+func CleanOldHistory(ctx context.Context) error {
+	settings := getSettings()
+	maxAgeDays := int64(DefaultMaxAgeDays)
+	if settings.HistoryMaxAgeDays != nil {
+		maxAgeDays = *settings.HistoryMaxAgeDays
+	}
+	maxItems := int64(DefaultMaxItems)
+	if settings.HistoryMaxItems != nil {
+		maxItems = *settings.HistoryMaxItems
+	}
+	return cleanHistory(ctx, maxAgeDays, maxItems)
+}
+
+func cleanHistory(ctx context.Context, maxAgeDays int64, maxItems int64) error {
+	return wstore.WithTx(ctx, func(tx *wstore.TxWrap) error {
+		if maxAgeDays > 0 {
+			olderThan := time.Now().Add(-time.Duration(maxAgeDays) * 24 * time.Hour).UnixMilli()
+			tx.Exec(`DELETE FROM db_cmdhistory WHERE ts < ?`, olderThan)
+		}
+		if maxItems > 0 {
+			query := `DELETE FROM db_cmdhistory WHERE historyid IN (SELECT historyid FROM db_cmdhistory ORDER BY ts DESC LIMIT -1 OFFSET ?)`
+			tx.Exec(query, maxItems)
+		}
+		return nil
+	})
+}
+
+func RunCleanLoop() {
+	defer func() {
+		panichandler.PanicHandler("cmdhistory:RunCleanLoop", recover())
+	}()
+	time.Sleep(InitialCleanWait)
+	for {
+		ctx, cancelFn := context.WithTimeout(context.Background(), 30*time.Second)
+		err := CleanOldHistory(ctx)
+		cancelFn()
+		if err != nil {
+			log.Printf("error cleaning command history: %v\n", err)
+		}
+		time.Sleep(CleanInterval)
+	}
+}
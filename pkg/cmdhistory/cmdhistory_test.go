@@ -0,0 +1,205 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package cmdhistory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/sconfig"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wstore"
+)
+
+var testDbOnce sync.Once
+
+// the wstore db is global, so it is created (and migrated) once in a temp data dir, each test starts by clearing the history
+func initTestDb(t *testing.T) {
+	testDbOnce.Do(func() {
+		dataDir, err := os.MkdirTemp("", "cmdhistory-test")
+		if err != nil {
+			t.Fatalf("error creating data dir: %v", err)
+		}
+		err = os.MkdirAll(filepath.Join(dataDir, starbase.StarDBDir), 0700)
+		if err != nil {
+			t.Fatalf("error creating db dir: %v", err)
+		}
+		starbase.DataHome_VarCache = dataDir
+		err = wstore.InitWStore()
+		if err != nil {
+			t.Fatalf("error initializing wstore: %v", err)
+		}
+	})
+	_, err := PurgeHistory(context.Background(), wshrpc.CommandHistoryPurgeData{All: true})
+	if err != nil {
+		t.Fatalf("error clearing history: %v", err)
+	}
+}
+
+func addTestHistory(t *testing.T, items ...wshrpc.HistoryItem) {
+	for _, item := range items {
+		if err := RecordCommand(context.Background(), item); err != nil {
+			t.Fatalf("error recording %q: %v", item.Cmd, err)
+		}
+	}
+}
+
+func historyCmds(items []wshrpc.HistoryItem) []string {
+	var rtn []string
+	for _, item := range items {
+		rtn = append(rtn, item.Cmd)
+	}
+	return rtn
+}
+
+func TestCheckIgnore(t *testing.T) {
+	settings := sconfig.SettingsType{HistoryIgnore: []string{`^export .*TOKEN=`, `[invalid`, `^pass\b`}}
+	tests := []struct {
+		cmd    string
+		ignore bool
+	}{
+		{"ls -l", false},
+		{"", true},
+		{"   ", true},
+		{" ls -l", true},
+		{"export GH_TOKEN=abc", true},
+		{"export PATH=/bin", false},
+		{"pass show email", true},
+		{"passwd", false},
+		{"echo pass", false},
+	}
+	for _, tc := range tests {
+		reason := checkIgnore(settings, tc.cmd)
+		if (reason != "") != tc.ignore {
+			t.Errorf("checkIgnore(%q) = %q, want ignore=%v", tc.cmd, reason, tc.ignore)
+		}
+	}
+}
+
+func TestSearchHistory(t *testing.T) {
+	initTestDb(t)
+	ctx := context.Background()
+	now := time.Now().UnixMilli()
+	exit1 := 1
+	exit0 := 0
+	remote := "user@host"
+	local := ""
+	addTestHistory(t,
+		wshrpc.HistoryItem{Cmd: "make test", Cwd: "/src", Ts: now - 5000, ExitCode: &exit1},
+		wshrpc.HistoryItem{Cmd: "ls", Cwd: "/src", Ts: now - 4000, ExitCode: &exit0},
+		wshrpc.HistoryItem{Cmd: "make test", Cwd: "/src", Ts: now - 3000, ExitCode: &exit0},
+		wshrpc.HistoryItem{Cmd: "100%_done", Cwd: "/tmp", Ts: now - 2000},
+		wshrpc.HistoryItem{Cmd: "uptime", Conn: remote, Ts: now - 1000},
+		wshrpc.HistoryItem{Cmd: " secret", Ts: now},
+	)
+	tests := []struct {
+		name string
+		data wshrpc.CommandHistorySearchData
+		want []string
+	}{
+		{"all", wshrpc.CommandHistorySearchData{}, []string{"uptime", "100%_done", "make test", "ls", "make test"}},
+		{"unique", wshrpc.CommandHistorySearchData{Unique: true}, []string{"uptime", "100%_done", "make test", "ls"}},
+		{"query", wshrpc.CommandHistorySearchData{Query: "MAKE"}, []string{"make test", "make test"}},
+		{"like chars", wshrpc.CommandHistorySearchData{Query: "0%_"}, []string{"100%_done"}},
+		{"like chars literal", wshrpc.CommandHistorySearchData{Query: "%e"}, nil},
+		{"conn", wshrpc.CommandHistorySearchData{Conn: &remote}, []string{"uptime"}},
+		{"local", wshrpc.CommandHistorySearchData{Conn: &local, Cwd: "/src", Unique: true}, []string{"make test", "ls"}},
+		{"failed", wshrpc.CommandHistorySearchData{Failed: true}, []string{"make test"}},
+		{"since", wshrpc.CommandHistorySearchData{Since: now - 2500}, []string{"uptime", "100%_done"}},
+		{"limit", wshrpc.CommandHistorySearchData{Limit: 2}, []string{"uptime", "100%_done"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			items, err := SearchHistory(ctx, tc.data)
+			if err != nil {
+				t.Fatalf("error searching history: %v", err)
+			}
+			if got := historyCmds(items); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	// the unique row is the most recent run (with its exit code)
+	items, err := SearchHistory(ctx, wshrpc.CommandHistorySearchData{Query: "make", Unique: true})
+	if err != nil {
+		t.Fatalf("error searching history: %v", err)
+	}
+	if len(items) != 1 || items[0].Ts != now-3000 || items[0].ExitCode == nil || *items[0].ExitCode != 0 {
+		t.Fatalf("got %+v, want the most recent make test", items)
+	}
+}
+
+func TestPurgeHistory(t *testing.T) {
+	initTestDb(t)
+	ctx := context.Background()
+	remote := "user@host"
+	addTestHistory(t,
+		wshrpc.HistoryItem{Cmd: "ls"},
+		wshrpc.HistoryItem{Cmd: "uptime", Conn: remote},
+		wshrpc.HistoryItem{Cmd: "df", Conn: remote},
+	)
+	if _, err := PurgeHistory(ctx, wshrpc.CommandHistoryPurgeData{}); err == nil {
+		t.Fatalf("expected an error without a conn or all")
+	}
+	numRemoved, err := PurgeHistory(ctx, wshrpc.CommandHistoryPurgeData{Conn: &remote})
+	if err != nil {
+		t.Fatalf("error purging history: %v", err)
+	}
+	if numRemoved != 2 {
+		t.Fatalf("purged %d items, want 2", numRemoved)
+	}
+	items, err := SearchHistory(ctx, wshrpc.CommandHistorySearchData{})
+	if err != nil {
+		t.Fatalf("error searching history: %v", err)
+	}
+	if got := historyCmds(items); !reflect.DeepEqual(got, []string{"ls"}) {
+		t.Fatalf("got %q after purge, want [ls]", got)
+	}
+}
+
+func TestCleanHistory(t *testing.T) {
+	initTestDb(t)
+	ctx := context.Background()
+	now := time.Now()
+	addTestHistory(t,
+		wshrpc.HistoryItem{Cmd: "ancient", Ts: now.Add(-400 * 24 * time.Hour).UnixMilli()},
+		wshrpc.HistoryItem{Cmd: "old", Ts: now.Add(-40 * 24 * time.Hour).UnixMilli()},
+		wshrpc.HistoryItem{Cmd: "one", Ts: now.Add(-3 * time.Hour).UnixMilli()},
+		wshrpc.HistoryItem{Cmd: "two", Ts: now.Add(-2 * time.Hour).UnixMilli()},
+		wshrpc.HistoryItem{Cmd: "three", Ts: now.Add(-1 * time.Hour).UnixMilli()},
+	)
+	check := func(want []string) {
+		t.Helper()
+		items, err := SearchHistory(ctx, wshrpc.CommandHistorySearchData{})
+		if err != nil {
+			t.Fatalf("error searching history: %v", err)
+		}
+		if got := historyCmds(items); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+	if err := cleanHistory(ctx, 0, 0); err != nil {
+		t.Fatalf("error cleaning history: %v", err)
+	}
+	check([]string{"three", "two", "one", "old", "ancient"})
+	if err := cleanHistory(ctx, DefaultMaxAgeDays, 0); err != nil {
+		t.Fatalf("error cleaning history: %v", err)
+	}
+	check([]string{"three", "two", "one", "old"})
+	if err := cleanHistory(ctx, 30, 0); err != nil {
+		t.Fatalf("error cleaning history: %v", err)
+	}
+	check([]string{"three", "two", "one"})
+	if err := cleanHistory(ctx, 0, 2); err != nil {
+		t.Fatalf("error cleaning history: %v", err)
+	}
+	check([]string{"three", "two"})
+}
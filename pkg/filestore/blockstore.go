@@ -210,6 +210,9 @@ func (s *FileStore) WriteMeta(ctx context.Context, zoneId string, name string, m
 			return err
 		}
 		if merge {
+			if entry.File.Meta == nil {
+				entry.File.Meta = make(wshrpc.FileMeta)
+			}
 			for k, v := range meta {
 				if v == nil {
 					delete(entry.File.Meta, k)
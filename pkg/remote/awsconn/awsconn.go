@@ -6,19 +6,24 @@ package awsconn
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"regexp"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 
 	"github.com/aws/smithy-go"
+	"github.com/commandlinedev/starterm/pkg/sconfig"
 	"github.com/commandlinedev/starterm/pkg/starobj"
 	"gopkg.in/ini.v1"
 )
@@ -28,21 +33,79 @@ const (
 	ProfileCredentialsKey = "profile:credentials"
 	ProfilePrefix         = "aws:"
 	TempFilePattern       = "starterm-awsconfig-%s"
+
+	DefaultRegion = "us-west-2"
+	// S3-compatible servers (MinIO, Ceph RGW) generally accept any region but sign with us-east-1 by default
+	DefaultEndpointRegion = "us-east-1"
 )
 
 var connectionRe = regexp.MustCompile(`^(.*):\w+:\/\/.*$`)
 
 var tempfiles map[string]string = make(map[string]string)
 
+// returns the "aws:profile" part of a connection string like "aws:profile:s3://bucket/key" (or "profile:s3://bucket/key")
+func getConnName(connection string) (string, error) {
+	connMatch := connectionRe.FindStringSubmatch(connection)
+	if connMatch == nil {
+		return "", fmt.Errorf("invalid connection string: %s)", connection)
+	}
+	if !strings.HasPrefix(connMatch[1], ProfilePrefix) {
+		return ProfilePrefix + connMatch[1], nil
+	}
+	return connMatch[1], nil
+}
+
+// returns the connections from connections.json (a var for tests)
+var getConnections = func() map[string]sconfig.ConnKeywords {
+	watcher := sconfig.GetWatcher()
+	if watcher == nil {
+		return nil
+	}
+	return watcher.GetFullConfig().Connections
+}
+
+// returns the settings for the connection from connections.json (connName includes the "aws:" prefix)
+func getConnSettings(connName string) sconfig.ConnKeywords {
+	return getConnections()[connName]
+}
+
+// returns an http client for a custom endpoint with a private CA or self-signed certificate, nil if the defaults are fine
+func getHTTPClient(settings sconfig.ConnKeywords) (*awshttp.BuildableClient, error) {
+	insecure := sconfig.DefaultBoolPtr(settings.S3InsecureSkipVerify, false)
+	if !insecure && settings.S3CaFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if settings.S3CaFile != "" {
+		caPem, err := os.ReadFile(settings.S3CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading s3:cafile: %w", err)
+		}
+		certPool, err := x509.SystemCertPool()
+		if err != nil {
+			certPool = x509.NewCertPool()
+		}
+		if !certPool.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("no certificates found in s3:cafile %q", settings.S3CaFile)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+	return awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+		tr.TLSClientConfig = tlsConfig
+	}), nil
+}
+
 func GetConfig(ctx context.Context, profile string) (*aws.Config, error) {
 	optfns := []func(*config.LoadOptions) error{}
+	var settings sconfig.ConnKeywords
 	// If profile is empty, use default config
 	if profile != "" {
-		connMatch := connectionRe.FindStringSubmatch(profile)
-		if connMatch == nil {
-			return nil, fmt.Errorf("invalid connection string: %s)", profile)
+		connName, err := getConnName(profile)
+		if err != nil {
+			return nil, err
 		}
-		profile = connMatch[1]
+		profile = connName
+		settings = getConnSettings(profile)
 
 		// TODO: Reimplement generic profile support
 		// profiles, cerrs := sconfig.ReadStarHomeConfigFile(sconfig.ProfilesFile)
@@ -63,17 +126,58 @@ func GetConfig(ctx context.Context, profile string) (*aws.Config, error) {
 		// 		tempfiles[profile+"_credentials"] = credentialsfilepath
 		// 	}
 		// }
-		optfns = append(optfns, config.WithRegion("us-west-2"))
-		trimmedProfile := strings.TrimPrefix(profile, ProfilePrefix)
-		optfns = append(optfns, config.WithSharedConfigProfile(trimmedProfile))
+		region := DefaultRegion
+		if settings.S3Region != "" {
+			region = settings.S3Region
+		} else if settings.S3EndpointUrl != "" {
+			region = DefaultEndpointRegion
+		}
+		optfns = append(optfns, config.WithRegion(region))
+		sharedProfile := strings.TrimPrefix(profile, ProfilePrefix)
+		if settings.S3Profile != "" {
+			sharedProfile = settings.S3Profile
+		}
+		// an endpoint profile that only exists in connections.json uses the default credentials chain (environment, default profile)
+		if _, found := parseSharedProfiles()[ProfilePrefix+sharedProfile]; found || settings.S3EndpointUrl == "" || settings.S3Profile != "" {
+			optfns = append(optfns, config.WithSharedConfigProfile(sharedProfile))
+		}
+		httpClient, err := getHTTPClient(settings)
+		if err != nil {
+			return nil, err
+		}
+		if httpClient != nil {
+			optfns = append(optfns, config.WithHTTPClient(httpClient))
+		}
 	}
 	cfg, err := config.LoadDefaultConfig(ctx, optfns...)
 	if err != nil {
 		return nil, fmt.Errorf("error loading config: %v", err)
 	}
+	if settings.S3EndpointUrl != "" {
+		cfg.BaseEndpoint = aws.String(settings.S3EndpointUrl)
+		// most S3-compatible servers do not support the checksums the sdk adds to every request by default
+		cfg.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		cfg.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+	}
 	return &cfg, nil
 }
 
+// GetS3Options returns the s3 client options for the connection (path-style addressing for custom endpoints)
+func GetS3Options(connection string) []func(*s3.Options) {
+	connName, err := getConnName(connection)
+	if err != nil {
+		return nil
+	}
+	settings := getConnSettings(connName)
+	// path-style is the default for custom endpoints since MinIO and Ceph RGW usually are not set up for bucket subdomains
+	if !sconfig.DefaultBoolPtr(settings.S3PathStyle, settings.S3EndpointUrl != "") {
+		return nil
+	}
+	return []func(*s3.Options){func(o *s3.Options) {
+		o.UsePathStyle = true
+	}}
+}
+
 func getTempFileFromConfig(config starobj.MetaMapType, key string, profile string) (string, error) {
 	connectionconfig := config.GetMap(profile)
 	if connectionconfig[key] != "" {
@@ -93,7 +197,18 @@ func getTempFileFromConfig(config starobj.MetaMapType, key string, profile strin
 	return "", nil
 }
 
+// ParseProfiles returns the names of the s3 connections, from the aws shared config files and the endpoint profiles in connections.json
 func ParseProfiles() map[string]struct{} {
+	profiles := parseSharedProfiles()
+	for connName, settings := range getConnections() {
+		if strings.HasPrefix(connName, ProfilePrefix) && settings.S3EndpointUrl != "" {
+			profiles[connName] = struct{}{}
+		}
+	}
+	return profiles
+}
+
+func parseSharedProfiles() map[string]struct{} {
 	profiles := make(map[string]struct{})
 	fname := config.DefaultSharedConfigFilename()
 	errs := []error{}
@@ -0,0 +1,215 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package awsconn
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/commandlinedev/starterm/pkg/sconfig"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+)
+
+const testEndpoint = "https://minio.local:9000"
+
+// points the aws shared config files at a temp home and sets the connections.json connections
+func setupTestConfig(t *testing.T, connections map[string]sconfig.ConnKeywords) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(homeDir, ".aws", "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(homeDir, ".aws", "credentials"))
+	if err := os.MkdirAll(filepath.Join(homeDir, ".aws"), 0700); err != nil {
+		t.Fatalf("error making .aws dir: %v", err)
+	}
+	configData := "[profile dev]\nregion = eu-west-1\n\n[profile empty]\n"
+	if err := os.WriteFile(filepath.Join(homeDir, ".aws", "config"), []byte(configData), 0600); err != nil {
+		t.Fatalf("error writing aws config: %v", err)
+	}
+	credsData := "[prod]\naws_access_key_id = AKIAEXAMPLE\naws_secret_access_key = secret\n"
+	if err := os.WriteFile(filepath.Join(homeDir, ".aws", "credentials"), []byte(credsData), 0600); err != nil {
+		t.Fatalf("error writing aws credentials: %v", err)
+	}
+	oldGetConnections := getConnections
+	getConnections = func() map[string]sconfig.ConnKeywords { return connections }
+	t.Cleanup(func() { getConnections = oldGetConnections })
+}
+
+func writeTestCaFile(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error creating certificate: %v", err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("error writing ca file: %v", err)
+	}
+	return caFile
+}
+
+func TestGetS3Options(t *testing.T) {
+	setupTestConfig(t, map[string]sconfig.ConnKeywords{
+		"aws:minio":       {S3EndpointUrl: testEndpoint},
+		"aws:minio-vhost": {S3EndpointUrl: testEndpoint, S3PathStyle: utilfn.Ptr(false)},
+		"aws:pathstyle":   {S3PathStyle: utilfn.Ptr(true)},
+	})
+	tests := []struct {
+		connection string
+		pathStyle  bool
+	}{
+		{"aws:minio:s3://bucket/key", true},
+		{"minio:s3://bucket/key", true},
+		{"aws:minio-vhost:s3://bucket/key", false},
+		{"aws:pathstyle:s3://bucket", true},
+		{"aws:dev:s3://bucket/key", false},
+		{"not a connection", false},
+	}
+	for _, tc := range tests {
+		var opts s3.Options
+		for _, optFn := range GetS3Options(tc.connection) {
+			optFn(&opts)
+		}
+		if opts.UsePathStyle != tc.pathStyle {
+			t.Errorf("%s: got path-style %v, want %v", tc.connection, opts.UsePathStyle, tc.pathStyle)
+		}
+	}
+}
+
+func TestGetHTTPClient(t *testing.T) {
+	caFile := writeTestCaFile(t)
+	badCaFile := filepath.Join(t.TempDir(), "bad.pem")
+	if err := os.WriteFile(badCaFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("error writing bad ca file: %v", err)
+	}
+	tests := []struct {
+		name      string
+		settings  sconfig.ConnKeywords
+		wantNil   bool
+		wantSkip  bool
+		wantRoots bool
+		wantErr   bool
+	}{
+		{name: "defaults", settings: sconfig.ConnKeywords{S3EndpointUrl: testEndpoint}, wantNil: true},
+		{name: "skip verify off", settings: sconfig.ConnKeywords{S3InsecureSkipVerify: utilfn.Ptr(false)}, wantNil: true},
+		{name: "skip verify", settings: sconfig.ConnKeywords{S3InsecureSkipVerify: utilfn.Ptr(true)}, wantSkip: true},
+		{name: "ca file", settings: sconfig.ConnKeywords{S3CaFile: caFile}, wantRoots: true},
+		{name: "missing ca file", settings: sconfig.ConnKeywords{S3CaFile: filepath.Join(t.TempDir(), "missing.pem")}, wantErr: true},
+		{name: "no certs in ca file", settings: sconfig.ConnKeywords{S3CaFile: badCaFile}, wantErr: true},
+	}
+	for _, tc := range tests {
+		client, err := getHTTPClient(tc.settings)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if tc.wantNil {
+			if client != nil {
+				t.Errorf("%s: expected no custom client", tc.name)
+			}
+			continue
+		}
+		if client == nil {
+			t.Errorf("%s: expected a custom client", tc.name)
+			continue
+		}
+		tlsConfig := client.GetTransport().TLSClientConfig
+		if tlsConfig == nil || tlsConfig.InsecureSkipVerify != tc.wantSkip || (tlsConfig.RootCAs != nil) != tc.wantRoots {
+			t.Errorf("%s: unexpected tls config %+v", tc.name, tlsConfig)
+		}
+	}
+}
+
+func TestParseProfiles(t *testing.T) {
+	setupTestConfig(t, map[string]sconfig.ConnKeywords{
+		"aws:minio":    {S3EndpointUrl: testEndpoint},
+		"aws:settings": {S3Region: "us-east-2"},
+		"user@host":    {S3EndpointUrl: testEndpoint},
+	})
+	profiles := ParseProfiles()
+	for _, name := range []string{"aws:dev", "aws:prod", "aws:minio"} {
+		if _, found := profiles[name]; !found {
+			t.Errorf("profile %q is missing from %v", name, profiles)
+		}
+	}
+	// profiles without keys, connections without an endpoint and non-aws connections are not s3 connections
+	for _, name := range []string{"aws:empty", "aws:settings", "aws:user@host", "user@host"} {
+		if _, found := profiles[name]; found {
+			t.Errorf("unexpected profile %q in %v", name, profiles)
+		}
+	}
+}
+
+func TestGetConfigEndpoint(t *testing.T) {
+	setupTestConfig(t, map[string]sconfig.ConnKeywords{
+		"aws:minio":    {S3EndpointUrl: testEndpoint, S3InsecureSkipVerify: utilfn.Ptr(true)},
+		"aws:minio-eu": {S3EndpointUrl: testEndpoint, S3Region: "eu-central-1"},
+	})
+	tests := []struct {
+		profile  string
+		region   string
+		endpoint string
+		skipTls  bool
+	}{
+		// endpoint profiles only in connections.json do not need an aws profile
+		{"aws:minio:s3://bucket", DefaultEndpointRegion, testEndpoint, true},
+		{"aws:minio-eu:s3://bucket", "eu-central-1", testEndpoint, false},
+		{"aws:dev:s3://bucket", "us-west-2", "", false},
+	}
+	for _, tc := range tests {
+		cfg, err := GetConfig(context.Background(), tc.profile)
+		if err != nil {
+			t.Errorf("%s: error getting config: %v", tc.profile, err)
+			continue
+		}
+		if cfg.Region != tc.region {
+			t.Errorf("%s: got region %q, want %q", tc.profile, cfg.Region, tc.region)
+		}
+		var endpoint string
+		if cfg.BaseEndpoint != nil {
+			endpoint = *cfg.BaseEndpoint
+		}
+		if endpoint != tc.endpoint {
+			t.Errorf("%s: got endpoint %q, want %q", tc.profile, endpoint, tc.endpoint)
+		}
+		client, ok := cfg.HTTPClient.(*awshttp.BuildableClient)
+		skipTls := ok && client.GetTransport().TLSClientConfig != nil && client.GetTransport().TLSClientConfig.InsecureSkipVerify
+		if skipTls != tc.skipTls {
+			t.Errorf("%s: got tls skip verify %v, want %v", tc.profile, skipTls, tc.skipTls)
+		}
+	}
+	if _, err := GetConfig(context.Background(), "aws:missing:s3://bucket"); err == nil {
+		t.Errorf("expected an error for a profile that is neither an aws profile nor an endpoint")
+	}
+}
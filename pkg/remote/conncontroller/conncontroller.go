@@ -43,6 +43,7 @@ const (
 	Status_Init         = "init"
 	Status_Connecting   = "connecting"
 	Status_Connected    = "connected"
+	Status_Reconnecting = "reconnecting"
 	Status_Disconnected = "disconnected"
 	Status_Error        = "error"
 )
@@ -69,6 +70,11 @@ type SSHConn struct {
 	HasWaiter          *atomic.Bool
 	LastConnectTime    int64
 	ActiveConnNum      int
+	Forwards           []*connForward
+	NextForwardId      int
+	pendingForwards    []*connForward        // bind addrs reserved while their listeners are opened
+	LastConnFlags      *sconfig.ConnKeywords // used for automatic reconnects
+	reconnectRunning   bool
 }
 
 var ConnServerCmdTemplate = strings.TrimSpace(
@@ -115,6 +121,7 @@ func (conn *SSHConn) DeriveConnStatus() wshrpc.ConnStatus {
 		WshError:      conn.WshError,
 		NoWshReason:   conn.NoWshReason,
 		WshVersion:    conn.WshVersion,
+		Forwards:      conn.getForwardStatuses_nolock(),
 	}
 }
 
@@ -143,7 +150,7 @@ func (conn *SSHConn) FireConnChangeEvent() {
 func (conn *SSHConn) Close() error {
 	defer conn.FireConnChangeEvent()
 	conn.WithLock(func() {
-		if conn.Status == Status_Connected || conn.Status == Status_Connecting {
+		if conn.Status == Status_Connected || conn.Status == Status_Connecting || conn.Status == Status_Reconnecting {
 			// if status is init, disconnected, or error don't change it
 			conn.Status = Status_Disconnected
 		}
@@ -175,6 +182,7 @@ func (conn *SSHConn) close_nolock() {
 		conn.Client.Close()
 		conn.Client = nil
 	}
+	conn.closeForwards_nolock()
 }
 
 func (conn *SSHConn) GetDomainSocketName() string {
@@ -197,7 +205,7 @@ func (conn *SSHConn) GetName() string {
 func (conn *SSHConn) OpenDomainSocketListener(ctx context.Context) error {
 	conn.Infof(ctx, "running OpenDomainSocketListener...\n")
 	allowed := WithLockRtn(conn, func() bool {
-		return conn.Status == Status_Connecting
+		return conn.Status == Status_Connecting || conn.Status == Status_Reconnecting
 	})
 	if !allowed {
 		return fmt.Errorf("cannot open domain socket for %q when status is %q", conn.GetName(), conn.GetStatus())
@@ -267,25 +275,29 @@ func (conn *SSHConn) GetConfigShellPath() string {
 	return config.ConnShellPath
 }
 
+// mints a jwt for this connection's connserver (signed with the current signing key)
+func (conn *SSHConn) MakeConnServerJwtToken() (string, error) {
+	rpcCtx := wshrpc.RpcContext{
+		ClientType: wshrpc.ClientType_ConnServer,
+		Conn:       conn.GetName(),
+	}
+	return wshutil.MakeClientJWTToken(rpcCtx, conn.GetDomainSocketName())
+}
+
 // returns (needsInstall, clientVersion, osArchStr, error)
 // if wsh is not installed, the clientVersion will be "not-installed", and it will also return an osArchStr
 // if clientVersion is set, then no osArchStr will be returned
 func (conn *SSHConn) StartConnServer(ctx context.Context, afterUpdate bool) (bool, string, string, error) {
 	conn.Infof(ctx, "running StartConnServer...\n")
 	allowed := WithLockRtn(conn, func() bool {
-		return conn.Status == Status_Connecting
+		return conn.Status == Status_Connecting || conn.Status == Status_Reconnecting
 	})
 	if !allowed {
 		return false, "", "", fmt.Errorf("cannot start conn server for %q when status is %q", conn.GetName(), conn.GetStatus())
 	}
 	client := conn.GetClient()
 	wshPath := conn.getWshPath()
-	rpcCtx := wshrpc.RpcContext{
-		ClientType: wshrpc.ClientType_ConnServer,
-		Conn:       conn.GetName(),
-	}
-	sockName := conn.GetDomainSocketName()
-	jwtToken, err := wshutil.MakeClientJWTToken(rpcCtx, sockName)
+	jwtToken, err := conn.MakeConnServerJwtToken()
 	if err != nil {
 		return false, "", "", fmt.Errorf("unable to create jwt token for conn controller: %w", err)
 	}
@@ -388,7 +400,7 @@ func (conn *SSHConn) StartConnServer(ctx context.Context, afterUpdate bool) (boo
 	conn.Infof(ctx, "connserver started, waiting for route to be registered\n")
 	regCtx, cancelFn := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelFn()
-	err = wshutil.DefaultRouter.WaitForRegister(regCtx, wshutil.MakeConnectionRouteId(rpcCtx.Conn))
+	err = wshutil.DefaultRouter.WaitForRegister(regCtx, wshutil.MakeConnectionRouteId(conn.GetName()))
 	if err != nil {
 		return false, clientVersion, "", fmt.Errorf("timeout waiting for connserver to register")
 	}
@@ -510,7 +522,7 @@ func (conn *SSHConn) WaitForConnect(ctx context.Context) error {
 		if status.Status == Status_Connected {
 			return nil
 		}
-		if status.Status == Status_Connecting {
+		if status.Status == Status_Connecting || status.Status == Status_Reconnecting {
 			select {
 			case <-ctx.Done():
 				return fmt.Errorf("context timeout")
@@ -533,7 +545,7 @@ func (conn *SSHConn) Connect(ctx context.Context, connFlags *sconfig.ConnKeyword
 	blocklogger.Infof(ctx, "\n")
 	var connectAllowed bool
 	conn.WithLock(func() {
-		if conn.Status == Status_Connecting || conn.Status == Status_Connected {
+		if conn.Status == Status_Connecting || conn.Status == Status_Connected || conn.Status == Status_Reconnecting {
 			connectAllowed = false
 		} else {
 			conn.Status = Status_Connecting
@@ -566,6 +578,7 @@ func (conn *SSHConn) Connect(ctx context.Context, connFlags *sconfig.ConnKeyword
 		} else {
 			conn.Infof(ctx, "successfully connected (wsh:%v)\n\n", conn.WshEnabled.Load())
 			conn.Status = Status_Connected
+			conn.LastConnFlags = connFlags
 			conn.LastConnectTime = time.Now().UnixMilli()
 			if conn.ActiveConnNum == 0 {
 				conn.ActiveConnNum = int(activeConnCounter.Add(1))
@@ -631,13 +644,10 @@ func WithLockRtn[T any](conn *SSHConn, fn func() T) T {
 // returns (enable-wsh, ask-before-install)
 func (conn *SSHConn) getConnWshSettings() (bool, bool) {
 	config := sconfig.GetWatcher().GetFullConfig()
-	enableWsh := config.Settings.ConnWshEnabled
+	enableWsh := getWshEnabledSetting(conn.GetName())
 	askBeforeInstall := sconfig.DefaultBoolPtr(config.Settings.ConnAskBeforeWshInstall, true)
 	connSettings, ok := conn.getConnectionConfig()
 	if ok {
-		if connSettings.ConnWshEnabled != nil {
-			enableWsh = *connSettings.ConnWshEnabled
-		}
 		// if the connection object exists, and conn:askbeforewshinstall is not set, the user must have allowed it
 		// TODO: in v0.12+ this should be removed.  we'll explicitly write a "false" into the connection object on successful connection
 		if connSettings.ConnAskBeforeWshInstall == nil {
@@ -649,6 +659,15 @@ func (conn *SSHConn) getConnWshSettings() (bool, bool) {
 	return enableWsh, askBeforeInstall
 }
 
+// conn:wshenabled for the connection, falling back to the global setting
+func getWshEnabledSetting(connName string) bool {
+	config := sconfig.GetWatcher().GetFullConfig()
+	if connSettings, ok := config.Connections[connName]; ok && connSettings.ConnWshEnabled != nil {
+		return *connSettings.ConnWshEnabled
+	}
+	return config.Settings.ConnWshEnabled
+}
+
 type WshCheckResult struct {
 	WshEnabled    bool
 	ClientVersion string
@@ -759,6 +778,13 @@ func (conn *SSHConn) connectInternal(ctx context.Context, connFlags *sconfig.Con
 		}()
 		conn.waitForDisconnect()
 	}()
+	keywords, err := remote.FindConnKeywords(conn.Opts, connFlags)
+	if err != nil {
+		// the same lookup just succeeded in ConnectToClient, so this should not happen
+		conn.Infof(ctx, "ERROR cannot determine connection keywords: %v\n", err)
+		keywords = &sconfig.ConnKeywords{}
+	}
+	conn.startKeepAlive(ctx, client, keywords)
 	fmtAddr := knownhosts.Normalize(fmt.Sprintf("%s@%s", client.User(), client.RemoteAddr().String()))
 	conn.Infof(ctx, "normalized knownhosts address: %s\n", fmtAddr)
 	clientDisplayName := fmt.Sprintf("%s (%s)", conn.GetName(), fmtAddr)
@@ -772,6 +798,7 @@ func (conn *SSHConn) connectInternal(ctx context.Context, connFlags *sconfig.Con
 		}
 	}
 	conn.persistWshInstalled(ctx, wshResult)
+	conn.startConfigForwards(ctx, keywords)
 	return nil
 }
 
@@ -783,6 +810,7 @@ func (conn *SSHConn) waitForDisconnect() {
 		return
 	}
 	err := client.Wait()
+	var startReconnect bool
 	conn.WithLock(func() {
 		// disconnects happen for a variety of reasons (like network, etc. and are typically transient)
 		// so we just set the status to "disconnected" here (not error)
@@ -790,11 +818,26 @@ func (conn *SSHConn) waitForDisconnect() {
 		if err != nil && conn.Error == "" {
 			conn.Error = err.Error()
 		}
-		if conn.Status != Status_Error {
+		if conn.Client != client {
+			// a newer client has replaced this one (reconnect), nothing to clean up
+			return
+		}
+		// Close() sets the status before closing the client, so a connected (or reconnecting) status here means
+		// the connection dropped out from under us
+		if conn.Status == Status_Connected || conn.Status == Status_Reconnecting {
+			conn.Status = Status_Reconnecting
+			if !conn.reconnectRunning {
+				conn.reconnectRunning = true
+				startReconnect = true
+			}
+		} else if conn.Status != Status_Error {
 			conn.Status = Status_Disconnected
 		}
 		conn.close_nolock()
 	})
+	if startReconnect {
+		go conn.reconnectLoop(time.Now().UnixMilli())
+	}
 }
 
 func (conn *SSHConn) SetWshError(err error) {
@@ -830,6 +873,23 @@ func GetConn(opts *remote.SSHOpts) *SSHConn {
 	return conn
 }
 
+// returns true if the connection is connected but is not running wsh (disabled, or it could not be installed).
+// if the connection is not up yet, returns true if conn:wshenabled is false, since it will not run wsh once it connects.
+// does not create the connection if it does not exist.
+func IsWshDisabled(connName string) bool {
+	connOpts, err := remote.ParseOpts(connName)
+	if err != nil {
+		return false
+	}
+	globalLock.Lock()
+	conn := clientControllerMap[*connOpts]
+	globalLock.Unlock()
+	if conn != nil && conn.GetStatus() == Status_Connected {
+		return !conn.WshEnabled.Load()
+	}
+	return !getWshEnabledSetting(connOpts.String())
+}
+
 // Convenience function for ensuring a connection is established
 func EnsureConnection(ctx context.Context, connName string) error {
 	if connName == "" {
@@ -847,7 +907,7 @@ func EnsureConnection(ctx context.Context, connName string) error {
 	switch connStatus.Status {
 	case Status_Connected:
 		return nil
-	case Status_Connecting:
+	case Status_Connecting, Status_Reconnecting:
 		return conn.WaitForConnect(ctx)
 	case Status_Init, Status_Disconnected:
 		return conn.Connect(ctx, &sconfig.ConnKeywords{})
@@ -966,8 +1026,8 @@ func GetConnectionsFromInternalConfig() []string {
 	var internalNames []string
 	config := sconfig.GetWatcher().GetFullConfig()
 	for internalName := range config.Connections {
-		if strings.HasPrefix(internalName, "wsl://") {
-			// don't add wsl conns to this list
+		if strings.HasPrefix(internalName, "wsl://") || strings.HasPrefix(internalName, "aws:") {
+			// don't add wsl or s3 conns to this list
 			continue
 		}
 		internalNames = append(internalNames, internalName)
@@ -0,0 +1,246 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package conncontroller
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/remote"
+	"github.com/commandlinedev/starterm/pkg/sconfig"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+const (
+	ForwardSource_Config = "config"
+	ForwardSource_AdHoc  = "adhoc"
+)
+
+const (
+	ForwardStatus_Active = "active"
+	ForwardStatus_Error  = "error"
+	ForwardStatus_Closed = "closed"
+)
+
+// forwards live and die with the ssh client they were opened on.
+// config forwards are re-opened on every connect, ad-hoc forwards are dropped on disconnect.
+type connForward struct {
+	ForwardId int
+	Type      string
+	Source    string
+	RawSpec   string
+	Spec      remote.ForwardSpec
+	Status    string
+	Error     string
+	listener  net.Listener
+}
+
+func (fwd *connForward) toStatus() wshrpc.ConnForwardStatus {
+	return wshrpc.ConnForwardStatus{
+		ForwardId:  fwd.ForwardId,
+		Type:       fwd.Type,
+		Source:     fwd.Source,
+		Spec:       fwd.RawSpec,
+		BindAddr:   fwd.Spec.BindAddr,
+		TargetAddr: fwd.Spec.TargetAddr,
+		Status:     fwd.Status,
+		Error:      fwd.Error,
+	}
+}
+
+func (conn *SSHConn) getForwardStatuses_nolock() []wshrpc.ConnForwardStatus {
+	var rtn []wshrpc.ConnForwardStatus
+	for _, fwd := range conn.Forwards {
+		rtn = append(rtn, fwd.toStatus())
+	}
+	return rtn
+}
+
+func (conn *SSHConn) registerForward_nolock(fwd *connForward) error {
+	if conn.Client == nil {
+		return fmt.Errorf("connection %q was closed", conn.GetName())
+	}
+	conn.NextForwardId++
+	fwd.ForwardId = conn.NextForwardId
+	conn.Forwards = append(conn.Forwards, fwd)
+	return nil
+}
+
+func (conn *SSHConn) releaseForward_nolock(fwd *connForward) {
+	for idx, pending := range conn.pendingForwards {
+		if pending == fwd {
+			conn.pendingForwards = append(conn.pendingForwards[:idx], conn.pendingForwards[idx+1:]...)
+			return
+		}
+	}
+}
+
+// the ssh client is closed before this is called, so closing remote listeners will not block
+func (conn *SSHConn) closeForwards_nolock() {
+	for _, fwd := range conn.Forwards {
+		if fwd.Status == ForwardStatus_Active {
+			fwd.Status = ForwardStatus_Closed
+			fwd.listener.Close()
+		}
+	}
+	conn.Forwards = nil
+}
+
+func sameForwardBind(fwd *connForward, spec remote.ForwardSpec) bool {
+	isRemote := spec.Type == remote.ForwardType_Remote
+	return (fwd.Spec.Type == remote.ForwardType_Remote) == isRemote && fwd.Spec.BindAddr == spec.BindAddr
+}
+
+// includes forwards whose listeners are still being opened
+func (conn *SSHConn) hasForwardBindAddr_nolock(spec remote.ForwardSpec) bool {
+	for _, fwd := range conn.Forwards {
+		if fwd.Status == ForwardStatus_Active && sameForwardBind(fwd, spec) {
+			return true
+		}
+	}
+	for _, fwd := range conn.pendingForwards {
+		if sameForwardBind(fwd, spec) {
+			return true
+		}
+	}
+	return false
+}
+
+// opens the listener, registers the forward and starts serving.  the bind addr stays reserved until the
+// forward is registered so two opens cannot both get past the in-use check.  on error the forward is
+// not registered, that is up to the caller
+func (conn *SSHConn) openForward(fwdType string, specStr string, source string) (*connForward, error) {
+	fwd := &connForward{Type: fwdType, Source: source, RawSpec: specStr}
+	spec, err := remote.ParseForwardSpec(fwdType, specStr)
+	if err != nil {
+		return fwd, err
+	}
+	fwd.Spec = spec
+	client := conn.GetClient()
+	if client == nil {
+		return fwd, fmt.Errorf("connection %q is not connected", conn.GetName())
+	}
+	inUse := WithLockRtn(conn, func() bool {
+		if conn.hasForwardBindAddr_nolock(spec) {
+			return true
+		}
+		conn.pendingForwards = append(conn.pendingForwards, fwd)
+		return false
+	})
+	if inUse {
+		return fwd, fmt.Errorf("%s is already forwarded on this connection", spec.BindAddr)
+	}
+	listener, err := remote.ListenForward(client, spec)
+	if err != nil {
+		conn.WithLock(func() {
+			conn.releaseForward_nolock(fwd)
+		})
+		return fwd, fmt.Errorf("cannot listen on %s: %w", spec.BindAddr, err)
+	}
+	err = WithLockRtn(conn, func() error {
+		conn.releaseForward_nolock(fwd)
+		fwd.listener = listener
+		fwd.Status = ForwardStatus_Active
+		return conn.registerForward_nolock(fwd)
+	})
+	if err != nil {
+		// the connection was closed while the forward was being opened
+		listener.Close()
+		fwd.Status = ForwardStatus_Closed
+		fwd.listener = nil
+		return fwd, err
+	}
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("conncontroller:serveForward", recover())
+		}()
+		serveErr := remote.ServeForward(client, spec, listener)
+		changed := WithLockRtn(conn, func() bool {
+			// closed forwards were shut down on purpose
+			if fwd.Status != ForwardStatus_Active {
+				return false
+			}
+			fwd.Status = ForwardStatus_Error
+			fwd.Error = serveErr.Error()
+			return true
+		})
+		if changed {
+			conn.FireConnChangeEvent()
+		}
+	}()
+	return fwd, nil
+}
+
+// config forwards that fail are still registered so that their error shows up in the conn status
+func (conn *SSHConn) startConfigForwards(ctx context.Context, keywords *sconfig.ConnKeywords) {
+	allSpecs := []struct {
+		fwdType string
+		specs   []string
+	}{
+		{remote.ForwardType_Local, keywords.SshLocalForward},
+		{remote.ForwardType_Remote, keywords.SshRemoteForward},
+		{remote.ForwardType_Dynamic, keywords.SshDynamicForward},
+	}
+	for _, typeSpecs := range allSpecs {
+		for _, specStr := range typeSpecs.specs {
+			fwd, err := conn.openForward(typeSpecs.fwdType, specStr, ForwardSource_Config)
+			if err == nil {
+				conn.Infof(ctx, "%s forward %s\n", typeSpecs.fwdType, fwd.Spec.String())
+				continue
+			}
+			conn.Infof(ctx, "ERROR %s forward %q: %v\n", typeSpecs.fwdType, specStr, err)
+			fwd.Status = ForwardStatus_Error
+			fwd.Error = err.Error()
+			regErr := WithLockRtn(conn, func() error {
+				return conn.registerForward_nolock(fwd)
+			})
+			if regErr != nil {
+				conn.Infof(ctx, "ERROR %s forward %q: %v\n", typeSpecs.fwdType, specStr, regErr)
+			}
+		}
+	}
+}
+
+func (conn *SSHConn) AddForward(fwdType string, specStr string) (wshrpc.ConnForwardStatus, error) {
+	if conn.GetStatus() != Status_Connected {
+		return wshrpc.ConnForwardStatus{}, fmt.Errorf("cannot add forward to %q when status is %q", conn.GetName(), conn.GetStatus())
+	}
+	fwd, err := conn.openForward(fwdType, specStr, ForwardSource_AdHoc)
+	if err != nil {
+		return wshrpc.ConnForwardStatus{}, err
+	}
+	rtn := WithLockRtn(conn, fwd.toStatus)
+	conn.FireConnChangeEvent()
+	return rtn, nil
+}
+
+func (conn *SSHConn) RemoveForward(forwardId int) error {
+	var found bool
+	var listener net.Listener
+	conn.WithLock(func() {
+		for idx, fwd := range conn.Forwards {
+			if fwd.ForwardId != forwardId {
+				continue
+			}
+			found = true
+			if fwd.Status == ForwardStatus_Active {
+				fwd.Status = ForwardStatus_Closed
+				listener = fwd.listener
+			}
+			conn.Forwards = append(conn.Forwards[:idx], conn.Forwards[idx+1:]...)
+			return
+		}
+	})
+	if !found {
+		return fmt.Errorf("forward %d not found on connection %q", forwardId, conn.GetName())
+	}
+	// closing a remote listener is a round trip to the server, so don't hold the lock
+	if listener != nil {
+		listener.Close()
+	}
+	conn.FireConnChangeEvent()
+	return nil
+}
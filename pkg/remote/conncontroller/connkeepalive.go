@@ -0,0 +1,223 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package conncontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/remote"
+	"github.com/commandlinedev/starterm/pkg/sconfig"
+	"golang.org/x/crypto/ssh"
+)
+
+// unlike openssh we probe by default, otherwise a dead network is only noticed when tcp gives up (which can take hours).
+// setting ssh:serveraliveinterval in connections.json (or ServerAliveInterval in ~/.ssh/config) to 0 disables the probes
+const (
+	DefaultServerAliveInterval = 30 // seconds
+	DefaultServerAliveCountMax = 3
+)
+
+const (
+	ReconnectInitialBackoff = 1 * time.Second
+	ReconnectMaxBackoff     = 60 * time.Second
+	ReconnectMaxAttempts    = 10
+	ReconnectTimeout        = 60 * time.Second
+)
+
+// called (in a new goroutine) after a dropped connection has been re-established.
+// droppedTs is when the connection was lost, so the handler can find the blocks that went down with it.
+// set in main-server (blockcontroller imports conncontroller, so it cannot be called directly)
+var ReconnectedHandler func(connName string, droppedTs int64)
+
+var errReconnectAborted = errors.New("connection closed while reconnecting")
+
+// returns the keepalive interval in seconds (0 if disabled) and the number of probes that can go unanswered
+func getKeepAliveSettings(keywords *sconfig.ConnKeywords) (int, int) {
+	interval := DefaultServerAliveInterval
+	if keywords.SshServerAliveInterval != nil {
+		interval = max(*keywords.SshServerAliveInterval, 0)
+	}
+	countMax := DefaultServerAliveCountMax
+	if keywords.SshServerAliveCountMax != nil && *keywords.SshServerAliveCountMax > 0 {
+		countMax = *keywords.SshServerAliveCountMax
+	}
+	return interval, countMax
+}
+
+func nextReconnectBackoff(backoff time.Duration) time.Duration {
+	return min(backoff*2, ReconnectMaxBackoff)
+}
+
+func (conn *SSHConn) startKeepAlive(ctx context.Context, client *ssh.Client, keywords *sconfig.ConnKeywords) {
+	interval, countMax := getKeepAliveSettings(keywords)
+	if interval == 0 {
+		conn.Infof(ctx, "keepalive disabled\n")
+		return
+	}
+	conn.Infof(ctx, "keepalive interval:%ds countmax:%d\n", interval, countMax)
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("conncontroller:runKeepAlive", recover())
+		}()
+		conn.runKeepAlive(client, time.Duration(interval)*time.Second, countMax)
+	}()
+}
+
+// sends a keepalive request every interval.  after countMax unanswered probes in a row the connection is
+// considered dead and the client is closed (which kicks off the reconnect in waitForDisconnect)
+func (conn *SSHConn) runKeepAlive(client *ssh.Client, interval time.Duration, countMax int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	missed := 0
+	for range ticker.C {
+		if conn.GetClient() != client {
+			return
+		}
+		if sendKeepAlive(client, interval) {
+			missed = 0
+			continue
+		}
+		missed++
+		log.Printf("conn %s: keepalive unanswered (%d/%d)\n", conn.GetName(), missed, countMax)
+		if missed >= countMax {
+			conn.handleDeadConnection(client, fmt.Errorf("no response from server after %d keepalives", missed))
+			return
+		}
+	}
+}
+
+// any reply (including a failure) means the server is alive
+func sendKeepAlive(client *ssh.Client, timeout time.Duration) bool {
+	rtnCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("conncontroller:sendKeepAlive", recover())
+		}()
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		rtnCh <- err
+	}()
+	select {
+	case err := <-rtnCh:
+		return err == nil
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (conn *SSHConn) handleDeadConnection(client *ssh.Client, err error) {
+	isCurrent := WithLockRtn(conn, func() bool {
+		if conn.Client != client {
+			return false
+		}
+		conn.Error = err.Error()
+		return true
+	})
+	if !isCurrent {
+		return
+	}
+	log.Printf("conn %s: connection is dead: %v\n", conn.GetName(), err)
+	// Wait() returns once the client is closed, waitForDisconnect takes it from there
+	client.Close()
+}
+
+// runs until the connection is re-established, the user closes the connection, or we run out of attempts.
+// only one loop runs at a time (guarded by reconnectRunning)
+func (conn *SSHConn) reconnectLoop(droppedTs int64) {
+	defer func() {
+		panichandler.PanicHandler("conncontroller:reconnectLoop", recover())
+	}()
+	log.Printf("conn %s: connection dropped, reconnecting\n", conn.GetName())
+	conn.FireConnChangeEvent()
+	backoff := ReconnectInitialBackoff
+	for attempt := 1; ; attempt++ {
+		time.Sleep(backoff)
+		if conn.GetStatus() != Status_Reconnecting {
+			// closed (or reconnected) by the user while we were waiting
+			conn.WithLock(func() {
+				conn.reconnectRunning = false
+			})
+			return
+		}
+		err := conn.tryReconnect(droppedTs)
+		if err == nil || errors.Is(err, errReconnectAborted) {
+			return
+		}
+		log.Printf("conn %s: reconnect attempt %d failed: %v\n", conn.GetName(), attempt, err)
+		var cancelErr remote.UserInputCancelError
+		giveUp := attempt >= ReconnectMaxAttempts || errors.As(err, &cancelErr)
+		conn.WithLock(func() {
+			if conn.Status == Status_Reconnecting {
+				conn.Error = err.Error()
+				if giveUp {
+					conn.Status = Status_Disconnected
+				}
+			}
+			if giveUp {
+				conn.reconnectRunning = false
+			}
+		})
+		conn.FireConnChangeEvent()
+		if giveUp {
+			return
+		}
+		backoff = nextReconnectBackoff(backoff)
+	}
+}
+
+// reconnectRunning is cleared if the connection was re-established or the attempt was aborted
+func (conn *SSHConn) tryReconnect(droppedTs int64) error {
+	ctx, cancelFn := context.WithTimeout(context.Background(), ReconnectTimeout)
+	defer cancelFn()
+	connFlags := WithLockRtn(conn, func() *sconfig.ConnKeywords {
+		return conn.LastConnFlags
+	})
+	if connFlags == nil {
+		connFlags = &sconfig.ConnKeywords{}
+	}
+	err := conn.connectInternal(ctx, connFlags)
+	var closed bool
+	conn.WithLock(func() {
+		if conn.Status != Status_Reconnecting {
+			// Close() was called while we were connecting, don't leave the new client running
+			// (unless the user already started a fresh connect, which owns the client now)
+			closed = true
+			if conn.Status == Status_Disconnected || conn.Status == Status_Error {
+				conn.close_nolock()
+			}
+			conn.reconnectRunning = false
+			return
+		}
+		if err != nil {
+			conn.close_nolock()
+			return
+		}
+		conn.Status = Status_Connected
+		conn.Error = ""
+		conn.LastConnectTime = time.Now().UnixMilli()
+		conn.reconnectRunning = false
+	})
+	if closed {
+		conn.FireConnChangeEvent()
+		return errReconnectAborted
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("conn %s: reconnected\n", conn.GetName())
+	conn.FireConnChangeEvent()
+	if ReconnectedHandler != nil {
+		go func() {
+			defer func() {
+				panichandler.PanicHandler("conncontroller:ReconnectedHandler", recover())
+			}()
+			ReconnectedHandler(conn.GetName(), droppedTs)
+		}()
+	}
+	return nil
+}
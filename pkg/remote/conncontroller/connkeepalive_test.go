@@ -0,0 +1,49 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package conncontroller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/sconfig"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+)
+
+func TestGetKeepAliveSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		keywords sconfig.ConnKeywords
+		interval int
+		countMax int
+	}{
+		{"defaults", sconfig.ConnKeywords{}, DefaultServerAliveInterval, DefaultServerAliveCountMax},
+		{"interval set", sconfig.ConnKeywords{SshServerAliveInterval: utilfn.Ptr(15)}, 15, DefaultServerAliveCountMax},
+		{"explicit 0 disables", sconfig.ConnKeywords{SshServerAliveInterval: utilfn.Ptr(0)}, 0, DefaultServerAliveCountMax},
+		{"negative disables", sconfig.ConnKeywords{SshServerAliveInterval: utilfn.Ptr(-5)}, 0, DefaultServerAliveCountMax},
+		{"countmax set", sconfig.ConnKeywords{SshServerAliveCountMax: utilfn.Ptr(5)}, DefaultServerAliveInterval, 5},
+		{"countmax 0 uses the default", sconfig.ConnKeywords{SshServerAliveCountMax: utilfn.Ptr(0)}, DefaultServerAliveInterval, DefaultServerAliveCountMax},
+	}
+	for _, tc := range tests {
+		interval, countMax := getKeepAliveSettings(&tc.keywords)
+		if interval != tc.interval || countMax != tc.countMax {
+			t.Errorf("%s: got interval %d countmax %d, want %d %d", tc.name, interval, countMax, tc.interval, tc.countMax)
+		}
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	var got []time.Duration
+	backoff := ReconnectInitialBackoff
+	for attempt := 1; attempt <= ReconnectMaxAttempts; attempt++ {
+		got = append(got, backoff)
+		backoff = nextReconnectBackoff(backoff)
+	}
+	want := []time.Duration{1, 2, 4, 8, 16, 32, 60, 60, 60, 60}
+	for idx := range want {
+		if got[idx] != want[idx]*time.Second {
+			t.Fatalf("got backoffs %v, want %v (seconds)", got, want)
+		}
+	}
+}
@@ -17,6 +17,7 @@ const (
 	ConnectionTypeWsh  = "wsh"
 	ConnectionTypeS3   = "s3"
 	ConnectionTypeStar = "starfile"
+	ConnectionTypeSftp = "sftp"
 
 	ConnHostCurrent = "current"
 	ConnHostStarSrv = "starsrv"
@@ -145,11 +146,17 @@ func ParseURI(uri string) (*Connection, error) {
 		}
 	} else if scheme == ConnectionTypeWsh {
 		parseWshPath()
+	} else if scheme == ConnectionTypeSftp {
+		parseGenericPath()
+		if host == "" {
+			return nil, fmt.Errorf("sftp connection must specify a host")
+		}
 	} else {
 		parseGenericPath()
 	}
 
-	if scheme == ConnectionTypeWsh {
+	// sftp paths are like wsh paths on an ssh host
+	if scheme == ConnectionTypeWsh || scheme == ConnectionTypeSftp {
 		if host == "" {
 			host = wshrpc.LocalConnName
 		}
@@ -481,3 +481,33 @@ func TestParseURI_S3BucketOnly(t *testing.T) {
 	t.Log("Testing with trailing slash")
 	testUri("profile:s3://bucket/", "/", "bucket/")
 }
+
+func TestParseURI_Sftp(t *testing.T) {
+	t.Parallel()
+
+	testUri := func(cstr string, pathExpected string) {
+		c, err := connparse.ParseURI(cstr)
+		if err != nil {
+			t.Fatalf("failed to parse URI: %v", err)
+		}
+		if c.Path != pathExpected {
+			t.Fatalf("expected path to be \"%q\", got \"%q\"", pathExpected, c.Path)
+		}
+		expected := "user@host:2222"
+		if c.Host != expected {
+			t.Fatalf("expected host to be \"%q\", got \"%q\"", expected, c.Host)
+		}
+		expected = "sftp"
+		if c.GetType() != expected {
+			t.Fatalf("expected conn type to be \"%q\", got \"%q\"", expected, c.GetType())
+		}
+	}
+
+	testUri("sftp://user@host:2222/path/to/file", "/path/to/file")
+	testUri("sftp://user@host:2222/~/file", "~/file")
+	testUri("sftp://user@host:2222/", "/")
+
+	if _, err := connparse.ParseURI("sftp:///path/to/file"); err == nil {
+		t.Fatalf("expected an error for an sftp URI without a host")
+	}
+}
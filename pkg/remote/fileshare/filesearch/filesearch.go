@@ -0,0 +1,214 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package filesearch searches file contents, on the connserver for wsh connections and on the client for the other fileshares.
+package filesearch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/filesync"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+const (
+	DefaultMaxFileSize = 10 * 1024 * 1024
+	DefaultMaxResults  = 10000
+	// number of files searched at the same time
+	SearchWorkers = 8
+	// the text of a match is truncated to this many bytes
+	MaxMatchTextLen = 512
+	// the rest of a file is skipped after a longer line (minified files)
+	maxScanLineLen = 1024 * 1024
+	// like git, a file with a NUL byte in its first 8000 bytes is binary
+	binarySniffLen = 8000
+)
+
+var ErrBinaryFile = errors.New("binary file")
+
+// the cause of the search being stopped once MaxResults is reached
+var errSearchDone = errors.New("search done")
+
+// MakeMatcher compiles the pattern of opts
+func MakeMatcher(opts *wshrpc.FileSearchOpts) (*regexp.Regexp, error) {
+	pattern := opts.Pattern
+	if opts.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search pattern %q: %w", opts.Pattern, err)
+	}
+	return re, nil
+}
+
+// MakeFilter returns the filter for the include and exclude patterns of opts, .git directories are skipped unless .gitignore files are ignored
+func MakeFilter(opts *wshrpc.FileSearchOpts) (*filesync.Filter, error) {
+	excludes := opts.Exclude
+	if !opts.NoGitIgnore {
+		excludes = append([]string{".git/"}, excludes...)
+	}
+	return filesync.MakeFilter(opts.Include, excludes)
+}
+
+func matchText(line []byte) string {
+	if len(line) > MaxMatchTextLen {
+		// the cut can split a multi-byte character
+		return strings.ToValidUTF8(string(line[:MaxMatchTextLen]), "")
+	}
+	return string(line)
+}
+
+// SearchReader returns the first maxMatches lines of r that match re, ErrBinaryFile if r looks binary
+func SearchReader(re *regexp.Regexp, relPath string, r io.Reader, maxMatches int) ([]wshrpc.FileSearchMatch, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, _ := br.Peek(binarySniffLen)
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, ErrBinaryFile
+	}
+	var matches []wshrpc.FileSearchMatch
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxScanLineLen)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSuffix(scanner.Bytes(), []byte{'\r'})
+		loc := re.FindIndex(line)
+		if loc == nil {
+			continue
+		}
+		matches = append(matches, wshrpc.FileSearchMatch{Path: relPath, Line: lineNum, Column: loc[0] + 1, Text: matchText(line)})
+		if len(matches) >= maxMatches {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, bufio.ErrTooLong) {
+		return matches, err
+	}
+	return matches, nil
+}
+
+// WalkFn calls addFile for every file to search, it should stop (returning any error) once addFile returns false
+type WalkFn func(ctx context.Context, addFile func(relPath string, size int64) bool) error
+
+type OpenFn func(ctx context.Context, relPath string) (io.ReadCloser, error)
+
+// Run searches the files from walkFn with SearchWorkers workers.  every file with matches is sent as one result, then a result with Done set and the totals.
+// sendFn is never called concurrently and returns false if the results are no longer wanted.
+// files that cannot be read are skipped (and counted), only errors of walkFn stop the search.
+func Run(ctx context.Context, opts *wshrpc.FileSearchOpts, walkFn WalkFn, openFn OpenFn, sendFn func(wshrpc.FileSearchResult) bool) error {
+	re, err := MakeMatcher(opts)
+	if err != nil {
+		return err
+	}
+	maxFileSize := opts.MaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxFileSize
+	}
+	maxResults := opts.MaxResults
+	if maxResults <= 0 {
+		maxResults = DefaultMaxResults
+	}
+	searchCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var lock sync.Mutex
+	remaining := maxResults
+	result := wshrpc.FileSearchResult{Done: true}
+	searchFile := func(relPath string) {
+		reader, err := openFn(searchCtx, relPath)
+		var matches []wshrpc.FileSearchMatch
+		if err == nil {
+			// one more than fits, so a search that ends on an exact fit is not reported as truncated
+			matches, err = SearchReader(re, relPath, reader, maxResults+1)
+			reader.Close()
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		if searchCtx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, ErrBinaryFile) {
+				log.Printf("filesearch: cannot search %q: %v\n", relPath, err)
+			}
+			result.FilesSkipped++
+			return
+		}
+		result.FilesSearched++
+		if len(matches) == 0 {
+			return
+		}
+		if len(matches) > remaining {
+			matches = matches[:remaining]
+			result.Truncated = true
+		}
+		remaining -= len(matches)
+		if len(matches) > 0 && !sendFn(wshrpc.FileSearchResult{Matches: matches}) {
+			cancel(context.Canceled)
+			return
+		}
+		if result.Truncated {
+			cancel(errSearchDone)
+		}
+	}
+
+	files := make(chan string, SearchWorkers*4)
+	wg := sync.WaitGroup{}
+	for i := 0; i < SearchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				panichandler.PanicHandler("filesearch:Run", recover())
+			}()
+			for relPath := range files {
+				if searchCtx.Err() == nil {
+					searchFile(relPath)
+				}
+			}
+		}()
+	}
+	walkErr := walkFn(searchCtx, func(relPath string, size int64) bool {
+		if size > maxFileSize {
+			lock.Lock()
+			result.FilesSkipped++
+			lock.Unlock()
+			return searchCtx.Err() == nil
+		}
+		select {
+		case files <- relPath:
+			return true
+		case <-searchCtx.Done():
+			return false
+		}
+	})
+	close(files)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	cause := context.Cause(searchCtx)
+	if cause != nil && cause != errSearchDone {
+		// the results are no longer wanted
+		return cause
+	}
+	if walkErr != nil && cause != errSearchDone {
+		return walkErr
+	}
+	sendFn(result)
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package filesearch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+func TestSearchReader(t *testing.T) {
+	re, err := MakeMatcher(&wshrpc.FileSearchOpts{Pattern: "a.c", Literal: true, IgnoreCase: true})
+	if err != nil {
+		t.Fatalf("MakeMatcher failed: %v", err)
+	}
+	matches, err := SearchReader(re, "f.txt", strings.NewReader("abc\r\nx A.C y\r\nnone\n  a.c"), 10)
+	if err != nil {
+		t.Fatalf("SearchReader failed: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches, got %v", matches)
+	}
+	if matches[0].Line != 2 || matches[0].Column != 3 || matches[0].Text != "x A.C y" {
+		t.Errorf("unexpected first match: %+v", matches[0])
+	}
+	if matches[1].Line != 4 || matches[1].Column != 3 || matches[1].Path != "f.txt" {
+		t.Errorf("unexpected second match: %+v", matches[1])
+	}
+
+	if _, err := SearchReader(re, "bin", strings.NewReader("a.c\x00"), 10); !errors.Is(err, ErrBinaryFile) {
+		t.Errorf("expected ErrBinaryFile, got %v", err)
+	}
+	long := strings.Repeat("x", 2*MaxMatchTextLen) + "a.c"
+	matches, err = SearchReader(re, "long", strings.NewReader(long), 10)
+	if err != nil || len(matches) != 1 || len(matches[0].Text) != MaxMatchTextLen || matches[0].Column != 2*MaxMatchTextLen+1 {
+		t.Errorf("unexpected match of a long line: %v, %v", matches, err)
+	}
+	if _, err := MakeMatcher(&wshrpc.FileSearchOpts{Pattern: "("}); err == nil {
+		t.Errorf("expected an invalid pattern to fail")
+	}
+}
+
+func runSearch(t *testing.T, files map[string]string, opts *wshrpc.FileSearchOpts) []wshrpc.FileSearchResult {
+	var paths []string
+	for relPath := range files {
+		paths = append(paths, relPath)
+	}
+	sort.Strings(paths)
+	var results []wshrpc.FileSearchResult
+	err := Run(context.Background(), opts, func(ctx context.Context, addFile func(string, int64) bool) error {
+		for _, relPath := range paths {
+			if !addFile(relPath, int64(len(files[relPath]))) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	}, func(ctx context.Context, relPath string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(files[relPath])), nil
+	}, func(result wshrpc.FileSearchResult) bool {
+		results = append(results, result)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(results) == 0 || !results[len(results)-1].Done {
+		t.Fatalf("expected a final result with Done set, got %v", results)
+	}
+	return results
+}
+
+func TestRun(t *testing.T) {
+	files := map[string]string{
+		"a.go":     "package a\nfunc Foo() {}\n",
+		"b/b.go":   "// Foo\nvar foo = 1\nFoo()\n",
+		"c.txt":    "nothing here\n",
+		"big.txt":  strings.Repeat("Foo\n", 100),
+		"data.bin": "Foo\x00",
+	}
+	results := runSearch(t, files, &wshrpc.FileSearchOpts{Pattern: `\bFoo\b`, MaxFileSize: 200})
+	done := results[len(results)-1]
+	if done.FilesSearched != 3 || done.FilesSkipped != 2 || done.Truncated {
+		t.Errorf("unexpected totals: %+v", done)
+	}
+	count := 0
+	for _, result := range results[:len(results)-1] {
+		for _, match := range result.Matches {
+			count++
+			if match.Path != "a.go" && match.Path != "b/b.go" {
+				t.Errorf("unexpected match: %+v", match)
+			}
+		}
+	}
+	if count != 3 {
+		t.Errorf("expected 3 matches, got %d", count)
+	}
+
+	results = runSearch(t, files, &wshrpc.FileSearchOpts{Pattern: "Foo", MaxResults: 10})
+	done = results[len(results)-1]
+	count = 0
+	for _, result := range results {
+		count += len(result.Matches)
+	}
+	if count != 10 || !done.Truncated {
+		t.Errorf("expected 10 matches and a truncated search, got %d, %+v", count, done)
+	}
+
+	// a search that ends on an exact fit is complete
+	tests := []struct {
+		name       string
+		files      map[string]string
+		maxResults int
+		count      int
+		truncated  bool
+	}{
+		{"exact fit over files", files, 3, 3, false},
+		{"one match too many", files, 2, 2, true},
+		{"exact fit in one file", map[string]string{"one.txt": "Foo\nFoo\n"}, 2, 2, false},
+	}
+	for _, tc := range tests {
+		results = runSearch(t, tc.files, &wshrpc.FileSearchOpts{Pattern: `\bFoo\b`, MaxFileSize: 200, MaxResults: tc.maxResults})
+		done = results[len(results)-1]
+		count = 0
+		for _, result := range results {
+			count += len(result.Matches)
+		}
+		if count != tc.count || done.Truncated != tc.truncated {
+			t.Errorf("%s: got %d matches, %+v, want %d matches (truncated %v)", tc.name, count, done, tc.count, tc.truncated)
+		}
+	}
+}
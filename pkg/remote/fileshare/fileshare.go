@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/commandlinedev/starterm/pkg/panichandler"
 	"github.com/commandlinedev/starterm/pkg/remote/awsconn"
 	"github.com/commandlinedev/starterm/pkg/remote/connparse"
 	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fstype"
 	"github.com/commandlinedev/starterm/pkg/remote/fileshare/s3fs"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/sftpfs"
 	"github.com/commandlinedev/starterm/pkg/remote/fileshare/starfs"
 	"github.com/commandlinedev/starterm/pkg/remote/fileshare/wshfs"
 	"github.com/commandlinedev/starterm/pkg/util/iochan/iochantypes"
@@ -35,10 +38,16 @@ func CreateFileShareClient(ctx context.Context, connection string) (fstype.FileS
 			log.Printf("error getting aws config: %v", err)
 			return nil, nil
 		}
-		return s3fs.NewS3Client(config), conn
+		return s3fs.NewS3Client(config, awsconn.GetS3Options(connection)...), conn
 	} else if conntype == connparse.ConnectionTypeStar {
 		return starfs.NewStarClient(), conn
+	} else if conntype == connparse.ConnectionTypeSftp {
+		return sftpfs.NewSftpClient(), conn
 	} else if conntype == connparse.ConnectionTypeWsh {
+		if sftpfs.ShouldUseSftp(conn.Host) {
+			// wsh is not running on the connection, fall back to the ssh server's sftp subsystem
+			return sftpfs.NewSftpClient(), conn
+		}
 		return wshfs.NewWshClient(), conn
 	} else {
 		log.Printf("unsupported connection type: %s", conntype)
@@ -70,9 +79,26 @@ func ReadTarStream(ctx context.Context, data wshrpc.CommandRemoteStreamTarData)
 	if conn == nil || client == nil {
 		return wshutil.SendErrCh[iochantypes.Packet](fmt.Errorf(ErrorParsingConnection, data.Path))
 	}
+	if len(data.Resume) > 0 && client.GetConnectionType() == connparse.ConnectionTypeWsh {
+		// only wsh sources can skip what is already at the destination
+		return wshfs.WshClient{}.ReadTarStreamWithResume(ctx, conn, data.Opts, data.Resume)
+	}
 	return client.ReadTarStream(ctx, conn, data.Opts)
 }
 
+// Watch streams the changes below the path until ctx is done, only wsh connections can be watched
+func Watch(ctx context.Context, data wshrpc.CommandFileWatchData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileWatchEvent] {
+	log.Printf("Watch: %v, opts: %v", data.Uri, data.Opts)
+	client, conn := CreateFileShareClient(ctx, data.Uri)
+	if conn == nil || client == nil {
+		return wshutil.SendErrCh[wshrpc.FileWatchEvent](fmt.Errorf(ErrorParsingConnection, data.Uri))
+	}
+	if client.GetConnectionType() != connparse.ConnectionTypeWsh {
+		return wshutil.SendErrCh[wshrpc.FileWatchEvent](fmt.Errorf("cannot watch %q: watching is only supported on connections running wsh", data.Uri))
+	}
+	return wshfs.WshClient{}.Watch(ctx, conn, data.Opts)
+}
+
 func ListEntries(ctx context.Context, path string, opts *wshrpc.FileListOpts) ([]*wshrpc.FileInfo, error) {
 	log.Printf("ListEntries: %v", path)
 	client, conn := CreateFileShareClient(ctx, path)
@@ -100,12 +126,51 @@ func Stat(ctx context.Context, path string) (*wshrpc.FileInfo, error) {
 	return client.Stat(ctx, conn)
 }
 
+// like Stat, opts.ObjectTags also gets the tags of s3 objects
+func StatWithOpts(ctx context.Context, path string, opts *wshrpc.FileOpts) (*wshrpc.FileInfo, error) {
+	if opts == nil || !opts.ObjectTags {
+		return Stat(ctx, path)
+	}
+	log.Printf("Stat: %v", path)
+	client, conn := CreateFileShareClient(ctx, path)
+	if conn == nil || client == nil {
+		return nil, fmt.Errorf(ErrorParsingConnection, path)
+	}
+	finfo, err := client.Stat(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if tagger, ok := client.(fstype.ObjectTagger); ok {
+		err = tagger.AddObjectTags(ctx, conn, finfo)
+		if err != nil {
+			// the file info is still useful without the tags
+			log.Printf("Stat: %v", err)
+		}
+	}
+	return finfo, nil
+}
+
+// etag checks and staged writes need a fileshare that can stage, the others would ignore them
+func checkWriteOpts(client fstype.FileShareClient, data wshrpc.FileData) error {
+	opts := data.Info.Opts
+	if opts == nil || (opts.IfMatch == "" && opts.StageId == "") {
+		return nil
+	}
+	if !client.GetCapability().CanStage {
+		return fmt.Errorf("etag checks and staged writes are not supported for %q", data.Info.Path)
+	}
+	return nil
+}
+
 func PutFile(ctx context.Context, data wshrpc.FileData) error {
 	log.Printf("PutFile: %v", data.Info.Path)
 	client, conn := CreateFileShareClient(ctx, data.Info.Path)
 	if conn == nil || client == nil {
 		return fmt.Errorf(ErrorParsingConnection, data.Info.Path)
 	}
+	if err := checkWriteOpts(client, data); err != nil {
+		return err
+	}
 	return client.PutFile(ctx, conn, data)
 }
 
@@ -132,6 +197,11 @@ func Move(ctx context.Context, data wshrpc.CommandFileCopyData) error {
 	if destConn == nil || destClient == nil {
 		return fmt.Errorf("error creating fileshare client, could not parse destination connection %s", data.DestUri)
 	}
+	if shouldTrashOverwrite(opts) {
+		if err := trashCopyDest(ctx, srcClient, srcConn, destClient, destConn); err != nil {
+			return err
+		}
+	}
 	if srcConn.Host != destConn.Host {
 		isDir, err := destClient.CopyRemote(ctx, srcConn, destConn, srcClient, opts)
 		if err != nil {
@@ -158,6 +228,11 @@ func Copy(ctx context.Context, data wshrpc.CommandFileCopyData) error {
 	if destConn == nil || destClient == nil {
 		return fmt.Errorf("error creating fileshare client, could not parse destination connection %s", data.DestUri)
 	}
+	if shouldTrashOverwrite(opts) {
+		if err := trashCopyDest(ctx, srcClient, srcConn, destClient, destConn); err != nil {
+			return err
+		}
+	}
 	if srcConn.Host != destConn.Host {
 		_, err := destClient.CopyRemote(ctx, srcConn, destConn, srcClient, opts)
 		return err
@@ -167,12 +242,63 @@ func Copy(ctx context.Context, data wshrpc.CommandFileCopyData) error {
 	}
 }
 
+// CopyStream is Copy with progress updates (the last update has Done set).
+// only copies to wsh connections report progress while copying, other destinations only send the final update.
+func CopyStream(ctx context.Context, data wshrpc.CommandFileCopyData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileCopyProgress] {
+	opts := data.Opts
+	if opts == nil {
+		opts = &wshrpc.FileCopyOpts{}
+	}
+	opts.Recursive = true
+	data.Opts = opts
+	srcClient, srcConn := CreateFileShareClient(ctx, data.SrcUri)
+	if srcConn == nil || srcClient == nil {
+		return wshutil.SendErrCh[wshrpc.FileCopyProgress](fmt.Errorf("error creating fileshare client, could not parse source connection %s", data.SrcUri))
+	}
+	destClient, destConn := CreateFileShareClient(ctx, data.DestUri)
+	if destConn == nil || destClient == nil {
+		return wshutil.SendErrCh[wshrpc.FileCopyProgress](fmt.Errorf("error creating fileshare client, could not parse destination connection %s", data.DestUri))
+	}
+	if destClient.GetConnectionType() == connparse.ConnectionTypeWsh {
+		log.Printf("CopyStream: srcuri: %v, desturi: %v, opts: %v", data.SrcUri, data.DestUri, opts)
+		if shouldTrashOverwrite(opts) {
+			if err := trashCopyDest(ctx, srcClient, srcConn, destClient, destConn); err != nil {
+				return wshutil.SendErrCh[wshrpc.FileCopyProgress](err)
+			}
+		}
+		return wshfs.WshClient{}.CopyStream(ctx, srcConn, destConn, opts)
+	}
+	ch := make(chan wshrpc.RespOrErrorUnion[wshrpc.FileCopyProgress], 1)
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("fileshare:CopyStream", recover())
+		}()
+		defer close(ch)
+		copyStart := time.Now()
+		if err := Copy(ctx, data); err != nil {
+			ch <- wshutil.RespErr[wshrpc.FileCopyProgress](err)
+			return
+		}
+		ch <- wshrpc.RespOrErrorUnion[wshrpc.FileCopyProgress]{Response: wshrpc.FileCopyProgress{ElapsedMs: time.Since(copyStart).Milliseconds(), Done: true}}
+	}()
+	return ch
+}
+
 func Delete(ctx context.Context, data wshrpc.CommandDeleteFileData) error {
 	log.Printf("Delete: %v", data)
 	client, conn := CreateFileShareClient(ctx, data.Path)
 	if conn == nil || client == nil {
 		return fmt.Errorf(ErrorParsingConnection, data.Path)
 	}
+	if !data.Permanent && trashEnabled() {
+		trashed, err := moveToTrash(ctx, client, conn, wshrpc.TrashReason_Delete, data.Recursive)
+		if err != nil {
+			return err
+		}
+		if trashed {
+			return nil
+		}
+	}
 	return client.Delete(ctx, conn, data.Recursive)
 }
 
@@ -191,6 +317,9 @@ func Append(ctx context.Context, data wshrpc.FileData) error {
 	if conn == nil || client == nil {
 		return fmt.Errorf(ErrorParsingConnection, data.Info.Path)
 	}
+	if err := checkWriteOpts(client, data); err != nil {
+		return err
+	}
 	return client.AppendFile(ctx, conn, data)
 }
 
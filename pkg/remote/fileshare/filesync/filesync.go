@@ -0,0 +1,153 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package filesync computes the changes needed to make one fileshare tree match another.
+package filesync
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/commandlinedev/starterm/pkg/remote/connparse"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fspath"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fstype"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+const MaxSyncFiles = 100000
+
+const (
+	SyncOp_Copy   = "copy"   // the file does not exist at the destination
+	SyncOp_Update = "update" // the file differs at the destination
+	SyncOp_Delete = "delete" // the file only exists at the destination
+)
+
+// ChildConn returns the connection for relPath below conn
+func ChildConn(conn *connparse.Connection, relPath string) *connparse.Connection {
+	return &connparse.Connection{Scheme: conn.Scheme, Host: conn.Host, Path: fspath.Join(conn.Path, relPath)}
+}
+
+// the name of a directory entry (starfile entries have the full name, other clients the base name)
+func entryName(entry *wshrpc.FileInfo) string {
+	if entry.Path != "" {
+		return fspath.Base(entry.Path)
+	}
+	return fspath.Base(entry.Name)
+}
+
+// ListTree lists the files below conn that pass the filter, keyed by path relative to conn.
+// directories excluded by the filter are not walked.  if readGitIgnore is set, .gitignore files are added to the filter as they are found
+// (GitIgnoreFile is added before the rest of its directory is filtered).
+func ListTree(ctx context.Context, client fstype.FileShareClient, conn *connparse.Connection, filter *Filter, readGitIgnore bool) (map[string]*wshrpc.FileInfo, error) {
+	files := make(map[string]*wshrpc.FileInfo)
+	var walk func(relDir string) error
+	walk = func(relDir string) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		dirConn := ChildConn(conn, relDir)
+		entries, err := client.ListEntries(ctx, dirConn, nil)
+		if err != nil {
+			return fmt.Errorf("cannot list %q: %w", dirConn.GetFullURI(), err)
+		}
+		if readGitIgnore {
+			for _, entry := range entries {
+				if entry.IsDir || entryName(entry) != GitIgnoreFile {
+					continue
+				}
+				fileData, err := client.Read(ctx, ChildConn(conn, fspath.Join(relDir, GitIgnoreFile)), wshrpc.FileData{})
+				if err != nil {
+					log.Printf("filesync: cannot read %s in %q: %v\n", GitIgnoreFile, relDir, err)
+					break
+				}
+				content, err := base64.StdEncoding.DecodeString(fileData.Data64)
+				if err != nil {
+					log.Printf("filesync: cannot decode %s in %q: %v\n", GitIgnoreFile, relDir, err)
+					break
+				}
+				filter.AddGitIgnore(relDir, string(content))
+				break
+			}
+		}
+		for _, entry := range entries {
+			name := entryName(entry)
+			if name == "" || name == "." || name == ".." {
+				continue
+			}
+			relPath := fspath.Join(relDir, name)
+			if filter.Excluded(relPath, entry.IsDir) {
+				continue
+			}
+			if entry.IsDir {
+				if err := walk(relPath); err != nil {
+					return err
+				}
+				continue
+			}
+			if !filter.Included(relPath) {
+				continue
+			}
+			files[relPath] = entry
+			if len(files) > MaxSyncFiles {
+				return fmt.Errorf("too many files to sync (max %d)", MaxSyncFiles)
+			}
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Diff returns the actions (sorted by path, deletes last) that make dest match src, and the number of files that are unchanged.
+// without checksum a file is updated if its size differs or the source is newer than the destination (the destination mod time is usually the time of the last sync).
+// with checksum a file is updated if its size differs or sameContent returns false (mod times are ignored).
+func Diff(src map[string]*wshrpc.FileInfo, dest map[string]*wshrpc.FileInfo, checksum bool, deleteExtra bool, sameContent func(relPath string) (bool, error)) ([]wshrpc.FileSyncAction, int, error) {
+	var actions []wshrpc.FileSyncAction
+	unchanged := 0
+	srcPaths := make([]string, 0, len(src))
+	for relPath := range src {
+		srcPaths = append(srcPaths, relPath)
+	}
+	sort.Strings(srcPaths)
+	for _, relPath := range srcPaths {
+		srcInfo := src[relPath]
+		destInfo := dest[relPath]
+		if destInfo == nil {
+			actions = append(actions, wshrpc.FileSyncAction{Op: SyncOp_Copy, Path: relPath, Size: srcInfo.Size})
+			continue
+		}
+		changed := srcInfo.Size != destInfo.Size
+		if !changed && checksum {
+			same, err := sameContent(relPath)
+			if err != nil {
+				return nil, 0, fmt.Errorf("cannot compare %q: %w", relPath, err)
+			}
+			changed = !same
+		} else if !changed {
+			changed = srcInfo.ModTime > destInfo.ModTime
+		}
+		if changed {
+			actions = append(actions, wshrpc.FileSyncAction{Op: SyncOp_Update, Path: relPath, Size: srcInfo.Size})
+		} else {
+			unchanged++
+		}
+	}
+	if deleteExtra {
+		var destPaths []string
+		for relPath := range dest {
+			if src[relPath] == nil {
+				destPaths = append(destPaths, relPath)
+			}
+		}
+		sort.Strings(destPaths)
+		for _, relPath := range destPaths {
+			actions = append(actions, wshrpc.FileSyncAction{Op: SyncOp_Delete, Path: relPath, Size: dest[relPath].Size})
+		}
+	}
+	return actions, unchanged, nil
+}
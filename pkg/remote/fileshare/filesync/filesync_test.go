@@ -0,0 +1,167 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package filesync_test
+
+import (
+	"testing"
+
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/filesync"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	filter, err := filesync.MakeFilter(nil, []string{"*.log", "build/", "/top.txt", "docs/**/draft-?.md"})
+	if err != nil {
+		t.Fatalf("MakeFilter failed: %v", err)
+	}
+	filter.AddGitIgnore("", "# comment\n*.tmp\n!keep.tmp\n")
+	filter.AddGitIgnore("sub", "local/\n")
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		excluded bool
+	}{
+		{"app.log", false, true},
+		{"a/b/app.log", false, true},
+		{"build", true, true},
+		{"a/build", true, true},
+		{"build", false, false},
+		{"top.txt", false, true},
+		{"a/top.txt", false, false},
+		{"docs/draft-1.md", false, true},
+		{"docs/x/y/draft-2.md", false, true},
+		{"docs/draft-10.md", false, false},
+		{"x.tmp", false, true},
+		{"keep.tmp", false, false},
+		{"sub/local", true, true},
+		{"local", true, false},
+		{"main.go", false, false},
+	}
+	for _, tc := range tests {
+		if got := filter.Excluded(tc.path, tc.isDir); got != tc.excluded {
+			t.Errorf("Excluded(%q, %v) = %v, expected %v", tc.path, tc.isDir, got, tc.excluded)
+		}
+	}
+}
+
+func TestFilterInclude(t *testing.T) {
+	t.Parallel()
+
+	filter, err := filesync.MakeFilter([]string{"*.go", "src/[a-c]*.txt"}, nil)
+	if err != nil {
+		t.Fatalf("MakeFilter failed: %v", err)
+	}
+	for path, included := range map[string]bool{
+		"main.go":      true,
+		"pkg/x/y.go":   true,
+		"src/abc.txt":  true,
+		"src/def.txt":  false,
+		"a/src/a.txt":  false,
+		"readme.md":    false,
+		"main.go.orig": false,
+	} {
+		if got := filter.Included(path); got != included {
+			t.Errorf("Included(%q) = %v, expected %v", path, got, included)
+		}
+	}
+
+	// directory patterns include everything below the directory
+	tests := []struct {
+		pattern  string
+		path     string
+		included bool
+	}{
+		{"conf/", "conf/app.yaml", true},
+		{"conf/", "conf/nested/app.yaml", true},
+		{"conf/", "a/conf/app.yaml", true},
+		{"conf/", "conf", false},
+		{"conf/", "config/app.yaml", false},
+		{"src", "src/a.go", true},
+		{"src", "src/pkg/b.go", true},
+		{"src", "src", true},
+		{"src", "lib/a.go", false},
+		{"src", "srcs/a.go", false},
+		{"src/**", "src/a.go", true},
+		{"src/**", "src/pkg/b.go", true},
+		{"src/**", "a/src/b.go", false},
+		{"/src/pkg/", "src/pkg/b.go", true},
+		{"/src/pkg/", "x/src/pkg/b.go", false},
+	}
+	for _, tc := range tests {
+		dirFilter, err := filesync.MakeFilter([]string{tc.pattern}, nil)
+		if err != nil {
+			t.Fatalf("MakeFilter(%q) failed: %v", tc.pattern, err)
+		}
+		if got := dirFilter.Included(tc.path); got != tc.included {
+			t.Errorf("include %q: Included(%q) = %v, expected %v", tc.pattern, tc.path, got, tc.included)
+		}
+	}
+
+	if _, err := filesync.MakeFilter(nil, []string{"[abc"}); err == nil {
+		t.Errorf("expected an error for an unterminated character class")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	src := map[string]*wshrpc.FileInfo{
+		"new.txt":     {Size: 10, ModTime: 100},
+		"resized.txt": {Size: 20, ModTime: 100},
+		"newer.txt":   {Size: 30, ModTime: 200},
+		"same.txt":    {Size: 40, ModTime: 100},
+		"a/older.txt": {Size: 50, ModTime: 100},
+	}
+	dest := map[string]*wshrpc.FileInfo{
+		"resized.txt": {Size: 21, ModTime: 300},
+		"newer.txt":   {Size: 30, ModTime: 100},
+		"same.txt":    {Size: 40, ModTime: 100},
+		"a/older.txt": {Size: 50, ModTime: 300},
+		"extra.txt":   {Size: 60, ModTime: 100},
+	}
+
+	actions, unchanged, err := filesync.Diff(src, dest, false, true, nil)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	expected := []wshrpc.FileSyncAction{
+		{Op: filesync.SyncOp_Copy, Path: "new.txt", Size: 10},
+		{Op: filesync.SyncOp_Update, Path: "newer.txt", Size: 30},
+		{Op: filesync.SyncOp_Update, Path: "resized.txt", Size: 20},
+		{Op: filesync.SyncOp_Delete, Path: "extra.txt", Size: 60},
+	}
+	if len(actions) != len(expected) {
+		t.Fatalf("expected %d actions, got %v", len(expected), actions)
+	}
+	for i := range expected {
+		if actions[i] != expected[i] {
+			t.Errorf("action %d: expected %v, got %v", i, expected[i], actions[i])
+		}
+	}
+	if unchanged != 2 {
+		t.Errorf("expected 2 unchanged files, got %d", unchanged)
+	}
+
+	// with checksum only files of the same size are compared by content, mod times are ignored
+	compared := make(map[string]bool)
+	actions, unchanged, err = filesync.Diff(src, dest, true, false, func(relPath string) (bool, error) {
+		compared[relPath] = true
+		return relPath != "a/older.txt", nil
+	})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(compared) != 3 || compared["resized.txt"] || compared["new.txt"] {
+		t.Errorf("unexpected files compared: %v", compared)
+	}
+	if len(actions) != 3 || actions[0].Path != "a/older.txt" || actions[0].Op != filesync.SyncOp_Update {
+		t.Errorf("unexpected actions: %v", actions)
+	}
+	if unchanged != 2 {
+		t.Errorf("expected 2 unchanged files, got %d", unchanged)
+	}
+}
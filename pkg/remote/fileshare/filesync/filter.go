@@ -0,0 +1,193 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package filesync
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const GitIgnoreFile = ".gitignore"
+
+// a compiled gitignore-style pattern
+type rule struct {
+	base    string // directory the rule is relative to ("" for the root)
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Filter decides which paths (relative to the sync root, "/" separated) take part in a sync.
+// include and exclude patterns use .gitignore syntax.  a file is synced if it matches an include pattern (when there are any),
+// does not match an exclude pattern, and is not ignored by a .gitignore rule.
+type Filter struct {
+	includes []rule
+	excludes []rule
+	gitRules []rule
+}
+
+func MakeFilter(includes []string, excludes []string) (*Filter, error) {
+	f := &Filter{}
+	for _, pattern := range includes {
+		r, err := compileRule("", pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+		}
+		if r != nil {
+			f.includes = append(f.includes, *r)
+		}
+	}
+	for _, pattern := range excludes {
+		r, err := compileRule("", pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+		if r != nil {
+			f.excludes = append(f.excludes, *r)
+		}
+	}
+	return f, nil
+}
+
+// AddGitIgnore adds the rules of the .gitignore file in dir (invalid lines are skipped, like git does)
+func (f *Filter) AddGitIgnore(dir string, content string) {
+	for _, line := range strings.Split(content, "\n") {
+		r, err := compileRule(dir, strings.TrimRight(line, "\r"))
+		if err != nil || r == nil {
+			continue
+		}
+		f.gitRules = append(f.gitRules, *r)
+	}
+}
+
+// Excluded returns true if the path should not be synced.  for a directory this means nothing below it is synced either.
+func (f *Filter) Excluded(relPath string, isDir bool) bool {
+	for _, r := range f.excludes {
+		if r.match(relPath, isDir) {
+			return true
+		}
+	}
+	// the last matching .gitignore rule wins
+	excluded := false
+	for _, r := range f.gitRules {
+		if r.match(relPath, isDir) {
+			excluded = !r.negate
+		}
+	}
+	return excluded
+}
+
+// Included returns true if the file, or one of the directories it is in, matches an include pattern (or there are no include patterns)
+func (f *Filter) Included(relPath string) bool {
+	if len(f.includes) == 0 {
+		return true
+	}
+	for _, r := range f.includes {
+		if r.match(relPath, false) {
+			return true
+		}
+		for dir := relPath; strings.Contains(dir, "/"); {
+			dir = dir[:strings.LastIndex(dir, "/")]
+			if r.match(dir, true) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r rule) match(relPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(relPath, r.base+"/") {
+			return false
+		}
+		relPath = strings.TrimPrefix(relPath, r.base+"/")
+	}
+	return r.re.MatchString(relPath)
+}
+
+// returns nil for blank lines and comments
+func compileRule(base string, pattern string) (*rule, error) {
+	if strings.HasPrefix(pattern, "#") {
+		return nil, nil
+	}
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(pattern, " ") && !strings.HasSuffix(pattern, "\\ ") {
+		pattern = pattern[:len(pattern)-1]
+	}
+	if pattern == "" {
+		return nil, nil
+	}
+	r := &rule{base: base}
+	if strings.HasPrefix(pattern, "!") {
+		r.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, "\\!") || strings.HasPrefix(pattern, "\\#") {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	// a pattern with a slash (other than a trailing one) is relative to base, otherwise it matches at any depth
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return nil, nil
+	}
+	reStr, err := globToRegex(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if anchored {
+		reStr = "^" + reStr + "$"
+	} else {
+		reStr = "(^|/)" + reStr + "$"
+	}
+	r.re, err = regexp.Compile(reStr)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func globToRegex(pattern string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case ch == '*':
+			sb.WriteString("[^/]*")
+		case ch == '?':
+			sb.WriteString("[^/]")
+		case ch == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end == -1 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, "\\", "\\\\") + "]")
+			i += end + 1
+		case ch == '\\' && i+1 < len(pattern):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	return sb.String(), nil
+}
@@ -20,8 +20,16 @@ const (
 	RecursiveRequiredError             = "recursive flag must be set for directory operations"
 	MergeRequiredError                 = "directory already exists at %q, set overwrite flag to delete the existing contents or set merge flag to merge the contents"
 	OverwriteRequiredError             = "file already exists at %q, set overwrite flag to delete the existing file"
+	ConflictErrorPrefix                = "CONFLICT:"
+	ConflictError                      = ConflictErrorPrefix + " %q was changed since it was read (etag %q, expected %q)"
 )
 
+// implemented by fileshares with object tags (s3), fetching them costs another request so it is optional
+type ObjectTagger interface {
+	// AddObjectTags adds the tags of the object to the meta of finfo (from Stat)
+	AddObjectTags(ctx context.Context, conn *connparse.Connection, finfo *wshrpc.FileInfo) error
+}
+
 type FileShareClient interface {
 	// Stat returns the file info at the given parsed connection path
 	Stat(ctx context.Context, conn *connparse.Connection) (*wshrpc.FileInfo, error)
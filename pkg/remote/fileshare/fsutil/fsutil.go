@@ -107,7 +107,7 @@ func PrefixCopyRemote(ctx context.Context, srcConn, destConn *connparse.Connecti
 	readCtx, cancel := context.WithCancelCause(ctx)
 	defer cancel(nil)
 	ioch := srcClient.ReadTarStream(readCtx, srcConn, opts)
-	err = tarcopy.TarCopyDest(readCtx, cancel, ioch, func(next *tar.Header, reader *tar.Reader, singleFile bool) error {
+	err = tarcopy.TarCopyDest(readCtx, cancel, ioch, func(next *tar.Header, reader io.Reader, singleFile bool) error {
 		if next.Typeflag == tar.TypeDir {
 			return nil
 		}
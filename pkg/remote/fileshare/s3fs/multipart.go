@@ -0,0 +1,341 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fspath"
+)
+
+// S3 limits, see https://docs.aws.amazon.com/AmazonS3/latest/userguide/qfacts.html
+const (
+	MaxObjectSize    = 5 * 1024 * 1024 * 1024 * 1024 // 5TiB
+	MaxSinglePutSize = 5 * 1024 * 1024 * 1024        // 5GiB, also the max size for CopyObject
+	MinPartSize      = 5 * 1024 * 1024               // all parts but the last must be at least this big
+	MaxParts         = 10000
+)
+
+const (
+	// objects at or below this size are uploaded with a single PutObject
+	MultipartThreshold = 16 * 1024 * 1024
+	DefaultPartSize    = 16 * 1024 * 1024
+	// number of parts uploaded (and buffered) at the same time
+	MultipartConcurrency = 4
+	abortTimeout         = 30 * time.Second
+
+	// with an unknown size the part size doubles every partSizeStep parts, so MaxParts parts cover MaxObjectSize
+	partSizeStep = 1000
+)
+
+// returns the part size for an object of the given size so it fits in MaxParts
+func partSizeFor(size int64) int64 {
+	partSize := int64(DefaultPartSize)
+	for (size+partSize-1)/partSize > MaxParts {
+		partSize *= 2
+	}
+	return partSize
+}
+
+// splits the first size bytes of an object into (inclusive) byte ranges of at most MaxSinglePutSize each, for copying as parts
+// that are followed by more data.  the ranges are the same size (give or take a byte) so none of them drops below MinPartSize,
+// which cutting every MaxSinglePutSize would do to the last range.  size must be at least MinPartSize.
+func copyPartRanges(size int64) [][2]int64 {
+	numParts := (size + MaxSinglePutSize - 1) / MaxSinglePutSize
+	rtn := make([][2]int64, 0, numParts)
+	start := int64(0)
+	for i := int64(1); i <= numParts; i++ {
+		end := size * i / numParts
+		rtn = append(rtn, [2]int64{start, end - 1})
+		start = end
+	}
+	return rtn
+}
+
+// tracks a multipart upload so it can be completed with its parts, or aborted so the parts are not left behind (and billed)
+type multipartUpload struct {
+	client   *s3.Client
+	bucket   string
+	key      string
+	uploadId string
+	lock     sync.Mutex
+	parts    []types.CompletedPart
+}
+
+func (c S3Client) createMultipartUpload(ctx context.Context, bucket string, key string) (*multipartUpload, error) {
+	output, err := c.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating multipart upload for %v:%v: %w", bucket, key, err)
+	}
+	if output.UploadId == nil {
+		return nil, fmt.Errorf("error creating multipart upload for %v:%v: no upload id returned", bucket, key)
+	}
+	return &multipartUpload{client: c.client, bucket: bucket, key: key, uploadId: *output.UploadId}, nil
+}
+
+func (mu *multipartUpload) addPart(partNum int32, etag *string) {
+	mu.lock.Lock()
+	defer mu.lock.Unlock()
+	mu.parts = append(mu.parts, types.CompletedPart{ETag: etag, PartNumber: aws.Int32(partNum)})
+}
+
+func (mu *multipartUpload) uploadPart(ctx context.Context, partNum int32, data []byte) error {
+	output, err := mu.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(mu.bucket),
+		Key:           aws.String(mu.key),
+		UploadId:      aws.String(mu.uploadId),
+		PartNumber:    aws.Int32(partNum),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	})
+	if err != nil {
+		return fmt.Errorf("error uploading part %d of %v:%v: %w", partNum, mu.bucket, mu.key, err)
+	}
+	mu.addPart(partNum, output.ETag)
+	return nil
+}
+
+// copies the given byte range (inclusive) of the source object as a part
+func (mu *multipartUpload) copyPart(ctx context.Context, partNum int32, srcBucket string, srcKey string, start int64, end int64) error {
+	output, err := mu.client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+		Bucket:          aws.String(mu.bucket),
+		Key:             aws.String(mu.key),
+		UploadId:        aws.String(mu.uploadId),
+		PartNumber:      aws.Int32(partNum),
+		CopySource:      aws.String(fspath.Join(srcBucket, srcKey)),
+		CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+	})
+	if err != nil {
+		return fmt.Errorf("error copying part %d of %v:%v: %w", partNum, mu.bucket, mu.key, err)
+	}
+	if output.CopyPartResult == nil {
+		return fmt.Errorf("error copying part %d of %v:%v: no result returned", partNum, mu.bucket, mu.key)
+	}
+	mu.addPart(partNum, output.CopyPartResult.ETag)
+	return nil
+}
+
+func (mu *multipartUpload) complete(ctx context.Context) error {
+	mu.lock.Lock()
+	parts := mu.parts
+	mu.lock.Unlock()
+	sort.Slice(parts, func(i, j int) bool {
+		return *parts[i].PartNumber < *parts[j].PartNumber
+	})
+	_, err := mu.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(mu.bucket),
+		Key:             aws.String(mu.key),
+		UploadId:        aws.String(mu.uploadId),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return fmt.Errorf("error completing multipart upload of %v:%v: %w", mu.bucket, mu.key, err)
+	}
+	return nil
+}
+
+// uses its own context since the upload is usually aborted because ctx was canceled
+func (mu *multipartUpload) abort() {
+	ctx, cancel := context.WithTimeout(context.Background(), abortTimeout)
+	defer cancel()
+	_, err := mu.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(mu.bucket),
+		Key:      aws.String(mu.key),
+		UploadId: aws.String(mu.uploadId),
+	})
+	if err != nil {
+		log.Printf("s3fs: error aborting multipart upload of %v:%v: %v", mu.bucket, mu.key, err)
+	}
+}
+
+// runs the part functions with at most MultipartConcurrency at a time, then completes the upload.
+// nextPart returns nil when there are no more parts.  on any error the upload is aborted.
+func (mu *multipartUpload) run(ctx context.Context, nextPart func() (func(context.Context) error, error)) (rtnErr error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer func() {
+		if rtnErr != nil {
+			mu.abort()
+		}
+	}()
+	sem := make(chan struct{}, MultipartConcurrency)
+	wg := sync.WaitGroup{}
+	var errLock sync.Mutex
+	var partErr error
+	setErr := func(err error) {
+		errLock.Lock()
+		defer errLock.Unlock()
+		if partErr == nil {
+			partErr = err
+			cancel(err)
+		}
+	}
+	for ctx.Err() == nil {
+		// acquire before reading the next part so at most MultipartConcurrency parts are buffered
+		sem <- struct{}{}
+		partFn, err := nextPart()
+		if err != nil {
+			<-sem
+			setErr(err)
+			break
+		}
+		if partFn == nil {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer func() {
+				if panicErr := panichandler.PanicHandler("s3fs:multipartUpload", recover()); panicErr != nil {
+					setErr(panicErr)
+				}
+			}()
+			if err := partFn(ctx); err != nil {
+				setErr(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if partErr != nil {
+		return partErr
+	}
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return mu.complete(ctx)
+}
+
+// putObject uploads the reader to bucket/key, with a single PutObject if size is known and small, otherwise as a multipart upload.
+// size is -1 if unknown.
+func (c S3Client) putObject(ctx context.Context, bucket string, key string, reader io.Reader, size int64) error {
+	if size > MaxObjectSize {
+		return fmt.Errorf("object %v:%v is too large (%d bytes, max %d)", bucket, key, size, int64(MaxObjectSize))
+	}
+	if size >= 0 && size <= MultipartThreshold {
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return fmt.Errorf("error reading data for %v:%v: %w", bucket, key, err)
+		}
+		_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(key),
+			Body:          bytes.NewReader(data),
+			ContentLength: aws.Int64(size),
+		})
+		if err != nil {
+			return fmt.Errorf("error putting object %v:%v: %w", bucket, key, err)
+		}
+		return nil
+	}
+	return c.uploadMultipart(ctx, bucket, key, reader, size)
+}
+
+func (c S3Client) uploadMultipart(ctx context.Context, bucket string, key string, reader io.Reader, size int64) error {
+	mu, err := c.createMultipartUpload(ctx, bucket, key)
+	if err != nil {
+		return err
+	}
+	partSize := int64(DefaultPartSize)
+	if size >= 0 {
+		partSize = partSizeFor(size)
+	}
+	partNum := int32(0)
+	eof := false
+	return mu.run(ctx, func() (func(context.Context) error, error) {
+		if eof {
+			return nil, nil
+		}
+		if size < 0 && partNum > 0 && partNum%partSizeStep == 0 {
+			partSize *= 2
+		}
+		buf := make([]byte, partSize)
+		n, err := io.ReadFull(reader, buf)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			eof = true
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading data for %v:%v: %w", bucket, key, err)
+		}
+		// an empty object still needs one (empty) part
+		if n == 0 && partNum > 0 {
+			return nil, nil
+		}
+		partNum++
+		if partNum > MaxParts {
+			return nil, fmt.Errorf("object %v:%v has too many parts (max %d)", bucket, key, MaxParts)
+		}
+		num := partNum
+		data := buf[:n]
+		return func(ctx context.Context) error {
+			return mu.uploadPart(ctx, num, data)
+		}, nil
+	})
+}
+
+// copyObject copies srcBucket/srcKey to destBucket/destKey on the server, using UploadPartCopy for objects too large for CopyObject
+func (c S3Client) copyObject(ctx context.Context, srcBucket string, srcKey string, destBucket string, destKey string) error {
+	head, err := c.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(srcBucket),
+		Key:    aws.String(srcKey),
+	})
+	if err != nil {
+		return fmt.Errorf("error getting size of %v:%v: %w", srcBucket, srcKey, err)
+	}
+	size := int64(0)
+	if head.ContentLength != nil {
+		size = *head.ContentLength
+	}
+	if size <= MaxSinglePutSize {
+		_, err := c.client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(destBucket),
+			Key:        aws.String(destKey),
+			CopySource: aws.String(fspath.Join(srcBucket, srcKey)),
+		})
+		if err != nil {
+			return fmt.Errorf("error copying %v:%v to %v:%v: %w", srcBucket, srcKey, destBucket, destKey, err)
+		}
+		return nil
+	}
+	mu, err := c.createMultipartUpload(ctx, destBucket, destKey)
+	if err != nil {
+		return err
+	}
+	// copied parts are not buffered locally, so use the largest part size allowed to keep the number of requests down
+	partSize := max(partSizeFor(size), MaxSinglePutSize/8)
+	offset := int64(0)
+	partNum := int32(0)
+	err = mu.run(ctx, func() (func(context.Context) error, error) {
+		if offset >= size {
+			return nil, nil
+		}
+		start := offset
+		end := min(offset+partSize, size) - 1
+		offset = end + 1
+		partNum++
+		num := partNum
+		return func(ctx context.Context) error {
+			return mu.copyPart(ctx, num, srcBucket, srcKey, start, end)
+		}, nil
+	})
+	if err != nil {
+		return fmt.Errorf("error copying %v:%v to %v:%v: %w", srcBucket, srcKey, destBucket, destKey, err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package s3fs
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+const (
+	testMiB = 1024 * 1024
+	testGiB = 1024 * testMiB
+)
+
+func TestPartSizeFor(t *testing.T) {
+	tests := []struct {
+		name string
+		size int64
+		want int64
+	}{
+		{"empty", 0, DefaultPartSize},
+		{"small", 10 * testMiB, DefaultPartSize},
+		{"max parts at default", DefaultPartSize * MaxParts, DefaultPartSize},
+		{"one byte over", DefaultPartSize*MaxParts + 1, 2 * DefaultPartSize},
+		{"max object", MaxObjectSize, 1024 * testMiB},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := partSizeFor(tc.size)
+			if got != tc.want {
+				t.Fatalf("partSizeFor(%d) = %d, want %d", tc.size, got, tc.want)
+			}
+			if (tc.size+got-1)/got > MaxParts {
+				t.Fatalf("partSizeFor(%d) = %d needs more than %d parts", tc.size, got, MaxParts)
+			}
+		})
+	}
+}
+
+func TestByteRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		at         wshrpc.FileDataAt
+		objectSize int64
+		want       string
+	}{
+		{"whole object", wshrpc.FileDataAt{}, 100, "bytes=0-"},
+		{"from offset", wshrpc.FileDataAt{Offset: 10}, 100, "bytes=10-"},
+		{"negative offset", wshrpc.FileDataAt{Offset: -5, Size: 10}, 100, "bytes=0-9"},
+		{"offset and size", wshrpc.FileDataAt{Offset: 10, Size: 20}, 100, "bytes=10-29"},
+		{"size to end", wshrpc.FileDataAt{Offset: 80, Size: 20}, 100, "bytes=80-"},
+		{"size past end", wshrpc.FileDataAt{Offset: 90, Size: 20}, 100, "bytes=90-"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			at := tc.at
+			if got := byteRange(&at, tc.objectSize); got != tc.want {
+				t.Fatalf("byteRange(%+v, %d) = %q, want %q", tc.at, tc.objectSize, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodedLen(t *testing.T) {
+	for _, data := range []string{"", "a", "ab", "abc", "abcd", "hello world", string(make([]byte, 1000))} {
+		data64 := base64.StdEncoding.EncodeToString([]byte(data))
+		if got := decodedLen(data64); got != int64(len(data)) {
+			t.Errorf("decodedLen(%q) = %d, want %d", data64, got, len(data))
+		}
+	}
+}
+
+func TestCopyPartRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int64
+		numParts int
+	}{
+		{"min part", MinPartSize, 1},
+		{"small", 100 * testMiB, 1},
+		{"max single", MaxSinglePutSize, 1},
+		// cutting every 5GiB would leave a 1 byte part in the middle
+		{"one byte over", MaxSinglePutSize + 1, 2},
+		{"short tail", 2*MaxSinglePutSize + testMiB, 3},
+		{"odd size", 7*testGiB + 12345, 2},
+		{"max object", MaxObjectSize, 1024},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ranges := copyPartRanges(tc.size)
+			if len(ranges) != tc.numParts {
+				t.Fatalf("got %d parts, want %d", len(ranges), tc.numParts)
+			}
+			next := int64(0)
+			for i, r := range ranges {
+				if r[0] != next {
+					t.Fatalf("part %d starts at %d, want %d", i+1, r[0], next)
+				}
+				partLen := r[1] - r[0] + 1
+				if partLen < MinPartSize || partLen > MaxSinglePutSize {
+					t.Fatalf("part %d has size %d, want %d-%d", i+1, partLen, int64(MinPartSize), int64(MaxSinglePutSize))
+				}
+				next = r[1] + 1
+			}
+			if next != tc.size {
+				t.Fatalf("parts end at %d, want %d", next, tc.size)
+			}
+		})
+	}
+}
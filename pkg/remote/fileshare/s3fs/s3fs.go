@@ -4,7 +4,6 @@
 package s3fs
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
 	"errors"
@@ -39,9 +38,9 @@ type S3Client struct {
 
 var _ fstype.FileShareClient = S3Client{}
 
-func NewS3Client(config *aws.Config) *S3Client {
+func NewS3Client(config *aws.Config, optFns ...func(*s3.Options)) *S3Client {
 	return &S3Client{
-		client: s3.NewFromConfig(*config),
+		client: s3.NewFromConfig(*config, optFns...),
 	}
 }
 
@@ -92,21 +91,19 @@ func (c S3Client) ReadStream(ctx context.Context, conn *connparse.Connection, da
 				}
 			}
 		} else {
-			var result *s3.GetObjectOutput
-			var err error
+			input := &s3.GetObjectInput{
+				Bucket: aws.String(bucket),
+				Key:    aws.String(objectKey),
+			}
 			if data.At != nil {
 				log.Printf("reading %v with offset %d and size %d", conn.GetFullURI(), data.At.Offset, data.At.Size)
-				result, err = c.client.GetObject(ctx, &s3.GetObjectInput{
-					Bucket: aws.String(bucket),
-					Key:    aws.String(objectKey),
-					Range:  aws.String(fmt.Sprintf("bytes=%d-%d", data.At.Offset, data.At.Offset+int64(data.At.Size)-1)),
-				})
-			} else {
-				result, err = c.client.GetObject(ctx, &s3.GetObjectInput{
-					Bucket: aws.String(bucket),
-					Key:    aws.String(objectKey),
-				})
+				if data.At.Offset >= finfo.Size {
+					// S3 rejects a range that starts past the end, there is nothing to read
+					return
+				}
+				input.Range = aws.String(byteRange(data.At, finfo.Size))
 			}
+			result, err := c.client.GetObject(ctx, input)
 			if err != nil {
 				log.Printf("error getting object %v:%v: %v", bucket, objectKey, err)
 				var noKey *types.NoSuchKey
@@ -163,6 +160,15 @@ func (c S3Client) ReadStream(ctx context.Context, conn *connparse.Connection, da
 	return rtn
 }
 
+// returns the http Range header for the requested part of an object (a size of 0 reads to the end)
+func byteRange(at *wshrpc.FileDataAt, objectSize int64) string {
+	offset := max(at.Offset, 0)
+	if at.Size <= 0 || offset+int64(at.Size) >= objectSize {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+int64(at.Size)-1)
+}
+
 func (c S3Client) ReadTarStream(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileCopyOpts) <-chan wshrpc.RespOrErrorUnion[iochantypes.Packet] {
 	recursive := opts != nil && opts.Recursive
 	bucket := conn.Host
@@ -454,6 +460,7 @@ func (c S3Client) ListEntriesStream(ctx context.Context, conn *connparse.Connect
 				if obj.Size != nil {
 					size = *obj.Size
 				}
+				meta := objectMeta(obj.ETag, string(obj.StorageClass))
 				entryMap[name] = &wshrpc.FileInfo{
 					Name:    name,
 					IsDir:   false,
@@ -461,6 +468,7 @@ func (c S3Client) ListEntriesStream(ctx context.Context, conn *connparse.Connect
 					Path:    path,
 					ModTime: lastModTime,
 					Size:    size,
+					Meta:    &meta,
 				}
 				fileutil.AddMimeTypeToFileInfo(path, entryMap[name])
 				numFetched++
@@ -536,12 +544,10 @@ func (c S3Client) Stat(ctx context.Context, conn *connparse.Connection) (*wshrpc
 			}, nil
 		}
 	}
-	result, err := c.client.GetObjectAttributes(ctx, &s3.GetObjectAttributesInput{
+	// HeadObject (unlike GetObjectAttributes) is supported by S3-compatible servers and returns the content type
+	result, err := c.client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(objectKey),
-		ObjectAttributes: []types.ObjectAttributes{
-			types.ObjectAttributesObjectSize,
-		},
 	})
 	if err != nil {
 		var noKey *types.NoSuchKey
@@ -583,13 +589,20 @@ func (c S3Client) Stat(ctx context.Context, conn *connparse.Connection) (*wshrpc
 		return nil, err
 	}
 	size := int64(0)
-	if result.ObjectSize != nil {
-		size = *result.ObjectSize
+	if result.ContentLength != nil {
+		size = *result.ContentLength
 	}
 	lastModified := int64(0)
 	if result.LastModified != nil {
 		lastModified = result.LastModified.UnixMilli()
 	}
+	meta := objectMeta(result.ETag, string(result.StorageClass))
+	if result.ContentType != nil {
+		meta[MetaKey_ContentType] = *result.ContentType
+	}
+	if result.VersionId != nil {
+		meta[MetaKey_VersionId] = *result.VersionId
+	}
 	rtn := &wshrpc.FileInfo{
 		Name:    objectKey,
 		Path:    conn.GetPathWithHost(),
@@ -597,11 +610,85 @@ func (c S3Client) Stat(ctx context.Context, conn *connparse.Connection) (*wshrpc
 		IsDir:   false,
 		Size:    size,
 		ModTime: lastModified,
+		Meta:    &meta,
 	}
 	fileutil.AddMimeTypeToFileInfo(rtn.Path, rtn)
 	return rtn, nil
 }
 
+// metadata keys set on the FileInfo of objects
+const (
+	MetaKey_ETag         = "s3:etag"
+	MetaKey_StorageClass = "s3:storageclass"
+	MetaKey_ContentType  = "s3:contenttype"
+	MetaKey_VersionId    = "s3:versionid"
+	MetaKey_Tags         = "s3:tags"
+)
+
+// returns the metadata available for both listed and stat'd objects
+func objectMeta(etag *string, storageClass string) wshrpc.FileMeta {
+	meta := make(wshrpc.FileMeta)
+	if etag != nil {
+		meta[MetaKey_ETag] = strings.Trim(*etag, "\"")
+	}
+	if storageClass != "" {
+		meta[MetaKey_StorageClass] = storageClass
+	}
+	return meta
+}
+
+// tags take another request per object, so they are only fetched when asked for (see fstype.ObjectTagger)
+func (c S3Client) AddObjectTags(ctx context.Context, conn *connparse.Connection, finfo *wshrpc.FileInfo) error {
+	if finfo == nil || finfo.IsDir || finfo.NotFound || conn.Host == "" || conn.Path == "" {
+		return nil
+	}
+	tags, err := c.getObjectTags(ctx, conn.Host, conn.Path)
+	if err != nil {
+		return fmt.Errorf("error getting tags for %v:%v: %w", conn.Host, conn.Path, err)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	if finfo.Meta == nil {
+		finfo.Meta = &wshrpc.FileMeta{}
+	}
+	(*finfo.Meta)[MetaKey_Tags] = tags
+	return nil
+}
+
+func (c S3Client) getObjectTags(ctx context.Context, bucket string, objectKey string) (map[string]string, error) {
+	output, err := c.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		if isTaggingUnsupported(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	tags := make(map[string]string)
+	for _, tag := range output.TagSet {
+		if tag.Key != nil {
+			tags[*tag.Key] = aws.ToString(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+// S3-compatible servers (MinIO, Ceph RGW) may not implement tagging or not allow it, that is the same as no tags
+func isTaggingUnsupported(err error) bool {
+	var apiError smithy.APIError
+	if !errors.As(err, &apiError) {
+		return false
+	}
+	switch apiError.ErrorCode() {
+	case "NotImplemented", "AccessDenied":
+		return true
+	}
+	return false
+}
+
 func (c S3Client) PutFile(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData) error {
 	if data.At != nil {
 		return errors.Join(errors.ErrUnsupported, fmt.Errorf("file data offset and size not supported"))
@@ -611,35 +698,92 @@ func (c S3Client) PutFile(ctx context.Context, conn *connparse.Connection, data
 	if bucket == "" || bucket == "/" || objectKey == "" || objectKey == "/" {
 		return errors.Join(errors.ErrUnsupported, fmt.Errorf("bucket and object key must be specified"))
 	}
-	contentMaxLength := base64.StdEncoding.DecodedLen(len(data.Data64))
-	var decodedBody []byte
-	var contentLength int
-	var err error
-	if contentMaxLength > 0 {
-		decodedBody = make([]byte, contentMaxLength)
-		contentLength, err = base64.StdEncoding.Decode(decodedBody, []byte(data.Data64))
-		if err != nil {
-			return err
-		}
+	// decode as the object is uploaded instead of holding a decoded copy of the whole payload
+	var body io.Reader
+	var contentLength int64
+	if data.Data64 != "" {
+		body = base64.NewDecoder(base64.StdEncoding, strings.NewReader(data.Data64))
+		contentLength = decodedLen(data.Data64)
 	} else {
-		decodedBody = []byte("\n")
+		body = strings.NewReader("\n")
 		contentLength = 1
 	}
-	bodyReaderSeeker := bytes.NewReader(decodedBody[:contentLength])
-	_, err = c.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:        aws.String(bucket),
-		Key:           aws.String(objectKey),
-		Body:          bodyReaderSeeker,
-		ContentLength: aws.Int64(int64(contentLength)),
-	})
+	err := c.putObject(ctx, bucket, objectKey, body, contentLength)
 	if err != nil {
 		log.Printf("PutFile: error putting object %v:%v: %v", bucket, objectKey, err)
 	}
 	return err
 }
 
+// the exact decoded length of padded base64 data
+func decodedLen(data64 string) int64 {
+	padding := len(data64) - len(strings.TrimRight(data64, "="))
+	return int64(len(data64)/4*3 - padding)
+}
+
+// AppendFile rewrites the object with the data appended.  objects large enough to be a multipart part are copied on the server,
+// smaller ones are read and uploaded again.  since every append rewrites the object, CanAppend stays false so callers send whole files instead.
 func (c S3Client) AppendFile(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData) error {
-	return errors.Join(errors.ErrUnsupported, fmt.Errorf("append file not supported"))
+	if data.At != nil {
+		return errors.Join(errors.ErrUnsupported, fmt.Errorf("file data offset and size not supported"))
+	}
+	bucket := conn.Host
+	objectKey := conn.Path
+	if bucket == "" || bucket == "/" || objectKey == "" || objectKey == "/" {
+		return errors.Join(errors.ErrUnsupported, fmt.Errorf("bucket and object key must be specified"))
+	}
+	finfo, err := c.Stat(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if finfo.NotFound {
+		return c.PutFile(ctx, conn, data)
+	}
+	if finfo.IsDir {
+		return fmt.Errorf("cannot append to directory %v:%v", bucket, objectKey)
+	}
+	if finfo.Size+decodedLen(data.Data64) > MaxObjectSize {
+		return fmt.Errorf("append would exceed maximum object size of %d bytes", int64(MaxObjectSize))
+	}
+	appendData := base64.NewDecoder(base64.StdEncoding, strings.NewReader(data.Data64))
+	if finfo.Size < MinPartSize {
+		result, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(objectKey),
+		})
+		if err != nil {
+			return fmt.Errorf("error getting object %v:%v: %w", bucket, objectKey, err)
+		}
+		defer utilfn.GracefulClose(result.Body, "s3fs", conn.GetFullURI())
+		return c.putObject(ctx, bucket, objectKey, io.MultiReader(result.Body, appendData), finfo.Size+decodedLen(data.Data64))
+	}
+	mu, err := c.createMultipartUpload(ctx, bucket, objectKey)
+	if err != nil {
+		return err
+	}
+	// the existing data is copied as the first parts, the appended data is always the last part (the only one that can be small)
+	copyRanges := copyPartRanges(finfo.Size)
+	partNum := int32(0)
+	return mu.run(ctx, func() (func(context.Context) error, error) {
+		partNum++
+		num := partNum
+		if int(num) <= len(copyRanges) {
+			copyRange := copyRanges[num-1]
+			return func(ctx context.Context) error {
+				return mu.copyPart(ctx, num, bucket, objectKey, copyRange[0], copyRange[1])
+			}, nil
+		}
+		if int(num) > len(copyRanges)+1 {
+			return nil, nil
+		}
+		buf, err := io.ReadAll(appendData)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding data for %v:%v: %w", bucket, objectKey, err)
+		}
+		return func(ctx context.Context) error {
+			return mu.uploadPart(ctx, num, buf)
+		}, nil
+	})
 }
 
 func (c S3Client) Mkdir(ctx context.Context, conn *connparse.Connection) error {
@@ -664,13 +808,7 @@ func (c S3Client) CopyRemote(ctx context.Context, srcConn, destConn *connparse.C
 		return false, fmt.Errorf("destination bucket must be specified")
 	}
 	return fsutil.PrefixCopyRemote(ctx, srcConn, destConn, srcClient, c, func(bucket, path string, size int64, reader io.Reader) error {
-		_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
-			Bucket:        aws.String(bucket),
-			Key:           aws.String(path),
-			Body:          reader,
-			ContentLength: aws.Int64(size),
-		})
-		return err
+		return c.putObject(ctx, bucket, path, reader, size)
 	}, opts)
 }
 
@@ -691,15 +829,7 @@ func (c S3Client) CopyInternal(ctx context.Context, srcConn, destConn *connparse
 		})
 		return entries, err
 	}, func(ctx context.Context, srcPath, destPath string) error {
-		_, err := c.client.CopyObject(ctx, &s3.CopyObjectInput{
-			Bucket:     aws.String(destBucket),
-			Key:        aws.String(destPath),
-			CopySource: aws.String(fspath.Join(srcBucket, srcPath)),
-		})
-		if err != nil {
-			return fmt.Errorf("error copying %v:%v to %v:%v: %w", srcBucket, srcPath, destBucket, destPath, err)
-		}
-		return nil
+		return c.copyObject(ctx, srcBucket, srcPath, destBucket, destPath)
 	})
 }
 
@@ -806,7 +936,9 @@ func (c S3Client) GetConnectionType() string {
 
 func (c S3Client) GetCapability() wshrpc.FileShareCapability {
 	return wshrpc.FileShareCapability{
-		CanAppend: false,
-		CanMkdir:  false,
+		CanAppend:    false,
+		CanMkdir:     false,
+		MaxFileSize:  MaxObjectSize,
+		CanMultipart: true,
 	}
 }
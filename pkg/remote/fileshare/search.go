@@ -0,0 +1,121 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package fileshare
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/remote/connparse"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/filesearch"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/filesync"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fspath"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fstype"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fsutil"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/wshfs"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshutil"
+)
+
+// a file being read from a fileshare, closing it stops the read
+type streamReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *streamReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+func openStream(ctx context.Context, client fstype.FileShareClient, conn *connparse.Connection) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("fileshare:openStream", recover())
+		}()
+		pw.CloseWithError(fsutil.ReadFileStreamToWriter(ctx, client.ReadStream(ctx, conn, wshrpc.FileData{}), pw))
+	}()
+	return &streamReader{PipeReader: pr, cancel: cancel}
+}
+
+// Search streams the lines matching the search pattern, in the file or below the directory data.Uri.
+// wsh connections are searched on the connection, other fileshares are read and searched here.
+func Search(ctx context.Context, data wshrpc.CommandFileSearchData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileSearchResult] {
+	log.Printf("Search: %v, opts: %v", data.Uri, data.Opts)
+	if data.Opts == nil {
+		return wshutil.SendErrCh[wshrpc.FileSearchResult](fmt.Errorf("no search pattern given"))
+	}
+	client, conn := CreateFileShareClient(ctx, data.Uri)
+	if conn == nil || client == nil {
+		return wshutil.SendErrCh[wshrpc.FileSearchResult](fmt.Errorf(ErrorParsingConnection, data.Uri))
+	}
+	if client.GetConnectionType() == connparse.ConnectionTypeWsh {
+		return wshfs.WshClient{}.Search(ctx, conn, data.Opts)
+	}
+	filter, err := filesearch.MakeFilter(data.Opts)
+	if err != nil {
+		return wshutil.SendErrCh[wshrpc.FileSearchResult](err)
+	}
+	ch := make(chan wshrpc.RespOrErrorUnion[wshrpc.FileSearchResult], 16)
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("fileshare:Search", recover())
+		}()
+		defer close(ch)
+		err := runSearch(ctx, client, conn, filter, data.Opts, func(result wshrpc.FileSearchResult) bool {
+			select {
+			case ch <- wshrpc.RespOrErrorUnion[wshrpc.FileSearchResult]{Response: result}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			ch <- wshutil.RespErr[wshrpc.FileSearchResult](err)
+		}
+	}()
+	return ch
+}
+
+func runSearch(ctx context.Context, client fstype.FileShareClient, conn *connparse.Connection, filter *filesync.Filter, opts *wshrpc.FileSearchOpts, sendFn func(wshrpc.FileSearchResult) bool) error {
+	finfo, err := client.Stat(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("cannot stat %q: %w", conn.GetFullURI(), err)
+	}
+	if finfo.NotFound {
+		return fmt.Errorf("%q not found", conn.GetFullURI())
+	}
+	return filesearch.Run(ctx, opts, func(ctx context.Context, addFile func(string, int64) bool) error {
+		if !finfo.IsDir {
+			addFile(fspath.Base(conn.Path), finfo.Size)
+			return nil
+		}
+		files, err := filesync.ListTree(ctx, client, conn, filter, !opts.NoGitIgnore)
+		if err != nil {
+			return err
+		}
+		relPaths := make([]string, 0, len(files))
+		for relPath := range files {
+			relPaths = append(relPaths, relPath)
+		}
+		sort.Strings(relPaths)
+		for _, relPath := range relPaths {
+			if !addFile(relPath, files[relPath].Size) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	}, func(ctx context.Context, relPath string) (io.ReadCloser, error) {
+		if !finfo.IsDir {
+			return openStream(ctx, client, conn), nil
+		}
+		return openStream(ctx, client, filesync.ChildConn(conn, relPath)), nil
+	}, sendFn)
+}
@@ -0,0 +1,803 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sftpfs is a fileshare client for ssh connections that do not run wsh, it talks to the "sftp" subsystem of the ssh server.
+package sftpfs
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/remote"
+	"github.com/commandlinedev/starterm/pkg/remote/conncontroller"
+	"github.com/commandlinedev/starterm/pkg/remote/connparse"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fstype"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fsutil"
+	"github.com/commandlinedev/starterm/pkg/util/fileutil"
+	"github.com/commandlinedev/starterm/pkg/util/iochan/iochantypes"
+	"github.com/commandlinedev/starterm/pkg/util/tarcopy"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshutil"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+type session struct {
+	sshClient *ssh.Client
+	client    *sftp.Client
+	closed    *atomic.Bool
+	home      string
+}
+
+var sessionsLock sync.Mutex
+var sessions = make(map[string]*session)
+
+// ShouldUseSftp returns true if wsh is not running on the connection (disabled or not installed), or will not run
+// once it connects (conn:wshenabled is false).  in that case wsh:// uris on the connection are served over sftp.
+func ShouldUseSftp(connName string) bool {
+	if connName == "" || connName == wshrpc.LocalConnName || strings.HasPrefix(connName, "wsl://") {
+		return false
+	}
+	return conncontroller.IsWshDisabled(connName)
+}
+
+type SftpClient struct{}
+
+var _ fstype.FileShareClient = SftpClient{}
+
+func NewSftpClient() *SftpClient {
+	return &SftpClient{}
+}
+
+// returns the ssh client of the connection, connecting if needed
+var getSshClient = func(ctx context.Context, connName string) (*ssh.Client, error) {
+	if err := conncontroller.EnsureConnection(ctx, connName); err != nil {
+		return nil, fmt.Errorf("cannot connect to %q: %w", connName, err)
+	}
+	opts, err := remote.ParseOpts(connName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse connection name %q: %w", connName, err)
+	}
+	client := conncontroller.GetConn(opts).GetClient()
+	if client == nil {
+		return nil, fmt.Errorf("connection %q is not connected", connName)
+	}
+	return client, nil
+}
+
+var newSftpClient = func(client *ssh.Client) (*sftp.Client, error) {
+	return sftp.NewClient(client)
+}
+
+// returns the cached session if it is still usable.  must hold sessionsLock.
+func getCachedSession(connName string, client *ssh.Client) *session {
+	sess := sessions[connName]
+	if sess == nil {
+		return nil
+	}
+	if sess.sshClient == client && !sess.closed.Load() {
+		return sess
+	}
+	sess.client.Close()
+	delete(sessions, connName)
+	return nil
+}
+
+// returns the sftp session for the connection, starting one if needed.  sessions are reused until the ssh client changes (reconnect) or the session fails.
+// the session is started without holding sessionsLock, so a slow host does not block the other connections.
+func getSession(ctx context.Context, connName string) (*session, error) {
+	client, err := getSshClient(ctx, connName)
+	if err != nil {
+		return nil, err
+	}
+	sessionsLock.Lock()
+	sess := getCachedSession(connName, client)
+	sessionsLock.Unlock()
+	if sess != nil {
+		return sess, nil
+	}
+	sftpClient, err := newSftpClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot start sftp session on %q: %w", connName, err)
+	}
+	home, err := sftpClient.RealPath(".")
+	if err != nil {
+		sftpClient.Close()
+		return nil, fmt.Errorf("cannot get home directory on %q: %w", connName, err)
+	}
+	sessionsLock.Lock()
+	defer sessionsLock.Unlock()
+	// another caller may have started a session in the meantime
+	if other := getCachedSession(connName, client); other != nil {
+		sftpClient.Close()
+		return other, nil
+	}
+	sess = &session{sshClient: client, client: sftpClient, closed: &atomic.Bool{}, home: home}
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("sftpfs:waitSession", recover())
+		}()
+		sftpClient.Wait()
+		sess.closed.Store(true)
+	}()
+	sessions[connName] = sess
+	return sess, nil
+}
+
+// resolves a wsh style path ("~", "~/x", relative to home, or absolute) to an absolute remote path
+func (s *session) resolvePath(p string) string {
+	if p == "" || p == "~" {
+		return s.home
+	}
+	if strings.HasPrefix(p, "~/") {
+		return path.Join(s.home, p[2:])
+	}
+	if !path.IsAbs(p) {
+		return path.Join(s.home, p)
+	}
+	return path.Clean(p)
+}
+
+// the reverse of resolvePath, like starbase.ReplaceHomeDir
+func (s *session) displayPath(p string) string {
+	if p == s.home {
+		return "~"
+	}
+	if s.home != "/" && strings.HasPrefix(p, s.home+"/") {
+		return "~" + p[len(s.home):]
+	}
+	return p
+}
+
+func (s *session) toFileInfo(fullPath string, finfo fs.FileInfo) *wshrpc.FileInfo {
+	rtn := &wshrpc.FileInfo{
+		Path:          s.displayPath(fullPath),
+		Dir:           path.Dir(fullPath),
+		Name:          finfo.Name(),
+		Size:          finfo.Size(),
+		Mode:          finfo.Mode(),
+		ModeStr:       finfo.Mode().String(),
+		ModTime:       finfo.ModTime().UnixMilli(),
+		IsDir:         finfo.IsDir(),
+		MimeType:      fileutil.DetectMimeType(fullPath, finfo, false),
+		SupportsMkdir: true,
+	}
+	if finfo.IsDir() {
+		rtn.Size = -1
+	}
+	return rtn
+}
+
+func (s *session) notFoundInfo(fullPath string) *wshrpc.FileInfo {
+	return &wshrpc.FileInfo{
+		Path:          s.displayPath(fullPath),
+		Dir:           path.Dir(fullPath),
+		NotFound:      true,
+		SupportsMkdir: true,
+	}
+}
+
+// the info of a symlink target, under the name of the link
+type linkInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (fi linkInfo) Name() string { return fi.name }
+
+// symlinks are listed with the info of their target, broken links are listed as is
+func (s *session) followLink(dirPath string, finfo fs.FileInfo) fs.FileInfo {
+	if finfo.Mode()&fs.ModeSymlink == 0 {
+		return finfo
+	}
+	target, err := s.client.Stat(path.Join(dirPath, finfo.Name()))
+	if err != nil {
+		return finfo
+	}
+	return linkInfo{FileInfo: target, name: finfo.Name()}
+}
+
+// unlike sftp.Client.RemoveAll, symlinks to directories are removed, not followed
+func (s *session) removeAll(p string) error {
+	finfo, err := s.client.Lstat(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if !finfo.IsDir() {
+		return s.client.Remove(p)
+	}
+	entries, err := s.client.ReadDir(p)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := s.removeAll(path.Join(p, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return s.client.RemoveDirectory(p)
+}
+
+func (c SftpClient) Read(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData) (*wshrpc.FileData, error) {
+	rtnCh := c.ReadStream(ctx, conn, data)
+	return fsutil.ReadStreamToFileData(ctx, rtnCh)
+}
+
+func (c SftpClient) ReadStream(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileData] {
+	ch := make(chan wshrpc.RespOrErrorUnion[wshrpc.FileData], 16)
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("sftpfs:ReadStream", recover())
+		}()
+		defer close(ch)
+		if err := c.readStream(ctx, conn, data, ch); err != nil {
+			ch <- wshutil.RespErr[wshrpc.FileData](err)
+		}
+	}()
+	return ch
+}
+
+func (c SftpClient) readStream(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData, ch chan<- wshrpc.RespOrErrorUnion[wshrpc.FileData]) error {
+	sess, err := getSession(ctx, conn.Host)
+	if err != nil {
+		return err
+	}
+	fullPath := sess.resolvePath(conn.Path)
+	finfo, err := sess.client.Stat(fullPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		ch <- wshrpc.RespOrErrorUnion[wshrpc.FileData]{Response: wshrpc.FileData{Info: sess.notFoundInfo(fullPath)}}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot stat file %q: %w", conn.Path, err)
+	}
+	ch <- wshrpc.RespOrErrorUnion[wshrpc.FileData]{Response: wshrpc.FileData{Info: sess.toFileInfo(fullPath, finfo)}}
+	if finfo.IsDir() {
+		dirEntries, err := sess.client.ReadDirContext(ctx, fullPath)
+		if err != nil {
+			return fmt.Errorf("cannot open dir %q: %w", conn.Path, err)
+		}
+		if len(dirEntries) > wshrpc.MaxDirSize {
+			dirEntries = dirEntries[:wshrpc.MaxDirSize]
+		}
+		var entries []*wshrpc.FileInfo
+		for _, entry := range dirEntries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			entry = sess.followLink(fullPath, entry)
+			entries = append(entries, sess.toFileInfo(path.Join(fullPath, entry.Name()), entry))
+			if len(entries) >= wshrpc.DirChunkSize {
+				ch <- wshrpc.RespOrErrorUnion[wshrpc.FileData]{Response: wshrpc.FileData{Entries: entries}}
+				entries = nil
+			}
+		}
+		if len(entries) > 0 {
+			ch <- wshrpc.RespOrErrorUnion[wshrpc.FileData]{Response: wshrpc.FileData{Entries: entries}}
+		}
+		return nil
+	}
+	file, err := sess.client.Open(fullPath)
+	if err != nil {
+		return fmt.Errorf("cannot open file %q: %w", conn.Path, err)
+	}
+	defer utilfn.GracefulClose(file, "sftpfs:ReadStream", fullPath)
+	var offset int64
+	remaining := int64(-1)
+	if data.At != nil {
+		offset = data.At.Offset
+		if data.At.Size > 0 {
+			remaining = int64(data.At.Size)
+		}
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("cannot seek file %q: %w", conn.Path, err)
+	}
+	buf := make([]byte, wshrpc.FileChunkSize)
+	for remaining != 0 {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		readBuf := buf
+		if remaining > 0 && remaining < int64(len(buf)) {
+			readBuf = buf[:remaining]
+		}
+		n, err := io.ReadFull(file, readBuf)
+		if n > 0 {
+			ch <- wshrpc.RespOrErrorUnion[wshrpc.FileData]{Response: wshrpc.FileData{
+				Data64: base64.StdEncoding.EncodeToString(readBuf[:n]),
+				At:     &wshrpc.FileDataAt{Offset: offset, Size: n},
+			}}
+			offset += int64(n)
+			if remaining > 0 {
+				remaining -= int64(n)
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading file %q: %w", conn.Path, err)
+		}
+	}
+	return nil
+}
+
+func (c SftpClient) ReadTarStream(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileCopyOpts) <-chan wshrpc.RespOrErrorUnion[iochantypes.Packet] {
+	if opts == nil {
+		opts = &wshrpc.FileCopyOpts{}
+	}
+	sess, err := getSession(ctx, conn.Host)
+	if err != nil {
+		return wshutil.SendErrCh[iochantypes.Packet](err)
+	}
+	srcHasSlash := strings.HasSuffix(conn.Path, "/")
+	cleanedPath := sess.resolvePath(conn.Path)
+	finfo, err := sess.client.Stat(cleanedPath)
+	if err != nil {
+		return wshutil.SendErrCh[iochantypes.Packet](fmt.Errorf("cannot stat file %q: %w", conn.Path, err))
+	}
+	singleFile := !finfo.IsDir()
+	if !singleFile && !opts.Recursive {
+		return wshutil.SendErrCh[iochantypes.Packet](fmt.Errorf(fstype.RecursiveRequiredError))
+	}
+	var pathPrefix string
+	if !singleFile && srcHasSlash {
+		pathPrefix = cleanedPath
+	} else {
+		pathPrefix = path.Dir(cleanedPath)
+	}
+
+	timeout := fstype.DefaultTimeout
+	if opts.Timeout > 0 {
+		timeout = time.Duration(opts.Timeout) * time.Millisecond
+	}
+	readerCtx, cancel := context.WithTimeout(ctx, timeout)
+	rtn, writeHeader, fileWriter, tarClose := tarcopy.TarCopySrcCompressed(readerCtx, pathPrefix, opts.Compression)
+
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("sftpfs:ReadTarStream", recover())
+		}()
+		defer func() {
+			tarClose()
+			cancel()
+		}()
+		var walk func(p string, info fs.FileInfo) error
+		walk = func(p string, info fs.FileInfo) error {
+			if readerCtx.Err() != nil {
+				return readerCtx.Err()
+			}
+			if info.IsDir() {
+				if err := writeHeader(info, p, singleFile, nil); err != nil {
+					return err
+				}
+				entries, err := sess.client.ReadDirContext(readerCtx, p)
+				if err != nil {
+					return fmt.Errorf("cannot read dir %q: %w", p, err)
+				}
+				for _, entry := range entries {
+					// symlinks to directories are not followed, so a link cycle cannot recurse forever
+					if entry.Mode()&fs.ModeSymlink != 0 {
+						entry = sess.followLink(p, entry)
+						if entry.IsDir() {
+							continue
+						}
+					}
+					if err := walk(path.Join(p, entry.Name()), entry); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			file, err := sess.client.Open(p)
+			if err != nil {
+				return fmt.Errorf("cannot open file %q: %w", p, err)
+			}
+			defer utilfn.GracefulClose(file, "sftpfs:ReadTarStream", p)
+			if err := writeHeader(info, p, singleFile, nil); err != nil {
+				return err
+			}
+			if _, err := io.Copy(fileWriter, file); err != nil {
+				return err
+			}
+			return nil
+		}
+		if err := walk(cleanedPath, finfo); err != nil {
+			rtn <- wshutil.RespErr[iochantypes.Packet](err)
+		}
+	}()
+	return rtn
+}
+
+func (c SftpClient) ListEntries(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileListOpts) ([]*wshrpc.FileInfo, error) {
+	var entries []*wshrpc.FileInfo
+	rtnCh := c.ListEntriesStream(ctx, conn, opts)
+	for respUnion := range rtnCh {
+		if respUnion.Error != nil {
+			return nil, respUnion.Error
+		}
+		entries = append(entries, respUnion.Response.FileInfo...)
+	}
+	return entries, nil
+}
+
+func (c SftpClient) ListEntriesStream(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileListOpts) <-chan wshrpc.RespOrErrorUnion[wshrpc.CommandRemoteListEntriesRtnData] {
+	if opts == nil {
+		opts = &wshrpc.FileListOpts{}
+	}
+	limit := opts.Limit
+	if limit == 0 {
+		limit = wshrpc.MaxDirSize
+	}
+	ch := make(chan wshrpc.RespOrErrorUnion[wshrpc.CommandRemoteListEntriesRtnData], 16)
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("sftpfs:ListEntriesStream", recover())
+		}()
+		defer close(ch)
+		sess, err := getSession(ctx, conn.Host)
+		if err != nil {
+			ch <- wshutil.RespErr[wshrpc.CommandRemoteListEntriesRtnData](err)
+			return
+		}
+		dirPath := sess.resolvePath(conn.Path)
+		var fileInfoArr []*wshrpc.FileInfo
+		seen := 0
+		// returns false once the limit is reached
+		addEntry := func(dir string, entry fs.FileInfo) bool {
+			defer func() {
+				seen++
+			}()
+			if seen < opts.Offset {
+				return true
+			}
+			if seen >= opts.Offset+limit {
+				return false
+			}
+			fileInfoArr = append(fileInfoArr, sess.toFileInfo(path.Join(dir, entry.Name()), entry))
+			if len(fileInfoArr) >= wshrpc.DirChunkSize {
+				ch <- wshrpc.RespOrErrorUnion[wshrpc.CommandRemoteListEntriesRtnData]{Response: wshrpc.CommandRemoteListEntriesRtnData{FileInfo: fileInfoArr}}
+				fileInfoArr = nil
+			}
+			return true
+		}
+		var walk func(dir string) (bool, error)
+		walk = func(dir string) (bool, error) {
+			var subDirs []string
+			more := true
+			entries, err := sess.client.ReadDirContext(ctx, dir)
+			if err != nil {
+				return false, fmt.Errorf("cannot open dir %q: %w", sess.displayPath(dir), err)
+			}
+			for _, entry := range entries {
+				if ctx.Err() != nil {
+					return false, ctx.Err()
+				}
+				if opts.All && entry.IsDir() {
+					subDirs = append(subDirs, path.Join(dir, entry.Name()))
+					continue
+				}
+				if !opts.All {
+					entry = sess.followLink(dir, entry)
+				}
+				if more = addEntry(dir, entry); !more {
+					break
+				}
+			}
+			for _, subDir := range subDirs {
+				if !more {
+					break
+				}
+				if more, err = walk(subDir); err != nil {
+					return false, err
+				}
+			}
+			return more, nil
+		}
+		if _, err := walk(dirPath); err != nil {
+			ch <- wshutil.RespErr[wshrpc.CommandRemoteListEntriesRtnData](err)
+			return
+		}
+		if len(fileInfoArr) > 0 {
+			ch <- wshrpc.RespOrErrorUnion[wshrpc.CommandRemoteListEntriesRtnData]{Response: wshrpc.CommandRemoteListEntriesRtnData{FileInfo: fileInfoArr}}
+		}
+	}()
+	return ch
+}
+
+func (c SftpClient) Stat(ctx context.Context, conn *connparse.Connection) (*wshrpc.FileInfo, error) {
+	sess, err := getSession(ctx, conn.Host)
+	if err != nil {
+		return nil, err
+	}
+	fullPath := sess.resolvePath(conn.Path)
+	finfo, err := sess.client.Stat(fullPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return sess.notFoundInfo(fullPath), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat file %q: %w", conn.Path, err)
+	}
+	return sess.toFileInfo(fullPath, finfo), nil
+}
+
+func (c SftpClient) PutFile(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData) error {
+	return c.writeFile(ctx, conn, data, false)
+}
+
+func (c SftpClient) AppendFile(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData) error {
+	return c.writeFile(ctx, conn, data, true)
+}
+
+func (c SftpClient) writeFile(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData, appendData bool) error {
+	sess, err := getSession(ctx, conn.Host)
+	if err != nil {
+		return err
+	}
+	fullPath := sess.resolvePath(conn.Path)
+	dataBytes, err := base64.StdEncoding.DecodeString(data.Data64)
+	if err != nil {
+		return fmt.Errorf("cannot decode base64 data: %w", err)
+	}
+	createMode := fstype.FileMode
+	if data.Info != nil && data.Info.Mode > 0 {
+		createMode = data.Info.Mode
+	}
+	var atOffset int64
+	if data.At != nil {
+		atOffset = data.At.Offset
+	}
+	if appendData && atOffset > 0 {
+		return fmt.Errorf("cannot specify non-zero offset with append option")
+	}
+	openFlags := os.O_CREATE | os.O_WRONLY
+	if !appendData && data.At == nil {
+		openFlags |= os.O_TRUNC
+	}
+	finfo, statErr := sess.client.Stat(fullPath)
+	// not every server honors the append flag with an explicit offset, so appends are written at the current end
+	if appendData && statErr == nil {
+		atOffset = finfo.Size()
+	}
+	file, err := sess.client.OpenFile(fullPath, openFlags)
+	if err != nil {
+		return fmt.Errorf("cannot open file %q: %w", conn.Path, err)
+	}
+	defer utilfn.GracefulClose(file, "sftpfs:writeFile", fullPath)
+	// sftp open does not take a mode, new files are created with the server default
+	if errors.Is(statErr, fs.ErrNotExist) {
+		if err := file.Chmod(createMode.Perm()); err != nil {
+			log.Printf("sftpfs: cannot set mode of %q: %v", fullPath, err)
+		}
+	}
+	if _, err := file.WriteAt(dataBytes, atOffset); err != nil {
+		return fmt.Errorf("cannot write to file %q: %w", conn.Path, err)
+	}
+	return nil
+}
+
+func (c SftpClient) Mkdir(ctx context.Context, conn *connparse.Connection) error {
+	sess, err := getSession(ctx, conn.Host)
+	if err != nil {
+		return err
+	}
+	fullPath := sess.resolvePath(conn.Path)
+	if finfo, err := sess.client.Stat(fullPath); err == nil {
+		if finfo.IsDir() {
+			return fmt.Errorf("directory %q already exists", conn.Path)
+		}
+		return fmt.Errorf("cannot create directory %q, file exists at path", conn.Path)
+	}
+	if err := sess.client.MkdirAll(fullPath); err != nil {
+		return fmt.Errorf("cannot create directory %q: %w", conn.Path, err)
+	}
+	return nil
+}
+
+// determines the destination path of a copy or move, following the same rules as fsutil.DetermineCopyDestPath
+// (which cannot be used since it does not keep the paths absolute).  also returns the source info.
+func (s *session) copyDestPath(ctx context.Context, srcConn, destConn *connparse.Connection, srcClient fstype.FileShareClient, opts *wshrpc.FileCopyOpts) (string, *wshrpc.FileInfo, error) {
+	merge := opts != nil && opts.Merge
+	overwrite := opts != nil && opts.Overwrite
+	recursive := opts != nil && opts.Recursive
+	if overwrite && merge {
+		return "", nil, fmt.Errorf("cannot specify both overwrite and merge")
+	}
+	srcInfo, err := srcClient.Stat(ctx, srcConn)
+	if err != nil {
+		return "", nil, fmt.Errorf("error getting source file info: %w", err)
+	}
+	if srcInfo.NotFound {
+		return "", nil, fmt.Errorf("source file not found: %w", fs.ErrNotExist)
+	}
+	if srcInfo.IsDir && !recursive {
+		return "", nil, fmt.Errorf(fstype.RecursiveRequiredError)
+	}
+	destPath := s.resolvePath(destConn.Path)
+	destInfo, err := s.client.Stat(destPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", nil, fmt.Errorf("error getting destination file info: %w", err)
+	}
+	srcHasSlash := strings.HasSuffix(srcConn.Path, "/")
+	destHasSlash := strings.HasSuffix(destConn.Path, "/")
+	if destInfo != nil && destInfo.IsDir() && (!srcHasSlash || !srcInfo.IsDir) {
+		// copying into an existing directory
+		destPath = path.Join(destPath, path.Base(strings.TrimSuffix(srcConn.Path, "/")))
+		destInfo, err = s.client.Stat(destPath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", nil, fmt.Errorf("error getting destination file info: %w", err)
+		}
+	} else if destInfo == nil && destHasSlash && !srcInfo.IsDir {
+		destPath = path.Join(destPath, path.Base(srcConn.Path))
+		destInfo = nil
+	}
+	if destInfo != nil {
+		if destInfo.IsDir() {
+			if !srcInfo.IsDir {
+				return "", nil, fmt.Errorf("cannot copy file %q over directory %q", srcConn.Path, s.displayPath(destPath))
+			}
+			if !merge && !overwrite {
+				return "", nil, fmt.Errorf(fstype.MergeRequiredError, s.displayPath(destPath))
+			}
+		} else if !overwrite {
+			return "", nil, fmt.Errorf(fstype.OverwriteRequiredError, s.displayPath(destPath))
+		}
+		if overwrite {
+			if err := s.removeAll(destPath); err != nil {
+				return "", nil, fmt.Errorf("error deleting conflicting destination %q: %w", s.displayPath(destPath), err)
+			}
+		}
+	}
+	return destPath, srcInfo, nil
+}
+
+// a plain sftp rename fails if newPath exists, posix-rename (OpenSSH) replaces it
+func (s *session) rename(oldPath string, newPath string, replace bool) error {
+	if _, ok := s.client.HasExtension("posix-rename@openssh.com"); replace && ok {
+		return s.client.PosixRename(oldPath, newPath)
+	}
+	return s.client.Rename(oldPath, newPath)
+}
+
+func (c SftpClient) MoveInternal(ctx context.Context, srcConn, destConn *connparse.Connection, opts *wshrpc.FileCopyOpts) error {
+	sess, err := getSession(ctx, destConn.Host)
+	if err != nil {
+		return err
+	}
+	destPath, _, err := sess.copyDestPath(ctx, srcConn, destConn, c, opts)
+	if err != nil {
+		return err
+	}
+	srcPath := sess.resolvePath(srcConn.Path)
+	if err := sess.rename(srcPath, destPath, opts != nil && opts.Overwrite); err != nil {
+		return fmt.Errorf("cannot move file %q to %q: %w", srcConn.Path, destConn.Path, err)
+	}
+	return nil
+}
+
+// CopyInternal streams the files through this machine, sftp has no server side copy
+func (c SftpClient) CopyInternal(ctx context.Context, srcConn, destConn *connparse.Connection, opts *wshrpc.FileCopyOpts) (bool, error) {
+	return c.CopyRemote(ctx, srcConn, destConn, c, opts)
+}
+
+func (c SftpClient) CopyRemote(ctx context.Context, srcConn, destConn *connparse.Connection, srcClient fstype.FileShareClient, opts *wshrpc.FileCopyOpts) (bool, error) {
+	sess, err := getSession(ctx, destConn.Host)
+	if err != nil {
+		return false, err
+	}
+	destPath, srcInfo, err := sess.copyDestPath(ctx, srcConn, destConn, srcClient, opts)
+	if err != nil {
+		return false, err
+	}
+	// entries are named relative to the source dir if it has a trailing slash, otherwise they include its name
+	entryRoot := path.Dir(destPath)
+	if strings.HasSuffix(srcConn.Path, "/") {
+		entryRoot = destPath
+		if err := sess.client.MkdirAll(destPath); err != nil {
+			return false, fmt.Errorf("cannot create directory %q: %w", destConn.Path, err)
+		}
+	}
+	log.Printf("sftpfs: copying %v -> %v", srcConn.GetFullURI(), destConn.GetFullURI())
+	readCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	ioch := srcClient.ReadTarStream(readCtx, srcConn, opts)
+	err = tarcopy.TarCopyDest(readCtx, cancel, ioch, func(next *tar.Header, reader io.Reader, singleFile bool) error {
+		if singleFile && srcInfo.IsDir {
+			return fmt.Errorf("protocol error: source is a directory, but only a single file is being copied")
+		}
+		nextPath := path.Join(entryRoot, next.Name)
+		if singleFile {
+			nextPath = destPath
+		}
+		if next.Typeflag == tar.TypeDir {
+			return sess.client.MkdirAll(nextPath)
+		}
+		if err := sess.client.MkdirAll(path.Dir(nextPath)); err != nil {
+			return fmt.Errorf("cannot create directory %q: %w", sess.displayPath(path.Dir(nextPath)), err)
+		}
+		file, err := sess.client.OpenFile(nextPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
+		if err != nil {
+			return fmt.Errorf("cannot create file %q: %w", sess.displayPath(nextPath), err)
+		}
+		if err := file.Chmod(os.FileMode(next.Mode).Perm()); err != nil {
+			log.Printf("sftpfs: cannot set mode of %q: %v", nextPath, err)
+		}
+		_, err = file.ReadFrom(reader)
+		utilfn.GracefulClose(file, "sftpfs:CopyRemote", nextPath)
+		if err != nil {
+			return fmt.Errorf("cannot write file %q: %w", sess.displayPath(nextPath), err)
+		}
+		if err := sess.client.Chtimes(nextPath, next.ModTime, next.ModTime); err != nil {
+			log.Printf("sftpfs: cannot set mod time of %q: %v", nextPath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		cancel(err)
+		return false, err
+	}
+	return srcInfo.IsDir, nil
+}
+
+func (c SftpClient) Delete(ctx context.Context, conn *connparse.Connection, recursive bool) error {
+	sess, err := getSession(ctx, conn.Host)
+	if err != nil {
+		return err
+	}
+	fullPath := sess.resolvePath(conn.Path)
+	finfo, err := sess.client.Lstat(fullPath)
+	if err != nil {
+		return fmt.Errorf("cannot delete file %q: %w", conn.Path, err)
+	}
+	if !finfo.IsDir() {
+		if err := sess.client.Remove(fullPath); err != nil {
+			return fmt.Errorf("cannot delete file %q: %w", conn.Path, err)
+		}
+		return nil
+	}
+	if !recursive {
+		// an empty directory can be deleted without the recursive flag, like os.Remove
+		if err := sess.client.RemoveDirectory(fullPath); err != nil {
+			return fmt.Errorf(fstype.RecursiveRequiredError)
+		}
+		return nil
+	}
+	if err := sess.removeAll(fullPath); err != nil {
+		return fmt.Errorf("cannot delete directory %q: %w", conn.Path, err)
+	}
+	return nil
+}
+
+func (c SftpClient) Join(ctx context.Context, conn *connparse.Connection, parts ...string) (*wshrpc.FileInfo, error) {
+	joined := path.Join(append([]string{conn.Path}, parts...)...)
+	return c.Stat(ctx, &connparse.Connection{Scheme: conn.Scheme, Host: conn.Host, Path: joined})
+}
+
+func (c SftpClient) GetConnectionType() string {
+	return connparse.ConnectionTypeSftp
+}
+
+func (c SftpClient) GetCapability() wshrpc.FileShareCapability {
+	return wshrpc.FileShareCapability{CanAppend: true, CanMkdir: true}
+}
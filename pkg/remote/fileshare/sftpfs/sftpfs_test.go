@@ -0,0 +1,296 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package sftpfs
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/remote/connparse"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestResolvePath(t *testing.T) {
+	sess := &session{home: "/home/user"}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", "/home/user"},
+		{"~", "/home/user"},
+		{"~/docs/a.txt", "/home/user/docs/a.txt"},
+		{"docs/../a.txt", "/home/user/a.txt"},
+		{"/etc/hosts", "/etc/hosts"},
+		{"/var/log/", "/var/log"},
+	}
+	for _, tc := range tests {
+		if got := sess.resolvePath(tc.path); got != tc.want {
+			t.Errorf("resolvePath(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestDisplayPath(t *testing.T) {
+	tests := []struct {
+		home string
+		path string
+		want string
+	}{
+		{"/home/user", "/home/user", "~"},
+		{"/home/user", "/home/user/docs", "~/docs"},
+		{"/home/user", "/home/username", "/home/username"},
+		{"/home/user", "/etc", "/etc"},
+		{"/", "/etc", "/etc"},
+	}
+	for _, tc := range tests {
+		sess := &session{home: tc.home}
+		if got := sess.displayPath(tc.path); got != tc.want {
+			t.Errorf("displayPath(%q) with home %q = %q, want %q", tc.path, tc.home, got, tc.want)
+		}
+	}
+}
+
+const testConnName = "user@testhost"
+
+type pipeConn struct {
+	io.Reader
+	io.WriteCloser
+}
+
+// runs an in-process sftp server rooted at a temp dir and points the sessions at it, returns the home dir
+func startTestServer(t *testing.T) string {
+	home := t.TempDir()
+	sshClient := &ssh.Client{}
+	oldGetSshClient, oldNewSftpClient := getSshClient, newSftpClient
+	getSshClient = func(ctx context.Context, connName string) (*ssh.Client, error) {
+		return sshClient, nil
+	}
+	newSftpClient = func(client *ssh.Client) (*sftp.Client, error) {
+		return newTestSftpClient(t, home)
+	}
+	t.Cleanup(func() {
+		getSshClient, newSftpClient = oldGetSshClient, oldNewSftpClient
+		sessionsLock.Lock()
+		for connName, sess := range sessions {
+			sess.client.Close()
+			delete(sessions, connName)
+		}
+		sessionsLock.Unlock()
+	})
+	return home
+}
+
+func newTestSftpClient(t *testing.T, home string) (*sftp.Client, error) {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	server, err := sftp.NewServer(pipeConn{serverReader, serverWriter}, sftp.WithServerWorkingDirectory(home))
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		server.Serve()
+		// the client waits for its reader to end before Close returns
+		serverWriter.Close()
+	}()
+	return sftp.NewClientPipe(clientReader, clientWriter)
+}
+
+func testConn(p string) *connparse.Connection {
+	return &connparse.Connection{Scheme: connparse.ConnectionTypeWsh, Host: testConnName, Path: p}
+}
+
+func TestSftpReadWrite(t *testing.T) {
+	home := startTestServer(t)
+	ctx := context.Background()
+	client := SftpClient{}
+	content := []byte("hello sftp world")
+	if err := client.PutFile(ctx, testConn("~/a.txt"), wshrpc.FileData{Data64: base64.StdEncoding.EncodeToString(content)}); err != nil {
+		t.Fatalf("PutFile: %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(home, "a.txt")); err != nil || string(got) != string(content) {
+		t.Fatalf("file contents %q (err %v), want %q", got, err, content)
+	}
+	if err := client.AppendFile(ctx, testConn("a.txt"), wshrpc.FileData{Data64: base64.StdEncoding.EncodeToString([]byte("!"))}); err != nil {
+		t.Fatalf("AppendFile: %v", err)
+	}
+
+	info, err := client.Stat(ctx, testConn("~/a.txt"))
+	if err != nil || info.NotFound || info.Size != int64(len(content)+1) || info.Path != "~/a.txt" {
+		t.Fatalf("Stat: %#v (err %v)", info, err)
+	}
+	info, err = client.Stat(ctx, testConn("~/missing.txt"))
+	if err != nil || !info.NotFound {
+		t.Fatalf("Stat of a missing file: %#v (err %v)", info, err)
+	}
+
+	data, err := client.Read(ctx, testConn("~/a.txt"), wshrpc.FileData{})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if got, _ := base64.StdEncoding.DecodeString(data.Data64); string(got) != "hello sftp world!" {
+		t.Fatalf("Read returned %q", got)
+	}
+	data, err = client.Read(ctx, testConn("~/a.txt"), wshrpc.FileData{At: &wshrpc.FileDataAt{Offset: 6, Size: 4}})
+	if err != nil {
+		t.Fatalf("ranged Read: %v", err)
+	}
+	if got, _ := base64.StdEncoding.DecodeString(data.Data64); string(got) != "sftp" {
+		t.Fatalf("ranged Read returned %q, want %q", got, "sftp")
+	}
+}
+
+func TestSftpDirOps(t *testing.T) {
+	home := startTestServer(t)
+	ctx := context.Background()
+	client := SftpClient{}
+	if err := client.Mkdir(ctx, testConn("~/dir/sub")); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := client.Mkdir(ctx, testConn("~/dir/sub")); err == nil {
+		t.Fatalf("Mkdir of an existing dir succeeded")
+	}
+	for _, name := range []string{"dir/b.txt", "dir/sub/c.txt"} {
+		if err := os.WriteFile(filepath.Join(home, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := client.ListEntries(ctx, testConn("~/dir"), nil)
+	if err != nil {
+		t.Fatalf("ListEntries: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "b.txt,sub" {
+		t.Fatalf("ListEntries returned %v", names)
+	}
+	entries, err = client.ListEntries(ctx, testConn("~/dir"), &wshrpc.FileListOpts{All: true})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("recursive ListEntries returned %d entries (err %v), want 2 files", len(entries), err)
+	}
+
+	if err := client.MoveInternal(ctx, testConn("~/dir/b.txt"), testConn("~/dir/sub/"), nil); err != nil {
+		t.Fatalf("MoveInternal: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, "dir/sub/b.txt")); err != nil {
+		t.Fatalf("moved file not found: %v", err)
+	}
+	if err := client.MoveInternal(ctx, testConn("~/dir/sub/b.txt"), testConn("~/dir/sub/c.txt"), nil); err == nil {
+		t.Fatalf("move over an existing file succeeded without overwrite")
+	}
+
+	if err := client.Delete(ctx, testConn("~/dir"), false); err == nil {
+		t.Fatalf("non-recursive delete of a non-empty dir succeeded")
+	}
+	if err := client.Delete(ctx, testConn("~/dir"), true); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, "dir")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("dir still exists after delete: %v", err)
+	}
+}
+
+func TestSftpSessionReuse(t *testing.T) {
+	startTestServer(t)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	sessCh := make(chan *session, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sess, err := getSession(ctx, testConnName)
+			if err != nil {
+				t.Errorf("getSession: %v", err)
+				return
+			}
+			sessCh <- sess
+		}()
+	}
+	wg.Wait()
+	close(sessCh)
+	sessionsLock.Lock()
+	cached := sessions[testConnName]
+	sessionsLock.Unlock()
+	for sess := range sessCh {
+		if sess != cached {
+			t.Fatalf("concurrent getSession calls returned different sessions")
+		}
+	}
+
+	// a closed session is replaced
+	cached.client.Close()
+	for !cached.closed.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	sess, err := getSession(ctx, testConnName)
+	if err != nil || sess == cached {
+		t.Fatalf("closed session was not replaced (err %v)", err)
+	}
+}
+
+func TestSftpSlowHostDoesNotBlock(t *testing.T) {
+	home := startTestServer(t)
+	slowClient := &ssh.Client{}
+	unblock := make(chan struct{})
+	slowDone := make(chan struct{})
+	defer func() {
+		close(unblock)
+		<-slowDone
+	}()
+	getSshClient = func(ctx context.Context, connName string) (*ssh.Client, error) {
+		if connName == "user@slowhost" {
+			return slowClient, nil
+		}
+		return &ssh.Client{}, nil
+	}
+	newSftpClient = func(client *ssh.Client) (*sftp.Client, error) {
+		if client == slowClient {
+			<-unblock
+			return nil, fmt.Errorf("host did not answer")
+		}
+		return newTestSftpClient(t, home)
+	}
+	go func() {
+		defer close(slowDone)
+		getSession(context.Background(), "user@slowhost")
+	}()
+	done := make(chan error, 1)
+	go func() {
+		_, err := getSession(context.Background(), testConnName)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("getSession: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("a hung host blocked sessions for other connections")
+	}
+}
+
+func TestShouldUseSftp(t *testing.T) {
+	for _, connName := range []string{"", wshrpc.LocalConnName, "wsl://Ubuntu"} {
+		if ShouldUseSftp(connName) {
+			t.Errorf("ShouldUseSftp(%q) = true, want false", connName)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package fileshare
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/remote/connparse"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/filesync"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fstype"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fsutil"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshutil"
+)
+
+func hashFile(ctx context.Context, client fstype.FileShareClient, conn *connparse.Connection) ([]byte, error) {
+	hasher := sha256.New()
+	err := fsutil.ReadFileStreamToWriter(ctx, client.ReadStream(ctx, conn, wshrpc.FileData{}), hasher)
+	if err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
+// Sync makes the destination directory match the source directory, copying only the files that changed.
+// the actions are streamed as they are done (or all at once for a dry run), the last update has Done set.
+func Sync(ctx context.Context, data wshrpc.CommandFileSyncData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileSyncProgress] {
+	opts := data.Opts
+	if opts == nil {
+		opts = &wshrpc.FileSyncOpts{}
+	}
+	log.Printf("Sync: srcuri: %v, desturi: %v, opts: %v", data.SrcUri, data.DestUri, opts)
+	srcClient, srcConn := CreateFileShareClient(ctx, data.SrcUri)
+	if srcConn == nil || srcClient == nil {
+		return wshutil.SendErrCh[wshrpc.FileSyncProgress](fmt.Errorf("error creating fileshare client, could not parse source connection %s", data.SrcUri))
+	}
+	destClient, destConn := CreateFileShareClient(ctx, data.DestUri)
+	if destConn == nil || destClient == nil {
+		return wshutil.SendErrCh[wshrpc.FileSyncProgress](fmt.Errorf("error creating fileshare client, could not parse destination connection %s", data.DestUri))
+	}
+	excludes := opts.Exclude
+	if opts.GitIgnore {
+		excludes = append([]string{".git/"}, excludes...)
+	}
+	filter, err := filesync.MakeFilter(opts.Include, excludes)
+	if err != nil {
+		return wshutil.SendErrCh[wshrpc.FileSyncProgress](err)
+	}
+	ch := make(chan wshrpc.RespOrErrorUnion[wshrpc.FileSyncProgress], 16)
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("fileshare:Sync", recover())
+		}()
+		defer close(ch)
+		// nobody reads ch once the request is canceled, runSync stops at the next action
+		send := func(resp wshrpc.RespOrErrorUnion[wshrpc.FileSyncProgress]) {
+			select {
+			case ch <- resp:
+			case <-ctx.Done():
+			}
+		}
+		err := runSync(ctx, srcClient, srcConn, destClient, destConn, filter, opts, func(action wshrpc.FileSyncAction) {
+			send(wshrpc.RespOrErrorUnion[wshrpc.FileSyncProgress]{Response: wshrpc.FileSyncProgress{Action: &action}})
+		}, func(unchanged int) {
+			send(wshrpc.RespOrErrorUnion[wshrpc.FileSyncProgress]{Response: wshrpc.FileSyncProgress{Unchanged: unchanged, Done: true}})
+		})
+		if err != nil {
+			send(wshutil.RespErr[wshrpc.FileSyncProgress](err))
+		}
+	}()
+	return ch
+}
+
+func runSync(ctx context.Context, srcClient fstype.FileShareClient, srcConn *connparse.Connection, destClient fstype.FileShareClient, destConn *connparse.Connection, filter *filesync.Filter, opts *wshrpc.FileSyncOpts, actionFn func(wshrpc.FileSyncAction), doneFn func(int)) error {
+	srcInfo, err := srcClient.Stat(ctx, srcConn)
+	if err != nil {
+		return fmt.Errorf("cannot stat source %q: %w", srcConn.GetFullURI(), err)
+	}
+	if srcInfo.NotFound {
+		return fmt.Errorf("source %q not found", srcConn.GetFullURI())
+	}
+	if !srcInfo.IsDir {
+		return fmt.Errorf("source %q is not a directory", srcConn.GetFullURI())
+	}
+	srcFiles, err := filesync.ListTree(ctx, srcClient, srcConn, filter, opts.GitIgnore)
+	if err != nil {
+		return err
+	}
+	destFiles := make(map[string]*wshrpc.FileInfo)
+	destInfo, err := destClient.Stat(ctx, destConn)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("cannot stat destination %q: %w", destConn.GetFullURI(), err)
+	}
+	if err == nil && !destInfo.NotFound {
+		if !destInfo.IsDir {
+			return fmt.Errorf("destination %q is not a directory", destConn.GetFullURI())
+		}
+		// the .gitignore rules of the source also protect the matching destination files
+		destFiles, err = filesync.ListTree(ctx, destClient, destConn, filter, false)
+		if err != nil {
+			return err
+		}
+	}
+	actions, unchanged, err := filesync.Diff(srcFiles, destFiles, opts.Checksum, opts.Delete, func(relPath string) (bool, error) {
+		srcHash, err := hashFile(ctx, srcClient, filesync.ChildConn(srcConn, relPath))
+		if err != nil {
+			return false, err
+		}
+		destHash, err := hashFile(ctx, destClient, filesync.ChildConn(destConn, relPath))
+		if err != nil {
+			return false, err
+		}
+		return bytes.Equal(srcHash, destHash), nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, action := range actions {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if opts.DryRun {
+			actionFn(action)
+			continue
+		}
+		destFileConn := filesync.ChildConn(destConn, action.Path)
+		if action.Op == filesync.SyncOp_Delete {
+			if err := Delete(ctx, wshrpc.CommandDeleteFileData{Path: destFileConn.GetFullURI()}); err != nil {
+				return fmt.Errorf("cannot delete %q: %w", destFileConn.GetFullURI(), err)
+			}
+		} else {
+			copyData := wshrpc.CommandFileCopyData{
+				SrcUri:  filesync.ChildConn(srcConn, action.Path).GetFullURI(),
+				DestUri: destFileConn.GetFullURI(),
+				Opts:    &wshrpc.FileCopyOpts{Overwrite: true, Timeout: opts.Timeout},
+			}
+			if err := Copy(ctx, copyData); err != nil {
+				return fmt.Errorf("cannot copy %q: %w", action.Path, err)
+			}
+		}
+		actionFn(action)
+	}
+	doneFn(unchanged)
+	return nil
+}
@@ -0,0 +1,449 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package fileshare
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/remote/connparse"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fspath"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fstype"
+	"github.com/commandlinedev/starterm/pkg/sconfig"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/google/uuid"
+)
+
+// the trash of a connection (or bucket, or starfile zone) has an info/<id>.json file for every entry, and the content in files/<id>/<name>
+const (
+	// prefix filesystems (s3, starfile) do not allow paths starting with "."
+	TrashPrefix             = "starterm-trash"
+	DefaultTrashRetention   = 30
+	TrashPurgeInterval      = time.Hour
+	trashInfoDir            = "info"
+	trashFilesDir           = "files"
+	trashMoveTimeout        = 10 * time.Minute
+	trashBackgroundTimeout  = 5 * time.Minute
+	crossDeviceErrSubstring = "cross-device"
+)
+
+// the ids moveToTrash creates: the trash time and the start of a uuid
+var trashIdRe = regexp.MustCompile(`^\d{8}-\d{6}-[0-9a-f]{8}$`)
+
+var trashPurgeLock = &sync.Mutex{}
+var trashLastPurge = make(map[string]time.Time)
+
+func getSettings() sconfig.SettingsType {
+	watcher := sconfig.GetWatcher()
+	if watcher == nil {
+		return sconfig.SettingsType{}
+	}
+	return watcher.GetFullConfig().Settings
+}
+
+func trashEnabled() bool {
+	return getSettings().FileTrash
+}
+
+// 0 if entries are kept until the trash is emptied
+func trashRetention() time.Duration {
+	days := int64(DefaultTrashRetention)
+	if setting := getSettings().FileTrashRetentionDays; setting != nil {
+		days = *setting
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func trashRoot(client fstype.FileShareClient, conn *connparse.Connection) *connparse.Connection {
+	rootPath := TrashPrefix
+	switch client.GetConnectionType() {
+	case connparse.ConnectionTypeWsh, connparse.ConnectionTypeSftp:
+		rootPath = starbase.RemoteTrashDir
+	}
+	return &connparse.Connection{Scheme: conn.Scheme, Host: conn.Host, Path: rootPath}
+}
+
+func childConn(conn *connparse.Connection, parts ...string) *connparse.Connection {
+	return &connparse.Connection{Scheme: conn.Scheme, Host: conn.Host, Path: fspath.Join(append([]string{conn.Path}, parts...)...)}
+}
+
+// returns nil (and no error) if nothing is at conn
+func statOrNil(ctx context.Context, client fstype.FileShareClient, conn *connparse.Connection) (*wshrpc.FileInfo, error) {
+	finfo, err := client.Stat(ctx, conn)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if finfo == nil || finfo.NotFound {
+		return nil, nil
+	}
+	return finfo, nil
+}
+
+// creates the directory if the fileshare has directories
+func ensureDir(ctx context.Context, client fstype.FileShareClient, conn *connparse.Connection) error {
+	if !client.GetCapability().CanMkdir {
+		return nil
+	}
+	finfo, err := statOrNil(ctx, client, conn)
+	if err != nil {
+		return err
+	}
+	if finfo != nil {
+		return nil
+	}
+	return client.Mkdir(ctx, conn)
+}
+
+// moves src to the exact path dest (which must not exist), directories are moved with their contents
+func moveExact(ctx context.Context, client fstype.FileShareClient, srcConn, destConn *connparse.Connection, isDir bool) error {
+	src := *srcConn
+	if isDir && !strings.HasSuffix(src.Path, fspath.Separator) {
+		// prefix filesystems would otherwise move the directory into dest
+		src.Path += fspath.Separator
+	}
+	if parent := fspath.Dir(destConn.Path); parent != "" && parent != "." {
+		if err := ensureDir(ctx, client, &connparse.Connection{Scheme: destConn.Scheme, Host: destConn.Host, Path: parent}); err != nil {
+			return fmt.Errorf("cannot create directory %q: %w", parent, err)
+		}
+	}
+	opts := &wshrpc.FileCopyOpts{Recursive: true, Timeout: trashMoveTimeout.Milliseconds()}
+	err := client.MoveInternal(ctx, &src, destConn, opts)
+	if err != nil && strings.Contains(err.Error(), crossDeviceErrSubstring) {
+		// the trash is on another filesystem than the file, a rename does not work
+		if _, err = client.CopyInternal(ctx, &src, destConn, opts); err == nil {
+			err = client.Delete(ctx, srcConn, isDir)
+		}
+	}
+	return err
+}
+
+// ids come from the caller and become paths in the trash, anything but an id moveToTrash creates could point outside of it
+func validateTrashId(id string) error {
+	if !trashIdRe.MatchString(id) {
+		return fmt.Errorf("invalid trash entry id %q", id)
+	}
+	return nil
+}
+
+func readTrashEntry(ctx context.Context, client fstype.FileShareClient, root *connparse.Connection, id string) (*wshrpc.TrashEntry, error) {
+	if err := validateTrashId(id); err != nil {
+		return nil, err
+	}
+	fileData, err := client.Read(ctx, childConn(root, trashInfoDir, id+".json"), wshrpc.FileData{})
+	if err != nil {
+		return nil, err
+	}
+	content, err := base64.StdEncoding.DecodeString(fileData.Data64)
+	if err != nil {
+		return nil, err
+	}
+	var entry wshrpc.TrashEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, err
+	}
+	entry.Id = id
+	return &entry, nil
+}
+
+// returns the content of the entry in the trash, and the directory it is in
+func trashContent(root *connparse.Connection, entry *wshrpc.TrashEntry) (*connparse.Connection, *connparse.Connection, error) {
+	if err := validateTrashId(entry.Id); err != nil {
+		return nil, nil, err
+	}
+	origConn, err := connparse.ParseURI(entry.Uri)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse trashed uri %q: %w", entry.Uri, err)
+	}
+	entryDir := childConn(root, trashFilesDir, entry.Id)
+	return childConn(entryDir, fspath.Base(strings.TrimSuffix(origConn.Path, fspath.Separator))), entryDir, nil
+}
+
+// moveToTrash moves what is at conn to the trash of its connection.  returns false if nothing was moved: if there is nothing at conn,
+// if it is a directory and recursive is not set, or if it is in the trash already (deleting it there is permanent).
+func moveToTrash(ctx context.Context, client fstype.FileShareClient, conn *connparse.Connection, reason string, recursive bool) (bool, error) {
+	finfo, err := statOrNil(ctx, client, conn)
+	if err != nil || finfo == nil || (finfo.IsDir && !recursive) {
+		// the delete (or copy) reports the error
+		return false, nil
+	}
+	root := trashRoot(client, conn)
+	rootInfo, err := statOrNil(ctx, client, root)
+	if err != nil {
+		return false, fmt.Errorf("cannot stat trash %q: %w", root.GetFullURI(), err)
+	}
+	if rootInfo != nil && (finfo.Path == rootInfo.Path || strings.HasPrefix(finfo.Path, strings.TrimSuffix(rootInfo.Path, fspath.Separator)+fspath.Separator)) {
+		return false, nil
+	}
+	entry := &wshrpc.TrashEntry{
+		Id:        time.Now().UTC().Format("20060102-150405") + "-" + uuid.NewString()[:8],
+		Uri:       conn.GetFullURI(),
+		IsDir:     finfo.IsDir,
+		Reason:    reason,
+		TrashedTs: time.Now().UnixMilli(),
+	}
+	if !finfo.IsDir {
+		entry.Size = finfo.Size
+	}
+	infoJson, err := json.Marshal(entry)
+	if err != nil {
+		return false, err
+	}
+	if err := ensureDir(ctx, client, childConn(root, trashInfoDir)); err != nil {
+		return false, fmt.Errorf("cannot create trash %q: %w", root.GetFullURI(), err)
+	}
+	infoConn := childConn(root, trashInfoDir, entry.Id+".json")
+	if err := client.PutFile(ctx, infoConn, wshrpc.FileData{Data64: base64.StdEncoding.EncodeToString(infoJson)}); err != nil {
+		return false, fmt.Errorf("cannot write trash info: %w", err)
+	}
+	contentConn, _, err := trashContent(root, entry)
+	if err != nil {
+		return false, err
+	}
+	log.Printf("moving %s to trash %s\n", entry.Uri, contentConn.GetFullURI())
+	if err := moveExact(ctx, client, conn, contentConn, finfo.IsDir); err != nil {
+		client.Delete(ctx, infoConn, false)
+		return false, fmt.Errorf("cannot move %q to the trash: %w", entry.Uri, err)
+	}
+	purgeTrashInBackground(client, root)
+	return true, nil
+}
+
+// a resumed copy keeps what is at the destination
+func shouldTrashOverwrite(opts *wshrpc.FileCopyOpts) bool {
+	return opts.Overwrite && !opts.Resume && trashEnabled()
+}
+
+// trashCopyDest moves what a copy (or move) with overwrite would replace to the trash.  the destination path follows the same rules as fsutil.DetermineCopyDestPath.
+func trashCopyDest(ctx context.Context, srcClient fstype.FileShareClient, srcConn *connparse.Connection, destClient fstype.FileShareClient, destConn *connparse.Connection) error {
+	srcInfo, err := statOrNil(ctx, srcClient, srcConn)
+	if err != nil || srcInfo == nil {
+		return nil
+	}
+	destInfo, err := statOrNil(ctx, destClient, destConn)
+	if err != nil {
+		return nil
+	}
+	target := destConn
+	if !strings.HasSuffix(srcConn.Path, fspath.Separator) {
+		if (destInfo != nil && destInfo.IsDir) || (destInfo == nil && !strings.HasSuffix(destConn.Path, fspath.Separator) && srcInfo.IsDir) {
+			target = childConn(destConn, fspath.Base(srcConn.Path))
+		}
+	}
+	_, err = moveToTrash(ctx, destClient, target, wshrpc.TrashReason_Overwrite, true)
+	return err
+}
+
+func listTrash(ctx context.Context, client fstype.FileShareClient, root *connparse.Connection) ([]*wshrpc.TrashEntry, error) {
+	infoDir := childConn(root, trashInfoDir)
+	finfo, err := statOrNil(ctx, client, infoDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat trash %q: %w", root.GetFullURI(), err)
+	}
+	if finfo == nil {
+		return nil, nil
+	}
+	infoEntries, err := client.ListEntries(ctx, infoDir, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list trash %q: %w", root.GetFullURI(), err)
+	}
+	var entries []*wshrpc.TrashEntry
+	for _, infoEntry := range infoEntries {
+		name := infoEntry.Name
+		if name == "" {
+			name = fspath.Base(infoEntry.Path)
+		}
+		id, ok := strings.CutSuffix(name, ".json")
+		if !ok || infoEntry.IsDir {
+			continue
+		}
+		entry, err := readTrashEntry(ctx, client, root, id)
+		if err != nil {
+			log.Printf("cannot read trash entry %q: %v\n", id, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].TrashedTs < entries[j].TrashedTs
+	})
+	return entries, nil
+}
+
+func deleteTrashEntry(ctx context.Context, client fstype.FileShareClient, root *connparse.Connection, entry *wshrpc.TrashEntry) error {
+	_, entryDir, err := trashContent(root, entry)
+	if err == nil {
+		if finfo, _ := statOrNil(ctx, client, entryDir); finfo != nil {
+			err = client.Delete(ctx, entryDir, true)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("cannot delete trash entry %q: %w", entry.Id, err)
+	}
+	return client.Delete(ctx, childConn(root, trashInfoDir, entry.Id+".json"), false)
+}
+
+// deletes the entries that are older than file:trashretentiondays
+func purgeTrash(ctx context.Context, client fstype.FileShareClient, root *connparse.Connection) error {
+	retention := trashRetention()
+	if retention <= 0 {
+		return nil
+	}
+	entries, err := listTrash(ctx, client, root)
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-retention).UnixMilli()
+	for _, entry := range entries {
+		if entry.TrashedTs >= cutoff {
+			break
+		}
+		if err := deleteTrashEntry(ctx, client, root, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purges the trash at most once per TrashPurgeInterval
+func purgeTrashInBackground(client fstype.FileShareClient, root *connparse.Connection) {
+	rootUri := root.GetFullURI()
+	trashPurgeLock.Lock()
+	if time.Since(trashLastPurge[rootUri]) < TrashPurgeInterval {
+		trashPurgeLock.Unlock()
+		return
+	}
+	trashLastPurge[rootUri] = time.Now()
+	trashPurgeLock.Unlock()
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("fileshare:purgeTrash", recover())
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), trashBackgroundTimeout)
+		defer cancel()
+		if err := purgeTrash(ctx, client, root); err != nil {
+			log.Printf("error purging trash %s: %v\n", rootUri, err)
+		}
+	}()
+}
+
+func trashForUri(ctx context.Context, uri string) (fstype.FileShareClient, *connparse.Connection, error) {
+	client, conn := CreateFileShareClient(ctx, uri)
+	if conn == nil || client == nil {
+		return nil, nil, fmt.Errorf(ErrorParsingConnection, uri)
+	}
+	return client, trashRoot(client, conn), nil
+}
+
+// TrashList returns the entries in the trash of the connection (or bucket) of data.Uri, oldest first
+func TrashList(ctx context.Context, data wshrpc.CommandFileTrashData) ([]*wshrpc.TrashEntry, error) {
+	client, root, err := trashForUri(ctx, data.Uri)
+	if err != nil {
+		return nil, err
+	}
+	if err := purgeTrash(ctx, client, root); err != nil {
+		log.Printf("error purging trash %s: %v\n", root.GetFullURI(), err)
+	}
+	return listTrash(ctx, client, root)
+}
+
+// TrashRestore moves the entries back to where they were (or a single entry to data.DestUri), nothing may be there now
+func TrashRestore(ctx context.Context, data wshrpc.CommandFileTrashData) error {
+	client, root, err := trashForUri(ctx, data.Uri)
+	if err != nil {
+		return err
+	}
+	return restoreTrash(ctx, client, root, data)
+}
+
+func restoreTrash(ctx context.Context, client fstype.FileShareClient, root *connparse.Connection, data wshrpc.CommandFileTrashData) error {
+	if len(data.Ids) == 0 {
+		return fmt.Errorf("no trash entries to restore")
+	}
+	if data.DestUri != "" && len(data.Ids) > 1 {
+		return fmt.Errorf("only a single trash entry can be restored to a new location")
+	}
+	for _, id := range data.Ids {
+		entry, err := readTrashEntry(ctx, client, root, id)
+		if err != nil {
+			return fmt.Errorf("cannot read trash entry %q: %w", id, err)
+		}
+		destUri := entry.Uri
+		if data.DestUri != "" {
+			destUri = data.DestUri
+		}
+		destConn, err := connparse.ParseURIAndReplaceCurrentHost(ctx, destUri)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q: %w", destUri, err)
+		}
+		if destConn.Scheme != root.Scheme || destConn.Host != root.Host {
+			return fmt.Errorf("cannot restore %q to %q: entries can only be restored on their own connection", id, destUri)
+		}
+		destInfo, err := statOrNil(ctx, client, destConn)
+		if err != nil {
+			return fmt.Errorf("cannot stat %q: %w", destUri, err)
+		}
+		if destInfo != nil {
+			return fmt.Errorf("cannot restore %q: %q already exists", id, destUri)
+		}
+		contentConn, _, err := trashContent(root, entry)
+		if err != nil {
+			return err
+		}
+		if err := moveExact(ctx, client, contentConn, destConn, entry.IsDir); err != nil {
+			return fmt.Errorf("cannot restore %q to %q: %w", id, destUri, err)
+		}
+		if err := deleteTrashEntry(ctx, client, root, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TrashEmpty permanently deletes the given entries, or all entries if data.Ids is empty.  returns the number of entries deleted
+func TrashEmpty(ctx context.Context, data wshrpc.CommandFileTrashData) (int, error) {
+	client, root, err := trashForUri(ctx, data.Uri)
+	if err != nil {
+		return 0, err
+	}
+	return emptyTrash(ctx, client, root, data.Ids)
+}
+
+func emptyTrash(ctx context.Context, client fstype.FileShareClient, root *connparse.Connection, ids []string) (int, error) {
+	var entries []*wshrpc.TrashEntry
+	var err error
+	if len(ids) == 0 {
+		if entries, err = listTrash(ctx, client, root); err != nil {
+			return 0, err
+		}
+	} else {
+		for _, id := range ids {
+			entry, err := readTrashEntry(ctx, client, root, id)
+			if err != nil {
+				return 0, fmt.Errorf("cannot read trash entry %q: %w", id, err)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	for idx, entry := range entries {
+		if err := deleteTrashEntry(ctx, client, root, entry); err != nil {
+			return idx, err
+		}
+	}
+	return len(entries), nil
+}
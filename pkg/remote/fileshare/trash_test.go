@@ -0,0 +1,370 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package fileshare
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/remote/connparse"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fstype"
+	"github.com/commandlinedev/starterm/pkg/util/iochan/iochantypes"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+// a wsh fileshare backed by a temp dir ("~" is the temp dir), enough of the FileShareClient for the trash
+type testFs struct {
+	home string
+}
+
+var _ fstype.FileShareClient = (*testFs)(nil)
+
+func (c *testFs) osPath(conn *connparse.Connection) string {
+	return filepath.Join(c.home, strings.TrimPrefix(conn.Path, "~"))
+}
+
+func (c *testFs) Stat(ctx context.Context, conn *connparse.Connection) (*wshrpc.FileInfo, error) {
+	osPath := c.osPath(conn)
+	finfo, err := os.Stat(osPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &wshrpc.FileInfo{Path: osPath, NotFound: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wshrpc.FileInfo{Path: osPath, Name: finfo.Name(), IsDir: finfo.IsDir(), Size: finfo.Size(), ModTime: finfo.ModTime().UnixMilli()}, nil
+}
+
+func (c *testFs) Read(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData) (*wshrpc.FileData, error) {
+	content, err := os.ReadFile(c.osPath(conn))
+	if err != nil {
+		return nil, err
+	}
+	return &wshrpc.FileData{Data64: base64.StdEncoding.EncodeToString(content)}, nil
+}
+
+func (c *testFs) ReadStream(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileData] {
+	return nil
+}
+
+func (c *testFs) ReadTarStream(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileCopyOpts) <-chan wshrpc.RespOrErrorUnion[iochantypes.Packet] {
+	return nil
+}
+
+func (c *testFs) ListEntries(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileListOpts) ([]*wshrpc.FileInfo, error) {
+	dirEntries, err := os.ReadDir(c.osPath(conn))
+	if err != nil {
+		return nil, err
+	}
+	var rtn []*wshrpc.FileInfo
+	for _, dirEntry := range dirEntries {
+		rtn = append(rtn, &wshrpc.FileInfo{Path: filepath.Join(c.osPath(conn), dirEntry.Name()), Name: dirEntry.Name(), IsDir: dirEntry.IsDir()})
+	}
+	return rtn, nil
+}
+
+func (c *testFs) ListEntriesStream(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileListOpts) <-chan wshrpc.RespOrErrorUnion[wshrpc.CommandRemoteListEntriesRtnData] {
+	return nil
+}
+
+func (c *testFs) PutFile(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData) error {
+	content, err := base64.StdEncoding.DecodeString(data.Data64)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.osPath(conn), content, 0644)
+}
+
+func (c *testFs) AppendFile(ctx context.Context, conn *connparse.Connection, data wshrpc.FileData) error {
+	return errors.ErrUnsupported
+}
+
+func (c *testFs) Mkdir(ctx context.Context, conn *connparse.Connection) error {
+	return os.MkdirAll(c.osPath(conn), 0755)
+}
+
+func (c *testFs) MoveInternal(ctx context.Context, srcConn, destConn *connparse.Connection, opts *wshrpc.FileCopyOpts) error {
+	return os.Rename(c.osPath(srcConn), c.osPath(destConn))
+}
+
+func (c *testFs) CopyInternal(ctx context.Context, srcConn, destConn *connparse.Connection, opts *wshrpc.FileCopyOpts) (bool, error) {
+	return false, errors.ErrUnsupported
+}
+
+func (c *testFs) CopyRemote(ctx context.Context, srcConn, destConn *connparse.Connection, srcClient fstype.FileShareClient, opts *wshrpc.FileCopyOpts) (bool, error) {
+	return false, errors.ErrUnsupported
+}
+
+func (c *testFs) Delete(ctx context.Context, conn *connparse.Connection, recursive bool) error {
+	if recursive {
+		return os.RemoveAll(c.osPath(conn))
+	}
+	return os.Remove(c.osPath(conn))
+}
+
+func (c *testFs) Join(ctx context.Context, conn *connparse.Connection, parts ...string) (*wshrpc.FileInfo, error) {
+	return c.Stat(ctx, childConn(conn, parts...))
+}
+
+func (c *testFs) GetConnectionType() string {
+	return connparse.ConnectionTypeWsh
+}
+
+func (c *testFs) GetCapability() wshrpc.FileShareCapability {
+	return wshrpc.FileShareCapability{CanMkdir: true}
+}
+
+// returns the client and its trash root, with the background purge turned off (tests purge explicitly)
+func makeTestFs(t *testing.T) (*testFs, *connparse.Connection) {
+	client := &testFs{home: t.TempDir()}
+	root := trashRoot(client, testConn(""))
+	trashPurgeLock.Lock()
+	trashLastPurge[root.GetFullURI()] = time.Now().Add(time.Hour)
+	trashPurgeLock.Unlock()
+	t.Cleanup(func() {
+		trashPurgeLock.Lock()
+		delete(trashLastPurge, root.GetFullURI())
+		trashPurgeLock.Unlock()
+	})
+	return client, root
+}
+
+func testConn(path string) *connparse.Connection {
+	return &connparse.Connection{Scheme: connparse.ConnectionTypeWsh, Host: "testhost", Path: path}
+}
+
+func writeTestFile(t *testing.T, client *testFs, path string, content string) {
+	osPath := client.osPath(testConn(path))
+	if err := os.MkdirAll(filepath.Dir(osPath), 0755); err != nil {
+		t.Fatalf("error creating dir for %s: %v", path, err)
+	}
+	if err := os.WriteFile(osPath, []byte(content), 0644); err != nil {
+		t.Fatalf("error writing %s: %v", path, err)
+	}
+}
+
+func testExists(client *testFs, path string) bool {
+	_, err := os.Stat(client.osPath(testConn(path)))
+	return err == nil
+}
+
+// the original paths of the entries in the trash
+func trashedPaths(t *testing.T, client *testFs, root *connparse.Connection) []string {
+	entries, err := listTrash(context.Background(), client, root)
+	if err != nil {
+		t.Fatalf("error listing trash: %v", err)
+	}
+	var rtn []string
+	for _, entry := range entries {
+		conn, err := connparse.ParseURI(entry.Uri)
+		if err != nil {
+			t.Fatalf("error parsing trashed uri %q: %v", entry.Uri, err)
+		}
+		rtn = append(rtn, conn.Path)
+	}
+	sort.Strings(rtn)
+	return rtn
+}
+
+func TestMoveToTrash(t *testing.T) {
+	ctx := context.Background()
+	client, root := makeTestFs(t)
+	writeTestFile(t, client, "~/docs/a.txt", "hello")
+	writeTestFile(t, client, "~/docs/sub/b.txt", "world")
+
+	moved, err := moveToTrash(ctx, client, testConn("~/docs/missing.txt"), wshrpc.TrashReason_Delete, false)
+	if err != nil || moved {
+		t.Fatalf("missing file: moved %v err %v", moved, err)
+	}
+	moved, err = moveToTrash(ctx, client, testConn("~/docs/sub"), wshrpc.TrashReason_Delete, false)
+	if err != nil || moved || !testExists(client, "~/docs/sub/b.txt") {
+		t.Fatalf("directory without recursive: moved %v err %v", moved, err)
+	}
+	moved, err = moveToTrash(ctx, client, testConn("~/docs/a.txt"), wshrpc.TrashReason_Delete, false)
+	if err != nil || !moved || testExists(client, "~/docs/a.txt") {
+		t.Fatalf("file: moved %v err %v", moved, err)
+	}
+	moved, err = moveToTrash(ctx, client, testConn("~/docs/sub"), wshrpc.TrashReason_Delete, true)
+	if err != nil || !moved || testExists(client, "~/docs/sub") {
+		t.Fatalf("directory: moved %v err %v", moved, err)
+	}
+
+	entries, err := listTrash(ctx, client, root)
+	if err != nil {
+		t.Fatalf("error listing trash: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d trash entries, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		contentConn, _, err := trashContent(root, entry)
+		if err != nil {
+			t.Fatalf("error getting trash content: %v", err)
+		}
+		// deleting what is already in the trash is permanent
+		moved, err = moveToTrash(ctx, client, contentConn, wshrpc.TrashReason_Delete, true)
+		if err != nil || moved {
+			t.Fatalf("already in the trash: moved %v err %v", moved, err)
+		}
+	}
+	moved, err = moveToTrash(ctx, client, root, wshrpc.TrashReason_Delete, true)
+	if err != nil || moved {
+		t.Fatalf("trash itself: moved %v err %v", moved, err)
+	}
+	// a sibling whose name starts with the trash dir name is not in the trash
+	writeTestFile(t, client, root.Path+"-old/c.txt", "x")
+	moved, err = moveToTrash(ctx, client, testConn(root.Path+"-old/c.txt"), wshrpc.TrashReason_Delete, false)
+	if err != nil || !moved {
+		t.Fatalf("trash sibling: moved %v err %v", moved, err)
+	}
+}
+
+func TestTrashCopyDest(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		dest string
+		want []string
+	}{
+		{"file to file", "~/src/a.txt", "~/out/a.txt", []string{"~/out/a.txt"}},
+		{"file to dir", "~/src/a.txt", "~/out", []string{"~/out/a.txt"}},
+		{"file to missing", "~/src/a.txt", "~/out/new.txt", nil},
+		{"dir to dir", "~/src/dir", "~/out", []string{"~/out/dir"}},
+		{"dir contents to dir", "~/src/dir/", "~/out/dir", []string{"~/out/dir"}},
+		{"dir to file", "~/src/dir", "~/out/a.txt", []string{"~/out/a.txt"}},
+		{"dir to missing", "~/src/dir", "~/out/newdir", nil},
+		{"missing source", "~/src/missing.txt", "~/out/a.txt", nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, root := makeTestFs(t)
+			writeTestFile(t, client, "~/src/a.txt", "new")
+			writeTestFile(t, client, "~/src/dir/b.txt", "new")
+			writeTestFile(t, client, "~/out/a.txt", "old")
+			writeTestFile(t, client, "~/out/dir/b.txt", "old")
+			err := trashCopyDest(context.Background(), client, testConn(tc.src), client, testConn(tc.dest))
+			if err != nil {
+				t.Fatalf("error trashing copy destination: %v", err)
+			}
+			got := trashedPaths(t, client, root)
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("trashed %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRestoreTrash(t *testing.T) {
+	ctx := context.Background()
+	client, root := makeTestFs(t)
+	writeTestFile(t, client, "~/docs/a.txt", "first")
+	if _, err := moveToTrash(ctx, client, testConn("~/docs/a.txt"), wshrpc.TrashReason_Delete, false); err != nil {
+		t.Fatalf("error trashing: %v", err)
+	}
+	entries, err := listTrash(ctx, client, root)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("got %d trash entries (err %v), want 1", len(entries), err)
+	}
+	id := entries[0].Id
+
+	// something new is at the original path
+	writeTestFile(t, client, "~/docs/a.txt", "second")
+	err = restoreTrash(ctx, client, root, wshrpc.CommandFileTrashData{Ids: []string{id}})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected an already exists error, got %v", err)
+	}
+	otherHost := testConn("~/docs/a2.txt")
+	otherHost.Host = "otherhost"
+	err = restoreTrash(ctx, client, root, wshrpc.CommandFileTrashData{Ids: []string{id}, DestUri: otherHost.GetFullURI()})
+	if err == nil {
+		t.Fatalf("expected an error restoring to another connection")
+	}
+	err = restoreTrash(ctx, client, root, wshrpc.CommandFileTrashData{Ids: []string{id, id}, DestUri: testConn("~/docs/a2.txt").GetFullURI()})
+	if err == nil {
+		t.Fatalf("expected an error restoring several entries to one destination")
+	}
+
+	err = restoreTrash(ctx, client, root, wshrpc.CommandFileTrashData{Ids: []string{id}, DestUri: testConn("~/restored/a.txt").GetFullURI()})
+	if err != nil {
+		t.Fatalf("error restoring: %v", err)
+	}
+	content, err := os.ReadFile(client.osPath(testConn("~/restored/a.txt")))
+	if err != nil || string(content) != "first" {
+		t.Fatalf("restored content %q (err %v), want first", content, err)
+	}
+	if got := trashedPaths(t, client, root); len(got) != 0 {
+		t.Fatalf("trash still has %q after restore", got)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	ctx := context.Background()
+	client, root := makeTestFs(t)
+	for _, name := range []string{"old.txt", "recent.txt"} {
+		writeTestFile(t, client, "~/"+name, name)
+		if _, err := moveToTrash(ctx, client, testConn("~/"+name), wshrpc.TrashReason_Delete, false); err != nil {
+			t.Fatalf("error trashing %s: %v", name, err)
+		}
+	}
+	entries, err := listTrash(ctx, client, root)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("got %d trash entries (err %v), want 2", len(entries), err)
+	}
+	// age the first entry past the (default) retention
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Uri, "old.txt") {
+			continue
+		}
+		entry.TrashedTs = time.Now().Add(-(DefaultTrashRetention + 1) * 24 * time.Hour).UnixMilli()
+		infoJson, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatalf("error marshalling trash entry: %v", err)
+		}
+		if err := os.WriteFile(client.osPath(childConn(root, trashInfoDir, entry.Id+".json")), infoJson, 0644); err != nil {
+			t.Fatalf("error writing trash entry: %v", err)
+		}
+	}
+	if err := purgeTrash(ctx, client, root); err != nil {
+		t.Fatalf("error purging trash: %v", err)
+	}
+	if got := trashedPaths(t, client, root); strings.Join(got, ",") != "~/recent.txt" {
+		t.Fatalf("trash has %q after purge, want only recent.txt", got)
+	}
+	dirEntries, err := os.ReadDir(client.osPath(childConn(root, trashFilesDir)))
+	if err != nil || len(dirEntries) != 1 {
+		t.Fatalf("trash files dir has %d entries (err %v), want 1", len(dirEntries), err)
+	}
+}
+
+func TestTrashIdTraversal(t *testing.T) {
+	ctx := context.Background()
+	client, root := makeTestFs(t)
+	writeTestFile(t, client, "~/victim.json", `{"uri":"wsh://testhost/~/victim.txt"}`)
+	writeTestFile(t, client, "~/victim.txt", "keep")
+	rootDepth := strings.Count(strings.TrimPrefix(root.Path, "~/"), "/") + 1
+	// info/<id>.json and files/<id> both resolve to ~/victim* with this id
+	traversalId := strings.Repeat("../", rootDepth+1) + "victim"
+	for _, id := range []string{traversalId, "a/b", `a\b`, "..", "not-an-id"} {
+		err := restoreTrash(ctx, client, root, wshrpc.CommandFileTrashData{Ids: []string{id}, DestUri: testConn("~/restored.txt").GetFullURI()})
+		if err == nil || !strings.Contains(err.Error(), "invalid trash entry id") {
+			t.Fatalf("restore %q: expected an invalid id error, got %v", id, err)
+		}
+		n, err := emptyTrash(ctx, client, root, []string{id})
+		if err == nil || n != 0 {
+			t.Fatalf("empty %q: deleted %d, expected an invalid id error, got %v", id, n, err)
+		}
+	}
+	if !testExists(client, "~/victim.json") || !testExists(client, "~/victim.txt") || testExists(client, "~/restored.txt") {
+		t.Fatalf("files outside the trash were changed")
+	}
+}
@@ -6,7 +6,9 @@ package wshfs
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/commandlinedev/starterm/pkg/panichandler"
 	"github.com/commandlinedev/starterm/pkg/remote/connparse"
 	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fstype"
 	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fsutil"
@@ -42,11 +44,19 @@ func (c WshClient) ReadStream(ctx context.Context, conn *connparse.Connection, d
 }
 
 func (c WshClient) ReadTarStream(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileCopyOpts) <-chan wshrpc.RespOrErrorUnion[iochantypes.Packet] {
+	return c.ReadTarStreamWithResume(ctx, conn, opts, nil)
+}
+
+// ReadTarStreamWithResume is ReadTarStream, skipping the files (or the parts of files) that are already at the destination
+func (c WshClient) ReadTarStreamWithResume(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileCopyOpts, resume map[string]wshrpc.FileResumeInfo) <-chan wshrpc.RespOrErrorUnion[iochantypes.Packet] {
+	if opts == nil {
+		opts = &wshrpc.FileCopyOpts{}
+	}
 	timeout := opts.Timeout
 	if timeout == 0 {
 		timeout = fstype.DefaultTimeout.Milliseconds()
 	}
-	return wshclient.RemoteTarStreamCommand(RpcClient, wshrpc.CommandRemoteStreamTarData{Path: conn.Path, Opts: opts}, &wshrpc.RpcOpts{Route: wshutil.MakeConnectionRouteId(conn.Host), Timeout: timeout})
+	return wshclient.RemoteTarStreamCommand(RpcClient, wshrpc.CommandRemoteStreamTarData{Path: conn.Path, Opts: opts, Resume: resume}, &wshrpc.RpcOpts{Route: wshutil.MakeConnectionRouteId(conn.Host), Timeout: timeout})
 }
 
 func (c WshClient) ListEntries(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileListOpts) ([]*wshrpc.FileInfo, error) {
@@ -129,6 +139,75 @@ func (c WshClient) CopyInternal(ctx context.Context, srcConn, destConn *connpars
 	return wshclient.RemoteFileCopyCommand(RpcClient, wshrpc.CommandFileCopyData{SrcUri: srcConn.GetFullURI(), DestUri: destConn.GetFullURI(), Opts: opts}, &wshrpc.RpcOpts{Route: wshutil.MakeConnectionRouteId(destConn.Host), Timeout: timeout})
 }
 
+// forwards a stream from the connection until ctx is done, the stream on the connection is canceled then
+func forwardStream[T any](ctx context.Context, name string, remoteCh <-chan wshrpc.RespOrErrorUnion[T], rpcOpts *wshrpc.RpcOpts) <-chan wshrpc.RespOrErrorUnion[T] {
+	ch := make(chan wshrpc.RespOrErrorUnion[T], 16)
+	go func() {
+		defer func() {
+			panichandler.PanicHandler(name, recover())
+		}()
+		defer close(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				if rpcOpts.StreamCancelFn != nil {
+					rpcOpts.StreamCancelFn()
+				}
+				// drain so the stream helper is not blocked
+				go func() {
+					for range remoteCh {
+					}
+				}()
+				return
+			case resp, ok := <-remoteCh:
+				if !ok {
+					return
+				}
+				select {
+				case ch <- resp:
+				case <-ctx.Done():
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// the rpc options for a stream that runs as long as ctx
+func streamRpcOpts(ctx context.Context, conn *connparse.Connection) *wshrpc.RpcOpts {
+	var timeout int64
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline).Milliseconds()
+	}
+	return &wshrpc.RpcOpts{Route: wshutil.MakeConnectionRouteId(conn.Host), Timeout: timeout}
+}
+
+// Watch watches conn on its connection until ctx is done
+func (c WshClient) Watch(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileWatchOpts) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileWatchEvent] {
+	rpcOpts := streamRpcOpts(ctx, conn)
+	remoteCh := wshclient.RemoteFileWatchCommand(RpcClient, wshrpc.CommandFileWatchData{Uri: conn.GetFullURI(), Opts: opts}, rpcOpts)
+	return forwardStream(ctx, "wshfs:Watch", remoteCh, rpcOpts)
+}
+
+// Search searches below conn on its connection, the search is canceled if ctx is done first
+func (c WshClient) Search(ctx context.Context, conn *connparse.Connection, opts *wshrpc.FileSearchOpts) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileSearchResult] {
+	rpcOpts := streamRpcOpts(ctx, conn)
+	remoteCh := wshclient.RemoteFileSearchCommand(RpcClient, wshrpc.CommandFileSearchData{Uri: conn.GetFullURI(), Opts: opts}, rpcOpts)
+	return forwardStream(ctx, "wshfs:Search", remoteCh, rpcOpts)
+}
+
+// CopyStream copies to destConn (from any source) on the destination connection, streaming the progress of the copy
+func (c WshClient) CopyStream(ctx context.Context, srcConn, destConn *connparse.Connection, opts *wshrpc.FileCopyOpts) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileCopyProgress] {
+	if opts == nil {
+		opts = &wshrpc.FileCopyOpts{}
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = fstype.DefaultTimeout.Milliseconds()
+	}
+	return wshclient.RemoteFileCopyStreamCommand(RpcClient, wshrpc.CommandFileCopyData{SrcUri: srcConn.GetFullURI(), DestUri: destConn.GetFullURI(), Opts: opts}, &wshrpc.RpcOpts{Route: wshutil.MakeConnectionRouteId(destConn.Host), Timeout: timeout})
+}
+
 func (c WshClient) Delete(ctx context.Context, conn *connparse.Connection, recursive bool) error {
 	return wshclient.RemoteFileDeleteCommand(RpcClient, wshrpc.CommandDeleteFileData{Path: conn.Path, Recursive: recursive}, &wshrpc.RpcOpts{Route: wshutil.MakeConnectionRouteId(conn.Host)})
 }
@@ -142,5 +221,5 @@ func (c WshClient) GetConnectionType() string {
 }
 
 func (c WshClient) GetCapability() wshrpc.FileShareCapability {
-	return wshrpc.FileShareCapability{CanAppend: true, CanMkdir: true}
+	return wshrpc.FileShareCapability{CanAppend: true, CanMkdir: true, CanStage: true}
 }
@@ -17,6 +17,7 @@ import (
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -39,15 +40,36 @@ import (
 const SshProxyJumpMaxDepth = 10
 
 var starSshConfigUserSettingsInternal *ssh_config.UserSettings
+var starSshConfigFiles []*ssh_config.Config
 var configUserSettingsOnce = &sync.Once{}
 
 func StarSshConfigUserSettings() *ssh_config.UserSettings {
+	loadSshConfigs()
+	return starSshConfigUserSettingsInternal
+}
+
+func loadSshConfigs() {
 	configUserSettingsOnce.Do(func() {
 		starSshConfigUserSettingsInternal = ssh_config.DefaultUserSettings
 		// IgnoreMatchDirective might not be available in this version
 		// Removed: starSshConfigUserSettingsInternal.IgnoreMatchDirective = true
+
+		// the UserSettings getters fill in the ssh_config defaults, the decoded files are kept (loaded once, like
+		// the UserSettings) for the few keywords where an explicit value has to be told apart from the default
+		starSshConfigFiles = nil
+		for _, fileName := range sshConfigFileNames() {
+			file, err := os.Open(fileName)
+			if err != nil {
+				continue
+			}
+			config, err := ssh_config.Decode(file)
+			file.Close()
+			if err != nil {
+				continue
+			}
+			starSshConfigFiles = append(starSshConfigFiles, config)
+		}
 	})
-	return starSshConfigUserSettingsInternal
 }
 
 type UserInputCancelError struct {
@@ -700,6 +722,47 @@ func ConnectToClient(connCtx context.Context, opts *SSHOpts, currentClient *ssh.
 		return nil, jumpNum, ConnectionError{ConnectionDebugInfo: debugInfo, Err: fmt.Errorf("ProxyJump %d exceeds Star's max depth of %d", jumpNum, SshProxyJumpMaxDepth)}
 	}
 
+	sshKeywords, err := FindConnKeywords(opts, connFlags)
+	if err != nil {
+		return nil, debugInfo.JumpNum, ConnectionError{ConnectionDebugInfo: debugInfo, Err: err}
+	}
+
+	for _, proxyName := range sshKeywords.SshProxyJump {
+		proxyOpts, err := ParseOpts(proxyName)
+		if err != nil {
+			return nil, debugInfo.JumpNum, ConnectionError{ConnectionDebugInfo: debugInfo, Err: err}
+		}
+
+		// ensure no overflow (this will likely never happen)
+		if jumpNum < math.MaxInt32 {
+			jumpNum += 1
+		}
+
+		// do not apply supplied keywords to proxies - ssh config must be used for that
+		debugInfo.CurrentClient, jumpNum, err = ConnectToClient(connCtx, proxyOpts, debugInfo.CurrentClient, jumpNum, &sconfig.ConnKeywords{})
+		if err != nil {
+			// do not add a context on a recursive call
+			// (this can cause a recursive nested context that's arbitrarily deep)
+			return nil, jumpNum, err
+		}
+	}
+	clientConfig, err := createClientConfig(connCtx, sshKeywords, debugInfo)
+	if err != nil {
+		return nil, debugInfo.JumpNum, ConnectionError{ConnectionDebugInfo: debugInfo, Err: err}
+	}
+	networkAddr := utilfn.SafeDeref(sshKeywords.SshHostName) + ":" + utilfn.SafeDeref(sshKeywords.SshPort)
+	client, err := connectInternal(connCtx, networkAddr, clientConfig, debugInfo.CurrentClient)
+	if err != nil {
+		return client, debugInfo.JumpNum, ConnectionError{ConnectionDebugInfo: debugInfo, Err: err}
+	}
+	return client, debugInfo.JumpNum, nil
+}
+
+// resolves the keywords for a connection, cascading the ssh config, the internal config and the passed in flags
+func FindConnKeywords(opts *SSHOpts, connFlags *sconfig.ConnKeywords) (*sconfig.ConnKeywords, error) {
+	if connFlags == nil {
+		connFlags = &sconfig.ConnKeywords{}
+	}
 	rawName := opts.String()
 	fullConfig := sconfig.GetWatcher().GetFullConfig()
 	internalSshConfigKeywords, ok := fullConfig.Connections[rawName]
@@ -712,15 +775,13 @@ func ConnectToClient(connCtx context.Context, opts *SSHOpts, currentClient *ssh.
 		var err error
 		sshConfigKeywords, err = findSshDefaults(opts.SSHHost)
 		if err != nil {
-			err = fmt.Errorf("cannot determine default config keywords: %w", err)
-			return nil, debugInfo.JumpNum, ConnectionError{ConnectionDebugInfo: debugInfo, Err: err}
+			return nil, fmt.Errorf("cannot determine default config keywords: %w", err)
 		}
 	} else {
 		var err error
 		sshConfigKeywords, err = findSshConfigKeywords(opts.SSHHost)
 		if err != nil {
-			err = fmt.Errorf("cannot determine config keywords: %w", err)
-			return nil, debugInfo.JumpNum, ConnectionError{ConnectionDebugInfo: debugInfo, Err: err}
+			return nil, fmt.Errorf("cannot determine config keywords: %w", err)
 		}
 	}
 
@@ -748,35 +809,24 @@ func ConnectToClient(connCtx context.Context, opts *SSHOpts, currentClient *ssh.
 	sshKeywords.SshIdentityFile = append(sshKeywords.SshIdentityFile, internalSshConfigKeywords.SshIdentityFile...)
 	sshKeywords.SshIdentityFile = append(sshKeywords.SshIdentityFile, sshConfigKeywords.SshIdentityFile...)
 
-	for _, proxyName := range sshKeywords.SshProxyJump {
-		proxyOpts, err := ParseOpts(proxyName)
-		if err != nil {
-			return nil, debugInfo.JumpNum, ConnectionError{ConnectionDebugInfo: debugInfo, Err: err}
-		}
-
-		// ensure no overflow (this will likely never happen)
-		if jumpNum < math.MaxInt32 {
-			jumpNum += 1
-		}
+	// forwards accumulate (like openssh) rather than override each other
+	sshKeywords.SshLocalForward = appendForwards(sshConfigKeywords.SshLocalForward, internalSshConfigKeywords.SshLocalForward, connFlags.SshLocalForward)
+	sshKeywords.SshRemoteForward = appendForwards(sshConfigKeywords.SshRemoteForward, internalSshConfigKeywords.SshRemoteForward, connFlags.SshRemoteForward)
+	sshKeywords.SshDynamicForward = appendForwards(sshConfigKeywords.SshDynamicForward, internalSshConfigKeywords.SshDynamicForward, connFlags.SshDynamicForward)
+	return sshKeywords, nil
+}
 
-		// do not apply supplied keywords to proxies - ssh config must be used for that
-		debugInfo.CurrentClient, jumpNum, err = ConnectToClient(connCtx, proxyOpts, debugInfo.CurrentClient, jumpNum, &sconfig.ConnKeywords{})
-		if err != nil {
-			// do not add a context on a recursive call
-			// (this can cause a recursive nested context that's arbitrarily deep)
-			return nil, jumpNum, err
+func appendForwards(forwardLists ...[]string) []string {
+	var rtn []string
+	for _, forwards := range forwardLists {
+		for _, fwd := range forwards {
+			if utilfn.ContainsStr(rtn, fwd) {
+				continue
+			}
+			rtn = append(rtn, fwd)
 		}
 	}
-	clientConfig, err := createClientConfig(connCtx, sshKeywords, debugInfo)
-	if err != nil {
-		return nil, debugInfo.JumpNum, ConnectionError{ConnectionDebugInfo: debugInfo, Err: err}
-	}
-	networkAddr := utilfn.SafeDeref(sshKeywords.SshHostName) + ":" + utilfn.SafeDeref(sshKeywords.SshPort)
-	client, err := connectInternal(connCtx, networkAddr, clientConfig, debugInfo.CurrentClient)
-	if err != nil {
-		return client, debugInfo.JumpNum, ConnectionError{ConnectionDebugInfo: debugInfo, Err: err}
-	}
-	return client, debugInfo.JumpNum, nil
+	return rtn
 }
 
 // note that a `var == "yes"` will default to false
@@ -918,9 +968,65 @@ func findSshConfigKeywords(hostPattern string) (connKeywords *sconfig.ConnKeywor
 	rawGlobalKnownHostsFile, _ := StarSshConfigUserSettings().GetStrict(hostPattern, "GlobalKnownHostsFile")
 	sshKeywords.SshGlobalKnownHostsFile = strings.Fields(rawGlobalKnownHostsFile) // TODO - smarter splitting escaped spaces and quotes
 
+	// the ssh_config library returns its default (0) when ServerAliveInterval is not set, so only an explicit value
+	// is used.  an unset interval uses star's default, an explicit 0 disables the keepalive
+	sshKeywords.SshServerAliveInterval = parseServerAliveInterval(getExplicitSshConfigValue(hostPattern, "ServerAliveInterval"))
+	serverAliveCountMaxRaw, err := StarSshConfigUserSettings().GetStrict(hostPattern, "ServerAliveCountMax")
+	if err != nil {
+		return nil, err
+	}
+	serverAliveCountMax, err := strconv.Atoi(trimquotes.TryTrimQuotes(serverAliveCountMaxRaw))
+	if err == nil && serverAliveCountMax > 0 {
+		sshKeywords.SshServerAliveCountMax = &serverAliveCountMax
+	}
+
+	// forward specs are validated when the connection starts them
+	sshKeywords.SshLocalForward = getAllTrimmed(hostPattern, "LocalForward")
+	sshKeywords.SshRemoteForward = getAllTrimmed(hostPattern, "RemoteForward")
+	sshKeywords.SshDynamicForward = getAllTrimmed(hostPattern, "DynamicForward")
+
 	return sshKeywords, nil
 }
 
+// the user and system ssh config files (a var for tests)
+var sshConfigFileNames = func() []string {
+	return []string{filepath.Join(starbase.GetHomeDir(), ".ssh", "config"), filepath.Join("/", "etc", "ssh", "ssh_config")}
+}
+
+// returns the value of key for the host only if a config file sets it ("" otherwise), unlike GetStrict it never
+// falls back to the ssh_config defaults
+func getExplicitSshConfigValue(hostPattern string, key string) string {
+	loadSshConfigs()
+	for _, config := range starSshConfigFiles {
+		val, err := config.Get(hostPattern, key)
+		if err == nil && val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+// nil if the interval is not set (or invalid), 0 disables the keepalive
+func parseServerAliveInterval(rawVal string) *int {
+	interval, err := strconv.Atoi(strings.TrimSpace(trimquotes.TryTrimQuotes(rawVal)))
+	if err != nil || interval < 0 {
+		return nil
+	}
+	return &interval
+}
+
+func getAllTrimmed(hostPattern string, key string) []string {
+	var rtn []string
+	for _, val := range StarSshConfigUserSettings().GetAll(hostPattern, key) {
+		val = strings.TrimSpace(trimquotes.TryTrimQuotes(val))
+		if val == "" {
+			continue
+		}
+		rtn = append(rtn, val)
+	}
+	return rtn
+}
+
 func findSshDefaults(hostPattern string) (connKeywords *sconfig.ConnKeywords, outErr error) {
 	sshKeywords := &sconfig.ConnKeywords{}
 
@@ -1023,6 +1129,12 @@ func mergeKeywords(oldKeywords *sconfig.ConnKeywords, newKeywords *sconfig.ConnK
 	if newKeywords.SshGlobalKnownHostsFile != nil {
 		outKeywords.SshGlobalKnownHostsFile = newKeywords.SshGlobalKnownHostsFile
 	}
+	if newKeywords.SshServerAliveInterval != nil {
+		outKeywords.SshServerAliveInterval = newKeywords.SshServerAliveInterval
+	}
+	if newKeywords.SshServerAliveCountMax != nil {
+		outKeywords.SshServerAliveCountMax = newKeywords.SshServerAliveCountMax
+	}
 
 	return &outKeywords
 }
@@ -0,0 +1,54 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package remote
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+)
+
+func TestServerAliveIntervalConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config")
+	config := "Host disabled\n  ServerAliveInterval 0\n\nHost quoted\n  ServerAliveInterval \"20\"\n\nHost bad\n  ServerAliveInterval soon\n\nHost *\n  ServerAliveCountMax 4\n"
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+	oldFileNames := sshConfigFileNames
+	sshConfigFileNames = func() []string { return []string{filepath.Join(t.TempDir(), "missing"), configFile} }
+	configUserSettingsOnce = &sync.Once{}
+	defer func() {
+		sshConfigFileNames = oldFileNames
+		configUserSettingsOnce = &sync.Once{}
+	}()
+
+	tests := []struct {
+		host     string
+		interval *int // nil if not set
+	}{
+		{"disabled", utilfn.Ptr(0)},
+		{"quoted", utilfn.Ptr(20)},
+		{"bad", nil},
+		{"unset", nil},
+	}
+	for _, tc := range tests {
+		interval := parseServerAliveInterval(getExplicitSshConfigValue(tc.host, "ServerAliveInterval"))
+		if (interval == nil) != (tc.interval == nil) || (interval != nil && *interval != *tc.interval) {
+			t.Errorf("%s: got interval %v, want %v", tc.host, fmtIntPtr(interval), fmtIntPtr(tc.interval))
+		}
+	}
+	if val := getExplicitSshConfigValue("unset", "ServerAliveCountMax"); val != "4" {
+		t.Errorf("got countmax %q from the wildcard host, want \"4\"", val)
+	}
+}
+
+func fmtIntPtr(val *int) any {
+	if val == nil {
+		return "unset"
+	}
+	return *val
+}
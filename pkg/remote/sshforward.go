@@ -0,0 +1,286 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package remote
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/trimquotes"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	ForwardType_Local   = "local"
+	ForwardType_Remote  = "remote"
+	ForwardType_Dynamic = "dynamic"
+)
+
+// matches the openssh defaults (GatewayPorts no)
+const DefaultForwardBindHost = "localhost"
+
+type ForwardSpec struct {
+	Type       string
+	BindAddr   string // host:port that is listened on (locally for local/dynamic, on the remote for remote)
+	TargetAddr string // host:port that connections are sent to (empty for dynamic)
+}
+
+func (spec ForwardSpec) String() string {
+	if spec.Type == ForwardType_Dynamic {
+		return spec.BindAddr + " (socks)"
+	}
+	return spec.BindAddr + " -> " + spec.TargetAddr
+}
+
+// splits on colons that are not inside of brackets (ipv6 addresses)
+func splitForwardSpec(specStr string) []string {
+	var parts []string
+	var inBracket bool
+	start := 0
+	for idx, ch := range specStr {
+		switch ch {
+		case '[':
+			inBracket = true
+		case ']':
+			inBracket = false
+		case ':':
+			if !inBracket {
+				parts = append(parts, specStr[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+	return append(parts, specStr[start:])
+}
+
+func makeForwardAddr(host string, portStr string, defaultHost string) (string, error) {
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", fmt.Errorf("invalid port %q", portStr)
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" {
+		host = defaultHost
+	} else if host == "*" {
+		host = ""
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// accepts both the ssh_config form ("[bind_address:]port host:hostport")
+// and the command line form ("[bind_address:]port:host:hostport").
+// dynamic forwards only take "[bind_address:]port"
+func ParseForwardSpec(fwdType string, specStr string) (ForwardSpec, error) {
+	specStr = strings.TrimSpace(trimquotes.TryTrimQuotes(strings.TrimSpace(specStr)))
+	if fwdType != ForwardType_Local && fwdType != ForwardType_Remote && fwdType != ForwardType_Dynamic {
+		return ForwardSpec{}, fmt.Errorf("invalid forward type %q", fwdType)
+	}
+	var bindParts, targetParts []string
+	fields := strings.Fields(specStr)
+	switch {
+	case len(fields) == 2 && fwdType != ForwardType_Dynamic:
+		bindParts = splitForwardSpec(fields[0])
+		targetParts = splitForwardSpec(fields[1])
+	case len(fields) == 1:
+		parts := splitForwardSpec(fields[0])
+		if fwdType == ForwardType_Dynamic {
+			bindParts = parts
+		} else if len(parts) >= 3 {
+			bindParts = parts[:len(parts)-2]
+			targetParts = parts[len(parts)-2:]
+		}
+	}
+	if len(bindParts) == 1 {
+		bindParts = []string{"", bindParts[0]}
+	}
+	if len(bindParts) != 2 || (fwdType != ForwardType_Dynamic && len(targetParts) != 2) {
+		return ForwardSpec{}, fmt.Errorf("invalid %s forward spec %q", fwdType, specStr)
+	}
+	bindAddr, err := makeForwardAddr(bindParts[0], bindParts[1], DefaultForwardBindHost)
+	if err != nil {
+		return ForwardSpec{}, fmt.Errorf("invalid %s forward spec %q: %w", fwdType, specStr, err)
+	}
+	rtn := ForwardSpec{Type: fwdType, BindAddr: bindAddr}
+	if fwdType != ForwardType_Dynamic {
+		if targetParts[0] == "" || targetParts[0] == "*" {
+			return ForwardSpec{}, fmt.Errorf("invalid %s forward spec %q: missing target host", fwdType, specStr)
+		}
+		rtn.TargetAddr, err = makeForwardAddr(targetParts[0], targetParts[1], "")
+		if err != nil {
+			return ForwardSpec{}, fmt.Errorf("invalid %s forward spec %q: %w", fwdType, specStr, err)
+		}
+	}
+	return rtn, nil
+}
+
+// opens the listening side of the forward (locally for local/dynamic, on the remote for remote)
+func ListenForward(client *ssh.Client, spec ForwardSpec) (net.Listener, error) {
+	switch spec.Type {
+	case ForwardType_Local, ForwardType_Dynamic:
+		return net.Listen("tcp", spec.BindAddr)
+	case ForwardType_Remote:
+		return client.Listen("tcp", spec.BindAddr)
+	}
+	return nil, fmt.Errorf("invalid forward type %q", spec.Type)
+}
+
+// accepts connections until the listener is closed (or fails).  returns the accept error
+func ServeForward(client *ssh.Client, spec ForwardSpec, listener net.Listener) error {
+	for {
+		localConn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer func() {
+				panichandler.PanicHandler("sshforward:handleForwardConn", recover())
+			}()
+			handleForwardConn(client, spec, localConn)
+		}()
+	}
+}
+
+func handleForwardConn(client *ssh.Client, spec ForwardSpec, srcConn net.Conn) {
+	defer srcConn.Close()
+	targetAddr := spec.TargetAddr
+	if spec.Type == ForwardType_Dynamic {
+		var err error
+		targetAddr, err = socks5Handshake(srcConn)
+		if err != nil {
+			log.Printf("socks forward %s: %v\n", spec.BindAddr, err)
+			return
+		}
+	}
+	var destConn net.Conn
+	var err error
+	if spec.Type == ForwardType_Remote {
+		destConn, err = net.Dial("tcp", targetAddr)
+	} else {
+		destConn, err = client.Dial("tcp", targetAddr)
+	}
+	if spec.Type == ForwardType_Dynamic {
+		// the socks reply has to be sent whether or not the dial succeeded
+		var replyStatus byte = socks5ReplySuccess
+		if err != nil {
+			replyStatus = socks5ReplyFailure
+		}
+		replyErr := writeSocks5Reply(srcConn, replyStatus)
+		if err == nil && replyErr != nil {
+			destConn.Close()
+			return
+		}
+	}
+	if err != nil {
+		log.Printf("forward %s: error dialing %s: %v\n", spec.BindAddr, targetAddr, err)
+		return
+	}
+	defer destConn.Close()
+	wg := &sync.WaitGroup{}
+	wg.Add(2)
+	copyFn := func(dst net.Conn, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		// unblock the other direction
+		dst.Close()
+		src.Close()
+	}
+	go copyFn(destConn, srcConn)
+	go copyFn(srcConn, destConn)
+	wg.Wait()
+}
+
+const (
+	socks5Version       = 0x05
+	socks5NoAuth        = 0x00
+	socks5NoAcceptable  = 0xff
+	socks5CmdConnect    = 0x01
+	socks5AddrIPv4      = 0x01
+	socks5AddrDomain    = 0x03
+	socks5AddrIPv6      = 0x04
+	socks5ReplySuccess  = 0x00
+	socks5ReplyFailure  = 0x01
+	socks5ReplyBadCmd   = 0x07
+	socks5ReplyBadAType = 0x08
+)
+
+// minimal socks5 server handshake (no auth, CONNECT only), returns the requested host:port
+func socks5Handshake(conn net.Conn) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", fmt.Errorf("error reading socks greeting: %w", err)
+	}
+	if header[0] != socks5Version {
+		return "", fmt.Errorf("unsupported socks version %d", header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", fmt.Errorf("error reading socks auth methods: %w", err)
+	}
+	hasNoAuth := false
+	for _, method := range methods {
+		if method == socks5NoAuth {
+			hasNoAuth = true
+		}
+	}
+	if !hasNoAuth {
+		conn.Write([]byte{socks5Version, socks5NoAcceptable})
+		return "", errors.New("socks client does not support unauthenticated connections")
+	}
+	if _, err := conn.Write([]byte{socks5Version, socks5NoAuth}); err != nil {
+		return "", err
+	}
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return "", fmt.Errorf("error reading socks request: %w", err)
+	}
+	if req[1] != socks5CmdConnect {
+		writeSocks5Reply(conn, socks5ReplyBadCmd)
+		return "", fmt.Errorf("unsupported socks command %d", req[1])
+	}
+	var host string
+	switch req[3] {
+	case socks5AddrIPv4, socks5AddrIPv6:
+		ipLen := net.IPv4len
+		if req[3] == socks5AddrIPv6 {
+			ipLen = net.IPv6len
+		}
+		ip := make([]byte, ipLen)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case socks5AddrDomain:
+		domainLen := make([]byte, 1)
+		if _, err := io.ReadFull(conn, domainLen); err != nil {
+			return "", err
+		}
+		domain := make([]byte, domainLen[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		writeSocks5Reply(conn, socks5ReplyBadAType)
+		return "", fmt.Errorf("unsupported socks address type %d", req[3])
+	}
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBytes); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(portBytes)))), nil
+}
+
+func writeSocks5Reply(conn net.Conn, status byte) error {
+	// we do not report the bound address (clients ignore it for CONNECT)
+	_, err := conn.Write([]byte{socks5Version, status, 0x00, socks5AddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,63 @@
+package remote_test
+
+import (
+	"testing"
+
+	"github.com/commandlinedev/starterm/pkg/remote"
+)
+
+func TestParseForwardSpec(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fwdType    string
+		spec       string
+		bindAddr   string
+		targetAddr string
+	}{
+		{remote.ForwardType_Local, "8080 localhost:80", "localhost:8080", "localhost:80"},
+		{remote.ForwardType_Local, "8080:db.internal:5432", "localhost:8080", "db.internal:5432"},
+		{remote.ForwardType_Local, "127.0.0.1:8080:localhost:80", "127.0.0.1:8080", "localhost:80"},
+		{remote.ForwardType_Local, "*:8080 localhost:80", ":8080", "localhost:80"},
+		{remote.ForwardType_Local, "[::1]:8080 [::1]:80", "[::1]:8080", "[::1]:80"},
+		{remote.ForwardType_Remote, "\"9000 localhost:3000\"", "localhost:9000", "localhost:3000"},
+		{remote.ForwardType_Dynamic, "1080", "localhost:1080", ""},
+		{remote.ForwardType_Dynamic, "0.0.0.0:1080", "0.0.0.0:1080", ""},
+	}
+	for _, test := range tests {
+		spec, err := remote.ParseForwardSpec(test.fwdType, test.spec)
+		if err != nil {
+			t.Fatalf("failed to parse %s forward %q: %v", test.fwdType, test.spec, err)
+		}
+		if spec.BindAddr != test.bindAddr {
+			t.Errorf("%q: expected bind addr %q, got %q", test.spec, test.bindAddr, spec.BindAddr)
+		}
+		if spec.TargetAddr != test.targetAddr {
+			t.Errorf("%q: expected target addr %q, got %q", test.spec, test.targetAddr, spec.TargetAddr)
+		}
+	}
+}
+
+func TestParseForwardSpecErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fwdType string
+		spec    string
+	}{
+		{remote.ForwardType_Local, ""},
+		{remote.ForwardType_Local, "8080"},
+		{remote.ForwardType_Local, "8080:localhost"},
+		{remote.ForwardType_Local, "abc localhost:80"},
+		{remote.ForwardType_Local, "8080 localhost:99999"},
+		{remote.ForwardType_Remote, "9000 :3000"},
+		{remote.ForwardType_Dynamic, "1080 localhost:80"},
+		{"other", "8080 localhost:80"},
+	}
+	for _, test := range tests {
+		_, err := remote.ParseForwardSpec(test.fwdType, test.spec)
+		if err == nil {
+			t.Errorf("expected error parsing %s forward %q", test.fwdType, test.spec)
+		}
+	}
+}
@@ -22,6 +22,7 @@ const (
 	ConfigKey_AIApiVersion                   = "ai:apiversion"
 	ConfigKey_AiMaxTokens                    = "ai:maxtokens"
 	ConfigKey_AiTimeoutMs                    = "ai:timeoutms"
+	ConfigKey_AiTools                        = "ai:tools"
 	ConfigKey_AiFontSize                     = "ai:fontsize"
 	ConfigKey_AiFixedFontSize                = "ai:fixedfontsize"
 
@@ -92,5 +93,22 @@ const (
 	ConfigKey_ConnClear                      = "conn:*"
 	ConfigKey_ConnAskBeforeWshInstall        = "conn:askbeforewshinstall"
 	ConfigKey_ConnWshEnabled                 = "conn:wshenabled"
+
+	ConfigKey_HistoryClear                   = "history:*"
+	ConfigKey_HistoryDisabled                = "history:disabled"
+	ConfigKey_HistoryIgnore                  = "history:ignore"
+	ConfigKey_HistoryMaxAgeDays              = "history:maxagedays"
+	ConfigKey_HistoryMaxItems                = "history:maxitems"
+
+	ConfigKey_FileClear                      = "file:*"
+	ConfigKey_FileTrash                      = "file:trash"
+	ConfigKey_FileTrashRetentionDays         = "file:trashretentiondays"
+
+	ConfigKey_DebugClear                     = "debug:*"
+	ConfigKey_DebugRpcMetrics                = "debug:rpcmetrics"
+
+	ConfigKey_ApiClear                       = "api:*"
+	ConfigKey_ApiEnabled                     = "api:enabled"
+	ConfigKey_ApiPort                        = "api:port"
 )
 
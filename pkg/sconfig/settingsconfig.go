@@ -24,6 +24,7 @@ import (
 const SettingsFile = "settings.json"
 const ConnectionsFile = "connections.json"
 const ProfilesFile = "profiles.json"
+const ApiTokensFile = "apitokens.json"
 
 const AnySchema = `
 {
@@ -44,6 +45,7 @@ type AiSettingsType struct {
 	AIApiVersion    string  `json:"ai:apiversion,omitempty"`
 	AiMaxTokens     float64 `json:"ai:maxtokens,omitempty"`
 	AiTimeoutMs     float64 `json:"ai:timeoutms,omitempty"`
+	AiTools         bool    `json:"ai:tools,omitempty"`
 	AiFontSize      float64 `json:"ai:fontsize,omitempty"`
 	AiFixedFontSize float64 `json:"ai:fixedfontsize,omitempty"`
 	DisplayName     string  `json:"display:name,omitempty"`
@@ -67,6 +69,7 @@ type SettingsType struct {
 	AIApiVersion    string  `json:"ai:apiversion,omitempty"`
 	AiMaxTokens     float64 `json:"ai:maxtokens,omitempty"`
 	AiTimeoutMs     float64 `json:"ai:timeoutms,omitempty"`
+	AiTools         bool    `json:"ai:tools,omitempty"`
 	AiFontSize      float64 `json:"ai:fontsize,omitempty"`
 	AiFixedFontSize float64 `json:"ai:fixedfontsize,omitempty"`
 
@@ -137,6 +140,23 @@ type SettingsType struct {
 	ConnClear               bool  `json:"conn:*,omitempty"`
 	ConnAskBeforeWshInstall *bool `json:"conn:askbeforewshinstall,omitempty"`
 	ConnWshEnabled          bool  `json:"conn:wshenabled,omitempty"`
+
+	HistoryClear      bool     `json:"history:*,omitempty"`
+	HistoryDisabled   bool     `json:"history:disabled,omitempty"`
+	HistoryIgnore     []string `json:"history:ignore,omitempty"`
+	HistoryMaxAgeDays *int64   `json:"history:maxagedays,omitempty"`
+	HistoryMaxItems   *int64   `json:"history:maxitems,omitempty"`
+
+	FileClear              bool   `json:"file:*,omitempty"`
+	FileTrash              bool   `json:"file:trash,omitempty"`
+	FileTrashRetentionDays *int64 `json:"file:trashretentiondays,omitempty"`
+
+	DebugClear      bool `json:"debug:*,omitempty"`
+	DebugRpcMetrics bool `json:"debug:rpcmetrics,omitempty"`
+
+	ApiClear   bool  `json:"api:*,omitempty"`
+	ApiEnabled bool  `json:"api:enabled,omitempty"`
+	ApiPort    int64 `json:"api:port,omitempty"`
 }
 
 type ConfigError struct {
@@ -153,6 +173,13 @@ type WebBookmark struct {
 	DisplayOrder float64 `json:"display:order,omitempty"`
 }
 
+// an automation api token, the token itself is never stored (see authkey.HashApiToken)
+type ApiTokenType struct {
+	TokenHash string   `json:"tokenhash"`
+	Scopes    []string `json:"scopes"`
+	CreatedTs int64    `json:"createdts,omitempty"`
+}
+
 type FullConfigType struct {
 	Settings       SettingsType                   `json:"settings" merge:"meta"`
 	MimeTypes      map[string]MimeTypeConfigType  `json:"mimetypes"`
@@ -162,6 +189,7 @@ type FullConfigType struct {
 	TermThemes     map[string]TermThemeType       `json:"termthemes"`
 	Connections    map[string]ConnKeywords        `json:"connections"`
 	Bookmarks      map[string]WebBookmark         `json:"bookmarks"`
+	ApiTokens      map[string]ApiTokenType        `json:"apitokens"`
 	ConfigErrors   []ConfigError                  `json:"configerrors" configfile:"-"`
 }
 type ConnKeywords struct {
@@ -202,6 +230,18 @@ type ConnKeywords struct {
 	SshProxyJump                    []string `json:"ssh:proxyjump,omitempty"`
 	SshUserKnownHostsFile           []string `json:"ssh:userknownhostsfile,omitempty"`
 	SshGlobalKnownHostsFile         []string `json:"ssh:globalknownhostsfile,omitempty"`
+	SshLocalForward                 []string `json:"ssh:localforward,omitempty"`
+	SshRemoteForward                []string `json:"ssh:remoteforward,omitempty"`
+	SshDynamicForward               []string `json:"ssh:dynamicforward,omitempty"`
+	SshServerAliveInterval          *int     `json:"ssh:serveraliveinterval,omitempty"`
+	SshServerAliveCountMax          *int     `json:"ssh:serveralivecountmax,omitempty"`
+
+	S3EndpointUrl        string `json:"s3:endpointurl,omitempty"`
+	S3PathStyle          *bool  `json:"s3:pathstyle,omitempty"`
+	S3Region             string `json:"s3:region,omitempty"`
+	S3Profile            string `json:"s3:profile,omitempty"`
+	S3InsecureSkipVerify *bool  `json:"s3:insecureskipverify,omitempty"`
+	S3CaFile             string `json:"s3:cafile,omitempty"`
 }
 
 func DefaultBoolPtr(arg *bool, def bool) bool {
@@ -610,6 +650,31 @@ func SetConnectionsConfigValue(connName string, toMerge starobj.MetaMapType) err
 	return WriteStarHomeConfigFile(ConnectionsFile, m)
 }
 
+// sets (or with a nil token, removes) the api token with the given name in apitokens.json
+func SetApiToken(name string, token *ApiTokenType) error {
+	m, cerrs := ReadStarHomeConfigFile(ApiTokensFile)
+	if len(cerrs) > 0 {
+		return fmt.Errorf("error reading config file: %v", cerrs[0])
+	}
+	if m == nil {
+		m = make(starobj.MetaMapType)
+	}
+	if token == nil {
+		if _, ok := m[name]; !ok {
+			return fmt.Errorf("api token %q not found", name)
+		}
+		delete(m, name)
+		return WriteStarHomeConfigFile(ApiTokensFile, m)
+	}
+	var tokenMap starobj.MetaMapType
+	err := utilfn.ReUnmarshal(&tokenMap, token)
+	if err != nil {
+		return fmt.Errorf("error converting api token: %w", err)
+	}
+	m[name] = tokenMap
+	return WriteStarHomeConfigFile(ApiTokensFile, m)
+}
+
 type WidgetConfigType struct {
 	DisplayOrder  float64          `json:"display:order,omitempty"`
 	DisplayHidden bool             `json:"display:hidden,omitempty"`
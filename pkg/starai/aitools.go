@@ -0,0 +1,483 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package starai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/commandlinedev/starterm/pkg/filestore"
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/termarchive"
+	"github.com/commandlinedev/starterm/pkg/userinput"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
+	"github.com/commandlinedev/starterm/pkg/wstore"
+)
+
+const (
+	MaxToolRounds         = 10
+	MaxToolOutputSize     = 64 * 1024
+	DefaultTermOutputSize = 16 * 1024
+	MaxDirEntries         = 500
+	ToolApprovalTimeout   = 60 * time.Second
+	toolRole              = "tool"
+	toolDeniedMsg         = "the user denied this request"
+)
+
+type toolContext struct {
+	BlockId string // the ai block (can be empty)
+	TabId   string
+}
+
+type aiTool struct {
+	Def wshrpc.StarAIToolDef
+	// tools with side effects need user approval, Describe returns the (markdown) text shown to the user
+	Describe func(input map[string]any) string
+	Run      func(ctx context.Context, tctx *toolContext, input map[string]any) (string, error)
+}
+
+func objectSchema(props map[string]any, required ...string) map[string]any {
+	rtn := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		rtn["required"] = required
+	}
+	return rtn
+}
+
+func propSchema(propType string, description string) map[string]any {
+	return map[string]any{"type": propType, "description": description}
+}
+
+func getStringInput(input map[string]any, key string) string {
+	val, _ := input[key].(string)
+	return val
+}
+
+func getIntInput(input map[string]any, key string, def int) int {
+	val, ok := input[key].(float64)
+	if !ok || val <= 0 {
+		return def
+	}
+	return int(val)
+}
+
+// paths can be full uris (wsh://conn/path, s3://bucket/key, etc.) or a path on the given connection
+func makeToolFileUri(input map[string]any) (string, error) {
+	path := getStringInput(input, "path")
+	if path == "" {
+		return "", errors.New("path is required")
+	}
+	if strings.Contains(path, "://") {
+		return path, nil
+	}
+	conn := getStringInput(input, "connection")
+	if conn == "" {
+		conn = wshrpc.LocalConnName
+	}
+	return fmt.Sprintf("wsh://%s/%s", conn, path), nil
+}
+
+func truncateToolOutput(output string) string {
+	if len(output) <= MaxToolOutputSize {
+		return output
+	}
+	cut := MaxToolOutputSize
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+	return output[:cut] + "\n[output truncated]"
+}
+
+func marshalToolOutput(val any) (string, error) {
+	barr, err := json.MarshalIndent(val, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(barr), nil
+}
+
+var builtinTools = []*aiTool{
+	{
+		Def: wshrpc.StarAIToolDef{
+			Name:        "list_terminal_blocks",
+			Description: "Lists the terminal blocks in the user's current tab (block id, connection, cwd and title).",
+			InputSchema: objectSchema(map[string]any{}),
+		},
+		Run: runListTerminalBlocks,
+	},
+	{
+		Def: wshrpc.StarAIToolDef{
+			Name:        "read_terminal_output",
+			Description: "Reads the most recent output of a terminal block, with escape sequences removed.",
+			InputSchema: objectSchema(map[string]any{
+				"blockid":  propSchema("string", "the block id (from list_terminal_blocks)"),
+				"maxbytes": propSchema("integer", fmt.Sprintf("how much of the end of the output to read (default %d, max %d)", DefaultTermOutputSize, MaxToolOutputSize)),
+			}, "blockid"),
+		},
+		Run: runReadTerminalOutput,
+	},
+	{
+		Def: wshrpc.StarAIToolDef{
+			Name:        "read_file",
+			Description: fmt.Sprintf("Reads a text file (up to %d bytes).", MaxToolOutputSize),
+			InputSchema: objectSchema(map[string]any{
+				"path":       propSchema("string", "the file path (absolute, or relative to the home directory)"),
+				"connection": propSchema("string", "the connection the file is on (defaults to the local machine)"),
+			}, "path"),
+		},
+		Run: runReadFile,
+	},
+	{
+		Def: wshrpc.StarAIToolDef{
+			Name:        "list_directory",
+			Description: fmt.Sprintf("Lists the entries of a directory (up to %d).", MaxDirEntries),
+			InputSchema: objectSchema(map[string]any{
+				"path":       propSchema("string", "the directory path (absolute, or relative to the home directory)"),
+				"connection": propSchema("string", "the connection the directory is on (defaults to the local machine)"),
+			}, "path"),
+		},
+		Run: runListDirectory,
+	},
+	{
+		Def: wshrpc.StarAIToolDef{
+			Name:        "get_connection_status",
+			Description: "Gets the status of the user's remote (ssh) connections.",
+			InputSchema: objectSchema(map[string]any{}),
+		},
+		Run: runGetConnectionStatus,
+	},
+	{
+		Def: wshrpc.StarAIToolDef{
+			Name:        "run_command",
+			Description: "Runs a shell command in a new terminal block in the user's tab (the user has to approve it). Returns the block id, use read_terminal_output to see the output.",
+			InputSchema: objectSchema(map[string]any{
+				"command":    propSchema("string", "the shell command to run"),
+				"connection": propSchema("string", "the connection to run the command on (defaults to the local machine)"),
+				"cwd":        propSchema("string", "the working directory"),
+			}, "command"),
+		},
+		Describe: describeRunCommand,
+		Run:      runRunCommand,
+	},
+}
+
+func GetToolDefs() []wshrpc.StarAIToolDef {
+	var rtn []wshrpc.StarAIToolDef
+	for _, tool := range builtinTools {
+		rtn = append(rtn, tool.Def)
+	}
+	return rtn
+}
+
+func findTool(name string) *aiTool {
+	for _, tool := range builtinTools {
+		if tool.Def.Name == name {
+			return tool
+		}
+	}
+	return nil
+}
+
+func (tctx *toolContext) getTabId() (string, error) {
+	if tctx.TabId == "" {
+		return "", errors.New("no tab for this request")
+	}
+	return tctx.TabId, nil
+}
+
+// asks the user to approve a tool call, a var for tests
+var getToolApproval = func(ctx context.Context, tool *aiTool, input map[string]any) (bool, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, ToolApprovalTimeout)
+	defer cancelFn()
+	request := &userinput.UserInputRequest{
+		ResponseType: "confirm",
+		Title:        "AI Tool Request",
+		QueryText:    tool.Describe(input),
+		Markdown:     true,
+		OkLabel:      "Allow",
+		CancelLabel:  "Deny",
+	}
+	response, err := userinput.GetUserInput(ctx, request)
+	if err != nil {
+		return false, err
+	}
+	return response.Confirm, nil
+}
+
+// errors are returned to the model (as the tool result), they do not end the request
+func (tctx *toolContext) runTool(ctx context.Context, call wshrpc.StarAIToolCall) (string, error) {
+	tool := findTool(call.Name)
+	if tool == nil {
+		return "", fmt.Errorf("unknown tool %q", call.Name)
+	}
+	input := make(map[string]any)
+	if strings.TrimSpace(call.Input) != "" {
+		err := json.Unmarshal([]byte(call.Input), &input)
+		if err != nil {
+			return "", fmt.Errorf("invalid tool input: %w", err)
+		}
+	}
+	if tool.Describe != nil {
+		approved, err := getToolApproval(ctx, tool, input)
+		if err != nil {
+			return "", fmt.Errorf("error getting approval: %w", err)
+		}
+		if !approved {
+			return "", errors.New(toolDeniedMsg)
+		}
+	}
+	output, err := tool.Run(ctx, tctx, input)
+	if err != nil {
+		return "", err
+	}
+	return truncateToolOutput(output), nil
+}
+
+func runListTerminalBlocks(ctx context.Context, tctx *toolContext, input map[string]any) (string, error) {
+	tabId, err := tctx.getTabId()
+	if err != nil {
+		return "", err
+	}
+	tab, err := wstore.DBMustGet[*starobj.Tab](ctx, tabId)
+	if err != nil {
+		return "", fmt.Errorf("error getting tab: %w", err)
+	}
+	type blockInfo struct {
+		BlockId    string `json:"blockid"`
+		Connection string `json:"connection,omitempty"`
+		Cwd        string `json:"cwd,omitempty"`
+		Title      string `json:"title,omitempty"`
+		Cmd        string `json:"cmd,omitempty"`
+	}
+	var rtn []blockInfo
+	for _, blockId := range tab.BlockIds {
+		block, err := wstore.DBGet[*starobj.Block](ctx, blockId)
+		if err != nil || block == nil {
+			continue
+		}
+		if block.Meta.GetString(starobj.MetaKey_View, "") != "term" {
+			continue
+		}
+		rtn = append(rtn, blockInfo{
+			BlockId:    blockId,
+			Connection: block.Meta.GetString(starobj.MetaKey_Connection, ""),
+			Cwd:        block.Meta.GetString(starobj.MetaKey_CmdCwd, ""),
+			Title:      block.Meta.GetString(starobj.MetaKey_FrameTitle, ""),
+			Cmd:        block.Meta.GetString(starobj.MetaKey_Cmd, ""),
+		})
+	}
+	if len(rtn) == 0 {
+		return "there are no terminal blocks in the current tab", nil
+	}
+	return marshalToolOutput(rtn)
+}
+
+func runReadTerminalOutput(ctx context.Context, tctx *toolContext, input map[string]any) (string, error) {
+	blockId := getStringInput(input, "blockid")
+	if blockId == "" {
+		return "", errors.New("blockid is required")
+	}
+	maxBytes := min(getIntInput(input, "maxbytes", DefaultTermOutputSize), MaxToolOutputSize)
+	wfile, err := filestore.WFS.Stat(ctx, blockId, starbase.BlockFile_Term)
+	if err == fs.ErrNotExist {
+		return "", fmt.Errorf("block %s has no terminal output", blockId)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading terminal output: %w", err)
+	}
+	offset := max(wfile.Size-int64(maxBytes), wfile.DataStartIdx())
+	_, data, err := filestore.WFS.ReadAt(ctx, blockId, starbase.BlockFile_Term, offset, wfile.Size-offset)
+	if err != nil {
+		return "", fmt.Errorf("error reading terminal output: %w", err)
+	}
+	text := termarchive.StripAnsi(data)
+	if text == "" {
+		return "[no output]", nil
+	}
+	return text, nil
+}
+
+func runReadFile(ctx context.Context, tctx *toolContext, input map[string]any) (string, error) {
+	uri, err := makeToolFileUri(input)
+	if err != nil {
+		return "", err
+	}
+	fileData, err := fileshare.Read(ctx, wshrpc.FileData{Info: &wshrpc.FileInfo{Path: uri}, At: &wshrpc.FileDataAt{Size: MaxToolOutputSize}})
+	if err != nil {
+		return "", fmt.Errorf("error reading file: %w", err)
+	}
+	if fileData.Info != nil && fileData.Info.IsDir {
+		return "", errors.New("path is a directory (use list_directory)")
+	}
+	data, err := base64.StdEncoding.DecodeString(fileData.Data64)
+	if err != nil {
+		return "", fmt.Errorf("error decoding file: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return "", errors.New("not a text file")
+	}
+	return string(data), nil
+}
+
+func runListDirectory(ctx context.Context, tctx *toolContext, input map[string]any) (string, error) {
+	uri, err := makeToolFileUri(input)
+	if err != nil {
+		return "", err
+	}
+	entries, err := fileshare.ListEntries(ctx, uri, &wshrpc.FileListOpts{Limit: MaxDirEntries})
+	if err != nil {
+		return "", fmt.Errorf("error listing directory: %w", err)
+	}
+	var buf strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir {
+			fmt.Fprintf(&buf, "%s/\n", entry.Name)
+		} else {
+			fmt.Fprintf(&buf, "%s\t%d bytes\n", entry.Name, entry.Size)
+		}
+	}
+	if buf.Len() == 0 {
+		return "[empty directory]", nil
+	}
+	return buf.String(), nil
+}
+
+func runGetConnectionStatus(ctx context.Context, tctx *toolContext, input map[string]any) (string, error) {
+	statuses, err := wshclient.ConnStatusCommand(wshclient.GetBareRpcClient(), nil)
+	if err != nil {
+		return "", fmt.Errorf("error getting connection status: %w", err)
+	}
+	if len(statuses) == 0 {
+		return "there are no remote connections", nil
+	}
+	return marshalToolOutput(statuses)
+}
+
+func describeRunCommand(input map[string]any) string {
+	target := "on the local machine"
+	if conn := getStringInput(input, "connection"); conn != "" {
+		target = fmt.Sprintf("on **%s**", conn)
+	}
+	if cwd := getStringInput(input, "cwd"); cwd != "" {
+		target += fmt.Sprintf(" in `%s`", cwd)
+	}
+	return fmt.Sprintf("The AI assistant wants to run a command %s:\n\n```\n%s\n```", target, getStringInput(input, "command"))
+}
+
+func runRunCommand(ctx context.Context, tctx *toolContext, input map[string]any) (string, error) {
+	command := getStringInput(input, "command")
+	if command == "" {
+		return "", errors.New("command is required")
+	}
+	tabId, err := tctx.getTabId()
+	if err != nil {
+		return "", err
+	}
+	meta := starobj.MetaMapType{
+		starobj.MetaKey_View:            "term",
+		starobj.MetaKey_Controller:      "cmd",
+		starobj.MetaKey_Cmd:             command,
+		starobj.MetaKey_CmdShell:        true,
+		starobj.MetaKey_CmdRunOnce:      true,
+		starobj.MetaKey_CmdRunOnStart:   true,
+		starobj.MetaKey_CmdClearOnStart: true,
+	}
+	if conn := getStringInput(input, "connection"); conn != "" && conn != wshrpc.LocalConnName {
+		meta[starobj.MetaKey_Connection] = conn
+	}
+	if cwd := getStringInput(input, "cwd"); cwd != "" {
+		meta[starobj.MetaKey_CmdCwd] = cwd
+	}
+	createData := wshrpc.CommandCreateBlockData{
+		TabId:    tabId,
+		BlockDef: &starobj.BlockDef{Meta: meta},
+	}
+	blockRef, err := wshclient.CreateBlockCommand(wshclient.GetBareRpcClient(), createData, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating block: %w", err)
+	}
+	return fmt.Sprintf("the command was started in block %s (use read_terminal_output to see its output)", blockRef.OID), nil
+}
+
+func formatToolStatus(call wshrpc.StarAIToolCall) string {
+	return fmt.Sprintf("\n\n> tool: `%s` %s\n\n", call.Name, call.Input)
+}
+
+func makeToolContext(ctx context.Context, blockId string) *toolContext {
+	tctx := &toolContext{BlockId: blockId}
+	if blockId != "" {
+		tabId, err := wstore.DBFindTabForBlockId(ctx, blockId)
+		if err == nil {
+			tctx.TabId = tabId
+		}
+	}
+	return tctx
+}
+
+// runs the completion, executes the tool calls from the response and sends the results back to the model,
+// until the model answers without calling a tool (or MaxToolRounds is hit)
+func runWithTools(ctx context.Context, backend AIBackend, request wshrpc.StarAIStreamRequest) chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType] {
+	rtn := make(chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType])
+	go func() {
+		defer func() {
+			panicErr := panichandler.PanicHandler("starai:runWithTools", recover())
+			if panicErr != nil {
+				rtn <- makeAIError(panicErr)
+			}
+			close(rtn)
+		}()
+		tctx := makeToolContext(ctx, request.BlockId)
+		request.Tools = GetToolDefs()
+		prompt := append([]wshrpc.StarAIPromptMessageType(nil), request.Prompt...)
+		for round := 0; round < MaxToolRounds; round++ {
+			request.Prompt = prompt
+			respCh := backend.StreamCompletion(ctx, request)
+			if respCh == nil {
+				rtn <- makeAIError(errors.New("error starting ai request"))
+				return
+			}
+			var text strings.Builder
+			var toolCalls []wshrpc.StarAIToolCall
+			var hadError bool
+			for resp := range respCh {
+				if resp.Error != nil {
+					hadError = true
+				} else {
+					text.WriteString(resp.Response.Text)
+					toolCalls = append(toolCalls, resp.Response.ToolCalls...)
+				}
+				rtn <- resp
+			}
+			if hadError || len(toolCalls) == 0 {
+				return
+			}
+			prompt = append(prompt, wshrpc.StarAIPromptMessageType{Role: "assistant", Content: text.String(), ToolCalls: toolCalls})
+			for _, call := range toolCalls {
+				pk := MakeStarAIPacket()
+				pk.Text = formatToolStatus(call)
+				rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: *pk}
+				output, err := tctx.runTool(ctx, call)
+				resultMsg := wshrpc.StarAIPromptMessageType{Role: toolRole, Name: call.Name, ToolCallId: call.Id, Content: output}
+				if err != nil {
+					resultMsg.Content = err.Error()
+					resultMsg.IsError = true
+				}
+				prompt = append(prompt, resultMsg)
+			}
+		}
+		rtn <- makeAIError(fmt.Errorf("stopped after %d rounds of tool calls", MaxToolRounds))
+	}()
+	return rtn
+}
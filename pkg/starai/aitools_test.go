@@ -0,0 +1,268 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package starai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+// replaces the builtin tools with an echo tool and a tool that needs approval
+func setupTestTools(t *testing.T, approve bool) *[]map[string]any {
+	var approvals []map[string]any
+	oldTools := builtinTools
+	oldGetToolApproval := getToolApproval
+	builtinTools = []*aiTool{
+		{
+			Def: wshrpc.StarAIToolDef{Name: "echo", InputSchema: objectSchema(map[string]any{"text": propSchema("string", "")}, "text")},
+			Run: func(ctx context.Context, tctx *toolContext, input map[string]any) (string, error) {
+				text := getStringInput(input, "text")
+				if text == "" {
+					return "", errors.New("text is required")
+				}
+				return text, nil
+			},
+		},
+		{
+			Def:      wshrpc.StarAIToolDef{Name: "approve", InputSchema: objectSchema(map[string]any{})},
+			Describe: func(input map[string]any) string { return "approve me" },
+			Run: func(ctx context.Context, tctx *toolContext, input map[string]any) (string, error) {
+				return "approved", nil
+			},
+		},
+	}
+	getToolApproval = func(ctx context.Context, tool *aiTool, input map[string]any) (bool, error) {
+		approvals = append(approvals, input)
+		return approve, nil
+	}
+	t.Cleanup(func() {
+		builtinTools = oldTools
+		getToolApproval = oldGetToolApproval
+	})
+	return &approvals
+}
+
+func TestRunTool(t *testing.T) {
+	tests := []struct {
+		name      string
+		call      wshrpc.StarAIToolCall
+		approve   bool
+		want      string
+		errStr    string // "" if the call succeeds
+		approvals int
+	}{
+		{name: "echo", call: wshrpc.StarAIToolCall{Name: "echo", Input: `{"text":"hello"}`}, want: "hello"},
+		{name: "unknown tool", call: wshrpc.StarAIToolCall{Name: "rm_rf", Input: `{}`}, errStr: `unknown tool "rm_rf"`},
+		{name: "invalid input", call: wshrpc.StarAIToolCall{Name: "echo", Input: `{"text":`}, errStr: "invalid tool input"},
+		{name: "input not an object", call: wshrpc.StarAIToolCall{Name: "echo", Input: `["hello"]`}, errStr: "invalid tool input"},
+		{name: "empty input", call: wshrpc.StarAIToolCall{Name: "echo", Input: "  "}, errStr: "text is required"},
+		{name: "wrong argument type", call: wshrpc.StarAIToolCall{Name: "echo", Input: `{"text":5}`}, errStr: "text is required"},
+		{name: "approved", call: wshrpc.StarAIToolCall{Name: "approve"}, approve: true, want: "approved", approvals: 1},
+		{name: "denied", call: wshrpc.StarAIToolCall{Name: "approve"}, errStr: toolDeniedMsg, approvals: 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			approvals := setupTestTools(t, tc.approve)
+			output, err := (&toolContext{}).runTool(context.Background(), tc.call)
+			if tc.errStr == "" && (err != nil || output != tc.want) {
+				t.Errorf("got %q (err %v), want %q", output, err, tc.want)
+			}
+			if tc.errStr != "" && (err == nil || !strings.Contains(err.Error(), tc.errStr)) {
+				t.Errorf("got error %v, want one containing %q", err, tc.errStr)
+			}
+			if len(*approvals) != tc.approvals {
+				t.Errorf("asked for approval %d times, want %d", len(*approvals), tc.approvals)
+			}
+		})
+	}
+}
+
+func TestBuiltinToolArgs(t *testing.T) {
+	oldGetToolApproval := getToolApproval
+	getToolApproval = func(ctx context.Context, tool *aiTool, input map[string]any) (bool, error) { return true, nil }
+	defer func() { getToolApproval = oldGetToolApproval }()
+	tests := []struct {
+		name   string
+		call   wshrpc.StarAIToolCall
+		errStr string
+	}{
+		{"read_terminal_output", wshrpc.StarAIToolCall{Name: "read_terminal_output", Input: `{"maxbytes":100}`}, "blockid is required"},
+		{"read_file", wshrpc.StarAIToolCall{Name: "read_file", Input: `{"connection":"user@host"}`}, "path is required"},
+		{"list_directory", wshrpc.StarAIToolCall{Name: "list_directory", Input: `{"path":""}`}, "path is required"},
+		{"run_command", wshrpc.StarAIToolCall{Name: "run_command", Input: `{"cwd":"/tmp"}`}, "command is required"},
+		{"run_command no tab", wshrpc.StarAIToolCall{Name: "run_command", Input: `{"command":"ls"}`}, "no tab"},
+		{"list_terminal_blocks no tab", wshrpc.StarAIToolCall{Name: "list_terminal_blocks"}, "no tab"},
+	}
+	for _, tc := range tests {
+		_, err := (&toolContext{}).runTool(context.Background(), tc.call)
+		if err == nil || !strings.Contains(err.Error(), tc.errStr) {
+			t.Errorf("%s: got error %v, want one containing %q", tc.name, err, tc.errStr)
+		}
+	}
+	for _, def := range GetToolDefs() {
+		if def.InputSchema["type"] != "object" {
+			t.Errorf("%s: input schema is not an object", def.Name)
+		}
+	}
+}
+
+func TestMakeToolFileUri(t *testing.T) {
+	tests := []struct {
+		input   map[string]any
+		want    string
+		wantErr bool
+	}{
+		{input: map[string]any{"path": "/etc/hosts"}, want: "wsh://" + wshrpc.LocalConnName + "//etc/hosts"},
+		{input: map[string]any{"path": "~/notes.txt", "connection": "user@host"}, want: "wsh://user@host/~/notes.txt"},
+		{input: map[string]any{"path": "s3://bucket/key", "connection": "user@host"}, want: "s3://bucket/key"},
+		{input: map[string]any{"path": "wsh://other/tmp"}, want: "wsh://other/tmp"},
+		{input: map[string]any{"connection": "user@host"}, wantErr: true},
+		{input: map[string]any{"path": 5}, wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := makeToolFileUri(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("makeToolFileUri(%v) = %q, want an error", tc.input, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("makeToolFileUri(%v) = %q (err %v), want %q", tc.input, got, err, tc.want)
+		}
+	}
+}
+
+func TestGetIntInput(t *testing.T) {
+	tests := []struct {
+		input map[string]any
+		want  int
+	}{
+		{map[string]any{"n": float64(100)}, 100},
+		{map[string]any{"n": 10.7}, 10},
+		{map[string]any{"n": float64(0)}, 42},
+		{map[string]any{"n": float64(-5)}, 42},
+		{map[string]any{"n": "100"}, 42},
+		{map[string]any{}, 42},
+	}
+	for _, tc := range tests {
+		if got := getIntInput(tc.input, "n", 42); got != tc.want {
+			t.Errorf("getIntInput(%v) = %d, want %d", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestTruncateToolOutput(t *testing.T) {
+	short := "hello"
+	if got := truncateToolOutput(short); got != short {
+		t.Errorf("short output changed: %q", got)
+	}
+	// a 3 byte rune straddling the limit is dropped whole
+	long := strings.Repeat("a", MaxToolOutputSize-1) + "€" + "tail"
+	got := truncateToolOutput(long)
+	if !strings.HasSuffix(got, "\n[output truncated]") || !utf8.ValidString(got) {
+		t.Fatalf("bad truncated output (len %d, valid %v)", len(got), utf8.ValidString(got))
+	}
+	if body := strings.TrimSuffix(got, "\n[output truncated]"); body != long[:MaxToolOutputSize-1] {
+		t.Errorf("truncated to %d bytes, want %d", len(body), MaxToolOutputSize-1)
+	}
+}
+
+// returns the queued responses in order, one per StreamCompletion call, and records the requests
+type testBackend struct {
+	responses [][]wshrpc.StarAIPacketType
+	requests  []wshrpc.StarAIStreamRequest
+}
+
+func (b *testBackend) StreamCompletion(ctx context.Context, request wshrpc.StarAIStreamRequest) chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType] {
+	b.requests = append(b.requests, request)
+	rtn := make(chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType], 10)
+	if len(b.responses) > 0 {
+		for _, pk := range b.responses[0] {
+			rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: pk}
+		}
+		b.responses = b.responses[1:]
+	}
+	close(rtn)
+	return rtn
+}
+
+func collectAIResponse(ch chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]) (string, error) {
+	var text strings.Builder
+	var lastErr error
+	for resp := range ch {
+		if resp.Error != nil {
+			lastErr = resp.Error
+			continue
+		}
+		text.WriteString(resp.Response.Text)
+	}
+	return text.String(), lastErr
+}
+
+func TestRunWithTools(t *testing.T) {
+	setupTestTools(t, false)
+	backend := &testBackend{responses: [][]wshrpc.StarAIPacketType{
+		{{Text: "let me check"}, {ToolCalls: []wshrpc.StarAIToolCall{
+			{Id: "call-1", Name: "echo", Input: `{"text":"hi"}`},
+			{Id: "call-2", Name: "approve", Input: `{}`},
+			{Id: "call-3", Name: "missing", Input: `{}`},
+		}}},
+		{{Text: "done"}},
+	}}
+	request := wshrpc.StarAIStreamRequest{Prompt: []wshrpc.StarAIPromptMessageType{{Role: "user", Content: "go"}}}
+	text, err := collectAIResponse(runWithTools(context.Background(), backend, request))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(text, "let me check") || !strings.HasSuffix(text, "done") || !strings.Contains(text, "tool: `echo`") {
+		t.Errorf("unexpected response text %q", text)
+	}
+	if len(backend.requests) != 2 {
+		t.Fatalf("got %d completion requests, want 2", len(backend.requests))
+	}
+	if len(backend.requests[0].Tools) != len(builtinTools) {
+		t.Errorf("got %d tool defs, want %d", len(backend.requests[0].Tools), len(builtinTools))
+	}
+	prompt := backend.requests[1].Prompt
+	if len(prompt) != 5 {
+		t.Fatalf("got %d prompt messages, want 5: %+v", len(prompt), prompt)
+	}
+	if prompt[1].Role != "assistant" || prompt[1].Content != "let me check" || len(prompt[1].ToolCalls) != 3 {
+		t.Errorf("unexpected assistant message %+v", prompt[1])
+	}
+	wantResults := []wshrpc.StarAIPromptMessageType{
+		{Role: toolRole, Name: "echo", ToolCallId: "call-1", Content: "hi"},
+		{Role: toolRole, Name: "approve", ToolCallId: "call-2", Content: toolDeniedMsg, IsError: true},
+		{Role: toolRole, Name: "missing", ToolCallId: "call-3", Content: `unknown tool "missing"`, IsError: true},
+	}
+	for idx, want := range wantResults {
+		got := prompt[idx+2]
+		if got.Role != want.Role || got.Name != want.Name || got.ToolCallId != want.ToolCallId || got.Content != want.Content || got.IsError != want.IsError {
+			t.Errorf("tool result %d: got %+v, want %+v", idx, got, want)
+		}
+	}
+}
+
+func TestRunWithToolsMaxRounds(t *testing.T) {
+	setupTestTools(t, false)
+	backend := &testBackend{}
+	for i := 0; i < MaxToolRounds+1; i++ {
+		backend.responses = append(backend.responses, []wshrpc.StarAIPacketType{
+			{ToolCalls: []wshrpc.StarAIToolCall{{Id: "call", Name: "echo", Input: `{"text":"again"}`}}},
+		})
+	}
+	_, err := collectAIResponse(runWithTools(context.Background(), backend, wshrpc.StarAIStreamRequest{}))
+	if err == nil || !strings.Contains(err.Error(), "rounds of tool calls") {
+		t.Fatalf("got error %v, want the max rounds error", err)
+	}
+	if len(backend.requests) != MaxToolRounds {
+		t.Errorf("got %d completion requests, want %d", len(backend.requests), MaxToolRounds)
+	}
+}
@@ -24,7 +24,13 @@ var _ AIBackend = AnthropicBackend{}
 // Claude API request types
 type anthropicMessage struct {
 	Role    string `json:"role"`
-	Content string `json:"content"`
+	Content any    `json:"content"` // string or []anthropicContentBlock
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
 }
 
 type anthropicRequest struct {
@@ -34,12 +40,23 @@ type anthropicRequest struct {
 	MaxTokens   int                `json:"max_tokens,omitempty"`
 	Stream      bool               `json:"stream"`
 	Temperature float32            `json:"temperature,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
 }
 
 // Claude API response types for SSE events
 type anthropicContentBlock struct {
-	Type string `json:"type"` // "text" or other content types
+	Type string `json:"type"` // "text", "tool_use" or "tool_result"
 	Text string `json:"text,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
 }
 
 type anthropicUsage struct {
@@ -64,11 +81,14 @@ type anthropicStreamEventError struct {
 }
 
 type anthropicStreamEventDelta struct {
-	Text string `json:"text"`
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	PartialJSON string `json:"partial_json"` // input_json_delta (tool_use input)
 }
 
 type anthropicStreamEvent struct {
 	Type         string                     `json:"type"`
+	Index        int                        `json:"index"`
 	Message      *anthropicResponseMessage  `json:"message,omitempty"`
 	ContentBlock *anthropicContentBlock     `json:"content_block,omitempty"`
 	Delta        *anthropicStreamEventDelta `json:"delta,omitempty"`
@@ -144,14 +164,23 @@ func (AnthropicBackend) StreamCompletion(ctx context.Context, request wshrpc.Sta
 				continue
 			}
 
+			if msg.Role == toolRole {
+				messages = appendAnthropicToolResult(messages, msg)
+				continue
+			}
+
 			role := "user"
 			if msg.Role == "assistant" {
 				role = "assistant"
 			}
 
+			var content any = msg.Content
+			if len(msg.ToolCalls) > 0 {
+				content = makeAnthropicToolUseContent(msg)
+			}
 			messages = append(messages, anthropicMessage{
 				Role:    role,
-				Content: msg.Content,
+				Content: content,
 			})
 		}
 
@@ -162,6 +191,9 @@ func (AnthropicBackend) StreamCompletion(ctx context.Context, request wshrpc.Sta
 			Stream:    true,
 			MaxTokens: request.Opts.MaxTokens,
 		}
+		for _, tool := range request.Tools {
+			anthropicReq.Tools = append(anthropicReq.Tools, anthropicTool{Name: tool.Name, Description: tool.Description, InputSchema: tool.InputSchema})
+		}
 
 		reqBody, err := json.Marshal(anthropicReq)
 		if err != nil {
@@ -195,6 +227,8 @@ func (AnthropicBackend) StreamCompletion(ctx context.Context, request wshrpc.Sta
 		}
 
 		reader := bufio.NewReader(resp.Body)
+		toolCalls := make(map[int]*wshrpc.StarAIToolCall) // by content block index
+		var toolCallOrder []int
 		for {
 			// Check for context cancellation
 			select {
@@ -237,14 +271,21 @@ func (AnthropicBackend) StreamCompletion(ctx context.Context, request wshrpc.Sta
 				}
 
 			case "content_block_start":
-				if event.ContentBlock != nil && event.ContentBlock.Text != "" {
+				if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+					toolCalls[event.Index] = &wshrpc.StarAIToolCall{Id: event.ContentBlock.ID, Name: event.ContentBlock.Name}
+					toolCallOrder = append(toolCallOrder, event.Index)
+				} else if event.ContentBlock != nil && event.ContentBlock.Text != "" {
 					pk := MakeStarAIPacket()
 					pk.Text = event.ContentBlock.Text
 					rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: *pk}
 				}
 
 			case "content_block_delta":
-				if event.Delta != nil && event.Delta.Text != "" {
+				if event.Delta != nil && event.Delta.Type == "input_json_delta" {
+					if call := toolCalls[event.Index]; call != nil {
+						call.Input += event.Delta.PartialJSON
+					}
+				} else if event.Delta != nil && event.Delta.Text != "" {
 					pk := MakeStarAIPacket()
 					pk.Text = event.Delta.Text
 					rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: *pk}
@@ -268,6 +309,13 @@ func (AnthropicBackend) StreamCompletion(ctx context.Context, request wshrpc.Sta
 				}
 
 			case "message_stop":
+				if len(toolCallOrder) > 0 {
+					pk := MakeStarAIPacket()
+					for _, idx := range toolCallOrder {
+						pk.ToolCalls = append(pk.ToolCalls, *toolCalls[idx])
+					}
+					rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: *pk}
+				}
 				if event.Message != nil {
 					pk := MakeStarAIPacket()
 					pk.FinishReason = event.Message.StopReason
@@ -290,3 +338,31 @@ func (AnthropicBackend) StreamCompletion(ctx context.Context, request wshrpc.Sta
 
 	return rtn
 }
+
+func makeAnthropicToolUseContent(msg wshrpc.StarAIPromptMessageType) []anthropicContentBlock {
+	var rtn []anthropicContentBlock
+	if msg.Content != "" {
+		rtn = append(rtn, anthropicContentBlock{Type: "text", Text: msg.Content})
+	}
+	for _, call := range msg.ToolCalls {
+		input := json.RawMessage(call.Input)
+		if len(strings.TrimSpace(call.Input)) == 0 {
+			input = json.RawMessage("{}")
+		}
+		rtn = append(rtn, anthropicContentBlock{Type: "tool_use", ID: call.Id, Name: call.Name, Input: input})
+	}
+	return rtn
+}
+
+// tool results go in a user message, the results of parallel tool calls are combined into one message
+func appendAnthropicToolResult(messages []anthropicMessage, msg wshrpc.StarAIPromptMessageType) []anthropicMessage {
+	block := anthropicContentBlock{Type: "tool_result", ToolUseID: msg.ToolCallId, Content: msg.Content, IsError: msg.IsError}
+	if len(messages) > 0 {
+		last := &messages[len(messages)-1]
+		if blocks, ok := last.Content.([]anthropicContentBlock); ok && last.Role == "user" && len(blocks) > 0 && blocks[0].Type == "tool_result" {
+			last.Content = append(blocks, block)
+			return messages
+		}
+	}
+	return append(messages, anthropicMessage{Role: "user", Content: []anthropicContentBlock{block}})
+}
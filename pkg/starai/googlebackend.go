@@ -2,8 +2,10 @@ package starai
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/commandlinedev/starterm/pkg/wshrpc"
 	"github.com/google/generative-ai-go/genai"
@@ -29,15 +31,19 @@ func (GoogleBackend) StreamCompletion(ctx context.Context, request wshrpc.StarAI
 		return nil
 	}
 
+	model.Tools = convertToolsToGoogle(request.Tools)
+
+	history, prompt := splitGooglePrompt(request.Prompt)
 	cs := model.StartChat()
-	cs.History = extractHistory(request.Prompt)
-	iter := cs.SendMessageStream(ctx, extractPrompt(request.Prompt))
+	cs.History = extractHistory(history)
+	iter := cs.SendMessageStream(ctx, extractPrompt(prompt)...)
 
 	rtn := make(chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType])
 
 	go func() {
 		defer client.Close()
 		defer close(rtn)
+		var numToolCalls int
 		for {
 			// Check for context cancellation
 			select {
@@ -56,36 +62,145 @@ func (GoogleBackend) StreamCompletion(ctx context.Context, request wshrpc.StarAI
 				break
 			}
 
-			rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: wshrpc.StarAIPacketType{Text: convertCandidatesToText(resp.Candidates)}}
+			pk := MakeStarAIPacket()
+			pk.Text, pk.ToolCalls = convertCandidates(resp.Candidates, &numToolCalls)
+			rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: *pk}
 		}
 	}()
 	return rtn
 }
 
+// the new message is the last message, or all of the trailing tool results (gemini wants the responses to
+// parallel function calls in a single turn)
+func splitGooglePrompt(prompt []wshrpc.StarAIPromptMessageType) ([]wshrpc.StarAIPromptMessageType, []wshrpc.StarAIPromptMessageType) {
+	idx := len(prompt) - 1
+	for idx > 0 && prompt[idx].Role == toolRole && prompt[idx-1].Role == toolRole {
+		idx--
+	}
+	return prompt[:idx], prompt[idx:]
+}
+
+func makeFunctionResponse(msg wshrpc.StarAIPromptMessageType) genai.Part {
+	key := "result"
+	if msg.IsError {
+		key = "error"
+	}
+	return genai.FunctionResponse{Name: msg.Name, Response: map[string]any{key: msg.Content}}
+}
+
 func extractHistory(history []wshrpc.StarAIPromptMessageType) []*genai.Content {
 	var rtn []*genai.Content
-	for _, h := range history[:len(history)-1] {
+	for _, h := range history {
 		if h.Role == "user" || h.Role == "model" {
 			rtn = append(rtn, &genai.Content{
 				Role:  h.Role,
 				Parts: []genai.Part{genai.Text(h.Content)},
 			})
+		} else if h.Role == "assistant" && len(h.ToolCalls) > 0 {
+			var parts []genai.Part
+			if h.Content != "" {
+				parts = append(parts, genai.Text(h.Content))
+			}
+			for _, call := range h.ToolCalls {
+				args := make(map[string]any)
+				json.Unmarshal([]byte(call.Input), &args)
+				parts = append(parts, genai.FunctionCall{Name: call.Name, Args: args})
+			}
+			rtn = append(rtn, &genai.Content{Role: "model", Parts: parts})
+		} else if h.Role == toolRole {
+			if len(rtn) > 0 && rtn[len(rtn)-1].Role == "user" {
+				if _, ok := rtn[len(rtn)-1].Parts[0].(genai.FunctionResponse); ok {
+					rtn[len(rtn)-1].Parts = append(rtn[len(rtn)-1].Parts, makeFunctionResponse(h))
+					continue
+				}
+			}
+			rtn = append(rtn, &genai.Content{Role: "user", Parts: []genai.Part{makeFunctionResponse(h)}})
+		}
+	}
+	return rtn
+}
+
+func extractPrompt(prompt []wshrpc.StarAIPromptMessageType) []genai.Part {
+	if prompt[0].Role != toolRole {
+		return []genai.Part{genai.Text(prompt[0].Content)}
+	}
+	var rtn []genai.Part
+	for _, msg := range prompt {
+		rtn = append(rtn, makeFunctionResponse(msg))
+	}
+	return rtn
+}
+
+func convertSchemaToGoogle(schema map[string]any) *genai.Schema {
+	rtn := &genai.Schema{}
+	rtn.Description, _ = schema["description"].(string)
+	switch schema["type"] {
+	case "object":
+		rtn.Type = genai.TypeObject
+	case "string":
+		rtn.Type = genai.TypeString
+	case "integer":
+		rtn.Type = genai.TypeInteger
+	case "number":
+		rtn.Type = genai.TypeNumber
+	case "boolean":
+		rtn.Type = genai.TypeBoolean
+	case "array":
+		rtn.Type = genai.TypeArray
+	}
+	if props, ok := schema["properties"].(map[string]any); ok {
+		rtn.Properties = make(map[string]*genai.Schema)
+		for name, prop := range props {
+			if propSchema, ok := prop.(map[string]any); ok {
+				rtn.Properties[name] = convertSchemaToGoogle(propSchema)
+			}
 		}
 	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		rtn.Items = convertSchemaToGoogle(items)
+	}
+	if required, ok := schema["required"].([]string); ok {
+		rtn.Required = required
+	}
 	return rtn
 }
 
-func extractPrompt(prompt []wshrpc.StarAIPromptMessageType) genai.Part {
-	p := prompt[len(prompt)-1]
-	return genai.Text(p.Content)
+func convertToolsToGoogle(tools []wshrpc.StarAIToolDef) []*genai.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	var decls []*genai.FunctionDeclaration
+	for _, tool := range tools {
+		decl := &genai.FunctionDeclaration{Name: tool.Name, Description: tool.Description}
+		// gemini rejects objects without properties, tools without input have no parameters
+		if props, _ := tool.InputSchema["properties"].(map[string]any); len(props) > 0 {
+			decl.Parameters = convertSchemaToGoogle(tool.InputSchema)
+		}
+		decls = append(decls, decl)
+	}
+	return []*genai.Tool{{FunctionDeclarations: decls}}
 }
 
-func convertCandidatesToText(candidates []*genai.Candidate) string {
-	var rtn string
+// gemini does not give function calls an id, numToolCalls is used to make one
+func convertCandidates(candidates []*genai.Candidate, numToolCalls *int) (string, []wshrpc.StarAIToolCall) {
+	var text strings.Builder
+	var toolCalls []wshrpc.StarAIToolCall
 	for _, c := range candidates {
+		if c.Content == nil {
+			continue
+		}
 		for _, p := range c.Content.Parts {
-			rtn += fmt.Sprintf("%v", p)
+			switch part := p.(type) {
+			case genai.Text:
+				text.WriteString(string(part))
+			case genai.FunctionCall:
+				input, _ := json.Marshal(part.Args)
+				*numToolCalls++
+				toolCalls = append(toolCalls, wshrpc.StarAIToolCall{Id: fmt.Sprintf("call_%d", *numToolCalls), Name: part.Name, Input: string(input)})
+			default:
+				fmt.Fprintf(&text, "%v", p)
+			}
 		}
 	}
-	return rtn
+	return text.String(), toolCalls
 }
@@ -0,0 +1,419 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package starai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/sconfig"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+// talks to the native ollama api (not the openai compatible /v1 endpoints).
+// a local backend never falls back to the cloud, if ollama can't be reached the request fails.
+type OllamaBackend struct{}
+
+var _ AIBackend = OllamaBackend{}
+
+const DefaultOllamaBaseURL = "http://localhost:11434"
+
+type ollamaToolCallFunction struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+type ollamaToolCall struct {
+	Function ollamaToolCallFunction `json:"function"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+type ollamaTool struct {
+	Type     string             `json:"type"`
+	Function ollamaToolFunction `json:"function"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Tools    []ollamaTool    `json:"tools,omitempty"`
+	Options  map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Model           string         `json:"model"`
+	CreatedAt       time.Time      `json:"created_at"`
+	Message         *ollamaMessage `json:"message,omitempty"`
+	Done            bool           `json:"done"`
+	DoneReason      string         `json:"done_reason,omitempty"`
+	PromptEvalCount int            `json:"prompt_eval_count,omitempty"`
+	EvalCount       int            `json:"eval_count,omitempty"`
+	Error           string         `json:"error,omitempty"`
+}
+
+type ollamaModelDetails struct {
+	Family            string `json:"family"`
+	ParameterSize     string `json:"parameter_size"`
+	QuantizationLevel string `json:"quantization_level"`
+}
+
+type ollamaModel struct {
+	Name       string             `json:"name"`
+	ModifiedAt time.Time          `json:"modified_at"`
+	Size       int64              `json:"size"`
+	Details    ollamaModelDetails `json:"details"`
+}
+
+type ollamaListResponse struct {
+	Models []ollamaModel `json:"models"`
+}
+
+type ollamaPullResponse struct {
+	Status    string `json:"status"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// accepts the base url with or without the /v1 (openai compatible) or /api suffix
+func GetOllamaBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return DefaultOllamaBaseURL
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+	baseURL = strings.TrimSuffix(baseURL, "/v1")
+	baseURL = strings.TrimSuffix(baseURL, "/api")
+	return baseURL
+}
+
+// ollama lists models with a tag, "llama3" is "llama3:latest"
+func normalizeOllamaModelName(name string) string {
+	if !strings.Contains(name, ":") {
+		return name + ":latest"
+	}
+	return name
+}
+
+func doOllamaRequest(ctx context.Context, baseURL string, method string, path string, body any) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		barr, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal ollama request: %v", err)
+		}
+		bodyReader = bytes.NewReader(barr)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ollama request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("request cancelled: %v", ctx.Err())
+		}
+		return nil, fmt.Errorf("cannot reach ollama at %s (is ollama running?): %v", baseURL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(bodyBytes, &errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("Ollama API error: %s - %s", resp.Status, errResp.Error)
+		}
+		return nil, fmt.Errorf("Ollama API error: %s - %s", resp.Status, string(bodyBytes))
+	}
+	return resp, nil
+}
+
+func getOllamaJson(ctx context.Context, baseURL string, path string, rtn any) error {
+	resp, err := doOllamaRequest(ctx, baseURL, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(rtn)
+	if err != nil {
+		return fmt.Errorf("error decoding ollama response: %v", err)
+	}
+	return nil
+}
+
+func ListOllamaModels(ctx context.Context, baseURL string) ([]wshrpc.StarAIModelInfo, error) {
+	baseURL = GetOllamaBaseURL(baseURL)
+	var listResp ollamaListResponse
+	err := getOllamaJson(ctx, baseURL, "/api/tags", &listResp)
+	if err != nil {
+		return nil, err
+	}
+	var rtn []wshrpc.StarAIModelInfo
+	for _, model := range listResp.Models {
+		rtn = append(rtn, wshrpc.StarAIModelInfo{
+			Name:          model.Name,
+			Size:          model.Size,
+			ModifiedTs:    model.ModifiedAt.UnixMilli(),
+			Family:        model.Details.Family,
+			ParameterSize: model.Details.ParameterSize,
+			Quantization:  model.Details.QuantizationLevel,
+		})
+	}
+	return rtn, nil
+}
+
+func ollamaHasModel(models []ollamaModel, name string) bool {
+	name = normalizeOllamaModelName(name)
+	for _, model := range models {
+		if normalizeOllamaModelName(model.Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func sendOllamaStatus(rtn chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType], status string) {
+	pk := MakeStarAIPacket()
+	pk.Status = status
+	rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: *pk}
+}
+
+func pullOllamaModel(ctx context.Context, baseURL string, model string, rtn chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]) error {
+	resp, err := doOllamaRequest(ctx, baseURL, http.MethodPost, "/api/pull", map[string]any{"model": model, "stream": true})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	lastStatus := ""
+	for {
+		var pullResp ollamaPullResponse
+		err := decoder.Decode(&pullResp)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading ollama pull response: %v", err)
+		}
+		if pullResp.Error != "" {
+			return fmt.Errorf("error pulling %s: %s", model, pullResp.Error)
+		}
+		status := fmt.Sprintf("pulling %s: %s", model, pullResp.Status)
+		if pullResp.Total > 0 {
+			// per-layer progress, only report whole percents
+			status = fmt.Sprintf("pulling %s: %d%%", model, pullResp.Completed*100/pullResp.Total)
+		}
+		if status != lastStatus {
+			sendOllamaStatus(rtn, status)
+			lastStatus = status
+		}
+	}
+}
+
+// pulls the model if it is not installed, and reports when it has to be loaded into memory (which can take a while)
+func ensureOllamaModel(ctx context.Context, baseURL string, model string, rtn chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]) error {
+	var installed ollamaListResponse
+	err := getOllamaJson(ctx, baseURL, "/api/tags", &installed)
+	if err != nil {
+		return err
+	}
+	if !ollamaHasModel(installed.Models, model) {
+		err = pullOllamaModel(ctx, baseURL, model, rtn)
+		if err != nil {
+			return err
+		}
+	}
+	var running ollamaListResponse
+	err = getOllamaJson(ctx, baseURL, "/api/ps", &running)
+	if err != nil {
+		return err
+	}
+	if !ollamaHasModel(running.Models, model) {
+		sendOllamaStatus(rtn, fmt.Sprintf("loading %s", model))
+	}
+	return nil
+}
+
+func convertPromptToOllama(prompt []wshrpc.StarAIPromptMessageType) []ollamaMessage {
+	var rtn []ollamaMessage
+	for _, p := range prompt {
+		msg := ollamaMessage{Role: p.Role, Content: p.Content}
+		if p.Role == toolRole {
+			msg.ToolName = p.Name
+		}
+		for _, call := range p.ToolCalls {
+			args := make(map[string]any)
+			json.Unmarshal([]byte(call.Input), &args)
+			msg.ToolCalls = append(msg.ToolCalls, ollamaToolCall{Function: ollamaToolCallFunction{Name: call.Name, Arguments: args}})
+		}
+		rtn = append(rtn, msg)
+	}
+	return rtn
+}
+
+func (OllamaBackend) StreamCompletion(ctx context.Context, request wshrpc.StarAIStreamRequest) chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType] {
+	rtn := make(chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType])
+	go func() {
+		defer func() {
+			panicErr := panichandler.PanicHandler("OllamaBackend.StreamCompletion", recover())
+			if panicErr != nil {
+				rtn <- makeAIError(panicErr)
+			}
+			close(rtn)
+		}()
+		if request.Opts == nil {
+			rtn <- makeAIError(errors.New("no ollama opts found"))
+			return
+		}
+		if request.Opts.Model == "" {
+			rtn <- makeAIError(errors.New("no ollama model specified"))
+			return
+		}
+		baseURL := GetOllamaBaseURL(request.Opts.BaseURL)
+		err := ensureOllamaModel(ctx, baseURL, request.Opts.Model, rtn)
+		if err != nil {
+			rtn <- makeAIError(err)
+			return
+		}
+
+		chatReq := ollamaChatRequest{
+			Model:    request.Opts.Model,
+			Messages: convertPromptToOllama(request.Prompt),
+			Stream:   true,
+		}
+		if request.Opts.MaxTokens > 0 {
+			chatReq.Options = map[string]any{"num_predict": request.Opts.MaxTokens}
+		}
+		for _, tool := range request.Tools {
+			chatReq.Tools = append(chatReq.Tools, ollamaTool{
+				Type:     "function",
+				Function: ollamaToolFunction{Name: tool.Name, Description: tool.Description, Parameters: tool.InputSchema},
+			})
+		}
+		resp, err := doOllamaRequest(ctx, baseURL, http.MethodPost, "/api/chat", chatReq)
+		if err != nil {
+			rtn <- makeAIError(err)
+			return
+		}
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		sentHeader := false
+		var toolCalls []wshrpc.StarAIToolCall
+		for {
+			var chatResp ollamaChatResponse
+			err := decoder.Decode(&chatResp)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					rtn <- makeAIError(fmt.Errorf("request cancelled: %v", ctx.Err()))
+				} else {
+					rtn <- makeAIError(fmt.Errorf("error reading ollama response: %v", err))
+				}
+				return
+			}
+			if chatResp.Error != "" {
+				rtn <- makeAIError(fmt.Errorf("Ollama API error: %s", chatResp.Error))
+				return
+			}
+			if !sentHeader {
+				pk := MakeStarAIPacket()
+				pk.Model = chatResp.Model
+				pk.Created = chatResp.CreatedAt.Unix()
+				rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: *pk}
+				sentHeader = true
+			}
+			if chatResp.Message != nil {
+				for _, call := range chatResp.Message.ToolCalls {
+					input, _ := json.Marshal(call.Function.Arguments)
+					// ollama does not give tool calls an id
+					toolCalls = append(toolCalls, wshrpc.StarAIToolCall{Id: fmt.Sprintf("call_%d", len(toolCalls)+1), Name: call.Function.Name, Input: string(input)})
+				}
+				if chatResp.Message.Content != "" {
+					pk := MakeStarAIPacket()
+					pk.Text = chatResp.Message.Content
+					rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: *pk}
+				}
+			}
+			if chatResp.Done {
+				pk := MakeStarAIPacket()
+				pk.FinishReason = chatResp.DoneReason
+				pk.ToolCalls = toolCalls
+				pk.Usage = &wshrpc.StarAIUsageType{
+					PromptTokens:     chatResp.PromptEvalCount,
+					CompletionTokens: chatResp.EvalCount,
+					TotalTokens:      chatResp.PromptEvalCount + chatResp.EvalCount,
+				}
+				rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: *pk}
+				break
+			}
+		}
+	}()
+	return rtn
+}
+
+const OllamaPresetsFile = "presets/ollama.json"
+
+func makeOllamaPresetKey(modelName string) string {
+	return "ai@ollama-" + strings.NewReplacer(":", "-", "/", "-", ".", "-").Replace(modelName)
+}
+
+// (re)writes presets/ollama.json with a preset for every model, returns the full path of the file
+func WriteOllamaPresets(baseURL string, models []wshrpc.StarAIModelInfo) (string, error) {
+	presets := make(starobj.MetaMapType)
+	for idx, model := range models {
+		preset := starobj.MetaMapType{
+			"display:name":            "Ollama - " + model.Name,
+			"display:order":           float64(10 + idx),
+			starobj.MetaKey_AiClear:   true,
+			starobj.MetaKey_AiApiType: ApiType_Ollama,
+			starobj.MetaKey_AiModel:   model.Name,
+			starobj.MetaKey_AiName:    model.Name,
+		}
+		if baseURL != "" && GetOllamaBaseURL(baseURL) != DefaultOllamaBaseURL {
+			preset[starobj.MetaKey_AiBaseURL] = GetOllamaBaseURL(baseURL)
+		}
+		presets[makeOllamaPresetKey(model.Name)] = preset
+	}
+	presetsDir := filepath.Join(starbase.GetStarConfigDir(), filepath.Dir(OllamaPresetsFile))
+	err := os.MkdirAll(presetsDir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("error creating presets directory: %w", err)
+	}
+	err = sconfig.WriteStarHomeConfigFile(OllamaPresetsFile, presets)
+	if err != nil {
+		return "", fmt.Errorf("error writing presets: %w", err)
+	}
+	return filepath.Join(starbase.GetStarConfigDir(), OllamaPresetsFile), nil
+}
@@ -0,0 +1,257 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package starai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+const testOllamaTags = `{"models":[
+	{"name":"llama3:latest","modified_at":"2025-01-02T03:04:05Z","size":4661224676,"details":{"family":"llama","parameter_size":"8.0B","quantization_level":"Q4_0"}},
+	{"name":"qwen2.5-coder:7b","modified_at":"2025-02-01T00:00:00Z","size":4683087332,"details":{"family":"qwen2","parameter_size":"7.6B","quantization_level":"Q4_K_M"}}
+]}`
+
+// writes each line as its own flushed chunk, like ollama's streaming responses
+func writeOllamaLines(w http.ResponseWriter, lines ...string) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+}
+
+func drainStatuses(rtn chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]) []string {
+	var statuses []string
+	for {
+		select {
+		case resp := <-rtn:
+			statuses = append(statuses, resp.Response.Status)
+		default:
+			return statuses
+		}
+	}
+}
+
+func TestGetOllamaBaseURL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", DefaultOllamaBaseURL},
+		{"http://gpu-box:11434", "http://gpu-box:11434"},
+		{"http://gpu-box:11434/", "http://gpu-box:11434"},
+		{"http://gpu-box:11434/v1", "http://gpu-box:11434"},
+		{"http://gpu-box:11434/v1/", "http://gpu-box:11434"},
+		{"http://gpu-box:11434/api", "http://gpu-box:11434"},
+	}
+	for _, tc := range tests {
+		if got := GetOllamaBaseURL(tc.input); got != tc.want {
+			t.Errorf("GetOllamaBaseURL(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestListOllamaModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, testOllamaTags)
+	}))
+	defer server.Close()
+	for _, baseURL := range []string{server.URL, server.URL + "/v1", server.URL + "/api/"} {
+		models, err := ListOllamaModels(context.Background(), baseURL)
+		if err != nil {
+			t.Fatalf("%s: error listing models: %v", baseURL, err)
+		}
+		if len(models) != 2 {
+			t.Fatalf("%s: got %d models, want 2", baseURL, len(models))
+		}
+		want := wshrpc.StarAIModelInfo{
+			Name:          "llama3:latest",
+			Size:          4661224676,
+			ModifiedTs:    time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC).UnixMilli(),
+			Family:        "llama",
+			ParameterSize: "8.0B",
+			Quantization:  "Q4_0",
+		}
+		if models[0] != want {
+			t.Errorf("%s: got %+v, want %+v", baseURL, models[0], want)
+		}
+		if models[1].Name != "qwen2.5-coder:7b" || models[1].Quantization != "Q4_K_M" {
+			t.Errorf("%s: unexpected model %+v", baseURL, models[1])
+		}
+	}
+}
+
+func TestListOllamaModelsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		errStr  string
+	}{
+		{
+			name: "api error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, `{"error":"out of memory"}`)
+			},
+			errStr: "out of memory",
+		},
+		{
+			name: "plain error body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+			},
+			errStr: "bad gateway",
+		},
+		{
+			name: "bad json",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"models":`)
+			},
+			errStr: "error decoding ollama response",
+		},
+	}
+	for _, tc := range tests {
+		server := httptest.NewServer(tc.handler)
+		_, err := ListOllamaModels(context.Background(), server.URL)
+		server.Close()
+		if err == nil || !strings.Contains(err.Error(), tc.errStr) {
+			t.Errorf("%s: got error %v, want one containing %q", tc.name, err, tc.errStr)
+		}
+	}
+	// the server is gone
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	if _, err := ListOllamaModels(context.Background(), server.URL); err == nil || !strings.Contains(err.Error(), "is ollama running") {
+		t.Errorf("unreachable server: got error %v", err)
+	}
+}
+
+func TestPullOllamaModel(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		statuses []string
+		errStr   string
+	}{
+		{
+			name: "progress",
+			lines: []string{
+				`{"status":"pulling manifest"}`,
+				`{"status":"pulling manifest"}`,
+				`{"status":"pulling 6a0746a1ec1a","digest":"sha256:6a0746a1ec1a","total":1000}`,
+				`{"status":"pulling 6a0746a1ec1a","digest":"sha256:6a0746a1ec1a","total":1000,"completed":4}`,
+				`{"status":"pulling 6a0746a1ec1a","digest":"sha256:6a0746a1ec1a","total":1000,"completed":502}`,
+				`{"status":"pulling 6a0746a1ec1a","digest":"sha256:6a0746a1ec1a","total":1000,"completed":509}`,
+				`{"status":"pulling 6a0746a1ec1a","digest":"sha256:6a0746a1ec1a","total":1000,"completed":1000}`,
+				`{"status":"verifying sha256 digest"}`,
+				`{"status":"success"}`,
+			},
+			statuses: []string{
+				"pulling llama3: pulling manifest",
+				"pulling llama3: 0%",
+				"pulling llama3: 50%",
+				"pulling llama3: 100%",
+				"pulling llama3: verifying sha256 digest",
+				"pulling llama3: success",
+			},
+		},
+		{
+			name:     "error line",
+			lines:    []string{`{"status":"pulling manifest"}`, `{"error":"pull model manifest: file does not exist"}`},
+			statuses: []string{"pulling llama3: pulling manifest"},
+			errStr:   "error pulling llama3: pull model manifest: file does not exist",
+		},
+		{
+			name:   "bad json",
+			lines:  []string{`{"status":`},
+			errStr: "error reading ollama pull response",
+		},
+	}
+	for _, tc := range tests {
+		var pullReq map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.URL.Path != "/api/pull" {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&pullReq)
+			writeOllamaLines(w, tc.lines...)
+		}))
+		rtn := make(chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType], len(tc.lines))
+		err := pullOllamaModel(context.Background(), server.URL, "llama3", rtn)
+		server.Close()
+		if tc.errStr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+		if tc.errStr != "" && (err == nil || !strings.Contains(err.Error(), tc.errStr)) {
+			t.Errorf("%s: got error %v, want one containing %q", tc.name, err, tc.errStr)
+		}
+		if pullReq["model"] != "llama3" || pullReq["stream"] != true {
+			t.Errorf("%s: unexpected pull request %v", tc.name, pullReq)
+		}
+		statuses := drainStatuses(rtn)
+		if strings.Join(statuses, "\n") != strings.Join(tc.statuses, "\n") {
+			t.Errorf("%s: got statuses %q, want %q", tc.name, statuses, tc.statuses)
+		}
+	}
+}
+
+func TestEnsureOllamaModel(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		running  string
+		pulled   bool
+		statuses []string
+	}{
+		{name: "installed and loaded", model: "llama3", running: `{"models":[{"name":"llama3:latest"}]}`},
+		{name: "installed", model: "qwen2.5-coder:7b", running: `{"models":[]}`, statuses: []string{"loading qwen2.5-coder:7b"}},
+		{name: "not installed", model: "mistral", running: `{"models":[]}`, pulled: true, statuses: []string{"pulling mistral: success", "loading mistral"}},
+	}
+	for _, tc := range tests {
+		pulled := false
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/tags":
+				fmt.Fprint(w, testOllamaTags)
+			case "/api/ps":
+				fmt.Fprint(w, tc.running)
+			case "/api/pull":
+				pulled = true
+				writeOllamaLines(w, `{"status":"success"}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		rtn := make(chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType], 10)
+		err := ensureOllamaModel(context.Background(), server.URL, tc.model, rtn)
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if pulled != tc.pulled {
+			t.Errorf("%s: pulled %v, want %v", tc.name, pulled, tc.pulled)
+		}
+		statuses := drainStatuses(rtn)
+		if strings.Join(statuses, "\n") != strings.Join(tc.statuses, "\n") {
+			t.Errorf("%s: got statuses %q, want %q", tc.name, statuses, tc.statuses)
+		}
+	}
+}
@@ -56,11 +56,80 @@ func convertPrompt(prompt []wshrpc.StarAIPromptMessageType) []openaiapi.ChatComp
 	var rtn []openaiapi.ChatCompletionMessage
 	for _, p := range prompt {
 		msg := openaiapi.ChatCompletionMessage{Role: p.Role, Content: p.Content, Name: p.Name}
+		if p.Role == toolRole {
+			msg.Role = openaiapi.ChatMessageRoleTool
+			msg.Name = ""
+			msg.ToolCallID = p.ToolCallId
+		}
+		for _, call := range p.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, openaiapi.ToolCall{
+				ID:       call.Id,
+				Type:     openaiapi.ToolTypeFunction,
+				Function: openaiapi.FunctionCall{Name: call.Name, Arguments: call.Input},
+			})
+		}
 		rtn = append(rtn, msg)
 	}
 	return rtn
 }
 
+func convertTools(tools []wshrpc.StarAIToolDef) []openaiapi.Tool {
+	var rtn []openaiapi.Tool
+	for _, tool := range tools {
+		rtn = append(rtn, openaiapi.Tool{
+			Type: openaiapi.ToolTypeFunction,
+			Function: &openaiapi.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+	return rtn
+}
+
+func convertToolCalls(calls []openaiapi.ToolCall) []wshrpc.StarAIToolCall {
+	var rtn []wshrpc.StarAIToolCall
+	for _, call := range calls {
+		rtn = append(rtn, wshrpc.StarAIToolCall{Id: call.ID, Name: call.Function.Name, Input: call.Function.Arguments})
+	}
+	return rtn
+}
+
+// tool calls are streamed in pieces (the arguments are split across deltas), keyed by their index
+type openaiToolCallAccumulator struct {
+	calls []*openaiapi.ToolCall
+}
+
+func (acc *openaiToolCallAccumulator) add(delta openaiapi.ToolCall) {
+	idx := len(acc.calls)
+	if delta.Index != nil {
+		idx = *delta.Index
+	}
+	for len(acc.calls) <= idx {
+		acc.calls = append(acc.calls, &openaiapi.ToolCall{Type: openaiapi.ToolTypeFunction})
+	}
+	call := acc.calls[idx]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Function.Name != "" {
+		call.Function.Name = delta.Function.Name
+	}
+	call.Function.Arguments += delta.Function.Arguments
+}
+
+func (acc *openaiToolCallAccumulator) getCalls() []wshrpc.StarAIToolCall {
+	var rtn []wshrpc.StarAIToolCall
+	for _, call := range acc.calls {
+		if call.Function.Name == "" {
+			continue
+		}
+		rtn = append(rtn, wshrpc.StarAIToolCall{Id: call.ID, Name: call.Function.Name, Input: call.Function.Arguments})
+	}
+	return rtn
+}
+
 func (OpenAIBackend) StreamCompletion(ctx context.Context, request wshrpc.StarAIStreamRequest) chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType] {
 	rtn := make(chan wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType])
 	go func() {
@@ -104,6 +173,7 @@ func (OpenAIBackend) StreamCompletion(ctx context.Context, request wshrpc.StarAI
 		req := openaiapi.ChatCompletionRequest{
 			Model:    request.Opts.Model,
 			Messages: convertPrompt(request.Prompt),
+			Tools:    convertTools(request.Tools),
 		}
 
 		// Handle o1 models differently - use non-streaming API
@@ -130,6 +200,9 @@ func (OpenAIBackend) StreamCompletion(ctx context.Context, request wshrpc.StarAI
 				pk.Index = i
 				pk.Text = choice.Message.Content
 				pk.FinishReason = string(choice.FinishReason)
+				if i == 0 {
+					pk.ToolCalls = convertToolCalls(choice.Message.ToolCalls)
+				}
 				rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: *pk}
 			}
 			return
@@ -148,9 +221,15 @@ func (OpenAIBackend) StreamCompletion(ctx context.Context, request wshrpc.StarAI
 			return
 		}
 		sentHeader := false
+		var toolCallAcc openaiToolCallAccumulator
 		for {
 			streamResp, err := apiResp.Recv()
 			if err == io.EOF {
+				if toolCalls := toolCallAcc.getCalls(); len(toolCalls) > 0 {
+					pk := MakeStarAIPacket()
+					pk.ToolCalls = toolCalls
+					rtn <- wshrpc.RespOrErrorUnion[wshrpc.StarAIPacketType]{Response: *pk}
+				}
 				break
 			}
 			if err != nil {
@@ -165,6 +244,11 @@ func (OpenAIBackend) StreamCompletion(ctx context.Context, request wshrpc.StarAI
 				sentHeader = true
 			}
 			for _, choice := range streamResp.Choices {
+				if choice.Index == 0 {
+					for _, delta := range choice.Delta.ToolCalls {
+						toolCallAcc.add(delta)
+					}
+				}
 				pk := MakeStarAIPacket()
 				pk.Index = choice.Index
 				pk.Text = choice.Delta.Content
@@ -17,6 +17,7 @@ const ApiType_Anthropic = "anthropic"
 const ApiType_Perplexity = "perplexity"
 const APIType_Google = "google"
 const APIType_OpenAI = "openai"
+const ApiType_Ollama = "ollama"
 
 type StarAICmdInfoPacketOutputType struct {
 	Model        string `json:"model,omitempty"`
@@ -49,6 +50,10 @@ func IsCloudAIRequest(opts *wshrpc.StarAIOptsType) bool {
 	if opts == nil {
 		return true
 	}
+	if opts.APIType == ApiType_Ollama {
+		// local backends never go to the cloud (even without a base url)
+		return false
+	}
 	return opts.BaseURL == "" && opts.APIToken == ""
 }
 
@@ -74,6 +79,10 @@ func RunAICommand(ctx context.Context, request wshrpc.StarAIStreamRequest) chan
 	} else if request.Opts.APIType == APIType_Google {
 		backend = GoogleBackend{}
 		backendType = APIType_Google
+	} else if request.Opts.APIType == ApiType_Ollama {
+		endpoint = GetOllamaBaseURL(request.Opts.BaseURL)
+		backend = OllamaBackend{}
+		backendType = ApiType_Ollama
 	} else if IsCloudAIRequest(request.Opts) {
 		endpoint = "starterm cloud"
 		request.Opts.APIType = APIType_OpenAI
@@ -96,5 +105,12 @@ func RunAICommand(ctx context.Context, request wshrpc.StarAIStreamRequest) chan
 	})
 
 	log.Printf("sending ai chat message to %s endpoint %q using model %s\n", request.Opts.APIType, endpoint, request.Opts.Model)
+	if request.Opts.Tools && backendSupportsTools(backendType) {
+		return runWithTools(ctx, backend, request)
+	}
 	return backend.StreamCompletion(ctx, request)
 }
+
+func backendSupportsTools(backendType string) bool {
+	return backendType == ApiType_Anthropic || backendType == APIType_Google || backendType == APIType_OpenAI || backendType == ApiType_Ollama
+}
@@ -38,10 +38,11 @@ const (
 )
 
 const (
-	BlockFile_Term  = "term"            // used for main pty output
-	BlockFile_Cache = "cache:term:full" // for cached block
-	BlockFile_VDom  = "vdom"            // used for alt html layout
-	BlockFile_Env   = "env"
+	BlockFile_Term   = "term"            // used for main pty output
+	BlockFile_Cache  = "cache:term:full" // for cached block
+	BlockFile_VDom   = "vdom"            // used for alt html layout
+	BlockFile_Env    = "env"
+	BlockFile_Record = "term.cast" // asciicast v2 recording of the pty output (term:record)
 )
 
 const NeedJwtConst = "NEED-JWT"
@@ -55,12 +56,13 @@ const StarLockFile = "star.lock"
 const DomainSocketBaseName = "star.sock"
 const RemoteDomainSocketBaseName = "star-remote.sock"
 const StarDBDir = "db"
-const JwtSecret = "starterm" // TODO generate and store this
+const JwtKeysFileName = "jwtkeys.json"
 const ConfigDir = "config"
 const RemoteStarHomeDirName = ".starterm"
 const RemoteWshBinDirName = "bin"
 const RemoteFullWshBinPath = "~/.starterm/bin/wsh"
 const RemoteFullDomainSocketPath = "~/.starterm/star-remote.sock"
+const RemoteTrashDir = "~/.starterm/trash"
 
 const AppPathBinDir = "bin"
 
@@ -48,6 +48,8 @@ const (
 	MetaKey_CmdCloseOnExitForce              = "cmd:closeonexitforce"
 	MetaKey_CmdCloseOnExitDelay              = "cmd:closeonexitdelay"
 	MetaKey_CmdNoWsh                         = "cmd:nowsh"
+	MetaKey_CmdNoHistory                     = "cmd:nohistory"
+	MetaKey_CmdWshCaps                       = "cmd:wshcaps"
 	MetaKey_CmdArgs                          = "cmd:args"
 	MetaKey_CmdShell                         = "cmd:shell"
 	MetaKey_CmdAllowConnChange               = "cmd:allowconnchange"
@@ -71,6 +73,7 @@ const (
 	MetaKey_AIApiVersion                     = "ai:apiversion"
 	MetaKey_AiMaxTokens                      = "ai:maxtokens"
 	MetaKey_AiTimeoutMs                      = "ai:timeoutms"
+	MetaKey_AiTools                          = "ai:tools"
 
 	MetaKey_EditorClear                      = "editor:*"
 	MetaKey_EditorMinimapEnabled             = "editor:minimapenabled"
@@ -103,6 +106,8 @@ const (
 	MetaKey_TermTransparency                 = "term:transparency"
 	MetaKey_TermAllowBracketedPaste          = "term:allowbracketedpaste"
 	MetaKey_TermConnDebug                    = "term:conndebug"
+	MetaKey_TermArchive                      = "term:archive"
+	MetaKey_TermRecord                       = "term:record"
 
 	MetaKey_WebZoom                          = "web:zoom"
 	MetaKey_WebHideNav                       = "web:hidenav"
@@ -47,6 +47,8 @@ type MetaTSType struct {
 	CmdCloseOnExitForce bool     `json:"cmd:closeonexitforce,omitempty"`
 	CmdCloseOnExitDelay float64  `json:"cmd:closeonexitdelay,omitempty"`
 	CmdNoWsh            bool     `json:"cmd:nowsh,omitempty"`
+	CmdNoHistory        bool     `json:"cmd:nohistory,omitempty"`
+	CmdWshCaps          []string `json:"cmd:wshcaps,omitempty"`
 	CmdArgs             []string `json:"cmd:args,omitempty"`  // args for cmd (only if cmd:shell is false)
 	CmdShell            bool     `json:"cmd:shell,omitempty"` // shell expansion for cmd+args (defaults to true)
 	CmdAllowConnChange  bool     `json:"cmd:allowconnchange,omitempty"`
@@ -73,6 +75,7 @@ type MetaTSType struct {
 	AIApiVersion string  `json:"ai:apiversion,omitempty"`
 	AiMaxTokens  float64 `json:"ai:maxtokens,omitempty"`
 	AiTimeoutMs  float64 `json:"ai:timeoutms,omitempty"`
+	AiTools      bool    `json:"ai:tools,omitempty"`
 
 	EditorClear               bool `json:"editor:*,omitempty"`
 	EditorMinimapEnabled      bool `json:"editor:minimapenabled,omitempty"`
@@ -106,6 +109,8 @@ type MetaTSType struct {
 	TermTransparency        *float64 `json:"term:transparency,omitempty"` // default 0.5
 	TermAllowBracketedPaste *bool    `json:"term:allowbracketedpaste,omitempty"`
 	TermConnDebug           string   `json:"term:conndebug,omitempty"` // null, info, debug
+	TermArchive             bool     `json:"term:archive,omitempty"`
+	TermRecord              bool     `json:"term:record,omitempty"`
 
 	WebZoom      float64 `json:"web:zoom,omitempty"`
 	WebHideNav   *bool   `json:"web:hidenav,omitempty"`
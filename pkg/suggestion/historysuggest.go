@@ -0,0 +1,144 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package suggestion
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/cmdhistory"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/junegunn/fzf/src/algo"
+	"github.com/junegunn/fzf/src/util"
+)
+
+// how many history items are considered for ranking
+const MaxHistoryCandidates = 5000
+
+const (
+	historyPrefixBonus   = 1000
+	historyCwdBonus      = 300
+	historyMaxCountBonus = 500
+)
+
+type historyCandidate struct {
+	cmd      string
+	count    int
+	cwdCount int
+	lastTs   int64
+	lastCwd  string
+	score    int
+	matchPos []int
+}
+
+func historyRecencyBonus(ts int64) int {
+	age := time.Since(time.UnixMilli(ts))
+	switch {
+	case age < time.Hour:
+		return 200
+	case age < 24*time.Hour:
+		return 100
+	case age < 7*24*time.Hour:
+		return 50
+	}
+	return 0
+}
+
+// ranks commands from the history.  prefix matches beat fuzzy matches, and commands that were
+// run before in the current directory (history:cwd) get a boost.  duplicates are merged (frequency also counts).
+func fetchHistorySuggestions(ctx context.Context, data wshrpc.FetchSuggestionsData) (*wshrpc.FetchSuggestionsResponse, error) {
+	if data.SuggestionType != "history" {
+		return nil, fmt.Errorf("unsupported suggestion type: %q", data.SuggestionType)
+	}
+	items, err := cmdhistory.GetRecentHistory(ctx, data.HistoryConnection, MaxHistoryCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	return &wshrpc.FetchSuggestionsResponse{
+		Suggestions: rankHistory(items, data),
+		ReqNum:      data.ReqNum,
+	}, nil
+}
+
+// items must be newest first (as returned by cmdhistory.GetRecentHistory)
+func rankHistory(items []wshrpc.HistoryItem, data wshrpc.FetchSuggestionsData) []wshrpc.SuggestionType {
+	candidateMap := make(map[string]*historyCandidate)
+	var candidates []*historyCandidate
+	for _, item := range items {
+		cand := candidateMap[item.Cmd]
+		if cand == nil {
+			// items are newest first, so the first one we see has the last ts/cwd
+			cand = &historyCandidate{cmd: item.Cmd, lastTs: item.Ts, lastCwd: item.Cwd}
+			candidateMap[item.Cmd] = cand
+			candidates = append(candidates, cand)
+		}
+		cand.count++
+		if data.HistoryCwd != "" && item.Cwd == data.HistoryCwd {
+			cand.cwdCount++
+		}
+	}
+
+	lowerQuery := strings.ToLower(data.Query)
+	patternRunes := []rune(lowerQuery)
+	var slab util.Slab
+	var scored []*historyCandidate
+	for _, cand := range candidates {
+		if lowerQuery != "" {
+			lowerCmd := strings.ToLower(cand.cmd)
+			if strings.HasPrefix(lowerCmd, lowerQuery) {
+				cand.score = historyPrefixBonus
+				for idx := range patternRunes {
+					cand.matchPos = append(cand.matchPos, idx)
+				}
+			} else {
+				text := util.ToChars([]byte(lowerCmd))
+				result, positions := algo.FuzzyMatchV2(false, true, true, &text, patternRunes, true, &slab)
+				if result.Score <= 0 {
+					continue
+				}
+				cand.score = result.Score
+				if positions != nil {
+					cand.matchPos = *positions
+				}
+			}
+		}
+		cand.score += min(cand.count*10, historyMaxCountBonus) + historyRecencyBonus(cand.lastTs)
+		if cand.cwdCount > 0 {
+			cand.score += historyCwdBonus + min(cand.cwdCount*10, historyMaxCountBonus)
+		}
+		scored = append(scored, cand)
+	}
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].score != scored[j].score {
+			return scored[i].score > scored[j].score
+		}
+		return scored[i].lastTs > scored[j].lastTs
+	})
+
+	var suggestions []wshrpc.SuggestionType
+	for _, cand := range scored {
+		subText := cand.lastCwd
+		if cand.cwdCount > 0 {
+			subText = data.HistoryCwd
+		}
+		sort.Ints(cand.matchPos)
+		suggestions = append(suggestions, wshrpc.SuggestionType{
+			Type:         "history",
+			SuggestionId: utilfn.QuickHashString(cand.cmd),
+			Display:      cand.cmd,
+			SubText:      subText,
+			Icon:         "clock-rotate-left",
+			MatchPos:     cand.matchPos,
+			Score:        cand.score,
+		})
+		if len(suggestions) >= MaxSuggestions {
+			break
+		}
+	}
+	return suggestions
+}
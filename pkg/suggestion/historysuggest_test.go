@@ -0,0 +1,114 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package suggestion
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+// makes history items (newest first) from commands, each one a minute older than the one before
+func makeHistoryItems(cwd string, cmds ...string) []wshrpc.HistoryItem {
+	now := time.Now().UnixMilli()
+	var rtn []wshrpc.HistoryItem
+	for idx, cmd := range cmds {
+		rtn = append(rtn, wshrpc.HistoryItem{Cmd: cmd, Cwd: cwd, Ts: now - int64(idx)*time.Minute.Milliseconds()})
+	}
+	return rtn
+}
+
+func suggestionCmds(suggestions []wshrpc.SuggestionType) []string {
+	var rtn []string
+	for _, s := range suggestions {
+		rtn = append(rtn, s.Display)
+	}
+	return rtn
+}
+
+func TestRankHistory(t *testing.T) {
+	oldTs := time.Now().Add(-30 * 24 * time.Hour).UnixMilli()
+	tests := []struct {
+		name  string
+		items []wshrpc.HistoryItem
+		data  wshrpc.FetchSuggestionsData
+		want  []string
+	}{
+		{
+			"prefix beats fuzzy and recency",
+			append(makeHistoryItems("/", "echo gist"), wshrpc.HistoryItem{Cmd: "git status", Ts: oldTs}),
+			wshrpc.FetchSuggestionsData{Query: "gi"},
+			[]string{"git status", "echo gist"},
+		},
+		{
+			"case insensitive",
+			makeHistoryItems("/", "ls", "Git Push"),
+			wshrpc.FetchSuggestionsData{Query: "git"},
+			[]string{"Git Push"},
+		},
+		{
+			"no match",
+			makeHistoryItems("/", "ls", "git status"),
+			wshrpc.FetchSuggestionsData{Query: "zzz"},
+			nil,
+		},
+		{
+			"duplicates merged, frequency counts",
+			makeHistoryItems("/", "ll", "ls", "ls", "ls"),
+			wshrpc.FetchSuggestionsData{},
+			[]string{"ls", "ll"},
+		},
+		{
+			"ties go to the most recent",
+			makeHistoryItems("/", "pwd", "ls"),
+			wshrpc.FetchSuggestionsData{},
+			[]string{"pwd", "ls"},
+		},
+		{
+			"cwd boost",
+			append(makeHistoryItems("/b", "make test", "make test"), makeHistoryItems("/a", "make")...),
+			wshrpc.FetchSuggestionsData{Query: "make", HistoryCwd: "/a"},
+			[]string{"make", "make test"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := suggestionCmds(rankHistory(tc.items, tc.data))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRankHistoryDetails(t *testing.T) {
+	items := append(makeHistoryItems("/b", "make test"), makeHistoryItems("/a", "make test")...)
+	suggestions := rankHistory(items, wshrpc.FetchSuggestionsData{Query: "MAKE", HistoryCwd: "/a"})
+	if len(suggestions) != 1 {
+		t.Fatalf("got %d suggestions, want 1", len(suggestions))
+	}
+	if suggestions[0].SubText != "/a" {
+		t.Errorf("got subtext %q, want the current cwd", suggestions[0].SubText)
+	}
+	if !reflect.DeepEqual(suggestions[0].MatchPos, []int{0, 1, 2, 3}) {
+		t.Errorf("got matchpos %v, want the prefix", suggestions[0].MatchPos)
+	}
+
+	suggestions = rankHistory(makeHistoryItems("/b", "make test"), wshrpc.FetchSuggestionsData{HistoryCwd: "/a"})
+	if len(suggestions) != 1 || suggestions[0].SubText != "/b" {
+		t.Errorf("got %+v, want subtext of the last cwd", suggestions)
+	}
+
+	var cmds []string
+	for idx := 0; idx < MaxSuggestions+10; idx++ {
+		cmds = append(cmds, fmt.Sprintf("cmd%d", idx))
+	}
+	suggestions = rankHistory(makeHistoryItems("/", cmds...), wshrpc.FetchSuggestionsData{})
+	if len(suggestions) != MaxSuggestions {
+		t.Errorf("got %d suggestions, want %d", len(suggestions), MaxSuggestions)
+	}
+}
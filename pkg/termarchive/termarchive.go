@@ -0,0 +1,224 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// archive mode for the circular term blockfile.  before output is overwritten in the circular file it is
+// rolled into gzip compressed segment files in the same zone (named by their starting offset).
+// offsets are the logical offsets of the term file, so archived output and live output line up.
+package termarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/commandlinedev/starterm/pkg/filestore"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+const (
+	SegmentPrefix      = "archive:term:"
+	DefaultSegmentSize = 64 * 1024
+	MaxSegments        = 512 // 32MB of raw output per block
+)
+
+const (
+	FileMetaKey_Archive       = "archive"
+	FileMetaKey_ArchiveOffset = "archive:offset" // everything before this offset is in a segment
+	SegMetaKey_StartOffset    = "startoffset"
+	SegMetaKey_EndOffset      = "endoffset"
+)
+
+type Segment struct {
+	Name        string
+	StartOffset int64
+	EndOffset   int64
+}
+
+func segmentName(startOffset int64) string {
+	return fmt.Sprintf("%s%016d", SegmentPrefix, startOffset)
+}
+
+func SetArchiveMode(ctx context.Context, zoneId string, fileName string, enabled bool) error {
+	meta := wshrpc.FileMeta{FileMetaKey_Archive: nil}
+	if enabled {
+		meta[FileMetaKey_Archive] = true
+	}
+	return filestore.WFS.WriteMeta(ctx, zoneId, fileName, meta, true)
+}
+
+func isArchiveEnabled(meta wshrpc.FileMeta) bool {
+	enabled, _ := meta[FileMetaKey_Archive].(bool)
+	return enabled
+}
+
+func writeSegment(ctx context.Context, zoneId string, startOffset int64, data []byte) error {
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	_, err := gzWriter.Write(data)
+	if err != nil {
+		return fmt.Errorf("compressing archive segment: %w", err)
+	}
+	err = gzWriter.Close()
+	if err != nil {
+		return fmt.Errorf("compressing archive segment: %w", err)
+	}
+	name := segmentName(startOffset)
+	meta := wshrpc.FileMeta{
+		SegMetaKey_StartOffset: startOffset,
+		SegMetaKey_EndOffset:   startOffset + int64(len(data)),
+	}
+	err = filestore.WFS.MakeFile(ctx, zoneId, name, meta, wshrpc.FileOpts{})
+	if err != nil && err != fs.ErrExist {
+		return fmt.Errorf("creating archive segment %s: %w", name, err)
+	}
+	if err == fs.ErrExist {
+		err = filestore.WFS.WriteMeta(ctx, zoneId, name, meta, true)
+		if err != nil {
+			return fmt.Errorf("updating archive segment %s: %w", name, err)
+		}
+	}
+	err = filestore.WFS.WriteFile(ctx, zoneId, name, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("writing archive segment %s: %w", name, err)
+	}
+	return nil
+}
+
+// must be called (by the single writer of the file) right before data is appended to a circular file.
+// archives everything that the append would push out of the file.  no-op unless archive mode is on, but it stats
+// the file, so callers that know archive mode is off should skip it.
+func ArchiveBeforeAppend(ctx context.Context, zoneId string, fileName string, data []byte) error {
+	wfile, err := filestore.WFS.Stat(ctx, zoneId, fileName)
+	if err == fs.ErrNotExist {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !wfile.Opts.Circular || !isArchiveEnabled(wfile.Meta) {
+		return nil
+	}
+	newStartIdx := wfile.Size + int64(len(data)) - wfile.Opts.MaxSize
+	archiveOffset, _ := utilfn.ToInt64(wfile.Meta[FileMetaKey_ArchiveOffset])
+	if archiveOffset < wfile.DataStartIdx() {
+		// output from before archive mode was turned on is already gone
+		archiveOffset = wfile.DataStartIdx()
+	}
+	if archiveOffset >= newStartIdx {
+		return nil
+	}
+	// archive whole segments ahead of time (so we don't create a tiny segment for every append)
+	for archiveOffset < newStartIdx && archiveOffset < wfile.Size {
+		readSize := min(int64(DefaultSegmentSize), wfile.Size-archiveOffset)
+		realOffset, segData, err := filestore.WFS.ReadAt(ctx, zoneId, fileName, archiveOffset, readSize)
+		if err != nil {
+			return fmt.Errorf("reading %s for archive: %w", fileName, err)
+		}
+		if len(segData) == 0 {
+			break
+		}
+		err = writeSegment(ctx, zoneId, realOffset, segData)
+		if err != nil {
+			return err
+		}
+		archiveOffset = realOffset + int64(len(segData))
+	}
+	if archiveOffset < newStartIdx && archiveOffset >= wfile.Size {
+		// the append is bigger than the file, the front of it never makes it into the circular file
+		segData := data[archiveOffset-wfile.Size : newStartIdx-wfile.Size]
+		err = writeSegment(ctx, zoneId, archiveOffset, segData)
+		if err != nil {
+			return err
+		}
+		archiveOffset = newStartIdx
+	}
+	err = filestore.WFS.WriteMeta(ctx, zoneId, fileName, wshrpc.FileMeta{FileMetaKey_ArchiveOffset: archiveOffset}, true)
+	if err != nil {
+		return fmt.Errorf("updating archive offset: %w", err)
+	}
+	return pruneSegments(ctx, zoneId)
+}
+
+func pruneSegments(ctx context.Context, zoneId string) error {
+	segs, err := ListSegments(ctx, zoneId)
+	if err != nil {
+		return err
+	}
+	if len(segs) <= MaxSegments {
+		return nil
+	}
+	for _, seg := range segs[:len(segs)-MaxSegments] {
+		err = filestore.WFS.DeleteFile(ctx, zoneId, seg.Name)
+		if err != nil {
+			return fmt.Errorf("deleting archive segment %s: %w", seg.Name, err)
+		}
+	}
+	return nil
+}
+
+// sorted by offset
+func ListSegments(ctx context.Context, zoneId string) ([]Segment, error) {
+	files, err := filestore.WFS.ListFiles(ctx, zoneId)
+	if err != nil {
+		return nil, err
+	}
+	var rtn []Segment
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name, SegmentPrefix) {
+			continue
+		}
+		startOffset, ok1 := utilfn.ToInt64(file.Meta[SegMetaKey_StartOffset])
+		endOffset, ok2 := utilfn.ToInt64(file.Meta[SegMetaKey_EndOffset])
+		if !ok1 || !ok2 {
+			continue
+		}
+		rtn = append(rtn, Segment{Name: file.Name, StartOffset: startOffset, EndOffset: endOffset})
+	}
+	sort.Slice(rtn, func(i, j int) bool {
+		return rtn[i].StartOffset < rtn[j].StartOffset
+	})
+	return rtn, nil
+}
+
+// returns the uncompressed segment data
+func ReadSegment(ctx context.Context, zoneId string, seg Segment) ([]byte, error) {
+	_, gzData, err := filestore.WFS.ReadFile(ctx, zoneId, seg.Name)
+	if err != nil {
+		return nil, fmt.Errorf("reading archive segment %s: %w", seg.Name, err)
+	}
+	gzReader, err := gzip.NewReader(bytes.NewReader(gzData))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing archive segment %s: %w", seg.Name, err)
+	}
+	defer gzReader.Close()
+	data, err := io.ReadAll(gzReader)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing archive segment %s: %w", seg.Name, err)
+	}
+	return data, nil
+}
+
+// called when the file is truncated (the offsets start over, so the old segments can't be lined up anymore)
+func ResetArchive(ctx context.Context, zoneId string, fileName string) error {
+	segs, err := ListSegments(ctx, zoneId)
+	if err != nil {
+		return err
+	}
+	for _, seg := range segs {
+		err = filestore.WFS.DeleteFile(ctx, zoneId, seg.Name)
+		if err != nil {
+			return fmt.Errorf("deleting archive segment %s: %w", seg.Name, err)
+		}
+	}
+	err = filestore.WFS.WriteMeta(ctx, zoneId, fileName, wshrpc.FileMeta{FileMetaKey_ArchiveOffset: nil}, true)
+	if err == fs.ErrNotExist {
+		return nil
+	}
+	return err
+}
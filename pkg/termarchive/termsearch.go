@@ -0,0 +1,282 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package termarchive
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"github.com/commandlinedev/starterm/pkg/filestore"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+const (
+	MaxLineLen        = 4096 // longer lines (e.g. full screen apps that never send a newline) get split
+	DefaultMaxMatches = 200
+	lineContextBefore = 80
+	lineContextAfter  = 160
+)
+
+const (
+	ansiState_Ground = iota
+	ansiState_Esc
+	ansiState_EscInter // ESC + intermediate byte(s), e.g. ESC ( B
+	ansiState_Csi
+	ansiState_Str    // OSC, DCS, SOS, PM, APC (terminated by BEL or ST)
+	ansiState_StrEsc // ESC inside of a string (the start of ST)
+)
+
+// strips ANSI escape sequences and control characters from a stream of term output and splits it into lines.
+// it remembers the raw offset of every byte it keeps so matches can be mapped back to the term file.
+// sequences and lines can span calls to Feed.
+type lineScanner struct {
+	state       int
+	line        []byte
+	lineOffsets []int64
+	nextOffset  int64
+	onLine      func(line []byte, offsets []int64) bool
+	stopped     bool
+}
+
+func makeLineScanner(onLine func(line []byte, offsets []int64) bool) *lineScanner {
+	return &lineScanner{nextOffset: -1, onLine: onLine}
+}
+
+func (s *lineScanner) emitLine() {
+	if len(s.line) > 0 && !s.stopped {
+		if !s.onLine(s.line, s.lineOffsets) {
+			s.stopped = true
+		}
+	}
+	s.line = s.line[:0]
+	s.lineOffsets = s.lineOffsets[:0]
+}
+
+// returns false once onLine asked to stop
+func (s *lineScanner) Feed(baseOffset int64, data []byte) bool {
+	if s.nextOffset >= 0 && baseOffset != s.nextOffset {
+		// there is a gap in the output, don't join lines (or sequences) across it
+		s.Flush()
+	}
+	s.nextOffset = baseOffset + int64(len(data))
+	for idx, ch := range data {
+		if s.stopped {
+			return false
+		}
+		switch s.state {
+		case ansiState_Ground:
+			if ch == 0x1b {
+				s.state = ansiState_Esc
+				continue
+			}
+			if ch == '\n' {
+				s.emitLine()
+				continue
+			}
+			if (ch < 0x20 && ch != '\t') || ch == 0x7f {
+				continue
+			}
+			s.line = append(s.line, ch)
+			s.lineOffsets = append(s.lineOffsets, baseOffset+int64(idx))
+			if len(s.line) >= MaxLineLen {
+				s.emitLine()
+			}
+		case ansiState_Esc:
+			switch {
+			case ch == '[':
+				s.state = ansiState_Csi
+			case ch == ']' || ch == 'P' || ch == 'X' || ch == '^' || ch == '_':
+				s.state = ansiState_Str
+			case ch >= 0x20 && ch <= 0x2f:
+				s.state = ansiState_EscInter
+			default:
+				s.state = ansiState_Ground
+			}
+		case ansiState_EscInter:
+			if ch < 0x20 || ch > 0x2f {
+				s.state = ansiState_Ground
+			}
+		case ansiState_Csi:
+			if ch >= 0x40 && ch <= 0x7e {
+				s.state = ansiState_Ground
+			}
+		case ansiState_Str:
+			if ch == 0x07 {
+				s.state = ansiState_Ground
+			} else if ch == 0x1b {
+				s.state = ansiState_StrEsc
+			}
+		case ansiState_StrEsc:
+			if ch == '\\' {
+				s.state = ansiState_Ground
+			} else if ch != 0x1b {
+				s.state = ansiState_Str
+			}
+		}
+	}
+	return !s.stopped
+}
+
+func (s *lineScanner) Flush() {
+	s.emitLine()
+	s.state = ansiState_Ground
+	s.nextOffset = -1
+}
+
+// returns the plain text of term output (escape sequences, control characters and blank lines removed)
+func StripAnsi(data []byte) string {
+	var buf strings.Builder
+	scanner := makeLineScanner(func(line []byte, offsets []int64) bool {
+		buf.Write(line)
+		buf.WriteByte('\n')
+		return true
+	})
+	scanner.Feed(0, data)
+	scanner.Flush()
+	return buf.String()
+}
+
+func MakeSearchRegexp(query string, isRegex bool, ignoreCase bool) (*regexp.Regexp, error) {
+	if query == "" {
+		return nil, fmt.Errorf("empty search query")
+	}
+	pattern := query
+	if !isRegex {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search pattern: %w", err)
+	}
+	return re, nil
+}
+
+// trims long lines to a window around the match (returns the new match position)
+func makeMatchLine(line []byte, matchStart int, matchEnd int) (string, int, int) {
+	start := 0
+	end := len(line)
+	if matchStart > lineContextBefore {
+		start = matchStart - lineContextBefore
+	}
+	if end-matchEnd > lineContextAfter {
+		end = matchEnd + lineContextAfter
+	}
+	prefix := strings.ToValidUTF8(string(line[start:matchStart]), "")
+	match := strings.ToValidUTF8(string(line[matchStart:matchEnd]), "")
+	suffix := strings.ToValidUTF8(string(line[matchEnd:end]), "")
+	return prefix + match + suffix, len(prefix), len(prefix) + len(match)
+}
+
+// searches the archived and the live output of the term file.  returns the matches (oldest first), and
+// whether there were more than maxMatches.
+func SearchTermFile(ctx context.Context, zoneId string, fileName string, re *regexp.Regexp, maxMatches int) ([]wshrpc.TermSearchMatch, bool, error) {
+	if maxMatches <= 0 {
+		maxMatches = DefaultMaxMatches
+	}
+	wfile, err := filestore.WFS.Stat(ctx, zoneId, fileName)
+	if err == fs.ErrNotExist {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("reading %s: %w", fileName, err)
+	}
+	liveStartIdx := wfile.DataStartIdx()
+	var matches []wshrpc.TermSearchMatch
+	truncated := false
+	scanner := makeLineScanner(func(line []byte, offsets []int64) bool {
+		for _, loc := range re.FindAllIndex(line, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			if len(matches) >= maxMatches {
+				truncated = true
+				return false
+			}
+			lineStr, matchStart, matchEnd := makeMatchLine(line, loc[0], loc[1])
+			matches = append(matches, wshrpc.TermSearchMatch{
+				BlockId:    zoneId,
+				Offset:     offsets[loc[0]],
+				EndOffset:  offsets[loc[1]-1] + 1,
+				Line:       lineStr,
+				MatchStart: matchStart,
+				MatchEnd:   matchEnd,
+				Archived:   offsets[loc[0]] < liveStartIdx,
+			})
+		}
+		return true
+	})
+	segs, err := ListSegments(ctx, zoneId)
+	if err != nil {
+		return nil, false, err
+	}
+	var scannedUpTo int64
+	for _, seg := range segs {
+		if seg.StartOffset >= liveStartIdx {
+			// the rest is still in the live output
+			break
+		}
+		data, err := ReadSegment(ctx, zoneId, seg)
+		if err != nil {
+			return nil, false, err
+		}
+		if !scanner.Feed(seg.StartOffset, data) {
+			return matches, truncated, nil
+		}
+		scannedUpTo = seg.EndOffset
+	}
+	offset, data, err := filestore.WFS.ReadFile(ctx, zoneId, fileName)
+	if err != nil {
+		return nil, false, fmt.Errorf("reading %s: %w", fileName, err)
+	}
+	if scannedUpTo > offset {
+		// the newest segments overlap with the live output
+		skip := min(scannedUpTo-offset, int64(len(data)))
+		data = data[skip:]
+		offset += skip
+	}
+	scanner.Feed(offset, data)
+	scanner.Flush()
+	return matches, truncated, nil
+}
+
+// the per-block search of SearchTermBlocks, a var for tests
+var searchTermFileFn = SearchTermFile
+
+// searches the term files of the blocks in order.  returns at most maxMatches matches over all blocks, and
+// whether any block had more matches.
+func SearchTermBlocks(ctx context.Context, blockIds []string, fileName string, re *regexp.Regexp, maxMatches int) ([]wshrpc.TermSearchMatch, bool, error) {
+	if maxMatches <= 0 {
+		maxMatches = DefaultMaxMatches
+	}
+	var matches []wshrpc.TermSearchMatch
+	for _, blockId := range blockIds {
+		remaining := maxMatches - len(matches)
+		if remaining == 0 {
+			// the cap is reached, one more match in the remaining blocks means the results are truncated
+			more, _, err := searchTermFileFn(ctx, blockId, fileName, re, 1)
+			if err != nil {
+				return nil, false, fmt.Errorf("error searching block %s: %w", blockId, err)
+			}
+			if len(more) > 0 {
+				return matches, true, nil
+			}
+			continue
+		}
+		blockMatches, truncated, err := searchTermFileFn(ctx, blockId, fileName, re, remaining)
+		if err != nil {
+			return nil, false, fmt.Errorf("error searching block %s: %w", blockId, err)
+		}
+		matches = append(matches, blockMatches...)
+		if truncated {
+			return matches, true, nil
+		}
+	}
+	return matches, false, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package termarchive
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+type scannedLine struct {
+	text    string
+	offsets []int64
+}
+
+func scanChunks(base int64, chunks ...string) []scannedLine {
+	var rtn []scannedLine
+	scanner := makeLineScanner(func(line []byte, offsets []int64) bool {
+		rtn = append(rtn, scannedLine{text: string(line), offsets: append([]int64(nil), offsets...)})
+		return true
+	})
+	offset := base
+	for _, chunk := range chunks {
+		scanner.Feed(offset, []byte(chunk))
+		offset += int64(len(chunk))
+	}
+	scanner.Flush()
+	return rtn
+}
+
+func TestLineScanner(t *testing.T) {
+	lines := scanChunks(100, "\x1b[1;32mhello\x1b[0m world\r\n", "\x1b]7;file://host/tmp\x07$ ls\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %v", len(lines), lines)
+	}
+	if lines[0].text != "hello world" {
+		t.Errorf("bad line 0: %q", lines[0].text)
+	}
+	// "hello" starts after the 7 byte SGR sequence
+	if lines[0].offsets[0] != 107 {
+		t.Errorf("bad offset for line 0: %d", lines[0].offsets[0])
+	}
+	if lines[1].text != "$ ls" {
+		t.Errorf("bad line 1: %q", lines[1].text)
+	}
+
+	// escape sequences and lines split across chunks
+	lines = scanChunks(0, "abc\x1b[3", "1mdef\x1b]0;ti", "tle\x1b", "\\ghi\n")
+	if len(lines) != 1 || lines[0].text != "abcdefghi" {
+		t.Fatalf("bad split scan: %v", lines)
+	}
+	if lines[0].offsets[3] != 8 {
+		t.Errorf("bad offset for 'd': %d", lines[0].offsets[3])
+	}
+
+	// ESC ( B (charset selection) should not leave a stray "B"
+	lines = scanChunks(0, "\x1b(Bplain\n")
+	if len(lines) != 1 || lines[0].text != "plain" {
+		t.Fatalf("bad charset scan: %v", lines)
+	}
+}
+
+func TestLineScannerGap(t *testing.T) {
+	var lines []string
+	scanner := makeLineScanner(func(line []byte, offsets []int64) bool {
+		lines = append(lines, string(line))
+		return true
+	})
+	scanner.Feed(0, []byte("first \x1b]0;unterminated"))
+	// a gap (lost output) ends the line and resets the escape state
+	scanner.Feed(1000, []byte("second\n"))
+	scanner.Flush()
+	if len(lines) != 2 || lines[0] != "first " || lines[1] != "second" {
+		t.Fatalf("bad gap scan: %q", lines)
+	}
+}
+
+func TestStripAnsi(t *testing.T) {
+	text := StripAnsi([]byte("\x1b[?2004h\x1b[32m$\x1b[0m make\r\n\r\nok\x07\r\n"))
+	if text != "$ make\nok\n" {
+		t.Errorf("bad stripped text: %q", text)
+	}
+}
+
+func TestMakeSearchRegexp(t *testing.T) {
+	re, err := MakeSearchRegexp("a.b", false, true)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if re.MatchString("axb") || !re.MatchString("A.B") {
+		t.Errorf("literal search should escape regexp chars and ignore case")
+	}
+	_, err = MakeSearchRegexp("(", true, false)
+	if err == nil {
+		t.Errorf("expected an error for an invalid regexp")
+	}
+}
+
+func TestSearchTermBlocks(t *testing.T) {
+	numMatches := map[string]int{"b1": 2, "b2": 0, "b3": 3}
+	oldSearchFn := searchTermFileFn
+	defer func() { searchTermFileFn = oldSearchFn }()
+	searchTermFileFn = func(ctx context.Context, zoneId string, fileName string, re *regexp.Regexp, maxMatches int) ([]wshrpc.TermSearchMatch, bool, error) {
+		var matches []wshrpc.TermSearchMatch
+		for i := 0; i < numMatches[zoneId]; i++ {
+			if len(matches) >= maxMatches {
+				return matches, true, nil
+			}
+			matches = append(matches, wshrpc.TermSearchMatch{BlockId: zoneId, Offset: int64(i)})
+		}
+		return matches, false, nil
+	}
+	tests := []struct {
+		blockIds   []string
+		maxMatches int
+		want       string
+		truncated  bool
+	}{
+		{[]string{"b1", "b2", "b3"}, 10, "b1:0 b1:1 b3:0 b3:1 b3:2", false},
+		{[]string{"b1", "b2", "b3"}, 5, "b1:0 b1:1 b3:0 b3:1 b3:2", false},
+		{[]string{"b1", "b2", "b3"}, 4, "b1:0 b1:1 b3:0 b3:1", true},
+		// the first block fills the cap exactly, a later block has more
+		{[]string{"b1", "b2", "b3"}, 2, "b1:0 b1:1", true},
+		{[]string{"b1", "b2"}, 2, "b1:0 b1:1", false},
+		{[]string{"b3", "b1"}, 1, "b3:0", true},
+	}
+	for _, tc := range tests {
+		matches, truncated, err := SearchTermBlocks(context.Background(), tc.blockIds, "term", nil, tc.maxMatches)
+		if err != nil {
+			t.Fatalf("%v max %d: error: %v", tc.blockIds, tc.maxMatches, err)
+		}
+		var got []string
+		for _, match := range matches {
+			got = append(got, fmt.Sprintf("%s:%d", match.BlockId, match.Offset))
+		}
+		if fmt.Sprint(got) != "["+tc.want+"]" || truncated != tc.truncated {
+			t.Errorf("%v max %d: got %v (truncated %v), want [%s] (truncated %v)", tc.blockIds, tc.maxMatches, got, truncated, tc.want, tc.truncated)
+		}
+	}
+}
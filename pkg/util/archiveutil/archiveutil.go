@@ -0,0 +1,396 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package archiveutil reads tar (optionally gzip or bzip2 compressed) and zip archives as read-only virtual directories.
+// A path inside an archive is written as the archive path, the separator "//" and the member path, e.g. /logs/a.tar.gz//inner/file.txt
+package archiveutil
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+)
+
+const Separator = "//"
+
+const (
+	Format_Tar    = "tar"
+	Format_TarGz  = "tar.gz"
+	Format_TarBz2 = "tar.bz2"
+	Format_Zip    = "zip"
+)
+
+// checked in order, so the compressed tar suffixes come before .tar
+var formatSuffixes = []struct {
+	suffix string
+	format string
+}{
+	{".tar.gz", Format_TarGz},
+	{".tgz", Format_TarGz},
+	{".tar.bz2", Format_TarBz2},
+	{".tbz2", Format_TarBz2},
+	{".tbz", Format_TarBz2},
+	{".tar", Format_Tar},
+	{".zip", Format_Zip},
+}
+
+// number of archive indexes kept in memory, so browsing an archive does not re-read it for every directory
+const indexCacheSize = 8
+
+var ErrReadOnly = errors.New("archives are read-only")
+
+// returns the archive format of a file name, or "" if it is not a supported archive
+func GetFormat(name string) string {
+	lowerName := strings.ToLower(name[strings.LastIndexAny(name, `/\`)+1:])
+	for _, fsuffix := range formatSuffixes {
+		if strings.HasSuffix(lowerName, fsuffix.suffix) && len(lowerName) > len(fsuffix.suffix) {
+			return fsuffix.format
+		}
+	}
+	return ""
+}
+
+func IsArchive(name string) bool {
+	return GetFormat(name) != ""
+}
+
+// cleans a member path to a slash separated path without leading or trailing slashes, "" is the archive root.
+// ".." cannot go above the archive root.
+func CleanMember(member string) string {
+	return strings.Trim(path.Clean("/"+member), "/")
+}
+
+// splits a path at the first separator that follows an archive name, member is the cleaned member path.
+// ok is false if the path is not inside an archive.
+func SplitPath(fullPath string) (archivePath string, member string, ok bool) {
+	searchFrom := 0
+	for {
+		idx := strings.Index(fullPath[searchFrom:], Separator)
+		if idx < 0 {
+			return "", "", false
+		}
+		idx += searchFrom
+		if IsArchive(fullPath[:idx]) {
+			return fullPath[:idx], CleanMember(fullPath[idx+len(Separator):]), true
+		}
+		searchFrom = idx + 1
+	}
+}
+
+// the inverse of SplitPath, the archive root is the archive path followed by the separator
+func JoinPath(archivePath string, member string) string {
+	return archivePath + Separator + member
+}
+
+// a file or directory inside an archive, implements fs.FileInfo
+type Member struct {
+	Path    string // cleaned member path, "" for the archive root
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	implied bool // a directory that only exists because files are below it
+}
+
+func (m *Member) Name() string {
+	if m.Path == "" {
+		return ""
+	}
+	return path.Base(m.Path)
+}
+
+func (m *Member) Size() int64        { return m.size }
+func (m *Member) Mode() fs.FileMode  { return m.mode }
+func (m *Member) ModTime() time.Time { return m.modTime }
+func (m *Member) IsDir() bool        { return m.mode.IsDir() }
+func (m *Member) Sys() any           { return nil }
+
+type index struct {
+	modTime  time.Time
+	size     int64
+	lastUsed time.Time
+	members  map[string]*Member
+	children map[string][]*Member
+}
+
+var indexCacheLock = &sync.Mutex{}
+var indexCache = make(map[string]*index)
+
+func (idx *index) add(m *Member) {
+	if existing, ok := idx.members[m.Path]; ok {
+		// the first entry wins, except over a directory we made up
+		if !existing.implied {
+			return
+		}
+		existing.size, existing.mode, existing.modTime, existing.implied = m.size, m.mode, m.modTime, m.implied
+		return
+	}
+	idx.members[m.Path] = m
+	parent := path.Dir(m.Path)
+	if parent == "." {
+		parent = ""
+	}
+	idx.children[parent] = append(idx.children[parent], m)
+	if _, ok := idx.members[parent]; !ok {
+		idx.add(&Member{Path: parent, mode: fs.ModeDir | 0755, modTime: m.modTime, implied: true})
+	}
+}
+
+func makeIndex(finfo fs.FileInfo) *index {
+	idx := &index{
+		modTime:  finfo.ModTime(),
+		size:     finfo.Size(),
+		members:  make(map[string]*Member),
+		children: make(map[string][]*Member),
+	}
+	idx.members[""] = &Member{mode: fs.ModeDir | 0755, modTime: finfo.ModTime()}
+	return idx
+}
+
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (mc *multiCloser) Close() error {
+	var rtnErr error
+	for i := len(mc.closers) - 1; i >= 0; i-- {
+		if err := mc.closers[i].Close(); err != nil && rtnErr == nil {
+			rtnErr = err
+		}
+	}
+	return rtnErr
+}
+
+// opens a (decompressed) tar archive
+func openTar(archivePath string, format string) (*tar.Reader, io.Closer, error) {
+	fd, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	mc := &multiCloser{closers: []io.Closer{fd}}
+	var reader io.Reader = bufio.NewReader(fd)
+	switch format {
+	case Format_TarGz:
+		gzReader, err := gzip.NewReader(reader)
+		if err != nil {
+			utilfn.GracefulClose(fd, "archiveutil", archivePath)
+			return nil, nil, fmt.Errorf("cannot read gzip stream of %q: %w", archivePath, err)
+		}
+		mc.closers = append(mc.closers, gzReader)
+		reader = gzReader
+	case Format_TarBz2:
+		reader = bzip2.NewReader(reader)
+	}
+	return tar.NewReader(reader), mc, nil
+}
+
+func tarMember(hdr *tar.Header) *Member {
+	memberPath := CleanMember(hdr.Name)
+	if memberPath == "" {
+		return nil
+	}
+	finfo := hdr.FileInfo()
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return &Member{Path: memberPath, mode: finfo.Mode(), modTime: hdr.ModTime}
+	case tar.TypeReg, tar.TypeSymlink:
+		return &Member{Path: memberPath, size: hdr.Size, mode: finfo.Mode(), modTime: hdr.ModTime}
+	}
+	return nil
+}
+
+func readTarIndex(archivePath string, format string, idx *index) error {
+	tr, closer, err := openTar(archivePath, format)
+	if err != nil {
+		return err
+	}
+	defer utilfn.GracefulClose(closer, "archiveutil", archivePath)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read tar archive %q: %w", archivePath, err)
+		}
+		if m := tarMember(hdr); m != nil {
+			idx.add(m)
+		}
+	}
+}
+
+func readZipIndex(archivePath string, idx *index) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("cannot read zip archive %q: %w", archivePath, err)
+	}
+	defer utilfn.GracefulClose(zr, "archiveutil", archivePath)
+	for _, f := range zr.File {
+		memberPath := CleanMember(f.Name)
+		if memberPath == "" {
+			continue
+		}
+		mode := f.Mode()
+		if strings.HasSuffix(f.Name, "/") {
+			mode |= fs.ModeDir
+		}
+		idx.add(&Member{Path: memberPath, size: int64(f.UncompressedSize64), mode: mode, modTime: f.Modified})
+	}
+	return nil
+}
+
+// returns the index of the archive, it is only re-read if the archive changed since it was last read
+func getIndex(archivePath string) (*index, error) {
+	format := GetFormat(archivePath)
+	if format == "" {
+		return nil, fmt.Errorf("%q is not a supported archive", archivePath)
+	}
+	finfo, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if finfo.IsDir() {
+		return nil, fmt.Errorf("%q is a directory, not an archive", archivePath)
+	}
+	indexCacheLock.Lock()
+	idx := indexCache[archivePath]
+	if idx != nil && idx.modTime.Equal(finfo.ModTime()) && idx.size == finfo.Size() {
+		idx.lastUsed = time.Now()
+		indexCacheLock.Unlock()
+		return idx, nil
+	}
+	indexCacheLock.Unlock()
+	idx = makeIndex(finfo)
+	if format == Format_Zip {
+		err = readZipIndex(archivePath, idx)
+	} else {
+		err = readTarIndex(archivePath, format, idx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, children := range idx.children {
+		sort.Slice(children, func(i, j int) bool { return children[i].Path < children[j].Path })
+	}
+	idx.lastUsed = time.Now()
+	indexCacheLock.Lock()
+	defer indexCacheLock.Unlock()
+	indexCache[archivePath] = idx
+	for len(indexCache) > indexCacheSize {
+		var oldestPath string
+		for cachedPath, cached := range indexCache {
+			if oldestPath == "" || cached.lastUsed.Before(indexCache[oldestPath].lastUsed) {
+				oldestPath = cachedPath
+			}
+		}
+		delete(indexCache, oldestPath)
+	}
+	return idx, nil
+}
+
+// returns the member, or an error wrapping fs.ErrNotExist if the archive has no such member
+func Stat(archivePath string, member string) (*Member, error) {
+	idx, err := getIndex(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := idx.members[CleanMember(member)]
+	if !ok {
+		return nil, fmt.Errorf("%q not found in %q: %w", member, archivePath, fs.ErrNotExist)
+	}
+	return m, nil
+}
+
+// lists the members directly below the directory dir, or with recursive set all the files (not directories) below it
+func List(archivePath string, dir string, recursive bool) ([]*Member, error) {
+	idx, err := getIndex(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	dir = CleanMember(dir)
+	m, ok := idx.members[dir]
+	if !ok {
+		return nil, fmt.Errorf("%q not found in %q: %w", dir, archivePath, fs.ErrNotExist)
+	}
+	if !m.IsDir() {
+		return nil, fmt.Errorf("%q in %q is not a directory", dir, archivePath)
+	}
+	if !recursive {
+		return idx.children[dir], nil
+	}
+	var rtn []*Member
+	var walk func(dir string)
+	walk = func(dir string) {
+		for _, child := range idx.children[dir] {
+			if child.IsDir() {
+				walk(child.Path)
+			} else {
+				rtn = append(rtn, child)
+			}
+		}
+	}
+	walk(dir)
+	return rtn, nil
+}
+
+// opens a regular file in the archive, only the archive is read (and decompressed) up to the member
+func Open(archivePath string, member string) (io.ReadCloser, *Member, error) {
+	m, err := Stat(archivePath, member)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !m.Mode().IsRegular() {
+		return nil, nil, fmt.Errorf("%q in %q is not a regular file", m.Path, archivePath)
+	}
+	format := GetFormat(archivePath)
+	if format == Format_Zip {
+		zr, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot read zip archive %q: %w", archivePath, err)
+		}
+		for _, f := range zr.File {
+			if CleanMember(f.Name) != m.Path {
+				continue
+			}
+			fr, err := f.Open()
+			if err != nil {
+				utilfn.GracefulClose(zr, "archiveutil", archivePath)
+				return nil, nil, fmt.Errorf("cannot open %q in %q: %w", m.Path, archivePath, err)
+			}
+			return &multiCloser{Reader: fr, closers: []io.Closer{zr, fr}}, m, nil
+		}
+		utilfn.GracefulClose(zr, "archiveutil", archivePath)
+		return nil, nil, fmt.Errorf("%q not found in %q: %w", m.Path, archivePath, fs.ErrNotExist)
+	}
+	tr, closer, err := openTar(archivePath, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			utilfn.GracefulClose(closer, "archiveutil", archivePath)
+			if errors.Is(err, io.EOF) {
+				return nil, nil, fmt.Errorf("%q not found in %q: %w", m.Path, archivePath, fs.ErrNotExist)
+			}
+			return nil, nil, fmt.Errorf("cannot read tar archive %q: %w", archivePath, err)
+		}
+		if CleanMember(hdr.Name) == m.Path && hdr.Typeflag == tar.TypeReg {
+			return &multiCloser{Reader: tr, closers: []io.Closer{closer}}, m, nil
+		}
+	}
+}
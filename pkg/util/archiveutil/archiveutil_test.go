@@ -0,0 +1,143 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package archiveutil_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/commandlinedev/starterm/pkg/util/archiveutil"
+)
+
+var testFiles = []struct {
+	name string
+	data string
+}{
+	{"README.md", "hello"},
+	{"src/", ""},
+	{"src/main.go", "package main\n"},
+	{"logs/2025/app.log", "line 1\nline 2\n"},
+}
+
+func writeTar(t *testing.T, path string, compress bool) {
+	fd, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	defer fd.Close()
+	var w io.Writer = fd
+	if compress {
+		gzWriter := gzip.NewWriter(fd)
+		defer gzWriter.Close()
+		w = gzWriter
+	}
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+	for _, f := range testFiles {
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}
+		if f.name[len(f.name)-1] == '/' {
+			hdr.Mode, hdr.Typeflag = 0755, tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("write header failed: %v", err)
+		}
+		if _, err := tw.Write([]byte(f.data)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+}
+
+func writeZip(t *testing.T, path string) {
+	fd, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	defer fd.Close()
+	zw := zip.NewWriter(fd)
+	defer zw.Close()
+	for _, f := range testFiles {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatalf("zip create failed: %v", err)
+		}
+		if _, err := w.Write([]byte(f.data)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		archive string
+		member  string
+		ok      bool
+	}{
+		{"/data/logs.tar.gz//inner/file.txt", "/data/logs.tar.gz", "inner/file.txt", true},
+		{"/data/logs.TGZ//", "/data/logs.TGZ", "", true},
+		{"/data//a.zip//x/../../y", "/data//a.zip", "y", true},
+		{"/data/logs.tar.gz", "", "", false},
+		{"/data//file.txt", "", "", false},
+		{"/data/.zip//a", "", "", false},
+	}
+	for _, test := range tests {
+		archive, member, ok := archiveutil.SplitPath(test.path)
+		if archive != test.archive || member != test.member || ok != test.ok {
+			t.Errorf("SplitPath(%q) = %q, %q, %v; expected %q, %q, %v", test.path, archive, member, ok, test.archive, test.member, test.ok)
+		}
+	}
+}
+
+func TestArchives(t *testing.T) {
+	dir := t.TempDir()
+	tarPath := filepath.Join(dir, "test.tar")
+	tgzPath := filepath.Join(dir, "test.tar.gz")
+	zipPath := filepath.Join(dir, "test.zip")
+	writeTar(t, tarPath, false)
+	writeTar(t, tgzPath, true)
+	writeZip(t, zipPath)
+	for _, archivePath := range []string{tarPath, tgzPath, zipPath} {
+		root, err := archiveutil.List(archivePath, "", false)
+		if err != nil {
+			t.Fatalf("%s: List failed: %v", archivePath, err)
+		}
+		var names []string
+		for _, m := range root {
+			names = append(names, m.Name())
+		}
+		if len(names) != 3 || names[0] != "README.md" || names[1] != "logs" || names[2] != "src" {
+			t.Errorf("%s: unexpected root entries %v", archivePath, names)
+		}
+		all, err := archiveutil.List(archivePath, "/logs/", true)
+		if err != nil || len(all) != 1 || all[0].Path != "logs/2025/app.log" {
+			t.Errorf("%s: unexpected recursive list %v, %v", archivePath, all, err)
+		}
+		m, err := archiveutil.Stat(archivePath, "logs/2025")
+		if err != nil || !m.IsDir() {
+			t.Errorf("%s: expected an implied directory, got %v, %v", archivePath, m, err)
+		}
+		if _, err := archiveutil.Stat(archivePath, "missing"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: expected not exist, got %v", archivePath, err)
+		}
+		rc, m, err := archiveutil.Open(archivePath, "logs/2025/app.log")
+		if err != nil {
+			t.Fatalf("%s: Open failed: %v", archivePath, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || string(data) != "line 1\nline 2\n" || m.Size() != int64(len(data)) {
+			t.Errorf("%s: unexpected content %q, %v", archivePath, data, err)
+		}
+		if _, _, err := archiveutil.Open(archivePath, "src"); err == nil {
+			t.Errorf("%s: expected opening a directory to fail", archivePath)
+		}
+	}
+}
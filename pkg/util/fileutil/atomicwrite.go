@@ -0,0 +1,221 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package fileutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+)
+
+const (
+	// files up to this size are hashed completely for their etag, larger files only have their first and last ETagSampleSize bytes hashed
+	ETagMaxHashSize = 64 * 1024 * 1024
+	ETagSampleSize  = 1024 * 1024
+
+	etagCacheSize = 256
+
+	// stage files left behind by writers that never committed are removed after this long
+	StageFileMaxAge = 24 * time.Hour
+
+	tempFilePrefix = ".starterm-"
+)
+
+var stageIdRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type etagCacheEntry struct {
+	modTime time.Time
+	size    int64
+	ino     uint64
+	hash    string
+}
+
+var etagCacheLock = &sync.Mutex{}
+var etagCache = make(map[string]etagCacheEntry)
+
+func hashFile(path string, size int64) (string, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer utilfn.GracefulClose(fd, "hashFile", path)
+	hasher := sha256.New()
+	if size <= ETagMaxHashSize {
+		if _, err := io.Copy(hasher, fd); err != nil {
+			return "", err
+		}
+	} else {
+		if _, err := io.CopyN(hasher, fd, ETagSampleSize); err != nil {
+			return "", err
+		}
+		if _, err := fd.Seek(size-ETagSampleSize, io.SeekStart); err != nil {
+			return "", err
+		}
+		if _, err := io.CopyN(hasher, fd, ETagSampleSize); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(hasher.Sum(nil))[:16], nil
+}
+
+// returns the etag of a regular file, made from its mtime, size and a hash of its content.
+// with useCache set the hash is reused while the mtime, size and inode do not change (so file infos stay cheap).
+// an in-place rewrite within one mtime tick keeps all three, so writers checking for conflicts should not use the cache.
+func ETag(path string, finfo fs.FileInfo, useCache bool) (string, error) {
+	if finfo == nil {
+		var err error
+		if finfo, err = os.Stat(path); err != nil {
+			return "", err
+		}
+	}
+	if !finfo.Mode().IsRegular() {
+		return "", fmt.Errorf("%q is not a regular file", path)
+	}
+	ino := fileIno(finfo)
+	var hash string
+	if useCache {
+		etagCacheLock.Lock()
+		entry, ok := etagCache[path]
+		etagCacheLock.Unlock()
+		if ok && entry.modTime.Equal(finfo.ModTime()) && entry.size == finfo.Size() && entry.ino == ino {
+			hash = entry.hash
+		}
+	}
+	if hash == "" {
+		var err error
+		if hash, err = hashFile(path, finfo.Size()); err != nil {
+			return "", fmt.Errorf("cannot hash %q: %w", path, err)
+		}
+		etagCacheLock.Lock()
+		if len(etagCache) >= etagCacheSize {
+			clear(etagCache)
+		}
+		etagCache[path] = etagCacheEntry{modTime: finfo.ModTime(), size: finfo.Size(), ino: ino, hash: hash}
+		etagCacheLock.Unlock()
+	}
+	return fmt.Sprintf("%x-%x-%s", finfo.ModTime().UnixNano(), finfo.Size(), hash), nil
+}
+
+// returns the path a write to path should replace, symlinks are followed so replacing a file keeps the link
+func resolveWritePath(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path
+	}
+	return resolved
+}
+
+// overwrites path with the content of r in place, used when a file cannot be replaced
+func overwriteFile(path string, r io.Reader, createMode os.FileMode) error {
+	fd, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, createMode)
+	if err != nil {
+		return fmt.Errorf("cannot open file %q: %w", path, err)
+	}
+	_, err = io.Copy(fd, r)
+	if closeErr := fd.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write to file %q: %w", path, err)
+	}
+	return nil
+}
+
+// moves tempPath over path, the new file gets the mode and owner of the file it replaces, or createMode for new files.
+// when the file cannot be replaced without losing its owner or its other hard links, its content is overwritten in place instead (which is not atomic).
+// tempPath is removed either way.
+func ReplaceFile(tempPath string, path string, createMode os.FileMode) error {
+	defer os.Remove(tempPath)
+	path = resolveWritePath(path)
+	finfo, err := os.Stat(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("cannot stat %q: %w", path, err)
+	}
+	mode := createMode
+	if finfo != nil {
+		mode = finfo.Mode().Perm()
+		if err := prepareReplace(tempPath, finfo); err != nil {
+			log.Printf("cannot replace %q, overwriting it in place: %v\n", path, err)
+			fd, err := os.Open(tempPath)
+			if err != nil {
+				return fmt.Errorf("cannot open %q: %w", tempPath, err)
+			}
+			defer utilfn.GracefulClose(fd, "ReplaceFile", tempPath)
+			return overwriteFile(path, fd, createMode)
+		}
+	}
+	if err := os.Chmod(tempPath, mode); err != nil {
+		return fmt.Errorf("cannot set mode of %q: %w", tempPath, err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("cannot replace %q: %w", path, err)
+	}
+	return nil
+}
+
+// writes data to a temp file next to path and moves it over path, so readers see either the old or the new content.
+// if the directory cannot hold the temp file (e.g. it is not writable), the file is overwritten in place.
+func WriteFileAtomic(path string, data []byte, createMode os.FileMode) error {
+	resolvedPath := resolveWritePath(path)
+	fd, err := os.CreateTemp(filepath.Dir(resolvedPath), tempFilePrefix+filepath.Base(resolvedPath)+"-*")
+	if err != nil {
+		log.Printf("cannot create temp file for %q, overwriting it in place: %v\n", path, err)
+		return overwriteFile(resolvedPath, bytes.NewReader(data), createMode)
+	}
+	tempPath := fd.Name()
+	_, err = fd.Write(data)
+	if err == nil {
+		err = fd.Sync()
+	}
+	if closeErr := fd.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("cannot write %q: %w", tempPath, err)
+	}
+	return ReplaceFile(tempPath, resolvedPath, createMode)
+}
+
+// returns the stage file that collects the chunks of a staged write to path, stage files live next to the file they replace
+func StageFilePath(path string, stageId string) (string, error) {
+	if !stageIdRe.MatchString(stageId) {
+		return "", fmt.Errorf("invalid stage id %q", stageId)
+	}
+	resolvedPath := resolveWritePath(path)
+	return filepath.Join(filepath.Dir(resolvedPath), tempFilePrefix+filepath.Base(resolvedPath)+"-stage-"+stageId), nil
+}
+
+// removes the stage files of path that are older than StageFileMaxAge
+func RemoveStaleStageFiles(path string) {
+	resolvedPath := resolveWritePath(path)
+	prefix := tempFilePrefix + filepath.Base(resolvedPath) + "-stage-"
+	entries, err := os.ReadDir(filepath.Dir(resolvedPath))
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		finfo, err := entry.Info()
+		if err != nil || time.Since(finfo.ModTime()) < StageFileMaxAge {
+			continue
+		}
+		os.Remove(filepath.Join(filepath.Dir(resolvedPath), entry.Name()))
+	}
+}
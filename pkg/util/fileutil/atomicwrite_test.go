@@ -0,0 +1,127 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.txt")
+	if err := os.WriteFile(path, []byte("old"), 0640); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	oldETag, err := ETag(path, nil, false)
+	if err != nil {
+		t.Fatalf("ETag failed: %v", err)
+	}
+	linkPath := filepath.Join(dir, "link.txt")
+	if err := os.Symlink(path, linkPath); err != nil {
+		t.Fatalf("symlink failed: %v", err)
+	}
+	if err := WriteFileAtomic(linkPath, []byte("new content"), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic failed: %v", err)
+	}
+	if lfinfo, err := os.Lstat(linkPath); err != nil || lfinfo.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected the symlink to be kept, got %v, %v", lfinfo, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new content" {
+		t.Errorf("unexpected content %q, %v", data, err)
+	}
+	finfo, err := os.Stat(path)
+	if err != nil || finfo.Mode().Perm() != 0640 {
+		t.Errorf("expected mode 0640 to be kept, got %v, %v", finfo, err)
+	}
+	newETag, err := ETag(path, nil, false)
+	if err != nil || newETag == oldETag {
+		t.Errorf("expected the etag to change, got %q (was %q), %v", newETag, oldETag, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), tempFilePrefix) {
+			t.Errorf("temp file %q was left behind", entry.Name())
+		}
+	}
+}
+
+func TestStageFilePath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big.log")
+	stagePath, err := StageFilePath(path, "a1b2c3")
+	if err != nil || filepath.Dir(stagePath) != dir {
+		t.Fatalf("unexpected stage path %q, %v", stagePath, err)
+	}
+	if _, err := StageFilePath(path, "../evil"); err == nil {
+		t.Errorf("expected an invalid stage id to fail")
+	}
+	if err := os.WriteFile(stagePath, []byte("staged"), 0600); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := ReplaceFile(stagePath, path, 0644); err != nil {
+		t.Fatalf("ReplaceFile failed: %v", err)
+	}
+	finfo, err := os.Stat(path)
+	if err != nil || finfo.Mode().Perm() != 0644 {
+		t.Errorf("expected a new file with mode 0644, got %v, %v", finfo, err)
+	}
+	if _, err := os.Stat(stagePath); !os.IsNotExist(err) {
+		t.Errorf("expected the stage file to be gone, got %v", err)
+	}
+}
+
+func TestETag(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+	etag, err := ETag(path, nil, true)
+	if err != nil {
+		t.Fatalf("ETag failed: %v", err)
+	}
+	if again, _ := ETag(path, nil, true); again != etag {
+		t.Errorf("etag of an unchanged file changed from %q to %q", etag, again)
+	}
+	// rewritten in place with the same size and (forced) the same mtime, only the content hash can tell
+	if err := os.WriteFile(path, []byte("xyz"), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+	changed, err := ETag(path, nil, false)
+	if err != nil || changed == etag {
+		t.Errorf("etag did not change after the file was rewritten: %q (err %v)", changed, err)
+	}
+	// the uncached etag refreshes the cache
+	if cached, _ := ETag(path, nil, true); cached != changed {
+		t.Errorf("cached etag %q, want %q", cached, changed)
+	}
+	// a replaced file (new inode) is hashed again even when the cache is used
+	if err := os.WriteFile(path+".new", []byte("123"), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := os.Chtimes(path+".new", mtime, mtime); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		t.Fatalf("rename failed: %v", err)
+	}
+	if replaced, _ := ETag(path, nil, true); replaced == changed {
+		t.Errorf("cached etag did not change after the file was replaced")
+	}
+	if _, err := ETag(dir, nil, false); err == nil {
+		t.Errorf("expected an error for a directory")
+	}
+}
@@ -15,10 +15,18 @@ import (
 	"time"
 
 	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/util/archiveutil"
 	"github.com/commandlinedev/starterm/pkg/wshrpc"
 )
 
 func FixPath(path string) (string, error) {
+	if archivePath, member, ok := archiveutil.SplitPath(path); ok {
+		fixedPath, err := FixPath(archivePath)
+		if err != nil {
+			return "", err
+		}
+		return archiveutil.JoinPath(fixedPath, member), nil
+	}
 	origPath := path
 	var err error
 	if strings.HasPrefix(path, "~") {
@@ -0,0 +1,36 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package fileutil
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// gives tempPath the owner and group of the file it will replace.
+// fails if the file has other hard links (they would keep the old content) or if its owner cannot be kept (only root can change owners).
+func prepareReplace(tempPath string, finfo fs.FileInfo) error {
+	stat, ok := finfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if stat.Nlink > 1 {
+		return fmt.Errorf("file has %d hard links", stat.Nlink)
+	}
+	if stat.Uid == uint32(os.Getuid()) && stat.Gid == uint32(os.Getgid()) {
+		return nil
+	}
+	return os.Chown(tempPath, int(stat.Uid), int(stat.Gid))
+}
+
+func fileIno(finfo fs.FileInfo) uint64 {
+	if stat, ok := finfo.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,20 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+
+package fileutil
+
+import (
+	"io/fs"
+)
+
+// windows files get their owner from the process that creates them, which cannot be changed here
+func prepareReplace(tempPath string, finfo fs.FileInfo) error {
+	return nil
+}
+
+// the file index is not part of the stat info on windows, the etag relies on mtime and size
+func fileIno(finfo fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,359 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package packetparser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+)
+
+// binary framing sends the large base64 values of a json message (file data, pty output) as raw bytes.
+// only the wire format changes, messages are still json inside the process (the reader puts the base64 values back).
+//
+// a frame is a header line followed by the json message (with the binary values emptied) and the raw values:
+//
+//	##B<json length> <offset>:<length> <offset>:<length>...\n<json><value bytes>...
+//
+// the offsets point into the emptied json, at the position between the quotes of each value.
+// frames are only sent after both sides exchanged FramingHello, older peers never answer it (they ignore messages without a command).
+
+var FramingHello = []byte(`{"wshframing":"binary"}`)
+
+const (
+	// base64 values shorter than this are left in the json
+	MinBinaryValueSize = 1024
+	// frames bigger than this are rejected by the reader
+	MaxFrameSize = 64 * 1024 * 1024
+
+	// domain sockets drop json lines longer than this (like utilfn.StreamToLines)
+	MaxJsonLineLength = 128 * 1024
+)
+
+var binaryFramePrefix = []byte("##B")
+
+// json keys of the values that hold base64 encoded bytes (FileData, CommandControllerAppendOutputData, CommandBlockInputData and iochantypes.Packet)
+var binaryKeys = [][]byte{[]byte(`"data64":"`), []byte(`"inputdata64":"`), []byte(`"Data":"`)}
+
+type binaryValue struct {
+	offset int
+	data   []byte
+}
+
+// finds the base64 values of msg worth sending as raw bytes, returns the json with those values emptied.
+// keys are only matched after '{' or ',', an unescaped quote there cannot be inside a string.
+// values must be canonical base64, so that encoding the decoded bytes gives back exactly the same json.
+func extractBinaryValues(msg []byte) ([]byte, []binaryValue) {
+	var stripped []byte
+	var values []binaryValue
+	last := 0
+	for pos := 1; pos < len(msg); {
+		idx := bytes.IndexByte(msg[pos:], '"')
+		if idx < 0 {
+			break
+		}
+		pos += idx
+		if msg[pos-1] != '{' && msg[pos-1] != ',' {
+			pos++
+			continue
+		}
+		var valueStart int
+		for _, key := range binaryKeys {
+			if bytes.HasPrefix(msg[pos:], key) {
+				valueStart = pos + len(key)
+				break
+			}
+		}
+		if valueStart == 0 {
+			pos++
+			continue
+		}
+		valueLen := bytes.IndexByte(msg[valueStart:], '"')
+		if valueLen < 0 {
+			break
+		}
+		value := msg[valueStart : valueStart+valueLen]
+		pos = valueStart + valueLen + 1
+		if len(value) < MinBinaryValueSize {
+			continue
+		}
+		// json escapes (backslashes) are not base64, so they fail to decode
+		data := make([]byte, base64.StdEncoding.DecodedLen(len(value)))
+		n, err := base64.StdEncoding.Strict().Decode(data, value)
+		if err != nil {
+			continue
+		}
+		data = data[:n]
+		if stripped == nil {
+			stripped = make([]byte, 0, len(msg)/4)
+		}
+		stripped = append(stripped, msg[last:valueStart]...)
+		values = append(values, binaryValue{offset: len(stripped), data: data})
+		last = valueStart + valueLen
+	}
+	if len(values) == 0 {
+		return msg, nil
+	}
+	stripped = append(stripped, msg[last:]...)
+	return stripped, values
+}
+
+// encodes msg as a binary frame, ok is false if msg has no values worth sending as raw bytes
+func EncodeBinaryFrame(msg []byte) (frame []byte, ok bool) {
+	stripped, values := extractBinaryValues(msg)
+	if len(values) == 0 {
+		return nil, false
+	}
+	size := len(stripped) + 32
+	for _, value := range values {
+		size += len(value.data) + 24
+	}
+	frame = make([]byte, 0, size)
+	frame = append(frame, binaryFramePrefix...)
+	frame = strconv.AppendInt(frame, int64(len(stripped)), 10)
+	for _, value := range values {
+		frame = append(frame, ' ')
+		frame = strconv.AppendInt(frame, int64(value.offset), 10)
+		frame = append(frame, ':')
+		frame = strconv.AppendInt(frame, int64(len(value.data)), 10)
+	}
+	frame = append(frame, '\n')
+	frame = append(frame, stripped...)
+	for _, value := range values {
+		frame = append(frame, value.data...)
+	}
+	return frame, true
+}
+
+func parseFrameHeader(header []byte) (int, []binaryValue, bool) {
+	fields := bytes.Fields(header[len(binaryFramePrefix):])
+	if len(fields) == 0 {
+		return 0, nil, false
+	}
+	jsonLen, err := strconv.Atoi(string(fields[0]))
+	if err != nil || jsonLen < 2 {
+		return 0, nil, false
+	}
+	total := jsonLen
+	lastOffset := 0
+	values := make([]binaryValue, 0, len(fields)-1)
+	for _, field := range fields[1:] {
+		offsetStr, lenStr, found := bytes.Cut(field, []byte{':'})
+		if !found {
+			return 0, nil, false
+		}
+		offset, err1 := strconv.Atoi(string(offsetStr))
+		valueLen, err2 := strconv.Atoi(string(lenStr))
+		if err1 != nil || err2 != nil || offset < lastOffset || offset > jsonLen || valueLen < 0 {
+			return 0, nil, false
+		}
+		total += valueLen
+		if total > MaxFrameSize {
+			return 0, nil, false
+		}
+		lastOffset = offset
+		values = append(values, binaryValue{offset: offset, data: make([]byte, valueLen)})
+	}
+	return jsonLen, values, true
+}
+
+// reads the rest of a frame whose header line was already read, returns the original json message
+func readBinaryFrame(header []byte, r io.Reader) ([]byte, error) {
+	jsonLen, values, ok := parseFrameHeader(bytes.TrimRight(header, "\r\n"))
+	if !ok {
+		return nil, fmt.Errorf("invalid binary frame header %q", header)
+	}
+	stripped := make([]byte, jsonLen)
+	if _, err := io.ReadFull(r, stripped); err != nil {
+		return nil, fmt.Errorf("error reading binary frame: %w", err)
+	}
+	size := jsonLen
+	for _, value := range values {
+		if _, err := io.ReadFull(r, value.data); err != nil {
+			return nil, fmt.Errorf("error reading binary frame: %w", err)
+		}
+		size += base64.StdEncoding.EncodedLen(len(value.data))
+	}
+	msg := make([]byte, 0, size)
+	last := 0
+	for _, value := range values {
+		msg = append(msg, stripped[last:value.offset]...)
+		msg = base64.StdEncoding.AppendEncode(msg, value.data)
+		last = value.offset
+	}
+	msg = append(msg, stripped[last:]...)
+	return msg, nil
+}
+
+func isFrameHeader(line []byte) bool {
+	return bytes.HasPrefix(line, binaryFramePrefix) && len(line) > len(binaryFramePrefix) && line[len(binaryFramePrefix)] >= '0' && line[len(binaryFramePrefix)] <= '9'
+}
+
+// reads a line (with its trailing newline), lines longer than maxLen (if set) are skipped
+func readLine(r *bufio.Reader, maxLen int) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong {
+			if maxLen > 0 && len(line)+len(chunk) > maxLen+1 {
+				tooLong = true
+				line = nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return line, err
+		}
+		if tooLong {
+			tooLong = false
+			continue
+		}
+		return line, nil
+	}
+}
+
+// reads the next line or binary frame, frames are returned as their decoded json message (without a trailing newline)
+func readLineOrFrame(r *bufio.Reader, maxLen int) (line []byte, isFrame bool, err error) {
+	line, err = readLine(r, maxLen)
+	if err != nil || !isFrameHeader(line) {
+		return line, false, err
+	}
+	msg, err := readBinaryFrame(line, r)
+	if err != nil {
+		return nil, false, err
+	}
+	return msg, true, nil
+}
+
+// negotiates and writes binary frames on one side of a connection.
+// the initiator (the side that connects) sends FramingHello, the other side answers it.
+// each side starts sending frames once it has seen the other side's hello.
+type Framing struct {
+	lock       *sync.Mutex
+	output     io.Writer
+	packetMode bool // "##N" packets on a stream that can also carry other output (conn server stdio), otherwise json lines (domain sockets)
+	initiator  bool
+	binary     bool
+}
+
+func MakeFraming(output io.Writer, packetMode bool, initiator bool) *Framing {
+	return &Framing{lock: &sync.Mutex{}, output: output, packetMode: packetMode, initiator: initiator}
+}
+
+// sends the hello (initiator only)
+func (f *Framing) Start() error {
+	if f == nil || !f.initiator {
+		return nil
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.writeJson(FramingHello)
+}
+
+func (f *Framing) IsBinary() bool {
+	if f == nil {
+		return false
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.binary
+}
+
+// returns true if msg was the other side's hello (and consumed)
+func (f *Framing) handleHello(msg []byte) bool {
+	if f == nil || !bytes.Equal(bytes.TrimSpace(msg), FramingHello) {
+		return false
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if !f.initiator && !f.binary {
+		if err := f.writeJson(FramingHello); err != nil {
+			return true
+		}
+	}
+	f.binary = true
+	return true
+}
+
+func (f *Framing) writeJson(msg []byte) error {
+	if f.packetMode {
+		return WritePacket(f.output, msg)
+	}
+	line := make([]byte, 0, len(msg)+1)
+	line = append(line, msg...)
+	line = append(line, '\n')
+	_, err := f.output.Write(line)
+	return err
+}
+
+// writes msg as a binary frame once both sides support it, otherwise as json
+func (f *Framing) WriteMsg(msg []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.binary {
+		if frame, ok := EncodeBinaryFrame(msg); ok {
+			if f.packetMode {
+				// like WritePacket, the newline makes sure the frame starts at the beginning of a line
+				frame = append([]byte{'\n'}, frame...)
+			}
+			_, err := f.output.Write(frame)
+			return err
+		}
+	}
+	return f.writeJson(msg)
+}
+
+// reads json lines and binary frames (the domain socket format) and sends the messages to msgCh.
+// framing (if set) consumes the other side's hello.
+func ParseJsonLines(input io.Reader, msgCh chan []byte, framing *Framing) error {
+	bufReader := bufio.NewReader(input)
+	for {
+		line, isFrame, err := readLineOrFrame(bufReader, MaxJsonLineLength)
+		if err != nil {
+			// a partial line at the end of the stream is dropped
+			return err
+		}
+		if !isFrame {
+			line = bytes.TrimSuffix(line, []byte{'\n'})
+		}
+		if len(line) > 0 && !framing.handleHello(line) {
+			msgCh <- line
+		}
+	}
+}
+
+// like utilfn.StreamToLinesChan, but binary frames are decoded and returned as "##N" packet lines (for ParseWithLinesChan)
+func StreamToLinesChan(input io.Reader) chan utilfn.LineOutput {
+	ch := make(chan utilfn.LineOutput)
+	go func() {
+		defer close(ch)
+		bufReader := bufio.NewReader(input)
+		for {
+			line, isFrame, err := readLineOrFrame(bufReader, MaxJsonLineLength)
+			if err != nil {
+				if err != io.EOF {
+					ch <- utilfn.LineOutput{Error: err}
+				}
+				return
+			}
+			if isFrame {
+				ch <- utilfn.LineOutput{Line: "##N" + string(line)}
+				continue
+			}
+			ch <- utilfn.LineOutput{Line: string(bytes.TrimSuffix(line, []byte{'\n'}))}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,181 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package packetparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testFileData struct {
+	Path   string `json:"path"`
+	Data64 string `json:"data64,omitempty"`
+}
+
+type testRpcMessage struct {
+	Command string       `json:"command,omitempty"`
+	ReqId   string       `json:"reqid,omitempty"`
+	Data    testFileData `json:"data,omitempty"`
+}
+
+func makeTestMsg(t testing.TB, dataSize int) []byte {
+	data := make([]byte, dataSize)
+	rand.New(rand.NewSource(int64(dataSize))).Read(data)
+	msg, err := json.Marshal(testRpcMessage{
+		Command: "filewrite",
+		ReqId:   "7b0e7e3a-0000-4000-8000-000000000001",
+		Data:    testFileData{Path: `/tmp/"data64":"x`, Data64: base64.StdEncoding.EncodeToString(data)},
+	})
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	return msg
+}
+
+// one direction of a connection
+type testPipe struct {
+	r *io.PipeReader
+	w *io.PipeWriter
+}
+
+func makeTestPipe() testPipe {
+	r, w := io.Pipe()
+	return testPipe{r: r, w: w}
+}
+
+func TestBinaryFrameRoundTrip(t *testing.T) {
+	msgs := [][]byte{
+		makeTestMsg(t, 0),
+		makeTestMsg(t, 100),
+		makeTestMsg(t, 64*1024),
+		[]byte(`{"command":"filewrite","data":{"data64":"` + string(bytes.Repeat([]byte("A"), 2047)) + `"}}`), // not valid base64
+		[]byte(`{"a":{"data64":"` + base64.StdEncoding.EncodeToString(make([]byte, 2000)) + `"},"Data":"` + base64.StdEncoding.EncodeToString(make([]byte, 3000)) + `"}`),
+	}
+	for _, packetMode := range []bool{false, true} {
+		toServer := makeTestPipe()
+		toClient := makeTestPipe()
+		client := MakeFraming(toServer.w, packetMode, true)
+		server := MakeFraming(toClient.w, packetMode, false)
+		serverCh := make(chan []byte, 10)
+		clientCh := make(chan []byte, 10)
+		var wg sync.WaitGroup
+		wg.Add(2)
+		if packetMode {
+			go func() {
+				defer wg.Done()
+				Parse(toServer.r, serverCh, make(chan []byte, 10), server)
+			}()
+			go func() {
+				defer wg.Done()
+				ParseWithLinesChan(StreamToLinesChan(toClient.r), clientCh, make(chan []byte, 10), client)
+			}()
+		} else {
+			go func() {
+				defer wg.Done()
+				defer close(serverCh)
+				ParseJsonLines(toServer.r, serverCh, server)
+			}()
+			go func() {
+				defer wg.Done()
+				defer close(clientCh)
+				ParseJsonLines(toClient.r, clientCh, client)
+			}()
+		}
+		if err := client.Start(); err != nil {
+			t.Fatalf("start failed: %v", err)
+		}
+		// the server answers the hello, the client knows about it once it sees a message written after the answer
+		for !server.IsBinary() {
+			time.Sleep(time.Millisecond)
+		}
+		if err := server.WriteMsg([]byte(`{"command":"ping"}`)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		if msg := <-clientCh; string(msg) != `{"command":"ping"}` {
+			t.Fatalf("unexpected message %q", msg)
+		}
+		if !client.IsBinary() || !server.IsBinary() {
+			t.Fatalf("binary framing was not negotiated (packetMode=%v)", packetMode)
+		}
+		for _, msg := range msgs {
+			if err := client.WriteMsg(msg); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+			if err := server.WriteMsg(msg); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+			if got := <-serverCh; !bytes.Equal(got, msg) {
+				t.Errorf("server got a different message (packetMode=%v, len %d vs %d)", packetMode, len(got), len(msg))
+			}
+			if got := <-clientCh; !bytes.Equal(got, msg) {
+				t.Errorf("client got a different message (packetMode=%v, len %d vs %d)", packetMode, len(got), len(msg))
+			}
+		}
+		toServer.w.Close()
+		toClient.w.Close()
+		wg.Wait()
+	}
+}
+
+func TestOldPeer(t *testing.T) {
+	// an old peer never answers the hello, so only json is written
+	var output bytes.Buffer
+	framing := MakeFraming(&output, false, true)
+	framing.Start()
+	msg := makeTestMsg(t, 64*1024)
+	framing.WriteMsg(msg)
+	expected := string(FramingHello) + "\n" + string(msg) + "\n"
+	if output.String() != expected {
+		t.Errorf("expected json lines only, got %d bytes (expected %d)", output.Len(), len(expected))
+	}
+}
+
+func benchmarkWrite(b *testing.B, dataSize int, binary bool) {
+	msg := makeTestMsg(b, dataSize)
+	var output bytes.Buffer
+	framing := MakeFraming(&output, true, false)
+	framing.binary = binary
+	b.SetBytes(int64(dataSize))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		output.Reset()
+		if err := framing.WriteMsg(msg); err != nil {
+			b.Fatalf("write failed: %v", err)
+		}
+	}
+	b.ReportMetric(float64(output.Len()), "wirebytes/msg")
+}
+
+func benchmarkRead(b *testing.B, dataSize int, binary bool) {
+	msg := makeTestMsg(b, dataSize)
+	var output bytes.Buffer
+	framing := MakeFraming(&output, true, false)
+	framing.binary = binary
+	framing.WriteMsg(msg)
+	wire := output.Bytes()
+	b.SetBytes(int64(dataSize))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		packetCh := make(chan []byte, 1)
+		Parse(bytes.NewReader(wire), packetCh, make(chan []byte, 1), framing)
+		if len(<-packetCh) != len(msg) {
+			b.Fatalf("bad message")
+		}
+	}
+}
+
+func BenchmarkWriteJson64K(b *testing.B)   { benchmarkWrite(b, 64*1024, false) }
+func BenchmarkWriteBinary64K(b *testing.B) { benchmarkWrite(b, 64*1024, true) }
+func BenchmarkWriteJson1M(b *testing.B)    { benchmarkWrite(b, 1024*1024, false) }
+func BenchmarkWriteBinary1M(b *testing.B)  { benchmarkWrite(b, 1024*1024, true) }
+func BenchmarkReadJson64K(b *testing.B)    { benchmarkRead(b, 64*1024, false) }
+func BenchmarkReadBinary64K(b *testing.B)  { benchmarkRead(b, 64*1024, true) }
+func BenchmarkReadJson1M(b *testing.B)     { benchmarkRead(b, 1024*1024, false) }
+func BenchmarkReadBinary1M(b *testing.B)   { benchmarkRead(b, 1024*1024, true) }
@@ -18,7 +18,8 @@ type PacketParser struct {
 	Ch     chan []byte
 }
 
-func ParseWithLinesChan(input chan utilfn.LineOutput, packetCh chan []byte, rawCh chan []byte) {
+// framing (if set) consumes the other side's hello, binary frames arrive as "##N" packets (see StreamToLinesChan)
+func ParseWithLinesChan(input chan utilfn.LineOutput, packetCh chan []byte, rawCh chan []byte, framing *Framing) {
 	defer close(packetCh)
 	defer close(rawCh)
 	for {
@@ -37,20 +38,29 @@ func ParseWithLinesChan(input chan utilfn.LineOutput, packetCh chan []byte, rawC
 		}
 		if bytes.HasPrefix([]byte(line.Line), []byte{'#', '#', 'N', '{'}) && bytes.HasSuffix([]byte(line.Line), []byte{'}'}) {
 			// strip off the leading "##"
-			packetCh <- []byte(line.Line[3:len(line.Line)])
+			packet := []byte(line.Line[3:len(line.Line)])
+			if framing.handleHello(packet) {
+				continue
+			}
+			packetCh <- packet
 		} else {
 			rawCh <- []byte(line.Line)
 		}
 	}
 }
 
-func Parse(input io.Reader, packetCh chan []byte, rawCh chan []byte) error {
+// framing (if set) consumes the other side's hello and decodes binary frames
+func Parse(input io.Reader, packetCh chan []byte, rawCh chan []byte, framing *Framing) error {
 	bufReader := bufio.NewReader(input)
 	defer close(packetCh)
 	defer close(rawCh)
 	for {
-		// note this line does have a trailing newline
-		line, err := bufReader.ReadBytes('\n')
+		// note this line does have a trailing newline (binary frames are returned without one)
+		line, isFrame, err := readLineOrFrame(bufReader, 0)
+		if isFrame {
+			packetCh <- line
+			continue
+		}
 		if err == io.EOF {
 			return nil
 		}
@@ -63,7 +73,11 @@ func Parse(input io.Reader, packetCh chan []byte, rawCh chan []byte) error {
 		}
 		if bytes.HasPrefix(line, []byte{'#', '#', 'N', '{'}) && bytes.HasSuffix(line, []byte{'}', '\n'}) {
 			// strip off the leading "##" and trailing "\n" (single byte)
-			packetCh <- line[3 : len(line)-1]
+			packet := line[3 : len(line)-1]
+			if framing.handleHello(packet) {
+				continue
+			}
+			packetCh <- packet
 		} else {
 			rawCh <- line
 		}
@@ -0,0 +1,194 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package shellutil
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// shell integration markers, OSC 133 (semantic prompt) and OSC 7 (cwd)
+const (
+	SIMark_PromptStart = "A" // start of the prompt
+	SIMark_InputStart  = "B" // end of the prompt, start of the command line
+	SIMark_OutputStart = "C" // command was submitted, output follows
+	SIMark_CommandDone = "D" // command finished (optional exit code)
+	SIMark_Cwd         = "cwd"
+)
+
+const MaxSIPayloadSize = 8 * 1024
+
+const (
+	siState_Normal  = 0
+	siState_Esc     = 1 // got ESC
+	siState_Osc     = 2 // inside of an OSC
+	siState_OscEsc  = 3 // got ESC inside of an OSC (expecting '\' for ST)
+	siState_OscSkip = 4 // OSC too large, discard until it terminates
+)
+
+type SIMark struct {
+	Mark      string
+	Offset    int64 // offset of the ESC that started the sequence
+	EndOffset int64 // offset just past the terminator
+	ExitCode  *int  // D
+	CmdLine   string
+	Cwd       string
+}
+
+// watches terminal output for shell integration sequences.  the sequences are not removed from the output
+// (the terminal ignores them), the scanner only reports where they were.  sequences can span chunks.
+type SIScanner struct {
+	state    int
+	seqStart int64
+	payload  []byte
+}
+
+func MakeSIScanner() *SIScanner {
+	return &SIScanner{}
+}
+
+// baseOffset is the offset of data[0] in the output stream
+func (s *SIScanner) Scan(baseOffset int64, data []byte) []SIMark {
+	var rtn []SIMark
+	for idx, ch := range data {
+		offset := baseOffset + int64(idx)
+		switch s.state {
+		case siState_Normal:
+			if ch == 0x1b {
+				s.state = siState_Esc
+				s.seqStart = offset
+			}
+		case siState_Esc:
+			if ch == ']' {
+				s.state = siState_Osc
+				s.payload = s.payload[:0]
+			} else if ch != 0x1b {
+				s.state = siState_Normal
+			} else {
+				s.seqStart = offset
+			}
+		case siState_Osc, siState_OscSkip:
+			if ch == 0x07 {
+				if s.state == siState_Osc {
+					if mark, ok := parseSIPayload(string(s.payload)); ok {
+						mark.Offset = s.seqStart
+						mark.EndOffset = offset + 1
+						rtn = append(rtn, mark)
+					}
+				}
+				s.state = siState_Normal
+			} else if ch == 0x1b {
+				if s.state == siState_OscSkip {
+					s.state = siState_Esc
+					s.seqStart = offset
+				} else {
+					s.state = siState_OscEsc
+				}
+			} else if s.state == siState_Osc {
+				if len(s.payload) >= MaxSIPayloadSize {
+					s.state = siState_OscSkip
+				} else {
+					s.payload = append(s.payload, ch)
+				}
+			}
+		case siState_OscEsc:
+			if ch == '\\' {
+				if mark, ok := parseSIPayload(string(s.payload)); ok {
+					mark.Offset = s.seqStart
+					mark.EndOffset = offset + 1
+					rtn = append(rtn, mark)
+				}
+				s.state = siState_Normal
+			} else if ch == ']' {
+				// unterminated OSC followed by a new OSC
+				s.state = siState_Osc
+				s.seqStart = offset - 1
+				s.payload = s.payload[:0]
+			} else {
+				s.state = siState_Normal
+			}
+		}
+	}
+	return rtn
+}
+
+func parseSIPayload(payload string) (SIMark, bool) {
+	if cwdUrl, ok := strings.CutPrefix(payload, "7;"); ok {
+		cwd := parseOsc7Cwd(cwdUrl)
+		if cwd == "" {
+			return SIMark{}, false
+		}
+		return SIMark{Mark: SIMark_Cwd, Cwd: cwd}, true
+	}
+	rest, ok := strings.CutPrefix(payload, "133;")
+	if !ok || rest == "" {
+		return SIMark{}, false
+	}
+	mark := SIMark{Mark: rest[:1]}
+	var params string
+	if len(rest) > 1 {
+		if rest[1] != ';' {
+			return SIMark{}, false
+		}
+		params = rest[2:]
+	}
+	switch mark.Mark {
+	case SIMark_PromptStart, SIMark_InputStart:
+	case SIMark_OutputStart:
+		mark.CmdLine = parseSICmdLine(params)
+	case SIMark_CommandDone:
+		exitStr, _, _ := strings.Cut(params, ";")
+		if exitCode, err := strconv.Atoi(exitStr); err == nil {
+			mark.ExitCode = &exitCode
+		}
+	default:
+		return SIMark{}, false
+	}
+	return mark, true
+}
+
+// supports the kitty extensions: "cmdline=" (raw, runs to the end of the sequence) and "cmdline_url=" (percent encoded)
+func parseSICmdLine(params string) string {
+	for params != "" {
+		if cmdLine, ok := strings.CutPrefix(params, "cmdline="); ok {
+			return cmdLine
+		}
+		var param string
+		param, params, _ = strings.Cut(params, ";")
+		if encoded, ok := strings.CutPrefix(param, "cmdline_url="); ok {
+			cmdLine, err := url.PathUnescape(encoded)
+			if err != nil {
+				return encoded
+			}
+			return cmdLine
+		}
+	}
+	return ""
+}
+
+// file://host/path.  the shells don't always percent encode the path, so fall back to the raw path
+func parseOsc7Cwd(cwdUrl string) string {
+	rest, ok := strings.CutPrefix(cwdUrl, "file://")
+	if !ok {
+		return ""
+	}
+	slashIdx := strings.Index(rest, "/")
+	if slashIdx == -1 {
+		return ""
+	}
+	path, err := url.PathUnescape(rest[slashIdx:])
+	if err != nil {
+		path = rest[slashIdx:]
+	}
+	// windows drive paths come through as file://host/C:/...
+	if len(path) >= 3 && path[2] == ':' && isAsciiLetter(path[1]) {
+		path = path[1:]
+	}
+	return path
+}
+
+func isAsciiLetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
@@ -0,0 +1,68 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+package shellutil
+
+import "testing"
+
+func TestSIScanner(t *testing.T) {
+	output := "\x1b]7;file://myhost/home/user/my%20dir\x07" +
+		"\x1b]133;A\x07$ \x1b]133;B\x07ls -l\r\n" +
+		"\x1b]133;C;cmdline=ls -l; echo a=b\x07" +
+		"file1\r\nfile2\r\n" +
+		"\x1b]133;D;2\x1b\\" +
+		"\x1b[0m\x1b]0;title\x07" +
+		"\x1b]133;C;cmdline_url=echo%20hi\x07hi\r\n\x1b]133;D\x07"
+
+	// feed the output in different chunk sizes, sequences must be reassembled across chunks
+	for _, chunkSize := range []int{len(output), 1, 7} {
+		scanner := MakeSIScanner()
+		var marks []SIMark
+		for start := 0; start < len(output); start += chunkSize {
+			end := min(start+chunkSize, len(output))
+			marks = append(marks, scanner.Scan(int64(start), []byte(output[start:end]))...)
+		}
+		if len(marks) != 7 {
+			t.Fatalf("chunk size %d: expected 7 marks, got %d: %#v", chunkSize, len(marks), marks)
+		}
+		if marks[0].Mark != SIMark_Cwd || marks[0].Cwd != "/home/user/my dir" {
+			t.Errorf("chunk size %d: bad cwd mark %#v", chunkSize, marks[0])
+		}
+		if marks[1].Mark != SIMark_PromptStart || marks[2].Mark != SIMark_InputStart {
+			t.Errorf("chunk size %d: bad prompt marks %#v %#v", chunkSize, marks[1], marks[2])
+		}
+		if marks[3].Mark != SIMark_OutputStart || marks[3].CmdLine != "ls -l; echo a=b" {
+			t.Errorf("chunk size %d: bad output start mark %#v", chunkSize, marks[3])
+		}
+		if output[marks[3].EndOffset:marks[4].Offset] != "file1\r\nfile2\r\n" {
+			t.Errorf("chunk size %d: bad command output offsets %d-%d", chunkSize, marks[3].EndOffset, marks[4].Offset)
+		}
+		if marks[4].Mark != SIMark_CommandDone || marks[4].ExitCode == nil || *marks[4].ExitCode != 2 {
+			t.Errorf("chunk size %d: bad command done mark %#v", chunkSize, marks[4])
+		}
+		if marks[5].CmdLine != "echo hi" {
+			t.Errorf("chunk size %d: bad url encoded cmdline %q", chunkSize, marks[5].CmdLine)
+		}
+		if marks[6].Mark != SIMark_CommandDone || marks[6].ExitCode != nil {
+			t.Errorf("chunk size %d: expected no exit code, got %#v", chunkSize, marks[6])
+		}
+	}
+}
+
+func TestParseOsc7Cwd(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"file://host/tmp", "/tmp"},
+		{"file:///tmp/a%20b", "/tmp/a b"},
+		{"file://host/tmp/100%", "/tmp/100%"},
+		{"file://host/C:/Users/me", "C:/Users/me"},
+		{"http://host/tmp", ""},
+		{"file://host", ""},
+	}
+	for _, test := range tests {
+		if got := parseOsc7Cwd(test.input); got != test.want {
+			t.Errorf("parseOsc7Cwd(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
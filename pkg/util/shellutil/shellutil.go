@@ -73,6 +73,36 @@ unset STARTERM_WSHBINDIR
 if [[ -n ${_comps+x} ]]; then
   source <(wsh completion zsh)
 fi
+
+# shell integration (OSC 133 command markers, OSC 7 cwd)
+_starterm_si_precmd() {
+  local ret=$?
+  printf '\033]133;D;%s\007' "$ret"
+  printf '\033]7;file://%s%s\007' "$HOST" "$PWD"
+  printf '\033]133;A\007'
+  return $ret
+}
+_starterm_si_preexec() {
+  printf '\033]133;C;cmdline=%s\007' "${1//[[:cntrl:]]/ }"
+}
+# precmd must run first to see the exit code of the command
+precmd_functions=(_starterm_si_precmd $precmd_functions)
+preexec_functions+=(_starterm_si_preexec)
+PS1="$PS1%{"$'\033]133;B\007'"%}"
+
+# Ctrl-R searches the star command history when fzf is installed (set STARTERM_NOHISTORYKEYS to keep your own binding)
+if [[ -z "$STARTERM_NOHISTORYKEYS" ]] && (( $+commands[wsh] && $+commands[fzf] )); then
+  _starterm_history_widget() {
+    if wsh history -i -- "$BUFFER" </dev/tty; then
+      BUFFER=""
+      CURSOR=0
+    fi
+    zle reset-prompt
+  }
+  zle -N _starterm_history_widget
+  bindkey -M emacs '^R' _starterm_history_widget
+  bindkey -M viins '^R' _starterm_history_widget
+fi
 `
 
 	ZshStartup_Zlogin = `
@@ -133,6 +163,46 @@ if type _init_completion &>/dev/null; then
   source <(wsh completion bash)
 fi
 
+# shell integration (OSC 133 command markers, OSC 7 cwd)
+_starterm_si_precmd() {
+    local ret=$?
+    local hist
+    hist=$(HISTTIMEFORMAT= builtin history 1)
+    if [[ $hist =~ ^[[:space:]]*([0-9]+) ]]; then
+        _starterm_si_histnum="${BASH_REMATCH[1]}"
+    fi
+    printf '\033]133;D;%s\007' "$ret"
+    printf '\033]7;file://%s%s\007' "$HOSTNAME" "$PWD"
+    printf '\033]133;A\007'
+    return $ret
+}
+_starterm_si_preexec() {
+    local cmd
+    cmd=$(HISTTIMEFORMAT= builtin history 1)
+    # when the history did not advance the command was not saved (HISTCONTROL, HISTIGNORE) and history 1 is an older command
+    if [[ ! $cmd =~ ^[[:space:]]*([0-9]+)[*]?[[:space:]]+(.*)$ ]] || [[ ${BASH_REMATCH[1]} == "$_starterm_si_histnum" ]]; then
+        printf '\033]133;C\007'
+        return
+    fi
+    cmd="${BASH_REMATCH[2]}"
+    printf '\033]133;C;cmdline=%s\007' "${cmd//[[:cntrl:]]/ }"
+}
+PROMPT_COMMAND="_starterm_si_precmd${PROMPT_COMMAND:+; $PROMPT_COMMAND}"
+PS1="$PS1"'\[\033]133;B\007\]'
+# PS0 (runs right before a command executes) requires bash 4.4+, older versions only get prompt markers
+PS0="$PS0"'$(_starterm_si_preexec)'
+
+# Ctrl-R searches the star command history when fzf is installed (set STARTERM_NOHISTORYKEYS to keep your own binding)
+_starterm_history_widget() {
+    if wsh history -i -- "$READLINE_LINE"; then
+        READLINE_LINE=""
+        READLINE_POINT=0
+    fi
+}
+if [[ -z "$STARTERM_NOHISTORYKEYS" ]] && type wsh &>/dev/null && type fzf &>/dev/null; then
+    bind -x '"\C-r": _starterm_history_widget'
+fi
+
 `
 
 	FishStartup_Starfish = `
@@ -146,6 +216,30 @@ set -e STARTERM_SWAPTOKEN
 
 # Load Star completions
 wsh completion fish | source
+
+# shell integration (OSC 133 command markers, OSC 7 cwd)
+function _starterm_si_preexec --on-event fish_preexec
+    printf '\033]133;C;cmdline=%s\007' (string replace -ra '[[:cntrl:]]' ' ' -- $argv[1])
+end
+function _starterm_si_postexec --on-event fish_postexec
+    printf '\033]133;D;%s\007' $status
+end
+function _starterm_si_prompt --on-event fish_prompt
+    printf '\033]7;file://%s%s\007' (hostname) "$PWD"
+    printf '\033]133;A\007'
+end
+
+# Ctrl-R searches the star command history when fzf is installed (set STARTERM_NOHISTORYKEYS to keep your own binding)
+function _starterm_history_widget
+    if wsh history -i -- (commandline | string collect)
+        commandline -r ''
+    end
+    commandline -f repaint
+end
+if test -z "$STARTERM_NOHISTORYKEYS"; and type -q wsh; and type -q fzf
+    bind \cr _starterm_history_widget
+    bind -M insert \cr _starterm_history_widget 2>/dev/null
+end
 `
 
 	PwshStartup_starpwsh = `
@@ -162,6 +256,50 @@ Remove-Item Env:STARTERM_SWAPTOKEN
 
 # Load Star completions
 wsh completion powershell | Out-String | Invoke-Expression
+
+# shell integration (OSC 133 command markers, OSC 7 cwd)
+$global:_starterm_si_orig_prompt = $function:prompt
+function global:prompt {
+    $starterm_si_exit = if ($?) { 0 } elseif ($global:LASTEXITCODE) { $global:LASTEXITCODE } else { 1 }
+    $starterm_si_esc = [char]27
+    $starterm_si_bel = [char]7
+    $starterm_si_out = "$starterm_si_esc]133;D;$starterm_si_exit$starterm_si_bel"
+    if ($PWD.Provider.Name -eq "FileSystem") {
+        $starterm_si_path = $PWD.ProviderPath -replace '\\', '/'
+        if (-not $starterm_si_path.StartsWith('/')) {
+            $starterm_si_path = '/' + $starterm_si_path
+        }
+        $starterm_si_out += "$starterm_si_esc]7;file://$([System.Net.Dns]::GetHostName())$starterm_si_path$starterm_si_bel"
+    }
+    $starterm_si_out += "$starterm_si_esc]133;A$starterm_si_bel"
+    $starterm_si_out + (& $global:_starterm_si_orig_prompt) + "$starterm_si_esc]133;B$starterm_si_bel"
+}
+# PSReadLine calls the history handler when a command is accepted (right before it runs)
+if (Get-Module PSReadLine) {
+    $global:_starterm_si_orig_history_handler = (Get-PSReadLineOption).AddToHistoryHandler
+    Set-PSReadLineOption -AddToHistoryHandler {
+        param([string]$line)
+        [Console]::Write("$([char]27)]133;C;cmdline=$($line -replace '[\x00-\x1f]', ' ')$([char]7)")
+        if ($global:_starterm_si_orig_history_handler) {
+            return & $global:_starterm_si_orig_history_handler $line
+        }
+        return $true
+    }
+}
+
+# Ctrl-R searches the star command history when fzf is installed (set STARTERM_NOHISTORYKEYS to keep your own binding)
+if ((Get-Module PSReadLine) -and -not $env:STARTERM_NOHISTORYKEYS -and (Get-Command wsh -ErrorAction SilentlyContinue) -and (Get-Command fzf -ErrorAction SilentlyContinue)) {
+    Set-PSReadLineKeyHandler -Chord Ctrl+r -BriefDescription StarHistory -ScriptBlock {
+        $starterm_line = $null
+        $starterm_cursor = $null
+        [Microsoft.PowerShell.PSConsoleReadLine]::GetBufferState([ref]$starterm_line, [ref]$starterm_cursor)
+        wsh history -i -- $starterm_line
+        if ($LASTEXITCODE -eq 0) {
+            [Microsoft.PowerShell.PSConsoleReadLine]::RevertLine()
+        }
+        [Microsoft.PowerShell.PSConsoleReadLine]::InvokePrompt()
+    }
+}
 `
 )
 
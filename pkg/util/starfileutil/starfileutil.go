@@ -21,6 +21,7 @@ func StarFileToFileInfo(wf *filestore.StarFile) *wshrpc.FileInfo {
 		Name:          wf.Name,
 		Opts:          &wf.Opts,
 		Size:          wf.Size,
+		ModTime:       wf.ModTs,
 		Meta:          &wf.Meta,
 		SupportsMkdir: false,
 	}
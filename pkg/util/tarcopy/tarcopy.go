@@ -6,13 +6,20 @@ package tarcopy
 
 import (
 	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"log"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/commandlinedev/starterm/pkg/util/iochan"
@@ -27,18 +34,59 @@ const (
 	pipeReaderName  = "pipe reader"
 	pipeWriterName  = "pipe writer"
 	tarWriterName   = "tar writer"
+	gzipWriterName  = "gzip writer"
 
 	// custom flag to indicate that the source is a single file
 	SingleFile = "singlefile"
+
+	// per-file pax records
+	FileChecksum = "sha256"       // hex sha256 of the whole file, verified when the entry is read
+	ResumeOffset = "resumeoffset" // the entry only holds the file data starting at this offset
+	Skipped      = "skipped"      // the file already matches at the destination, the entry holds no data
+)
+
+const (
+	Compression_None = "none"
+	Compression_Gzip = "gzip"
 )
 
+var gzipMagic = []byte{0x1f, 0x8b}
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// returns the compression the source will use for a requested compression (unsupported values fall back to none).
+// the destination detects the compression from the stream, so it never has to be told.
+func NegotiateCompression(requested string) string {
+	if requested == Compression_Gzip {
+		return Compression_Gzip
+	}
+	return Compression_None
+}
+
 // TarCopySrc creates a tar stream writer and returns a channel to send the tar stream to.
 // writeHeader is a function that writes the tar header for the file. If only a single file is being written, the singleFile flag should be set to true.
 // writer is the tar writer to write the file data to.
 // close is a function that closes the tar writer and internal pipe writer.
 func TarCopySrc(ctx context.Context, pathPrefix string) (outputChan chan wshrpc.RespOrErrorUnion[iochantypes.Packet], writeHeader func(fi fs.FileInfo, file string, singleFile bool) error, writer io.Writer, close func()) {
+	rtnChan, writeHeaderWithRecords, writer, close := TarCopySrcCompressed(ctx, pathPrefix, Compression_None)
+	return rtnChan, func(fi fs.FileInfo, path string, singleFile bool) error {
+		return writeHeaderWithRecords(fi, path, singleFile, nil)
+	}, writer, close
+}
+
+// TarCopySrcCompressed is TarCopySrc with a compressed stream (see NegotiateCompression).
+// writeHeader takes extra pax records for the entry (FileChecksum, ResumeOffset, Skipped), the entry size is adjusted for ResumeOffset and Skipped.
+func TarCopySrcCompressed(ctx context.Context, pathPrefix string, compression string) (outputChan chan wshrpc.RespOrErrorUnion[iochantypes.Packet], writeHeader func(fi fs.FileInfo, file string, singleFile bool, records map[string]string) error, writer io.Writer, close func()) {
 	pipeReader, pipeWriter := io.Pipe()
-	tarWriter := tar.NewWriter(pipeWriter)
+	var tarWriter *tar.Writer
+	var gzipWriter *gzip.Writer
+	if NegotiateCompression(compression) == Compression_Gzip {
+		// favor throughput, the stream is usually network bound well before BestSpeed is cpu bound
+		gzipWriter, _ = gzip.NewWriterLevel(pipeWriter, gzip.BestSpeed)
+		tarWriter = tar.NewWriter(gzipWriter)
+	} else {
+		tarWriter = tar.NewWriter(pipeWriter)
+	}
 	rtnChan := iochan.ReaderChan(ctx, pipeReader, wshrpc.FileChunkSize, func() {
 		log.Printf("Closing pipe reader\n")
 		utilfn.GracefulClose(pipeReader, tarCopySrcName, pipeReaderName)
@@ -46,7 +94,7 @@ func TarCopySrc(ctx context.Context, pathPrefix string) (outputChan chan wshrpc.
 
 	singleFileFlagSet := false
 
-	return rtnChan, func(fi fs.FileInfo, path string, singleFile bool) error {
+	return rtnChan, func(fi fs.FileInfo, path string, singleFile bool, records map[string]string) error {
 			// generate tar header
 			header, err := tar.FileInfoHeader(fi, path)
 			if err != nil {
@@ -62,6 +110,23 @@ func TarCopySrc(ctx context.Context, pathPrefix string) (outputChan chan wshrpc.
 				singleFileFlagSet = true
 			}
 
+			if len(records) > 0 {
+				if header.PAXRecords == nil {
+					header.PAXRecords = make(map[string]string)
+				}
+				for key, val := range records {
+					header.PAXRecords[key] = val
+				}
+				if records[Skipped] == "true" {
+					header.Size = 0
+				} else if offset := ResumeOffsetOf(header); offset > 0 {
+					if offset > header.Size {
+						return fmt.Errorf("resume offset %d is past the end of %q", offset, path)
+					}
+					header.Size -= offset
+				}
+			}
+
 			path, err = fixPath(path, pathPrefix)
 			if err != nil {
 				return err
@@ -81,10 +146,78 @@ func TarCopySrc(ctx context.Context, pathPrefix string) (outputChan chan wshrpc.
 		}, tarWriter, func() {
 			log.Printf("Closing tar writer\n")
 			utilfn.GracefulClose(tarWriter, tarCopySrcName, tarWriterName)
+			if gzipWriter != nil {
+				utilfn.GracefulClose(gzipWriter, tarCopySrcName, gzipWriterName)
+			}
 			utilfn.GracefulClose(pipeWriter, tarCopySrcName, pipeWriterName)
 		}
 }
 
+// EntryName returns the name of the tar entry TarCopySrc writes for path
+func EntryName(path, pathPrefix string) (string, error) {
+	name, err := fixPath(path, pathPrefix)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(name), nil
+}
+
+// ResumeOffsetOf returns the ResumeOffset record of the header (0 if not set)
+func ResumeOffsetOf(header *tar.Header) int64 {
+	if header.PAXRecords == nil || header.PAXRecords[ResumeOffset] == "" {
+		return 0
+	}
+	offset, err := strconv.ParseInt(header.PAXRecords[ResumeOffset], 10, 64)
+	if err != nil || offset < 0 {
+		return 0
+	}
+	return offset
+}
+
+// IsSkipped returns true if the header is for a file that already matches at the destination
+func IsSkipped(header *tar.Header) bool {
+	return header.PAXRecords != nil && header.PAXRecords[Skipped] == "true"
+}
+
+// ChecksumFile returns the hex sha256 of a file, to be set as the FileChecksum record
+func ChecksumFile(r io.Reader) (string, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// verifies the FileChecksum record of an entry once the entry has been read to EOF
+type checksumReader struct {
+	reader   io.Reader
+	hasher   hash.Hash
+	expected string
+	name     string
+}
+
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.hasher.Write(p[:n])
+	if err == io.EOF {
+		if actual := hex.EncodeToString(cr.hasher.Sum(nil)); actual != cr.expected {
+			return n, fmt.Errorf("%w for %q", ErrChecksumMismatch, cr.name)
+		}
+	}
+	return n, err
+}
+
+// SeedChecksum feeds the data already at the destination (the first ResumeOffset bytes of the file) into the checksum of a resumed entry.
+// reader is the reader passed to readNext, this is a no-op if the entry has no checksum.
+func SeedChecksum(reader io.Reader, prefix io.Reader) error {
+	cr, ok := reader.(*checksumReader)
+	if !ok {
+		return nil
+	}
+	_, err := io.Copy(cr.hasher, prefix)
+	return err
+}
+
 func fixPath(path, prefix string) (string, error) {
 	path = strings.TrimPrefix(strings.TrimPrefix(filepath.Clean(strings.TrimPrefix(path, prefix)), "/"), "\\")
 	if strings.Contains(path, "..") {
@@ -95,13 +228,26 @@ func fixPath(path, prefix string) (string, error) {
 
 // TarCopyDest reads a tar stream from a channel and writes the files to the destination.
 // readNext is a function that is called for each file in the tar stream to read the file data. If only a single file is being written from the tar src, the singleFile flag will be set in this callback. It should return an error if the file cannot be read.
+// Compressed streams are detected and decompressed. If the entry has a FileChecksum record, reading the entry to EOF returns an error if the checksum does not match (see SeedChecksum for resumed entries).
 // The function returns an error if the tar stream cannot be read.
-func TarCopyDest(ctx context.Context, cancel context.CancelCauseFunc, ch <-chan wshrpc.RespOrErrorUnion[iochantypes.Packet], readNext func(next *tar.Header, reader *tar.Reader, singleFile bool) error) error {
+func TarCopyDest(ctx context.Context, cancel context.CancelCauseFunc, ch <-chan wshrpc.RespOrErrorUnion[iochantypes.Packet], readNext func(next *tar.Header, reader io.Reader, singleFile bool) error) error {
 	pipeReader, pipeWriter := io.Pipe()
 	iochan.WriterChan(ctx, pipeWriter, ch, func() {
 		utilfn.GracefulClose(pipeWriter, tarCopyDestName, pipeWriterName)
 	}, cancel)
-	tarReader := tar.NewReader(pipeReader)
+	bufReader := bufio.NewReader(pipeReader)
+	var streamReader io.Reader = bufReader
+	// an empty stream (or an error) falls through to the tar reader, which reports it
+	if magic, err := bufReader.Peek(len(gzipMagic)); err == nil && bytes.Equal(magic, gzipMagic) {
+		gzipReader, err := gzip.NewReader(bufReader)
+		if err != nil {
+			utilfn.GracefulClose(pipeReader, tarCopyDestName, pipeReaderName)
+			cancel(nil)
+			return fmt.Errorf("cannot read compressed tar stream: %w", err)
+		}
+		streamReader = gzipReader
+	}
+	tarReader := tar.NewReader(streamReader)
 	defer func() {
 		if !utilfn.GracefulClose(pipeReader, tarCopyDestName, pipeReaderName) {
 			// If the pipe reader cannot be closed, cancel the context. This should kill the writer goroutine.
@@ -123,6 +269,14 @@ func TarCopyDest(ctx context.Context, cancel context.CancelCauseFunc, ch <-chan
 					return context.Cause(ctx)
 				}
 				if errors.Is(err, io.EOF) {
+					// read the rest of the stream, this verifies the gzip trailer and lets the writer finish
+					if _, err := io.Copy(io.Discard, streamReader); err != nil && ctx.Err() == nil {
+						return fmt.Errorf("cannot read end of tar stream: %w", err)
+					}
+					if ctx.Err() != nil {
+						// the stream checksum did not match
+						return context.Cause(ctx)
+					}
 					return nil
 				} else {
 					return err
@@ -133,7 +287,11 @@ func TarCopyDest(ctx context.Context, cancel context.CancelCauseFunc, ch <-chan
 			if strings.Contains(next.Name, "..") {
 				return fmt.Errorf("invalid tar path containing directory traversal: %s", next.Name)
 			}
-			err = readNext(next, tarReader, next.PAXRecords != nil && next.PAXRecords[SingleFile] == "true")
+			var entryReader io.Reader = tarReader
+			if next.PAXRecords != nil && next.PAXRecords[FileChecksum] != "" && !IsSkipped(next) {
+				entryReader = &checksumReader{reader: tarReader, hasher: sha256.New(), expected: next.PAXRecords[FileChecksum], name: next.Name}
+			}
+			err = readNext(next, entryReader, next.PAXRecords != nil && next.PAXRecords[SingleFile] == "true")
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,151 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package tarcopy_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/commandlinedev/starterm/pkg/util/tarcopy"
+)
+
+type copiedFile struct {
+	data     []byte
+	skipped  bool
+	resumeAt int64
+}
+
+func writeTestFile(t *testing.T, dir string, name string, data string) (string, fs.FileInfo) {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	return path, info
+}
+
+// streams the files (with the given records) through TarCopySrcCompressed and TarCopyDest
+func copyFiles(t *testing.T, dir string, compression string, files []string, records []map[string]string, seed map[string]string) (map[string]copiedFile, error) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	ch, writeHeader, writer, closeFn := tarcopy.TarCopySrcCompressed(ctx, dir, compression)
+	go func() {
+		defer closeFn()
+		for idx, path := range files {
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Errorf("stat failed: %v", err)
+				return
+			}
+			if err := writeHeader(info, path, false, records[idx]); err != nil {
+				t.Errorf("write header failed: %v", err)
+				return
+			}
+			if records[idx][tarcopy.Skipped] == "true" {
+				continue
+			}
+			data, _ := os.ReadFile(path)
+			if _, err := writer.Write(data[tarcopy.ResumeOffsetOf(&tar.Header{PAXRecords: records[idx]}):]); err != nil {
+				t.Errorf("write failed: %v", err)
+				return
+			}
+		}
+	}()
+	copied := make(map[string]copiedFile)
+	err := tarcopy.TarCopyDest(ctx, cancel, ch, func(next *tar.Header, reader io.Reader, singleFile bool) error {
+		if prefix, ok := seed[next.Name]; ok {
+			if err := tarcopy.SeedChecksum(reader, strings.NewReader(prefix)); err != nil {
+				return err
+			}
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		copied[next.Name] = copiedFile{data: data, skipped: tarcopy.IsSkipped(next), resumeAt: tarcopy.ResumeOffsetOf(next)}
+		return nil
+	})
+	return copied, err
+}
+
+func checksumOf(t *testing.T, data string) string {
+	checksum, err := tarcopy.ChecksumFile(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("checksum failed: %v", err)
+	}
+	return checksum
+}
+
+func TestTarCopy_Compressed(t *testing.T) {
+	for _, compression := range []string{tarcopy.Compression_None, tarcopy.Compression_Gzip} {
+		dir := t.TempDir()
+		content := strings.Repeat("hello world\n", 1000)
+		path1, _ := writeTestFile(t, dir, "a.txt", content)
+		path2, _ := writeTestFile(t, dir, "b.txt", "")
+		records := []map[string]string{
+			{tarcopy.FileChecksum: checksumOf(t, content)},
+			{tarcopy.FileChecksum: checksumOf(t, "")},
+		}
+		copied, err := copyFiles(t, dir, compression, []string{path1, path2}, records, nil)
+		if err != nil {
+			t.Fatalf("%s: copy failed: %v", compression, err)
+		}
+		if string(copied["a.txt"].data) != content {
+			t.Errorf("%s: content mismatch for a.txt", compression)
+		}
+		if _, ok := copied["b.txt"]; !ok {
+			t.Errorf("%s: b.txt not copied", compression)
+		}
+	}
+}
+
+func TestTarCopy_ChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	path, _ := writeTestFile(t, dir, "a.txt", "hello world")
+	records := []map[string]string{{tarcopy.FileChecksum: checksumOf(t, "something else")}}
+	_, err := copyFiles(t, dir, tarcopy.Compression_Gzip, []string{path}, records, nil)
+	if !errors.Is(err, tarcopy.ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestTarCopy_Resume(t *testing.T) {
+	dir := t.TempDir()
+	content := "0123456789abcdefghij"
+	path1, _ := writeTestFile(t, dir, "partial.txt", content)
+	path2, _ := writeTestFile(t, dir, "done.txt", "already there")
+	records := []map[string]string{
+		{tarcopy.ResumeOffset: "10", tarcopy.FileChecksum: checksumOf(t, content)},
+		{tarcopy.Skipped: "true"},
+	}
+	copied, err := copyFiles(t, dir, tarcopy.Compression_Gzip, []string{path1, path2}, records, map[string]string{"partial.txt": content[:10]})
+	if err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	partial := copied["partial.txt"]
+	if partial.resumeAt != 10 || !bytes.Equal(partial.data, []byte(content[10:])) {
+		t.Errorf("unexpected resumed data %q (offset %d)", partial.data, partial.resumeAt)
+	}
+	done := copied["done.txt"]
+	if !done.skipped || len(done.data) != 0 {
+		t.Errorf("expected done.txt to be skipped without data")
+	}
+
+	// resuming from a prefix that does not match the source fails the checksum
+	_, err = copyFiles(t, dir, tarcopy.Compression_None, []string{path1}, records[:1], map[string]string{"partial.txt": "xxxxxxxxxx"})
+	if !errors.Is(err, tarcopy.ErrChecksumMismatch) {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
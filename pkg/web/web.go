@@ -24,6 +24,7 @@ import (
 	"github.com/commandlinedev/starterm/pkg/panichandler"
 	"github.com/commandlinedev/starterm/pkg/remote/fileshare"
 	"github.com/commandlinedev/starterm/pkg/schema"
+	"github.com/commandlinedev/starterm/pkg/sconfig"
 	"github.com/commandlinedev/starterm/pkg/service"
 	"github.com/commandlinedev/starterm/pkg/starbase"
 	"github.com/commandlinedev/starterm/pkg/util/utilfn"
@@ -377,6 +378,19 @@ func WriteJsonSuccess(w http.ResponseWriter, data interface{}) {
 	w.Write(barr)
 }
 
+// rpc metrics in the prometheus text format, only served when the debug:rpcmetrics setting is on
+func handleRpcMetrics(w http.ResponseWriter, r *http.Request) {
+	watcher := sconfig.GetWatcher()
+	if watcher == nil || !watcher.GetFullConfig().Settings.DebugRpcMetrics {
+		http.Error(w, "rpc metrics are disabled (set debug:rpcmetrics to enable them)", http.StatusNotFound)
+		return
+	}
+	stats := wshutil.GetRpcStats(wshutil.DefaultRoute, wshrpc.CommandRpcStatsData{})
+	w.Header().Set(ContentTypeHeaderKey, "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	wshutil.WriteRpcStatsPrometheus(w, stats)
+}
+
 type ClientActiveState struct {
 	Fg     bool `json:"fg"`
 	Active bool `json:"active"`
@@ -447,6 +461,7 @@ func RunWebServer(listener net.Listener) {
 	gr.PathPrefix("/star/stream-file/").HandlerFunc(WebFnWrap(WebFnOpts{AllowCaching: true}, handleStreamFile))
 	gr.HandleFunc("/star/file", WebFnWrap(WebFnOpts{AllowCaching: false}, handleStarFile))
 	gr.HandleFunc("/star/service", WebFnWrap(WebFnOpts{JsonErrors: true}, handleService))
+	gr.HandleFunc("/star/rpc-metrics", WebFnWrap(WebFnOpts{AllowCaching: false}, handleRpcMetrics))
 	gr.HandleFunc("/vdom/{uuid}/{path:.*}", WebFnWrap(WebFnOpts{AllowCaching: true}, handleVDom))
 	gr.PathPrefix(docsitePrefix).Handler(http.StripPrefix(docsitePrefix, docsite.GetDocsiteHandler()))
 	gr.PathPrefix(schemaPrefix).Handler(http.StripPrefix(schemaPrefix, schema.GetSchemaHandler()))
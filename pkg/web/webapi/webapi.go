@@ -0,0 +1,329 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+// local automation api (rest + websocket) for driving star from external tools (editor plugins, ci dashboards).
+// it listens on 127.0.0.1 (separate from the web server, whose port and auth key are only known to electron),
+// only runs when api:enabled is set, and every request needs an api token (see apitokens.json and wsh apitoken).
+// tokens are scoped (wshrpc.ApiScope_*), each endpoint requires one scope.
+package webapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/authkey"
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/sconfig"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wstore"
+	"github.com/gorilla/mux"
+)
+
+const ApiVersion = "v1"
+const ApiPathPrefix = "/api/" + ApiVersion
+
+// written to the data dir while the api is running, so tools can find the (possibly random) port
+const ApiEndpointFile = "api.json"
+
+const apiReadTimeout = 10 * time.Second
+const apiMaxHeaderBytes = 60000
+const apiMaxBodySize = 1024 * 1024
+
+var apiTokenNameRe = regexp.MustCompile(`^[a-zA-Z0-9_.@-]+$`)
+
+var apiUrlLock = &sync.Mutex{}
+var apiUrl string
+
+type ApiEndpointInfo struct {
+	Url     string `json:"url"`
+	WsUrl   string `json:"wsurl"`
+	Version string `json:"version"`
+	Pid     int    `json:"pid"`
+}
+
+type apiError struct {
+	Status int
+	Err    error
+}
+
+func (e *apiError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *apiError) Unwrap() error {
+	return e.Err
+}
+
+func makeApiError(status int, format string, args ...any) error {
+	return &apiError{Status: status, Err: fmt.Errorf(format, args...)}
+}
+
+type apiTokenCtxKey struct{}
+
+type apiTokenInfo struct {
+	Name   string
+	Scopes []string
+}
+
+func (t *apiTokenInfo) hasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+func getApiToken(ctx context.Context) *apiTokenInfo {
+	tokenInfo, _ := ctx.Value(apiTokenCtxKey{}).(*apiTokenInfo)
+	return tokenInfo
+}
+
+// returns the base url of the api ("" if it is not running)
+func GetApiUrl() string {
+	apiUrlLock.Lock()
+	defer apiUrlLock.Unlock()
+	return apiUrl
+}
+
+func setApiUrl(url string) {
+	apiUrlLock.Lock()
+	defer apiUrlLock.Unlock()
+	apiUrl = url
+}
+
+func getApiSettings() (sconfig.FullConfigType, error) {
+	watcher := sconfig.GetWatcher()
+	if watcher == nil {
+		return sconfig.FullConfigType{}, makeApiError(http.StatusServiceUnavailable, "config is not available")
+	}
+	config := watcher.GetFullConfig()
+	if !config.Settings.ApiEnabled {
+		return config, makeApiError(http.StatusForbidden, "the automation api is disabled (set api:enabled to enable it)")
+	}
+	return config, nil
+}
+
+// the token comes from the "Authorization: Bearer" header, websocket clients that cannot set headers can use the "token" query parameter
+func getRequestToken(r *http.Request, allowQuery bool) string {
+	authHeader := r.Header.Get("Authorization")
+	if token, found := strings.CutPrefix(authHeader, "Bearer "); found {
+		return strings.TrimSpace(token)
+	}
+	if allowQuery {
+		return r.URL.Query().Get("token")
+	}
+	return ""
+}
+
+func validateApiToken(token string) (*apiTokenInfo, error) {
+	config, err := getApiSettings()
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, makeApiError(http.StatusUnauthorized, "no api token (use an Authorization: Bearer header)")
+	}
+	tokenHash := []byte(authkey.HashApiToken(token))
+	for name, apiToken := range config.ApiTokens {
+		if subtle.ConstantTimeCompare(tokenHash, []byte(apiToken.TokenHash)) == 1 {
+			return &apiTokenInfo{Name: name, Scopes: apiToken.Scopes}, nil
+		}
+	}
+	return nil, makeApiError(http.StatusUnauthorized, "invalid api token")
+}
+
+func writeApiJson(w http.ResponseWriter, status int, data any) {
+	barr, err := json.Marshal(data)
+	if err != nil {
+		writeApiError(w, fmt.Errorf("error marshalling response: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(barr)
+}
+
+func writeApiError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var aerr *apiError
+	if errors.As(err, &aerr) {
+		status = aerr.Status
+	} else if errors.Is(err, wstore.ErrNotFound) {
+		status = http.StatusNotFound
+	}
+	barr, _ := json.Marshal(map[string]string{"error": err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(barr)
+}
+
+func readApiJson(r *http.Request, data any) error {
+	barr, err := io.ReadAll(io.LimitReader(r.Body, apiMaxBodySize+1))
+	if err != nil {
+		return makeApiError(http.StatusBadRequest, "error reading request body: %v", err)
+	}
+	if len(barr) > apiMaxBodySize {
+		return makeApiError(http.StatusRequestEntityTooLarge, "request body is too large")
+	}
+	if len(barr) == 0 {
+		return nil
+	}
+	err = json.Unmarshal(barr, data)
+	if err != nil {
+		return makeApiError(http.StatusBadRequest, "invalid json in request body: %v", err)
+	}
+	return nil
+}
+
+type apiFnType = func(w http.ResponseWriter, r *http.Request) error
+
+// checks the token and its scope (empty scope = any valid token) before calling fn, errors are written as {"error": ...}
+func apiFnWrap(scope string, fn apiFnType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recErr := panichandler.PanicHandler("webapi", recover())
+			if recErr != nil {
+				writeApiError(w, recErr)
+			}
+		}()
+		w.Header().Set("Cache-Control", "no-cache")
+		tokenInfo, err := validateApiToken(getRequestToken(r, false))
+		if err != nil {
+			writeApiError(w, err)
+			return
+		}
+		if scope != "" && !tokenInfo.hasScope(scope) {
+			writeApiError(w, makeApiError(http.StatusForbidden, "api token %q does not have the %q scope", tokenInfo.Name, scope))
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), apiTokenCtxKey{}, tokenInfo))
+		err = fn(w, r)
+		if err != nil {
+			writeApiError(w, err)
+		}
+	}
+}
+
+// returns the listener for the api, or nil if the api is disabled (api:enabled), api:port sets the port (random if unset)
+func MakeApiListener() (net.Listener, error) {
+	watcher := sconfig.GetWatcher()
+	if watcher == nil {
+		return nil, nil
+	}
+	settings := watcher.GetFullConfig().Settings
+	if !settings.ApiEnabled {
+		return nil, nil
+	}
+	serverAddr := fmt.Sprintf("127.0.0.1:%d", settings.ApiPort)
+	rtn, err := net.Listen("tcp", serverAddr)
+	if err != nil {
+		return nil, fmt.Errorf("error creating listener at %v: %v", serverAddr, err)
+	}
+	log.Printf("Server [api] listening on %s\n", rtn.Addr())
+	return rtn, nil
+}
+
+func writeApiEndpointFile(addr net.Addr) error {
+	info := ApiEndpointInfo{
+		Url:     fmt.Sprintf("http://%s%s", addr, ApiPathPrefix),
+		WsUrl:   fmt.Sprintf("ws://%s%s/ws", addr, ApiPathPrefix),
+		Version: ApiVersion,
+		Pid:     os.Getpid(),
+	}
+	barr, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	setApiUrl(info.Url)
+	return os.WriteFile(filepath.Join(starbase.GetStarDataDir(), ApiEndpointFile), barr, 0600)
+}
+
+func makeApiRouter() *mux.Router {
+	gr := mux.NewRouter()
+	api := gr.PathPrefix(ApiPathPrefix).Subrouter()
+	api.HandleFunc("/info", apiFnWrap("", handleInfo)).Methods("GET")
+	api.HandleFunc("/workspaces", apiFnWrap(wshrpc.ApiScope_Read, handleListWorkspaces)).Methods("GET")
+	api.HandleFunc("/workspaces", apiFnWrap(wshrpc.ApiScope_Write, handleCreateWorkspace)).Methods("POST")
+	api.HandleFunc("/workspaces/{workspaceid}", apiFnWrap(wshrpc.ApiScope_Read, handleGetWorkspace)).Methods("GET")
+	api.HandleFunc("/workspaces/{workspaceid}", apiFnWrap(wshrpc.ApiScope_Write, handleUpdateWorkspace)).Methods("PATCH")
+	api.HandleFunc("/workspaces/{workspaceid}", apiFnWrap(wshrpc.ApiScope_Write, handleDeleteWorkspace)).Methods("DELETE")
+	api.HandleFunc("/workspaces/{workspaceid}/tabs", apiFnWrap(wshrpc.ApiScope_Read, handleListTabs)).Methods("GET")
+	api.HandleFunc("/workspaces/{workspaceid}/tabs", apiFnWrap(wshrpc.ApiScope_Write, handleCreateTab)).Methods("POST")
+	api.HandleFunc("/tabs/{tabid}", apiFnWrap(wshrpc.ApiScope_Read, handleGetTab)).Methods("GET")
+	api.HandleFunc("/tabs/{tabid}", apiFnWrap(wshrpc.ApiScope_Write, handleUpdateTab)).Methods("PATCH")
+	api.HandleFunc("/tabs/{tabid}", apiFnWrap(wshrpc.ApiScope_Write, handleDeleteTab)).Methods("DELETE")
+	api.HandleFunc("/tabs/{tabid}/blocks", apiFnWrap(wshrpc.ApiScope_Read, handleListBlocks)).Methods("GET")
+	api.HandleFunc("/tabs/{tabid}/blocks", apiFnWrap(wshrpc.ApiScope_Write, handleCreateBlock)).Methods("POST")
+	api.HandleFunc("/blocks/{blockid}", apiFnWrap(wshrpc.ApiScope_Read, handleGetBlock)).Methods("GET")
+	api.HandleFunc("/blocks/{blockid}", apiFnWrap(wshrpc.ApiScope_Write, handleUpdateBlock)).Methods("PATCH")
+	api.HandleFunc("/blocks/{blockid}", apiFnWrap(wshrpc.ApiScope_Write, handleDeleteBlock)).Methods("DELETE")
+	api.HandleFunc("/blocks/{blockid}/input", apiFnWrap(wshrpc.ApiScope_Input, handleBlockInput)).Methods("POST")
+	api.HandleFunc("/blocks/{blockid}/output", apiFnWrap(wshrpc.ApiScope_Output, handleBlockOutput)).Methods("GET")
+	api.HandleFunc("/metrics", apiFnWrap(wshrpc.ApiScope_Metrics, handleRpcMetrics)).Methods("GET")
+	// scopes are checked per message
+	api.HandleFunc("/ws", handleApiWs).Methods("GET")
+	gr.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeApiError(w, makeApiError(http.StatusNotFound, "no such endpoint: %s %s", r.Method, r.URL.Path))
+	})
+	gr.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeApiError(w, makeApiError(http.StatusMethodNotAllowed, "method %s not allowed for %s", r.Method, r.URL.Path))
+	})
+	return gr
+}
+
+// blocking
+func RunApiServer(listener net.Listener) {
+	err := writeApiEndpointFile(listener.Addr())
+	if err != nil {
+		log.Printf("[api] error writing %s: %v\n", ApiEndpointFile, err)
+	}
+	server := &http.Server{
+		ReadTimeout:    apiReadTimeout,
+		MaxHeaderBytes: apiMaxHeaderBytes,
+		Handler:        makeApiRouter(),
+	}
+	err = server.Serve(listener)
+	if err != nil {
+		log.Printf("[api] error running api server: %v\n", err)
+	}
+	setApiUrl("")
+}
+
+// creates (or replaces) the api token with the given name, the returned token is not stored anywhere
+func CreateApiToken(data wshrpc.CommandApiTokenCreateData) (*wshrpc.CommandApiTokenCreateRtnData, error) {
+	if !apiTokenNameRe.MatchString(data.Name) {
+		return nil, fmt.Errorf("invalid api token name %q (use letters, digits, '_', '.', '@' and '-')", data.Name)
+	}
+	if len(data.Scopes) == 0 {
+		return nil, fmt.Errorf("api token needs at least one scope (%s)", strings.Join(wshrpc.AllApiScopes, ", "))
+	}
+	var scopes []string
+	for _, scope := range data.Scopes {
+		if !slices.Contains(wshrpc.AllApiScopes, scope) {
+			return nil, fmt.Errorf("invalid api scope %q (valid scopes are %s)", scope, strings.Join(wshrpc.AllApiScopes, ", "))
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	token, tokenHash, err := authkey.MakeApiToken()
+	if err != nil {
+		return nil, err
+	}
+	err = sconfig.SetApiToken(data.Name, &sconfig.ApiTokenType{TokenHash: tokenHash, Scopes: scopes, CreatedTs: time.Now().UnixMilli()})
+	if err != nil {
+		return nil, fmt.Errorf("error saving api token: %w", err)
+	}
+	return &wshrpc.CommandApiTokenCreateRtnData{Name: data.Name, Token: token, Url: GetApiUrl()}, nil
+}
@@ -0,0 +1,305 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package webapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/authkey"
+	"github.com/commandlinedev/starterm/pkg/sconfig"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/wps"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	testReadToken   = "testtoken-read"
+	testWriteToken  = "testtoken-write"
+	testEventsToken = "testtoken-events"
+	testAllToken    = "testtoken-all"
+)
+
+var testConfigOnce sync.Once
+
+// writes a fixture config (api enabled, one token per scope set) and loads it into the config watcher.
+// the watcher is a singleton, so this is only done once for all tests.
+func initTestConfig(t *testing.T) {
+	testConfigOnce.Do(func() {
+		configDir, err := os.MkdirTemp("", "webapi-test-config")
+		if err != nil {
+			t.Fatalf("error creating config dir: %v", err)
+		}
+		settings := map[string]any{"api:enabled": true, "debug:rpcmetrics": true}
+		apiTokens := map[string]sconfig.ApiTokenType{
+			"reader": {TokenHash: authkey.HashApiToken(testReadToken), Scopes: []string{wshrpc.ApiScope_Read}},
+			"writer": {TokenHash: authkey.HashApiToken(testWriteToken), Scopes: []string{wshrpc.ApiScope_Read, wshrpc.ApiScope_Write}},
+			"events": {TokenHash: authkey.HashApiToken(testEventsToken), Scopes: []string{wshrpc.ApiScope_Events}},
+			"all":    {TokenHash: authkey.HashApiToken(testAllToken), Scopes: wshrpc.AllApiScopes},
+		}
+		writeTestJson(t, filepath.Join(configDir, "settings.json"), settings)
+		writeTestJson(t, filepath.Join(configDir, sconfig.ApiTokensFile), apiTokens)
+		starbase.ConfigHome_VarCache = configDir
+		sconfig.GetWatcher().Start()
+	})
+}
+
+func writeTestJson(t *testing.T, fileName string, data any) {
+	barr, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("error marshalling %s: %v", fileName, err)
+	}
+	err = os.WriteFile(fileName, barr, 0600)
+	if err != nil {
+		t.Fatalf("error writing %s: %v", fileName, err)
+	}
+}
+
+func TestValidateApiToken(t *testing.T) {
+	initTestConfig(t)
+	tests := []struct {
+		name     string
+		token    string
+		wantName string
+		status   int
+	}{
+		{"no token", "", "", http.StatusUnauthorized},
+		{"invalid token", "testtoken-nope", "", http.StatusUnauthorized},
+		{"token prefix", testReadToken[:len(testReadToken)-1], "", http.StatusUnauthorized},
+		{"reader", testReadToken, "reader", 0},
+		{"all", testAllToken, "all", 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenInfo, err := validateApiToken(tc.token)
+			if tc.status != 0 {
+				var aerr *apiError
+				if !errors.As(err, &aerr) || aerr.Status != tc.status {
+					t.Fatalf("got error %v, want status %d", err, tc.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tokenInfo.Name != tc.wantName {
+				t.Fatalf("got token %q, want %q", tokenInfo.Name, tc.wantName)
+			}
+		})
+	}
+}
+
+func TestApiFnWrapScope(t *testing.T) {
+	initTestConfig(t)
+	tests := []struct {
+		name   string
+		scope  string
+		token  string
+		status int
+	}{
+		{"no token", wshrpc.ApiScope_Read, "", http.StatusUnauthorized},
+		{"invalid token", wshrpc.ApiScope_Read, "testtoken-nope", http.StatusUnauthorized},
+		{"has scope", wshrpc.ApiScope_Read, testReadToken, http.StatusOK},
+		{"missing scope", wshrpc.ApiScope_Write, testReadToken, http.StatusForbidden},
+		{"missing output scope", wshrpc.ApiScope_Output, testEventsToken, http.StatusForbidden},
+		{"any scope", "", testEventsToken, http.StatusOK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			handler := apiFnWrap(tc.scope, func(w http.ResponseWriter, r *http.Request) error {
+				called = true
+				if getApiToken(r.Context()) == nil {
+					t.Errorf("no token info in the request context")
+				}
+				w.WriteHeader(http.StatusOK)
+				return nil
+			})
+			req := httptest.NewRequest(http.MethodGet, ApiPathPrefix+"/info", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != tc.status {
+				t.Fatalf("got status %d, want %d (body %s)", rec.Code, tc.status, rec.Body.String())
+			}
+			if called != (tc.status == http.StatusOK) {
+				t.Fatalf("handler called = %v with status %d", called, rec.Code)
+			}
+		})
+	}
+}
+
+func TestApiRpcMetrics(t *testing.T) {
+	initTestConfig(t)
+	router := makeApiRouter()
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"missing scope", testReadToken, http.StatusForbidden},
+		{"metrics scope", testAllToken, http.StatusOK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, ApiPathPrefix+"/metrics", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tc.status {
+				t.Fatalf("got status %d, want %d (body %s)", rec.Code, tc.status, rec.Body.String())
+			}
+			if tc.status == http.StatusOK && !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+				t.Fatalf("got content type %q, want the prometheus text format", rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func dialTestWs(t *testing.T, server *httptest.Server, token string) *websocket.Conn {
+	wsUrl := "ws" + strings.TrimPrefix(server.URL, "http") + ApiPathPrefix + "/ws"
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	conn, resp, err := websocket.DefaultDialer.Dial(wsUrl, header)
+	if err != nil {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		t.Fatalf("error dialing websocket (status %d): %v", status, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func wsRoundTrip(t *testing.T, conn *websocket.Conn, msg ApiWsClientMessage) ApiWsServerMessage {
+	err := conn.WriteJSON(msg)
+	if err != nil {
+		t.Fatalf("error writing message: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var rtn ApiWsServerMessage
+	err = conn.ReadJSON(&rtn)
+	if err != nil {
+		t.Fatalf("error reading reply: %v", err)
+	}
+	if rtn.ReqId != msg.ReqId {
+		t.Fatalf("got reply for reqid %q, want %q", rtn.ReqId, msg.ReqId)
+	}
+	return rtn
+}
+
+func TestApiWsSubscribeScopes(t *testing.T) {
+	initTestConfig(t)
+	server := httptest.NewServer(makeApiRouter())
+	defer server.Close()
+
+	t.Run("invalid token", func(t *testing.T) {
+		wsUrl := "ws" + strings.TrimPrefix(server.URL, "http") + ApiPathPrefix + "/ws?token=testtoken-nope"
+		_, resp, err := websocket.DefaultDialer.Dial(wsUrl, nil)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected a 401, got resp %v err %v", resp, err)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		token   string
+		event   string
+		allowed bool
+	}{
+		{"events", testEventsToken, wps.Event_ControllerStatus, true},
+		{"blockfile without output", testEventsToken, wps.Event_BlockFile, false},
+		{"blockfile with output", testAllToken, wps.Event_BlockFile, true},
+		{"no events scope", testReadToken, wps.Event_ControllerStatus, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			conn := dialTestWs(t, server, tc.token)
+			reply := wsRoundTrip(t, conn, ApiWsClientMessage{Type: ApiWsMsg_Subscribe, ReqId: "1", Event: tc.event, AllScopes: true})
+			if tc.allowed && reply.Type != ApiWsMsg_Ack {
+				t.Fatalf("expected ack, got %q (%s)", reply.Type, reply.Error)
+			}
+			if !tc.allowed && reply.Type != ApiWsMsg_Error {
+				t.Fatalf("expected error, got %q", reply.Type)
+			}
+			// the connection stays usable after a denied subscribe
+			reply = wsRoundTrip(t, conn, ApiWsClientMessage{Type: ApiWsMsg_Ping, ReqId: "2"})
+			if reply.Type != ApiWsMsg_Pong {
+				t.Fatalf("expected pong, got %q", reply.Type)
+			}
+		})
+	}
+}
+
+func TestApiExecMetaScope(t *testing.T) {
+	initTestConfig(t)
+	tests := []struct {
+		name   string
+		token  string
+		meta   starobj.MetaMapType
+		status int // the status of the scope check, 0 if it passes
+	}{
+		{"write view", testWriteToken, starobj.MetaMapType{"view": "preview", "file": "~"}, 0},
+		{"write controller", testWriteToken, starobj.MetaMapType{"view": "term", "controller": "shell"}, http.StatusForbidden},
+		{"write cmd", testWriteToken, starobj.MetaMapType{"view": "term", "cmd": "rm -rf ~"}, http.StatusForbidden},
+		{"write cmd args", testWriteToken, starobj.MetaMapType{"view": "term", "cmd:args": []string{"-c", "id"}}, http.StatusForbidden},
+		{"write connection", testWriteToken, starobj.MetaMapType{"view": "term", "connection": "user@host"}, http.StatusForbidden},
+		{"write shell path", testWriteToken, starobj.MetaMapType{"view": "term", "term:localshellpath": "/bin/sh"}, http.StatusForbidden},
+		{"input cmd", testAllToken, starobj.MetaMapType{"view": "term", "controller": "cmd", "cmd": "ls"}, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenInfo, err := validateApiToken(tc.token)
+			if err != nil {
+				t.Fatalf("error validating token: %v", err)
+			}
+			ctx := context.WithValue(context.Background(), apiTokenCtxKey{}, tokenInfo)
+			err = checkExecMeta(ctx, tc.meta)
+			var apiErr *apiError
+			if tc.status == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &apiErr) || apiErr.Status != tc.status {
+				t.Fatalf("got error %v, want status %d", err, tc.status)
+			}
+		})
+	}
+}
+
+func TestApiBlockHandlersRejectExecMeta(t *testing.T) {
+	initTestConfig(t)
+	handlers := map[string]apiFnType{"create": handleCreateBlock, "update": handleUpdateBlock}
+	for name, fn := range handlers {
+		t.Run(name, func(t *testing.T) {
+			body := `{"meta": {"view": "term", "controller": "cmd", "cmd": "touch /tmp/pwned"}}`
+			req := httptest.NewRequest(http.MethodPost, ApiPathPrefix+"/tabs/tabid/blocks", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+testWriteToken)
+			rec := httptest.NewRecorder()
+			apiFnWrap(wshrpc.ApiScope_Write, fn)(rec, req)
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("got status %d, want %d (body %s)", rec.Code, http.StatusForbidden, rec.Body.String())
+			}
+		})
+	}
+}
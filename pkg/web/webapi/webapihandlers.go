@@ -0,0 +1,635 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package webapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/blockcontroller"
+	"github.com/commandlinedev/starterm/pkg/filestore"
+	"github.com/commandlinedev/starterm/pkg/sconfig"
+	"github.com/commandlinedev/starterm/pkg/service/workspaceservice"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/wps"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
+	"github.com/commandlinedev/starterm/pkg/wshutil"
+	"github.com/commandlinedev/starterm/pkg/wstore"
+	"github.com/gorilla/mux"
+)
+
+const apiDefaultTimeout = 5 * time.Second
+
+// output reads return at most this much (from the end of the output when no offset is given)
+const apiMaxOutputRead = 1024 * 1024
+
+var workspaceService = &workspaceservice.WorkspaceService{}
+
+type ApiInfoData struct {
+	ApiVersion string   `json:"apiversion"`
+	Version    string   `json:"version"`
+	TokenName  string   `json:"tokenname"`
+	Scopes     []string `json:"scopes"`
+}
+
+type ApiWorkspaceRequest struct {
+	Name  string `json:"name,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+	Color string `json:"color,omitempty"`
+}
+
+type ApiTabRequest struct {
+	Name     string              `json:"name,omitempty"`
+	Pinned   bool                `json:"pinned,omitempty"`
+	Activate bool                `json:"activate,omitempty"`
+	Meta     starobj.MetaMapType `json:"meta,omitempty"`
+}
+
+type ApiBlockRequest struct {
+	Meta          starobj.MetaMapType `json:"meta"`
+	Magnified     bool                `json:"magnified,omitempty"`
+	TargetBlockId string              `json:"targetblockid,omitempty"`
+	TargetAction  string              `json:"targetaction,omitempty"` // "replace", "splitright", "splitdown", "splitleft", "splitup"
+	TermSize      *starobj.TermSize   `json:"termsize,omitempty"`
+}
+
+type ApiInputRequest struct {
+	Text     string            `json:"text,omitempty"`
+	Data64   string            `json:"data64,omitempty"`
+	Signal   string            `json:"signal,omitempty"`
+	TermSize *starobj.TermSize `json:"termsize,omitempty"`
+}
+
+type ApiOutputData struct {
+	BlockId    string `json:"blockid"`
+	Offset     int64  `json:"offset"`     // offset of data in the output (older output is dropped, so this can be bigger than the requested offset)
+	NextOffset int64  `json:"nextoffset"` // offset to read next
+	Size       int64  `json:"size"`       // total size of the output
+	Data64     string `json:"data64"`
+}
+
+func rpcOpts() *wshrpc.RpcOpts {
+	return &wshrpc.RpcOpts{Timeout: int64(apiDefaultTimeout / time.Millisecond)}
+}
+
+func handleInfo(w http.ResponseWriter, r *http.Request) error {
+	tokenInfo := getApiToken(r.Context())
+	writeApiJson(w, http.StatusOK, ApiInfoData{
+		ApiVersion: ApiVersion,
+		Version:    starbase.StarVersion,
+		TokenName:  tokenInfo.Name,
+		Scopes:     tokenInfo.Scopes,
+	})
+	return nil
+}
+
+// rpc metrics in the prometheus text format, only served when the debug:rpcmetrics setting is on
+func handleRpcMetrics(w http.ResponseWriter, r *http.Request) error {
+	watcher := sconfig.GetWatcher()
+	if watcher == nil || !watcher.GetFullConfig().Settings.DebugRpcMetrics {
+		return makeApiError(http.StatusNotFound, "rpc metrics are disabled (set debug:rpcmetrics to enable them)")
+	}
+	stats := wshutil.GetRpcStats(wshutil.DefaultRoute, wshrpc.CommandRpcStatsData{})
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	wshutil.WriteRpcStatsPrometheus(w, stats)
+	return nil
+}
+
+func getWorkspaceInfo(ctx context.Context, workspaceId string) (*wshrpc.WorkspaceInfoData, error) {
+	workspace, err := wstore.DBMustGet[*starobj.Workspace](ctx, workspaceId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting workspace %q: %w", workspaceId, err)
+	}
+	windowId, err := wstore.DBFindWindowForWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return nil, fmt.Errorf("error finding window for workspace %q: %w", workspaceId, err)
+	}
+	return &wshrpc.WorkspaceInfoData{WindowId: windowId, WorkspaceData: workspace}, nil
+}
+
+// unlike score.ListWorkspaces this includes the unnamed workspaces of open windows
+func handleListWorkspaces(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	workspaces, err := wstore.DBGetAllObjsByType[*starobj.Workspace](ctx, starobj.OType_Workspace)
+	if err != nil {
+		return fmt.Errorf("error listing workspaces: %w", err)
+	}
+	windows, err := wstore.DBGetAllObjsByType[*starobj.Window](ctx, starobj.OType_Window)
+	if err != nil {
+		return fmt.Errorf("error listing windows: %w", err)
+	}
+	workspaceToWindow := make(map[string]string)
+	for _, window := range windows {
+		workspaceToWindow[window.WorkspaceId] = window.OID
+	}
+	rtn := make([]wshrpc.WorkspaceInfoData, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		rtn = append(rtn, wshrpc.WorkspaceInfoData{WindowId: workspaceToWindow[workspace.OID], WorkspaceData: workspace})
+	}
+	writeApiJson(w, http.StatusOK, rtn)
+	return nil
+}
+
+func handleCreateWorkspace(w http.ResponseWriter, r *http.Request) error {
+	var req ApiWorkspaceRequest
+	if err := readApiJson(r, &req); err != nil {
+		return err
+	}
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	workspaceId, err := workspaceService.CreateWorkspace(ctx, req.Name, req.Icon, req.Color, true)
+	if err != nil {
+		return err
+	}
+	info, err := getWorkspaceInfo(ctx, workspaceId)
+	if err != nil {
+		return err
+	}
+	writeApiJson(w, http.StatusCreated, info)
+	return nil
+}
+
+func handleGetWorkspace(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	info, err := getWorkspaceInfo(ctx, mux.Vars(r)["workspaceid"])
+	if err != nil {
+		return err
+	}
+	writeApiJson(w, http.StatusOK, info)
+	return nil
+}
+
+func handleUpdateWorkspace(w http.ResponseWriter, r *http.Request) error {
+	var req ApiWorkspaceRequest
+	if err := readApiJson(r, &req); err != nil {
+		return err
+	}
+	workspaceId := mux.Vars(r)["workspaceid"]
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	if _, err := getWorkspaceInfo(ctx, workspaceId); err != nil {
+		return err
+	}
+	_, err := workspaceService.UpdateWorkspace(ctx, workspaceId, req.Name, req.Icon, req.Color, false)
+	if err != nil {
+		return err
+	}
+	info, err := getWorkspaceInfo(ctx, workspaceId)
+	if err != nil {
+		return err
+	}
+	writeApiJson(w, http.StatusOK, info)
+	return nil
+}
+
+// workspaces open in a window cannot be deleted (the window would have to switch to another workspace)
+func handleDeleteWorkspace(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	info, err := getWorkspaceInfo(ctx, mux.Vars(r)["workspaceid"])
+	if err != nil {
+		return err
+	}
+	if info.WindowId != "" {
+		return makeApiError(http.StatusConflict, "workspace %q is open in a window", info.WorkspaceData.OID)
+	}
+	_, _, err = workspaceService.DeleteWorkspace(info.WorkspaceData.OID)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func getTabs(ctx context.Context, tabIds []string) ([]*starobj.Tab, error) {
+	tabMap, err := wstore.DBSelectMap[*starobj.Tab](ctx, tabIds)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tabs: %w", err)
+	}
+	rtn := make([]*starobj.Tab, 0, len(tabIds))
+	for _, tabId := range tabIds {
+		if tab := tabMap[tabId]; tab != nil {
+			rtn = append(rtn, tab)
+		}
+	}
+	return rtn, nil
+}
+
+// pinned tabs first (like in the tab bar)
+func handleListTabs(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	info, err := getWorkspaceInfo(ctx, mux.Vars(r)["workspaceid"])
+	if err != nil {
+		return err
+	}
+	tabs, err := getTabs(ctx, slices.Concat(info.WorkspaceData.PinnedTabIds, info.WorkspaceData.TabIds))
+	if err != nil {
+		return err
+	}
+	writeApiJson(w, http.StatusOK, tabs)
+	return nil
+}
+
+func updateTab(ctx context.Context, tabId string, req ApiTabRequest) error {
+	ctx = starobj.ContextWithUpdates(ctx)
+	if req.Name != "" {
+		err := wstore.UpdateTabName(ctx, tabId, req.Name)
+		if err != nil {
+			return fmt.Errorf("error updating tab name: %w", err)
+		}
+	}
+	if len(req.Meta) > 0 {
+		err := wstore.UpdateObjectMeta(ctx, starobj.MakeORef(starobj.OType_Tab, tabId), req.Meta, false)
+		if err != nil {
+			return fmt.Errorf("error updating tab meta: %w", err)
+		}
+	}
+	wps.Broker.SendUpdateEvents(starobj.ContextGetUpdatesRtn(ctx))
+	return nil
+}
+
+func handleCreateTab(w http.ResponseWriter, r *http.Request) error {
+	var req ApiTabRequest
+	if err := readApiJson(r, &req); err != nil {
+		return err
+	}
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	info, err := getWorkspaceInfo(ctx, mux.Vars(r)["workspaceid"])
+	if err != nil {
+		return err
+	}
+	tabId, _, err := workspaceService.CreateTab(info.WorkspaceData.OID, req.Name, req.Activate, req.Pinned)
+	if err != nil {
+		return err
+	}
+	if len(req.Meta) > 0 {
+		err = updateTab(ctx, tabId, ApiTabRequest{Meta: req.Meta})
+		if err != nil {
+			return err
+		}
+	}
+	tab, err := wstore.DBMustGet[*starobj.Tab](ctx, tabId)
+	if err != nil {
+		return fmt.Errorf("error getting tab: %w", err)
+	}
+	writeApiJson(w, http.StatusCreated, tab)
+	return nil
+}
+
+func handleGetTab(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	tab, err := wstore.DBMustGet[*starobj.Tab](ctx, mux.Vars(r)["tabid"])
+	if err != nil {
+		return fmt.Errorf("error getting tab: %w", err)
+	}
+	writeApiJson(w, http.StatusOK, tab)
+	return nil
+}
+
+func handleUpdateTab(w http.ResponseWriter, r *http.Request) error {
+	var req ApiTabRequest
+	if err := readApiJson(r, &req); err != nil {
+		return err
+	}
+	tabId := mux.Vars(r)["tabid"]
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	if _, err := wstore.DBMustGet[*starobj.Tab](ctx, tabId); err != nil {
+		return fmt.Errorf("error getting tab: %w", err)
+	}
+	if err := updateTab(ctx, tabId, req); err != nil {
+		return err
+	}
+	tab, err := wstore.DBMustGet[*starobj.Tab](ctx, tabId)
+	if err != nil {
+		return fmt.Errorf("error getting tab: %w", err)
+	}
+	writeApiJson(w, http.StatusOK, tab)
+	return nil
+}
+
+// the last tab of a workspace that is open in a window cannot be closed (the window would be closed)
+func handleDeleteTab(w http.ResponseWriter, r *http.Request) error {
+	tabId := mux.Vars(r)["tabid"]
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	if _, err := wstore.DBMustGet[*starobj.Tab](ctx, tabId); err != nil {
+		return fmt.Errorf("error getting tab: %w", err)
+	}
+	workspaceId, err := wstore.DBFindWorkspaceForTabId(ctx, tabId)
+	if err != nil {
+		return fmt.Errorf("error finding workspace for tab: %w", err)
+	}
+	info, err := getWorkspaceInfo(ctx, workspaceId)
+	if err != nil {
+		return err
+	}
+	if info.WindowId != "" && len(info.WorkspaceData.TabIds)+len(info.WorkspaceData.PinnedTabIds) <= 1 {
+		return makeApiError(http.StatusConflict, "tab %q is the last tab of a workspace that is open in a window", tabId)
+	}
+	_, _, err = workspaceService.CloseTab(ctx, workspaceId, tabId, false)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func handleListBlocks(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	tab, err := wstore.DBMustGet[*starobj.Tab](ctx, mux.Vars(r)["tabid"])
+	if err != nil {
+		return fmt.Errorf("error getting tab: %w", err)
+	}
+	blockMap, err := wstore.DBSelectMap[*starobj.Block](ctx, tab.BlockIds)
+	if err != nil {
+		return fmt.Errorf("error getting blocks: %w", err)
+	}
+	rtn := make([]*starobj.Block, 0, len(tab.BlockIds))
+	for _, blockId := range tab.BlockIds {
+		if block := blockMap[blockId]; block != nil {
+			rtn = append(rtn, block)
+		}
+	}
+	writeApiJson(w, http.StatusOK, rtn)
+	return nil
+}
+
+// meta keys that choose what a block controller runs (and where), setting them needs the input scope as well as write
+var apiExecMetaKeys = []string{
+	starobj.MetaKey_Controller,
+	starobj.MetaKey_Connection,
+	starobj.MetaKey_Cmd,
+	starobj.MetaKey_TermLocalShellPath,
+	starobj.MetaKey_TermLocalShellOpts,
+}
+
+func isApiExecMetaKey(key string) bool {
+	return slices.Contains(apiExecMetaKeys, key) || strings.HasPrefix(key, starobj.MetaKey_Cmd+":")
+}
+
+// api-created blocks are started right away, so with these keys write alone would run arbitrary commands
+func checkExecMeta(ctx context.Context, meta starobj.MetaMapType) error {
+	tokenInfo := getApiToken(ctx)
+	if tokenInfo != nil && tokenInfo.hasScope(wshrpc.ApiScope_Input) {
+		return nil
+	}
+	for key := range meta {
+		if isApiExecMetaKey(key) {
+			return makeApiError(http.StatusForbidden, "setting the %q meta key needs the %q scope", key, wshrpc.ApiScope_Input)
+		}
+	}
+	return nil
+}
+
+// block controllers are normally started when the frontend shows the block,
+// blocks driven by the api may not be shown (yet), so they are started here
+func ensureBlockController(ctx context.Context, tabId string, block *starobj.Block, termSize *starobj.TermSize) error {
+	if block.Meta.GetString(starobj.MetaKey_Controller, "") == "" || blockcontroller.GetBlockController(block.OID) != nil {
+		return nil
+	}
+	if tabId == "" {
+		var err error
+		tabId, err = wstore.DBFindTabForBlockId(ctx, block.OID)
+		if err != nil {
+			return fmt.Errorf("error finding tab for block: %w", err)
+		}
+	}
+	data := wshrpc.CommandControllerResyncData{TabId: tabId, BlockId: block.OID}
+	if termSize != nil {
+		data.RtOpts = &starobj.RuntimeOpts{TermSize: *termSize}
+	}
+	err := wshclient.ControllerResyncCommand(wshclient.GetBareRpcClient(), data, rpcOpts())
+	if err != nil {
+		return fmt.Errorf("error starting block controller: %w", err)
+	}
+	return nil
+}
+
+func handleCreateBlock(w http.ResponseWriter, r *http.Request) error {
+	var req ApiBlockRequest
+	if err := readApiJson(r, &req); err != nil {
+		return err
+	}
+	if req.Meta.GetString(starobj.MetaKey_View, "") == "" {
+		return makeApiError(http.StatusBadRequest, "block meta needs a %q key", starobj.MetaKey_View)
+	}
+	if err := checkExecMeta(r.Context(), req.Meta); err != nil {
+		return err
+	}
+	tabId := mux.Vars(r)["tabid"]
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	if _, err := wstore.DBMustGet[*starobj.Tab](ctx, tabId); err != nil {
+		return fmt.Errorf("error getting tab: %w", err)
+	}
+	data := wshrpc.CommandCreateBlockData{
+		TabId:         tabId,
+		BlockDef:      &starobj.BlockDef{Meta: req.Meta},
+		Magnified:     req.Magnified,
+		TargetBlockId: req.TargetBlockId,
+		TargetAction:  req.TargetAction,
+	}
+	if req.TermSize != nil {
+		data.RtOpts = &starobj.RuntimeOpts{TermSize: *req.TermSize}
+	}
+	blockRef, err := wshclient.CreateBlockCommand(wshclient.GetBareRpcClient(), data, rpcOpts())
+	if err != nil {
+		return makeApiError(http.StatusBadRequest, "error creating block: %w", err)
+	}
+	block, err := wstore.DBMustGet[*starobj.Block](ctx, blockRef.OID)
+	if err != nil {
+		return fmt.Errorf("error getting block: %w", err)
+	}
+	err = ensureBlockController(ctx, tabId, block, req.TermSize)
+	if err != nil {
+		return err
+	}
+	writeApiJson(w, http.StatusCreated, block)
+	return nil
+}
+
+func handleGetBlock(w http.ResponseWriter, r *http.Request) error {
+	blockId := mux.Vars(r)["blockid"]
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	if _, err := wstore.DBMustGet[*starobj.Block](ctx, blockId); err != nil {
+		return fmt.Errorf("error getting block: %w", err)
+	}
+	blockInfo, err := wshclient.BlockInfoCommand(wshclient.GetBareRpcClient(), blockId, rpcOpts())
+	if err != nil {
+		return err
+	}
+	writeApiJson(w, http.StatusOK, blockInfo)
+	return nil
+}
+
+func handleUpdateBlock(w http.ResponseWriter, r *http.Request) error {
+	var req ApiBlockRequest
+	if err := readApiJson(r, &req); err != nil {
+		return err
+	}
+	if err := checkExecMeta(r.Context(), req.Meta); err != nil {
+		return err
+	}
+	blockId := mux.Vars(r)["blockid"]
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	if _, err := wstore.DBMustGet[*starobj.Block](ctx, blockId); err != nil {
+		return fmt.Errorf("error getting block: %w", err)
+	}
+	if len(req.Meta) > 0 {
+		data := wshrpc.CommandSetMetaData{ORef: starobj.MakeORef(starobj.OType_Block, blockId), Meta: req.Meta}
+		err := wshclient.SetMetaCommand(wshclient.GetBareRpcClient(), data, rpcOpts())
+		if err != nil {
+			return makeApiError(http.StatusBadRequest, "error updating block meta: %w", err)
+		}
+	}
+	block, err := wstore.DBMustGet[*starobj.Block](ctx, blockId)
+	if err != nil {
+		return fmt.Errorf("error getting block: %w", err)
+	}
+	writeApiJson(w, http.StatusOK, block)
+	return nil
+}
+
+func handleDeleteBlock(w http.ResponseWriter, r *http.Request) error {
+	blockId := mux.Vars(r)["blockid"]
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	if _, err := wstore.DBMustGet[*starobj.Block](ctx, blockId); err != nil {
+		return fmt.Errorf("error getting block: %w", err)
+	}
+	err := wshclient.DeleteBlockCommand(wshclient.GetBareRpcClient(), wshrpc.CommandDeleteBlockData{BlockId: blockId}, rpcOpts())
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// sends input (text or base64 bytes), a signal and/or a terminal size to the block's controller (starting it if needed)
+func sendBlockInput(ctx context.Context, blockId string, req ApiInputRequest) error {
+	block, err := wstore.DBMustGet[*starobj.Block](ctx, blockId)
+	if err != nil {
+		return fmt.Errorf("error getting block: %w", err)
+	}
+	if req.Text == "" && req.Data64 == "" && req.Signal == "" && req.TermSize == nil {
+		return makeApiError(http.StatusBadRequest, "no input (set text, data64, signal or termsize)")
+	}
+	if req.Text != "" && req.Data64 != "" {
+		return makeApiError(http.StatusBadRequest, "text and data64 cannot both be set")
+	}
+	data := wshrpc.CommandBlockInputData{BlockId: blockId, SigName: req.Signal, TermSize: req.TermSize}
+	if req.Text != "" {
+		data.InputData64 = base64.StdEncoding.EncodeToString([]byte(req.Text))
+	} else if req.Data64 != "" {
+		if _, err := base64.StdEncoding.DecodeString(req.Data64); err != nil {
+			return makeApiError(http.StatusBadRequest, "invalid data64: %v", err)
+		}
+		data.InputData64 = req.Data64
+	}
+	err = ensureBlockController(ctx, "", block, req.TermSize)
+	if err != nil {
+		return err
+	}
+	err = wshclient.ControllerInputCommand(wshclient.GetBareRpcClient(), data, rpcOpts())
+	if err != nil {
+		return makeApiError(http.StatusConflict, "error sending input: %w", err)
+	}
+	return nil
+}
+
+func handleBlockInput(w http.ResponseWriter, r *http.Request) error {
+	var req ApiInputRequest
+	if err := readApiJson(r, &req); err != nil {
+		return err
+	}
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	err := sendBlockInput(ctx, mux.Vars(r)["blockid"], req)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// reads up to maxSize bytes of terminal output starting at offset (offset < 0 reads the last maxSize bytes)
+func readBlockOutput(ctx context.Context, blockId string, offset int64, maxSize int64) (*ApiOutputData, error) {
+	rtn := &ApiOutputData{BlockId: blockId}
+	file, err := filestore.WFS.Stat(ctx, blockId, starbase.BlockFile_Term)
+	if errors.Is(err, fs.ErrNotExist) {
+		return rtn, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting terminal output: %w", err)
+	}
+	rtn.Size = file.Size
+	if offset < 0 {
+		offset = max(file.Size-maxSize, 0)
+	}
+	if offset >= file.Size {
+		rtn.Offset = file.Size
+		rtn.NextOffset = file.Size
+		return rtn, nil
+	}
+	readOffset, data, err := filestore.WFS.ReadAt(ctx, blockId, starbase.BlockFile_Term, offset, min(file.Size-offset, maxSize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading terminal output: %w", err)
+	}
+	rtn.Offset = readOffset
+	rtn.NextOffset = readOffset + int64(len(data))
+	rtn.Size = max(file.Size, rtn.NextOffset)
+	rtn.Data64 = base64.StdEncoding.EncodeToString(data)
+	return rtn, nil
+}
+
+func handleBlockOutput(w http.ResponseWriter, r *http.Request) error {
+	blockId := mux.Vars(r)["blockid"]
+	offset := int64(-1)
+	maxSize := int64(apiMaxOutputRead)
+	var err error
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		offset, err = strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil || offset < 0 {
+			return makeApiError(http.StatusBadRequest, "invalid offset %q", offsetStr)
+		}
+	}
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		maxSize, err = strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || maxSize <= 0 {
+			return makeApiError(http.StatusBadRequest, "invalid limit %q", limitStr)
+		}
+		maxSize = min(maxSize, apiMaxOutputRead)
+	}
+	ctx, cancelFn := context.WithTimeout(r.Context(), apiDefaultTimeout)
+	defer cancelFn()
+	if _, err := wstore.DBMustGet[*starobj.Block](ctx, blockId); err != nil {
+		return fmt.Errorf("error getting block: %w", err)
+	}
+	output, err := readBlockOutput(ctx, blockId, offset, maxSize)
+	if err != nil {
+		return err
+	}
+	writeApiJson(w, http.StatusOK, output)
+	return nil
+}
@@ -0,0 +1,468 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package webapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+	"github.com/commandlinedev/starterm/pkg/wps"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshutil"
+	"github.com/commandlinedev/starterm/pkg/wstore"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const apiWsReadWaitTimeout = 30 * time.Second
+const apiWsWriteWaitTimeout = 10 * time.Second
+const apiWsPingPeriod = 10 * time.Second
+const apiWsReadLimit = 1024 * 1024
+const apiWsOutputChSize = 256
+const apiWsOutputChunkSize = 64 * 1024
+
+const (
+	ApiWsMsg_Subscribe   = "subscribe"
+	ApiWsMsg_Unsubscribe = "unsubscribe"
+	ApiWsMsg_Output      = "output"
+	ApiWsMsg_StopOutput  = "stopoutput"
+	ApiWsMsg_Input       = "input"
+	ApiWsMsg_Ping        = "ping"
+
+	ApiWsMsg_Ack         = "ack"
+	ApiWsMsg_Error       = "error"
+	ApiWsMsg_Event       = "event"
+	ApiWsMsg_OutputReset = "outputreset"
+	ApiWsMsg_Pong        = "pong"
+)
+
+var apiWsUpgrader = websocket.Upgrader{
+	ReadBufferSize:   4 * 1024,
+	WriteBufferSize:  32 * 1024,
+	HandshakeTimeout: 5 * time.Second,
+	// the api is authenticated by token (not cookies), so any origin is ok
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// messages from the client, fields are used depending on type
+type ApiWsClientMessage struct {
+	Type      string   `json:"type"`
+	ReqId     string   `json:"reqid,omitempty"`
+	Event     string   `json:"event,omitempty"`     // subscribe, unsubscribe
+	Scopes    []string `json:"scopes,omitempty"`    // subscribe
+	AllScopes bool     `json:"allscopes,omitempty"` // subscribe
+	BlockId   string   `json:"blockid,omitempty"`   // output, stopoutput, input
+	Offset    *int64   `json:"offset,omitempty"`    // output (defaults to the current end of the output)
+	ApiInputRequest
+}
+
+type ApiWsServerMessage struct {
+	Type  string         `json:"type"`
+	ReqId string         `json:"reqid,omitempty"`
+	Error string         `json:"error,omitempty"`
+	Event *wps.StarEvent `json:"event,omitempty"`
+	*ApiOutputData
+}
+
+type apiOutputStream struct {
+	blockId  string
+	notifyCh chan struct{}
+	cancelFn context.CancelFunc
+}
+
+// each websocket connection is registered as a route in the wsh router so it can get wps events
+type apiWsConn struct {
+	lock      *sync.Mutex
+	routeId   string
+	conn      *websocket.Conn
+	token     string
+	subs      map[string]wps.SubscriptionRequest // event => client subscription
+	outputs   map[string]*apiOutputStream        // blockid => output stream
+	outputCh  chan ApiWsServerMessage
+	closeCh   chan struct{}
+	closeOnce *sync.Once
+}
+
+func handleApiWs(w http.ResponseWriter, r *http.Request) {
+	token := getRequestToken(r, true)
+	if _, err := validateApiToken(token); err != nil {
+		writeApiError(w, err)
+		return
+	}
+	conn, err := apiWsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// upgrader already wrote the error response
+		log.Printf("[api] websocket upgrade error: %v\n", err)
+		return
+	}
+	c := &apiWsConn{
+		lock:      &sync.Mutex{},
+		routeId:   "api:" + uuid.New().String(),
+		conn:      conn,
+		token:     token,
+		subs:      make(map[string]wps.SubscriptionRequest),
+		outputs:   make(map[string]*apiOutputStream),
+		outputCh:  make(chan ApiWsServerMessage, apiWsOutputChSize),
+		closeCh:   make(chan struct{}),
+		closeOnce: &sync.Once{},
+	}
+	wshutil.DefaultRouter.RegisterRoute(c.routeId, c, false)
+	defer c.close()
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("webapi:writeLoop", recover())
+		}()
+		c.writeLoop()
+	}()
+	c.readLoop()
+}
+
+func (c *apiWsConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.closeCh)
+		c.conn.Close()
+		c.lock.Lock()
+		for _, stream := range c.outputs {
+			stream.cancelFn()
+		}
+		c.outputs = make(map[string]*apiOutputStream)
+		c.lock.Unlock()
+		// also removes all of our wps subscriptions
+		wshutil.DefaultRouter.UnregisterRoute(c.routeId)
+	})
+}
+
+// AbstractRpcClient, the router only sends us events
+func (c *apiWsConn) SendRpcMessage(msg []byte) {
+	var rpcMsg wshutil.RpcMessage
+	err := json.Unmarshal(msg, &rpcMsg)
+	if err != nil || rpcMsg.Command != wshrpc.Command_EventRecv || rpcMsg.Data == nil {
+		return
+	}
+	var event wps.StarEvent
+	err = utilfn.ReUnmarshal(&event, rpcMsg.Data)
+	if err != nil {
+		return
+	}
+	c.dispatchEvent(&event)
+}
+
+// AbstractRpcClient, we never send rpc messages into the router
+func (c *apiWsConn) RecvRpcMessage() ([]byte, bool) {
+	<-c.closeCh
+	return nil, false
+}
+
+// must not block (called from the router)
+func (c *apiWsConn) dispatchEvent(event *wps.StarEvent) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if event.Event == wps.Event_BlockFile && isTermFileEvent(event) {
+		for _, scope := range event.Scopes {
+			oref, err := starobj.ParseORef(scope)
+			if err != nil || oref.OType != starobj.OType_Block {
+				continue
+			}
+			if stream := c.outputs[oref.OID]; stream != nil {
+				select {
+				case stream.notifyCh <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+	sub, ok := c.subs[event.Event]
+	if !ok || !subMatchesEvent(sub, event) {
+		return
+	}
+	select {
+	case c.outputCh <- ApiWsServerMessage{Type: ApiWsMsg_Event, Event: event}:
+	default:
+		// client is not keeping up
+		log.Printf("[api] websocket %s output overflow, closing\n", c.routeId)
+		go c.close()
+	}
+}
+
+func isTermFileEvent(event *wps.StarEvent) bool {
+	var fileData wps.WSFileEventData
+	err := utilfn.ReUnmarshal(&fileData, event.Data)
+	return err == nil && fileData.FileName == starbase.BlockFile_Term
+}
+
+func subMatchesEvent(sub wps.SubscriptionRequest, event *wps.StarEvent) bool {
+	if sub.AllScopes {
+		return true
+	}
+	for _, subScope := range sub.Scopes {
+		for _, scope := range event.Scopes {
+			if utilfn.StarMatchString(subScope, scope, ":") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// the broker subscription is the union of the client's subscription and the blocks we stream output for
+func (c *apiWsConn) updateBrokerSub_nolock(eventName string) {
+	sub, hasSub := c.subs[eventName]
+	if eventName == wps.Event_BlockFile && !sub.AllScopes {
+		sub.Event = eventName
+		sub.Scopes = append([]string{}, sub.Scopes...)
+		for blockId := range c.outputs {
+			sub.Scopes = append(sub.Scopes, starobj.MakeORef(starobj.OType_Block, blockId).String())
+		}
+		hasSub = len(sub.Scopes) > 0
+	}
+	if !hasSub {
+		wps.Broker.Unsubscribe(c.routeId, eventName)
+		return
+	}
+	wps.Broker.Subscribe(c.routeId, sub)
+}
+
+func (c *apiWsConn) send(msg ApiWsServerMessage) {
+	select {
+	case c.outputCh <- msg:
+	case <-c.closeCh:
+	}
+}
+
+func (c *apiWsConn) sendResult(reqId string, err error) {
+	if err != nil {
+		c.send(ApiWsServerMessage{Type: ApiWsMsg_Error, ReqId: reqId, Error: err.Error()})
+		return
+	}
+	if reqId != "" {
+		c.send(ApiWsServerMessage{Type: ApiWsMsg_Ack, ReqId: reqId})
+	}
+}
+
+func (c *apiWsConn) readLoop() {
+	c.conn.SetReadLimit(apiWsReadLimit)
+	c.conn.SetReadDeadline(time.Now().Add(apiWsReadWaitTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(apiWsReadWaitTimeout))
+	})
+	for {
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) && !errors.Is(err, websocket.ErrCloseSent) {
+				log.Printf("[api] websocket %s read error: %v\n", c.routeId, err)
+			}
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(apiWsReadWaitTimeout))
+		var msg ApiWsClientMessage
+		err = json.Unmarshal(message, &msg)
+		if err != nil {
+			c.sendResult("", fmt.Errorf("invalid json message: %v", err))
+			continue
+		}
+		// tokens can be revoked (or api:enabled turned off) while connected
+		tokenInfo, err := validateApiToken(c.token)
+		if err != nil {
+			c.sendResult(msg.ReqId, err)
+			return
+		}
+		c.processMessage(tokenInfo, msg)
+	}
+}
+
+func checkScope(tokenInfo *apiTokenInfo, scope string) error {
+	if !tokenInfo.hasScope(scope) {
+		return fmt.Errorf("api token %q does not have the %q scope", tokenInfo.Name, scope)
+	}
+	return nil
+}
+
+func (c *apiWsConn) processMessage(tokenInfo *apiTokenInfo, msg ApiWsClientMessage) {
+	switch msg.Type {
+	case ApiWsMsg_Ping:
+		c.send(ApiWsServerMessage{Type: ApiWsMsg_Pong, ReqId: msg.ReqId})
+
+	case ApiWsMsg_Subscribe:
+		err := checkScope(tokenInfo, wshrpc.ApiScope_Events)
+		if err == nil && msg.Event == wps.Event_BlockFile {
+			// blockfile events carry the file data
+			err = checkScope(tokenInfo, wshrpc.ApiScope_Output)
+		}
+		if err == nil && msg.Event == "" {
+			err = fmt.Errorf("subscribe needs an event")
+		}
+		if err == nil && !msg.AllScopes && len(msg.Scopes) == 0 {
+			err = fmt.Errorf("subscribe needs scopes or allscopes")
+		}
+		if err == nil {
+			c.lock.Lock()
+			c.subs[msg.Event] = wps.SubscriptionRequest{Event: msg.Event, Scopes: msg.Scopes, AllScopes: msg.AllScopes}
+			c.updateBrokerSub_nolock(msg.Event)
+			c.lock.Unlock()
+		}
+		c.sendResult(msg.ReqId, err)
+
+	case ApiWsMsg_Unsubscribe:
+		c.lock.Lock()
+		delete(c.subs, msg.Event)
+		c.updateBrokerSub_nolock(msg.Event)
+		c.lock.Unlock()
+		c.sendResult(msg.ReqId, nil)
+
+	case ApiWsMsg_Output:
+		err := checkScope(tokenInfo, wshrpc.ApiScope_Output)
+		if err == nil {
+			err = c.startOutput(msg.BlockId, msg.Offset)
+		}
+		c.sendResult(msg.ReqId, err)
+
+	case ApiWsMsg_StopOutput:
+		c.lock.Lock()
+		if stream := c.outputs[msg.BlockId]; stream != nil {
+			stream.cancelFn()
+			delete(c.outputs, msg.BlockId)
+			c.updateBrokerSub_nolock(wps.Event_BlockFile)
+		}
+		c.lock.Unlock()
+		c.sendResult(msg.ReqId, nil)
+
+	case ApiWsMsg_Input:
+		err := checkScope(tokenInfo, wshrpc.ApiScope_Input)
+		if err != nil {
+			c.sendResult(msg.ReqId, err)
+			return
+		}
+		// can start the block controller, so don't hold up the read loop
+		go func() {
+			defer func() {
+				panichandler.PanicHandler("webapi:input", recover())
+			}()
+			ctx, cancelFn := context.WithTimeout(context.Background(), apiDefaultTimeout)
+			defer cancelFn()
+			c.sendResult(msg.ReqId, sendBlockInput(ctx, msg.BlockId, msg.ApiInputRequest))
+		}()
+
+	default:
+		c.sendResult(msg.ReqId, fmt.Errorf("unknown message type %q", msg.Type))
+	}
+}
+
+func (c *apiWsConn) startOutput(blockId string, offset *int64) error {
+	ctx, cancelFn := context.WithTimeout(context.Background(), apiDefaultTimeout)
+	defer cancelFn()
+	if _, err := wstore.DBMustGet[*starobj.Block](ctx, blockId); err != nil {
+		return fmt.Errorf("error getting block: %w", err)
+	}
+	startOffset := int64(0)
+	if offset != nil {
+		startOffset = *offset
+	} else {
+		output, err := readBlockOutput(ctx, blockId, -1, 0)
+		if err != nil {
+			return err
+		}
+		startOffset = output.Size
+	}
+	streamCtx, streamCancelFn := context.WithCancel(context.Background())
+	stream := &apiOutputStream{blockId: blockId, notifyCh: make(chan struct{}, 1), cancelFn: streamCancelFn}
+	c.lock.Lock()
+	if oldStream := c.outputs[blockId]; oldStream != nil {
+		oldStream.cancelFn()
+	}
+	c.outputs[blockId] = stream
+	// subscribe before the first read so no appends are missed
+	c.updateBrokerSub_nolock(wps.Event_BlockFile)
+	c.lock.Unlock()
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("webapi:outputStream", recover())
+		}()
+		c.runOutputStream(streamCtx, stream, startOffset)
+	}()
+	return nil
+}
+
+// sends the terminal output from offset on (and everything appended after that) until canceled
+func (c *apiWsConn) runOutputStream(ctx context.Context, stream *apiOutputStream, offset int64) {
+	for {
+		for {
+			readCtx, cancelFn := context.WithTimeout(ctx, apiDefaultTimeout)
+			output, err := readBlockOutput(readCtx, stream.blockId, offset, apiWsOutputChunkSize)
+			cancelFn()
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				c.send(ApiWsServerMessage{Type: ApiWsMsg_Error, Error: err.Error(), ApiOutputData: &ApiOutputData{BlockId: stream.blockId}})
+				return
+			}
+			if output.Size < offset {
+				// output was cleared
+				offset = 0
+				c.send(ApiWsServerMessage{Type: ApiWsMsg_OutputReset, ApiOutputData: &ApiOutputData{BlockId: stream.blockId}})
+				continue
+			}
+			if output.Data64 == "" {
+				break
+			}
+			offset = output.NextOffset
+			select {
+			case c.outputCh <- ApiWsServerMessage{Type: ApiWsMsg_Output, ApiOutputData: output}:
+			case <-ctx.Done():
+				return
+			case <-c.closeCh:
+				return
+			}
+		}
+		select {
+		case <-stream.notifyCh:
+		case <-ctx.Done():
+			return
+		case <-c.closeCh:
+			return
+		}
+	}
+}
+
+func (c *apiWsConn) writeLoop() {
+	defer c.close()
+	ticker := time.NewTicker(apiWsPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg := <-c.outputCh:
+			c.conn.SetWriteDeadline(time.Now().Add(apiWsWriteWaitTimeout))
+			err := c.conn.WriteJSON(msg)
+			if err != nil {
+				log.Printf("[api] websocket %s write error: %v\n", c.routeId, err)
+				return
+			}
+
+		case <-ticker.C:
+			if _, err := validateApiToken(c.token); err != nil {
+				c.conn.SetWriteDeadline(time.Now().Add(apiWsWriteWaitTimeout))
+				c.conn.WriteJSON(ApiWsServerMessage{Type: ApiWsMsg_Error, Error: err.Error()})
+				return
+			}
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(apiWsWriteWaitTimeout))
+			if err != nil {
+				log.Printf("[api] websocket %s ping error: %v\n", c.routeId, err)
+				return
+			}
+
+		case <-c.closeCh:
+			return
+		}
+	}
+}
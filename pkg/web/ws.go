@@ -296,7 +296,7 @@ func HandleWsInternal(w http.ResponseWriter, r *http.Request) error {
 	eventbus.RegisterWSChannel(wsConnId, tabId, outputCh)
 	defer eventbus.UnregisterWSChannel(wsConnId)
 	wproxy := wshutil.MakeRpcProxy() // we create a wshproxy to handle rpc messages to/from the window
-	defer close(wproxy.ToRemoteCh)
+	defer wproxy.Close()
 	registerConn(wsConnId, routeId, wproxy)
 	defer unregisterConn(wsConnId, routeId)
 	wg := &sync.WaitGroup{}
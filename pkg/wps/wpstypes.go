@@ -16,6 +16,8 @@ const (
 	Event_UserInput        = "userinput"
 	Event_RouteGone        = "route:gone"
 	Event_WorkspaceUpdate  = "workspace:update"
+	Event_BlockCommand     = "blockcommand"
+	Event_FileWatch        = "filewatch"
 )
 
 type StarEvent struct {
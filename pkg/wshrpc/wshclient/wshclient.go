@@ -28,6 +28,18 @@ func AiSendMessageCommand(w *wshutil.WshRpc, data wshrpc.AiMessageData, opts *ws
 	return err
 }
 
+// command "apitokencreate", wshserver.ApiTokenCreateCommand
+func ApiTokenCreateCommand(w *wshutil.WshRpc, data wshrpc.CommandApiTokenCreateData, opts *wshrpc.RpcOpts) (*wshrpc.CommandApiTokenCreateRtnData, error) {
+	resp, err := sendRpcRequestCallHelper[*wshrpc.CommandApiTokenCreateRtnData](w, "apitokencreate", data, opts)
+	return resp, err
+}
+
+// command "apitokendelete", wshserver.ApiTokenDeleteCommand
+func ApiTokenDeleteCommand(w *wshutil.WshRpc, data string, opts *wshrpc.RpcOpts) error {
+	_, err := sendRpcRequestCallHelper[any](w, "apitokendelete", data, opts)
+	return err
+}
+
 // command "authenticate", wshserver.AuthenticateCommand
 func AuthenticateCommand(w *wshutil.WshRpc, data string, opts *wshrpc.RpcOpts) (wshrpc.CommandAuthenticateRtnData, error) {
 	resp, err := sendRpcRequestCallHelper[wshrpc.CommandAuthenticateRtnData](w, "authenticate", data, opts)
@@ -40,6 +52,12 @@ func AuthenticateTokenCommand(w *wshutil.WshRpc, data wshrpc.CommandAuthenticate
 	return resp, err
 }
 
+// command "blockcommands", wshserver.BlockCommandsCommand
+func BlockCommandsCommand(w *wshutil.WshRpc, data wshrpc.CommandBlockCommandsData, opts *wshrpc.RpcOpts) ([]wshrpc.BlockCommandRecord, error) {
+	resp, err := sendRpcRequestCallHelper[[]wshrpc.BlockCommandRecord](w, "blockcommands", data, opts)
+	return resp, err
+}
+
 // command "blockinfo", wshserver.BlockInfoCommand
 func BlockInfoCommand(w *wshutil.WshRpc, data string, opts *wshrpc.RpcOpts) (*wshrpc.BlockInfoData, error) {
 	resp, err := sendRpcRequestCallHelper[*wshrpc.BlockInfoData](w, "blockinfo", data, opts)
@@ -64,6 +82,18 @@ func ConnEnsureCommand(w *wshutil.WshRpc, data wshrpc.ConnExtData, opts *wshrpc.
 	return err
 }
 
+// command "connforwardadd", wshserver.ConnForwardAddCommand
+func ConnForwardAddCommand(w *wshutil.WshRpc, data wshrpc.CommandConnForwardAddData, opts *wshrpc.RpcOpts) (wshrpc.ConnForwardStatus, error) {
+	resp, err := sendRpcRequestCallHelper[wshrpc.ConnForwardStatus](w, "connforwardadd", data, opts)
+	return resp, err
+}
+
+// command "connforwardrm", wshserver.ConnForwardRmCommand
+func ConnForwardRmCommand(w *wshutil.WshRpc, data wshrpc.CommandConnForwardRmData, opts *wshrpc.RpcOpts) error {
+	_, err := sendRpcRequestCallHelper[any](w, "connforwardrm", data, opts)
+	return err
+}
+
 // command "connlist", wshserver.ConnListCommand
 func ConnListCommand(w *wshutil.WshRpc, opts *wshrpc.RpcOpts) ([]string, error) {
 	resp, err := sendRpcRequestCallHelper[[]string](w, "connlist", nil, opts)
@@ -220,6 +250,11 @@ func FileCopyCommand(w *wshutil.WshRpc, data wshrpc.CommandFileCopyData, opts *w
 	return err
 }
 
+// command "filecopystream", wshserver.FileCopyStreamCommand
+func FileCopyStreamCommand(w *wshutil.WshRpc, data wshrpc.CommandFileCopyData, opts *wshrpc.RpcOpts) chan wshrpc.RespOrErrorUnion[wshrpc.FileCopyProgress] {
+	return sendRpcRequestResponseStreamHelper[wshrpc.FileCopyProgress](w, "filecopystream", data, opts)
+}
+
 // command "filecreate", wshserver.FileCreateCommand
 func FileCreateCommand(w *wshutil.WshRpc, data wshrpc.FileData, opts *wshrpc.RpcOpts) error {
 	_, err := sendRpcRequestCallHelper[any](w, "filecreate", data, opts)
@@ -278,6 +313,11 @@ func FileReadStreamCommand(w *wshutil.WshRpc, data wshrpc.FileData, opts *wshrpc
 	return sendRpcRequestResponseStreamHelper[wshrpc.FileData](w, "filereadstream", data, opts)
 }
 
+// command "filesearch", wshserver.FileSearchCommand
+func FileSearchCommand(w *wshutil.WshRpc, data wshrpc.CommandFileSearchData, opts *wshrpc.RpcOpts) chan wshrpc.RespOrErrorUnion[wshrpc.FileSearchResult] {
+	return sendRpcRequestResponseStreamHelper[wshrpc.FileSearchResult](w, "filesearch", data, opts)
+}
+
 // command "filesharecapability", wshserver.FileShareCapabilityCommand
 func FileShareCapabilityCommand(w *wshutil.WshRpc, data string, opts *wshrpc.RpcOpts) (wshrpc.FileShareCapability, error) {
 	resp, err := sendRpcRequestCallHelper[wshrpc.FileShareCapability](w, "filesharecapability", data, opts)
@@ -289,6 +329,34 @@ func FileStreamTarCommand(w *wshutil.WshRpc, data wshrpc.CommandRemoteStreamTarD
 	return sendRpcRequestResponseStreamHelper[iochantypes.Packet](w, "filestreamtar", data, opts)
 }
 
+// command "filesync", wshserver.FileSyncCommand
+func FileSyncCommand(w *wshutil.WshRpc, data wshrpc.CommandFileSyncData, opts *wshrpc.RpcOpts) chan wshrpc.RespOrErrorUnion[wshrpc.FileSyncProgress] {
+	return sendRpcRequestResponseStreamHelper[wshrpc.FileSyncProgress](w, "filesync", data, opts)
+}
+
+// command "filetrashempty", wshserver.FileTrashEmptyCommand
+func FileTrashEmptyCommand(w *wshutil.WshRpc, data wshrpc.CommandFileTrashData, opts *wshrpc.RpcOpts) (int, error) {
+	resp, err := sendRpcRequestCallHelper[int](w, "filetrashempty", data, opts)
+	return resp, err
+}
+
+// command "filetrashlist", wshserver.FileTrashListCommand
+func FileTrashListCommand(w *wshutil.WshRpc, data wshrpc.CommandFileTrashData, opts *wshrpc.RpcOpts) ([]*wshrpc.TrashEntry, error) {
+	resp, err := sendRpcRequestCallHelper[[]*wshrpc.TrashEntry](w, "filetrashlist", data, opts)
+	return resp, err
+}
+
+// command "filetrashrestore", wshserver.FileTrashRestoreCommand
+func FileTrashRestoreCommand(w *wshutil.WshRpc, data wshrpc.CommandFileTrashData, opts *wshrpc.RpcOpts) error {
+	_, err := sendRpcRequestCallHelper[any](w, "filetrashrestore", data, opts)
+	return err
+}
+
+// command "filewatch", wshserver.FileWatchCommand
+func FileWatchCommand(w *wshutil.WshRpc, data wshrpc.CommandFileWatchData, opts *wshrpc.RpcOpts) chan wshrpc.RespOrErrorUnion[wshrpc.FileWatchEvent] {
+	return sendRpcRequestResponseStreamHelper[wshrpc.FileWatchEvent](w, "filewatch", data, opts)
+}
+
 // command "filewrite", wshserver.FileWriteCommand
 func FileWriteCommand(w *wshutil.WshRpc, data wshrpc.FileData, opts *wshrpc.RpcOpts) error {
 	_, err := sendRpcRequestCallHelper[any](w, "filewrite", data, opts)
@@ -331,6 +399,18 @@ func GetVarCommand(w *wshutil.WshRpc, data wshrpc.CommandVarData, opts *wshrpc.R
 	return resp, err
 }
 
+// command "historypurge", wshserver.HistoryPurgeCommand
+func HistoryPurgeCommand(w *wshutil.WshRpc, data wshrpc.CommandHistoryPurgeData, opts *wshrpc.RpcOpts) (int, error) {
+	resp, err := sendRpcRequestCallHelper[int](w, "historypurge", data, opts)
+	return resp, err
+}
+
+// command "historysearch", wshserver.HistorySearchCommand
+func HistorySearchCommand(w *wshutil.WshRpc, data wshrpc.CommandHistorySearchData, opts *wshrpc.RpcOpts) ([]wshrpc.HistoryItem, error) {
+	resp, err := sendRpcRequestCallHelper[[]wshrpc.HistoryItem](w, "historysearch", data, opts)
+	return resp, err
+}
+
 // command "message", wshserver.MessageCommand
 func MessageCommand(w *wshutil.WshRpc, data wshrpc.CommandMessageData, opts *wshrpc.RpcOpts) error {
 	_, err := sendRpcRequestCallHelper[any](w, "message", data, opts)
@@ -349,6 +429,12 @@ func PathCommand(w *wshutil.WshRpc, data wshrpc.PathCommandData, opts *wshrpc.Rp
 	return resp, err
 }
 
+// command "recordingreplay", wshserver.RecordingReplayCommand
+func RecordingReplayCommand(w *wshutil.WshRpc, data wshrpc.CommandRecordingReplayData, opts *wshrpc.RpcOpts) (*starobj.ORef, error) {
+	resp, err := sendRpcRequestCallHelper[*starobj.ORef](w, "recordingreplay", data, opts)
+	return resp, err
+}
+
 // command "recordtevent", wshserver.RecordTEventCommand
 func RecordTEventCommand(w *wshutil.WshRpc, data telemetrydata.TEvent, opts *wshrpc.RpcOpts) error {
 	_, err := sendRpcRequestCallHelper[any](w, "recordtevent", data, opts)
@@ -361,6 +447,11 @@ func RemoteFileCopyCommand(w *wshutil.WshRpc, data wshrpc.CommandFileCopyData, o
 	return resp, err
 }
 
+// command "remotefilecopystream", wshserver.RemoteFileCopyStreamCommand
+func RemoteFileCopyStreamCommand(w *wshutil.WshRpc, data wshrpc.CommandFileCopyData, opts *wshrpc.RpcOpts) chan wshrpc.RespOrErrorUnion[wshrpc.FileCopyProgress] {
+	return sendRpcRequestResponseStreamHelper[wshrpc.FileCopyProgress](w, "remotefilecopystream", data, opts)
+}
+
 // command "remotefiledelete", wshserver.RemoteFileDeleteCommand
 func RemoteFileDeleteCommand(w *wshutil.WshRpc, data wshrpc.CommandDeleteFileData, opts *wshrpc.RpcOpts) error {
 	_, err := sendRpcRequestCallHelper[any](w, "remotefiledelete", data, opts)
@@ -385,12 +476,22 @@ func RemoteFileMoveCommand(w *wshutil.WshRpc, data wshrpc.CommandFileCopyData, o
 	return err
 }
 
+// command "remotefilesearch", wshserver.RemoteFileSearchCommand
+func RemoteFileSearchCommand(w *wshutil.WshRpc, data wshrpc.CommandFileSearchData, opts *wshrpc.RpcOpts) chan wshrpc.RespOrErrorUnion[wshrpc.FileSearchResult] {
+	return sendRpcRequestResponseStreamHelper[wshrpc.FileSearchResult](w, "remotefilesearch", data, opts)
+}
+
 // command "remotefiletouch", wshserver.RemoteFileTouchCommand
 func RemoteFileTouchCommand(w *wshutil.WshRpc, data string, opts *wshrpc.RpcOpts) error {
 	_, err := sendRpcRequestCallHelper[any](w, "remotefiletouch", data, opts)
 	return err
 }
 
+// command "remotefilewatch", wshserver.RemoteFileWatchCommand
+func RemoteFileWatchCommand(w *wshutil.WshRpc, data wshrpc.CommandFileWatchData, opts *wshrpc.RpcOpts) chan wshrpc.RespOrErrorUnion[wshrpc.FileWatchEvent] {
+	return sendRpcRequestResponseStreamHelper[wshrpc.FileWatchEvent](w, "remotefilewatch", data, opts)
+}
+
 // command "remotegetinfo", wshserver.RemoteGetInfoCommand
 func RemoteGetInfoCommand(w *wshutil.WshRpc, opts *wshrpc.RpcOpts) (wshrpc.RemoteInfo, error) {
 	resp, err := sendRpcRequestCallHelper[wshrpc.RemoteInfo](w, "remotegetinfo", nil, opts)
@@ -429,6 +530,12 @@ func RemoteTarStreamCommand(w *wshutil.WshRpc, data wshrpc.CommandRemoteStreamTa
 	return sendRpcRequestResponseStreamHelper[iochantypes.Packet](w, "remotetarstream", data, opts)
 }
 
+// command "remoteupdatejwt", wshserver.RemoteUpdateJwtCommand
+func RemoteUpdateJwtCommand(w *wshutil.WshRpc, data string, opts *wshrpc.RpcOpts) error {
+	_, err := sendRpcRequestCallHelper[any](w, "remoteupdatejwt", data, opts)
+	return err
+}
+
 // command "remotewritefile", wshserver.RemoteWriteFileCommand
 func RemoteWriteFileCommand(w *wshutil.WshRpc, data wshrpc.FileData, opts *wshrpc.RpcOpts) error {
 	_, err := sendRpcRequestCallHelper[any](w, "remotewritefile", data, opts)
@@ -441,6 +548,12 @@ func ResolveIdsCommand(w *wshutil.WshRpc, data wshrpc.CommandResolveIdsData, opt
 	return resp, err
 }
 
+// command "rotatejwtkey", wshserver.RotateJwtKeyCommand
+func RotateJwtKeyCommand(w *wshutil.WshRpc, data wshrpc.CommandRotateJwtKeyData, opts *wshrpc.RpcOpts) (wshrpc.CommandRotateJwtKeyRtnData, error) {
+	resp, err := sendRpcRequestCallHelper[wshrpc.CommandRotateJwtKeyRtnData](w, "rotatejwtkey", data, opts)
+	return resp, err
+}
+
 // command "routeannounce", wshserver.RouteAnnounceCommand
 func RouteAnnounceCommand(w *wshutil.WshRpc, opts *wshrpc.RpcOpts) error {
 	_, err := sendRpcRequestCallHelper[any](w, "routeannounce", nil, opts)
@@ -453,6 +566,12 @@ func RouteUnannounceCommand(w *wshutil.WshRpc, opts *wshrpc.RpcOpts) error {
 	return err
 }
 
+// command "rpcstats", wshserver.RpcStatsCommand
+func RpcStatsCommand(w *wshutil.WshRpc, data wshrpc.CommandRpcStatsData, opts *wshrpc.RpcOpts) (*wshrpc.RpcStatsData, error) {
+	resp, err := sendRpcRequestCallHelper[*wshrpc.RpcStatsData](w, "rpcstats", data, opts)
+	return resp, err
+}
+
 // command "sendtelemetry", wshserver.SendTelemetryCommand
 func SendTelemetryCommand(w *wshutil.WshRpc, opts *wshrpc.RpcOpts) error {
 	_, err := sendRpcRequestCallHelper[any](w, "sendtelemetry", nil, opts)
@@ -489,6 +608,12 @@ func SetViewCommand(w *wshutil.WshRpc, data wshrpc.CommandBlockSetViewData, opts
 	return err
 }
 
+// command "starailistmodels", wshserver.StarAiListModelsCommand
+func StarAiListModelsCommand(w *wshutil.WshRpc, data wshrpc.CommandStarAiListModelsData, opts *wshrpc.RpcOpts) (*wshrpc.CommandStarAiListModelsRtnData, error) {
+	resp, err := sendRpcRequestCallHelper[*wshrpc.CommandStarAiListModelsRtnData](w, "starailistmodels", data, opts)
+	return resp, err
+}
+
 // command "starinfo", wshserver.StarInfoCommand
 func StarInfoCommand(w *wshutil.WshRpc, opts *wshrpc.RpcOpts) (*wshrpc.StarInfoData, error) {
 	resp, err := sendRpcRequestCallHelper[*wshrpc.StarInfoData](w, "starinfo", nil, opts)
@@ -510,6 +635,12 @@ func StreamTestCommand(w *wshutil.WshRpc, opts *wshrpc.RpcOpts) chan wshrpc.Resp
 	return sendRpcRequestResponseStreamHelper[int](w, "streamtest", nil, opts)
 }
 
+// command "termsearch", wshserver.TermSearchCommand
+func TermSearchCommand(w *wshutil.WshRpc, data wshrpc.CommandTermSearchData, opts *wshrpc.RpcOpts) (*wshrpc.CommandTermSearchRtnData, error) {
+	resp, err := sendRpcRequestCallHelper[*wshrpc.CommandTermSearchRtnData](w, "termsearch", data, opts)
+	return resp, err
+}
+
 // command "test", wshserver.TestCommand
 func TestCommand(w *wshutil.WshRpc, data string, opts *wshrpc.RpcOpts) error {
 	_, err := sendRpcRequestCallHelper[any](w, "test", data, opts)
@@ -0,0 +1,191 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshremote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/util/archiveutil"
+	"github.com/commandlinedev/starterm/pkg/util/fileutil"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshutil"
+)
+
+var errArchiveCopy = errors.New("copying out of archives is not supported, read the files with wsh file cat")
+
+// paths inside archives must be split before they are expanded, expanding cleans the path which removes the "//" separator.
+// the returned archive path is expanded.
+func splitArchivePath(path string) (string, string, bool) {
+	archivePath, member, ok := archiveutil.SplitPath(path)
+	if !ok {
+		return "", "", false
+	}
+	return starbase.ExpandHomeDirSafe(archivePath), member, true
+}
+
+// like starbase.ExpandHomeDirSafe, but keeps the "//" separator of paths inside archives
+func expandArchivePath(path string) string {
+	if archivePath, member, ok := splitArchivePath(path); ok {
+		return archiveutil.JoinPath(archivePath, member)
+	}
+	return starbase.ExpandHomeDirSafe(path)
+}
+
+// archive members cannot be changed, without this check the cleaned path would point below the archive file
+func checkNotInArchive(paths ...string) error {
+	for _, path := range paths {
+		if _, _, ok := splitArchivePath(path); ok {
+			return fmt.Errorf("cannot change %q: %w", path, archiveutil.ErrReadOnly)
+		}
+	}
+	return nil
+}
+
+func archiveDirPart(archivePath string, member string) string {
+	if member == "" {
+		return computeDirPart(archivePath)
+	}
+	parent := path.Dir(member)
+	if parent == "." {
+		parent = ""
+	}
+	return archiveutil.JoinPath(archivePath, parent)
+}
+
+func memberToFileInfo(archivePath string, m *archiveutil.Member) *wshrpc.FileInfo {
+	rtn := &wshrpc.FileInfo{
+		Path:     starbase.ReplaceHomeDir(archiveutil.JoinPath(archivePath, m.Path)),
+		Dir:      archiveDirPart(archivePath, m.Path),
+		Name:     m.Name(),
+		Size:     m.Size(),
+		Mode:     m.Mode(),
+		ModeStr:  m.Mode().String(),
+		ModTime:  m.ModTime().UnixMilli(),
+		IsDir:    m.IsDir(),
+		MimeType: fileutil.DetectMimeType(m.Path, m, false),
+		ReadOnly: true,
+	}
+	if m.Path == "" {
+		rtn.Name = filepath.Base(archivePath)
+	}
+	if m.IsDir() {
+		rtn.Size = -1
+	}
+	return rtn
+}
+
+func archiveFileInfo(archivePath string, member string) (*wshrpc.FileInfo, error) {
+	m, err := archiveutil.Stat(archivePath, member)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &wshrpc.FileInfo{
+			Path:     starbase.ReplaceHomeDir(archiveutil.JoinPath(archivePath, member)),
+			Dir:      archiveDirPart(archivePath, member),
+			NotFound: true,
+			ReadOnly: true,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return memberToFileInfo(archivePath, m), nil
+}
+
+// returns the archive and member to list for path, a path to an archive file lists the archive root
+func archiveListPath(path string) (string, string, bool) {
+	if archivePath, member, ok := splitArchivePath(path); ok {
+		return archivePath, member, true
+	}
+	if !archiveutil.IsArchive(path) {
+		return "", "", false
+	}
+	cleanedPath := starbase.ExpandHomeDirSafe(path)
+	if finfo, err := os.Stat(cleanedPath); err != nil || !finfo.Mode().IsRegular() {
+		return "", "", false
+	}
+	return cleanedPath, "", true
+}
+
+func listArchiveEntries(ctx context.Context, archivePath string, member string, opts *wshrpc.FileListOpts, ch chan wshrpc.RespOrErrorUnion[wshrpc.CommandRemoteListEntriesRtnData]) {
+	members, err := archiveutil.List(archivePath, member, opts.All)
+	if err != nil {
+		ch <- wshutil.RespErr[wshrpc.CommandRemoteListEntriesRtnData](err)
+		return
+	}
+	if opts.Offset >= len(members) {
+		return
+	}
+	members = members[opts.Offset:]
+	if len(members) > opts.Limit {
+		members = members[:opts.Limit]
+	}
+	var fileInfoArr []*wshrpc.FileInfo
+	for _, m := range members {
+		if ctx.Err() != nil {
+			ch <- wshutil.RespErr[wshrpc.CommandRemoteListEntriesRtnData](ctx.Err())
+			return
+		}
+		fileInfoArr = append(fileInfoArr, memberToFileInfo(archivePath, m))
+		if len(fileInfoArr) >= wshrpc.DirChunkSize {
+			ch <- wshrpc.RespOrErrorUnion[wshrpc.CommandRemoteListEntriesRtnData]{Response: wshrpc.CommandRemoteListEntriesRtnData{FileInfo: fileInfoArr}}
+			fileInfoArr = nil
+		}
+	}
+	if len(fileInfoArr) > 0 {
+		ch <- wshrpc.RespOrErrorUnion[wshrpc.CommandRemoteListEntriesRtnData]{Response: wshrpc.CommandRemoteListEntriesRtnData{FileInfo: fileInfoArr}}
+	}
+}
+
+// streams a directory listing or a file from inside an archive, only the requested member is decompressed and sent
+func (impl *ServerImpl) remoteStreamArchive(ctx context.Context, archivePath string, member string, isDir bool, byteRange ByteRangeType, dataCallback func(fileInfo []*wshrpc.FileInfo, data []byte, byteRange ByteRangeType)) error {
+	if isDir {
+		members, err := archiveutil.List(archivePath, member, false)
+		if err != nil {
+			return err
+		}
+		if byteRange.All {
+			if len(members) > wshrpc.MaxDirSize {
+				members = members[:wshrpc.MaxDirSize]
+			}
+		} else if byteRange.Start < int64(len(members)) {
+			members = members[byteRange.Start:min(byteRange.End, int64(len(members)))]
+		} else {
+			members = nil
+		}
+		var fileInfoArr []*wshrpc.FileInfo
+		for _, m := range members {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fileInfoArr = append(fileInfoArr, memberToFileInfo(archivePath, m))
+			if len(fileInfoArr) >= wshrpc.DirChunkSize {
+				dataCallback(fileInfoArr, nil, byteRange)
+				fileInfoArr = nil
+			}
+		}
+		if len(fileInfoArr) > 0 {
+			dataCallback(fileInfoArr, nil, byteRange)
+		}
+		return nil
+	}
+	rc, _, err := archiveutil.Open(archivePath, member)
+	if err != nil {
+		return err
+	}
+	defer utilfn.GracefulClose(rc, "remoteStreamArchive", archivePath)
+	if !byteRange.All && byteRange.Start > 0 {
+		if _, err := io.CopyN(io.Discard, rc, byteRange.Start); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("seeking %q in %q: %w", member, archivePath, err)
+		}
+	}
+	return streamReaderData(ctx, archiveutil.JoinPath(archivePath, member), rc, byteRange, dataCallback)
+}
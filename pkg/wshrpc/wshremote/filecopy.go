@@ -0,0 +1,206 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshremote
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/util/tarcopy"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+const (
+	// files received over a tar stream are written next to the destination with this suffix and renamed into place when complete.
+	// an interrupted copy leaves the partial file behind so it can be resumed.
+	PartialFileSuffix = ".starterm-part"
+
+	MaxResumeEntries     = 100000
+	copyProgressInterval = 250 * time.Millisecond
+)
+
+// tracks the progress of a copy, sendFn is nil if nobody is listening
+type copyProgress struct {
+	progress wshrpc.FileCopyProgress
+	startTs  time.Time
+	lastSent time.Time
+	sendFn   func(wshrpc.FileCopyProgress)
+}
+
+func makeCopyProgress(sendFn func(wshrpc.FileCopyProgress)) *copyProgress {
+	return &copyProgress{startTs: time.Now(), sendFn: sendFn}
+}
+
+func (cp *copyProgress) send(force bool) {
+	if cp.sendFn == nil {
+		return
+	}
+	now := time.Now()
+	if !force && now.Sub(cp.lastSent) < copyProgressInterval {
+		return
+	}
+	cp.lastSent = now
+	cp.progress.ElapsedMs = now.Sub(cp.startTs).Milliseconds()
+	cp.sendFn(cp.progress)
+}
+
+func (cp *copyProgress) startFile(path string, size int64, resumedBytes int64) {
+	cp.progress.Path = path
+	cp.progress.FileSize = size
+	cp.progress.FileBytes = resumedBytes
+	cp.send(false)
+}
+
+// counts the bytes written for the current file (used with io.TeeReader)
+func (cp *copyProgress) Write(p []byte) (int, error) {
+	cp.progress.FileBytes += int64(len(p))
+	cp.progress.TotalBytes += int64(len(p))
+	cp.send(false)
+	return len(p), nil
+}
+
+func (cp *copyProgress) finishFile() {
+	cp.progress.NumFiles++
+	cp.send(false)
+}
+
+func (cp *copyProgress) done(isDir bool) {
+	cp.progress.Path = ""
+	cp.progress.FileSize = 0
+	cp.progress.FileBytes = 0
+	cp.progress.IsDir = isDir
+	cp.progress.Done = true
+	cp.send(true)
+}
+
+func addResumeEntry(manifest map[string]wshrpc.FileResumeInfo, key string, info fs.FileInfo) {
+	if strings.HasSuffix(key, PartialFileSuffix) {
+		key = strings.TrimSuffix(key, PartialFileSuffix)
+		entry := manifest[key]
+		entry.PartialSize = info.Size()
+		manifest[key] = entry
+		return
+	}
+	entry := manifest[key]
+	entry.Size = info.Size()
+	entry.ModTs = info.ModTime().UnixMilli()
+	manifest[key] = entry
+}
+
+// lists what is already at the destination of a copy, keyed by tar entry name (relative to destPath).
+// srcBase is the base name of the source if its contents are copied into a directory named after it (no trailing slash), only that directory is walked.
+// the "" key is for a single file copied to destPath itself.
+// with checksum set the complete files are hashed, so the source only skips files that really match.
+func buildResumeManifest(destPath string, srcBase string, checksum bool) (map[string]wshrpc.FileResumeInfo, error) {
+	manifest := make(map[string]wshrpc.FileResumeInfo)
+	if info, err := os.Stat(destPath); err == nil && info.Mode().IsRegular() {
+		addResumeEntry(manifest, "", info)
+	}
+	if info, err := os.Stat(destPath + PartialFileSuffix); err == nil && info.Mode().IsRegular() {
+		addResumeEntry(manifest, PartialFileSuffix, info)
+	}
+	root := destPath
+	if srcBase != "" {
+		root = filepath.Join(destPath, srcBase)
+		if info, err := os.Stat(root + PartialFileSuffix); err == nil && info.Mode().IsRegular() {
+			addResumeEntry(manifest, srcBase+PartialFileSuffix, info)
+		}
+	}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() || path == destPath {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(destPath, path)
+		if err != nil {
+			return err
+		}
+		addResumeEntry(manifest, filepath.ToSlash(rel), info)
+		if len(manifest) > MaxResumeEntries {
+			return fmt.Errorf("too many files at the destination to resume (max %d)", MaxResumeEntries)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if checksum {
+		for key, entry := range manifest {
+			if entry.ModTs == 0 {
+				continue
+			}
+			entry.Checksum, err = checksumPath(filepath.Join(destPath, filepath.FromSlash(key)))
+			if err != nil {
+				return nil, fmt.Errorf("cannot checksum %q: %w", key, err)
+			}
+			manifest[key] = entry
+		}
+	}
+	return manifest, nil
+}
+
+func checksumPath(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer utilfn.GracefulClose(file, "checksumPath", path)
+	return tarcopy.ChecksumFile(file)
+}
+
+// returns the pax records for a regular file in the tar stream given what is already at the destination, and the offset to start sending from.
+// with checksum set a file is only skipped if its sha256 matches the destination's, the records then carry the checksum of the file.
+func resumeRecords(path string, pathPrefix string, info fs.FileInfo, singleFile bool, checksum bool, manifest map[string]wshrpc.FileResumeInfo) (map[string]string, int64) {
+	if len(manifest) == 0 {
+		return nil, 0
+	}
+	name, err := tarcopy.EntryName(path, pathPrefix)
+	if err != nil {
+		return nil, 0
+	}
+	destInfo, ok := manifest[name]
+	if singleFile {
+		if singleInfo, found := manifest[""]; found {
+			destInfo, ok = singleInfo, true
+		}
+	}
+	if !ok {
+		return nil, 0
+	}
+	// tar headers round the mod time to the second, which is what the destination sets
+	if destInfo.ModTs != 0 && destInfo.Size == info.Size() && time.UnixMilli(destInfo.ModTs).Round(time.Second).Equal(info.ModTime().Round(time.Second)) {
+		if !checksum {
+			return map[string]string{tarcopy.Skipped: "true"}, 0
+		}
+		srcChecksum, err := checksumPath(path)
+		if err != nil {
+			return nil, 0
+		}
+		if srcChecksum == destInfo.Checksum {
+			return map[string]string{tarcopy.Skipped: "true"}, 0
+		}
+		// same size and mod time but different content, the whole file is sent again
+		return map[string]string{tarcopy.FileChecksum: srcChecksum}, 0
+	}
+	if destInfo.PartialSize > 0 && destInfo.PartialSize <= info.Size() {
+		return map[string]string{tarcopy.ResumeOffset: strconv.FormatInt(destInfo.PartialSize, 10)}, destInfo.PartialSize
+	}
+	return nil, 0
+}
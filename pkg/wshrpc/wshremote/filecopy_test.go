@@ -0,0 +1,65 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshremote
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/util/tarcopy"
+)
+
+func TestResumeRecordsChecksum(t *testing.T) {
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	// same size and mod time on both sides, only "changed.txt" has different content
+	for name, content := range map[string][2]string{
+		"same.txt":    {"hello", "hello"},
+		"changed.txt": {"hello", "HELLO"},
+	} {
+		for idx, dir := range []string{srcDir, destDir} {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content[idx]), 0644); err != nil {
+				t.Fatalf("error writing %s: %v", path, err)
+			}
+			if err := os.Chtimes(path, mtime, mtime); err != nil {
+				t.Fatalf("error setting mtime of %s: %v", path, err)
+			}
+		}
+	}
+	tests := []struct {
+		name     string
+		checksum bool
+		skipped  bool
+	}{
+		{"same.txt", false, true},
+		{"changed.txt", false, true},
+		{"same.txt", true, true},
+		{"changed.txt", true, false},
+	}
+	for _, tc := range tests {
+		manifest, err := buildResumeManifest(destDir, "", tc.checksum)
+		if err != nil {
+			t.Fatalf("error building manifest: %v", err)
+		}
+		if hasChecksum := manifest[tc.name].Checksum != ""; hasChecksum != tc.checksum {
+			t.Errorf("%s (checksum %v): manifest has a checksum %v", tc.name, tc.checksum, hasChecksum)
+		}
+		path := filepath.Join(srcDir, tc.name)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("error stating %s: %v", path, err)
+		}
+		records, offset := resumeRecords(path, srcDir, info, false, tc.checksum, manifest)
+		if skipped := records[tarcopy.Skipped] == "true"; skipped != tc.skipped || offset != 0 {
+			t.Errorf("%s (checksum %v): got skipped %v offset %d, want skipped %v", tc.name, tc.checksum, skipped, offset, tc.skipped)
+		}
+		if !tc.skipped && records[tarcopy.FileChecksum] == "" {
+			t.Errorf("%s (checksum %v): a resent file should carry its checksum", tc.name, tc.checksum)
+		}
+	}
+}
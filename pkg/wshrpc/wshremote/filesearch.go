@@ -0,0 +1,119 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshremote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/remote/connparse"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/filesearch"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/filesync"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshutil"
+)
+
+// walks root adding the regular files that pass the filter (symlinks are not followed), .gitignore files are added to the filter as their directories are entered
+func walkSearchDir(ctx context.Context, root string, filter *filesync.Filter, readGitIgnore bool, addFile func(relPath string, size int64) bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			// not readable, or removed while walking
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel == "." {
+				rel = ""
+			} else if filter.Excluded(rel, true) {
+				return filepath.SkipDir
+			}
+			if readGitIgnore {
+				if content, err := os.ReadFile(filepath.Join(path, filesync.GitIgnoreFile)); err == nil {
+					filter.AddGitIgnore(rel, string(content))
+				}
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || filter.Excluded(rel, false) || !filter.Included(rel) {
+			return nil
+		}
+		finfo, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if !addFile(rel, finfo.Size()) {
+			return ctx.Err()
+		}
+		return nil
+	})
+}
+
+func (impl *ServerImpl) RemoteFileSearchCommand(ctx context.Context, data wshrpc.CommandFileSearchData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileSearchResult] {
+	opts := data.Opts
+	if opts == nil {
+		return wshutil.SendErrCh[wshrpc.FileSearchResult](fmt.Errorf("no search pattern given"))
+	}
+	conn, err := connparse.ParseURIAndReplaceCurrentHost(ctx, data.Uri)
+	if err != nil {
+		return wshutil.SendErrCh[wshrpc.FileSearchResult](fmt.Errorf("cannot parse uri %q: %w", data.Uri, err))
+	}
+	path, err := starbase.ExpandHomeDir(conn.Path)
+	if err != nil {
+		return wshutil.SendErrCh[wshrpc.FileSearchResult](err)
+	}
+	path = filepath.Clean(path)
+	filter, err := filesearch.MakeFilter(opts)
+	if err != nil {
+		return wshutil.SendErrCh[wshrpc.FileSearchResult](err)
+	}
+	finfo, err := os.Stat(path)
+	if err != nil {
+		return wshutil.SendErrCh[wshrpc.FileSearchResult](fmt.Errorf("cannot stat %q: %w", conn.Path, err))
+	}
+	log.Printf("RemoteFileSearchCommand: searching %s for %q\n", path, opts.Pattern)
+	ch := make(chan wshrpc.RespOrErrorUnion[wshrpc.FileSearchResult], 16)
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("RemoteFileSearchCommand", recover())
+		}()
+		defer close(ch)
+		err := filesearch.Run(ctx, opts, func(ctx context.Context, addFile func(string, int64) bool) error {
+			if !finfo.IsDir() {
+				addFile(finfo.Name(), finfo.Size())
+				return nil
+			}
+			return walkSearchDir(ctx, path, filter, !opts.NoGitIgnore, addFile)
+		}, func(ctx context.Context, relPath string) (io.ReadCloser, error) {
+			if !finfo.IsDir() {
+				return os.Open(path)
+			}
+			return os.Open(filepath.Join(path, filepath.FromSlash(relPath)))
+		}, func(result wshrpc.FileSearchResult) bool {
+			select {
+			case ch <- wshrpc.RespOrErrorUnion[wshrpc.FileSearchResult]{Response: result}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			ch <- wshutil.RespErr[wshrpc.FileSearchResult](err)
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,299 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshremote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+	"github.com/commandlinedev/starterm/pkg/remote/connparse"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/filesync"
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/wps"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
+	"github.com/commandlinedev/starterm/pkg/wshutil"
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	DefaultWatchDebounce = 100 * time.Millisecond
+	// under constant changes (a growing log file) a batch is still sent every maxWatchDelayFactor debounce periods
+	maxWatchDelayFactor = 10
+	// each directory takes an inotify watch, which are limited per user (fs.inotify.max_user_watches)
+	MaxWatchDirs = 8192
+)
+
+type fileWatcher struct {
+	watcher   *fsnotify.Watcher
+	root      string // the watched directory, or the parent directory when watching a single file
+	fileName  string // set when watching a single file
+	recursive bool
+	filter    *filesync.Filter
+	dirs      map[string]bool
+}
+
+// returns the path relative to the root ("/" separated), false if it is outside the root
+func (fw *fileWatcher) relPath(path string) (string, bool) {
+	rel, err := filepath.Rel(fw.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// watches dir, and its subdirectories if recursive (directories excluded by the filter are not watched)
+func (fw *fileWatcher) addDir(dir string) error {
+	if !fw.recursive {
+		return fw.addWatch(dir)
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path != dir {
+				// removed while walking, or not readable
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if rel, ok := fw.relPath(path); ok && rel != "." && fw.filter.Excluded(rel, true) {
+			return filepath.SkipDir
+		}
+		return fw.addWatch(path)
+	})
+}
+
+func (fw *fileWatcher) addWatch(dir string) error {
+	if fw.dirs[dir] {
+		return nil
+	}
+	if len(fw.dirs) >= MaxWatchDirs {
+		return fmt.Errorf("too many directories to watch (max %d)", MaxWatchDirs)
+	}
+	if err := fw.watcher.Add(dir); err != nil {
+		return fmt.Errorf("cannot watch %q: %w", dir, err)
+	}
+	fw.dirs[dir] = true
+	return nil
+}
+
+// converts an fsnotify event, returns nil if the event is filtered out
+func (fw *fileWatcher) makeChange(event fsnotify.Event) *wshrpc.FileWatchChange {
+	var op string
+	switch {
+	case event.Has(fsnotify.Create):
+		op = wshrpc.FileWatchOp_Create
+	case event.Has(fsnotify.Write):
+		op = wshrpc.FileWatchOp_Write
+	case event.Has(fsnotify.Remove):
+		op = wshrpc.FileWatchOp_Remove
+	case event.Has(fsnotify.Rename):
+		op = wshrpc.FileWatchOp_Rename
+	default:
+		// chmod only
+		return nil
+	}
+	if fw.fileName != "" && filepath.Base(event.Name) != fw.fileName {
+		return nil
+	}
+	rel, ok := fw.relPath(event.Name)
+	if !ok || rel == "." {
+		return nil
+	}
+	isDir := fw.dirs[event.Name]
+	if op == wshrpc.FileWatchOp_Create {
+		if finfo, err := os.Lstat(event.Name); err == nil {
+			isDir = finfo.IsDir()
+		}
+	}
+	if fw.filter.Excluded(rel, isDir) || (!isDir && !fw.filter.Included(rel)) {
+		return nil
+	}
+	if isDir && fw.recursive {
+		if op == wshrpc.FileWatchOp_Create {
+			if err := fw.addDir(event.Name); err != nil {
+				log.Printf("filewatch: %v\n", err)
+			}
+		} else if op == wshrpc.FileWatchOp_Remove || op == wshrpc.FileWatchOp_Rename {
+			// the watches of removed directories go away on their own
+			fw.watcher.Remove(event.Name)
+			delete(fw.dirs, event.Name)
+		}
+	}
+	return &wshrpc.FileWatchChange{Path: rel, Op: op, IsDir: isDir}
+}
+
+// the changes waiting to be sent, one per path
+type watchBatch struct {
+	changes []wshrpc.FileWatchChange
+	idx     map[string]int
+	first   time.Time
+}
+
+func (b *watchBatch) add(change wshrpc.FileWatchChange, now time.Time) {
+	if idx, found := b.idx[change.Path]; found {
+		// a new file that is written to is still just created
+		if !(b.changes[idx].Op == wshrpc.FileWatchOp_Create && change.Op == wshrpc.FileWatchOp_Write) {
+			b.changes[idx] = change
+		}
+		return
+	}
+	if len(b.changes) == 0 {
+		b.first = now
+		b.idx = make(map[string]int)
+	}
+	b.idx[change.Path] = len(b.changes)
+	b.changes = append(b.changes, change)
+}
+
+// true if the batch has waited long enough that it is sent even though changes keep coming
+func (b *watchBatch) due(now time.Time, debounce time.Duration) bool {
+	return len(b.changes) > 0 && now.Sub(b.first) >= maxWatchDelayFactor*debounce
+}
+
+func (b *watchBatch) take() []wshrpc.FileWatchChange {
+	rtn := b.changes
+	b.changes = nil
+	b.idx = nil
+	return rtn
+}
+
+func (impl *ServerImpl) RemoteFileWatchCommand(ctx context.Context, data wshrpc.CommandFileWatchData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileWatchEvent] {
+	opts := data.Opts
+	if opts == nil {
+		opts = &wshrpc.FileWatchOpts{}
+	}
+	conn, err := connparse.ParseURIAndReplaceCurrentHost(ctx, data.Uri)
+	if err != nil {
+		return wshutil.SendErrCh[wshrpc.FileWatchEvent](fmt.Errorf("cannot parse uri %q: %w", data.Uri, err))
+	}
+	path, err := starbase.ExpandHomeDir(conn.Path)
+	if err != nil {
+		return wshutil.SendErrCh[wshrpc.FileWatchEvent](err)
+	}
+	path = filepath.Clean(path)
+	filter, err := filesync.MakeFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return wshutil.SendErrCh[wshrpc.FileWatchEvent](err)
+	}
+	finfo, err := os.Stat(path)
+	if err != nil {
+		return wshutil.SendErrCh[wshrpc.FileWatchEvent](fmt.Errorf("cannot stat %q: %w", conn.Path, err))
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return wshutil.SendErrCh[wshrpc.FileWatchEvent](fmt.Errorf("cannot create watcher: %w", err))
+	}
+	fw := &fileWatcher{watcher: watcher, root: path, recursive: opts.Recursive, filter: filter, dirs: make(map[string]bool)}
+	if finfo.IsDir() {
+		err = fw.addDir(path)
+	} else {
+		// editors often replace files instead of writing them, so the directory is watched
+		fw.root = filepath.Dir(path)
+		fw.fileName = filepath.Base(path)
+		fw.recursive = false
+		err = fw.addWatch(fw.root)
+	}
+	if err != nil {
+		watcher.Close()
+		return wshutil.SendErrCh[wshrpc.FileWatchEvent](err)
+	}
+	debounce := DefaultWatchDebounce
+	if opts.DebounceMs > 0 {
+		debounce = time.Duration(opts.DebounceMs) * time.Millisecond
+	}
+	uri := (&connparse.Connection{Scheme: connparse.ConnectionTypeWsh, Host: conn.Host, Path: filepath.ToSlash(path)}).GetFullURI()
+	rpcClient := wshutil.GetWshRpcFromContext(ctx)
+	log.Printf("RemoteFileWatchCommand: watching %s (%d dirs)\n", uri, len(fw.dirs))
+
+	ch := make(chan wshrpc.RespOrErrorUnion[wshrpc.FileWatchEvent], 16)
+	go func() {
+		defer func() {
+			panichandler.PanicHandler("RemoteFileWatchCommand", recover())
+		}()
+		defer close(ch)
+		defer watcher.Close()
+		send := func(event wshrpc.FileWatchEvent) bool {
+			select {
+			case ch <- wshrpc.RespOrErrorUnion[wshrpc.FileWatchEvent]{Response: event}:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if !send(wshrpc.FileWatchEvent{Uri: uri, Ready: true}) {
+			return
+		}
+		var batch watchBatch
+		timer := time.NewTimer(debounce)
+		timer.Stop()
+		defer timer.Stop()
+		flush := func() bool {
+			changes := batch.take()
+			if len(changes) == 0 {
+				return true
+			}
+			event := wshrpc.FileWatchEvent{Uri: uri, Changes: changes}
+			if rpcClient != nil {
+				wshclient.EventPublishCommand(rpcClient, wps.StarEvent{
+					Event:  wps.Event_FileWatch,
+					Scopes: []string{conn.Host, uri},
+					Data:   event,
+				}, &wshrpc.RpcOpts{NoResponse: true})
+			}
+			return send(event)
+		}
+		// returns false if the watch is done
+		addPending := func(change wshrpc.FileWatchChange) bool {
+			batch.add(change, time.Now())
+			if batch.due(time.Now(), debounce) {
+				timer.Stop()
+				return flush()
+			}
+			timer.Reset(debounce)
+			return true
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				change := fw.makeChange(event)
+				if change != nil && !addPending(*change) {
+					return
+				}
+			case <-timer.C:
+				if !flush() {
+					return
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				if errors.Is(err, fsnotify.ErrEventOverflow) {
+					// changes were dropped, send a change for the root so watchers rescan
+					if !addPending(wshrpc.FileWatchChange{Path: ".", Op: wshrpc.FileWatchOp_Write, IsDir: true}) {
+						return
+					}
+					continue
+				}
+				log.Printf("RemoteFileWatchCommand: watcher error: %v\n", err)
+			}
+		}
+	}()
+	return ch
+}
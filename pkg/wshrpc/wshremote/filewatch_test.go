@@ -0,0 +1,205 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshremote
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/remote/connparse"
+	"github.com/commandlinedev/starterm/pkg/remote/fileshare/filesync"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/fsnotify/fsnotify"
+)
+
+func makeTestWatcher(t *testing.T, recursive bool, includes []string, excludes []string) (*fileWatcher, string) {
+	root := t.TempDir()
+	filter, err := filesync.MakeFilter(includes, excludes)
+	if err != nil {
+		t.Fatalf("error making filter: %v", err)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatalf("error creating watcher: %v", err)
+	}
+	t.Cleanup(func() { watcher.Close() })
+	fw := &fileWatcher{watcher: watcher, root: root, recursive: recursive, filter: filter, dirs: make(map[string]bool)}
+	if err := fw.addDir(root); err != nil {
+		t.Fatalf("error watching %s: %v", root, err)
+	}
+	return fw, root
+}
+
+func mkdirTest(t *testing.T, path string) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("error creating %s: %v", path, err)
+	}
+}
+
+func TestMakeChange(t *testing.T) {
+	fw, root := makeTestWatcher(t, true, nil, []string{"*.log", "node_modules/"})
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("x"), 0644); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	mkdirTest(t, filepath.Join(root, "sub", "deep"))
+	mkdirTest(t, filepath.Join(root, "node_modules", "pkg"))
+
+	tests := []struct {
+		name  string
+		event fsnotify.Event
+		want  *wshrpc.FileWatchChange
+	}{
+		{"create file", fsnotify.Event{Name: filepath.Join(root, "a.txt"), Op: fsnotify.Create}, &wshrpc.FileWatchChange{Path: "a.txt", Op: wshrpc.FileWatchOp_Create}},
+		{"write file", fsnotify.Event{Name: filepath.Join(root, "a.txt"), Op: fsnotify.Write}, &wshrpc.FileWatchChange{Path: "a.txt", Op: wshrpc.FileWatchOp_Write}},
+		{"create and write", fsnotify.Event{Name: filepath.Join(root, "a.txt"), Op: fsnotify.Create | fsnotify.Write}, &wshrpc.FileWatchChange{Path: "a.txt", Op: wshrpc.FileWatchOp_Create}},
+		{"rename", fsnotify.Event{Name: filepath.Join(root, "old.txt"), Op: fsnotify.Rename}, &wshrpc.FileWatchChange{Path: "old.txt", Op: wshrpc.FileWatchOp_Rename}},
+		{"chmod", fsnotify.Event{Name: filepath.Join(root, "a.txt"), Op: fsnotify.Chmod}, nil},
+		{"excluded file", fsnotify.Event{Name: filepath.Join(root, "sub", "x.log"), Op: fsnotify.Write}, nil},
+		{"excluded dir", fsnotify.Event{Name: filepath.Join(root, "node_modules"), Op: fsnotify.Create}, nil},
+		{"outside root", fsnotify.Event{Name: filepath.Join(filepath.Dir(root), "other.txt"), Op: fsnotify.Write}, nil},
+		{"root", fsnotify.Event{Name: root, Op: fsnotify.Write}, nil},
+		{"create dir", fsnotify.Event{Name: filepath.Join(root, "sub"), Op: fsnotify.Create}, &wshrpc.FileWatchChange{Path: "sub", Op: wshrpc.FileWatchOp_Create, IsDir: true}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := fw.makeChange(tc.event)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+
+	// created directories are watched with their subdirectories, excluded ones are not
+	for _, dir := range []string{filepath.Join(root, "sub"), filepath.Join(root, "sub", "deep")} {
+		if !fw.dirs[dir] {
+			t.Errorf("%s is not watched after it was created", dir)
+		}
+	}
+	if fw.dirs[filepath.Join(root, "node_modules")] {
+		t.Errorf("excluded directory is watched")
+	}
+	change := fw.makeChange(fsnotify.Event{Name: filepath.Join(root, "sub"), Op: fsnotify.Remove})
+	if change == nil || change.Op != wshrpc.FileWatchOp_Remove || !change.IsDir {
+		t.Fatalf("got %+v for a removed directory", change)
+	}
+	if fw.dirs[filepath.Join(root, "sub")] {
+		t.Errorf("removed directory is still watched")
+	}
+}
+
+func TestMakeChangeFilters(t *testing.T) {
+	fw, root := makeTestWatcher(t, false, []string{"*.go"}, nil)
+	mkdirTest(t, filepath.Join(root, "pkg"))
+	if got := fw.makeChange(fsnotify.Event{Name: filepath.Join(root, "main.go"), Op: fsnotify.Write}); got == nil {
+		t.Errorf("included file was filtered out")
+	}
+	if got := fw.makeChange(fsnotify.Event{Name: filepath.Join(root, "README.md"), Op: fsnotify.Write}); got != nil {
+		t.Errorf("got %+v for a file that is not included", got)
+	}
+	// includes only apply to files, and directories are not watched when not recursive
+	got := fw.makeChange(fsnotify.Event{Name: filepath.Join(root, "pkg"), Op: fsnotify.Create})
+	if got == nil || !got.IsDir {
+		t.Errorf("got %+v for a created directory", got)
+	}
+	if fw.dirs[filepath.Join(root, "pkg")] {
+		t.Errorf("directory is watched without recursive")
+	}
+
+	// watching a single file only reports that file
+	fw.fileName = "main.go"
+	if got := fw.makeChange(fsnotify.Event{Name: filepath.Join(root, "other.go"), Op: fsnotify.Write}); got != nil {
+		t.Errorf("got %+v for another file when watching a single file", got)
+	}
+	if got := fw.makeChange(fsnotify.Event{Name: filepath.Join(root, "main.go"), Op: fsnotify.Write}); got == nil {
+		t.Errorf("watched file was filtered out")
+	}
+}
+
+func TestWatchBatch(t *testing.T) {
+	debounce := 100 * time.Millisecond
+	start := time.Now()
+	var batch watchBatch
+	batch.add(wshrpc.FileWatchChange{Path: "a", Op: wshrpc.FileWatchOp_Create}, start)
+	batch.add(wshrpc.FileWatchChange{Path: "a", Op: wshrpc.FileWatchOp_Write}, start)
+	batch.add(wshrpc.FileWatchChange{Path: "b", Op: wshrpc.FileWatchOp_Write}, start)
+	batch.add(wshrpc.FileWatchChange{Path: "b", Op: wshrpc.FileWatchOp_Remove}, start)
+	batch.add(wshrpc.FileWatchChange{Path: "c", Op: wshrpc.FileWatchOp_Write}, start)
+	batch.add(wshrpc.FileWatchChange{Path: "c", Op: wshrpc.FileWatchOp_Write}, start)
+
+	// constant changes do not hold the batch back forever
+	if batch.due(start.Add(maxWatchDelayFactor*debounce-time.Millisecond), debounce) {
+		t.Errorf("batch is due before %d debounce periods", maxWatchDelayFactor)
+	}
+	if !batch.due(start.Add(maxWatchDelayFactor*debounce), debounce) {
+		t.Errorf("batch is not due after %d debounce periods", maxWatchDelayFactor)
+	}
+
+	want := []wshrpc.FileWatchChange{
+		{Path: "a", Op: wshrpc.FileWatchOp_Create},
+		{Path: "b", Op: wshrpc.FileWatchOp_Remove},
+		{Path: "c", Op: wshrpc.FileWatchOp_Write},
+	}
+	if got := batch.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got := batch.take(); len(got) != 0 {
+		t.Fatalf("got %+v after take, want nothing", got)
+	}
+	if batch.due(start.Add(time.Hour), debounce) {
+		t.Errorf("empty batch is due")
+	}
+
+	// the delay restarts with the next batch
+	later := start.Add(time.Hour)
+	batch.add(wshrpc.FileWatchChange{Path: "a", Op: wshrpc.FileWatchOp_Write}, later)
+	if batch.due(later.Add(debounce), debounce) {
+		t.Errorf("new batch is due right away")
+	}
+}
+
+func TestRemoteFileWatchCommand(t *testing.T) {
+	root := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	impl := &ServerImpl{}
+	uri := (&connparse.Connection{Scheme: connparse.ConnectionTypeWsh, Host: "local", Path: filepath.ToSlash(root)}).GetFullURI()
+	ch := impl.RemoteFileWatchCommand(ctx, wshrpc.CommandFileWatchData{Uri: uri, Opts: &wshrpc.FileWatchOpts{Recursive: true, DebounceMs: 50}})
+	next := func() wshrpc.FileWatchEvent {
+		t.Helper()
+		select {
+		case resp, ok := <-ch:
+			if !ok {
+				t.Fatalf("watch ended")
+			}
+			if resp.Error != nil {
+				t.Fatalf("watch error: %v", resp.Error)
+			}
+			return resp.Response
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for watch event")
+		}
+		return wshrpc.FileWatchEvent{}
+	}
+	if event := next(); !event.Ready {
+		t.Fatalf("got %+v, want ready", event)
+	}
+
+	mkdirTest(t, filepath.Join(root, "sub"))
+	event := next()
+	if !reflect.DeepEqual(event.Changes, []wshrpc.FileWatchChange{{Path: "sub", Op: wshrpc.FileWatchOp_Create, IsDir: true}}) {
+		t.Fatalf("got %+v, want the created directory", event.Changes)
+	}
+	// the new directory is watched
+	if err := os.WriteFile(filepath.Join(root, "sub", "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	event = next()
+	if !reflect.DeepEqual(event.Changes, []wshrpc.FileWatchChange{{Path: "sub/a.txt", Op: wshrpc.FileWatchOp_Create}}) {
+		t.Fatalf("got %+v, want a single create", event.Changes)
+	}
+}
@@ -17,11 +17,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/commandlinedev/starterm/pkg/panichandler"
 	"github.com/commandlinedev/starterm/pkg/remote/connparse"
 	"github.com/commandlinedev/starterm/pkg/remote/fileshare/fstype"
 	"github.com/commandlinedev/starterm/pkg/remote/fileshare/wshfs"
 	"github.com/commandlinedev/starterm/pkg/starbase"
 	"github.com/commandlinedev/starterm/pkg/suggestion"
+	"github.com/commandlinedev/starterm/pkg/util/archiveutil"
 	"github.com/commandlinedev/starterm/pkg/util/fileutil"
 	"github.com/commandlinedev/starterm/pkg/util/iochan/iochantypes"
 	"github.com/commandlinedev/starterm/pkg/util/tarcopy"
@@ -31,6 +33,9 @@ import (
 	"github.com/commandlinedev/starterm/pkg/wshutil"
 )
 
+// the server waits 5s for RemoteUpdateJwtCommand, which waits for the re-authentication
+const RemoteUpdateJwtTimeout = 3000
+
 type ServerImpl struct {
 	LogWriter io.Writer
 }
@@ -135,12 +140,19 @@ func (impl *ServerImpl) remoteStreamFileRegular(ctx context.Context, path string
 		return fmt.Errorf("cannot open file %q: %w", path, err)
 	}
 	defer utilfn.GracefulClose(fd, "remoteStreamFileRegular", path)
-	var filePos int64
 	if !byteRange.All && byteRange.Start > 0 {
 		_, err := fd.Seek(byteRange.Start, io.SeekStart)
 		if err != nil {
 			return fmt.Errorf("seeking file %q: %w", path, err)
 		}
+	}
+	return streamReaderData(ctx, path, fd, byteRange, dataCallback)
+}
+
+// sends the data of r in chunks, r must already be positioned at the start of the byte range
+func streamReaderData(ctx context.Context, path string, r io.Reader, byteRange ByteRangeType, dataCallback func(fileInfo []*wshrpc.FileInfo, data []byte, byteRange ByteRangeType)) error {
+	var filePos int64
+	if !byteRange.All {
 		filePos = byteRange.Start
 	}
 	buf := make([]byte, wshrpc.FileChunkSize)
@@ -148,7 +160,7 @@ func (impl *ServerImpl) remoteStreamFileRegular(ctx context.Context, path string
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
-		n, err := fd.Read(buf)
+		n, err := r.Read(buf)
 		if n > 0 {
 			if !byteRange.All && filePos+int64(n) > byteRange.End {
 				n = int(byteRange.End - filePos)
@@ -174,6 +186,17 @@ func (impl *ServerImpl) remoteStreamFileInternal(ctx context.Context, data wshrp
 	if err != nil {
 		return err
 	}
+	if archivePath, member, ok := splitArchivePath(data.Path); ok {
+		finfo, err := archiveFileInfo(archivePath, member)
+		if err != nil {
+			return fmt.Errorf("cannot stat file %q: %w", data.Path, err)
+		}
+		dataCallback([]*wshrpc.FileInfo{finfo}, nil, byteRange)
+		if finfo.NotFound {
+			return nil
+		}
+		return impl.remoteStreamArchive(ctx, archivePath, member, finfo.IsDir, byteRange, dataCallback)
+	}
 	path, err := starbase.ExpandHomeDir(data.Path)
 	if err != nil {
 		return err
@@ -229,6 +252,9 @@ func (impl *ServerImpl) RemoteTarStreamCommand(ctx context.Context, data wshrpc.
 		opts = &wshrpc.FileCopyOpts{}
 	}
 	log.Printf("RemoteTarStreamCommand: path=%s\n", path)
+	if _, _, ok := splitArchivePath(path); ok {
+		return wshutil.SendErrCh[iochantypes.Packet](fmt.Errorf("cannot copy %q: %w", path, errArchiveCopy))
+	}
 	srcHasSlash := strings.HasSuffix(path, "/")
 	path, err := starbase.ExpandHomeDir(path)
 	if err != nil {
@@ -253,7 +279,7 @@ func (impl *ServerImpl) RemoteTarStreamCommand(ctx context.Context, data wshrpc.
 		timeout = time.Duration(opts.Timeout) * time.Millisecond
 	}
 	readerCtx, cancel := context.WithTimeout(ctx, timeout)
-	rtn, writeHeader, fileWriter, tarClose := tarcopy.TarCopySrc(readerCtx, pathPrefix)
+	rtn, writeHeader, fileWriter, tarClose := tarcopy.TarCopySrcCompressed(readerCtx, pathPrefix, opts.Compression)
 
 	go func() {
 		defer func() {
@@ -267,20 +293,46 @@ func (impl *ServerImpl) RemoteTarStreamCommand(ctx context.Context, data wshrpc.
 			if err != nil {
 				return err
 			}
-			if err = writeHeader(info, path, singleFile); err != nil {
+			if info.IsDir() {
+				return writeHeader(info, path, singleFile, nil)
+			}
+			var records map[string]string
+			var offset int64
+			if info.Mode().IsRegular() {
+				records, offset = resumeRecords(path, pathPrefix, info, singleFile, opts.Checksum, data.Resume)
+			}
+			if records[tarcopy.Skipped] == "true" {
+				return writeHeader(info, path, singleFile, records)
+			}
+			file, err := os.Open(path)
+			if err != nil {
 				return err
 			}
-			// if not a dir, write file content
-			if !info.IsDir() {
-				data, err := os.Open(path)
+			defer utilfn.GracefulClose(file, "RemoteTarStreamCommand", path)
+			if opts.Checksum && info.Mode().IsRegular() && records[tarcopy.FileChecksum] == "" {
+				// hashed up front so the checksum can go in the header, the file is read again below (--no-checksum skips this)
+				checksum, err := tarcopy.ChecksumFile(file)
 				if err != nil {
+					return fmt.Errorf("cannot checksum file %q: %w", path, err)
+				}
+				if records == nil {
+					records = make(map[string]string)
+				}
+				records[tarcopy.FileChecksum] = checksum
+				if _, err := file.Seek(offset, io.SeekStart); err != nil {
 					return err
 				}
-				defer utilfn.GracefulClose(data, "RemoteTarStreamCommand", path)
-				if _, err := io.Copy(fileWriter, data); err != nil {
+			} else if offset > 0 {
+				if _, err := file.Seek(offset, io.SeekStart); err != nil {
 					return err
 				}
 			}
+			if err = writeHeader(info, path, singleFile, records); err != nil {
+				return err
+			}
+			if _, err := io.Copy(fileWriter, file); err != nil {
+				return err
+			}
 			return nil
 		}
 		log.Printf("RemoteTarStreamCommand: starting\n")
@@ -300,6 +352,39 @@ func (impl *ServerImpl) RemoteTarStreamCommand(ctx context.Context, data wshrpc.
 }
 
 func (impl *ServerImpl) RemoteFileCopyCommand(ctx context.Context, data wshrpc.CommandFileCopyData) (bool, error) {
+	return impl.remoteFileCopy(ctx, data, nil)
+}
+
+// same as RemoteFileCopyCommand, but streams the progress of the copy (the last update has Done set)
+func (impl *ServerImpl) RemoteFileCopyStreamCommand(ctx context.Context, data wshrpc.CommandFileCopyData) chan wshrpc.RespOrErrorUnion[wshrpc.FileCopyProgress] {
+	ch := make(chan wshrpc.RespOrErrorUnion[wshrpc.FileCopyProgress], 16)
+	go func() {
+		defer close(ch)
+		defer func() {
+			if panicErr := panichandler.PanicHandler("RemoteFileCopyStreamCommand", recover()); panicErr != nil {
+				select {
+				case ch <- wshutil.RespErr[wshrpc.FileCopyProgress](panicErr):
+				case <-ctx.Done():
+				}
+			}
+		}()
+		_, err := impl.remoteFileCopy(ctx, data, func(progress wshrpc.FileCopyProgress) {
+			select {
+			case ch <- wshrpc.RespOrErrorUnion[wshrpc.FileCopyProgress]{Response: progress}:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil {
+			select {
+			case ch <- wshutil.RespErr[wshrpc.FileCopyProgress](err):
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return ch
+}
+
+func (impl *ServerImpl) remoteFileCopy(ctx context.Context, data wshrpc.CommandFileCopyData, progressFn func(wshrpc.FileCopyProgress)) (bool, error) {
 	log.Printf("RemoteFileCopyCommand: src=%s, dest=%s\n", data.SrcUri, data.DestUri)
 	opts := data.Opts
 	if opts == nil {
@@ -309,6 +394,7 @@ func (impl *ServerImpl) RemoteFileCopyCommand(ctx context.Context, data wshrpc.C
 	srcUri := data.SrcUri
 	merge := opts.Merge
 	overwrite := opts.Overwrite
+	resume := opts.Resume
 	if overwrite && merge {
 		return false, fmt.Errorf("cannot specify both overwrite and merge")
 	}
@@ -317,6 +403,9 @@ func (impl *ServerImpl) RemoteFileCopyCommand(ctx context.Context, data wshrpc.C
 	if err != nil {
 		return false, fmt.Errorf("cannot parse destination URI %q: %w", destUri, err)
 	}
+	if err := checkNotInArchive(destConn.Path); err != nil {
+		return false, err
+	}
 	destPathCleaned := filepath.Clean(starbase.ExpandHomeDirSafe(destConn.Path))
 	destinfo, err := os.Stat(destPathCleaned)
 	if err != nil {
@@ -329,7 +418,8 @@ func (impl *ServerImpl) RemoteFileCopyCommand(ctx context.Context, data wshrpc.C
 	destIsDir := destExists && destinfo.IsDir()
 	destHasSlash := strings.HasSuffix(destUri, "/")
 
-	if destExists && !destIsDir {
+	// when resuming, a matching destination file is skipped rather than replaced
+	if destExists && !destIsDir && !resume {
 		if !overwrite {
 			return false, fmt.Errorf(fstype.OverwriteRequiredError, destPathCleaned)
 		} else {
@@ -343,11 +433,15 @@ func (impl *ServerImpl) RemoteFileCopyCommand(ctx context.Context, data wshrpc.C
 	if err != nil {
 		return false, fmt.Errorf("cannot parse source URI %q: %w", srcUri, err)
 	}
+	if _, _, ok := splitArchivePath(srcConn.Path); ok && srcConn.Host == destConn.Host {
+		return false, fmt.Errorf("cannot copy %q: %w", srcUri, errArchiveCopy)
+	}
 
-	copyFileFunc := func(path string, finfo fs.FileInfo, srcFile io.Reader) (int64, error) {
+	// checks the destination against overwrite/merge and creates the directories.  returns the path to write to ("" for a directory)
+	prepareDestFunc := func(path string, finfo fs.FileInfo) (string, error) {
 		nextinfo, err := os.Stat(path)
 		if err != nil && !errors.Is(err, fs.ErrNotExist) {
-			return 0, fmt.Errorf("cannot stat file %q: %w", path, err)
+			return "", fmt.Errorf("cannot stat file %q: %w", path, err)
 		}
 
 		if nextinfo != nil {
@@ -357,26 +451,28 @@ func (impl *ServerImpl) RemoteFileCopyCommand(ctx context.Context, data wshrpc.C
 					path = filepath.Join(path, filepath.Base(finfo.Name()))
 					newdestinfo, err := os.Stat(path)
 					if err != nil && !errors.Is(err, fs.ErrNotExist) {
-						return 0, fmt.Errorf("cannot stat file %q: %w", path, err)
+						return "", fmt.Errorf("cannot stat file %q: %w", path, err)
 					}
 					if newdestinfo != nil && !overwrite {
-						return 0, fmt.Errorf(fstype.OverwriteRequiredError, path)
+						return "", fmt.Errorf(fstype.OverwriteRequiredError, path)
 					}
+				} else if resume {
+					// resuming always merges into the directories of the earlier copy
 				} else if overwrite {
 					err := os.RemoveAll(path)
 					if err != nil {
-						return 0, fmt.Errorf("cannot remove directory %q: %w", path, err)
+						return "", fmt.Errorf("cannot remove directory %q: %w", path, err)
 					}
 				} else if !merge {
-					return 0, fmt.Errorf(fstype.MergeRequiredError, path)
+					return "", fmt.Errorf(fstype.MergeRequiredError, path)
 				}
 			} else {
 				if !overwrite {
-					return 0, fmt.Errorf(fstype.OverwriteRequiredError, path)
+					return "", fmt.Errorf(fstype.OverwriteRequiredError, path)
 				} else if finfo.IsDir() {
 					err := os.RemoveAll(path)
 					if err != nil {
-						return 0, fmt.Errorf("cannot remove directory %q: %w", path, err)
+						return "", fmt.Errorf("cannot remove directory %q: %w", path, err)
 					}
 				}
 			}
@@ -385,16 +481,23 @@ func (impl *ServerImpl) RemoteFileCopyCommand(ctx context.Context, data wshrpc.C
 		if finfo.IsDir() {
 			err := os.MkdirAll(path, finfo.Mode())
 			if err != nil {
-				return 0, fmt.Errorf("cannot create directory %q: %w", path, err)
+				return "", fmt.Errorf("cannot create directory %q: %w", path, err)
 			}
-			return 0, nil
+			return "", nil
 		} else {
 			err := os.MkdirAll(filepath.Dir(path), 0755)
 			if err != nil {
-				return 0, fmt.Errorf("cannot create parent directory %q: %w", filepath.Dir(path), err)
+				return "", fmt.Errorf("cannot create parent directory %q: %w", filepath.Dir(path), err)
 			}
 		}
+		return path, nil
+	}
 
+	copyFileFunc := func(path string, finfo fs.FileInfo, srcFile io.Reader) (int64, error) {
+		path, err := prepareDestFunc(path, finfo)
+		if err != nil || path == "" {
+			return 0, err
+		}
 		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, finfo.Mode())
 		if err != nil {
 			return 0, fmt.Errorf("cannot create new file %q: %w", path, err)
@@ -408,6 +511,72 @@ func (impl *ServerImpl) RemoteFileCopyCommand(ctx context.Context, data wshrpc.C
 		return finfo.Size(), nil
 	}
 
+	progress := makeCopyProgress(progressFn)
+
+	// regular files from a tar stream are written to a partial file that is renamed into place once the entry has been read (and its checksum verified)
+	copyEntryFunc := func(path string, next *tar.Header, reader io.Reader) error {
+		finfo := next.FileInfo()
+		if tarcopy.IsSkipped(next) {
+			progress.progress.NumSkipped++
+			progress.send(false)
+			return nil
+		}
+		if !finfo.Mode().IsRegular() {
+			_, err := copyFileFunc(path, finfo, reader)
+			return err
+		}
+		path, err := prepareDestFunc(path, finfo)
+		if err != nil {
+			return err
+		}
+		partPath := path + PartialFileSuffix
+		offset := tarcopy.ResumeOffsetOf(next)
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if offset > 0 {
+			partInfo, err := os.Stat(partPath)
+			if err != nil || partInfo.Size() != offset {
+				return fmt.Errorf("cannot resume %q, the partial file changed", path)
+			}
+			partFile, err := os.Open(partPath)
+			if err != nil {
+				return fmt.Errorf("cannot open partial file %q: %w", partPath, err)
+			}
+			err = tarcopy.SeedChecksum(reader, partFile)
+			utilfn.GracefulClose(partFile, "RemoteFileCopyCommand", partPath)
+			if err != nil {
+				return fmt.Errorf("cannot read partial file %q: %w", partPath, err)
+			}
+			flags = os.O_WRONLY | os.O_APPEND
+			progress.progress.NumResumed++
+		}
+		file, err := os.OpenFile(partPath, flags, finfo.Mode())
+		if err != nil {
+			return fmt.Errorf("cannot create new file %q: %w", partPath, err)
+		}
+		progress.startFile(next.Name, offset+next.Size, offset)
+		_, err = io.Copy(file, io.TeeReader(reader, progress))
+		closeErr := file.Close()
+		if err != nil {
+			if errors.Is(err, tarcopy.ErrChecksumMismatch) {
+				// resuming from a bad partial file would fail the same way
+				os.Remove(partPath)
+			}
+			return fmt.Errorf("cannot write file %q: %w", path, err)
+		}
+		if closeErr != nil {
+			return fmt.Errorf("cannot write file %q: %w", path, closeErr)
+		}
+		if err := os.Rename(partPath, path); err != nil {
+			return fmt.Errorf("cannot rename partial file %q: %w", partPath, err)
+		}
+		// keep the mod time so a resumed copy can tell the file is complete
+		if err := os.Chtimes(path, next.ModTime, next.ModTime); err != nil {
+			log.Printf("RemoteFileCopyCommand: cannot set mod time of %q: %v\n", path, err)
+		}
+		progress.finishFile()
+		return nil
+	}
+
 	srcIsDir := false
 	if srcConn.Host == destConn.Host {
 		srcPathCleaned := filepath.Clean(starbase.ExpandHomeDirSafe(srcConn.Path))
@@ -439,7 +608,11 @@ func (impl *ServerImpl) RemoteFileCopyCommand(ctx context.Context, data wshrpc.C
 					}
 					defer utilfn.GracefulClose(file, "RemoteFileCopyCommand", srcFilePath)
 				}
-				_, err = copyFileFunc(destFilePath, info, file)
+				n, err := copyFileFunc(destFilePath, info, file)
+				if err == nil && !info.IsDir() {
+					progress.progress.TotalBytes += n
+					progress.finishFile()
+				}
 				return err
 			})
 			if err != nil {
@@ -457,52 +630,60 @@ func (impl *ServerImpl) RemoteFileCopyCommand(ctx context.Context, data wshrpc.C
 			} else {
 				destFilePath = destPathCleaned
 			}
-			_, err = copyFileFunc(destFilePath, srcFileStat, file)
+			n, err := copyFileFunc(destFilePath, srcFileStat, file)
 			if err != nil {
 				return false, fmt.Errorf("cannot copy %q to %q: %w", srcUri, destUri, err)
 			}
+			progress.progress.TotalBytes += n
+			progress.finishFile()
 		}
 	} else {
 		timeout := fstype.DefaultTimeout
 		if opts.Timeout > 0 {
 			timeout = time.Duration(opts.Timeout) * time.Millisecond
 		}
+		streamData := wshrpc.CommandRemoteStreamTarData{Path: srcUri, Opts: opts}
+		if resume {
+			srcBase := ""
+			if !strings.HasSuffix(srcConn.Path, "/") {
+				srcBase = filepath.Base(srcConn.Path)
+			}
+			streamData.Resume, err = buildResumeManifest(destPathCleaned, srcBase, opts.Checksum)
+			if err != nil {
+				return false, fmt.Errorf("cannot resume copy to %q: %w", destUri, err)
+			}
+		}
 		readCtx, cancel := context.WithCancelCause(ctx)
 		readCtx, timeoutCancel := context.WithTimeoutCause(readCtx, timeout, fmt.Errorf("timeout copying file %q to %q", srcUri, destUri))
 		defer timeoutCancel()
 		copyStart := time.Now()
-		ioch := wshclient.FileStreamTarCommand(wshfs.RpcClient, wshrpc.CommandRemoteStreamTarData{Path: srcUri, Opts: opts}, &wshrpc.RpcOpts{Timeout: opts.Timeout})
-		numFiles := 0
-		numSkipped := 0
-		totalBytes := int64(0)
+		ioch := wshclient.FileStreamTarCommand(wshfs.RpcClient, streamData, &wshrpc.RpcOpts{Timeout: opts.Timeout})
 
-		err := tarcopy.TarCopyDest(readCtx, cancel, ioch, func(next *tar.Header, reader *tar.Reader, singleFile bool) error {
-			numFiles++
+		err := tarcopy.TarCopyDest(readCtx, cancel, ioch, func(next *tar.Header, reader io.Reader, singleFile bool) error {
 			nextpath := filepath.Join(destPathCleaned, next.Name)
 			srcIsDir = !singleFile
 			if singleFile && !destHasSlash {
 				// custom flag to indicate that the source is a single file, not a directory the contents of a directory
 				nextpath = destPathCleaned
 			}
-			finfo := next.FileInfo()
-			n, err := copyFileFunc(nextpath, finfo, reader)
+			err := copyEntryFunc(nextpath, next, reader)
 			if err != nil {
 				return fmt.Errorf("cannot copy file %q: %w", next.Name, err)
 			}
-			totalBytes += n
 			return nil
 		})
 		if err != nil {
 			return false, fmt.Errorf("cannot copy %q to %q: %w", srcUri, destUri, err)
 		}
 		totalTime := time.Since(copyStart).Seconds()
-		totalMegaBytes := float64(totalBytes) / 1024 / 1024
+		totalMegaBytes := float64(progress.progress.TotalBytes) / 1024 / 1024
 		rate := float64(0)
 		if totalTime > 0 {
 			rate = totalMegaBytes / totalTime
 		}
-		log.Printf("RemoteFileCopyCommand: done; %d files copied in %.3fs, total of %.4f MB, %.2f MB/s, %d files skipped\n", numFiles, totalTime, totalMegaBytes, rate, numSkipped)
+		log.Printf("RemoteFileCopyCommand: done; %d files copied in %.3fs, total of %.4f MB, %.2f MB/s, %d files skipped, %d resumed\n", progress.progress.NumFiles, totalTime, totalMegaBytes, rate, progress.progress.NumSkipped, progress.progress.NumResumed)
 	}
+	progress.done(srcIsDir)
 	return srcIsDir, nil
 }
 
@@ -520,6 +701,10 @@ func (impl *ServerImpl) RemoteListEntriesCommand(ctx context.Context, data wshrp
 		if data.Opts.Limit == 0 {
 			data.Opts.Limit = wshrpc.MaxDirSize
 		}
+		if archivePath, member, ok := archiveListPath(data.Path); ok {
+			listArchiveEntries(ctx, archivePath, member, data.Opts, ch)
+			return
+		}
 		if data.Opts.All {
 			fs.WalkDir(os.DirFS(path), ".", func(path string, d fs.DirEntry, err error) error {
 				defer func() {
@@ -631,6 +816,9 @@ func computeDirPart(path string) string {
 }
 
 func (*ServerImpl) fileInfoInternal(path string, extended bool) (*wshrpc.FileInfo, error) {
+	if archivePath, member, ok := splitArchivePath(path); ok {
+		return archiveFileInfo(archivePath, member)
+	}
 	cleanedPath := filepath.Clean(starbase.ExpandHomeDirSafe(path))
 	finfo, err := os.Stat(cleanedPath)
 	if os.IsNotExist(err) {
@@ -648,6 +836,13 @@ func (*ServerImpl) fileInfoInternal(path string, extended bool) (*wshrpc.FileInf
 	rtn := statToFileInfo(cleanedPath, finfo, extended)
 	if extended {
 		rtn.ReadOnly = checkIsReadOnly(cleanedPath, finfo, true)
+		if finfo.Mode().IsRegular() {
+			etag, err := fileutil.ETag(cleanedPath, finfo, true)
+			if err != nil {
+				log.Printf("cannot compute etag of %q: %v\n", cleanedPath, err)
+			}
+			rtn.ETag = etag
+		}
 	}
 	return rtn, nil
 }
@@ -656,13 +851,17 @@ func resolvePaths(paths []string) string {
 	if len(paths) == 0 {
 		return starbase.ExpandHomeDirSafe("~")
 	}
-	rtnPath := starbase.ExpandHomeDirSafe(paths[0])
+	rtnPath := expandArchivePath(paths[0])
 	for _, path := range paths[1:] {
-		path = starbase.ExpandHomeDirSafe(path)
+		path = expandArchivePath(path)
 		if filepath.IsAbs(path) {
 			rtnPath = path
 			continue
 		}
+		if archivePath, member, ok := archiveutil.SplitPath(rtnPath); ok {
+			rtnPath = archiveutil.JoinPath(archivePath, archiveutil.CleanMember(member+"/"+filepath.ToSlash(path)))
+			continue
+		}
 		rtnPath = filepath.Join(rtnPath, path)
 	}
 	return rtnPath
@@ -678,6 +877,9 @@ func (impl *ServerImpl) RemoteFileInfoCommand(ctx context.Context, path string)
 }
 
 func (impl *ServerImpl) RemoteFileTouchCommand(ctx context.Context, path string) error {
+	if err := checkNotInArchive(path); err != nil {
+		return err
+	}
 	cleanedPath := filepath.Clean(starbase.ExpandHomeDirSafe(path))
 	if _, err := os.Stat(cleanedPath); err == nil {
 		return fmt.Errorf("file %q already exists", path)
@@ -722,6 +924,9 @@ func (impl *ServerImpl) RemoteFileMoveCommand(ctx context.Context, data wshrpc.C
 	if err != nil {
 		return fmt.Errorf("cannot parse source URI %q: %w", srcUri, err)
 	}
+	if err := checkNotInArchive(srcConn.Path, destConn.Path); err != nil {
+		return err
+	}
 	if srcConn.Host == destConn.Host {
 		srcPathCleaned := filepath.Clean(starbase.ExpandHomeDirSafe(srcConn.Path))
 		finfo, err := os.Stat(srcPathCleaned)
@@ -742,6 +947,9 @@ func (impl *ServerImpl) RemoteFileMoveCommand(ctx context.Context, data wshrpc.C
 }
 
 func (impl *ServerImpl) RemoteMkdirCommand(ctx context.Context, path string) error {
+	if err := checkNotInArchive(path); err != nil {
+		return err
+	}
 	cleanedPath := filepath.Clean(starbase.ExpandHomeDirSafe(path))
 	if stat, err := os.Stat(cleanedPath); err == nil {
 		if stat.IsDir() {
@@ -755,12 +963,68 @@ func (impl *ServerImpl) RemoteMkdirCommand(ctx context.Context, path string) err
 	}
 	return nil
 }
+
+// fails with a conflict error unless the file at path has the etag ifMatch (a missing file has the etag "")
+func checkETag(path string, ifMatch string) error {
+	etag, err := fileutil.ETag(path, nil, false)
+	if errors.Is(err, fs.ErrNotExist) {
+		etag = ""
+	} else if err != nil {
+		return err
+	}
+	if etag != ifMatch {
+		return fmt.Errorf(fstype.ConflictError, path, etag, ifMatch)
+	}
+	return nil
+}
+
+// writes in place, at atOffset or at the end of the file
+func writeFileData(path string, dataBytes []byte, truncate bool, append bool, atOffset int64, createMode os.FileMode) error {
+	finfo, err := os.Stat(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("cannot stat file %q: %w", path, err)
+	}
+	fileSize := int64(0)
+	if finfo != nil {
+		fileSize = finfo.Size()
+	}
+	if atOffset > fileSize {
+		return fmt.Errorf("cannot write at offset %d, file size is %d", atOffset, fileSize)
+	}
+	openFlags := os.O_CREATE | os.O_WRONLY
+	if truncate {
+		openFlags |= os.O_TRUNC
+	}
+	if append {
+		openFlags |= os.O_APPEND
+	}
+
+	file, err := os.OpenFile(path, openFlags, createMode)
+	if err != nil {
+		return fmt.Errorf("cannot open file %q: %w", path, err)
+	}
+	defer utilfn.GracefulClose(file, "RemoteWriteFileCommand", path)
+	if atOffset > 0 && !append {
+		_, err = file.WriteAt(dataBytes, atOffset)
+	} else {
+		_, err = file.Write(dataBytes)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write to file %q: %w", path, err)
+	}
+	return nil
+}
+
 func (*ServerImpl) RemoteWriteFileCommand(ctx context.Context, data wshrpc.FileData) error {
-	var truncate, append bool
+	var truncate, append, commit bool
+	var ifMatch, stageId string
 	var atOffset int64
 	if data.Info != nil && data.Info.Opts != nil {
 		truncate = data.Info.Opts.Truncate
 		append = data.Info.Opts.Append
+		ifMatch = data.Info.Opts.IfMatch
+		stageId = data.Info.Opts.StageId
+		commit = data.Info.Opts.Commit
 	}
 	if data.At != nil {
 		atOffset = data.At.Offset
@@ -771,10 +1035,16 @@ func (*ServerImpl) RemoteWriteFileCommand(ctx context.Context, data wshrpc.FileD
 	if append && atOffset > 0 {
 		return fmt.Errorf("cannot specify non-zero offset with append option")
 	}
+	if commit && stageId == "" {
+		return fmt.Errorf("cannot commit a write without a stage id")
+	}
 	path, err := starbase.ExpandHomeDir(data.Info.Path)
 	if err != nil {
 		return err
 	}
+	if err := checkNotInArchive(data.Info.Path); err != nil {
+		return err
+	}
 	createMode := os.FileMode(0644)
 	if data.Info != nil && data.Info.Mode > 0 {
 		createMode = data.Info.Mode
@@ -785,39 +1055,40 @@ func (*ServerImpl) RemoteWriteFileCommand(ctx context.Context, data wshrpc.FileD
 	if err != nil {
 		return fmt.Errorf("cannot decode base64 data: %w", err)
 	}
-	finfo, err := os.Stat(path)
-	if err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("cannot stat file %q: %w", path, err)
-	}
-	fileSize := int64(0)
-	if finfo != nil {
-		fileSize = finfo.Size()
+	if stageId == "" {
+		if ifMatch != "" {
+			if err := checkETag(path, ifMatch); err != nil {
+				return err
+			}
+		}
+		if truncate && !append {
+			// whole file writes replace the file, so readers never see a partly written file
+			return fileutil.WriteFileAtomic(path, dataBytes[:n], createMode)
+		}
+		return writeFileData(path, dataBytes[:n], truncate, append, atOffset, createMode)
 	}
-	if atOffset > fileSize {
-		return fmt.Errorf("cannot write at offset %d, file size is %d", atOffset, fileSize)
+	stagePath, err := fileutil.StageFilePath(path, stageId)
+	if err != nil {
+		return err
 	}
-	openFlags := os.O_CREATE | os.O_WRONLY
 	if truncate {
-		openFlags |= os.O_TRUNC
-	}
-	if append {
-		openFlags |= os.O_APPEND
+		fileutil.RemoveStaleStageFiles(path)
+	} else if _, err := os.Stat(stagePath); err != nil {
+		return fmt.Errorf("stage %q of %q was not started (or was already committed): %w", stageId, path, err)
 	}
-
-	file, err := os.OpenFile(path, openFlags, createMode)
-	if err != nil {
-		return fmt.Errorf("cannot open file %q: %w", path, err)
+	if err := writeFileData(stagePath, dataBytes[:n], truncate, append, atOffset, 0600); err != nil {
+		return err
 	}
-	defer utilfn.GracefulClose(file, "RemoteWriteFileCommand", path)
-	if atOffset > 0 && !append {
-		n, err = file.WriteAt(dataBytes[:n], atOffset)
-	} else {
-		n, err = file.Write(dataBytes[:n])
+	if !commit {
+		return nil
 	}
-	if err != nil {
-		return fmt.Errorf("cannot write to file %q: %w", path, err)
+	// on a conflict the stage is kept, so the writer can still commit it without the etag
+	if ifMatch != "" {
+		if err := checkETag(path, ifMatch); err != nil {
+			return err
+		}
 	}
-	return nil
+	return fileutil.ReplaceFile(stagePath, path, createMode)
 }
 
 func (*ServerImpl) RemoteFileDeleteCommand(ctx context.Context, data wshrpc.CommandDeleteFileData) error {
@@ -825,6 +1096,9 @@ func (*ServerImpl) RemoteFileDeleteCommand(ctx context.Context, data wshrpc.Comm
 	if err != nil {
 		return fmt.Errorf("cannot delete file %q: %w", data.Path, err)
 	}
+	if err := checkNotInArchive(data.Path); err != nil {
+		return err
+	}
 	cleanedPath := filepath.Clean(expandedPath)
 
 	err = os.Remove(cleanedPath)
@@ -853,6 +1127,42 @@ func (*ServerImpl) RemoteInstallRcFilesCommand(ctx context.Context) error {
 	return wshutil.InstallRcFiles()
 }
 
+// called by the server after the jwt signing key is rotated (and before our token expires).  we cannot verify the signature here (no secret),
+// so we check the claims, only accept a connserver token for our own connection, and re-authenticate with it (the server verifies it)
+// before using it for re-execs of wsh.
+func (*ServerImpl) RemoteUpdateJwtCommand(ctx context.Context, jwtToken string) error {
+	newCtx, err := wshutil.ValidateUnverifiedJwtToken(jwtToken)
+	if err != nil {
+		return fmt.Errorf("invalid jwt token: %w", err)
+	}
+	if newCtx.ClientType != wshrpc.ClientType_ConnServer {
+		return fmt.Errorf("invalid jwt token: expected client type %q, got %q", wshrpc.ClientType_ConnServer, newCtx.ClientType)
+	}
+	wshRpc := wshutil.GetWshRpcFromContext(ctx)
+	if wshRpc == nil {
+		return fmt.Errorf("no rpc client to re-authenticate")
+	}
+	if curConn := wshRpc.GetRpcContext().Conn; curConn != newCtx.Conn {
+		return fmt.Errorf("invalid jwt token: connection mismatch %q != %q", newCtx.Conn, curConn)
+	}
+	authRtn, err := wshclient.AuthenticateCommand(wshRpc, jwtToken, &wshrpc.RpcOpts{Timeout: RemoteUpdateJwtTimeout})
+	if err != nil {
+		return fmt.Errorf("error re-authenticating: %w", err)
+	}
+	if authRtn.AuthToken != "" {
+		wshRpc.SetAuthToken(authRtn.AuthToken)
+	}
+	return os.Setenv(starbase.StarJwtTokenVarName, jwtToken)
+}
+
+func (*ServerImpl) RpcStatsCommand(ctx context.Context, data wshrpc.CommandRpcStatsData) (*wshrpc.RpcStatsData, error) {
+	name := "connserver"
+	if wshRpc := wshutil.GetWshRpcFromContext(ctx); wshRpc != nil && wshRpc.GetRpcContext().Conn != "" {
+		name = wshutil.MakeConnectionRouteId(wshRpc.GetRpcContext().Conn)
+	}
+	return wshutil.GetRpcStats(name, data), nil
+}
+
 func (*ServerImpl) FetchSuggestionsCommand(ctx context.Context, data wshrpc.FetchSuggestionsData) (*wshrpc.FetchSuggestionsResponse, error) {
 	return suggestion.FetchSuggestions(ctx, data)
 }
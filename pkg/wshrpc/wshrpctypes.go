@@ -22,7 +22,7 @@ import (
 )
 
 const (
-	// MaxFileSize is the maximum file size that can be read
+	// MaxFileSize is the maximum file size that can be read at once, larger files can be read in ranges (FileData.At)
 	MaxFileSize = 50 * 1024 * 1024 // 50M
 	// MaxDirSize is the maximum number of entries that can be read in a directory
 	MaxDirSize = 1024
@@ -67,6 +67,11 @@ const (
 	Command_Mkdir             = "mkdir"
 	Command_ResolveIds        = "resolveids"
 	Command_BlockInfo         = "blockinfo"
+	Command_BlockCommands     = "blockcommands"
+	Command_HistorySearch     = "historysearch"
+	Command_HistoryPurge      = "historypurge"
+	Command_TermSearch        = "termsearch"
+	Command_RecordingReplay   = "recordingreplay"
 	Command_CreateBlock       = "createblock"
 	Command_DeleteBlock       = "deleteblock"
 
@@ -75,6 +80,13 @@ const (
 	Command_FileReadStream      = "filereadstream"
 	Command_FileMove            = "filemove"
 	Command_FileCopy            = "filecopy"
+	Command_FileCopyStream      = "filecopystream"
+	Command_FileSync            = "filesync"
+	Command_FileWatch           = "filewatch"
+	Command_FileSearch          = "filesearch"
+	Command_FileTrashList       = "filetrashlist"
+	Command_FileTrashRestore    = "filetrashrestore"
+	Command_FileTrashEmpty      = "filetrashempty"
 	Command_FileStreamTar       = "filestreamtar"
 	Command_FileAppend          = "fileappend"
 	Command_FileAppendIJson     = "fileappendijson"
@@ -89,13 +101,18 @@ const (
 	Command_EventReadHistory     = "eventreadhistory"
 	Command_StreamTest           = "streamtest"
 	Command_StreamStarAi         = "streamstarai"
+	Command_StarAiListModels     = "starailistmodels"
 	Command_StreamCpuData        = "streamcpudata"
 	Command_Test                 = "test"
+	Command_RpcStats             = "rpcstats"
 	Command_SetConfig            = "setconfig"
 	Command_SetConnectionsConfig = "connectionsconfig"
 	Command_GetFullConfig        = "getfullconfig"
 	Command_RemoteStreamFile     = "remotestreamfile"
 	Command_RemoteTarStream      = "remotetarstream"
+	Command_RemoteFileCopyStream = "remotefilecopystream"
+	Command_RemoteFileWatch      = "remotefilewatch"
+	Command_RemoteFileSearch     = "remotefilesearch"
 	Command_RemoteFileInfo       = "remotefileinfo"
 	Command_RemoteFileTouch      = "remotefiletouch"
 	Command_RemoteWriteFile      = "remotewritefile"
@@ -110,6 +127,7 @@ const (
 	Command_RemoteMkdir          = "remotemkdir"
 	Command_RemoteGetInfo        = "remotegetinfo"
 	Command_RemoteInstallRcfiles = "remoteinstallrcfiles"
+	Command_RemoteUpdateJwt      = "remoteupdatejwt"
 
 	Command_ConnStatus       = "connstatus"
 	Command_WslStatus        = "wslstatus"
@@ -123,6 +141,13 @@ const (
 	Command_WslDefaultDistro = "wsldefaultdistro"
 	Command_DismissWshFail   = "dismisswshfail"
 	Command_ConnUpdateWsh    = "updatewsh"
+	Command_ConnForwardAdd   = "connforwardadd"
+	Command_ConnForwardRm    = "connforwardrm"
+
+	Command_RotateJwtKey = "rotatejwtkey"
+
+	Command_ApiTokenCreate = "apitokencreate"
+	Command_ApiTokenDelete = "apitokendelete"
 
 	Command_WorkspaceList = "workspacelist"
 
@@ -177,6 +202,13 @@ type WshRpcInterface interface {
 	FileStreamTarCommand(ctx context.Context, data CommandRemoteStreamTarData) <-chan RespOrErrorUnion[iochantypes.Packet]
 	FileMoveCommand(ctx context.Context, data CommandFileCopyData) error
 	FileCopyCommand(ctx context.Context, data CommandFileCopyData) error
+	FileCopyStreamCommand(ctx context.Context, data CommandFileCopyData) <-chan RespOrErrorUnion[FileCopyProgress]
+	FileSyncCommand(ctx context.Context, data CommandFileSyncData) <-chan RespOrErrorUnion[FileSyncProgress]
+	FileWatchCommand(ctx context.Context, data CommandFileWatchData) <-chan RespOrErrorUnion[FileWatchEvent]
+	FileSearchCommand(ctx context.Context, data CommandFileSearchData) <-chan RespOrErrorUnion[FileSearchResult]
+	FileTrashListCommand(ctx context.Context, data CommandFileTrashData) ([]*TrashEntry, error)
+	FileTrashRestoreCommand(ctx context.Context, data CommandFileTrashData) error
+	FileTrashEmptyCommand(ctx context.Context, data CommandFileTrashData) (int, error)
 	FileInfoCommand(ctx context.Context, data FileData) (*FileInfo, error)
 	FileListCommand(ctx context.Context, data FileListData) ([]*FileInfo, error)
 	FileJoinCommand(ctx context.Context, paths []string) (*FileInfo, error)
@@ -190,12 +222,19 @@ type WshRpcInterface interface {
 	EventReadHistoryCommand(ctx context.Context, data CommandEventReadHistoryData) ([]*wps.StarEvent, error)
 	StreamTestCommand(ctx context.Context) chan RespOrErrorUnion[int]
 	StreamStarAiCommand(ctx context.Context, request StarAIStreamRequest) chan RespOrErrorUnion[StarAIPacketType]
+	StarAiListModelsCommand(ctx context.Context, data CommandStarAiListModelsData) (*CommandStarAiListModelsRtnData, error)
 	StreamCpuDataCommand(ctx context.Context, request CpuDataRequest) chan RespOrErrorUnion[TimeSeriesData]
 	TestCommand(ctx context.Context, data string) error
 	SetConfigCommand(ctx context.Context, data MetaSettingsType) error
 	SetConnectionsConfigCommand(ctx context.Context, data ConnConfigRequest) error
 	GetFullConfigCommand(ctx context.Context) (sconfig.FullConfigType, error)
 	BlockInfoCommand(ctx context.Context, blockId string) (*BlockInfoData, error)
+	BlockCommandsCommand(ctx context.Context, data CommandBlockCommandsData) ([]BlockCommandRecord, error)
+	HistorySearchCommand(ctx context.Context, data CommandHistorySearchData) ([]HistoryItem, error)
+	HistoryPurgeCommand(ctx context.Context, data CommandHistoryPurgeData) (int, error)
+	TermSearchCommand(ctx context.Context, data CommandTermSearchData) (*CommandTermSearchRtnData, error)
+	RecordingReplayCommand(ctx context.Context, data CommandRecordingReplayData) (*starobj.ORef, error)
+	RpcStatsCommand(ctx context.Context, data CommandRpcStatsData) (*RpcStatsData, error)
 	StarInfoCommand(ctx context.Context) (*StarInfoData, error)
 	WshActivityCommand(ct context.Context, data map[string]int) error
 	ActivityCommand(ctx context.Context, data ActivityUpdate) error
@@ -207,6 +246,9 @@ type WshRpcInterface interface {
 	FetchSuggestionsCommand(ctx context.Context, data FetchSuggestionsData) (*FetchSuggestionsResponse, error)
 	DisposeSuggestionsCommand(ctx context.Context, widgetId string) error
 	GetTabCommand(ctx context.Context, tabId string) (*starobj.Tab, error)
+	RotateJwtKeyCommand(ctx context.Context, data CommandRotateJwtKeyData) (CommandRotateJwtKeyRtnData, error)
+	ApiTokenCreateCommand(ctx context.Context, data CommandApiTokenCreateData) (*CommandApiTokenCreateRtnData, error)
+	ApiTokenDeleteCommand(ctx context.Context, name string) error
 
 	// connection functions
 	ConnStatusCommand(ctx context.Context) ([]ConnStatus, error)
@@ -221,6 +263,8 @@ type WshRpcInterface interface {
 	WslDefaultDistroCommand(ctx context.Context) (string, error)
 	DismissWshFailCommand(ctx context.Context, connName string) error
 	ConnUpdateWshCommand(ctx context.Context, remoteInfo RemoteInfo) (bool, error)
+	ConnForwardAddCommand(ctx context.Context, data CommandConnForwardAddData) (ConnForwardStatus, error)
+	ConnForwardRmCommand(ctx context.Context, data CommandConnForwardRmData) error
 
 	// eventrecv is special, it's handled internally by WshRpc with EventListener
 	EventRecvCommand(ctx context.Context, data wps.StarEvent) error
@@ -229,6 +273,9 @@ type WshRpcInterface interface {
 	RemoteStreamFileCommand(ctx context.Context, data CommandRemoteStreamFileData) chan RespOrErrorUnion[FileData]
 	RemoteTarStreamCommand(ctx context.Context, data CommandRemoteStreamTarData) <-chan RespOrErrorUnion[iochantypes.Packet]
 	RemoteFileCopyCommand(ctx context.Context, data CommandFileCopyData) (bool, error)
+	RemoteFileCopyStreamCommand(ctx context.Context, data CommandFileCopyData) <-chan RespOrErrorUnion[FileCopyProgress]
+	RemoteFileWatchCommand(ctx context.Context, data CommandFileWatchData) <-chan RespOrErrorUnion[FileWatchEvent]
+	RemoteFileSearchCommand(ctx context.Context, data CommandFileSearchData) <-chan RespOrErrorUnion[FileSearchResult]
 	RemoteListEntriesCommand(ctx context.Context, data CommandRemoteListEntriesData) chan RespOrErrorUnion[CommandRemoteListEntriesRtnData]
 	RemoteFileInfoCommand(ctx context.Context, path string) (*FileInfo, error)
 	RemoteFileTouchCommand(ctx context.Context, path string) error
@@ -240,6 +287,7 @@ type WshRpcInterface interface {
 	RemoteStreamCpuDataCommand(ctx context.Context) chan RespOrErrorUnion[TimeSeriesData]
 	RemoteGetInfoCommand(ctx context.Context) (RemoteInfo, error)
 	RemoteInstallRcFilesCommand(ctx context.Context) error
+	RemoteUpdateJwtCommand(ctx context.Context, jwtToken string) error
 
 	// emain
 	WebSelectorCommand(ctx context.Context, data CommandWebSelectorData) ([]string, error)
@@ -279,11 +327,35 @@ const (
 	ClientType_BlockController = "blockcontroller"
 )
 
+// capabilities restrict the commands a route may call, a route without capabilities can call everything
+const (
+	RouteCap_ReadOnly = "readonly" // only commands that do not change anything
+	RouteCap_OwnBlock = "ownblock" // commands can only target the route's own block (and its tab)
+	RouteCap_NoConfig = "noconfig" // no changes to the settings or connections config, no jwt key rotation
+	RouteCap_NoAi     = "noai"     // no ai requests
+	RouteCap_NoConn   = "noconn"   // no connecting, disconnecting or changing connections
+)
+
+var AllRouteCaps = []string{RouteCap_ReadOnly, RouteCap_OwnBlock, RouteCap_NoConfig, RouteCap_NoAi, RouteCap_NoConn}
+
+// scopes of automation api tokens, a request is only allowed if the token has the scope of its endpoint
+const (
+	ApiScope_Read    = "read"    // list and get workspaces, tabs and blocks
+	ApiScope_Write   = "write"   // create, change and delete workspaces, tabs and blocks (meta that runs commands also needs input)
+	ApiScope_Input   = "input"   // send input, signals and terminal sizes to block controllers
+	ApiScope_Output  = "output"  // read and stream terminal output
+	ApiScope_Events  = "events"  // subscribe to events
+	ApiScope_Metrics = "metrics" // scrape the rpc metrics (when debug:rpcmetrics is set)
+)
+
+var AllApiScopes = []string{ApiScope_Read, ApiScope_Write, ApiScope_Input, ApiScope_Output, ApiScope_Events, ApiScope_Metrics}
+
 type RpcContext struct {
-	ClientType string `json:"ctype,omitempty"`
-	BlockId    string `json:"blockid,omitempty"`
-	TabId      string `json:"tabid,omitempty"`
-	Conn       string `json:"conn,omitempty"`
+	ClientType string   `json:"ctype,omitempty"`
+	BlockId    string   `json:"blockid,omitempty"`
+	TabId      string   `json:"tabid,omitempty"`
+	Conn       string   `json:"conn,omitempty"`
+	Caps       []string `json:"caps,omitempty"` // see RouteCap_*, carried in the jwt token so it cannot be changed by the client
 }
 
 func HackRpcContextIntoData(dataPtr any, rpcContext RpcContext) {
@@ -318,8 +390,9 @@ func HackRpcContextIntoData(dataPtr any, rpcContext RpcContext) {
 }
 
 type CommandAuthenticateRtnData struct {
-	RouteId   string `json:"routeid"`
-	AuthToken string `json:"authtoken,omitempty"`
+	RouteId    string      `json:"routeid"`
+	AuthToken  string      `json:"authtoken,omitempty"`
+	RpcContext *RpcContext `json:"rpccontext,omitempty"` // the context from the verified token (its caps are enforced by proxies)
 
 	// these fields are only set when doing a token swap
 	Env            map[string]string `json:"env,omitempty"`
@@ -330,6 +403,27 @@ type CommandAuthenticateTokenData struct {
 	Token string `json:"token"`
 }
 
+type CommandRotateJwtKeyData struct {
+	RevokeOld bool `json:"revokeold,omitempty"` // tokens signed with previous keys stop working immediately
+}
+
+type CommandRotateJwtKeyRtnData struct {
+	KeyId        string   `json:"keyid"`
+	RekeyedConns []string `json:"rekeyedconns,omitempty"`
+	RekeyErrors  []string `json:"rekeyerrors,omitempty"`
+}
+
+type CommandApiTokenCreateData struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"` // see ApiScope_*
+}
+
+type CommandApiTokenCreateRtnData struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`         // only returned here, the config only keeps its hash
+	Url   string `json:"url,omitempty"` // base url of the automation api (if it is running)
+}
+
 type CommandDisposeData struct {
 	RouteId string `json:"routeid"`
 	// auth token travels in the packet directly
@@ -424,15 +518,20 @@ type FileInfo struct {
 	SupportsMkdir bool        `json:"supportsmkdir,omitempty"`
 	MimeType      string      `json:"mimetype,omitempty"`
 	ReadOnly      bool        `json:"readonly,omitempty"` // this is not set for fileinfo's returned from directory listings
+	ETag          string      `json:"etag,omitempty"`     // mtime, size and content hash of a regular file, set on single file infos and reads from wsh connections
 }
 
 type FileOpts struct {
-	MaxSize     int64 `json:"maxsize,omitempty"`
-	Circular    bool  `json:"circular,omitempty"`
-	IJson       bool  `json:"ijson,omitempty"`
-	IJsonBudget int   `json:"ijsonbudget,omitempty"`
-	Truncate    bool  `json:"truncate,omitempty"`
-	Append      bool  `json:"append,omitempty"`
+	MaxSize     int64  `json:"maxsize,omitempty"`
+	Circular    bool   `json:"circular,omitempty"`
+	IJson       bool   `json:"ijson,omitempty"`
+	IJsonBudget int    `json:"ijsonbudget,omitempty"`
+	Truncate    bool   `json:"truncate,omitempty"`
+	Append      bool   `json:"append,omitempty"`
+	IfMatch     string `json:"ifmatch,omitempty"` // the write fails with a conflict error unless the file still has this etag
+	StageId     string `json:"stageid,omitempty"` // chunks are collected in a stage file next to the file, until a write with Commit set replaces the file with it
+	Commit      bool   `json:"commit,omitempty"`
+	ObjectTags  bool   `json:"objecttags,omitempty"` // stat also returns the tags of s3 objects (another request per object)
 }
 
 type FileMeta = map[string]any
@@ -479,14 +578,31 @@ type CommandEventReadHistoryData struct {
 
 type StarAIStreamRequest struct {
 	ClientId string                    `json:"clientid,omitempty"`
+	BlockId  string                    `json:"blockid,omitempty"` // the ai block, tools use its tab
 	Opts     *StarAIOptsType           `json:"opts"`
 	Prompt   []StarAIPromptMessageType `json:"prompt"`
+	Tools    []StarAIToolDef           `json:"tools,omitempty"` // set by the backend when opts.tools is true
 }
 
 type StarAIPromptMessageType struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-	Name    string `json:"name,omitempty"`
+	Role       string           `json:"role"` // "system", "user", "assistant", or "tool" (a tool result)
+	Content    string           `json:"content"`
+	Name       string           `json:"name,omitempty"`       // the tool name for "tool" messages
+	ToolCalls  []StarAIToolCall `json:"toolcalls,omitempty"`  // assistant messages
+	ToolCallId string           `json:"toolcallid,omitempty"` // "tool" messages
+	IsError    bool             `json:"iserror,omitempty"`    // "tool" messages
+}
+
+type StarAIToolDef struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputschema"` // json schema, must be an object
+}
+
+type StarAIToolCall struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Input string `json:"input"` // json encoded object
 }
 
 type StarAIOptsType struct {
@@ -499,6 +615,7 @@ type StarAIOptsType struct {
 	MaxTokens  int    `json:"maxtokens,omitempty"`
 	MaxChoices int    `json:"maxchoices,omitempty"`
 	TimeoutMs  int    `json:"timeoutms,omitempty"`
+	Tools      bool   `json:"tools,omitempty"`
 }
 
 type StarAIPacketType struct {
@@ -509,6 +626,8 @@ type StarAIPacketType struct {
 	Usage        *StarAIUsageType `json:"usage,omitempty"`
 	Index        int              `json:"index,omitempty"`
 	Text         string           `json:"text,omitempty"`
+	ToolCalls    []StarAIToolCall `json:"toolcalls,omitempty"` // complete calls, sent once per response
+	Status       string           `json:"status,omitempty"`    // transient progress (e.g. a model download), not part of the response
 	Error        string           `json:"error,omitempty"`
 }
 
@@ -518,6 +637,26 @@ type StarAIUsageType struct {
 	TotalTokens      int `json:"total_tokens,omitempty"`
 }
 
+type CommandStarAiListModelsData struct {
+	APIType      string `json:"apitype"` // only "ollama" is supported
+	BaseURL      string `json:"baseurl,omitempty"`
+	WritePresets bool   `json:"writepresets,omitempty"` // also (re)write a preset for every model
+}
+
+type CommandStarAiListModelsRtnData struct {
+	Models      []StarAIModelInfo `json:"models"`
+	PresetsFile string            `json:"presetsfile,omitempty"`
+}
+
+type StarAIModelInfo struct {
+	Name          string `json:"name"`
+	Size          int64  `json:"size,omitempty"`
+	ModifiedTs    int64  `json:"modifiedts,omitempty"`
+	Family        string `json:"family,omitempty"`
+	ParameterSize string `json:"parametersize,omitempty"`
+	Quantization  string `json:"quantization,omitempty"`
+}
+
 type CpuDataRequest struct {
 	Id    string `json:"id"`
 	Count int    `json:"count"`
@@ -531,6 +670,7 @@ type CpuDataType struct {
 type CommandDeleteFileData struct {
 	Path      string `json:"path"`
 	Recursive bool   `json:"recursive"`
+	Permanent bool   `json:"permanent,omitempty"` // skip the trash (when file:trash is set)
 }
 
 type CommandFileCopyData struct {
@@ -540,15 +680,152 @@ type CommandFileCopyData struct {
 }
 
 type CommandRemoteStreamTarData struct {
-	Path string        `json:"path"`
-	Opts *FileCopyOpts `json:"opts,omitempty"`
+	Path   string                    `json:"path"`
+	Opts   *FileCopyOpts             `json:"opts,omitempty"`
+	Resume map[string]FileResumeInfo `json:"resume,omitempty"` // what is already at the destination, keyed by tar entry name ("" for a single file copied to a file path)
 }
 
 type FileCopyOpts struct {
-	Overwrite bool  `json:"overwrite,omitempty"`
-	Recursive bool  `json:"recursive,omitempty"` // only used for move, always true for copy
-	Merge     bool  `json:"merge,omitempty"`
-	Timeout   int64 `json:"timeout,omitempty"`
+	Overwrite   bool   `json:"overwrite,omitempty"`
+	Recursive   bool   `json:"recursive,omitempty"` // only used for move, always true for copy
+	Merge       bool   `json:"merge,omitempty"`
+	Timeout     int64  `json:"timeout,omitempty"`
+	Compression string `json:"compression,omitempty"` // requested tar stream compression, "gzip" or "none"
+	Checksum    bool   `json:"checksum,omitempty"`    // send a sha256 for every file in the tar stream
+	Resume      bool   `json:"resume,omitempty"`      // skip files that already match, continue partially copied files
+}
+
+type FileResumeInfo struct {
+	Size        int64  `json:"size,omitempty"`
+	ModTs       int64  `json:"modts,omitempty"`
+	PartialSize int64  `json:"partialsize,omitempty"` // size of the partial file left by an interrupted copy
+	Checksum    string `json:"checksum,omitempty"`    // sha256 of the complete file, only set for checksummed copies
+}
+
+type FileCopyProgress struct {
+	Path       string `json:"path,omitempty"` // file currently being copied
+	FileSize   int64  `json:"filesize,omitempty"`
+	FileBytes  int64  `json:"filebytes,omitempty"` // includes resumed bytes
+	NumFiles   int    `json:"numfiles"`
+	NumSkipped int    `json:"numskipped,omitempty"`
+	NumResumed int    `json:"numresumed,omitempty"`
+	TotalBytes int64  `json:"totalbytes"` // bytes written at the destination
+	ElapsedMs  int64  `json:"elapsedms"`
+	IsDir      bool   `json:"isdir,omitempty"`
+	Done       bool   `json:"done,omitempty"`
+}
+
+type CommandFileSyncData struct {
+	SrcUri  string        `json:"srcuri"`
+	DestUri string        `json:"desturi"`
+	Opts    *FileSyncOpts `json:"opts,omitempty"`
+}
+
+type FileSyncOpts struct {
+	Checksum  bool     `json:"checksum,omitempty"` // compare file contents instead of mod times
+	Delete    bool     `json:"delete,omitempty"`   // delete destination files that are not in the source
+	Include   []string `json:"include,omitempty"`  // .gitignore style patterns
+	Exclude   []string `json:"exclude,omitempty"`
+	GitIgnore bool     `json:"gitignore,omitempty"` // skip files ignored by .gitignore files in the source
+	DryRun    bool     `json:"dryrun,omitempty"`
+	Timeout   int64    `json:"timeout,omitempty"` // per file copy
+}
+
+type FileSyncAction struct {
+	Op   string `json:"op"`   // "copy", "update" or "delete"
+	Path string `json:"path"` // relative to the sync root
+	Size int64  `json:"size,omitempty"`
+}
+
+type FileSyncProgress struct {
+	Action    *FileSyncAction `json:"action,omitempty"` // sent once the action is done (or planned, for a dry run)
+	Unchanged int             `json:"unchanged,omitempty"`
+	Done      bool            `json:"done,omitempty"`
+}
+
+const (
+	FileWatchOp_Create = "create"
+	FileWatchOp_Write  = "write"
+	FileWatchOp_Remove = "remove"
+	FileWatchOp_Rename = "rename" // sent for the old path, the new path gets a create
+)
+
+type CommandFileWatchData struct {
+	Uri  string         `json:"uri"`
+	Opts *FileWatchOpts `json:"opts,omitempty"`
+}
+
+type FileWatchOpts struct {
+	Recursive  bool     `json:"recursive,omitempty"`
+	DebounceMs int64    `json:"debouncems,omitempty"` // changes are sent once nothing changed for this long (default 100ms)
+	Include    []string `json:"include,omitempty"`    // .gitignore style patterns, relative to the watched directory
+	Exclude    []string `json:"exclude,omitempty"`
+}
+
+type FileWatchChange struct {
+	Path  string `json:"path"` // relative to the watched directory, "." if changes were dropped and the directory should be rescanned
+	Op    string `json:"op"`
+	IsDir bool   `json:"isdir,omitempty"`
+}
+
+// FileWatchEvent is a batch of changes, it is also published as a "filewatch" event scoped by the connection name and by Uri
+type FileWatchEvent struct {
+	Uri     string            `json:"uri"` // the watched path, as a wsh:// uri with an absolute path
+	Changes []FileWatchChange `json:"changes,omitempty"`
+	Ready   bool              `json:"ready,omitempty"` // sent once the watches are set up
+}
+
+type CommandFileSearchData struct {
+	Uri  string          `json:"uri"` // a directory (searched recursively) or a single file
+	Opts *FileSearchOpts `json:"opts"`
+}
+
+type FileSearchOpts struct {
+	Pattern     string   `json:"pattern"` // a go regexp, unless Literal is set
+	Literal     bool     `json:"literal,omitempty"`
+	IgnoreCase  bool     `json:"ignorecase,omitempty"`
+	Include     []string `json:"include,omitempty"` // .gitignore style patterns, relative to the searched directory
+	Exclude     []string `json:"exclude,omitempty"`
+	NoGitIgnore bool     `json:"nogitignore,omitempty"` // also search files ignored by .gitignore files (and .git directories)
+	MaxFileSize int64    `json:"maxfilesize,omitempty"` // larger files are skipped (default 10MB)
+	MaxResults  int      `json:"maxresults,omitempty"`  // the search stops after this many matches (default 10000)
+}
+
+type FileSearchMatch struct {
+	Path   string `json:"path"`   // relative to the searched directory (the file name when searching a single file)
+	Line   int    `json:"line"`   // 1-based
+	Column int    `json:"column"` // 1-based byte offset of the first match in the line
+	Text   string `json:"text"`   // the matching line, truncated if very long
+}
+
+// FileSearchResult has the matches of one file, the last result has Done set along with the totals
+type FileSearchResult struct {
+	Matches       []FileSearchMatch `json:"matches,omitempty"`
+	FilesSearched int               `json:"filessearched,omitempty"`
+	FilesSkipped  int               `json:"filesskipped,omitempty"` // too large, binary or unreadable
+	Truncated     bool              `json:"truncated,omitempty"`    // MaxResults was reached
+	Done          bool              `json:"done,omitempty"`
+}
+
+const (
+	TrashReason_Delete    = "delete"
+	TrashReason_Overwrite = "overwrite"
+)
+
+// TrashEntry is a file or directory moved to the trash of its connection (or bucket) instead of being deleted or overwritten
+type TrashEntry struct {
+	Id        string `json:"id"`
+	Uri       string `json:"uri"` // where it was
+	IsDir     bool   `json:"isdir,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	Reason    string `json:"reason"`
+	TrashedTs int64  `json:"trashedts"`
+}
+
+type CommandFileTrashData struct {
+	Uri     string   `json:"uri"`               // any path on the connection (or in the bucket) whose trash is used
+	Ids     []string `json:"ids,omitempty"`     // for restore and empty (all entries if empty)
+	DestUri string   `json:"desturi,omitempty"` // restores a single entry here instead of where it was
 }
 
 type CommandRemoteStreamFileData struct {
@@ -622,6 +899,19 @@ type ConnStatus struct {
 	WshError      string `json:"wsherror,omitempty"`
 	NoWshReason   string `json:"nowshreason,omitempty"`
 	WshVersion    string `json:"wshversion,omitempty"`
+
+	Forwards []ConnForwardStatus `json:"forwards,omitempty"`
+}
+
+type ConnForwardStatus struct {
+	ForwardId  int    `json:"forwardid"`
+	Type       string `json:"type"`   // "local", "remote", or "dynamic"
+	Source     string `json:"source"` // "config" or "adhoc"
+	Spec       string `json:"spec"`   // the spec as written in the config (or passed to wsh)
+	BindAddr   string `json:"bindaddr,omitempty"`
+	TargetAddr string `json:"targetaddr,omitempty"`
+	Status     string `json:"status"` // "active", "error", or "closed"
+	Error      string `json:"error,omitempty"`
 }
 
 type WebSelectorOpts struct {
@@ -645,6 +935,88 @@ type BlockInfoData struct {
 	Files       []*FileInfo    `json:"files"`
 }
 
+type CommandBlockCommandsData struct {
+	BlockId string `json:"blockid" wshcontext:"BlockId"`
+	Limit   int    `json:"limit,omitempty"` // most recent N commands (0 for all)
+}
+
+// a command tracked with the shell integration markers.  offsets are into the "term" blockfile
+type BlockCommandRecord struct {
+	CmdId        int    `json:"cmdid"`
+	BlockId      string `json:"blockid"`
+	CmdLine      string `json:"cmdline,omitempty"`
+	Cwd          string `json:"cwd,omitempty"`
+	PromptOffset int64  `json:"promptoffset"` // start of the prompt, -1 if the prompt was not marked
+	StartOffset  int64  `json:"startoffset"`  // start of the command output
+	EndOffset    int64  `json:"endoffset"`    // end of the command output (not set while running)
+	StartTs      int64  `json:"startts"`
+	EndTs        int64  `json:"endts,omitempty"`
+	Duration     int64  `json:"duration,omitempty"` // ms
+	ExitCode     *int   `json:"exitcode,omitempty"`
+	Running      bool   `json:"running,omitempty"`
+}
+
+type HistoryItem struct {
+	HistoryId   string `json:"historyid" db:"historyid"`
+	Ts          int64  `json:"ts" db:"ts"`
+	Cmd         string `json:"cmd" db:"cmd"`
+	Cwd         string `json:"cwd,omitempty" db:"cwd"`
+	Conn        string `json:"conn,omitempty" db:"conn"` // "" for local
+	BlockId     string `json:"blockid,omitempty" db:"blockid"`
+	TabId       string `json:"tabid,omitempty" db:"tabid"`
+	WorkspaceId string `json:"workspaceid,omitempty" db:"workspaceid"`
+	ExitCode    *int   `json:"exitcode,omitempty" db:"exitcode"`
+	DurationMs  int64  `json:"durationms,omitempty" db:"durationms"`
+}
+
+type CommandHistorySearchData struct {
+	Query  string  `json:"query,omitempty"`
+	Limit  int     `json:"limit,omitempty"`
+	Conn   *string `json:"conn,omitempty"` // "" for local
+	Cwd    string  `json:"cwd,omitempty"`
+	Failed bool    `json:"failed,omitempty"`
+	Since  int64   `json:"since,omitempty"` // unix ms
+	Unique bool    `json:"unique,omitempty"`
+}
+
+type CommandHistoryPurgeData struct {
+	Conn *string `json:"conn,omitempty"` // "" purges local history
+	All  bool    `json:"all,omitempty"`
+}
+
+// searches the term output (including the archive) of a block, or of every block in a tab or workspace
+type CommandTermSearchData struct {
+	ORef       starobj.ORef `json:"oref"`
+	Query      string       `json:"query"`
+	Regex      bool         `json:"regex,omitempty"`
+	IgnoreCase bool         `json:"ignorecase,omitempty"`
+	MaxMatches int          `json:"maxmatches,omitempty"` // total, across all blocks
+}
+
+// offsets are into the "term" blockfile, line is the ANSI stripped output line (trimmed around the match)
+type TermSearchMatch struct {
+	BlockId    string `json:"blockid"`
+	Offset     int64  `json:"offset"`
+	EndOffset  int64  `json:"endoffset"`
+	Line       string `json:"line"`
+	MatchStart int    `json:"matchstart"`
+	MatchEnd   int    `json:"matchend"`
+	Archived   bool   `json:"archived,omitempty"` // no longer in the live scrollback
+}
+
+type CommandTermSearchRtnData struct {
+	Matches   []TermSearchMatch `json:"matches"`
+	Truncated bool              `json:"truncated,omitempty"`
+}
+
+// plays the term:record recording of a block into a new (read-only) term block
+type CommandRecordingReplayData struct {
+	BlockId   string  `json:"blockid" wshcontext:"BlockId"`
+	TabId     string  `json:"tabid" wshcontext:"TabId"`
+	Speed     float64 `json:"speed,omitempty"`     // defaults to 1
+	MaxIdleMs int64   `json:"maxidlems,omitempty"` // caps the pauses between output, 0 for no cap
+}
+
 type StarNotificationOptions struct {
 	Title  string `json:"title,omitempty"`
 	Body   string `json:"body,omitempty"`
@@ -740,14 +1112,27 @@ type ConnExtData struct {
 	LogBlockId string `json:"logblockid,omitempty"`
 }
 
+type CommandConnForwardAddData struct {
+	ConnName string `json:"connname"`
+	Type     string `json:"type"`
+	Spec     string `json:"spec"`
+}
+
+type CommandConnForwardRmData struct {
+	ConnName  string `json:"connname"`
+	ForwardId int    `json:"forwardid"`
+}
+
 type FetchSuggestionsData struct {
-	SuggestionType string `json:"suggestiontype"`
-	Query          string `json:"query"`
-	WidgetId       string `json:"widgetid"`
-	ReqNum         int    `json:"reqnum"`
-	FileCwd        string `json:"file:cwd,omitempty"`
-	FileDirOnly    bool   `json:"file:dironly,omitempty"`
-	FileConnection string `json:"file:connection,omitempty"`
+	SuggestionType    string `json:"suggestiontype"`
+	Query             string `json:"query"`
+	WidgetId          string `json:"widgetid"`
+	ReqNum            int    `json:"reqnum"`
+	FileCwd           string `json:"file:cwd,omitempty"`
+	FileDirOnly       bool   `json:"file:dironly,omitempty"`
+	FileConnection    string `json:"file:connection,omitempty"`
+	HistoryCwd        string `json:"history:cwd,omitempty"`
+	HistoryConnection string `json:"history:connection,omitempty"`
 }
 
 type FetchSuggestionsResponse struct {
@@ -778,4 +1163,52 @@ type FileShareCapability struct {
 	CanAppend bool `json:"canappend"`
 	// CanMkdir indicates whether the file share supports creating directories
 	CanMkdir bool `json:"canmkdir"`
+	// MaxFileSize is the largest file the file share can store (0 if there is no fixed limit)
+	MaxFileSize int64 `json:"maxfilesize,omitempty"`
+	// CanMultipart indicates whether large files are uploaded and copied in parts
+	CanMultipart bool `json:"canmultipart,omitempty"`
+	// CanStage indicates whether writes can be staged in chunks, committed atomically and checked against an etag
+	CanStage bool `json:"canstage,omitempty"`
+}
+
+const (
+	RpcSide_Client = "client" // requests sent
+	RpcSide_Server = "server" // requests handled
+	RpcSide_Router = "router" // requests passing through the router
+)
+
+type CommandRpcStatsData struct {
+	Reset bool  `json:"reset,omitempty"`
+	Trace *bool `json:"trace,omitempty"` // turns request tracing on or off
+}
+
+type RpcCommandStats struct {
+	Side          string  `json:"side"`
+	Command       string  `json:"command"`
+	Route         string  `json:"route"`
+	Count         int64   `json:"count"`
+	Errors        int64   `json:"errors"`
+	InFlight      int64   `json:"inflight"`
+	LatencySumMs  float64 `json:"latencysumms"`
+	LatencyMaxMs  float64 `json:"latencymaxms"`
+	LatencyCounts []int64 `json:"latencycounts"` // one per bucket in RpcStatsData.LatencyBucketsMs (not cumulative), the last one counts slower requests
+}
+
+type RpcTraceSpan struct {
+	TraceId    string  `json:"traceid"`
+	Side       string  `json:"side"`
+	Command    string  `json:"command"`
+	Route      string  `json:"route"`
+	StartTs    int64   `json:"startts"`
+	DurationMs float64 `json:"durationms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type RpcStatsData struct {
+	Name             string            `json:"name"`
+	SinceTs          int64             `json:"sincets"`
+	Tracing          bool              `json:"tracing"`
+	LatencyBucketsMs []float64         `json:"latencybucketsms"`
+	Stats            []RpcCommandStats `json:"stats"`
+	Traces           []RpcTraceSpan    `json:"traces,omitempty"`
 }
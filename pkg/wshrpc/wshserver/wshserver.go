@@ -6,6 +6,7 @@ package wshserver
 // this file contains the implementation of the wsh server methods
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -17,8 +18,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/commandlinedev/starterm/pkg/asciicast"
 	"github.com/commandlinedev/starterm/pkg/blockcontroller"
 	"github.com/commandlinedev/starterm/pkg/blocklogger"
+	"github.com/commandlinedev/starterm/pkg/cmdhistory"
 	"github.com/commandlinedev/starterm/pkg/filestore"
 	"github.com/commandlinedev/starterm/pkg/genconn"
 	"github.com/commandlinedev/starterm/pkg/panichandler"
@@ -34,6 +37,7 @@ import (
 	"github.com/commandlinedev/starterm/pkg/suggestion"
 	"github.com/commandlinedev/starterm/pkg/telemetry"
 	"github.com/commandlinedev/starterm/pkg/telemetry/telemetrydata"
+	"github.com/commandlinedev/starterm/pkg/termarchive"
 	"github.com/commandlinedev/starterm/pkg/util/envutil"
 	"github.com/commandlinedev/starterm/pkg/util/iochan/iochantypes"
 	"github.com/commandlinedev/starterm/pkg/util/iterfn"
@@ -41,8 +45,10 @@ import (
 	"github.com/commandlinedev/starterm/pkg/util/starfileutil"
 	"github.com/commandlinedev/starterm/pkg/util/utilfn"
 	"github.com/commandlinedev/starterm/pkg/wcloud"
+	"github.com/commandlinedev/starterm/pkg/web/webapi"
 	"github.com/commandlinedev/starterm/pkg/wps"
 	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/commandlinedev/starterm/pkg/wshrpc/wshclient"
 	"github.com/commandlinedev/starterm/pkg/wshutil"
 	"github.com/commandlinedev/starterm/pkg/wsl"
 	"github.com/commandlinedev/starterm/pkg/wslconn"
@@ -50,6 +56,8 @@ import (
 	"github.com/skratchdot/open-golang/open"
 )
 
+const ConnServerRekeyInterval = 24 * time.Hour
+
 var InvalidWslDistroNames = []string{"docker-desktop", "docker-desktop-data"}
 
 type WshServer struct{}
@@ -58,6 +66,23 @@ func (*WshServer) WshServerImpl() {}
 
 var WshServerImpl = WshServer{}
 
+// new connections authenticate in their proxy, this is only reached by a route that is already connected and sends a new token for itself
+// (connservers do after a key rotation, see wshremote.RemoteUpdateJwtCommand)
+func (ws *WshServer) AuthenticateCommand(ctx context.Context, data string) (wshrpc.CommandAuthenticateRtnData, error) {
+	newCtx, err := wshutil.ValidateAndExtractRpcContextFromToken(data)
+	if err != nil {
+		return wshrpc.CommandAuthenticateRtnData{}, fmt.Errorf("error validating token: %w", err)
+	}
+	routeId, err := wshutil.MakeRouteIdFromCtx(newCtx)
+	if err != nil {
+		return wshrpc.CommandAuthenticateRtnData{}, err
+	}
+	if source := wshutil.GetRpcSourceFromContext(ctx); routeId != source {
+		return wshrpc.CommandAuthenticateRtnData{}, fmt.Errorf("token is for route %q, not %q", routeId, source)
+	}
+	return wshrpc.CommandAuthenticateRtnData{RouteId: routeId, RpcContext: newCtx}, nil
+}
+
 // TODO remove this after implementing in multiproxy, just for wsl
 func (ws *WshServer) AuthenticateTokenCommand(ctx context.Context, data wshrpc.CommandAuthenticateTokenData) (wshrpc.CommandAuthenticateRtnData, error) {
 	entry := shellutil.GetAndRemoveTokenSwapEntry(data.Token)
@@ -80,6 +105,10 @@ func (ws *WshServer) TestCommand(ctx context.Context, data string) error {
 	return nil
 }
 
+func (ws *WshServer) RpcStatsCommand(ctx context.Context, data wshrpc.CommandRpcStatsData) (*wshrpc.RpcStatsData, error) {
+	return wshutil.GetRpcStats(wshutil.DefaultRoute, data), nil
+}
+
 // for testing
 func (ws *WshServer) MessageCommand(ctx context.Context, data wshrpc.CommandMessageData) error {
 	log.Printf("MESSAGE: %s | %q\n", data.ORef, data.Message)
@@ -106,6 +135,24 @@ func (ws *WshServer) StreamStarAiCommand(ctx context.Context, request wshrpc.Sta
 	return starai.RunAICommand(ctx, request)
 }
 
+func (ws *WshServer) StarAiListModelsCommand(ctx context.Context, data wshrpc.CommandStarAiListModelsData) (*wshrpc.CommandStarAiListModelsRtnData, error) {
+	if data.APIType != starai.ApiType_Ollama {
+		return nil, fmt.Errorf("listing models is not supported for api type %q", data.APIType)
+	}
+	models, err := starai.ListOllamaModels(ctx, data.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	rtn := &wshrpc.CommandStarAiListModelsRtnData{Models: models}
+	if data.WritePresets {
+		rtn.PresetsFile, err = starai.WriteOllamaPresets(data.BaseURL, models)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rtn, nil
+}
+
 func MakePlotData(ctx context.Context, blockId string) error {
 	block, err := wstore.DBMustGet[*starobj.Block](ctx, blockId)
 	if err != nil {
@@ -155,6 +202,19 @@ func (ws *WshServer) SetMetaCommand(ctx context.Context, data wshrpc.CommandSetM
 	if err != nil {
 		return fmt.Errorf("error updating object meta: %w", err)
 	}
+	if _, ok := data.Meta[starobj.MetaKey_TermArchive]; ok && oref.OType == starobj.OType_Block {
+		// takes effect right away (otherwise it would wait for the next shell start)
+		err = termarchive.SetArchiveMode(ctx, oref.OID, starbase.BlockFile_Term, data.Meta.GetBool(starobj.MetaKey_TermArchive, false))
+		if err != nil && err != fs.ErrNotExist {
+			log.Printf("error setting archive mode for block %s: %v\n", oref.OID, err)
+		}
+	}
+	if _, ok := data.Meta[starobj.MetaKey_TermRecord]; ok && oref.OType == starobj.OType_Block {
+		err = blockcontroller.SetRecording(ctx, oref.OID, data.Meta.GetBool(starobj.MetaKey_TermRecord, false))
+		if err != nil {
+			log.Printf("error setting recording for block %s: %v\n", oref.OID, err)
+		}
+	}
 	sendStarObjUpdate(oref)
 	return nil
 }
@@ -366,7 +426,7 @@ func (ws *WshServer) FileDeleteCommand(ctx context.Context, data wshrpc.CommandD
 }
 
 func (ws *WshServer) FileInfoCommand(ctx context.Context, data wshrpc.FileData) (*wshrpc.FileInfo, error) {
-	return fileshare.Stat(ctx, data.Info.Path)
+	return fileshare.StatWithOpts(ctx, data.Info.Path, data.Info.Opts)
 }
 
 func (ws *WshServer) FileListCommand(ctx context.Context, data wshrpc.FileListData) ([]*wshrpc.FileInfo, error) {
@@ -393,6 +453,34 @@ func (ws *WshServer) FileCopyCommand(ctx context.Context, data wshrpc.CommandFil
 	return fileshare.Copy(ctx, data)
 }
 
+func (ws *WshServer) FileCopyStreamCommand(ctx context.Context, data wshrpc.CommandFileCopyData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileCopyProgress] {
+	return fileshare.CopyStream(ctx, data)
+}
+
+func (ws *WshServer) FileSyncCommand(ctx context.Context, data wshrpc.CommandFileSyncData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileSyncProgress] {
+	return fileshare.Sync(ctx, data)
+}
+
+func (ws *WshServer) FileWatchCommand(ctx context.Context, data wshrpc.CommandFileWatchData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileWatchEvent] {
+	return fileshare.Watch(ctx, data)
+}
+
+func (ws *WshServer) FileTrashListCommand(ctx context.Context, data wshrpc.CommandFileTrashData) ([]*wshrpc.TrashEntry, error) {
+	return fileshare.TrashList(ctx, data)
+}
+
+func (ws *WshServer) FileTrashRestoreCommand(ctx context.Context, data wshrpc.CommandFileTrashData) error {
+	return fileshare.TrashRestore(ctx, data)
+}
+
+func (ws *WshServer) FileTrashEmptyCommand(ctx context.Context, data wshrpc.CommandFileTrashData) (int, error) {
+	return fileshare.TrashEmpty(ctx, data)
+}
+
+func (ws *WshServer) FileSearchCommand(ctx context.Context, data wshrpc.CommandFileSearchData) <-chan wshrpc.RespOrErrorUnion[wshrpc.FileSearchResult] {
+	return fileshare.Search(ctx, data)
+}
+
 func (ws *WshServer) FileMoveCommand(ctx context.Context, data wshrpc.CommandFileCopyData) error {
 	return fileshare.Move(ctx, data)
 }
@@ -554,6 +642,81 @@ func (ws *WshServer) WslStatusCommand(ctx context.Context) ([]wshrpc.ConnStatus,
 	return rtn, nil
 }
 
+// sends a fresh token to a running connserver so its re-execs of wsh keep working after a key rotation
+func rekeyConnServer(connName string) error {
+	var jwtToken string
+	var err error
+	if strings.HasPrefix(connName, "wsl://") {
+		conn := wslconn.GetWslConn(strings.TrimPrefix(connName, "wsl://"))
+		if conn == nil {
+			return fmt.Errorf("connection not found: %s", connName)
+		}
+		jwtToken, err = conn.MakeConnServerJwtToken()
+	} else {
+		connOpts, parseErr := remote.ParseOpts(connName)
+		if parseErr != nil {
+			return fmt.Errorf("error parsing connection name: %w", parseErr)
+		}
+		conn := conncontroller.GetConn(connOpts)
+		if conn == nil {
+			return fmt.Errorf("connection not found: %s", connName)
+		}
+		jwtToken, err = conn.MakeConnServerJwtToken()
+	}
+	if err != nil {
+		return fmt.Errorf("error creating jwt token: %w", err)
+	}
+	return wshclient.RemoteUpdateJwtCommand(wshclient.GetBareRpcClient(), jwtToken, &wshrpc.RpcOpts{Route: wshutil.MakeConnectionRouteId(connName), Timeout: 5000})
+}
+
+// sends fresh tokens to every connected connserver, returns the connections that were re-keyed and the errors for the others
+func rekeyAllConnServers() ([]string, []string) {
+	var rekeyed, rekeyErrors []string
+	allStatus := append(conncontroller.GetAllConnStatus(), wslconn.GetAllConnStatus()...)
+	for _, status := range allStatus {
+		if !status.Connected || !status.WshEnabled {
+			continue
+		}
+		err := rekeyConnServer(status.Connection)
+		if err != nil {
+			log.Printf("error re-keying connserver for %s: %v\n", status.Connection, err)
+			rekeyErrors = append(rekeyErrors, fmt.Sprintf("%s: %v", status.Connection, err))
+			continue
+		}
+		rekeyed = append(rekeyed, status.Connection)
+	}
+	return rekeyed, rekeyErrors
+}
+
+// connserver tokens expire after wshutil.JwtConnServerTokenLifetime, they are refreshed long before that so connections that stay up keep working
+func RunConnServerRekeyLoop() {
+	defer func() {
+		panichandler.PanicHandler("wshserver:RunConnServerRekeyLoop", recover())
+	}()
+	for {
+		time.Sleep(ConnServerRekeyInterval)
+		rekeyAllConnServers()
+	}
+}
+
+func (ws *WshServer) RotateJwtKeyCommand(ctx context.Context, data wshrpc.CommandRotateJwtKeyData) (wshrpc.CommandRotateJwtKeyRtnData, error) {
+	keyId, err := wshutil.RotateJwtKey(data.RevokeOld)
+	if err != nil {
+		return wshrpc.CommandRotateJwtKeyRtnData{}, fmt.Errorf("error rotating jwt key: %w", err)
+	}
+	rtn := wshrpc.CommandRotateJwtKeyRtnData{KeyId: keyId}
+	rtn.RekeyedConns, rtn.RekeyErrors = rekeyAllConnServers()
+	return rtn, nil
+}
+
+func (ws *WshServer) ApiTokenCreateCommand(ctx context.Context, data wshrpc.CommandApiTokenCreateData) (*wshrpc.CommandApiTokenCreateRtnData, error) {
+	return webapi.CreateApiToken(data)
+}
+
+func (ws *WshServer) ApiTokenDeleteCommand(ctx context.Context, name string) error {
+	return sconfig.SetApiToken(name, nil)
+}
+
 func termCtxWithLogBlockId(ctx context.Context, logBlockId string) context.Context {
 	if logBlockId == "" {
 		return ctx
@@ -666,6 +829,37 @@ func (ws *WshServer) ConnReinstallWshCommand(ctx context.Context, data wshrpc.Co
 	return conn.InstallWsh(ctx, "")
 }
 
+func getSshConnForForward(connName string) (*conncontroller.SSHConn, error) {
+	if strings.HasPrefix(connName, "wsl://") || strings.HasPrefix(connName, "aws:") {
+		return nil, fmt.Errorf("port forwarding is only supported on ssh connections")
+	}
+	connOpts, err := remote.ParseOpts(connName)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing connection name: %w", err)
+	}
+	conn := conncontroller.GetConn(connOpts)
+	if conn == nil {
+		return nil, fmt.Errorf("connection not found: %s", connName)
+	}
+	return conn, nil
+}
+
+func (ws *WshServer) ConnForwardAddCommand(ctx context.Context, data wshrpc.CommandConnForwardAddData) (wshrpc.ConnForwardStatus, error) {
+	conn, err := getSshConnForForward(data.ConnName)
+	if err != nil {
+		return wshrpc.ConnForwardStatus{}, err
+	}
+	return conn.AddForward(data.Type, data.Spec)
+}
+
+func (ws *WshServer) ConnForwardRmCommand(ctx context.Context, data wshrpc.CommandConnForwardRmData) error {
+	conn, err := getSshConnForForward(data.ConnName)
+	if err != nil {
+		return err
+	}
+	return conn.RemoveForward(data.ForwardId)
+}
+
 func (ws *WshServer) ConnUpdateWshCommand(ctx context.Context, remoteInfo wshrpc.RemoteInfo) (bool, error) {
 	handler := wshutil.GetRpcResponseHandlerFromContext(ctx)
 	if handler == nil {
@@ -772,6 +966,109 @@ func (ws *WshServer) DismissWshFailCommand(ctx context.Context, connName string)
 	return nil
 }
 
+func (ws *WshServer) BlockCommandsCommand(ctx context.Context, data wshrpc.CommandBlockCommandsData) ([]wshrpc.BlockCommandRecord, error) {
+	return blockcontroller.GetBlockCommands(data.BlockId, data.Limit)
+}
+
+func getTermSearchBlockIds(ctx context.Context, oref starobj.ORef) ([]string, error) {
+	switch oref.OType {
+	case starobj.OType_Block:
+		return []string{oref.OID}, nil
+	case starobj.OType_Tab:
+		tab, err := wstore.DBMustGet[*starobj.Tab](ctx, oref.OID)
+		if err != nil {
+			return nil, fmt.Errorf("error getting tab: %w", err)
+		}
+		return tab.BlockIds, nil
+	case starobj.OType_Workspace:
+		ws, err := wstore.DBMustGet[*starobj.Workspace](ctx, oref.OID)
+		if err != nil {
+			return nil, fmt.Errorf("error getting workspace: %w", err)
+		}
+		var blockIds []string
+		for _, tabId := range append(ws.PinnedTabIds, ws.TabIds...) {
+			tab, err := wstore.DBMustGet[*starobj.Tab](ctx, tabId)
+			if err != nil {
+				return nil, fmt.Errorf("error getting tab: %w", err)
+			}
+			blockIds = append(blockIds, tab.BlockIds...)
+		}
+		return blockIds, nil
+	}
+	return nil, fmt.Errorf("cannot search %q objects (must be a block, tab, or workspace)", oref.OType)
+}
+
+func (ws *WshServer) TermSearchCommand(ctx context.Context, data wshrpc.CommandTermSearchData) (*wshrpc.CommandTermSearchRtnData, error) {
+	re, err := termarchive.MakeSearchRegexp(data.Query, data.Regex, data.IgnoreCase)
+	if err != nil {
+		return nil, err
+	}
+	blockIds, err := getTermSearchBlockIds(ctx, data.ORef)
+	if err != nil {
+		return nil, err
+	}
+	matches, truncated, err := termarchive.SearchTermBlocks(ctx, blockIds, starbase.BlockFile_Term, re, data.MaxMatches)
+	if err != nil {
+		return nil, err
+	}
+	return &wshrpc.CommandTermSearchRtnData{Matches: matches, Truncated: truncated}, nil
+}
+
+func (ws *WshServer) RecordingReplayCommand(ctx context.Context, data wshrpc.CommandRecordingReplayData) (*starobj.ORef, error) {
+	_, recData, err := filestore.WFS.ReadFile(ctx, data.BlockId, starbase.BlockFile_Record)
+	if err == fs.ErrNotExist {
+		return nil, fmt.Errorf("block %s has no recording (set term:record to record it)", data.BlockId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading recording: %w", err)
+	}
+	reader, header, err := asciicast.MakeReader(bytes.NewReader(recData))
+	if err != nil {
+		return nil, err
+	}
+	tabId := data.TabId
+	if tabId == "" {
+		tabId, err = wstore.DBFindTabForBlockId(ctx, data.BlockId)
+		if err != nil {
+			return nil, fmt.Errorf("error finding tab: %w", err)
+		}
+	}
+	speed := data.Speed
+	if speed <= 0 {
+		speed = blockcontroller.DefaultReplaySpeed
+	}
+	title := fmt.Sprintf("replay %s (%gx)", time.Unix(header.Timestamp, 0).Format("2006-01-02 15:04"), speed)
+	blockRef, err := ws.CreateBlockCommand(ctx, wshrpc.CommandCreateBlockData{
+		TabId: tabId,
+		BlockDef: &starobj.BlockDef{
+			Meta: starobj.MetaMapType{
+				starobj.MetaKey_View:       "term",
+				starobj.MetaKey_FrameTitle: title,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	opts := blockcontroller.ReplayOpts{
+		Speed:   speed,
+		MaxIdle: time.Duration(data.MaxIdleMs) * time.Millisecond,
+	}
+	err = blockcontroller.StartReplay(blockRef.OID, reader, opts)
+	if err != nil {
+		return nil, err
+	}
+	return blockRef, nil
+}
+
+func (ws *WshServer) HistorySearchCommand(ctx context.Context, data wshrpc.CommandHistorySearchData) ([]wshrpc.HistoryItem, error) {
+	return cmdhistory.SearchHistory(ctx, data)
+}
+
+func (ws *WshServer) HistoryPurgeCommand(ctx context.Context, data wshrpc.CommandHistoryPurgeData) (int, error) {
+	return cmdhistory.PurgeHistory(ctx, data)
+}
+
 func (ws *WshServer) BlockInfoCommand(ctx context.Context, blockId string) (*wshrpc.BlockInfoData, error) {
 	blockData, err := wstore.DBMustGet[*starobj.Block](ctx, blockId)
 	if err != nil {
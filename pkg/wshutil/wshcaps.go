@@ -0,0 +1,456 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshutil
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+// routing and bookkeeping commands every route needs, they are never restricted
+var protocolCommands = map[string]bool{
+	wshrpc.Command_Authenticate:      true,
+	wshrpc.Command_AuthenticateToken: true,
+	wshrpc.Command_Dispose:           true,
+	wshrpc.Command_RouteAnnounce:     true,
+	wshrpc.Command_RouteUnannounce:   true,
+	wshrpc.Command_Message:           true,
+	wshrpc.Command_EventRecv:         true,
+	wshrpc.Command_EventSub:          true,
+	wshrpc.Command_EventUnsub:        true,
+	wshrpc.Command_EventUnsubAll:     true,
+	wshrpc.Command_WshActivity:       true,
+	wshrpc.Command_Activity:          true,
+	wshrpc.Command_Test:              true,
+	wshrpc.Command_StreamTest:        true,
+	"waitforroute":                   true,
+	"recordtevent":                   true,
+	"sendtelemetry":                  true,
+}
+
+// commands that do not change anything, the only ones allowed with RouteCap_ReadOnly.
+// recordingreplay (creates a block), streamstarai (runs ai tools like run_command) and
+// starailistmodels (can write presets) are deliberately not here. remotestreamfile and remotetarstream
+// only read the source of a copy, the destination is written by remotefilecopy (which is not here).
+var readOnlyCommands = map[string]bool{
+	wshrpc.Command_GetMeta:             true,
+	wshrpc.Command_ResolveIds:          true,
+	wshrpc.Command_BlockInfo:           true,
+	wshrpc.Command_BlockCommands:       true,
+	wshrpc.Command_HistorySearch:       true,
+	wshrpc.Command_TermSearch:          true,
+	wshrpc.Command_FileRead:            true,
+	wshrpc.Command_FileReadStream:      true,
+	wshrpc.Command_FileStreamTar:       true,
+	wshrpc.Command_FileWatch:           true,
+	wshrpc.Command_FileSearch:          true,
+	wshrpc.Command_FileTrashList:       true,
+	wshrpc.Command_FileJoin:            true,
+	wshrpc.Command_FileShareCapability: true,
+	"fileinfo":                         true,
+	"filelist":                         true,
+	"fileliststream":                   true,
+	wshrpc.Command_EventReadHistory:    true,
+	wshrpc.Command_StreamCpuData:       true,
+	wshrpc.Command_GetFullConfig:       true,
+	wshrpc.Command_GetVar:              true,
+	"path":                             true,
+	"gettab":                           true,
+	wshrpc.Command_StarInfo:            true,
+	wshrpc.Command_ConnStatus:          true,
+	wshrpc.Command_WslStatus:           true,
+	wshrpc.Command_ConnList:            true,
+	wshrpc.Command_ConnListAWS:         true,
+	wshrpc.Command_WslList:             true,
+	wshrpc.Command_WslDefaultDistro:    true,
+	wshrpc.Command_WorkspaceList:       true,
+	wshrpc.Command_GetUpdateChannel:    true,
+	wshrpc.Command_WebSelector:         true,
+	wshrpc.Command_RemoteStreamFile:    true,
+	wshrpc.Command_RemoteTarStream:     true,
+	wshrpc.Command_RemoteFileWatch:     true,
+	wshrpc.Command_RemoteFileSearch:    true,
+	wshrpc.Command_RemoteFileInfo:      true,
+	wshrpc.Command_RemoteFileJoin:      true,
+	wshrpc.Command_RemoteGetInfo:       true,
+	"remotelistentries":                true,
+	"remotestreamcpudata":              true,
+	"fetchsuggestions":                 true,
+	"disposesuggestions":               true,
+}
+
+var configCommands = map[string]bool{
+	wshrpc.Command_SetConfig:      true,
+	"setconnectionsconfig":        true,
+	wshrpc.Command_RotateJwtKey:   true,
+	wshrpc.Command_ApiTokenCreate: true,
+	wshrpc.Command_ApiTokenDelete: true,
+}
+
+var aiCommands = map[string]bool{
+	wshrpc.Command_StreamStarAi:     true,
+	wshrpc.Command_StarAiListModels: true,
+	wshrpc.Command_AiSendMessage:    true,
+}
+
+var connCommands = map[string]bool{
+	wshrpc.Command_ConnEnsure:           true,
+	wshrpc.Command_ConnReinstallWsh:     true,
+	wshrpc.Command_ConnConnect:          true,
+	wshrpc.Command_ConnDisconnect:       true,
+	wshrpc.Command_ConnUpdateWsh:        true,
+	wshrpc.Command_ConnForwardAdd:       true,
+	wshrpc.Command_ConnForwardRm:        true,
+	wshrpc.Command_DismissWshFail:       true,
+	wshrpc.Command_RemoteInstallRcfiles: true,
+	wshrpc.Command_RemoteUpdateJwt:      true,
+}
+
+// implemented by clients whose commands are restricted by route capabilities, checked by the router before dispatch
+type capsRpcClient interface {
+	GetCapsContext() *wshrpc.RpcContext
+}
+
+func validateRouteCaps(caps []string) error {
+	for _, routeCap := range caps {
+		if !slices.Contains(wshrpc.AllRouteCaps, routeCap) {
+			return fmt.Errorf("invalid capability %q in jwt token", routeCap)
+		}
+	}
+	return nil
+}
+
+func capDeniedErr(command string, routeCap string) error {
+	return fmt.Errorf("command %q is not allowed (route capability %q)", command, routeCap)
+}
+
+// with RouteCap_OwnBlock commands can only go to the server (see checkOwnUris and checkOwnIdData for what they can do there), the route's own connection and the route's own block and tab
+func checkOwnRoute(rpcCtx *wshrpc.RpcContext, sourceRouteId string, destRouteId string) bool {
+	if destRouteId == "" || destRouteId == DefaultRoute || destRouteId == ElectronRoute || destRouteId == sourceRouteId {
+		return true
+	}
+	if rpcCtx.Conn != "" && destRouteId == MakeConnectionRouteId(rpcCtx.Conn) {
+		return true
+	}
+	if rpcCtx.BlockId != "" && (destRouteId == MakeControllerRouteId(rpcCtx.BlockId) || destRouteId == MakeFeBlockRouteId(rpcCtx.BlockId)) {
+		return true
+	}
+	return rpcCtx.TabId != "" && destRouteId == MakeTabRouteId(rpcCtx.TabId)
+}
+
+// the fields of the command data that default to the caller's block or tab (wshcontext tags) must not point anywhere else.
+// empty fields are fine, they are filled in with the caller's own ids (see HackRpcContextIntoData).
+func checkOwnContextData(rpcCtx *wshrpc.RpcContext, command string, data any) error {
+	methodDecl := WshCommandDeclMap[command]
+	if methodDecl == nil || methodDecl.CommandDataType == nil || data == nil {
+		return nil
+	}
+	dataPtr := reflect.New(methodDecl.CommandDataType)
+	if err := utilfn.ReUnmarshal(dataPtr.Interface(), data); err != nil {
+		return fmt.Errorf("error re-marshalling command data: %w", err)
+	}
+	dataVal := dataPtr.Elem()
+	if dataVal.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < dataVal.NumField(); i++ {
+		field := dataVal.Field(i)
+		if field.IsZero() {
+			continue
+		}
+		var matches bool
+		switch dataVal.Type().Field(i).Tag.Get("wshcontext") {
+		case "":
+			continue
+		case "BlockId":
+			matches = field.String() == rpcCtx.BlockId
+		case "TabId":
+			matches = field.String() == rpcCtx.TabId
+		case "BlockORef":
+			matches = rpcCtx.BlockId != "" && field.Interface() == any(starobj.MakeORef(starobj.OType_Block, rpcCtx.BlockId))
+		}
+		if !matches {
+			return fmt.Errorf("command %q cannot target other blocks or tabs (route capability %q)", command, wshrpc.RouteCap_OwnBlock)
+		}
+	}
+	return nil
+}
+
+// commands whose data is just a block or tab id (so there is no wshcontext tag to check)
+var idDataCommands = map[string]string{
+	wshrpc.Command_BlockInfo:      "BlockId",
+	wshrpc.Command_ControllerStop: "BlockId",
+	"gettab":                      "TabId",
+}
+
+func checkOwnIdData(rpcCtx *wshrpc.RpcContext, command string, data any) error {
+	idType := idDataCommands[command]
+	if idType == "" || data == nil {
+		return nil
+	}
+	id, ok := data.(string)
+	if !ok {
+		return fmt.Errorf("command %q: invalid data type %T", command, data)
+	}
+	if (idType == "BlockId" && id != rpcCtx.BlockId) || (idType == "TabId" && id != rpcCtx.TabId) {
+		return fmt.Errorf("command %q cannot target other blocks or tabs (route capability %q)", command, wshrpc.RouteCap_OwnBlock)
+	}
+	return nil
+}
+
+// returns the file uris in the data of file commands (the server, or a connection for remote copies, opens these itself)
+func commandUris(command string, data any) ([]string, error) {
+	var rtn []string
+	var err error
+	switch command {
+	case wshrpc.Command_FileWrite, wshrpc.Command_FileRead, wshrpc.Command_FileReadStream, wshrpc.Command_FileAppend, "filemkdir", "filecreate", "fileinfo":
+		var fileData wshrpc.FileData
+		if err = utilfn.ReUnmarshal(&fileData, data); err == nil && fileData.Info != nil {
+			rtn = append(rtn, fileData.Info.Path)
+		}
+	case "filedelete":
+		var deleteData wshrpc.CommandDeleteFileData
+		if err = utilfn.ReUnmarshal(&deleteData, data); err == nil {
+			rtn = append(rtn, deleteData.Path)
+		}
+	case wshrpc.Command_FileMove, wshrpc.Command_FileCopy, wshrpc.Command_FileCopyStream, wshrpc.Command_RemoteFileCopyStream, "remotefilecopy", "remotefilemove":
+		var copyData wshrpc.CommandFileCopyData
+		if err = utilfn.ReUnmarshal(&copyData, data); err == nil {
+			rtn = append(rtn, copyData.SrcUri, copyData.DestUri)
+		}
+	case wshrpc.Command_FileSync:
+		var syncData wshrpc.CommandFileSyncData
+		if err = utilfn.ReUnmarshal(&syncData, data); err == nil {
+			rtn = append(rtn, syncData.SrcUri, syncData.DestUri)
+		}
+	case wshrpc.Command_FileStreamTar:
+		var tarData wshrpc.CommandRemoteStreamTarData
+		if err = utilfn.ReUnmarshal(&tarData, data); err == nil {
+			rtn = append(rtn, tarData.Path)
+		}
+	case wshrpc.Command_FileWatch:
+		var watchData wshrpc.CommandFileWatchData
+		if err = utilfn.ReUnmarshal(&watchData, data); err == nil {
+			rtn = append(rtn, watchData.Uri)
+		}
+	case wshrpc.Command_FileSearch:
+		var searchData wshrpc.CommandFileSearchData
+		if err = utilfn.ReUnmarshal(&searchData, data); err == nil {
+			rtn = append(rtn, searchData.Uri)
+		}
+	case wshrpc.Command_FileTrashList, wshrpc.Command_FileTrashRestore, wshrpc.Command_FileTrashEmpty:
+		var trashData wshrpc.CommandFileTrashData
+		if err = utilfn.ReUnmarshal(&trashData, data); err == nil {
+			rtn = append(rtn, trashData.Uri, trashData.DestUri)
+		}
+	case "filelist", "fileliststream":
+		var listData wshrpc.FileListData
+		if err = utilfn.ReUnmarshal(&listData, data); err == nil {
+			rtn = append(rtn, listData.Path)
+		}
+	case wshrpc.Command_FileJoin:
+		// the first path is the uri, the others are joined to it
+		var paths []string
+		if err = utilfn.ReUnmarshal(&paths, data); err == nil && len(paths) > 0 {
+			rtn = append(rtn, paths[0])
+		}
+	case wshrpc.Command_FileShareCapability:
+		path, ok := data.(string)
+		if !ok {
+			err = fmt.Errorf("invalid data type %T", data)
+		}
+		rtn = append(rtn, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error re-marshalling command data: %w", err)
+	}
+	return rtn, nil
+}
+
+// a uri is on the route's own connection (wsh:// or sftp://, or a plain path on the current connection) or in the route's own block's files (starfile://).
+// this only looks at the scheme and host (like connparse.ParseURI, which cannot be used here), anything else (s3, other hosts, other zones) is denied.
+func isOwnUri(rpcCtx *wshrpc.RpcContext, uri string) bool {
+	ownConn := rpcCtx.Conn
+	if ownConn == "" {
+		ownConn = wshrpc.LocalConnName
+	}
+	scheme, rest, hasScheme := strings.Cut(uri, "://")
+	if !hasScheme {
+		if strings.HasPrefix(uri, "//") {
+			scheme, rest = "wsh", strings.TrimPrefix(uri, "//")
+		} else if strings.HasPrefix(uri, "/~") {
+			return ownConn == wshrpc.LocalConnName
+		} else {
+			return true
+		}
+	}
+	rest = strings.TrimPrefix(rest, "//")
+	var host string
+	if strings.HasPrefix(rest, "wsl://") {
+		host = "wsl://" + strings.SplitN(strings.TrimPrefix(rest, "wsl://"), "/", 2)[0]
+	} else {
+		host = strings.SplitN(rest, "/", 2)[0]
+	}
+	switch scheme {
+	case "wsh":
+		return host == "" || host == "current" || host == ownConn
+	case "sftp":
+		return host == ownConn
+	case "starfile":
+		return rpcCtx.BlockId != "" && host == rpcCtx.BlockId
+	}
+	return false
+}
+
+// file commands go to the server (which can open any connection), so their uris are checked, not just the route
+func checkOwnUris(rpcCtx *wshrpc.RpcContext, command string, data any) error {
+	uris, err := commandUris(command, data)
+	if err != nil {
+		return err
+	}
+	for _, uri := range uris {
+		if uri != "" && !isOwnUri(rpcCtx, uri) {
+			return fmt.Errorf("command %q cannot access %q (route capability %q)", command, uri, wshrpc.RouteCap_OwnBlock)
+		}
+	}
+	return nil
+}
+
+// with RouteCap_OwnBlock blocks can only be created in the route's own tab (see wshcontext tags), next to or below the route's own block
+func checkOwnTargetBlock(rpcCtx *wshrpc.RpcContext, msg *RpcMessage) error {
+	var targetBlockId string
+	switch msg.Command {
+	case wshrpc.Command_CreateBlock:
+		var data wshrpc.CommandCreateBlockData
+		if err := utilfn.ReUnmarshal(&data, msg.Data); err != nil {
+			return fmt.Errorf("error re-marshalling command data: %w", err)
+		}
+		targetBlockId = data.TargetBlockId
+	case "createsubblock":
+		var data wshrpc.CommandCreateSubBlockData
+		if err := utilfn.ReUnmarshal(&data, msg.Data); err != nil {
+			return fmt.Errorf("error re-marshalling command data: %w", err)
+		}
+		targetBlockId = data.ParentBlockId
+	default:
+		return nil
+	}
+	if targetBlockId != "" && targetBlockId != rpcCtx.BlockId {
+		return fmt.Errorf("command %q cannot target other blocks (route capability %q)", msg.Command, wshrpc.RouteCap_OwnBlock)
+	}
+	return nil
+}
+
+// a restricted route cannot lift its own restrictions
+func checkCapsMeta(msg *RpcMessage) error {
+	if msg.Command != wshrpc.Command_SetMeta {
+		return nil
+	}
+	var data wshrpc.CommandSetMetaData
+	if err := utilfn.ReUnmarshal(&data, msg.Data); err != nil {
+		return fmt.Errorf("error re-marshalling command data: %w", err)
+	}
+	for key := range data.Meta {
+		if key == starobj.MetaKey_CmdWshCaps || key == starobj.MetaKey_CmdClear {
+			return fmt.Errorf("a route with capabilities cannot change %q", key)
+		}
+	}
+	return nil
+}
+
+// blocks created by a restricted route get its capabilities (merged with the ones they already have), so they cannot be used to escape them
+func inheritRouteCaps(rpcCtx *wshrpc.RpcContext, msg *RpcMessage) error {
+	var blockDefPtr **starobj.BlockDef
+	var data any
+	switch msg.Command {
+	case wshrpc.Command_CreateBlock:
+		createData := &wshrpc.CommandCreateBlockData{}
+		blockDefPtr, data = &createData.BlockDef, createData
+	case "createsubblock":
+		createData := &wshrpc.CommandCreateSubBlockData{}
+		blockDefPtr, data = &createData.BlockDef, createData
+	default:
+		return nil
+	}
+	if err := utilfn.ReUnmarshal(data, msg.Data); err != nil {
+		return fmt.Errorf("error re-marshalling command data: %w", err)
+	}
+	if *blockDefPtr == nil {
+		*blockDefPtr = &starobj.BlockDef{}
+	}
+	blockDef := *blockDefPtr
+	if blockDef.Meta == nil {
+		blockDef.Meta = make(starobj.MetaMapType)
+	}
+	caps := blockDef.Meta.GetStringList(starobj.MetaKey_CmdWshCaps)
+	for _, routeCap := range rpcCtx.Caps {
+		if !slices.Contains(caps, routeCap) {
+			caps = append(caps, routeCap)
+		}
+	}
+	capsArr := make([]any, 0, len(caps))
+	for _, routeCap := range caps {
+		capsArr = append(capsArr, routeCap)
+	}
+	blockDef.Meta[starobj.MetaKey_CmdWshCaps] = capsArr
+	msg.Data = data
+	return nil
+}
+
+// checks a new command sent by sourceRouteId against the capabilities of the route's context before it is dispatched,
+// and passes the capabilities on to blocks the command creates. a nil context or one without capabilities is unrestricted.
+func ApplyRouteCaps(rpcCtx *wshrpc.RpcContext, sourceRouteId string, msg *RpcMessage) error {
+	if rpcCtx == nil || len(rpcCtx.Caps) == 0 || msg.Command == "" || protocolCommands[msg.Command] {
+		return nil
+	}
+	for _, routeCap := range rpcCtx.Caps {
+		switch routeCap {
+		case wshrpc.RouteCap_ReadOnly:
+			if !readOnlyCommands[msg.Command] {
+				return capDeniedErr(msg.Command, routeCap)
+			}
+		case wshrpc.RouteCap_NoConfig:
+			if configCommands[msg.Command] {
+				return capDeniedErr(msg.Command, routeCap)
+			}
+		case wshrpc.RouteCap_NoAi:
+			if aiCommands[msg.Command] {
+				return capDeniedErr(msg.Command, routeCap)
+			}
+		case wshrpc.RouteCap_NoConn:
+			if connCommands[msg.Command] {
+				return capDeniedErr(msg.Command, routeCap)
+			}
+		case wshrpc.RouteCap_OwnBlock:
+			if !checkOwnRoute(rpcCtx, sourceRouteId, msg.Route) {
+				return fmt.Errorf("command %q cannot be sent to route %q (route capability %q)", msg.Command, msg.Route, routeCap)
+			}
+			if err := checkOwnContextData(rpcCtx, msg.Command, msg.Data); err != nil {
+				return err
+			}
+			if err := checkOwnTargetBlock(rpcCtx, msg); err != nil {
+				return err
+			}
+			if err := checkOwnIdData(rpcCtx, msg.Command, msg.Data); err != nil {
+				return err
+			}
+			if err := checkOwnUris(rpcCtx, msg.Command, msg.Data); err != nil {
+				return err
+			}
+		default:
+			// unknown capabilities deny everything, so a typo never grants more than intended
+			return fmt.Errorf("command %q is not allowed (unknown route capability %q)", msg.Command, routeCap)
+		}
+	}
+	if err := checkCapsMeta(msg); err != nil {
+		return err
+	}
+	return inheritRouteCaps(rpcCtx, msg)
+}
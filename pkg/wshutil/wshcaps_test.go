@@ -0,0 +1,194 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshutil
+
+import (
+	"testing"
+
+	"github.com/commandlinedev/starterm/pkg/starobj"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+const (
+	testBlockId      = "5e1e5e8c-0000-4000-8000-000000000001"
+	testOtherBlockId = "5e1e5e8c-0000-4000-8000-000000000002"
+	testTabId        = "5e1e5e8c-0000-4000-8000-000000000003"
+	testConn         = "user@testhost"
+)
+
+func TestApplyRouteCaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		caps    []string
+		msg     RpcMessage
+		allowed bool
+	}{
+		{"unrestricted", nil, RpcMessage{Command: wshrpc.Command_SetConfig}, true},
+		{"readonly read", []string{wshrpc.RouteCap_ReadOnly}, RpcMessage{Command: wshrpc.Command_FileRead}, true},
+		{"readonly write", []string{wshrpc.RouteCap_ReadOnly}, RpcMessage{Command: wshrpc.Command_FileWrite}, false},
+		{"readonly protocol", []string{wshrpc.RouteCap_ReadOnly}, RpcMessage{Command: wshrpc.Command_RouteAnnounce}, true},
+		{"readonly replay", []string{wshrpc.RouteCap_ReadOnly}, RpcMessage{Command: wshrpc.Command_RecordingReplay}, false},
+		{"readonly ai", []string{wshrpc.RouteCap_ReadOnly}, RpcMessage{Command: wshrpc.Command_StreamStarAi}, false},
+		{"readonly ai models", []string{wshrpc.RouteCap_ReadOnly}, RpcMessage{Command: wshrpc.Command_StarAiListModels}, false},
+		{"readonly copy source", []string{wshrpc.RouteCap_ReadOnly}, RpcMessage{Command: wshrpc.Command_RemoteTarStream}, true},
+		{"readonly copy dest", []string{wshrpc.RouteCap_ReadOnly}, RpcMessage{Command: "remotefilecopy"}, false},
+		{"noconfig", []string{wshrpc.RouteCap_NoConfig}, RpcMessage{Command: wshrpc.Command_SetConfig}, false},
+		{"noai", []string{wshrpc.RouteCap_NoAi}, RpcMessage{Command: wshrpc.Command_StreamStarAi}, false},
+		{"noconn", []string{wshrpc.RouteCap_NoConn}, RpcMessage{Command: wshrpc.Command_ConnDisconnect}, false},
+		{"unknown cap", []string{"readonyl"}, RpcMessage{Command: wshrpc.Command_GetMeta}, false},
+		{
+			"ownblock own block",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_DeleteBlock, Data: map[string]any{"blockid": testBlockId}},
+			true,
+		},
+		{
+			"ownblock other block",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_DeleteBlock, Data: map[string]any{"blockid": testOtherBlockId}},
+			false,
+		},
+		{
+			"ownblock other oref",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_GetMeta, Data: map[string]any{"oref": "block:" + testOtherBlockId}},
+			false,
+		},
+		{
+			"ownblock other controller",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_ControllerInput, Route: MakeControllerRouteId(testOtherBlockId)},
+			false,
+		},
+		{
+			"ownblock own conn",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_RemoteWriteFile, Route: MakeConnectionRouteId(testConn)},
+			true,
+		},
+		{
+			"ownblock other conn",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_RemoteWriteFile, Route: MakeConnectionRouteId("user@otherhost")},
+			false,
+		},
+		{
+			"ownblock replace other block",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_CreateBlock, Data: map[string]any{"tabid": testTabId, "targetblockid": testOtherBlockId}},
+			false,
+		},
+		{
+			"ownblock blockinfo other block",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_BlockInfo, Data: testOtherBlockId},
+			false,
+		},
+		{
+			"ownblock blockinfo own block",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_BlockInfo, Data: testBlockId},
+			true,
+		},
+		{
+			"ownblock write own conn",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_FileWrite, Data: map[string]any{"info": map[string]any{"path": "wsh://" + testConn + "/~/a.txt"}}},
+			true,
+		},
+		{
+			"ownblock write current conn",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_FileWrite, Data: map[string]any{"info": map[string]any{"path": "notes/a.txt"}}},
+			true,
+		},
+		{
+			"ownblock write other conn",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_FileWrite, Data: map[string]any{"info": map[string]any{"path": "wsh://user@otherhost/~/.bashrc"}}},
+			false,
+		},
+		{
+			"ownblock write local",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_FileWrite, Data: map[string]any{"info": map[string]any{"path": "/~/.bashrc"}}},
+			false,
+		},
+		{
+			"ownblock delete other conn",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: "filedelete", Data: map[string]any{"path": "wsh://local/etc/hosts"}},
+			false,
+		},
+		{
+			"ownblock copy to other conn",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_FileCopy, Data: map[string]any{"srcuri": "wsh://" + testConn + "/~/a.txt", "desturi": "sftp://user@otherhost/~/a.txt"}},
+			false,
+		},
+		{
+			"ownblock s3",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_FileRead, Data: map[string]any{"info": map[string]any{"path": "s3://bucket/key"}}},
+			false,
+		},
+		{
+			"ownblock own starfile",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_FileWrite, Data: map[string]any{"info": map[string]any{"path": "starfile://" + testBlockId + "/a.txt"}}},
+			true,
+		},
+		{
+			"ownblock other starfile",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_FileWrite, Data: map[string]any{"info": map[string]any{"path": "starfile://" + testOtherBlockId + "/a.txt"}}},
+			false,
+		},
+		{
+			"ownblock trash other conn",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_FileTrashEmpty, Data: map[string]any{"uri": "wsh://user@otherhost/"}},
+			false,
+		},
+		{
+			"ownblock join other conn",
+			[]string{wshrpc.RouteCap_OwnBlock},
+			RpcMessage{Command: wshrpc.Command_FileJoin, Data: []any{"wsh://user@otherhost/~", "a.txt"}},
+			false,
+		},
+		{
+			"lift own caps",
+			[]string{wshrpc.RouteCap_NoAi},
+			RpcMessage{Command: wshrpc.Command_SetMeta, Data: map[string]any{"meta": map[string]any{"cmd:wshcaps": nil}}},
+			false,
+		},
+	}
+	for _, test := range tests {
+		rpcCtx := &wshrpc.RpcContext{BlockId: testBlockId, TabId: testTabId, Conn: testConn, Caps: test.caps}
+		msg := test.msg
+		err := ApplyRouteCaps(rpcCtx, "proc:test", &msg)
+		if (err == nil) != test.allowed {
+			t.Errorf("%s: expected allowed=%v, got error %v", test.name, test.allowed, err)
+		}
+	}
+}
+
+func TestInheritRouteCaps(t *testing.T) {
+	rpcCtx := &wshrpc.RpcContext{BlockId: testBlockId, TabId: testTabId, Caps: []string{wshrpc.RouteCap_NoAi, wshrpc.RouteCap_OwnBlock}}
+	msg := RpcMessage{
+		Command: wshrpc.Command_CreateBlock,
+		Data:    map[string]any{"tabid": testTabId, "blockdef": map[string]any{"meta": map[string]any{"view": "term", "cmd:wshcaps": []any{"readonly"}}}},
+	}
+	if err := ApplyRouteCaps(rpcCtx, "proc:test", &msg); err != nil {
+		t.Fatalf("ApplyRouteCaps failed: %v", err)
+	}
+	data, ok := msg.Data.(*wshrpc.CommandCreateBlockData)
+	if !ok {
+		t.Fatalf("unexpected data type %T", msg.Data)
+	}
+	caps := data.BlockDef.Meta.GetStringList(starobj.MetaKey_CmdWshCaps)
+	if len(caps) != 3 || caps[0] != "readonly" || caps[1] != wshrpc.RouteCap_NoAi || caps[2] != wshrpc.RouteCap_OwnBlock {
+		t.Errorf("unexpected inherited caps %v", caps)
+	}
+}
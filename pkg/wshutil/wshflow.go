@@ -0,0 +1,286 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/commandlinedev/starterm/pkg/panichandler"
+)
+
+// flow control for streaming responses.
+//
+// the requestor sets "window" on a responsestream command, the number of responses it accepts before granting more.
+// a responder that supports flow control echoes "window" on its first response and then pauses whenever it runs out of credits.
+// the requestor grants credits (packets with reqid and "credits") as responses are consumed, but only after it saw the echo,
+// older responders never echo it (and would treat a credit packet as an unknown command).
+//
+// outgoing messages are scheduled (see outputScheduler), streaming responses queue per stream and take turns while
+// requests and other responses go first, so a bulk transfer cannot queue up in front of interactive traffic.
+// WshRpc schedules its own output, and the proxies schedule the links they share between routes.
+
+// the window fits in the response channel, so a flow controlled stream never blocks runServer (see sendRespWithBlockMessage)
+const DefaultStreamWindow = RespChSize
+
+const outputPriorityQueueSize = DefaultOutputChSize
+const outputStreamQueueSize = DefaultStreamWindow
+
+var errOutputClosed = errors.New("rpc output is closed")
+
+// responder side of a flow controlled stream
+type streamFlow struct {
+	window   int64
+	credits  *atomic.Int64
+	creditCh chan struct{} // signaled when credits are granted
+	acked    bool          // window was echoed (only touched by the sending goroutine)
+}
+
+func makeStreamFlow(window int64) *streamFlow {
+	if window <= 0 {
+		return nil
+	}
+	if window > DefaultStreamWindow {
+		window = DefaultStreamWindow
+	}
+	flow := &streamFlow{window: window, credits: &atomic.Int64{}, creditCh: make(chan struct{}, 1)}
+	flow.credits.Store(window)
+	return flow
+}
+
+func (flow *streamFlow) grant(credits int64) {
+	flow.credits.Add(credits)
+	select {
+	case flow.creditCh <- struct{}{}:
+	default:
+	}
+}
+
+// blocks until there is a credit to send a response (or ctx is done, or the requestor canceled)
+func (flow *streamFlow) takeCredit(ctx context.Context, canceled *atomic.Bool) error {
+	for {
+		if canceled.Load() {
+			return errors.New("stream canceled by requestor")
+		}
+		if flow.credits.Add(-1) >= 0 {
+			return nil
+		}
+		flow.credits.Add(1)
+		select {
+		case <-flow.creditCh:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// requestor side of a flow controlled stream
+type streamCredits struct {
+	window   int64
+	acked    bool
+	consumed int64
+}
+
+// called for each response the consumer takes, returns the credits to grant (0 for none yet)
+func (sc *streamCredits) consume(resp *RpcMessage) int64 {
+	if resp.Window > 0 {
+		sc.acked = true
+	}
+	if !sc.acked || !resp.Cont {
+		return 0
+	}
+	sc.consumed++
+	if sc.consumed < (sc.window+1)/2 {
+		return 0
+	}
+	rtn := sc.consumed
+	sc.consumed = 0
+	return rtn
+}
+
+func (w *WshRpc) grantStreamCredits(reqId string, credits int64) {
+	w.Lock.Lock()
+	handler := w.ResponseHandlerMap[reqId]
+	w.Lock.Unlock()
+	if handler == nil || handler.flow == nil {
+		return
+	}
+	handler.flow.grant(credits)
+}
+
+func (handler *RpcRequestHandler) sendCredits(credits int64) {
+	msg := &RpcMessage{
+		ReqId:     handler.reqId,
+		Credits:   credits,
+		AuthToken: handler.w.GetAuthToken(),
+	}
+	barr, _ := json.Marshal(msg) // will never fail
+	handler.w.sendOutput(barr)
+}
+
+// orders the messages going out on a link.  requests and other responses go first, streaming responses ("bulk") wait
+// in per-stream queues that take turns.  messages are handed out one at a time by pump, the next message is only
+// picked once the output took the previous one, so at most one bulk message is ever ahead of a queued request.
+type outputScheduler struct {
+	lock     *sync.Mutex
+	priority [][]byte
+	streams  map[string][][]byte // resid => queued streaming responses
+	turns    []string            // streams with queued responses, in turn order
+	readyCh  chan struct{}       // signaled when a message is queued (or the scheduler is closed)
+	spaceCh  chan struct{}       // closed when a message is taken and a sender is waiting for room
+	waiting  bool
+	closed   bool
+}
+
+func makeOutputScheduler() *outputScheduler {
+	return &outputScheduler{
+		lock:    &sync.Mutex{},
+		streams: make(map[string][][]byte),
+		readyCh: make(chan struct{}, 1),
+		spaceCh: make(chan struct{}),
+	}
+}
+
+// gets the stream a raw message belongs to, for links that only see the bytes
+func getRpcMsgStream(msgBytes []byte) (string, bool) {
+	var msg struct {
+		ResId string `json:"resid"`
+		Cont  bool   `json:"cont"`
+	}
+	if err := json.Unmarshal(msgBytes, &msg); err != nil {
+		return "", false
+	}
+	return msg.ResId, msg.Cont
+}
+
+// queues msg, waiting while its queue is full.  bulk is set for streaming responses (which need a resid).
+// a response for a stream that still has queued messages goes behind them, so the final response stays last.
+func (s *outputScheduler) queue(ctx context.Context, resId string, bulk bool, msg []byte) error {
+	if resId == "" {
+		bulk = false
+	}
+	for {
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			return errOutputClosed
+		}
+		var numQueued int
+		if resId != "" {
+			numQueued = len(s.streams[resId])
+		}
+		if (bulk && numQueued < outputStreamQueueSize) || (!bulk && numQueued > 0) {
+			if numQueued == 0 {
+				s.turns = append(s.turns, resId)
+			}
+			s.streams[resId] = append(s.streams[resId], msg)
+			s.lock.Unlock()
+			s.signalReady()
+			return nil
+		}
+		if !bulk && len(s.priority) < outputPriorityQueueSize {
+			s.priority = append(s.priority, msg)
+			s.lock.Unlock()
+			s.signalReady()
+			return nil
+		}
+		s.waiting = true
+		spaceCh := s.spaceCh
+		s.lock.Unlock()
+		select {
+		case <-spaceCh:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *outputScheduler) signalReady() {
+	select {
+	case s.readyCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *outputScheduler) takeLocked() ([]byte, bool) {
+	var msg []byte
+	if len(s.priority) > 0 {
+		msg = s.priority[0]
+		s.priority[0] = nil
+		s.priority = s.priority[1:]
+	} else if len(s.turns) > 0 {
+		resId := s.turns[0]
+		s.turns = s.turns[1:]
+		streamQueue := s.streams[resId]
+		msg = streamQueue[0]
+		streamQueue[0] = nil
+		if len(streamQueue) == 1 {
+			delete(s.streams, resId)
+		} else {
+			s.streams[resId] = streamQueue[1:]
+			s.turns = append(s.turns, resId)
+		}
+	} else {
+		return nil, false
+	}
+	if s.waiting {
+		close(s.spaceCh)
+		s.spaceCh = make(chan struct{})
+		s.waiting = false
+	}
+	return msg, true
+}
+
+// blocks until there is a message to send, returns false once the scheduler is closed and drained
+func (s *outputScheduler) next() ([]byte, bool) {
+	for {
+		s.lock.Lock()
+		msg, ok := s.takeLocked()
+		closed := s.closed
+		s.lock.Unlock()
+		if ok {
+			return msg, true
+		}
+		if closed {
+			return nil, false
+		}
+		<-s.readyCh
+	}
+}
+
+// hands the messages to out until the scheduler is closed and drained (the caller closes out)
+func (s *outputScheduler) pump(out chan []byte) {
+	defer func() {
+		panichandler.PanicHandler("outputScheduler:pump", recover())
+		s.close(true)
+	}()
+	for {
+		msg, ok := s.next()
+		if !ok {
+			return
+		}
+		out <- msg
+	}
+}
+
+// new messages are refused after close, queued messages are still sent unless discard is set
+func (s *outputScheduler) close(discard bool) {
+	s.lock.Lock()
+	s.closed = true
+	if discard {
+		s.priority = nil
+		s.streams = make(map[string][][]byte)
+		s.turns = nil
+	}
+	if s.waiting {
+		close(s.spaceCh)
+		s.spaceCh = make(chan struct{})
+		s.waiting = false
+	}
+	s.lock.Unlock()
+	s.signalReady()
+}
@@ -0,0 +1,169 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+const flowTestCount = 200
+
+type flowTestServer struct {
+	produced *atomic.Int64
+	testDone chan struct{}
+}
+
+func (*flowTestServer) WshServerImpl() {}
+
+func (s *flowTestServer) StreamTestCommand(ctx context.Context) chan wshrpc.RespOrErrorUnion[int] {
+	ch := make(chan wshrpc.RespOrErrorUnion[int])
+	go func() {
+		defer close(ch)
+		for i := 0; i < flowTestCount; i++ {
+			select {
+			case ch <- wshrpc.RespOrErrorUnion[int]{Response: i}:
+				s.produced.Add(1)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (s *flowTestServer) TestCommand(ctx context.Context, data string) error {
+	close(s.testDone)
+	return nil
+}
+
+func TestStreamFlowControl(t *testing.T) {
+	toServer := make(chan []byte, DefaultInputChSize)
+	toClient := make(chan []byte, DefaultOutputChSize)
+	server := &flowTestServer{produced: &atomic.Int64{}, testDone: make(chan struct{})}
+	MakeWshRpc(toServer, toClient, wshrpc.RpcContext{}, server, "flowtest-server")
+	client := MakeWshRpc(toClient, toServer, wshrpc.RpcContext{}, nil, "flowtest-client")
+	handler, err := client.SendComplexRequest(wshrpc.Command_StreamTest, nil, &wshrpc.RpcOpts{Timeout: 10000})
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer handler.finalize()
+	// the responder keeps at most a window of responses (plus the ones held by the adapter and producer) in flight
+	maxAhead := int64(DefaultStreamWindow + 2)
+	for consumed := int64(0); consumed < flowTestCount; consumed++ {
+		if consumed%50 == 0 {
+			// slow consumer, give the producer time to run ahead
+			time.Sleep(50 * time.Millisecond)
+		}
+		if ahead := server.produced.Load() - consumed; ahead > maxAhead {
+			t.Fatalf("producer ran %d responses ahead of the consumer (window %d)", ahead, DefaultStreamWindow)
+		}
+		resp, err := handler.NextResponse()
+		if err != nil {
+			t.Fatalf("response %d failed: %v", consumed, err)
+		}
+		if n, ok := resp.(float64); !ok || int64(n) != consumed {
+			t.Fatalf("unexpected response %v, expected %d", resp, consumed)
+		}
+	}
+}
+
+func TestOutputScheduler(t *testing.T) {
+	s := makeOutputScheduler()
+	ctx := context.Background()
+	queue := func(resId string, bulk bool, msg string) {
+		if err := s.queue(ctx, resId, bulk, []byte(msg)); err != nil {
+			t.Fatalf("error queuing %q: %v", msg, err)
+		}
+	}
+	queue("a", true, "a1")
+	queue("a", true, "a2")
+	queue("b", true, "b1")
+	queue("a", false, "a-done") // behind the queued responses of stream a
+	queue("", false, "req")
+	queue("c", false, "c-done") // stream c has nothing queued
+	s.close(false)
+	if err := s.queue(ctx, "", false, []byte("late")); err == nil {
+		t.Fatalf("message queued after close")
+	}
+	var got []string
+	for {
+		msg, ok := s.next()
+		if !ok {
+			break
+		}
+		got = append(got, string(msg))
+	}
+	want := []string{"req", "c-done", "a1", "b1", "a2", "a-done"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got order %v, want %v", got, want)
+	}
+}
+
+// a bulk stream and an interactive request share a proxy link (like the routes behind a connserver do),
+// the response to the request must not wait behind the queued stream responses
+func TestStreamSharedLink(t *testing.T) {
+	toServer := make(chan []byte, DefaultInputChSize)
+	serverOut := make(chan []byte, DefaultOutputChSize)
+	toClient := make(chan []byte, DefaultOutputChSize)
+	server := &flowTestServer{produced: &atomic.Int64{}, testDone: make(chan struct{})}
+	MakeWshRpc(toServer, serverOut, wshrpc.RpcContext{}, server, "sharedlink-server")
+	link := MakeRpcProxy()
+	defer link.Close()
+	go func() {
+		for msg := range serverOut {
+			link.SendRpcMessage(msg)
+		}
+	}()
+	client := MakeWshRpc(toClient, toServer, wshrpc.RpcContext{}, nil, "sharedlink-client")
+	handler, err := client.SendComplexRequest(wshrpc.Command_StreamTest, nil, &wshrpc.RpcOpts{Timeout: 10000})
+	if err != nil {
+		t.Fatalf("stream request failed: %v", err)
+	}
+	defer handler.finalize()
+	// nothing reads the link yet, wait for the stream to fill its window
+	for i := 0; server.produced.Load() < DefaultStreamWindow; i++ {
+		if i > 500 {
+			t.Fatalf("stream only produced %d responses", server.produced.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.SendRpcRequest(wshrpc.Command_Test, "ping", &wshrpc.RpcOpts{Timeout: 10000})
+		errCh <- err
+	}()
+	select {
+	case <-server.testDone:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("test command was not handled")
+	}
+	// give the response time to reach the link
+	time.Sleep(50 * time.Millisecond)
+	numBulk := 0
+	for {
+		msgBytes := <-link.ToRemoteCh
+		toClient <- msgBytes
+		var msg RpcMessage
+		if err := json.Unmarshal(msgBytes, &msg); err != nil {
+			t.Fatalf("bad message on link: %v", err)
+		}
+		if !msg.Cont {
+			break
+		}
+		numBulk++
+	}
+	if numBulk > 1 {
+		t.Fatalf("response waited behind %d stream responses", numBulk)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("test command failed: %v", err)
+	}
+}
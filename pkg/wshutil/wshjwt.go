@@ -0,0 +1,293 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshutil
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// the signing keys for wsh route tokens live in the data dir (never the config dir) with 0600 perms.
+// tokens carry the key id in the "kid" header.  after a rotation the previous keys are kept
+// (retired) until every token they could have signed has expired, unless they are revoked.
+
+// block tokens are never re-issued, they have to outlive the shell that holds them.
+// connserver tokens are short lived, the rekey loop sends fresh ones to connected connservers.
+const JwtTokenLifetime = 365 * 24 * time.Hour
+const JwtConnServerTokenLifetime = 30 * 24 * time.Hour
+const JwtSecretNumBytes = 32
+const JwtKeyIdHexDigits = 16
+
+type jwtKey struct {
+	KeyId     string `json:"kid"`
+	Secret64  string `json:"secret64"`
+	CreatedTs int64  `json:"createdts"`
+	RetiredTs int64  `json:"retiredts,omitempty"`
+
+	secret []byte
+}
+
+type jwtKeyFile struct {
+	CurrentKeyId string    `json:"currentkeyid"`
+	Keys         []*jwtKey `json:"keys"`
+}
+
+var jwtKeyLock = &sync.Mutex{}
+var jwtKeys *jwtKeyFile
+
+func getJwtKeysFileName() string {
+	return filepath.Join(starbase.GetStarDataDir(), starbase.JwtKeysFileName)
+}
+
+func makeJwtKey() (*jwtKey, error) {
+	secret := make([]byte, JwtSecretNumBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("error generating jwt secret: %w", err)
+	}
+	keyId, err := utilfn.RandomHexString(JwtKeyIdHexDigits)
+	if err != nil {
+		return nil, fmt.Errorf("error generating jwt key id: %w", err)
+	}
+	return &jwtKey{
+		KeyId:     keyId,
+		Secret64:  base64.StdEncoding.EncodeToString(secret),
+		CreatedTs: time.Now().UnixMilli(),
+		secret:    secret,
+	}, nil
+}
+
+func getJwtTokenLifetime(clientType string) time.Duration {
+	if clientType == wshrpc.ClientType_ConnServer {
+		return JwtConnServerTokenLifetime
+	}
+	return JwtTokenLifetime
+}
+
+// a retired key is only useful while a token it signed can still be valid (block tokens have the longest lifetime)
+func (kf *jwtKeyFile) pruneRetired_nolock() {
+	cutoff := time.Now().Add(-JwtTokenLifetime).UnixMilli()
+	var keys []*jwtKey
+	for _, key := range kf.Keys {
+		if key.KeyId != kf.CurrentKeyId && key.RetiredTs > 0 && key.RetiredTs < cutoff {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	kf.Keys = keys
+}
+
+func (kf *jwtKeyFile) getKey_nolock(keyId string) *jwtKey {
+	for _, key := range kf.Keys {
+		if key.KeyId == keyId {
+			return key
+		}
+	}
+	return nil
+}
+
+func writeJwtKeyFile_nolock(kf *jwtKeyFile) error {
+	barr, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling jwt keys: %w", err)
+	}
+	fileName := getJwtKeysFileName()
+	tempName := fileName + ".new"
+	err = os.WriteFile(tempName, barr, 0600)
+	if err != nil {
+		return fmt.Errorf("error writing jwt keys file: %w", err)
+	}
+	// WriteFile does not change the perms of an existing file
+	err = os.Chmod(tempName, 0600)
+	if err != nil {
+		return fmt.Errorf("error setting jwt keys file permissions: %w", err)
+	}
+	err = os.Rename(tempName, fileName)
+	if err != nil {
+		return fmt.Errorf("error renaming jwt keys file: %w", err)
+	}
+	return nil
+}
+
+func readJwtKeyFile_nolock() (*jwtKeyFile, error) {
+	fileName := getJwtKeysFileName()
+	finfo, err := os.Stat(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if finfo.Mode().Perm()&0077 != 0 {
+		log.Printf("jwt keys file %q has insecure permissions %v, fixing\n", fileName, finfo.Mode().Perm())
+		err = os.Chmod(fileName, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("error fixing jwt keys file permissions: %w", err)
+		}
+	}
+	barr, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var kf jwtKeyFile
+	err = json.Unmarshal(barr, &kf)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing jwt keys file: %w", err)
+	}
+	for _, key := range kf.Keys {
+		key.secret, err = base64.StdEncoding.DecodeString(key.Secret64)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding jwt secret for key %q: %w", key.KeyId, err)
+		}
+		if len(key.secret) < JwtSecretNumBytes {
+			return nil, fmt.Errorf("jwt secret for key %q is too short", key.KeyId)
+		}
+	}
+	if kf.getKey_nolock(kf.CurrentKeyId) == nil {
+		return nil, fmt.Errorf("jwt keys file has no current key")
+	}
+	return &kf, nil
+}
+
+func ensureJwtKeys_nolock() error {
+	if jwtKeys != nil {
+		return nil
+	}
+	if starbase.GetStarDataDir() == "" {
+		return fmt.Errorf("jwt keys are only available in the star server")
+	}
+	kf, err := readJwtKeyFile_nolock()
+	if err == nil {
+		kf.pruneRetired_nolock()
+		jwtKeys = kf
+		return nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		// a corrupt file invalidates every outstanding token anyway, so start over with a new key
+		log.Printf("error reading jwt keys (will generate a new key): %v\n", err)
+	}
+	key, err := makeJwtKey()
+	if err != nil {
+		return err
+	}
+	kf = &jwtKeyFile{CurrentKeyId: key.KeyId, Keys: []*jwtKey{key}}
+	err = writeJwtKeyFile_nolock(kf)
+	if err != nil {
+		return err
+	}
+	log.Printf("generated new jwt signing key %s\n", key.KeyId)
+	jwtKeys = kf
+	return nil
+}
+
+// loads (or generates) the per-installation jwt signing keys, must be called by the server at startup
+func InitJwtKeys() error {
+	jwtKeyLock.Lock()
+	defer jwtKeyLock.Unlock()
+	return ensureJwtKeys_nolock()
+}
+
+// returns (keyid, secret, error)
+func getJwtSigningKey() (string, []byte, error) {
+	jwtKeyLock.Lock()
+	defer jwtKeyLock.Unlock()
+	err := ensureJwtKeys_nolock()
+	if err != nil {
+		return "", nil, err
+	}
+	key := jwtKeys.getKey_nolock(jwtKeys.CurrentKeyId)
+	return key.KeyId, key.secret, nil
+}
+
+func getJwtVerifyKey(keyId string) ([]byte, error) {
+	jwtKeyLock.Lock()
+	defer jwtKeyLock.Unlock()
+	err := ensureJwtKeys_nolock()
+	if err != nil {
+		return nil, err
+	}
+	key := jwtKeys.getKey_nolock(keyId)
+	if key == nil {
+		return nil, fmt.Errorf("unknown or revoked key id %q", keyId)
+	}
+	return key.secret, nil
+}
+
+// generates a new signing key.  previous keys keep validating their tokens until those tokens expire.
+// if revokeOld is true, previous keys are dropped immediately and all tokens they signed stop working.
+// returns the new key id
+func RotateJwtKey(revokeOld bool) (string, error) {
+	jwtKeyLock.Lock()
+	defer jwtKeyLock.Unlock()
+	err := ensureJwtKeys_nolock()
+	if err != nil {
+		return "", err
+	}
+	newKey, err := makeJwtKey()
+	if err != nil {
+		return "", err
+	}
+	newKeys := &jwtKeyFile{CurrentKeyId: newKey.KeyId}
+	if !revokeOld {
+		for _, key := range jwtKeys.Keys {
+			keyCopy := *key
+			if keyCopy.RetiredTs == 0 {
+				keyCopy.RetiredTs = newKey.CreatedTs
+			}
+			newKeys.Keys = append(newKeys.Keys, &keyCopy)
+		}
+	}
+	newKeys.Keys = append(newKeys.Keys, newKey)
+	newKeys.pruneRetired_nolock()
+	err = writeJwtKeyFile_nolock(newKeys)
+	if err != nil {
+		return "", err
+	}
+	jwtKeys = newKeys
+	log.Printf("rotated jwt signing key, new key %s (revoke-old:%v)\n", newKey.KeyId, revokeOld)
+	return newKey.KeyId, nil
+}
+
+// checks the claims of a token on the client, which cannot verify the signature (the server does that when the token is used to authenticate).
+// catches tokens that are malformed, expired, or not made by MakeClientJWTToken.
+func ValidateUnverifiedJwtToken(tokenStr string) (*wshrpc.RpcContext, error) {
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenStr, jwt.MapClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("error parsing token: %w", err)
+	}
+	if token.Method.Alg() != jwt.SigningMethodHS256.Name {
+		return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+	}
+	if keyId, ok := token.Header["kid"].(string); !ok || keyId == "" {
+		return nil, fmt.Errorf("kid header is missing or invalid")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("error getting claims from token")
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil, fmt.Errorf("exp claim is missing or invalid")
+	}
+	if !exp.After(time.Now()) {
+		return nil, fmt.Errorf("token has expired")
+	}
+	if iss, _ := claims["iss"].(string); iss != "starterm" {
+		return nil, fmt.Errorf("unexpected issuer: %q", iss)
+	}
+	if sockName, _ := claims["sock"].(string); sockName == "" {
+		return nil, fmt.Errorf("sock claim is missing or invalid")
+	}
+	return mapClaimsToRpcContext(claims), nil
+}
@@ -0,0 +1,289 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshutil
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/starbase"
+	"github.com/commandlinedev/starterm/pkg/util/utilfn"
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// points the jwt keys at a temp data dir and drops the cached keys
+func initTestJwtKeys(t *testing.T) {
+	oldDataDir := starbase.DataHome_VarCache
+	starbase.DataHome_VarCache = t.TempDir()
+	jwtKeyLock.Lock()
+	jwtKeys = nil
+	jwtKeyLock.Unlock()
+	t.Cleanup(func() {
+		starbase.DataHome_VarCache = oldDataDir
+		jwtKeyLock.Lock()
+		jwtKeys = nil
+		jwtKeyLock.Unlock()
+	})
+	if err := InitJwtKeys(); err != nil {
+		t.Fatalf("error initializing jwt keys: %v", err)
+	}
+}
+
+func makeTestConnServerToken(t *testing.T, conn string) string {
+	token, err := MakeClientJWTToken(wshrpc.RpcContext{ClientType: wshrpc.ClientType_ConnServer, Conn: conn}, "~/.starterm/star-remote.sock")
+	if err != nil {
+		t.Fatalf("error making token: %v", err)
+	}
+	return token
+}
+
+// signs claims with the current key, for tokens MakeClientJWTToken would not make
+func signTestClaims(t *testing.T, claims jwt.MapClaims) string {
+	keyId, secret, err := getJwtSigningKey()
+	if err != nil {
+		t.Fatalf("error getting signing key: %v", err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = keyId
+	tokenStr, err := token.SignedString(secret)
+	if err != nil {
+		t.Fatalf("error signing token: %v", err)
+	}
+	return tokenStr
+}
+
+func TestJwtKeyRotation(t *testing.T) {
+	initTestJwtKeys(t)
+	oldToken := makeTestConnServerToken(t, testConn)
+	newKeyId, err := RotateJwtKey(false)
+	if err != nil {
+		t.Fatalf("error rotating key: %v", err)
+	}
+	newToken := makeTestConnServerToken(t, testConn)
+	parsed, _, err := new(jwt.Parser).ParseUnverified(newToken, jwt.MapClaims{})
+	if err != nil || parsed.Header["kid"] != newKeyId {
+		t.Fatalf("new token is not signed with the new key %q (err %v)", newKeyId, err)
+	}
+	for name, token := range map[string]string{"previous key": oldToken, "new key": newToken} {
+		rpcCtx, err := ValidateAndExtractRpcContextFromToken(token)
+		if err != nil {
+			t.Fatalf("%s: token rejected: %v", name, err)
+		}
+		if rpcCtx.Conn != testConn || rpcCtx.ClientType != wshrpc.ClientType_ConnServer {
+			t.Fatalf("%s: unexpected context %#v", name, rpcCtx)
+		}
+	}
+
+	// the keys survive a restart
+	jwtKeyLock.Lock()
+	jwtKeys = nil
+	jwtKeyLock.Unlock()
+	if _, err := ValidateAndExtractRpcContextFromToken(oldToken); err != nil {
+		t.Fatalf("previous key not accepted after reload: %v", err)
+	}
+	finfo, err := os.Stat(getJwtKeysFileName())
+	if err != nil || finfo.Mode().Perm() != 0600 {
+		t.Fatalf("jwt keys file perms %v (err %v), want 0600", finfo.Mode().Perm(), err)
+	}
+
+	if _, err := RotateJwtKey(true); err != nil {
+		t.Fatalf("error rotating key with revoke: %v", err)
+	}
+	for name, token := range map[string]string{"first key": oldToken, "second key": newToken} {
+		if _, err := ValidateAndExtractRpcContextFromToken(token); err == nil {
+			t.Fatalf("%s: token accepted after its key was revoked", name)
+		}
+	}
+}
+
+func TestJwtPruneRetired(t *testing.T) {
+	initTestJwtKeys(t)
+	oldToken := makeTestConnServerToken(t, testConn)
+	if _, err := RotateJwtKey(false); err != nil {
+		t.Fatalf("error rotating key: %v", err)
+	}
+	// age the retired key past the token lifetime, nothing it signed can still be valid
+	jwtKeyLock.Lock()
+	for _, key := range jwtKeys.Keys {
+		if key.KeyId != jwtKeys.CurrentKeyId {
+			key.RetiredTs = time.Now().Add(-JwtTokenLifetime - time.Hour).UnixMilli()
+		}
+	}
+	jwtKeyLock.Unlock()
+	if _, err := RotateJwtKey(false); err != nil {
+		t.Fatalf("error rotating key: %v", err)
+	}
+	jwtKeyLock.Lock()
+	numKeys := len(jwtKeys.Keys)
+	jwtKeyLock.Unlock()
+	if numKeys != 2 {
+		t.Fatalf("got %d keys after pruning, want 2", numKeys)
+	}
+	if _, err := ValidateAndExtractRpcContextFromToken(oldToken); err == nil {
+		t.Fatalf("token of a pruned key accepted")
+	}
+}
+
+func TestJwtTokenExpiry(t *testing.T) {
+	initTestJwtKeys(t)
+	baseClaims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iat":   time.Now().Unix(),
+			"iss":   "starterm",
+			"sock":  "~/.starterm/star-remote.sock",
+			"ctype": wshrpc.ClientType_ConnServer,
+			"conn":  testConn,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	tests := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+		errStr string // "" if the token is valid
+	}{
+		{"valid", func(claims jwt.MapClaims) {}, ""},
+		{"expired", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() }, "expired"},
+		{"no exp", func(claims jwt.MapClaims) { delete(claims, "exp") }, "exp"},
+		{"wrong issuer", func(claims jwt.MapClaims) { claims["iss"] = "other" }, "issuer"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := baseClaims()
+			tc.modify(claims)
+			token := signTestClaims(t, claims)
+			for name, validate := range map[string]func(string) (*wshrpc.RpcContext, error){
+				"verified":   ValidateAndExtractRpcContextFromToken,
+				"unverified": ValidateUnverifiedJwtToken,
+			} {
+				_, err := validate(token)
+				if tc.errStr == "" && err != nil {
+					t.Fatalf("%s: unexpected error: %v", name, err)
+				}
+				if tc.errStr != "" && (err == nil || !strings.Contains(err.Error(), tc.errStr)) {
+					t.Fatalf("%s: got error %v, want one containing %q", name, err, tc.errStr)
+				}
+			}
+		})
+	}
+	// the lifetimes are what MakeClientJWTToken sets, block tokens are never refreshed so they outlive connserver tokens
+	blockToken, err := MakeClientJWTToken(wshrpc.RpcContext{BlockId: "block-1", TabId: "tab-1"}, "~/.starterm/star.sock")
+	if err != nil {
+		t.Fatalf("error making block token: %v", err)
+	}
+	for token, lifetime := range map[string]time.Duration{
+		makeTestConnServerToken(t, testConn): JwtConnServerTokenLifetime,
+		blockToken:                           JwtTokenLifetime,
+	} {
+		parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+		if err != nil {
+			t.Fatalf("error parsing token: %v", err)
+		}
+		exp, err := parsed.Claims.GetExpirationTime()
+		if err != nil || exp.Sub(time.Now()) < lifetime-time.Minute || exp.Sub(time.Now()) > lifetime {
+			t.Fatalf("token expires at %v (err %v), want about %v from now", exp, err, lifetime)
+		}
+	}
+}
+
+func TestJwtBlockTokenNearExpiry(t *testing.T) {
+	initTestJwtKeys(t)
+	// a block token from a shell that has been open for almost the whole token lifetime
+	issued := time.Now().Add(-JwtTokenLifetime + time.Hour)
+	blockToken := signTestClaims(t, jwt.MapClaims{
+		"iat":     issued.Unix(),
+		"iss":     "starterm",
+		"sock":    "~/.starterm/star.sock",
+		"blockid": "block-1",
+		"tabid":   "tab-1",
+		"exp":     issued.Add(JwtTokenLifetime).Unix(),
+	})
+	// rotate and age the retired key past the connserver lifetime, it must be kept for the block token
+	if _, err := RotateJwtKey(false); err != nil {
+		t.Fatalf("error rotating key: %v", err)
+	}
+	jwtKeyLock.Lock()
+	for _, key := range jwtKeys.Keys {
+		if key.KeyId != jwtKeys.CurrentKeyId {
+			key.RetiredTs = issued.UnixMilli()
+		}
+	}
+	jwtKeyLock.Unlock()
+	if _, err := RotateJwtKey(false); err != nil {
+		t.Fatalf("error rotating key: %v", err)
+	}
+	rpcCtx, err := ValidateAndExtractRpcContextFromToken(blockToken)
+	if err != nil {
+		t.Fatalf("block token near expiry rejected: %v", err)
+	}
+	if rpcCtx.BlockId != "block-1" || rpcCtx.TabId != "tab-1" {
+		t.Fatalf("unexpected context %#v", rpcCtx)
+	}
+}
+
+func TestValidateUnverifiedJwtToken(t *testing.T) {
+	initTestJwtKeys(t)
+	rpcCtx, err := ValidateUnverifiedJwtToken(makeTestConnServerToken(t, testConn))
+	if err != nil || rpcCtx.Conn != testConn {
+		t.Fatalf("valid token: context %#v, err %v", rpcCtx, err)
+	}
+	noSock := signTestClaims(t, jwt.MapClaims{"iss": "starterm", "exp": time.Now().Add(time.Hour).Unix(), "conn": testConn})
+	if _, err := ValidateUnverifiedJwtToken(noSock); err == nil {
+		t.Fatalf("token without a sock claim accepted")
+	}
+	noKid := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": "starterm", "exp": time.Now().Add(time.Hour).Unix(), "sock": "x"})
+	noKidStr, _ := noKid.SignedString([]byte("not-the-secret"))
+	if _, err := ValidateUnverifiedJwtToken(noKidStr); err == nil {
+		t.Fatalf("token without a kid header accepted")
+	}
+	if _, err := ValidateUnverifiedJwtToken("not-a-token"); err == nil {
+		t.Fatalf("garbage accepted")
+	}
+}
+
+func TestMultiProxyReauth(t *testing.T) {
+	initTestJwtKeys(t)
+	proxy := MakeRpcMultiProxy()
+	routeInfo := &multiProxyRouteInfo{
+		RouteId:    MakeConnectionRouteId(testConn),
+		AuthToken:  "test-auth-token",
+		Proxy:      MakeRpcProxy(),
+		RpcContext: &wshrpc.RpcContext{ClientType: wshrpc.ClientType_ConnServer, Conn: testConn},
+	}
+	proxy.setRouteInfo(routeInfo.AuthToken, routeInfo)
+	if _, err := RotateJwtKey(false); err != nil {
+		t.Fatalf("error rotating key: %v", err)
+	}
+	reauth := func(authToken string, jwtToken string) RpcMessage {
+		msg := RpcMessage{Command: wshrpc.Command_Authenticate, ReqId: "req", AuthToken: authToken, Data: jwtToken}
+		msgBytes, _ := json.Marshal(msg)
+		proxy.handleUnauthMessage(msgBytes)
+		var resp RpcMessage
+		if err := json.Unmarshal(<-proxy.ToRemoteCh, &resp); err != nil {
+			t.Fatalf("error parsing response: %v", err)
+		}
+		return resp
+	}
+
+	resp := reauth(routeInfo.AuthToken, makeTestConnServerToken(t, testConn))
+	var rtnData wshrpc.CommandAuthenticateRtnData
+	if err := utilfn.ReUnmarshal(&rtnData, resp.Data); resp.Error != "" || err != nil {
+		t.Fatalf("re-authentication failed: %s %v", resp.Error, err)
+	}
+	if rtnData.RouteId != routeInfo.RouteId || rtnData.AuthToken != routeInfo.AuthToken {
+		t.Fatalf("re-authentication changed the route: %#v", rtnData)
+	}
+	if resp := reauth(routeInfo.AuthToken, makeTestConnServerToken(t, "user@otherhost")); resp.Error == "" {
+		t.Fatalf("token for another connection accepted")
+	}
+	if resp := reauth("unknown-auth-token", makeTestConnServerToken(t, testConn)); resp.Error == "" {
+		t.Fatalf("re-authentication with an unknown auth token accepted")
+	}
+	if len(proxy.RouteInfo) != 1 {
+		t.Fatalf("re-authentication added routes: %d", len(proxy.RouteInfo))
+	}
+}
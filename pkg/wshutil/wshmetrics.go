@@ -0,0 +1,259 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshutil
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+	"github.com/google/uuid"
+)
+
+// per process rpc metrics, kept by side (client/server/router), command and route.
+// routes are reduced to their kind ("controller", "proc", ...) except for connections, so the number of series stays small.
+// when tracing is on, requests get a trace id (RpcMessage.TraceId) that is passed along with the request,
+// every process that sees a request with a trace id records a span for it.
+
+var RpcLatencyBucketsMs = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+const maxRpcTraceSpans = 256
+
+type rpcStatsKey struct {
+	side    string
+	command string
+	route   string
+}
+
+type rpcStats struct {
+	count         int64
+	errors        int64
+	inFlight      int64
+	latencySumMs  float64
+	latencyMaxMs  float64
+	latencyCounts []int64
+}
+
+type rpcMetrics struct {
+	lock      *sync.Mutex
+	since     time.Time
+	stats     map[rpcStatsKey]*rpcStats
+	traces    []wshrpc.RpcTraceSpan // ring buffer
+	traceNext int
+}
+
+var globalRpcMetrics = &rpcMetrics{lock: &sync.Mutex{}, since: time.Now(), stats: make(map[rpcStatsKey]*rpcStats)}
+var rpcTracing = &atomic.Bool{}
+
+// a request being timed, finish is safe to call more than once (only the first call counts)
+type rpcCall struct {
+	key      rpcStatsKey
+	traceId  string
+	start    time.Time
+	finished *atomic.Bool
+}
+
+func routeLabel(routeId string) string {
+	if routeId == "" {
+		return DefaultRoute
+	}
+	if strings.HasPrefix(routeId, RoutePrefix_Conn) {
+		return routeId
+	}
+	if prefix, _, found := strings.Cut(routeId, ":"); found {
+		return prefix
+	}
+	return routeId
+}
+
+func (m *rpcMetrics) getStats(key rpcStatsKey) *rpcStats {
+	stats := m.stats[key]
+	if stats == nil {
+		stats = &rpcStats{latencyCounts: make([]int64, len(RpcLatencyBucketsMs)+1)}
+		m.stats[key] = stats
+	}
+	return stats
+}
+
+// returns the trace id for a new request, existing is the id the request already carries
+func getTraceId(existing string) string {
+	if existing != "" || !rpcTracing.Load() {
+		return existing
+	}
+	return uuid.New().String()
+}
+
+func startRpcCall(side string, command string, routeId string, traceId string) *rpcCall {
+	call := &rpcCall{
+		key:      rpcStatsKey{side: side, command: command, route: routeLabel(routeId)},
+		traceId:  traceId,
+		start:    time.Now(),
+		finished: &atomic.Bool{},
+	}
+	globalRpcMetrics.lock.Lock()
+	defer globalRpcMetrics.lock.Unlock()
+	globalRpcMetrics.getStats(call.key).inFlight++
+	return call
+}
+
+func (call *rpcCall) finish(errStr string) {
+	if call == nil || call.finished.Swap(true) {
+		return
+	}
+	durationMs := float64(time.Since(call.start).Microseconds()) / 1000
+	m := globalRpcMetrics
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	stats := m.getStats(call.key)
+	if stats.inFlight > 0 {
+		stats.inFlight--
+	}
+	stats.count++
+	if errStr != "" {
+		stats.errors++
+	}
+	stats.latencySumMs += durationMs
+	stats.latencyMaxMs = max(stats.latencyMaxMs, durationMs)
+	bucket := sort.SearchFloat64s(RpcLatencyBucketsMs, durationMs)
+	stats.latencyCounts[bucket]++
+	if call.traceId == "" {
+		return
+	}
+	span := wshrpc.RpcTraceSpan{
+		TraceId:    call.traceId,
+		Side:       call.key.side,
+		Command:    call.key.command,
+		Route:      call.key.route,
+		StartTs:    call.start.UnixMilli(),
+		DurationMs: durationMs,
+		Error:      errStr,
+	}
+	if len(m.traces) < maxRpcTraceSpans {
+		m.traces = append(m.traces, span)
+	} else {
+		m.traces[m.traceNext] = span
+	}
+	m.traceNext = (m.traceNext + 1) % maxRpcTraceSpans
+}
+
+// counts a request that has no response (so no latency)
+func countRpc(side string, command string, routeId string, failed bool) {
+	m := globalRpcMetrics
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	stats := m.getStats(rpcStatsKey{side: side, command: command, route: routeLabel(routeId)})
+	stats.count++
+	if failed {
+		stats.errors++
+	}
+}
+
+// returns the metrics of this process (name identifies it in the output), optionally turning tracing on/off and resetting the metrics
+func GetRpcStats(name string, data wshrpc.CommandRpcStatsData) *wshrpc.RpcStatsData {
+	if data.Trace != nil {
+		rpcTracing.Store(*data.Trace)
+	}
+	m := globalRpcMetrics
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	rtn := &wshrpc.RpcStatsData{
+		Name:             name,
+		SinceTs:          m.since.UnixMilli(),
+		Tracing:          rpcTracing.Load(),
+		LatencyBucketsMs: RpcLatencyBucketsMs,
+	}
+	for key, stats := range m.stats {
+		rtn.Stats = append(rtn.Stats, wshrpc.RpcCommandStats{
+			Side:          key.side,
+			Command:       key.command,
+			Route:         key.route,
+			Count:         stats.count,
+			Errors:        stats.errors,
+			InFlight:      stats.inFlight,
+			LatencySumMs:  stats.latencySumMs,
+			LatencyMaxMs:  stats.latencyMaxMs,
+			LatencyCounts: append([]int64(nil), stats.latencyCounts...),
+		})
+	}
+	sort.Slice(rtn.Stats, func(i, j int) bool {
+		a, b := rtn.Stats[i], rtn.Stats[j]
+		if a.Side != b.Side {
+			return a.Side < b.Side
+		}
+		if a.Command != b.Command {
+			return a.Command < b.Command
+		}
+		return a.Route < b.Route
+	})
+	// oldest span first
+	if len(m.traces) == maxRpcTraceSpans {
+		rtn.Traces = append(rtn.Traces, m.traces[m.traceNext:]...)
+		rtn.Traces = append(rtn.Traces, m.traces[:m.traceNext]...)
+	} else {
+		rtn.Traces = append(rtn.Traces, m.traces...)
+	}
+	if data.Reset {
+		m.since = time.Now()
+		m.traces = nil
+		m.traceNext = 0
+		for key, stats := range m.stats {
+			if stats.inFlight == 0 {
+				delete(m.stats, key)
+				continue
+			}
+			m.stats[key] = &rpcStats{inFlight: stats.inFlight, latencyCounts: make([]int64, len(RpcLatencyBucketsMs)+1)}
+		}
+	}
+	return rtn
+}
+
+func promLabels(stats wshrpc.RpcCommandStats) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return fmt.Sprintf(`side="%s",command="%s",route="%s"`, escape.Replace(stats.Side), escape.Replace(stats.Command), escape.Replace(stats.Route))
+}
+
+// writes the metrics in the prometheus text format
+func WriteRpcStatsPrometheus(w io.Writer, data *wshrpc.RpcStatsData) error {
+	var buf strings.Builder
+	buf.WriteString("# HELP starterm_rpc_requests_total rpc requests by side (client, server, router), command and route.\n")
+	buf.WriteString("# TYPE starterm_rpc_requests_total counter\n")
+	for _, stats := range data.Stats {
+		fmt.Fprintf(&buf, "starterm_rpc_requests_total{%s} %d\n", promLabels(stats), stats.Count)
+	}
+	buf.WriteString("# HELP starterm_rpc_errors_total rpc requests that failed (including timeouts).\n")
+	buf.WriteString("# TYPE starterm_rpc_errors_total counter\n")
+	for _, stats := range data.Stats {
+		fmt.Fprintf(&buf, "starterm_rpc_errors_total{%s} %d\n", promLabels(stats), stats.Errors)
+	}
+	buf.WriteString("# HELP starterm_rpc_inflight rpc requests waiting for their (last) response.\n")
+	buf.WriteString("# TYPE starterm_rpc_inflight gauge\n")
+	for _, stats := range data.Stats {
+		fmt.Fprintf(&buf, "starterm_rpc_inflight{%s} %d\n", promLabels(stats), stats.InFlight)
+	}
+	buf.WriteString("# HELP starterm_rpc_latency_seconds time until the (last) response of rpc requests.\n")
+	buf.WriteString("# TYPE starterm_rpc_latency_seconds histogram\n")
+	for _, stats := range data.Stats {
+		labels := promLabels(stats)
+		var cumulative int64
+		for idx, bucketMs := range data.LatencyBucketsMs {
+			if idx < len(stats.LatencyCounts) {
+				cumulative += stats.LatencyCounts[idx]
+			}
+			fmt.Fprintf(&buf, "starterm_rpc_latency_seconds_bucket{%s,le=\"%g\"} %d\n", labels, bucketMs/1000, cumulative)
+		}
+		if len(stats.LatencyCounts) > len(data.LatencyBucketsMs) {
+			cumulative += stats.LatencyCounts[len(data.LatencyBucketsMs)]
+		}
+		fmt.Fprintf(&buf, "starterm_rpc_latency_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, cumulative)
+		fmt.Fprintf(&buf, "starterm_rpc_latency_seconds_sum{%s} %g\n", labels, stats.LatencySumMs/1000)
+		fmt.Fprintf(&buf, "starterm_rpc_latency_seconds_count{%s} %d\n", labels, cumulative)
+	}
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
@@ -0,0 +1,87 @@
+// Copyright 2025, Command Line Inc.
+// SPDX-License-Identifier: Apache-2.0
+
+package wshutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/commandlinedev/starterm/pkg/wshrpc"
+)
+
+func findRpcStats(data *wshrpc.RpcStatsData, side string, command string) *wshrpc.RpcCommandStats {
+	for idx := range data.Stats {
+		if data.Stats[idx].Side == side && data.Stats[idx].Command == command {
+			return &data.Stats[idx]
+		}
+	}
+	return nil
+}
+
+func TestRpcMetrics(t *testing.T) {
+	GetRpcStats("test", wshrpc.CommandRpcStatsData{Reset: true})
+	call := startRpcCall(wshrpc.RpcSide_Server, "metricstest", "proc:1234", "trace-1")
+	pending := startRpcCall(wshrpc.RpcSide_Server, "metricstest", "proc:5678", "")
+	call.finish("some error")
+	call.finish("") // only the first finish counts
+	countRpc(wshrpc.RpcSide_Client, "metricsevent", "", false)
+	data := GetRpcStats("test", wshrpc.CommandRpcStatsData{Reset: true})
+	stats := findRpcStats(data, wshrpc.RpcSide_Server, "metricstest")
+	if stats == nil {
+		t.Fatalf("no stats for metricstest")
+	}
+	if stats.Route != "proc" || stats.Count != 1 || stats.Errors != 1 || stats.InFlight != 1 {
+		t.Errorf("unexpected stats %+v", *stats)
+	}
+	if stats.LatencyCounts[0] != 1 {
+		t.Errorf("expected the request in the first latency bucket, got %v", stats.LatencyCounts)
+	}
+	if event := findRpcStats(data, wshrpc.RpcSide_Client, "metricsevent"); event == nil || event.Route != DefaultRoute || event.Count != 1 {
+		t.Errorf("unexpected stats for metricsevent %+v", event)
+	}
+	if len(data.Traces) != 1 || data.Traces[0].TraceId != "trace-1" || data.Traces[0].Error != "some error" {
+		t.Errorf("unexpected traces %+v", data.Traces)
+	}
+	var buf strings.Builder
+	if err := WriteRpcStatsPrometheus(&buf, data); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	output := buf.String()
+	for _, expected := range []string{
+		`starterm_rpc_errors_total{side="server",command="metricstest",route="proc"} 1`,
+		`starterm_rpc_inflight{side="server",command="metricstest",route="proc"} 1`,
+		`starterm_rpc_latency_seconds_bucket{side="server",command="metricstest",route="proc",le="+Inf"} 1`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("prometheus output is missing %q", expected)
+		}
+	}
+	// in-flight requests survive a reset
+	pending.finish("")
+	data = GetRpcStats("test", wshrpc.CommandRpcStatsData{})
+	if stats := findRpcStats(data, wshrpc.RpcSide_Server, "metricstest"); stats == nil || stats.Count != 1 || stats.InFlight != 0 {
+		t.Errorf("unexpected stats after reset %+v", stats)
+	}
+}
+
+func TestRouteInfoSweep(t *testing.T) {
+	router := NewWshRouter()
+	GetRpcStats("test", wshrpc.CommandRpcStatsData{Reset: true})
+	router.registerRouteInfo(RpcMessage{Command: "sweeptest", ReqId: "req-short", Source: "proc:1", Timeout: 100}, "proc:2")
+	router.registerRouteInfo(RpcMessage{Command: "sweeptest", ReqId: "req-long", Source: "proc:1", Timeout: 60000}, "proc:2")
+	router.sweepRouteInfos(time.Now().Add(routeInfoTimeoutGrace + time.Second))
+	if router.getRouteInfo("req-short") != nil {
+		t.Errorf("timed out route info was not removed")
+	}
+	if router.getRouteInfo("req-long") == nil {
+		t.Errorf("pending route info was removed")
+	}
+	data := GetRpcStats("test", wshrpc.CommandRpcStatsData{})
+	if stats := findRpcStats(data, wshrpc.RpcSide_Router, "sweeptest"); stats == nil || stats.Errors != 1 || stats.InFlight != 1 {
+		t.Errorf("unexpected stats after sweep %+v", stats)
+	}
+	// in-flight counts survive a reset, time out the pending one too so the test can run again
+	router.sweepRouteInfos(time.Now().Add(time.Hour))
+}
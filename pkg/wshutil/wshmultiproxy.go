@@ -4,6 +4,7 @@
 package wshutil
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -24,17 +25,26 @@ type multiProxyRouteInfo struct {
 type WshRpcMultiProxy struct {
 	Lock            *sync.Mutex
 	RouteInfo       map[string]*multiProxyRouteInfo // authtoken to info
-	ToRemoteCh      chan []byte
-	FromRemoteRawCh chan []byte // raw message from the remote
+	ToRemoteCh      chan []byte                     // unbuffered, fed by output (the routes share it)
+	FromRemoteRawCh chan []byte                     // raw message from the remote
+	output          *outputScheduler
 }
 
 func MakeRpcMultiProxy() *WshRpcMultiProxy {
-	return &WshRpcMultiProxy{
+	p := &WshRpcMultiProxy{
 		Lock:            &sync.Mutex{},
 		RouteInfo:       make(map[string]*multiProxyRouteInfo),
-		ToRemoteCh:      make(chan []byte, DefaultInputChSize),
+		ToRemoteCh:      make(chan []byte),
 		FromRemoteRawCh: make(chan []byte, DefaultOutputChSize),
+		output:          makeOutputScheduler(),
 	}
+	go p.output.pump(p.ToRemoteCh)
+	return p
+}
+
+func (p *WshRpcMultiProxy) sendRpcMessage(msg []byte) {
+	resId, bulk := getRpcMsgStream(msg)
+	p.output.queue(context.Background(), resId, bulk, msg)
 }
 
 func (p *WshRpcMultiProxy) DisposeRoutes() {
@@ -74,20 +84,43 @@ func (p *WshRpcMultiProxy) sendResponseError(msg RpcMessage, sendErr error) {
 		Error: sendErr.Error(),
 	}
 	respBytes, _ := json.Marshal(resp)
-	p.ToRemoteCh <- respBytes
+	p.sendRpcMessage(respBytes)
 }
 
-func (p *WshRpcMultiProxy) sendAuthResponse(msg RpcMessage, routeId string, authToken string) {
+func (p *WshRpcMultiProxy) sendAuthResponse(msg RpcMessage, routeId string, authToken string, rpcContext *wshrpc.RpcContext) {
 	if msg.ReqId == "" {
 		// no response needed
 		return
 	}
 	resp := RpcMessage{
 		ResId: msg.ReqId,
-		Data:  wshrpc.CommandAuthenticateRtnData{RouteId: routeId, AuthToken: authToken},
+		Data:  wshrpc.CommandAuthenticateRtnData{RouteId: routeId, AuthToken: authToken, RpcContext: rpcContext},
 	}
 	respBytes, _ := json.Marshal(resp)
-	p.ToRemoteCh <- respBytes
+	p.sendRpcMessage(respBytes)
+}
+
+// an authenticated route sends a new token for itself (connservers do after a key rotation), the route and its auth token stay the same
+func (p *WshRpcMultiProxy) handleReauth(msg RpcMessage) {
+	routeInfo := p.getRouteInfo(msg.AuthToken)
+	if routeInfo == nil {
+		p.sendResponseError(msg, fmt.Errorf("invalid auth token"))
+		return
+	}
+	rpcContext, routeId, err := handleAuthenticationCommand(msg)
+	if err != nil {
+		p.sendResponseError(msg, err)
+		return
+	}
+	if routeId != routeInfo.RouteId {
+		p.sendResponseError(msg, fmt.Errorf("token is for route %q, not %q", routeId, routeInfo.RouteId))
+		return
+	}
+	p.Lock.Lock()
+	routeInfo.RpcContext = rpcContext
+	p.Lock.Unlock()
+	routeInfo.Proxy.SetRpcContext(rpcContext)
+	p.sendAuthResponse(msg, routeId, routeInfo.AuthToken, rpcContext)
 }
 
 func (p *WshRpcMultiProxy) handleUnauthMessage(msgBytes []byte) {
@@ -97,6 +130,10 @@ func (p *WshRpcMultiProxy) handleUnauthMessage(msgBytes []byte) {
 		// nothing to do here, malformed message
 		return
 	}
+	if msg.Command == wshrpc.Command_Authenticate && msg.AuthToken != "" {
+		p.handleReauth(msg)
+		return
+	}
 	if msg.Command == wshrpc.Command_Authenticate {
 		rpcContext, routeId, err := handleAuthenticationCommand(msg)
 		if err != nil {
@@ -111,13 +148,13 @@ func (p *WshRpcMultiProxy) handleUnauthMessage(msgBytes []byte) {
 		routeInfo.Proxy = MakeRpcProxy()
 		routeInfo.Proxy.SetRpcContext(rpcContext)
 		p.setRouteInfo(routeInfo.AuthToken, routeInfo)
-		p.sendAuthResponse(msg, routeId, routeInfo.AuthToken)
+		p.sendAuthResponse(msg, routeId, routeInfo.AuthToken, rpcContext)
 		go func() {
 			defer func() {
 				panichandler.PanicHandler("WshRpcMultiProxy:handleUnauthMessage", recover())
 			}()
 			for msgBytes := range routeInfo.Proxy.ToRemoteCh {
-				p.ToRemoteCh <- msgBytes
+				p.sendRpcMessage(msgBytes)
 			}
 		}()
 		DefaultRouter.RegisterRoute(routeId, routeInfo.Proxy, true)
@@ -140,7 +177,7 @@ func (p *WshRpcMultiProxy) handleUnauthMessage(msgBytes []byte) {
 	if msg.Command == wshrpc.Command_Dispose {
 		DefaultRouter.UnregisterRoute(routeInfo.RouteId)
 		p.removeRouteInfo(msg.AuthToken)
-		close(routeInfo.Proxy.ToRemoteCh)
+		routeInfo.Proxy.Close()
 		close(routeInfo.Proxy.FromRemoteCh)
 		return
 	}
@@ -4,12 +4,12 @@
 package wshutil
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sync"
 
 	"github.com/google/uuid"
-	"github.com/commandlinedev/starterm/pkg/panichandler"
 	"github.com/commandlinedev/starterm/pkg/util/shellutil"
 	"github.com/commandlinedev/starterm/pkg/util/utilfn"
 	"github.com/commandlinedev/starterm/pkg/wshrpc"
@@ -18,17 +18,30 @@ import (
 type WshRpcProxy struct {
 	Lock         *sync.Mutex
 	RpcContext   *wshrpc.RpcContext
-	ToRemoteCh   chan []byte
+	CapsContext  *wshrpc.RpcContext // set on the client side (conn server), where RpcContext is not set
+	ToRemoteCh   chan []byte        // unbuffered, fed by output (closed by Close)
 	FromRemoteCh chan []byte
 	AuthToken    string
+	output       *outputScheduler
 }
 
 func MakeRpcProxy() *WshRpcProxy {
-	return &WshRpcProxy{
+	p := &WshRpcProxy{
 		Lock:         &sync.Mutex{},
-		ToRemoteCh:   make(chan []byte, DefaultInputChSize),
+		ToRemoteCh:   make(chan []byte),
 		FromRemoteCh: make(chan []byte, DefaultOutputChSize),
+		output:       makeOutputScheduler(),
 	}
+	go func() {
+		defer close(p.ToRemoteCh)
+		p.output.pump(p.ToRemoteCh)
+	}()
+	return p
+}
+
+// drops the queued messages and closes ToRemoteCh, later messages are dropped
+func (p *WshRpcProxy) Close() {
+	p.output.close(true)
 }
 
 func (p *WshRpcProxy) SetRpcContext(rpcCtx *wshrpc.RpcContext) {
@@ -43,6 +56,22 @@ func (p *WshRpcProxy) GetRpcContext() *wshrpc.RpcContext {
 	return p.RpcContext
 }
 
+func (p *WshRpcProxy) SetCapsContext(rpcCtx *wshrpc.RpcContext) {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	p.CapsContext = rpcCtx
+}
+
+// returns the context whose capabilities restrict the commands sent through this proxy (see ApplyRouteCaps)
+func (p *WshRpcProxy) GetCapsContext() *wshrpc.RpcContext {
+	p.Lock.Lock()
+	defer p.Lock.Unlock()
+	if p.CapsContext != nil {
+		return p.CapsContext
+	}
+	return p.RpcContext
+}
+
 func (p *WshRpcProxy) SetAuthToken(authToken string) {
 	p.Lock.Lock()
 	defer p.Lock.Unlock()
@@ -69,7 +98,7 @@ func (p *WshRpcProxy) sendResponseError(msg RpcMessage, sendErr error) {
 	p.SendRpcMessage(respBytes)
 }
 
-func (p *WshRpcProxy) sendAuthenticateResponse(msg RpcMessage, routeId string) {
+func (p *WshRpcProxy) sendAuthenticateResponse(msg RpcMessage, routeId string, rpcContext *wshrpc.RpcContext) {
 	if msg.ReqId == "" {
 		// no response needed
 		return
@@ -77,7 +106,7 @@ func (p *WshRpcProxy) sendAuthenticateResponse(msg RpcMessage, routeId string) {
 	resp := RpcMessage{
 		ResId: msg.ReqId,
 		Route: msg.Source,
-		Data:  wshrpc.CommandAuthenticateRtnData{RouteId: routeId},
+		Data:  wshrpc.CommandAuthenticateRtnData{RouteId: routeId, RpcContext: rpcContext},
 	}
 	respBytes, _ := json.Marshal(resp)
 	p.SendRpcMessage(respBytes)
@@ -114,6 +143,9 @@ func validateRpcContextFromAuth(newCtx *wshrpc.RpcContext) (string, error) {
 			return "", fmt.Errorf("invalid blockId in jwt token")
 		}
 	}
+	if err := validateRouteCaps(newCtx.Caps); err != nil {
+		return "", err
+	}
 	routeId, err := MakeRouteIdFromCtx(newCtx)
 	if err != nil {
 		return "", fmt.Errorf("error making routeId from context: %w", err)
@@ -188,6 +220,11 @@ func (p *WshRpcProxy) HandleClientProxyAuth(router *WshRouter) (string, error) {
 				return "", respErr
 			}
 			p.SetAuthToken(authRtn.AuthToken)
+			// enforce the capabilities of the context upstream verified (not the unverified token the client sent),
+			// an upstream that does not return it still enforces them itself
+			if authRtn.RpcContext != nil {
+				p.SetCapsContext(authRtn.RpcContext)
+			}
 			announceMsg := RpcMessage{
 				Command:   wshrpc.Command_RouteAnnounce,
 				Source:    authRtn.RouteId,
@@ -195,7 +232,7 @@ func (p *WshRpcProxy) HandleClientProxyAuth(router *WshRouter) (string, error) {
 			}
 			announceBytes, _ := json.Marshal(announceMsg)
 			router.InjectMessage(announceBytes, authRtn.RouteId)
-			p.sendAuthenticateResponse(origMsg, authRtn.RouteId)
+			p.sendAuthenticateResponse(origMsg, authRtn.RouteId, authRtn.RpcContext)
 			return authRtn.RouteId, nil
 		}
 		if origMsg.Command == wshrpc.Command_AuthenticateToken {
@@ -230,7 +267,7 @@ func (p *WshRpcProxy) HandleAuthentication() (*wshrpc.RpcContext, error) {
 				p.sendResponseError(msg, err)
 				continue
 			}
-			p.sendAuthenticateResponse(msg, routeId)
+			p.sendAuthenticateResponse(msg, routeId, newCtx)
 			return newCtx, nil
 		}
 		if msg.Command == wshrpc.Command_AuthenticateToken {
@@ -250,10 +287,8 @@ func (p *WshRpcProxy) HandleAuthentication() (*wshrpc.RpcContext, error) {
 
 // TODO: Figure out who is sending to closed routes and why we're not catching it
 func (p *WshRpcProxy) SendRpcMessage(msg []byte) {
-	defer func() {
-		panichandler.PanicHandler("WshRpcProxy.SendRpcMessage", recover())
-	}()
-	p.ToRemoteCh <- msg
+	resId, bulk := getRpcMsgStream(msg)
+	p.output.queue(context.Background(), resId, bulk, msg)
 }
 
 func (p *WshRpcProxy) RecvRpcMessage() ([]byte, bool) {
@@ -40,8 +40,16 @@ type routeInfo struct {
 	RpcId         string
 	SourceRouteId string
 	DestRouteId   string
+	Call          *rpcCall
+	Deadline      time.Time // after this the requestor gave up, the call is counted as a timeout and the route info is dropped
 }
 
+// how often route infos are checked for requests that timed out
+const routeInfoSweepInterval = 10 * time.Second
+
+// responses can arrive a bit after the requestor's timeout
+const routeInfoTimeoutGrace = 5 * time.Second
+
 type msgAndRoute struct {
 	msgBytes    []byte
 	fromRouteId string
@@ -89,6 +97,7 @@ func NewWshRouter() *WshRouter {
 		InputCh:          make(chan msgAndRoute, DefaultInputChSize),
 	}
 	go rtn.runServer()
+	go rtn.runRouteInfoSweep()
 	return rtn
 }
 
@@ -122,6 +131,7 @@ func (router *WshRouter) SendEvent(routeId string, event wps.StarEvent) {
 
 func (router *WshRouter) handleNoRoute(msg RpcMessage) {
 	nrErr := noRouteErr(msg.Route)
+	countRpc(wshrpc.RpcSide_Router, msg.Command, msg.Route, true)
 	if msg.ReqId == "" {
 		if msg.Command == wshrpc.Command_Message {
 			// to prevent infinite loops
@@ -142,13 +152,67 @@ func (router *WshRouter) handleNoRoute(msg RpcMessage) {
 	router.sendRoutedMessage(respBytes, msg.Source)
 }
 
-func (router *WshRouter) registerRouteInfo(rpcId string, sourceRouteId string, destRouteId string) {
-	if rpcId == "" {
+// audits a command that was denied by the route's capabilities and sends the error back to the caller
+func denyCommand(rpc AbstractRpcClient, msg RpcMessage, routeId string, denyErr error) {
+	log.Printf("[router] denied command %q from route %q to %q: %v\n", msg.Command, routeId, msg.Route, denyErr)
+	if msg.ReqId == "" {
 		return
 	}
+	response := RpcMessage{
+		ResId: msg.ReqId,
+		Error: denyErr.Error(),
+	}
+	respBytes, _ := json.Marshal(response)
+	rpc.SendRpcMessage(respBytes)
+}
+
+func (router *WshRouter) registerRouteInfo(msg RpcMessage, destRouteId string) {
+	if msg.ReqId == "" {
+		countRpc(wshrpc.RpcSide_Router, msg.Command, destRouteId, false)
+		return
+	}
+	timeoutMs := msg.Timeout
+	if timeoutMs <= 0 {
+		timeoutMs = DefaultTimeoutMs
+	}
+	call := startRpcCall(wshrpc.RpcSide_Router, msg.Command, destRouteId, msg.TraceId)
 	router.Lock.Lock()
 	defer router.Lock.Unlock()
-	router.RpcMap[rpcId] = &routeInfo{RpcId: rpcId, SourceRouteId: sourceRouteId, DestRouteId: destRouteId}
+	router.RpcMap[msg.ReqId] = &routeInfo{
+		RpcId:         msg.ReqId,
+		SourceRouteId: msg.Source,
+		DestRouteId:   destRouteId,
+		Call:          call,
+		Deadline:      call.start.Add(time.Duration(timeoutMs)*time.Millisecond + routeInfoTimeoutGrace),
+	}
+}
+
+// removes the route infos of requests that timed out (the requestor has already given up on them),
+// responses that still arrive are dropped like responses to unknown requests
+func (router *WshRouter) sweepRouteInfos(now time.Time) {
+	var expired []*routeInfo
+	router.Lock.Lock()
+	for rpcId, info := range router.RpcMap {
+		if now.After(info.Deadline) {
+			expired = append(expired, info)
+			delete(router.RpcMap, rpcId)
+		}
+	}
+	router.Lock.Unlock()
+	for _, info := range expired {
+		info.Call.finish("EC-TIME: timeout waiting for response")
+	}
+}
+
+func (router *WshRouter) runRouteInfoSweep() {
+	defer func() {
+		panichandler.PanicHandler("WshRouter:runRouteInfoSweep", recover())
+	}()
+	ticker := time.NewTicker(routeInfoSweepInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		router.sweepRouteInfos(now)
+	}
 }
 
 func (router *WshRouter) unregisterRouteInfo(rpcId string) {
@@ -241,7 +305,7 @@ func (router *WshRouter) runServer() {
 				router.handleNoRoute(msg)
 				continue
 			}
-			router.registerRouteInfo(msg.ReqId, msg.Source, routeId)
+			router.registerRouteInfo(msg, routeId)
 			continue
 		}
 		// look at reqid or resid to route correctly
@@ -266,6 +330,7 @@ func (router *WshRouter) runServer() {
 			}
 			router.sendRoutedMessage(msgBytes, routeInfo.SourceRouteId)
 			if !msg.Cont {
+				routeInfo.Call.finish(msg.Error)
 				router.unregisterRouteInfo(msg.ResId)
 			}
 			continue
@@ -335,6 +400,13 @@ func (router *WshRouter) RegisterRoute(routeId string, rpc AbstractRpcClient, sh
 				if rpcMsg.Route == "" {
 					rpcMsg.Route = DefaultRoute
 				}
+				rpcMsg.TraceId = getTraceId(rpcMsg.TraceId)
+				if capsRpc, ok := rpc.(capsRpcClient); ok {
+					if err := ApplyRouteCaps(capsRpc.GetCapsContext(), routeId, &rpcMsg); err != nil {
+						denyCommand(rpc, rpcMsg, routeId, err)
+						continue
+					}
+				}
 				msgBytes, err = json.Marshal(rpcMsg)
 				if err != nil {
 					continue
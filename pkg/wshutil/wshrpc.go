@@ -57,6 +57,7 @@ type WshRpc struct {
 	Debug              bool
 	DebugName          string
 	ServerDone         bool
+	output             *outputScheduler // feeds OutputCh
 }
 
 type wshRpcContextKey struct{}
@@ -106,6 +107,11 @@ func (w *WshRpc) SendRpcMessage(msg []byte) {
 	w.InputCh <- msg
 }
 
+// messages written after the server is done are dropped
+func (w *WshRpc) sendOutput(msg []byte) {
+	w.output.queue(context.Background(), "", false, msg)
+}
+
 func (w *WshRpc) RecvRpcMessage() ([]byte, bool) {
 	msg, more := <-w.OutputCh
 	return msg, more
@@ -121,6 +127,9 @@ type RpcMessage struct {
 	Source    string `json:"source,omitempty"`    // source route id
 	Cont      bool   `json:"cont,omitempty"`      // flag if additional requests/responses are forthcoming
 	Cancel    bool   `json:"cancel,omitempty"`    // used to cancel a streaming request or response (sent from the side that is not streaming)
+	Window    int64  `json:"window,omitempty"`    // flow control window for a streaming response (set on the command, echoed on the first response)
+	Credits   int64  `json:"credits,omitempty"`   // grants more streaming responses (sent by the requestor, see wshflow.go)
+	TraceId   string `json:"traceid,omitempty"`   // set on commands when rpc tracing is on (see wshmetrics.go)
 	Error     string `json:"error,omitempty"`
 	DataType  string `json:"datatype,omitempty"`
 	Data      any    `json:"data,omitempty"`
@@ -146,6 +155,15 @@ func (r *RpcMessage) Validate() error {
 		}
 		return nil
 	}
+	if r.Credits != 0 {
+		if r.Command != "" || r.ResId != "" || r.ReqId == "" {
+			return fmt.Errorf("credit packets must only have reqid set")
+		}
+		if r.Credits < 0 {
+			return fmt.Errorf("credit packets may not have negative credits")
+		}
+		return nil
+	}
 	if r.Command != "" {
 		if r.ResId != "" {
 			return fmt.Errorf("command packets may not have resid set")
@@ -187,6 +205,7 @@ type rpcData struct {
 	Route   string
 	ResCh   chan *RpcMessage
 	Handler *RpcRequestHandler
+	Call    *rpcCall
 }
 
 func validateServerImpl(serverImpl ServerImpl) {
@@ -219,8 +238,13 @@ func MakeWshRpc(inputCh chan []byte, outputCh chan []byte, rpcCtx wshrpc.RpcCont
 		EventListener:      MakeEventListener(),
 		ServerImpl:         serverImpl,
 		ResponseHandlerMap: make(map[string]*RpcResponseHandler),
+		output:             makeOutputScheduler(),
 	}
 	rtn.RpcContext.Store(&rpcCtx)
+	go func() {
+		defer close(outputCh)
+		rtn.output.pump(outputCh)
+	}()
 	go rtn.runServer()
 	return rtn
 }
@@ -263,6 +287,10 @@ func (w *WshRpc) cancelRequest(reqId string) {
 	handler := w.ResponseHandlerMap[reqId]
 	if handler != nil {
 		handler.canceled.Store(true)
+		if handler.flow != nil {
+			// wake up a stream waiting for credits
+			handler.flow.grant(0)
+		}
 	}
 
 }
@@ -302,6 +330,8 @@ func (w *WshRpc) handleRequest(req *RpcMessage) {
 		canceled:        &atomic.Bool{},
 		contextCancelFn: &atomic.Pointer[context.CancelFunc]{},
 		rpcCtx:          w.GetRpcContext(),
+		flow:            makeStreamFlow(req.Window),
+		call:            startRpcCall(wshrpc.RpcSide_Server, req.Command, req.Source, req.TraceId),
 	}
 	respHandler.contextCancelFn.Store(&cancelFn)
 	respHandler.ctx = withRespHandler(ctx, respHandler)
@@ -319,10 +349,12 @@ func (w *WshRpc) handleRequest(req *RpcMessage) {
 				}()
 				<-ctx.Done()
 				respHandler.Finalize()
+				respHandler.call.finish("")
 			}()
 		} else {
 			cancelFn()
 			respHandler.Finalize()
+			respHandler.call.finish("")
 		}
 	}()
 	handlerFn := serverImplAdapter(w.ServerImpl)
@@ -332,7 +364,7 @@ func (w *WshRpc) handleRequest(req *RpcMessage) {
 func (w *WshRpc) runServer() {
 	defer func() {
 		panichandler.PanicHandler("wshrpc.runServer", recover())
-		close(w.OutputCh)
+		w.output.close(false)
 		w.setServerDone()
 	}()
 outer:
@@ -369,6 +401,10 @@ outer:
 			}
 			continue
 		}
+		if msg.Credits > 0 && msg.Command == "" {
+			w.grantStreamCredits(msg.ReqId, msg.Credits)
+			continue
+		}
 		if msg.IsRpcRequest() {
 			go func() {
 				defer func() {
@@ -377,6 +413,10 @@ outer:
 				w.handleRequest(&msg)
 			}()
 		} else {
+			if !msg.Cont && msg.Error != "" {
+				// before the response is delivered, the requestor may unregister the rpc as soon as it sees it
+				w.finishRpcCall(msg.ResId, msg.Error)
+			}
 			w.sendRespWithBlockMessage(msg)
 			if !msg.Cont {
 				w.unregisterRpc(msg.ResId, nil)
@@ -405,7 +445,7 @@ func (w *WshRpc) SetServerImpl(serverImpl ServerImpl) {
 	w.ServerImpl = serverImpl
 }
 
-func (w *WshRpc) registerRpc(handler *RpcRequestHandler, command string, route string, reqId string) chan *RpcMessage {
+func (w *WshRpc) registerRpc(handler *RpcRequestHandler, command string, route string, reqId string, call *rpcCall) chan *RpcMessage {
 	w.Lock.Lock()
 	defer w.Lock.Unlock()
 	rpcCh := make(chan *RpcMessage, RespChSize)
@@ -414,6 +454,7 @@ func (w *WshRpc) registerRpc(handler *RpcRequestHandler, command string, route s
 		Command: command,
 		Route:   route,
 		ResCh:   rpcCh,
+		Call:    call,
 	}
 	go func() {
 		defer func() {
@@ -433,6 +474,7 @@ func (w *WshRpc) unregisterRpc(reqId string, err error) {
 		return
 	}
 	if err != nil {
+		rd.Call.finish(err.Error())
 		errResp := &RpcMessage{
 			ResId: reqId,
 			Error: err.Error(),
@@ -445,11 +487,21 @@ func (w *WshRpc) unregisterRpc(reqId string, err error) {
 		default:
 		}
 	}
+	rd.Call.finish("")
 	delete(w.RpcMap, reqId)
 	close(rd.ResCh)
 	rd.Handler.callContextCancelFn()
 }
 
+func (w *WshRpc) finishRpcCall(reqId string, errStr string) {
+	w.Lock.Lock()
+	rd := w.RpcMap[reqId]
+	w.Lock.Unlock()
+	if rd != nil {
+		rd.Call.finish(errStr)
+	}
+}
+
 // no response
 func (w *WshRpc) SendCommand(command string, data any, opts *wshrpc.RpcOpts) error {
 	var optsCopy wshrpc.RpcOpts
@@ -488,6 +540,7 @@ type RpcRequestHandler struct {
 	reqId       string
 	respCh      chan *RpcMessage
 	cachedResp  *RpcMessage
+	credits     *streamCredits // set for responsestream commands
 }
 
 func (handler *RpcRequestHandler) Context() context.Context {
@@ -504,7 +557,7 @@ func (handler *RpcRequestHandler) SendCancel() {
 		AuthToken: handler.w.GetAuthToken(),
 	}
 	barr, _ := json.Marshal(msg) // will never fail
-	handler.w.OutputCh <- barr
+	handler.w.sendOutput(barr)
 	handler.finalize()
 }
 
@@ -535,6 +588,11 @@ func (handler *RpcRequestHandler) NextResponse() (any, error) {
 	if resp == nil {
 		return nil, errors.New("response channel closed")
 	}
+	if handler.credits != nil {
+		if credits := handler.credits.consume(resp); credits > 0 {
+			handler.sendCredits(credits)
+		}
+	}
 	if resp.Error != "" {
 		return nil, errors.New(resp.Error)
 	}
@@ -566,6 +624,8 @@ type RpcResponseHandler struct {
 	rpcCtx          wshrpc.RpcContext
 	canceled        *atomic.Bool // canceled by requestor
 	done            *atomic.Bool
+	flow            *streamFlow // set if the requestor asked for flow control
+	call            *rpcCall
 }
 
 func (handler *RpcResponseHandler) Context() context.Context {
@@ -601,7 +661,7 @@ func (handler *RpcResponseHandler) SendMessage(msg string) {
 		AuthToken: handler.w.GetAuthToken(),
 	}
 	msgBytes, _ := json.Marshal(rpcMsg) // will never fail
-	handler.w.OutputCh <- msgBytes
+	handler.w.sendOutput(msgBytes)
 }
 
 func (handler *RpcResponseHandler) SendResponse(data any, done bool) error {
@@ -623,12 +683,23 @@ func (handler *RpcResponseHandler) SendResponse(data any, done bool) error {
 		Cont:      !done,
 		AuthToken: handler.w.GetAuthToken(),
 	}
+	if handler.flow != nil && !handler.flow.acked {
+		msg.Window = handler.flow.window
+		handler.flow.acked = true
+	}
 	barr, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
-	handler.w.OutputCh <- barr
-	return nil
+	if done {
+		return handler.w.output.queue(context.Background(), handler.reqId, false, barr)
+	}
+	if handler.flow != nil {
+		if err := handler.flow.takeCredit(handler.ctx, handler.canceled); err != nil {
+			return fmt.Errorf("stream stalled, no credits from requestor: %w", err)
+		}
+	}
+	return handler.w.output.queue(handler.ctx, handler.reqId, true, barr)
 }
 
 func (handler *RpcResponseHandler) SendResponseError(err error) {
@@ -639,13 +710,14 @@ func (handler *RpcResponseHandler) SendResponseError(err error) {
 		return
 	}
 	defer handler.close()
+	handler.call.finish(err.Error())
 	msg := &RpcMessage{
 		ResId:     handler.reqId,
 		Error:     err.Error(),
 		AuthToken: handler.w.GetAuthToken(),
 	}
 	barr, _ := json.Marshal(msg) // will never fail
-	handler.w.OutputCh <- barr
+	handler.w.output.queue(context.Background(), handler.reqId, false, barr)
 }
 
 func (handler *RpcResponseHandler) IsCanceled() bool {
@@ -709,13 +781,24 @@ func (w *WshRpc) SendComplexRequest(command string, data any, opts *wshrpc.RpcOp
 		Timeout:   timeoutMs,
 		Route:     opts.Route,
 		AuthToken: w.GetAuthToken(),
+		TraceId:   getTraceId(""),
+	}
+	if methodDecl := WshCommandDeclMap[command]; handler.reqId != "" && methodDecl != nil && methodDecl.CommandType == wshrpc.RpcType_ResponseStream {
+		req.Window = DefaultStreamWindow
+		handler.credits = &streamCredits{window: DefaultStreamWindow}
 	}
 	barr, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
-	handler.respCh = w.registerRpc(handler, command, opts.Route, handler.reqId)
-	w.OutputCh <- barr
+	var call *rpcCall
+	if handler.reqId != "" {
+		call = startRpcCall(wshrpc.RpcSide_Client, command, opts.Route, req.TraceId)
+	} else {
+		countRpc(wshrpc.RpcSide_Client, command, opts.Route, false)
+	}
+	handler.respCh = w.registerRpc(handler, command, opts.Route, handler.reqId, call)
+	w.sendOutput(barr)
 	return handler, nil
 }
 
@@ -7,21 +7,26 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/commandlinedev/starterm/pkg/util/packetparser"
 	"github.com/commandlinedev/starterm/pkg/util/utilfn"
 )
 
 // special I/O wrappers for wshrpc
 // * terminal (wrap with OSC codes)
-// * stream (json lines)
+// * stream (json lines, or binary frames once negotiated, see packetparser.Framing)
 // * websocket (json packets)
 
-func AdaptStreamToMsgCh(input io.Reader, output chan []byte) error {
+// framing is optional, without it the stream only carries json lines
+func AdaptStreamToMsgCh(input io.Reader, output chan []byte, framing *packetparser.Framing) error {
+	if framing != nil {
+		return packetparser.ParseJsonLines(input, output, framing)
+	}
 	return utilfn.StreamToLines(input, func(line []byte) {
 		output <- line
 	})
 }
 
-func AdaptOutputChToStream(outputCh chan []byte, output io.Writer) error {
+func AdaptOutputChToStream(outputCh chan []byte, output io.Writer, framing *packetparser.Framing) error {
 	drain := false
 	defer func() {
 		if drain {
@@ -29,6 +34,13 @@ func AdaptOutputChToStream(outputCh chan []byte, output io.Writer) error {
 		}
 	}()
 	for msg := range outputCh {
+		if framing != nil {
+			if err := framing.WriteMsg(msg); err != nil {
+				drain = true
+				return fmt.Errorf("error writing to output (AdaptOutputChToStream): %w", err)
+			}
+			continue
+		}
 		if _, err := output.Write(msg); err != nil {
 			drain = true
 			return fmt.Errorf("error writing to output (AdaptOutputChToStream): %w", err)
@@ -228,7 +228,7 @@ func SetupPacketRpcClient(input io.Reader, output io.Writer, serverImpl ServerIm
 	outputCh := make(chan []byte, DefaultOutputChSize)
 	rawCh := make(chan []byte, DefaultOutputChSize)
 	rpcClient := MakeWshRpc(messageCh, outputCh, wshrpc.RpcContext{}, serverImpl, debugStr)
-	go packetparser.Parse(input, messageCh, rawCh)
+	go packetparser.Parse(input, messageCh, rawCh, nil)
 	go func() {
 		defer func() {
 			panichandler.PanicHandler("SetupPacketRpcClient:outputloop", recover())
@@ -244,11 +244,17 @@ func SetupConnRpcClient(conn net.Conn, serverImpl ServerImpl, debugStr string) (
 	inputCh := make(chan []byte, DefaultInputChSize)
 	outputCh := make(chan []byte, DefaultOutputChSize)
 	writeErrCh := make(chan error, 1)
+	// we connect, so we offer binary framing (the server answers if it supports it)
+	framing := packetparser.MakeFraming(conn, false, true)
+	if err := framing.Start(); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("error writing to domain socket: %w", err)
+	}
 	go func() {
 		defer func() {
 			panichandler.PanicHandler("SetupConnRpcClient:AdaptOutputChToStream", recover())
 		}()
-		writeErr := AdaptOutputChToStream(outputCh, conn)
+		writeErr := AdaptOutputChToStream(outputCh, conn, framing)
 		if writeErr != nil {
 			writeErrCh <- writeErr
 			close(writeErrCh)
@@ -260,7 +266,7 @@ func SetupConnRpcClient(conn net.Conn, serverImpl ServerImpl, debugStr string) (
 		}()
 		// when input is closed, close the connection
 		defer conn.Close()
-		AdaptStreamToMsgCh(conn, inputCh)
+		AdaptStreamToMsgCh(conn, inputCh, framing)
 	}()
 	rtn := MakeWshRpc(inputCh, outputCh, wshrpc.RpcContext{}, serverImpl, debugStr)
 	return rtn, writeErrCh, nil
@@ -302,7 +308,7 @@ func MakeClientJWTToken(rpcCtx wshrpc.RpcContext, sockName string) (string, erro
 	claims["iat"] = time.Now().Unix()
 	claims["iss"] = "starterm"
 	claims["sock"] = sockName
-	claims["exp"] = time.Now().Add(time.Hour * 24 * 365).Unix()
+	claims["exp"] = time.Now().Add(getJwtTokenLifetime(rpcCtx.ClientType)).Unix()
 	if rpcCtx.BlockId != "" {
 		claims["blockid"] = rpcCtx.BlockId
 	}
@@ -315,8 +321,16 @@ func MakeClientJWTToken(rpcCtx wshrpc.RpcContext, sockName string) (string, erro
 	if rpcCtx.ClientType != "" {
 		claims["ctype"] = rpcCtx.ClientType
 	}
+	if len(rpcCtx.Caps) > 0 {
+		claims["caps"] = rpcCtx.Caps
+	}
+	keyId, secret, err := getJwtSigningKey()
+	if err != nil {
+		return "", fmt.Errorf("error getting jwt signing key: %w", err)
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString([]byte(starbase.JwtSecret))
+	token.Header["kid"] = keyId
+	tokenStr, err := token.SignedString(secret)
 	if err != nil {
 		return "", fmt.Errorf("error signing token: %w", err)
 	}
@@ -324,9 +338,13 @@ func MakeClientJWTToken(rpcCtx wshrpc.RpcContext, sockName string) (string, erro
 }
 
 func ValidateAndExtractRpcContextFromToken(tokenStr string) (*wshrpc.RpcContext, error) {
-	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}))
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}), jwt.WithExpirationRequired())
 	token, err := parser.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return []byte(starbase.JwtSecret), nil
+		keyId, ok := token.Header["kid"].(string)
+		if !ok || keyId == "" {
+			return nil, fmt.Errorf("kid header is missing or invalid")
+		}
+		return getJwtVerifyKey(keyId)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error parsing token: %w", err)
@@ -376,6 +394,15 @@ func mapClaimsToRpcContext(claims jwt.MapClaims) *wshrpc.RpcContext {
 			rpcCtx.ClientType = ctype
 		}
 	}
+	if claims["caps"] != nil {
+		if caps, ok := claims["caps"].([]any); ok {
+			for _, capAny := range caps {
+				if capStr, ok := capAny.(string); ok {
+					rpcCtx.Caps = append(rpcCtx.Caps, capStr)
+				}
+			}
+		}
+	}
 	return rpcCtx
 }
 
@@ -421,10 +448,16 @@ type WriteFlusher interface {
 }
 
 // blocking, returns if there is an error, or on EOF of input
+// input should come from packetparser.StreamToLinesChan so binary frames can be read
 func HandleStdIOClient(logName string, input chan utilfn.LineOutput, output io.Writer) {
 	proxy := MakeRpcMultiProxy()
 	rawCh := make(chan []byte, DefaultInputChSize)
-	go packetparser.ParseWithLinesChan(input, proxy.FromRemoteRawCh, rawCh)
+	framing := packetparser.MakeFraming(output, true, true)
+	if err := framing.Start(); err != nil {
+		log.Printf("[%s] error writing to output: %v\n", logName, err)
+		return
+	}
+	go packetparser.ParseWithLinesChan(input, proxy.FromRemoteRawCh, rawCh, framing)
 	doneCh := make(chan struct{})
 	var doneOnce sync.Once
 	closeDoneCh := func() {
@@ -445,7 +478,7 @@ func HandleStdIOClient(logName string, input chan utilfn.LineOutput, output io.W
 		}()
 		defer closeDoneCh()
 		for msg := range proxy.ToRemoteCh {
-			err := packetparser.WritePacket(output, msg)
+			err := framing.WriteMsg(msg)
 			if err != nil {
 				log.Printf("[%s] error writing to output: %v\n", logName, err)
 				break
@@ -470,11 +503,12 @@ func HandleStdIOClient(logName string, input chan utilfn.LineOutput, output io.W
 func handleDomainSocketClient(conn net.Conn) {
 	var routeIdContainer atomic.Pointer[string]
 	proxy := MakeRpcProxy()
+	framing := packetparser.MakeFraming(conn, false, false)
 	go func() {
 		defer func() {
 			panichandler.PanicHandler("handleDomainSocketClient:AdaptOutputChToStream", recover())
 		}()
-		writeErr := AdaptOutputChToStream(proxy.ToRemoteCh, conn)
+		writeErr := AdaptOutputChToStream(proxy.ToRemoteCh, conn, framing)
 		if writeErr != nil {
 			log.Printf("error writing to domain socket: %v\n", writeErr)
 		}
@@ -487,13 +521,13 @@ func handleDomainSocketClient(conn net.Conn) {
 		defer func() {
 			conn.Close()
 			close(proxy.FromRemoteCh)
-			close(proxy.ToRemoteCh)
+			proxy.Close()
 			routeIdPtr := routeIdContainer.Load()
 			if routeIdPtr != nil && *routeIdPtr != "" {
 				DefaultRouter.UnregisterRoute(*routeIdPtr)
 			}
 		}()
-		AdaptStreamToMsgCh(conn, proxy.FromRemoteCh)
+		AdaptStreamToMsgCh(conn, proxy.FromRemoteCh, framing)
 	}()
 	rpcCtx, err := proxy.HandleAuthentication()
 	if err != nil {
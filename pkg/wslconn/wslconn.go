@@ -25,6 +25,7 @@ import (
 	"github.com/commandlinedev/starterm/pkg/telemetry"
 	"github.com/commandlinedev/starterm/pkg/telemetry/telemetrydata"
 	"github.com/commandlinedev/starterm/pkg/userinput"
+	"github.com/commandlinedev/starterm/pkg/util/packetparser"
 	"github.com/commandlinedev/starterm/pkg/util/shellutil"
 	"github.com/commandlinedev/starterm/pkg/util/utilfn"
 	"github.com/commandlinedev/starterm/pkg/wps"
@@ -246,6 +247,15 @@ func (conn *WslConn) GetConfigShellPath() string {
 	return config.ConnShellPath
 }
 
+// mints a jwt for this connection's connserver (signed with the current signing key)
+func (conn *WslConn) MakeConnServerJwtToken() (string, error) {
+	rpcCtx := wshrpc.RpcContext{
+		ClientType: wshrpc.ClientType_ConnServer,
+		Conn:       conn.GetName(),
+	}
+	return wshutil.MakeClientJWTToken(rpcCtx, conn.GetDomainSocketName())
+}
+
 // returns (needsInstall, clientVersion, osArchStr, error)
 // if wsh is not installed, the clientVersion will be "not-installed", and it will also return an osArchStr
 // if clientVersion is set, then no osArchStr will be returned
@@ -259,12 +269,7 @@ func (conn *WslConn) StartConnServer(ctx context.Context, afterUpdate bool) (boo
 	}
 	client := conn.GetClient()
 	wshPath := conn.getWshPath()
-	rpcCtx := wshrpc.RpcContext{
-		ClientType: wshrpc.ClientType_ConnServer,
-		Conn:       conn.GetName(),
-	}
-	sockName := conn.GetDomainSocketName()
-	jwtToken, err := wshutil.MakeClientJWTToken(rpcCtx, sockName)
+	jwtToken, err := conn.MakeConnServerJwtToken()
 	if err != nil {
 		return false, "", "", fmt.Errorf("unable to create jwt token for conn controller: %w", err)
 	}
@@ -290,7 +295,7 @@ func (conn *WslConn) StartConnServer(ctx context.Context, afterUpdate bool) (boo
 	if err != nil {
 		return false, "", "", fmt.Errorf("unable to start conn controller cmd: %w", err)
 	}
-	linesChan := utilfn.StreamToLinesChan(pipeRead)
+	linesChan := packetparser.StreamToLinesChan(pipeRead)
 	versionLine, err := utilfn.ReadLineWithTimeout(linesChan, 5*time.Second)
 	if err != nil {
 		cancelFn()
@@ -359,7 +364,7 @@ func (conn *WslConn) StartConnServer(ctx context.Context, afterUpdate bool) (boo
 	conn.Infof(ctx, "connserver started, waiting for route to be registered\n")
 	regCtx, cancelFn := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelFn()
-	err = wshutil.DefaultRouter.WaitForRegister(regCtx, wshutil.MakeConnectionRouteId(rpcCtx.Conn))
+	err = wshutil.DefaultRouter.WaitForRegister(regCtx, wshutil.MakeConnectionRouteId(conn.GetName()))
 	if err != nil {
 		return false, clientVersion, "", fmt.Errorf("timeout waiting for connserver to register")
 	}
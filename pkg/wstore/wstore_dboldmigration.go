@@ -69,12 +69,11 @@ func GetAllOldHistory() ([]*OldHistoryType, error) {
 
 func ReplaceOldHistory(ctx context.Context, hist []*OldHistoryType) error {
 	return WithTx(ctx, func(tx *TxWrap) error {
-		query := `DELETE FROM history_migrated`
-		tx.Exec(query)
-		query = `INSERT INTO history_migrated (historyid, ts, remotename, haderror, cmdstr, exitcode, durationms) 
-		                               VALUES (?, ?, ?, ?, ?, ?, ?)`
+		// old history ids are preserved, so re-running the migration does not create duplicates
+		query := `INSERT OR REPLACE INTO db_cmdhistory (historyid, ts, cmd, conn, exitcode, durationms)
+		                                        VALUES (?, ?, ?, ?, ?, ?)`
 		for _, hobj := range hist {
-			tx.Exec(query, hobj.HistoryId, hobj.Ts, hobj.RemoteName, hobj.HadError, hobj.CmdStr, hobj.ExitCode, hobj.DurationMs)
+			tx.Exec(query, hobj.HistoryId, hobj.Ts, hobj.CmdStr, hobj.RemoteName, hobj.ExitCode, hobj.DurationMs)
 		}
 		return nil
 	})